@@ -0,0 +1,145 @@
+package claude
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AuthMethod is how the CLI authenticates with the model provider.
+type AuthMethod string
+
+const (
+	// AuthMethodNone means no credentials were found.
+	AuthMethodNone AuthMethod = ""
+	// AuthMethodAPIKey is an Anthropic API key or auth token.
+	AuthMethodAPIKey AuthMethod = "api_key"
+	// AuthMethodOAuth is a Claude account login, or a token made with
+	// `claude setup-token`.
+	AuthMethodOAuth AuthMethod = "oauth"
+	// AuthMethodBedrock is Amazon Bedrock, with AWS credentials.
+	AuthMethodBedrock AuthMethod = "bedrock"
+	// AuthMethodVertex is Google Vertex AI, with Google Cloud credentials.
+	AuthMethodVertex AuthMethod = "vertex"
+)
+
+// authHint is the guidance given when no credentials are found.
+const authHint = "Log in with `claude login`, set ANTHROPIC_API_KEY, or create a token with " +
+	"`claude setup-token` and set CLAUDE_CODE_OAUTH_TOKEN."
+
+// AuthInfo describes the credentials the CLI uses.
+type AuthInfo struct {
+	// Authenticated reports whether credentials were found.
+	Authenticated bool
+	Method        AuthMethod
+	// Source tells where the credentials come from, such as an
+	// environment variable or the source the CLI reports.
+	Source string
+
+	// Email, Organization and SubscriptionType describe the account, if
+	// the CLI reports them.
+	Email            string
+	Organization     string
+	SubscriptionType string
+
+	// Hint tells how to authenticate when Authenticated is false.
+	Hint string
+}
+
+// AuthStatus reports whether the CLI has credentials, so that a service
+// can fail fast at startup with guidance instead of failing its first
+// query with an authentication error. Options such as WithCLIPath and
+// WithEnv apply.
+//
+// The CLI is started to report the account it uses. If it does not, the
+// environment is checked for provider settings, API keys and tokens, and
+// the CLI's configuration directory for a stored login. Credentials are
+// not tried against the API, so an expired or revoked key still counts as
+// found.
+//
+// Example:
+//
+//	auth, err := claude.AuthStatus(ctx)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	if !auth.Authenticated {
+//	    log.Fatalf("Claude is not authenticated: %s", auth.Hint)
+//	}
+func AuthStatus(ctx context.Context, opts ...Option) (*AuthInfo, error) {
+	client := NewClient(opts...)
+	if err := client.Connect(ctx); err != nil {
+		return nil, err
+	}
+	defer func() { _ = client.Close() }()
+
+	return parseAuthInfo(client.GetServerInfo(), client.options), nil
+}
+
+// parseAuthInfo reads the account from the CLI's initialize response,
+// falling back to the environment and configuration of options.
+func parseAuthInfo(info map[string]any, o *Options) *AuthInfo {
+	env := func(key string) string {
+		if value, ok := o.Env[key]; ok {
+			return value
+		}
+		return os.Getenv(key)
+	}
+	enabled := func(key string) bool {
+		value := strings.ToLower(env(key))
+		return value == "1" || value == "true"
+	}
+
+	auth := &AuthInfo{}
+	account, _ := info["account"].(map[string]any)
+	auth.Email, _ = account["email"].(string)
+	auth.Organization, _ = account["organization"].(string)
+	auth.SubscriptionType, _ = account["subscriptionType"].(string)
+	apiKeySource, _ := account["apiKeySource"].(string)
+	tokenSource, _ := account["tokenSource"].(string)
+
+	switch {
+	case enabled("CLAUDE_CODE_USE_BEDROCK"):
+		auth.Method, auth.Source = AuthMethodBedrock, "CLAUDE_CODE_USE_BEDROCK"
+	case enabled("CLAUDE_CODE_USE_VERTEX"):
+		auth.Method, auth.Source = AuthMethodVertex, "CLAUDE_CODE_USE_VERTEX"
+	case apiKeySource != "" && apiKeySource != "none":
+		auth.Method, auth.Source = AuthMethodAPIKey, apiKeySource
+	case tokenSource != "" && tokenSource != "none":
+		auth.Method, auth.Source = AuthMethodOAuth, tokenSource
+	case account != nil:
+		// The CLI reported an account without credentials
+	case env("ANTHROPIC_API_KEY") != "":
+		auth.Method, auth.Source = AuthMethodAPIKey, "ANTHROPIC_API_KEY"
+	case env("ANTHROPIC_AUTH_TOKEN") != "":
+		auth.Method, auth.Source = AuthMethodAPIKey, "ANTHROPIC_AUTH_TOKEN"
+	case env("CLAUDE_CODE_OAUTH_TOKEN") != "":
+		auth.Method, auth.Source = AuthMethodOAuth, "CLAUDE_CODE_OAUTH_TOKEN"
+	default:
+		if path := credentialsPath(env("CLAUDE_CONFIG_DIR")); path != "" {
+			if _, err := os.Stat(path); err == nil {
+				auth.Method, auth.Source = AuthMethodOAuth, path
+			}
+		}
+	}
+
+	auth.Authenticated = auth.Method != AuthMethodNone
+	if !auth.Authenticated {
+		auth.Hint = authHint
+	}
+	return auth
+}
+
+// credentialsPath returns the file the CLI stores its login in, outside
+// of the macOS keychain.
+func credentialsPath(configDir string) string {
+	if configDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		configDir = filepath.Join(home, ".claude")
+	}
+	return filepath.Join(configDir, ".credentials.json")
+}
@@ -0,0 +1,105 @@
+package claude
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseAuthInfo(t *testing.T) {
+	for _, key := range []string{
+		"CLAUDE_CODE_USE_BEDROCK", "CLAUDE_CODE_USE_VERTEX", "ANTHROPIC_API_KEY",
+		"ANTHROPIC_AUTH_TOKEN", "CLAUDE_CODE_OAUTH_TOKEN",
+	} {
+		t.Setenv(key, "")
+	}
+	configDir := t.TempDir()
+	t.Setenv("CLAUDE_CONFIG_DIR", configDir)
+
+	tests := map[string]struct {
+		info   map[string]any
+		opts   []Option
+		method AuthMethod
+		source string
+	}{
+		"account api key": {
+			info:   map[string]any{"account": map[string]any{"apiKeySource": "ANTHROPIC_API_KEY"}},
+			method: AuthMethodAPIKey,
+			source: "ANTHROPIC_API_KEY",
+		},
+		"account login": {
+			info:   map[string]any{"account": map[string]any{"tokenSource": "claude.ai", "email": "dev@example.com"}},
+			method: AuthMethodOAuth,
+			source: "claude.ai",
+		},
+		"account without credentials": {
+			info:   map[string]any{"account": map[string]any{"apiKeySource": "none"}},
+			opts:   []Option{WithEnvVar("ANTHROPIC_API_KEY", "sk-test")},
+			method: AuthMethodNone,
+		},
+		"bedrock": {
+			opts:   []Option{WithEnvVar("CLAUDE_CODE_USE_BEDROCK", "1")},
+			method: AuthMethodBedrock,
+			source: "CLAUDE_CODE_USE_BEDROCK",
+		},
+		"env api key": {
+			opts:   []Option{WithEnvVar("ANTHROPIC_API_KEY", "sk-test")},
+			method: AuthMethodAPIKey,
+			source: "ANTHROPIC_API_KEY",
+		},
+		"env oauth token": {
+			opts:   []Option{WithEnvVar("CLAUDE_CODE_OAUTH_TOKEN", "token")},
+			method: AuthMethodOAuth,
+			source: "CLAUDE_CODE_OAUTH_TOKEN",
+		},
+		"nothing": {
+			method: AuthMethodNone,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			auth := parseAuthInfo(tt.info, NewOptions(tt.opts...))
+			if auth.Method != tt.method || auth.Source != tt.source {
+				t.Errorf("Expected %q from %q, got %q from %q", tt.method, tt.source, auth.Method, auth.Source)
+			}
+			if auth.Authenticated != (tt.method != AuthMethodNone) {
+				t.Errorf("Expected Authenticated %v, got %v", tt.method != AuthMethodNone, auth.Authenticated)
+			}
+			if !auth.Authenticated && auth.Hint == "" {
+				t.Error("Expected a hint when not authenticated")
+			}
+		})
+	}
+
+	// A stored login counts when the CLI reports no account
+	path := filepath.Join(configDir, ".credentials.json")
+	if err := os.WriteFile(path, []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if auth := parseAuthInfo(nil, NewOptions()); auth.Method != AuthMethodOAuth || auth.Source != path {
+		t.Errorf("Expected the stored login, got %+v", auth)
+	}
+}
+
+func TestAuthStatus(t *testing.T) {
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{"account":{"email":"dev@example.com","organization":"Example","subscriptionType":"max","tokenSource":"claude.ai"}}}}'
+cat > /dev/null
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	auth, err := AuthStatus(ctx, WithCLIPath(cli))
+	if err != nil {
+		t.Fatalf("AuthStatus failed: %v", err)
+	}
+	if !auth.Authenticated || auth.Method != AuthMethodOAuth || auth.Email != "dev@example.com" ||
+		auth.Organization != "Example" || auth.SubscriptionType != "max" {
+		t.Errorf("Unexpected auth info: %+v", auth)
+	}
+}
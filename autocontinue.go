@@ -0,0 +1,81 @@
+package claude
+
+import "sync"
+
+// defaultAutoContinueLoops is the number of follow-ups per turn allowed
+// when WithAutoContinueBudget does not set one.
+const defaultAutoContinueLoops = 10
+
+// autoContinue decides when a Client follows up on a turn, as configured
+// with WithAutoContinue.
+type autoContinue struct {
+	predicate  func(*ResultMessage) (string, bool)
+	maxLoops   int
+	maxCostUSD float64
+	locale     string
+
+	mu sync.Mutex
+	// loops counts the follow-ups sent in the current turn.
+	loops int
+}
+
+// newAutoContinue returns the auto-continue loop for options, or nil if it
+// is not enabled.
+func newAutoContinue(o *Options) *autoContinue {
+	if o.AutoContinue == nil {
+		return nil
+	}
+	maxLoops := o.AutoContinueMaxLoops
+	if maxLoops <= 0 {
+		maxLoops = defaultAutoContinueLoops
+	}
+	return &autoContinue{
+		predicate:  o.AutoContinue,
+		maxLoops:   maxLoops,
+		maxCostUSD: o.AutoContinueMaxCostUSD,
+		locale:     o.Locale,
+	}
+}
+
+// next returns the prompt to follow up on result with, or "" if the loop
+// ends. The event reports the follow-up, or why the loop stopped short of
+// what the predicate asked for; it is nil if the predicate ended the loop.
+func (a *autoContinue) next(result *ResultMessage) (string, *DiagnosticEvent) {
+	if a == nil || result.IsInterrupted() {
+		return "", nil
+	}
+	prompt, ok := a.predicate(result)
+	if !ok || prompt == "" {
+		return "", nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.loops >= a.maxLoops {
+		return "", &DiagnosticEvent{
+			Kind:    DiagnosticKindAutoContinue,
+			Message: localize(a.locale, MessageAutoContinueLoopBudget, a.loops),
+		}
+	}
+	if a.maxCostUSD > 0 && result.TotalCostUSD != nil && *result.TotalCostUSD >= a.maxCostUSD {
+		return "", &DiagnosticEvent{
+			Kind:    DiagnosticKindAutoContinue,
+			Message: localize(a.locale, MessageAutoContinueCostCeiling, a.loops, *result.TotalCostUSD, a.maxCostUSD),
+		}
+	}
+	a.loops++
+	return prompt, &DiagnosticEvent{
+		Kind:    DiagnosticKindAutoContinue,
+		Message: localize(a.locale, MessageAutoContinueFollowUp, a.loops, a.maxLoops, prompt),
+	}
+}
+
+// reset starts counting follow-ups for a new turn.
+func (a *autoContinue) reset() {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	a.loops = 0
+	a.mu.Unlock()
+}
@@ -0,0 +1,139 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAutoContinue_Disabled(t *testing.T) {
+	if a := newAutoContinue(NewOptions()); a != nil {
+		t.Error("Expected no auto-continue without a predicate")
+	}
+	var a *autoContinue
+	if prompt, event := a.next(&ResultMessage{}); prompt != "" || event != nil {
+		t.Error("Expected a nil auto-continue to do nothing")
+	}
+	a.reset()
+}
+
+func TestAutoContinue_LoopBudget(t *testing.T) {
+	a := newAutoContinue(NewOptions(
+		WithAutoContinue(func(*ResultMessage) (string, bool) { return "continue", true }),
+		WithAutoContinueBudget(2, 0),
+	))
+
+	for i := 1; i <= 2; i++ {
+		prompt, event := a.next(&ResultMessage{})
+		if prompt != "continue" || event == nil || event.Kind != DiagnosticKindAutoContinue {
+			t.Fatalf("Follow-up %d: expected a prompt and event, got %q %+v", i, prompt, event)
+		}
+	}
+	prompt, event := a.next(&ResultMessage{})
+	if prompt != "" || event == nil || !strings.Contains(event.Message, "loop budget") {
+		t.Errorf("Expected the loop to stop at its budget, got %q %+v", prompt, event)
+	}
+
+	// A new turn starts a new budget
+	a.reset()
+	if prompt, _ := a.next(&ResultMessage{}); prompt != "continue" {
+		t.Errorf("Expected a follow-up after reset, got %q", prompt)
+	}
+}
+
+func TestAutoContinue_DefaultLoops(t *testing.T) {
+	a := newAutoContinue(NewOptions(
+		WithAutoContinue(func(*ResultMessage) (string, bool) { return "continue", true }),
+	))
+	if a.maxLoops != defaultAutoContinueLoops {
+		t.Errorf("Expected %d loops, got %d", defaultAutoContinueLoops, a.maxLoops)
+	}
+}
+
+func TestAutoContinue_CostCeiling(t *testing.T) {
+	a := newAutoContinue(NewOptions(
+		WithAutoContinue(func(*ResultMessage) (string, bool) { return "continue", true }),
+		WithAutoContinueBudget(5, 1.0),
+	))
+
+	cheap, costly := 0.5, 1.0
+	if prompt, _ := a.next(&ResultMessage{TotalCostUSD: &cheap}); prompt != "continue" {
+		t.Errorf("Expected a follow-up under the ceiling, got %q", prompt)
+	}
+	prompt, event := a.next(&ResultMessage{TotalCostUSD: &costly})
+	if prompt != "" || event == nil || !strings.Contains(event.Message, "ceiling") {
+		t.Errorf("Expected the loop to stop at the ceiling, got %q %+v", prompt, event)
+	}
+}
+
+func TestAutoContinue_PredicateEndsLoop(t *testing.T) {
+	a := newAutoContinue(NewOptions(
+		WithAutoContinue(func(r *ResultMessage) (string, bool) { return "continue", r.Result != "done" }),
+	))
+	if prompt, event := a.next(&ResultMessage{Result: "done"}); prompt != "" || event != nil {
+		t.Errorf("Expected no follow-up, got %q %+v", prompt, event)
+	}
+	if prompt, event := a.next(&ResultMessage{Interrupted: true}); prompt != "" || event != nil {
+		t.Errorf("Expected no follow-up of an interrupted turn, got %q %+v", prompt, event)
+	}
+}
+
+func TestClient_AutoContinue(t *testing.T) {
+	dir := t.TempDir()
+	cli := writeStubCLI(t, fmt.Sprintf(`
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+n=0
+while read line; do
+  echo "$line" >> %[1]s/prompts
+  n=$((n+1))
+  echo '{"type":"result","subtype":"success","session_id":"s","result":"run '$n'"}'
+done
+`, dir))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(
+		WithCLIPath(cli),
+		WithAutoContinue(func(r *ResultMessage) (string, bool) {
+			return "Keep going", r.Result != "run 3"
+		}),
+	)
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.Query(ctx, "Fix the tests"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	var events []*DiagnosticEvent
+	var results []*ResultMessage
+	for msg := range client.ReceiveResponse(ctx) {
+		switch m := msg.(type) {
+		case *DiagnosticEvent:
+			events = append(events, m)
+		case *ResultMessage:
+			results = append(results, m)
+		}
+	}
+
+	if len(results) != 1 || results[0].Result != "run 3" {
+		t.Fatalf("Expected only the final result, got %+v", results)
+	}
+	if len(events) != 2 || events[0].Kind != DiagnosticKindAutoContinue {
+		t.Errorf("Expected 2 auto-continue events, got %+v", events)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, "prompts"))
+	prompts := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(prompts) != 3 || !strings.Contains(prompts[0], "Fix the tests") || !strings.Contains(prompts[2], "Keep going") {
+		t.Errorf("Unexpected prompts: %q", prompts)
+	}
+}
@@ -0,0 +1,266 @@
+// Package bench load-tests the SDK's protocol layer, so that performance
+// regressions are caught before release. It runs concurrent sessions over
+// a fake transport that plays the CLI: each session is initialized, sent a
+// prompt, and streamed stream events at a configured rate, which are
+// decoded, routed and parsed as the Client does:
+//
+//	result, err := bench.Run(ctx, bench.Config{Sessions: 100, Messages: 1000, Rate: 500})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	fmt.Println(result)
+//
+// The Result reports throughput, the latency from the transport to the
+// consumer of parsed messages, allocations per message and goroutine
+// counts. The claude-bench command runs it from the command line.
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	claude "github.com/afsharalex/claude-agent-sdk-go"
+	"github.com/afsharalex/claude-agent-sdk-go/internal/protocol"
+)
+
+// Config configures a run.
+type Config struct {
+	// Sessions is the number of concurrent sessions, 1 if zero.
+	Sessions int
+	// Messages is the number of stream events each session receives, 100
+	// if zero.
+	Messages int
+	// Rate is the number of messages per second each session receives. If
+	// zero, messages are sent as fast as they are consumed.
+	Rate float64
+	// PayloadSize is the size in bytes of the text of each stream event,
+	// 32 if zero.
+	PayloadSize int
+	// LowAllocParsing decodes messages as with claude.WithLowAllocParsing.
+	LowAllocParsing bool
+}
+
+func (c Config) withDefaults() Config {
+	if c.Sessions <= 0 {
+		c.Sessions = 1
+	}
+	if c.Messages <= 0 {
+		c.Messages = 100
+	}
+	if c.PayloadSize <= 0 {
+		c.PayloadSize = 32
+	}
+	return c
+}
+
+// Latency summarizes the latencies of messages.
+type Latency struct {
+	Mean time.Duration `json:"mean"`
+	P50  time.Duration `json:"p50"`
+	P90  time.Duration `json:"p90"`
+	P99  time.Duration `json:"p99"`
+	Max  time.Duration `json:"max"`
+}
+
+// Result is the outcome of a run.
+type Result struct {
+	Config Config `json:"config"`
+	// Messages is the number of messages received across sessions, and
+	// Throughput the number received per second.
+	Messages   int           `json:"messages"`
+	Duration   time.Duration `json:"duration"`
+	Throughput float64       `json:"throughput"`
+	// Latency is the time from a message being read by the transport to
+	// its parsed form reaching the consumer.
+	Latency Latency `json:"latency"`
+	// AllocsPerMessage and BytesPerMessage are the heap allocations of the
+	// run divided by the number of messages.
+	AllocsPerMessage float64 `json:"allocs_per_message"`
+	BytesPerMessage  float64 `json:"bytes_per_message"`
+	// PeakGoroutines is the largest number of goroutines seen during the
+	// run, and LeakedGoroutines the number still running after all
+	// sessions were closed, beyond those running before.
+	PeakGoroutines   int `json:"peak_goroutines"`
+	LeakedGoroutines int `json:"leaked_goroutines"`
+}
+
+// String formats the result as a short report.
+func (r *Result) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d sessions x %d messages", r.Config.Sessions, r.Config.Messages)
+	if r.Config.Rate > 0 {
+		fmt.Fprintf(&b, " at %g/s", r.Config.Rate)
+	}
+	if r.Config.LowAllocParsing {
+		b.WriteString(" (low-alloc parsing)")
+	}
+	fmt.Fprintf(&b, "\n  throughput  %.0f messages/s over %s\n", r.Throughput, r.Duration.Round(time.Millisecond))
+	fmt.Fprintf(&b, "  latency     mean %s  p50 %s  p90 %s  p99 %s  max %s\n",
+		r.Latency.Mean, r.Latency.P50, r.Latency.P90, r.Latency.P99, r.Latency.Max)
+	fmt.Fprintf(&b, "  allocations %.1f allocs/message, %.0f B/message\n", r.AllocsPerMessage, r.BytesPerMessage)
+	fmt.Fprintf(&b, "  goroutines  peak %d, leaked %d", r.PeakGoroutines, r.LeakedGoroutines)
+	return b.String()
+}
+
+// Run runs the sessions of cfg to completion and reports their
+// performance.
+func Run(ctx context.Context, cfg Config) (*Result, error) {
+	cfg = cfg.withDefaults()
+	result := &Result{Config: cfg}
+
+	runtime.GC()
+	baseGoroutines := runtime.NumGoroutine()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	var peak atomic.Int64
+	peak.Store(int64(baseGoroutines))
+	stopSampling := sampleGoroutines(&peak)
+
+	latencies := make([][]time.Duration, cfg.Sessions)
+	errs := make([]error, cfg.Sessions)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := range cfg.Sessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			latencies[i], errs[i] = runSession(ctx, cfg, i)
+		}()
+	}
+	wg.Wait()
+	result.Duration = time.Since(start)
+	stopSampling()
+
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+	result.PeakGoroutines = int(peak.Load())
+	result.LeakedGoroutines = settledGoroutines(baseGoroutines) - baseGoroutines
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("session %d: %w", i, err)
+		}
+	}
+
+	all := slices.Concat(latencies...)
+	result.Messages = len(all)
+	if result.Messages > 0 {
+		result.Throughput = float64(result.Messages) / result.Duration.Seconds()
+		result.AllocsPerMessage = float64(after.Mallocs-before.Mallocs) / float64(result.Messages)
+		result.BytesPerMessage = float64(after.TotalAlloc-before.TotalAlloc) / float64(result.Messages)
+		result.Latency = summarize(all)
+	}
+	return result, nil
+}
+
+// runSession runs one session and returns the latencies of its messages.
+func runSession(ctx context.Context, cfg Config, id int) ([]time.Duration, error) {
+	t := newFakeTransport(cfg, fmt.Sprintf("bench-%d", id))
+	if err := t.Connect(ctx); err != nil {
+		return nil, err
+	}
+	q := protocol.NewQuery(protocol.QueryConfig{
+		Transport:       t,
+		IsStreamingMode: true,
+	})
+	defer func() { _ = q.Close() }()
+	q.Start(ctx)
+
+	if _, err := q.Initialize(ctx); err != nil {
+		return nil, err
+	}
+	prompt, _ := json.Marshal(map[string]any{
+		"type":    "user",
+		"message": map[string]any{"role": "user", "content": "Go"},
+	})
+	if err := t.Write(ctx, string(prompt)+"\n"); err != nil {
+		return nil, err
+	}
+
+	latencies := make([]time.Duration, 0, cfg.Messages)
+	for data := range q.ReceiveMessages() {
+		if data["type"] == "error" {
+			return nil, fmt.Errorf("%v", data["error"])
+		}
+		msg, err := claude.ParseMessage(data)
+		if err != nil {
+			return nil, err
+		}
+		switch m := msg.(type) {
+		case *claude.StreamEvent:
+			latencies = append(latencies, t.latency(m.UUID))
+		case *claude.ResultMessage:
+			return latencies, nil
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("stream ended after %d of %d messages", len(latencies), cfg.Messages)
+}
+
+// sampleGoroutines records the largest number of goroutines in peak until
+// the returned function is called.
+func sampleGoroutines(peak *atomic.Int64) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if n := int64(runtime.NumGoroutine()); n > peak.Load() {
+					peak.Store(n)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// settledGoroutines waits briefly for goroutines of closed sessions to
+// exit, and returns the number left.
+func settledGoroutines(base int) int {
+	deadline := time.Now().Add(time.Second)
+	for {
+		n := runtime.NumGoroutine()
+		if n <= base || time.Now().After(deadline) {
+			return n
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// summarize computes the latency statistics of durations.
+func summarize(durations []time.Duration) Latency {
+	slices.Sort(durations)
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	at := func(p float64) time.Duration {
+		return durations[min(len(durations)-1, int(p*float64(len(durations))))]
+	}
+	return Latency{
+		Mean: total / time.Duration(len(durations)),
+		P50:  at(0.50),
+		P90:  at(0.90),
+		P99:  at(0.99),
+		Max:  durations[len(durations)-1],
+	}
+}
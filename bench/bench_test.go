@@ -0,0 +1,89 @@
+package bench
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, lowAlloc := range []bool{false, true} {
+		result, err := Run(ctx, Config{Sessions: 4, Messages: 200, LowAllocParsing: lowAlloc})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if result.Messages != 800 {
+			t.Errorf("Expected 800 messages, got %d", result.Messages)
+		}
+		if result.Throughput <= 0 || result.Latency.Max <= 0 || result.Latency.P50 > result.Latency.P99 {
+			t.Errorf("Unexpected measurements %+v", result)
+		}
+		if result.AllocsPerMessage <= 0 || result.PeakGoroutines <= 0 {
+			t.Errorf("Expected allocations and goroutines to be measured, got %+v", result)
+		}
+		if result.LeakedGoroutines > 0 {
+			t.Errorf("Expected no leaked goroutines, got %d", result.LeakedGoroutines)
+		}
+	}
+}
+
+func TestRun_Rate(t *testing.T) {
+	result, err := Run(context.Background(), Config{Sessions: 2, Messages: 20, Rate: 200})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	// 20 messages at 200 per second take at least 95ms
+	if result.Duration < 95*time.Millisecond {
+		t.Errorf("Expected messages to be paced, run took %s", result.Duration)
+	}
+	if !strings.Contains(result.String(), "at 200/s") {
+		t.Errorf("Expected the rate in the report, got %q", result.String())
+	}
+}
+
+func TestRun_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Run(ctx, Config{Messages: 10}); err == nil {
+		t.Error("Expected a cancelled run to fail")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	var durations []time.Duration
+	for i := 100; i >= 1; i-- {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+	latency := summarize(durations)
+	if latency.P50 != 51*time.Millisecond || latency.P99 != 100*time.Millisecond || latency.Max != 100*time.Millisecond {
+		t.Errorf("Unexpected percentiles %+v", latency)
+	}
+	if latency.Mean != 50500*time.Microsecond {
+		t.Errorf("Expected mean 50.5ms, got %s", latency.Mean)
+	}
+}
+
+// BenchmarkSession measures a session of stream events through the
+// protocol layer, per message.
+func BenchmarkSession(b *testing.B) {
+	for _, lowAlloc := range []bool{false, true} {
+		name := "encoding/json"
+		if lowAlloc {
+			name = "low-alloc"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			cfg := Config{Messages: 1000, LowAllocParsing: lowAlloc}.withDefaults()
+			for b.Loop() {
+				if _, err := runSession(context.Background(), cfg, 0); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*cfg.Messages), "ns/message")
+		})
+	}
+}
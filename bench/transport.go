@@ -0,0 +1,183 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/transport"
+)
+
+// fakeTransport plays the CLI for a session: it answers control requests
+// and, once sent a prompt, streams the session's messages as encoded JSON
+// lines, decoded as the subprocess transport decodes them.
+type fakeTransport struct {
+	cfg       Config
+	sessionID string
+	lines     [][]byte
+
+	// sentAt holds the time each message was read, indexed by its
+	// position. A message's entry is written before the message is sent
+	// and read after it is received.
+	sentAt []time.Time
+
+	mu      sync.Mutex
+	ready   bool
+	ch      chan transport.ReadResult
+	started bool
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+var _ transport.Transport = (*fakeTransport)(nil)
+
+func newFakeTransport(cfg Config, sessionID string) *fakeTransport {
+	text := strings.Repeat("x", cfg.PayloadSize)
+	lines := make([][]byte, cfg.Messages+1)
+	for i := range cfg.Messages {
+		lines[i] = fmt.Appendf(nil,
+			`{"type":"stream_event","uuid":"%d","session_id":%q,"parent_tool_use_id":null,`+
+				`"event":{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":%q}}}`,
+			i, sessionID, text)
+	}
+	lines[cfg.Messages] = fmt.Appendf(nil,
+		`{"type":"result","subtype":"success","session_id":%q,"duration_ms":1,"duration_api_ms":1,`+
+			`"is_error":false,"num_turns":1,"total_cost_usd":0}`, sessionID)
+
+	return &fakeTransport{
+		cfg:       cfg,
+		sessionID: sessionID,
+		lines:     lines,
+		sentAt:    make([]time.Time, cfg.Messages),
+		ch:        make(chan transport.ReadResult, 100),
+		done:      make(chan struct{}),
+	}
+}
+
+func (t *fakeTransport) Connect(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ready = true
+	return nil
+}
+
+// Write answers control requests and starts streaming on the first user
+// message.
+func (t *fakeTransport) Write(ctx context.Context, data string) error {
+	var message map[string]any
+	if err := json.Unmarshal([]byte(data), &message); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.ready {
+		return fmt.Errorf("transport is not ready for writing")
+	}
+
+	switch message["type"] {
+	case "control_request":
+		requestID, _ := message["request_id"].(string)
+		response := map[string]any{
+			"type": "control_response",
+			"response": map[string]any{
+				"subtype":    "success",
+				"request_id": requestID,
+				"response":   map[string]any{},
+			},
+		}
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			t.send(transport.ReadResult{Data: response})
+		}()
+	case "user":
+		if !t.started {
+			t.started = true
+			t.wg.Add(1)
+			go t.stream()
+		}
+	}
+	return nil
+}
+
+// stream sends the session's messages, paced at the configured rate.
+func (t *fakeTransport) stream() {
+	defer t.wg.Done()
+	decode, release := transport.NewDecoder(t.cfg.LowAllocParsing)
+	defer release()
+
+	start := time.Now()
+	for i, line := range t.lines {
+		if t.cfg.Rate > 0 {
+			next := start.Add(time.Duration(float64(i) / t.cfg.Rate * float64(time.Second)))
+			if wait := time.Until(next); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-t.done:
+					return
+				}
+			}
+		}
+
+		if i < len(t.sentAt) {
+			t.sentAt[i] = time.Now()
+		}
+		data, err := decode(line)
+		if !t.send(transport.ReadResult{Data: data, Error: err}) {
+			return
+		}
+	}
+}
+
+// send delivers a result unless the transport is closed.
+func (t *fakeTransport) send(result transport.ReadResult) bool {
+	select {
+	case t.ch <- result:
+		return true
+	case <-t.done:
+		return false
+	}
+}
+
+// latency returns the time since the message with uuid was read.
+func (t *fakeTransport) latency(uuid string) time.Duration {
+	i, err := strconv.Atoi(uuid)
+	if err != nil || i < 0 || i >= len(t.sentAt) {
+		return 0
+	}
+	return time.Since(t.sentAt[i])
+}
+
+func (t *fakeTransport) ReadMessages(ctx context.Context) <-chan transport.ReadResult {
+	return t.ch
+}
+
+func (t *fakeTransport) Close() error {
+	t.mu.Lock()
+	if !t.ready {
+		t.mu.Unlock()
+		return nil
+	}
+	t.ready = false
+	close(t.done)
+	t.mu.Unlock()
+
+	t.wg.Wait()
+	close(t.ch)
+	return nil
+}
+
+func (t *fakeTransport) IsReady() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ready
+}
+
+func (t *fakeTransport) EndInput() error {
+	return nil
+}
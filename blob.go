@@ -0,0 +1,217 @@
+package claude
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultLargeOutputThreshold is the size in bytes above which a
+// LargeOutputPolicy stores tool output instead of returning it inline.
+const DefaultLargeOutputThreshold = 256 << 10
+
+// BlobStore stores tool outputs too large to return inline.
+type BlobStore interface {
+	// Put stores data and returns a URI it can be read from.
+	Put(ctx context.Context, name, mimeType string, data []byte) (string, error)
+}
+
+// FileBlobStore stores blobs as files in a directory, which Claude can
+// read with the Read tool.
+type FileBlobStore struct {
+	// Dir is the directory the files are written to. Defaults to a
+	// claude-blobs directory in os.TempDir.
+	Dir string
+}
+
+// Put writes data to a new file and returns its file:// URI.
+func (s FileBlobStore) Put(ctx context.Context, name, mimeType string, data []byte) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	dir := s.Dir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "claude-blobs")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	f, err := os.CreateTemp(dir, "*-"+blobFileName(name, mimeType))
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(f.Name())}).String(), nil
+}
+
+// blobFileName makes name safe to use in a file name, adding an
+// extension for mimeType if it has none.
+func blobFileName(name, mimeType string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" {
+		name = "output"
+	}
+	if filepath.Ext(name) == "" {
+		if exts, _ := mime.ExtensionsByType(mimeType); len(exts) > 0 {
+			name += exts[0]
+		}
+	}
+	return name
+}
+
+// LargeOutputPolicy returns tool outputs above a size threshold by
+// reference: the output is put in a BlobStore and the tool result holds a
+// resource_link to it, along with a note telling Claude where to read it.
+// This keeps a tool returning megabytes of data from being truncated or
+// flooding the control channel.
+//
+// Example:
+//
+//	policy := claude.LargeOutputPolicy{Store: claude.FileBlobStore{Dir: "/tmp/tool-output"}}
+//	server := policy.WrapServer(claude.NewMCPServer("db", "1.0.0", tools))
+type LargeOutputPolicy struct {
+	// Threshold is the size in bytes above which outputs are stored.
+	// Defaults to DefaultLargeOutputThreshold.
+	Threshold int
+
+	// Store holds the stored outputs. Defaults to FileBlobStore{}.
+	Store BlobStore
+}
+
+func (p LargeOutputPolicy) threshold() int {
+	if p.Threshold > 0 {
+		return p.Threshold
+	}
+	return DefaultLargeOutputThreshold
+}
+
+func (p LargeOutputPolicy) store() BlobStore {
+	if p.Store != nil {
+		return p.Store
+	}
+	return FileBlobStore{}
+}
+
+// BlobResult returns data as a tool result: inline as text or an image if
+// it is within the threshold and of such a type, and as a link to the
+// stored data otherwise.
+func (p LargeOutputPolicy) BlobResult(ctx context.Context, name, mimeType string, data []byte) (MCPToolResult, error) {
+	if len(data) <= p.threshold() {
+		switch {
+		case strings.HasPrefix(mimeType, "text/") || mimeType == "application/json":
+			return TextResult(string(data)), nil
+		case strings.HasPrefix(mimeType, "image/"):
+			return ImageResult(base64.StdEncoding.EncodeToString(data), mimeType), nil
+		}
+	}
+	content, err := p.link(ctx, name, mimeType, data)
+	if err != nil {
+		return MCPToolResult{}, err
+	}
+	return MCPToolResult{Content: content}, nil
+}
+
+// Apply stores the text and image contents of result that are above the
+// threshold and replaces them with links.
+func (p LargeOutputPolicy) Apply(ctx context.Context, result MCPToolResult) (MCPToolResult, error) {
+	var content []MCPContent
+	for i, c := range result.Content {
+		var data []byte
+		name := fmt.Sprintf("output-%d", i+1)
+		mimeType := c.MimeType
+		switch {
+		case c.Type == "text" && len(c.Text) > p.threshold():
+			data = []byte(c.Text)
+			mimeType = "text/plain"
+		case c.Type == "image" && base64.StdEncoding.DecodedLen(len(c.Data)) > p.threshold():
+			decoded, err := base64.StdEncoding.DecodeString(c.Data)
+			if err != nil {
+				return MCPToolResult{}, fmt.Errorf("decoding image content: %w", err)
+			}
+			data = decoded
+		default:
+			content = append(content, c)
+			continue
+		}
+
+		link, err := p.link(ctx, name, mimeType, data)
+		if err != nil {
+			return MCPToolResult{}, err
+		}
+		content = append(content, link...)
+	}
+	result.Content = content
+	return result, nil
+}
+
+// Wrap returns a handler that applies the policy to the results of
+// handler.
+func (p LargeOutputPolicy) Wrap(handler MCPToolHandler) MCPToolHandler {
+	return func(ctx context.Context, args map[string]any) (MCPToolResult, error) {
+		result, err := handler(ctx, args)
+		if err != nil {
+			return result, err
+		}
+		return p.Apply(ctx, result)
+	}
+}
+
+// WrapServer returns a copy of server whose tools apply the policy to
+// their results.
+func (p LargeOutputPolicy) WrapServer(server *MCPServer) *MCPServer {
+	tools := make([]MCPTool, len(server.Tools()))
+	for i, tool := range server.Tools() {
+		tool.Handler = p.Wrap(tool.Handler)
+		tools[i] = tool
+	}
+	return NewMCPServer(server.Name(), server.Version(), tools)
+}
+
+// link stores data and returns the content referring to it.
+func (p LargeOutputPolicy) link(ctx context.Context, name, mimeType string, data []byte) ([]MCPContent, error) {
+	uri, err := p.store().Put(ctx, name, mimeType, data)
+	if err != nil {
+		return nil, fmt.Errorf("storing large tool output: %w", err)
+	}
+
+	location := uri
+	if u, err := url.Parse(uri); err == nil && u.Scheme == "file" {
+		location = filepath.FromSlash(u.Path)
+	}
+	return []MCPContent{
+		{
+			Type: "text",
+			Text: fmt.Sprintf("The output (%d bytes, %s) is too large to include and was saved to %s.", len(data), mimeType, location),
+		},
+		ResourceLink(uri, name, mimeType, int64(len(data))),
+	}, nil
+}
+
+// ResourceLink creates resource_link content, referring to content stored
+// outside the tool result.
+func ResourceLink(uri, name, mimeType string, size int64) MCPContent {
+	return MCPContent{
+		Type:     "resource_link",
+		URI:      uri,
+		Name:     name,
+		MimeType: mimeType,
+		Size:     size,
+	}
+}
@@ -0,0 +1,133 @@
+package claude
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// memoryBlobStore keeps blobs in memory.
+type memoryBlobStore struct {
+	blobs map[string][]byte
+}
+
+func (s *memoryBlobStore) Put(ctx context.Context, name, mimeType string, data []byte) (string, error) {
+	if s.blobs == nil {
+		s.blobs = make(map[string][]byte)
+	}
+	uri := "blob://" + name
+	s.blobs[uri] = data
+	return uri, nil
+}
+
+func TestFileBlobStore(t *testing.T) {
+	dir := t.TempDir()
+	uri, err := FileBlobStore{Dir: dir}.Put(context.Background(), "rows/2024", "application/json", []byte(`[1,2]`))
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		t.Fatalf("Expected a file URI, got %q", uri)
+	}
+	path := filepath.FromSlash(u.Path)
+	if filepath.Dir(path) != dir || !strings.HasSuffix(path, "rows_2024.json") {
+		t.Errorf("Unexpected path %s", path)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != `[1,2]` {
+		t.Errorf("Unexpected file contents %q, %v", data, err)
+	}
+}
+
+func TestLargeOutputPolicy_BlobResult(t *testing.T) {
+	store := &memoryBlobStore{}
+	policy := LargeOutputPolicy{Threshold: 10, Store: store}
+	ctx := context.Background()
+
+	result, err := policy.BlobResult(ctx, "small.txt", "text/plain", []byte("short"))
+	if err != nil || len(result.Content) != 1 || result.Content[0].Text != "short" {
+		t.Errorf("Expected small text inline, got %+v, %v", result, err)
+	}
+
+	result, err = policy.BlobResult(ctx, "dot.png", "image/png", []byte{1, 2, 3})
+	if err != nil || result.Content[0].Type != "image" || result.Content[0].Data != "AQID" {
+		t.Errorf("Expected small image inline, got %+v, %v", result, err)
+	}
+
+	result, err = policy.BlobResult(ctx, "archive.zip", "application/zip", []byte{1})
+	if err != nil || len(result.Content) != 2 || result.Content[1].Type != "resource_link" {
+		t.Errorf("Expected binary data by reference, got %+v, %v", result, err)
+	}
+
+	large := strings.Repeat("x", 20)
+	result, err = policy.BlobResult(ctx, "big.txt", "text/plain", []byte(large))
+	if err != nil {
+		t.Fatalf("BlobResult failed: %v", err)
+	}
+	link := result.Content[1]
+	if link.URI != "blob://big.txt" || link.Size != 20 || link.MimeType != "text/plain" {
+		t.Errorf("Unexpected link %+v", link)
+	}
+	if string(store.blobs[link.URI]) != large {
+		t.Error("Expected large output to be stored")
+	}
+	if !strings.Contains(result.Content[0].Text, "blob://big.txt") {
+		t.Errorf("Expected a note with the location, got %q", result.Content[0].Text)
+	}
+}
+
+func TestLargeOutputPolicy_WrapServer(t *testing.T) {
+	dir := t.TempDir()
+	large := strings.Repeat("row\n", 100)
+	image := base64.StdEncoding.EncodeToString(make([]byte, 100))
+
+	server := NewMCPServer("db", "1.0.0", []MCPTool{
+		Tool("dump", "", nil, func(ctx context.Context, args map[string]any) (MCPToolResult, error) {
+			return MCPToolResult{Content: []MCPContent{
+				{Type: "text", Text: "summary"},
+				{Type: "text", Text: large},
+				{Type: "image", Data: image, MimeType: "image/png"},
+			}}, nil
+		}),
+		Tool("fail", "", nil, func(ctx context.Context, args map[string]any) (MCPToolResult, error) {
+			return MCPToolResult{}, errors.New("boom")
+		}),
+	})
+	wrapped := LargeOutputPolicy{Threshold: 50, Store: FileBlobStore{Dir: dir}}.WrapServer(server)
+
+	if wrapped.Name() != "db" || len(wrapped.Tools()) != 2 {
+		t.Fatalf("Expected the server's tools, got %+v", wrapped.Tools())
+	}
+
+	result, err := wrapped.Tools()[0].Handler(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Handler failed: %v", err)
+	}
+	var types []string
+	for _, c := range result.Content {
+		types = append(types, c.Type)
+	}
+	if got := strings.Join(types, ","); got != "text,text,resource_link,text,resource_link" {
+		t.Fatalf("Unexpected content %s", got)
+	}
+	if result.Content[0].Text != "summary" {
+		t.Error("Expected small content to be kept inline")
+	}
+	u, _ := url.Parse(result.Content[2].URI)
+	if data, err := os.ReadFile(filepath.FromSlash(u.Path)); err != nil || string(data) != large {
+		t.Errorf("Expected large text in the linked file, got %v", err)
+	}
+	if result.Content[4].MimeType != "image/png" || result.Content[4].Size != 100 {
+		t.Errorf("Expected decoded image to be stored, got %+v", result.Content[4])
+	}
+
+	if _, err := wrapped.Tools()[1].Handler(context.Background(), nil); err == nil {
+		t.Error("Expected handler errors to be passed on")
+	}
+}
@@ -0,0 +1,295 @@
+package claude
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache stores the messages of Query results, so that a query repeated
+// with the same prompt and options is answered without running the CLI.
+// Implementations decide how long entries are kept.
+type Cache interface {
+	// Get returns the value stored under key, if it is present and has not
+	// expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key.
+	Set(ctx context.Context, key string, value []byte) error
+}
+
+// MemoryCache is a Cache held in memory. It is safe for concurrent use.
+type MemoryCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+}
+
+type memoryCacheEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryCache returns a MemoryCache whose entries expire after ttl. A
+// ttl of zero keeps entries for the life of the cache.
+func NewMemoryCache(ttl time.Duration) *MemoryCache {
+	return &MemoryCache{ttl: ttl, now: time.Now, entries: make(map[string]memoryCacheEntry)}
+}
+
+// Get returns the value stored under key.
+func (c *MemoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if !entry.expires.IsZero() && !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+// Set stores value under key.
+func (c *MemoryCache) Set(ctx context.Context, key string, value []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := memoryCacheEntry{value: value}
+	if c.ttl > 0 {
+		entry.expires = c.now().Add(c.ttl)
+	}
+	c.entries[key] = entry
+	return nil
+}
+
+// FileCache is a Cache stored as files in a directory, which persists
+// across runs of a program, such as a test suite.
+type FileCache struct {
+	// Dir is the directory the entries are written to. Defaults to a
+	// claude-cache directory in os.UserCacheDir.
+	Dir string
+	// TTL is how long entries are kept, judged by the modification time
+	// of their files. Zero keeps them until they are deleted.
+	TTL time.Duration
+}
+
+// Get reads the entry stored under key.
+func (c FileCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	path, err := c.path(key)
+	if err != nil {
+		return nil, false, err
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if c.TTL > 0 && time.Since(info.ModTime()) >= c.TTL {
+		_ = os.Remove(path)
+		return nil, false, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// Set writes the entry stored under key, replacing it atomically.
+func (c FileCache) Set(ctx context.Context, key string, value []byte) error {
+	path, err := c.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(value); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// path returns the file of the entry stored under key.
+func (c FileCache) path(key string) (string, error) {
+	dir := c.Dir
+	if dir == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(base, "claude-cache")
+	}
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".json"), nil
+}
+
+// queryCache caches the messages of one Query. A nil queryCache is valid
+// and caches nothing.
+type queryCache struct {
+	cache    Cache
+	key      string
+	messages []Message
+	// intercept is applied to replayed messages, as to live ones.
+	intercept func(Message) Message
+}
+
+// newQueryCache returns the cache for a Query of prompt, or nil if no
+// cache is configured or the query depends on an earlier session.
+func newQueryCache(prompt string, o *Options) *queryCache {
+	if o.Cache == nil || o.ContinueConversation || o.Resume != "" || o.ResumeSessionAt != "" || o.ForkSession {
+		return nil
+	}
+	key, err := queryCacheKey(prompt, o)
+	if err != nil {
+		return nil
+	}
+	return &queryCache{cache: o.Cache, key: key, intercept: o.intercept}
+}
+
+// queryCacheKey returns the cache key for a Query of prompt: its
+// normalized prompt and the options that affect the answer, as JSON.
+func queryCacheKey(prompt string, o *Options) (string, error) {
+	var servers any
+	switch s := o.MCPServers.(type) {
+	case string:
+		servers = s
+	case map[string]MCPServerConfig:
+		names := make([]string, 0, len(s))
+		for name := range s {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		servers = names
+	}
+
+	key, err := json.Marshal(map[string]any{
+		"prompt":                   strings.Join(strings.Fields(prompt), " "),
+		"model":                    o.Model,
+		"fallback_model":           o.FallbackModel,
+		"model_fallbacks":          o.ModelFallbacks,
+		"system_prompt":            o.SystemPrompt,
+		"append_system_prompt":     o.AppendSystemPrompt,
+		"system_prompt_fragments":  systemPromptFragments(o),
+		"tools":                    o.Tools,
+		"allowed_tools":            o.AllowedTools,
+		"disallowed_tools":         o.DisallowedTools,
+		"mcp_servers":              servers,
+		"mcp_servers_allowed":      o.MCPServersAllowed,
+		"permission_mode":          o.PermissionMode,
+		"max_turns":                o.MaxTurns,
+		"betas":                    o.Betas,
+		"cwd":                      o.Cwd,
+		"add_dirs":                 o.AddDirs,
+		"settings":                 o.Settings,
+		"setting_sources":          o.SettingSources,
+		"agents":                   o.Agents,
+		"plugins":                  o.Plugins,
+		"max_thinking_tokens":      o.MaxThinkingTokens,
+		"output_format":            o.OutputFormat,
+		"temperature":              o.Temperature,
+		"top_p":                    o.TopP,
+		"seed":                     o.Seed,
+		"extra_args":               o.ExtraArgs,
+		"include_partial_messages": o.IncludePartialMessages,
+	})
+	return string(key), err
+}
+
+// replay sends the cached messages for the query, if there are any, and
+// reports whether it did. Entries that cannot be read are ignored.
+func (c *queryCache) replay(ctx context.Context, messages chan<- Message) (bool, error) {
+	if c == nil {
+		return false, nil
+	}
+	value, ok, err := c.cache.Get(ctx, c.key)
+	if err != nil || !ok {
+		return false, nil
+	}
+
+	var encoded []map[string]any
+	if err := json.Unmarshal(value, &encoded); err != nil || len(encoded) == 0 {
+		return false, nil
+	}
+	parsed := make([]Message, 0, len(encoded))
+	for _, data := range encoded {
+		msg, err := ParseMessage(data)
+		if err != nil {
+			return false, nil
+		}
+		parsed = append(parsed, msg)
+	}
+	if _, ok := parsed[len(parsed)-1].(*ResultMessage); !ok {
+		return false, nil
+	}
+
+	for _, msg := range parsed {
+		if msg = c.intercept(msg); msg == nil {
+			continue
+		}
+		select {
+		case messages <- msg:
+		case <-ctx.Done():
+			return true, ctx.Err()
+		}
+	}
+	return true, nil
+}
+
+// record adds a message forwarded by the query. Results are left out, as
+// only the final one is forwarded; it is passed to store.
+func (c *queryCache) record(msg Message) {
+	if c == nil {
+		return
+	}
+	if _, ok := msg.(*ResultMessage); !ok {
+		c.messages = append(c.messages, msg)
+	}
+}
+
+// store caches the recorded messages and the final result, unless the
+// result reports an error.
+func (c *queryCache) store(ctx context.Context, result *ResultMessage) {
+	if c == nil || result.IsError {
+		return
+	}
+	encoded := make([]map[string]any, 0, len(c.messages)+1)
+	for _, msg := range append(c.messages, result) {
+		data, err := EncodeMessage(msg)
+		if err != nil {
+			return
+		}
+		encoded = append(encoded, data)
+	}
+	value, err := json.Marshal(encoded)
+	if err != nil {
+		return
+	}
+	_ = c.cache.Set(ctx, c.key, value)
+}
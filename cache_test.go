@@ -0,0 +1,164 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemoryCache(time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	if _, ok, _ := cache.Get(ctx, "k"); ok {
+		t.Error("Expected a miss on an empty cache")
+	}
+	_ = cache.Set(ctx, "k", []byte("v"))
+	if value, ok, _ := cache.Get(ctx, "k"); !ok || string(value) != "v" {
+		t.Errorf("Expected a hit, got %q, %v", value, ok)
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok, _ := cache.Get(ctx, "k"); ok {
+		t.Error("Expected the entry to expire")
+	}
+}
+
+func TestFileCache(t *testing.T) {
+	ctx := context.Background()
+	cache := FileCache{Dir: t.TempDir(), TTL: time.Hour}
+
+	if _, ok, err := cache.Get(ctx, "k"); ok || err != nil {
+		t.Errorf("Expected a miss, got %v, %v", ok, err)
+	}
+	if err := cache.Set(ctx, "k", []byte("v")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if value, ok, err := cache.Get(ctx, "k"); !ok || err != nil || string(value) != "v" {
+		t.Errorf("Expected a hit, got %q, %v, %v", value, ok, err)
+	}
+
+	// Entries older than the TTL are dropped
+	path, _ := cache.path("k")
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := cache.Get(ctx, "k"); ok {
+		t.Error("Expected the entry to expire")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected the expired file to be removed")
+	}
+}
+
+func TestQueryCacheKey(t *testing.T) {
+	key := func(prompt string, opts ...Option) string {
+		k, err := queryCacheKey(prompt, NewOptions(opts...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	base := key("What is  2+2?\n", WithModel("sonnet"))
+	if key(" What is 2+2? ", WithModel("sonnet")) != base {
+		t.Error("Expected whitespace to be normalized")
+	}
+	if key("What is 2+2?", WithModel("opus")) == base {
+		t.Error("Expected the model to be part of the key")
+	}
+	if key("What is 2+2?", WithModel("sonnet"), WithSystemPrompt("Be brief")) == base {
+		t.Error("Expected the system prompt to be part of the key")
+	}
+	guarded := key("What is 2+2?", WithModel("sonnet"), WithSystemPromptFragment("guardrails", "Never run rm", 0))
+	if guarded == base || key("What is 2+2?", WithModel("sonnet"), WithSystemPromptFragment("guardrails", "Never push", 0)) == guarded {
+		t.Error("Expected the system prompt fragments to be part of the key")
+	}
+	ordered := key("What is 2+2?", WithModel("sonnet"), WithSystemPromptFragment("a", "A", 0), WithSystemPromptFragment("b", "B", 1))
+	if key("What is 2+2?", WithModel("sonnet"), WithSystemPromptFragment("b", "B", 1), WithSystemPromptFragment("a", "A", 0)) != ordered {
+		t.Error("Expected fragments applied in any order to share a key")
+	}
+	if key("What is 2+2?", WithModel("sonnet"), WithStderr(func(string) {})) != base {
+		t.Error("Expected options that do not affect the answer to be ignored")
+	}
+
+	if newQueryCache("Hi", NewOptions(WithCache(NewMemoryCache(0)), WithResume("sess-1"))) != nil {
+		t.Error("Expected resumed queries not to be cached")
+	}
+}
+
+func TestQuery_Cache(t *testing.T) {
+	dir := t.TempDir()
+	cli := writeStubCLI(t, fmt.Sprintf(`
+echo run >> %s/runs
+echo '{"type":"assistant","message":{"model":"m","content":[{"type":"text","text":"4"}]}}'
+echo '{"type":"result","subtype":"success","session_id":"sess-1","total_cost_usd":0.01,"result":"4"}'
+`, dir))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cache := FileCache{Dir: filepath.Join(dir, "cache")}
+	query := func(prompt string, opts ...Option) []Message {
+		t.Helper()
+		messages, errs := Query(ctx, prompt, append([]Option{WithCLIPath(cli), WithCache(cache)}, opts...)...)
+		var received []Message
+		for msg := range messages {
+			received = append(received, msg)
+		}
+		if err := <-errs; err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		return received
+	}
+	runs := func() int {
+		data, _ := os.ReadFile(filepath.Join(dir, "runs"))
+		return strings.Count(string(data), "run")
+	}
+
+	first := query("What is 2+2?")
+	second := query("What is 2+2?")
+	if runs() != 1 {
+		t.Fatalf("Expected the second query to be answered from cache, got %d runs", runs())
+	}
+	if len(second) != len(first) || len(second) != 2 {
+		t.Fatalf("Expected the cached messages, got %+v", second)
+	}
+	if a, ok := second[0].(*AssistantMessage); !ok || a.Content[0].(TextBlock).Text != "4" {
+		t.Errorf("Unexpected cached assistant message %+v", second[0])
+	}
+	if r, ok := second[1].(*ResultMessage); !ok || r.Result != "4" || r.SessionID != "sess-1" {
+		t.Errorf("Unexpected cached result %+v", second[1])
+	}
+
+	query("What is 2+2?", WithModel("opus"))
+	if runs() != 2 {
+		t.Errorf("Expected a different model to miss the cache, got %d runs", runs())
+	}
+}
+
+func TestQuery_CacheSkipsErrors(t *testing.T) {
+	dir := t.TempDir()
+	cli := writeStubCLI(t, fmt.Sprintf(`
+echo run >> %s/runs
+echo '{"type":"result","subtype":"error_max_turns","is_error":true,"session_id":"sess-1"}'
+`, dir))
+
+	cache := NewMemoryCache(0)
+	for range 2 {
+		messages, errs := Query(context.Background(), "Loop", WithCLIPath(cli), WithCache(cache))
+		for range messages {
+		}
+		<-errs
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "runs")); strings.Count(string(data), "run") != 2 {
+		t.Errorf("Expected error results not to be cached, got %q", data)
+	}
+}
@@ -92,6 +92,13 @@ func toTransportOptions(o *Options) *transport.Options {
 				SOCKSProxyPort:      o.Sandbox.Network.SOCKSProxyPort,
 			}
 		}
+		if httpPort, socksPort := sandboxProxyPorts(o); o.Sandbox.Enabled && (httpPort != 0 || socksPort != 0) {
+			if sandbox.Network == nil {
+				sandbox.Network = &transport.SandboxNetworkConfig{}
+			}
+			sandbox.Network.HTTPProxyPort = httpPort
+			sandbox.Network.SOCKSProxyPort = socksPort
+		}
 		if o.Sandbox.IgnoreViolations != nil {
 			sandbox.IgnoreViolations = &transport.SandboxIgnoreViolations{
 				File:    o.Sandbox.IgnoreViolations.File,
@@ -100,6 +107,38 @@ func toTransportOptions(o *Options) *transport.Options {
 		}
 	}
 
+	var container *transport.ContainerOptions
+	if o.Container != nil {
+		container = &transport.ContainerOptions{
+			Runtime: o.Container.Runtime,
+			Image:   o.Container.Image,
+			Mounts:  o.Container.Mounts,
+			Env:     o.Container.Env,
+		}
+	}
+
+	var ssh *transport.SSHOptions
+	if o.SSH != nil {
+		ssh = &transport.SSHOptions{
+			Path:         o.SSH.Path,
+			Host:         o.SSH.Host,
+			Port:         o.SSH.Port,
+			User:         o.SSH.User,
+			IdentityFile: o.SSH.IdentityFile,
+			Options:      o.SSH.Options,
+			Env:          o.SSH.Env,
+		}
+	}
+
+	var proxy *transport.ProxyOptions
+	if o.HTTPProxy != "" || len(o.NoProxy) > 0 || o.CABundle != "" {
+		proxy = &transport.ProxyOptions{
+			HTTPProxy: o.HTTPProxy,
+			NoProxy:   o.NoProxy,
+			CABundle:  o.CABundle,
+		}
+	}
+
 	agents := make(map[string]transport.AgentDefinition)
 	for k, v := range o.Agents {
 		agents[k] = transport.AgentDefinition{
@@ -118,65 +157,15 @@ func toTransportOptions(o *Options) *transport.Options {
 		})
 	}
 
-	// Convert MCP servers to map[string]any for transport
-	var mcpServers any
-	if o.MCPServers != nil {
-		if servers, ok := o.MCPServers.(map[string]MCPServerConfig); ok {
-			serversMap := make(map[string]any)
-			for name, config := range servers {
-				switch c := config.(type) {
-				case MCPStdioServerConfig:
-					serversMap[name] = map[string]any{
-						"type":    c.GetType(),
-						"command": c.Command,
-						"args":    c.Args,
-						"env":     c.Env,
-					}
-				case MCPSSEServerConfig:
-					serversMap[name] = map[string]any{
-						"type":    "sse",
-						"url":     c.URL,
-						"headers": c.Headers,
-					}
-				case MCPHTTPServerConfig:
-					serversMap[name] = map[string]any{
-						"type":    "http",
-						"url":     c.URL,
-						"headers": c.Headers,
-					}
-				case MCPSDKServerConfig:
-					serversMap[name] = map[string]any{
-						"type": "sdk",
-						"name": c.Name,
-					}
-				}
-			}
-			mcpServers = serversMap
-		} else if path, ok := o.MCPServers.(string); ok {
-			mcpServers = path
-		}
-	}
-
-	// Combine SystemPrompt and AppendSystemPrompt
-	var systemPrompt any = o.SystemPrompt
-	if o.AppendSystemPrompt != "" {
-		if systemPrompt == nil {
-			systemPrompt = o.AppendSystemPrompt
-		} else if sp, ok := systemPrompt.(string); ok {
-			systemPrompt = sp + "\n" + o.AppendSystemPrompt
-		}
-		// Note: If SystemPrompt is a SystemPromptPreset, AppendSystemPrompt is ignored
-		// as presets have their own append mechanism
-	}
-
 	return &transport.Options{
 		Tools:                    o.Tools,
-		AllowedTools:             o.AllowedTools,
-		SystemPrompt:             systemPrompt,
-		MCPServers:               mcpServers,
+		AllowedTools:             allowedTools(o),
+		SystemPrompt:             transportSystemPrompt(o),
+		MCPServers:               toTransportMCPServers(o.MCPServers),
 		PermissionMode:           string(o.PermissionMode),
 		ContinueConversation:     o.ContinueConversation,
 		Resume:                   o.Resume,
+		ResumeSessionAt:          o.ResumeSessionAt,
 		MaxTurns:                 o.MaxTurns,
 		MaxBudgetUSD:             o.MaxBudgetUSD,
 		DisallowedTools:          o.DisallowedTools,
@@ -191,6 +180,9 @@ func toTransportOptions(o *Options) *transport.Options {
 		Env:                      o.Env,
 		ExtraArgs:                o.ExtraArgs,
 		MaxBufferSize:            o.MaxBufferSize,
+		MaxStderrLineSize:        o.MaxStderrLineSize,
+		MaxControlResponseSize:   o.MaxControlResponseSize,
+		LowAllocParsing:          o.LowAllocParsing,
 		DebugStderr:              o.DebugStderr,
 		Stderr:                   o.Stderr,
 		User:                     o.User,
@@ -203,6 +195,15 @@ func toTransportOptions(o *Options) *transport.Options {
 		MaxThinkingTokens:        o.MaxThinkingTokens,
 		OutputFormat:             o.OutputFormat,
 		EnableFileCheckpointing:  o.EnableFileCheckpointing,
+		Temperature:              o.Temperature,
+		TopP:                     o.TopP,
+		Seed:                     o.Seed,
+		Container:                container,
+		SSH:                      ssh,
+		Proxy:                    proxy,
+		TempDir:                  o.TempDir,
+		WireLog:                  o.WireLog,
+		WireLogRedact:            o.WireLogRedact,
 	}
 }
 
@@ -249,45 +250,25 @@ func toPublicHookInput(input types.HookInput) HookInput {
 	switch v := input.(type) {
 	case types.PreToolUseHookInput:
 		return PreToolUseHookInput{
-			BaseHookInput: BaseHookInput{
-				SessionID:      v.SessionID,
-				TranscriptPath: v.TranscriptPath,
-				Cwd:            v.Cwd,
-				PermissionMode: v.PermissionMode,
-			},
-			ToolName:  v.ToolName,
-			ToolInput: v.ToolInput,
+			BaseHookInput: toPublicBaseHookInput(v.BaseHookInput),
+			ToolName:      v.ToolName,
+			ToolInput:     v.ToolInput,
 		}
 	case types.PostToolUseHookInput:
 		return PostToolUseHookInput{
-			BaseHookInput: BaseHookInput{
-				SessionID:      v.SessionID,
-				TranscriptPath: v.TranscriptPath,
-				Cwd:            v.Cwd,
-				PermissionMode: v.PermissionMode,
-			},
-			ToolName:     v.ToolName,
-			ToolInput:    v.ToolInput,
-			ToolResponse: v.ToolResponse,
+			BaseHookInput: toPublicBaseHookInput(v.BaseHookInput),
+			ToolName:      v.ToolName,
+			ToolInput:     v.ToolInput,
+			ToolResponse:  v.ToolResponse,
 		}
 	case types.UserPromptSubmitHookInput:
 		return UserPromptSubmitHookInput{
-			BaseHookInput: BaseHookInput{
-				SessionID:      v.SessionID,
-				TranscriptPath: v.TranscriptPath,
-				Cwd:            v.Cwd,
-				PermissionMode: v.PermissionMode,
-			},
-			Prompt: v.Prompt,
+			BaseHookInput: toPublicBaseHookInput(v.BaseHookInput),
+			Prompt:        v.Prompt,
 		}
 	case types.StopHookInput:
 		return StopHookInput{
-			BaseHookInput: BaseHookInput{
-				SessionID:      v.SessionID,
-				TranscriptPath: v.TranscriptPath,
-				Cwd:            v.Cwd,
-				PermissionMode: v.PermissionMode,
-			},
+			BaseHookInput:  toPublicBaseHookInput(v.BaseHookInput),
 			StopHookActive: v.StopHookActive,
 		}
 	default:
@@ -295,6 +276,17 @@ func toPublicHookInput(input types.HookInput) HookInput {
 	}
 }
 
+// toPublicBaseHookInput converts the fields common to hook inputs.
+func toPublicBaseHookInput(b types.BaseHookInput) BaseHookInput {
+	return BaseHookInput{
+		SessionID:      b.SessionID,
+		TranscriptPath: b.TranscriptPath,
+		Cwd:            b.Cwd,
+		PermissionMode: b.PermissionMode,
+		raw:            b.Raw,
+	}
+}
+
 // toInternalHookOutput converts public hook output to internal type.
 func toInternalHookOutput(output HookOutput) types.HookOutput {
 	result := types.HookOutput{
@@ -313,24 +305,33 @@ func toInternalHookOutput(output HookOutput) types.HookOutput {
 		case PreToolUseHookSpecificOutput:
 			result.HookEventName = types.HookEvent(v.HookEventName)
 			result.PermissionDecision = types.HookPermissionDecision(v.PermissionDecision)
+			result.PermissionReason = v.PermissionDecisionReason
 			result.UpdatedInput = v.UpdatedInput
 		case PostToolUseHookSpecificOutput:
 			result.HookEventName = types.HookEvent(v.HookEventName)
 			result.AdditionalContext = v.AdditionalContext
+		case PostToolUseFailureHookSpecificOutput:
+			result.HookEventName = types.HookEvent(v.HookEventName)
+			result.AdditionalContext = v.AdditionalContext
+		case UserPromptSubmitHookSpecificOutput:
+			result.HookEventName = types.HookEvent(v.HookEventName)
+			result.AdditionalContext = v.AdditionalContext
 		}
 	}
 
 	return result
 }
 
-// toInternalCanUseTool converts public callback to internal type.
-func toInternalCanUseTool(fn CanUseToolFunc) types.CanUseToolFunc {
+// toInternalCanUseTool converts public callback to internal type, writing
+// the guidance of denials in locale.
+func toInternalCanUseTool(fn CanUseToolFunc, locale string) types.CanUseToolFunc {
 	if fn == nil {
 		return nil
 	}
 	return func(ctx context.Context, toolName string, input map[string]any, permCtx types.ToolPermissionContext) (types.PermissionResult, error) {
 		publicCtx := ToolPermissionContext{
-			Signal: permCtx.Signal,
+			Signal:      permCtx.Signal,
+			Suggestions: toPublicPermissionUpdates(permCtx.Suggestions),
 		}
 
 		result, err := fn(ctx, toolName, input, publicCtx)
@@ -341,19 +342,188 @@ func toInternalCanUseTool(fn CanUseToolFunc) types.CanUseToolFunc {
 		switch r := result.(type) {
 		case PermissionResultAllow:
 			return types.PermissionResultAllow{
-				UpdatedInput: r.UpdatedInput,
+				UpdatedInput:       r.UpdatedInput,
+				UpdatedPermissions: toInternalPermissionUpdates(r.UpdatedPermissions),
 			}, nil
 		case PermissionResultDeny:
 			return types.PermissionResultDeny{
-				Message:   r.Message,
+				Message:   r.denyMessage(locale),
 				Interrupt: r.Interrupt,
 			}, nil
+		case PermissionResultAsk:
+			return types.PermissionResultAsk{
+				Message: r.Message,
+			}, nil
 		default:
 			return nil, NewClaudeSDKError("invalid permission result type")
 		}
 	}
 }
 
+// toPublicPermissionUpdates converts internal permission updates to the
+// public type.
+func toPublicPermissionUpdates(updates []types.PermissionUpdate) []PermissionUpdate {
+	if updates == nil {
+		return nil
+	}
+	result := make([]PermissionUpdate, len(updates))
+	for i, u := range updates {
+		result[i] = PermissionUpdate{
+			Type:        PermissionUpdateType(u.Type),
+			Behavior:    PermissionBehavior(u.Behavior),
+			Mode:        PermissionMode(u.Mode),
+			Directories: u.Directories,
+			Destination: PermissionUpdateDestination(u.Destination),
+		}
+		for _, rule := range u.Rules {
+			result[i].Rules = append(result[i].Rules, PermissionRuleValue(rule))
+		}
+	}
+	return result
+}
+
+// toInternalPermissionUpdates converts public permission updates to the
+// internal type.
+func toInternalPermissionUpdates(updates []PermissionUpdate) []types.PermissionUpdate {
+	if updates == nil {
+		return nil
+	}
+	result := make([]types.PermissionUpdate, len(updates))
+	for i, u := range updates {
+		result[i] = types.PermissionUpdate{
+			Type:        types.PermissionUpdateType(u.Type),
+			Behavior:    types.PermissionBehavior(u.Behavior),
+			Mode:        types.PermissionMode(u.Mode),
+			Directories: u.Directories,
+			Destination: types.PermissionUpdateDestination(u.Destination),
+		}
+		for _, rule := range u.Rules {
+			result[i].Rules = append(result[i].Rules, types.PermissionRuleValue(rule))
+		}
+	}
+	return result
+}
+
+// resolveAsks passes the asks of fn to resolve, if set.
+func resolveAsks(fn CanUseToolFunc, resolve AskResolverFunc) CanUseToolFunc {
+	if fn == nil || resolve == nil {
+		return fn
+	}
+	return func(ctx context.Context, toolName string, input map[string]any, permCtx ToolPermissionContext) (PermissionResult, error) {
+		result, err := fn(ctx, toolName, input, permCtx)
+		if ask, ok := result.(PermissionResultAsk); ok && err == nil {
+			return resolve(ctx, toolName, input, permCtx, ask)
+		}
+		return result, err
+	}
+}
+
+// transportSystemPrompt combines SystemPrompt, AppendSystemPrompt and
+// the system prompt fragments. Appended text keeps the prompt it is
+// appended to: a string prompt, a preset, or, if neither is set, the
+// claude_code preset, the default prompt of the CLI.
+func transportSystemPrompt(o *Options) any {
+	appendText := joinPrompt(o.AppendSystemPrompt, systemPromptFragments(o))
+	switch sp := o.SystemPrompt.(type) {
+	case string:
+		return joinPrompt(sp, appendText)
+	case *SystemPromptPreset:
+		if sp != nil {
+			return &transport.SystemPromptPreset{
+				Type:   sp.Type,
+				Preset: sp.Preset,
+				Append: joinPrompt(sp.Append, appendText),
+			}
+		}
+	case nil:
+	default:
+		return o.SystemPrompt
+	}
+
+	if appendText == "" {
+		return nil
+	}
+	return &transport.SystemPromptPreset{Type: "preset", Preset: "claude_code", Append: appendText}
+}
+
+// joinPrompt joins two parts of a prompt with a newline, leaving out
+// empty parts.
+func joinPrompt(a, b string) string {
+	if a == "" || b == "" {
+		return a + b
+	}
+	return a + "\n" + b
+}
+
+// toTransportMCPServers converts MCP servers to the map[string]any or
+// config file path passed to the transport.
+func toTransportMCPServers(mcpConfig any) any {
+	var mcpServers any
+	if mcpConfig != nil {
+		if servers, ok := mcpConfig.(map[string]MCPServerConfig); ok {
+			serversMap := make(map[string]any)
+			for name, config := range servers {
+				switch c := config.(type) {
+				case MCPStdioServerConfig:
+					serversMap[name] = map[string]any{
+						"type":    c.GetType(),
+						"command": c.Command,
+						"args":    c.Args,
+						"env":     c.Env,
+					}
+				case MCPSSEServerConfig:
+					serversMap[name] = map[string]any{
+						"type":    "sse",
+						"url":     c.URL,
+						"headers": c.Headers,
+					}
+				case MCPHTTPServerConfig:
+					serversMap[name] = map[string]any{
+						"type":    "http",
+						"url":     c.URL,
+						"headers": c.Headers,
+					}
+				case MCPSDKServerConfig:
+					serversMap[name] = map[string]any{
+						"type": "sdk",
+						"name": c.Name,
+					}
+				}
+			}
+			mcpServers = serversMap
+		} else if path, ok := mcpConfig.(string); ok {
+			mcpServers = path
+		}
+	}
+	return mcpServers
+}
+
+// allowedTools returns the allowed tools, including the tools of servers
+// allowed with WithMCPServerAllowed.
+func allowedTools(o *Options) []string {
+	if len(o.MCPServersAllowed) == 0 {
+		return o.AllowedTools
+	}
+
+	servers, _ := o.MCPServers.(map[string]MCPServerConfig)
+	sel := NewToolSelection()
+	for _, tool := range o.AllowedTools {
+		sel.Add(ToolName(tool))
+	}
+	for _, name := range o.MCPServersAllowed {
+		// The tools of SDK servers are known up front; other servers are
+		// allowed as a whole.
+		if config, ok := servers[name].(MCPSDKServerConfig); ok && config.Server != nil {
+			for _, tool := range config.Server.Tools() {
+				sel.AddMCP(name, tool.Name)
+			}
+			continue
+		}
+		sel.AddMCP(name)
+	}
+	return sel.Names()
+}
+
 // toInternalMCPServers converts public MCP servers to internal type.
 func toInternalMCPServers(servers map[string]MCPServerConfig) map[string]*types.MCPServer {
 	if servers == nil {
@@ -363,43 +533,55 @@ func toInternalMCPServers(servers map[string]MCPServerConfig) map[string]*types.
 	result := make(map[string]*types.MCPServer)
 	for name, config := range servers {
 		if sdkConfig, ok := config.(MCPSDKServerConfig); ok && sdkConfig.Server != nil {
-			var tools []types.MCPTool
-			for _, t := range sdkConfig.Server.Tools() {
-				tools = append(tools, types.MCPTool{
-					Name:        t.Name,
-					Description: t.Description,
-					InputSchema: t.InputSchema,
-					Handler: func(ctx context.Context, args map[string]any) (types.MCPToolResult, error) {
-						result, err := t.Handler(ctx, args)
-						if err != nil {
-							return types.MCPToolResult{}, err
-						}
-						var content []types.MCPContent
-						for _, c := range result.Content {
-							content = append(content, types.MCPContent{
-								Type:     c.Type,
-								Text:     c.Text,
-								Data:     c.Data,
-								MimeType: c.MimeType,
-							})
-						}
-						return types.MCPToolResult{
-							Content: content,
-							IsError: result.IsError,
-						}, nil
-					},
-				})
-			}
-			result[name] = &types.MCPServer{
-				Name:    sdkConfig.Server.Name(),
-				Version: sdkConfig.Server.Version(),
-				Tools:   tools,
-			}
+			result[name] = toInternalMCPServer(sdkConfig.Server)
 		}
 	}
 	return result
 }
 
+// toInternalMCPServer converts a public MCP server to internal type.
+func toInternalMCPServer(server *MCPServer) *types.MCPServer {
+	var tools []types.MCPTool
+	for _, t := range server.Tools() {
+		tools = append(tools, types.MCPTool{
+			Name:        t.Name,
+			Description: t.Description,
+			InputSchema: t.InputSchema,
+			Handler: func(ctx context.Context, args map[string]any) (types.MCPToolResult, error) {
+				var result MCPToolResult
+				var err error
+				if violations := server.checkInput(t, args); len(violations) > 0 {
+					result = invalidInputResult(t.Name, violations)
+				} else if result, err = t.Handler(ctx, args); err != nil {
+					return types.MCPToolResult{}, err
+				}
+				var content []types.MCPContent
+				for _, c := range result.Content {
+					content = append(content, types.MCPContent{
+						Type:     c.Type,
+						Text:     c.Text,
+						Data:     c.Data,
+						MimeType: c.MimeType,
+						URI:      c.URI,
+						Name:     c.Name,
+						Size:     c.Size,
+					})
+				}
+				return types.MCPToolResult{
+					Content:    content,
+					IsError:    result.IsError,
+					Structured: result.Structured,
+				}, nil
+			},
+		})
+	}
+	return &types.MCPServer{
+		Name:    server.Name(),
+		Version: server.Version(),
+		Tools:   tools,
+	}
+}
+
 // Query performs a one-shot query to Claude Code.
 func Query(ctx context.Context, prompt string, opts ...Option) (<-chan Message, <-chan error) {
 	messages := make(chan Message, 100)
@@ -410,54 +592,287 @@ func Query(ctx context.Context, prompt string, opts ...Option) (<-chan Message,
 		defer close(errors)
 
 		options := NewOptions(opts...)
-		transportOpts := toTransportOptions(options)
-
-		t, err := transport.NewSubprocessTransport(prompt, false, transportOpts)
+		files, err := loadContextFiles(options)
 		if err != nil {
 			errors <- err
 			return
 		}
+		options = files.systemOptions(options)
+		prompt = files.prompt(prompt)
 
-		if err := t.Connect(ctx); err != nil {
+		cache := newQueryCache(prompt, options)
+		if ok, err := cache.replay(ctx, messages); ok {
+			if err != nil {
+				errors <- err
+			}
+			return
+		}
+
+		validator := newStructuredOutputValidator(options)
+		turn := &queryTurn{
+			failover:   newModelFailover(options),
+			limits:     newRateLimitTracker(),
+			timer:      newTurnTimer(),
+			cache:      cache,
+			transcript: newTranscriptWriter(options),
+		}
+		defer func() { _ = turn.transcript.close() }()
+		failover := turn.failover
+		costs := newCostTracker(options)
+
+		if err := options.RateLimitPacer.Wait(ctx); err != nil {
 			errors <- err
 			return
 		}
-		defer func() { _ = t.Close() }()
+		if err := preflightMCP(ctx, options); err != nil {
+			errors <- err
+			return
+		}
+		turn.timer.begin()
 
-		q := protocol.NewQuery(protocol.QueryConfig{
-			Transport:       t,
-			IsStreamingMode: false,
-		})
-		defer func() { _ = q.Close() }()
+		for {
+			costs.newProcess()
+			result, err := runQuery(ctx, prompt, options, messages, turn)
+			if err != nil {
+				errors <- err
+				return
+			}
+			if result == nil {
+				return
+			}
+			costs.observe(result)
 
-		q.Start(ctx)
+			if model, event, ok := failover.next(result); ok {
+				// Retry the turn in the same session with the next model
+				if msg := options.intercept(event); msg != nil {
+					select {
+					case messages <- msg:
+					case <-ctx.Done():
+						errors <- ctx.Err()
+						return
+					}
+				}
+				options = resumeOptions(options, result.SessionID)
+				options.Model = model
+				continue
+			}
+			failover.annotate(result)
+			abortErr := failover.takeAborted()
+			rateErr := turn.limits.result()
+			options.RateLimitPacer.observe(rateErr)
+
+			repair, err := "", abortErr
+			if abortErr == nil {
+				repair, err = validator.check(result)
+			}
+			if repair != "" {
+				// Ask for a repair in the same session
+				options = resumeOptions(options, result.SessionID)
+				prompt = repair
+				continue
+			}
+			turn.timer.finish(result)
+
+			if msg := options.intercept(result); msg != nil {
+				select {
+				case messages <- msg:
+				case <-ctx.Done():
+					errors <- ctx.Err()
+					return
+				}
+			}
+			var errs []error
+			if rateErr != nil {
+				errs = append(errs, rateErr)
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+			if err := turn.transcript.close(); err != nil {
+				errs = append(errs, err)
+			}
+			if len(errs) > 0 {
+				errors <- joinErrors(errs)
+			} else {
+				cache.store(ctx, result)
+			}
+			return
+		}
+	}()
+
+	return messages, errors
+}
+
+// resumeOptions returns a copy of options that resumes sessionID, for
+// retrying a turn of Query in the same session.
+func resumeOptions(options *Options, sessionID string) *Options {
+	retry := *options
+	retry.Resume = sessionID
+	retry.ResumeSessionAt = ""
+	retry.ContinueConversation = false
+	retry.ForkSession = false
+	return &retry
+}
+
+// partialTurn collects the assistant and user messages of the turn in
+// progress, so they can be salvaged if the CLI exits mid-turn.
+type partialTurn struct {
+	messages []Message
+}
+
+func (p *partialTurn) observe(msg Message) {
+	switch msg.(type) {
+	case *AssistantMessage, *UserMessage:
+		p.messages = append(p.messages, msg)
+	case *ResultMessage:
+		p.messages = nil
+	}
+}
+
+// streamError converts an error message from the protocol layer. A CLI
+// that exited with an error becomes a ProcessError carrying the messages
+// of the unfinished turn.
+func (p *partialTurn) streamError(data map[string]any) error {
+	errMsg, _ := data["error"].(string)
+	if kind, ok := data["limit_kind"].(string); ok {
+		limit, _ := data["limit"].(int)
+		size, _ := data["size"].(int)
+		return NewBufferLimitError(BufferLimitKind(kind), limit, size)
+	}
+	exitCode, ok := data["exit_code"].(int)
+	if !ok {
+		return NewClaudeSDKError(errMsg)
+	}
+
+	stderr, _ := data["stderr"].(string)
+	err := NewProcessError("Claude Code process failed", exitCode, stderr)
+	err.PartialMessages = p.messages
+	return err
+}
+
+// queryTurn tracks a turn of Query across the CLI invocations it takes.
+type queryTurn struct {
+	failover   *modelFailover
+	limits     *rateLimitTracker
+	timer      *turnTimer
+	cache      *queryCache
+	transcript *transcriptWriter
+}
+
+// observe reports msg to the trackers of the turn.
+func (q *queryTurn) observe(msg Message) {
+	q.failover.observe(msg)
+	q.limits.observe(msg)
+	q.timer.observe(msg)
+	q.cache.record(msg)
+}
+
+// runQuery runs one CLI invocation for Query, forwarding its messages
+// except the ResultMessage, which it returns. All messages are reported
+// to turn.
+func runQuery(ctx context.Context, prompt string, options *Options, messages chan<- Message, turn *queryTurn) (*ResultMessage, error) {
+	if err := validateOptions(options); err != nil {
+		return nil, err
+	}
+
+	transportOpts := toTransportOptions(options)
+	if err := applyDeadline(ctx, options, transportOpts); err != nil {
+		return nil, err
+	}
+	forks := newForkRecorder(options.ConversationTree, transportOpts)
+
+	lock, err := acquireWorkspaceLock(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.release()
+
+	t, err := transport.NewSubprocessTransport(prompt, false, transportOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.Connect(ctx); err != nil {
+		return nil, err
+	}
+	defer func() { _ = t.Close() }()
+	turn.transcript.prompt(map[string]any{
+		"message": map[string]any{"role": "user", "content": prompt},
+	})
+
+	q := protocol.NewQuery(protocol.QueryConfig{
+		Transport:       t,
+		IsStreamingMode: false,
+	})
+	defer func() { _ = q.Close() }()
+
+	q.Start(ctx)
+
+	watchdog := newStallWatchdog(options)
+	watchdog.begin()
+	tick, stop := watchdog.ticker()
+	defer stop()
+
+	var last *ResultMessage
+	var partial partialTurn
+	received := q.ReceiveMessages()
+	for {
+		select {
+		case data, ok := <-received:
+			if !ok {
+				return last, nil
+			}
+			watchdog.touch()
 
-		for data := range q.ReceiveMessages() {
 			if data["type"] == "end" {
-				return
+				return last, nil
 			}
 			if data["type"] == "error" {
-				errMsg, _ := data["error"].(string)
-				errors <- NewClaudeSDKError(errMsg)
-				return
+				return nil, partial.streamError(data)
 			}
+			turn.transcript.observe(data)
 
 			msg, err := ParseMessage(data)
 			if err != nil {
-				errors <- err
-				return
+				return nil, err
+			}
+			partial.observe(msg)
+			turn.observe(msg)
+			forks.observe(msg)
+			if result, ok := msg.(*ResultMessage); ok {
+				watchdog.end()
+				last = result
+				continue
 			}
 
+			if msg = options.intercept(msg); msg == nil {
+				continue
+			}
 			select {
 			case messages <- msg:
 			case <-ctx.Done():
-				errors <- ctx.Err()
-				return
+				return nil, ctx.Err()
 			}
-		}
-	}()
 
-	return messages, errors
+		case now := <-tick:
+			event := watchdog.check(now)
+			if event == nil {
+				continue
+			}
+			if msg := options.intercept(event); msg != nil {
+				select {
+				case messages <- msg:
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			}
+			// A one-shot query cannot be interrupted, so any action
+			// beyond a diagnostic ends it.
+			if event.Action != StallActionDiagnostic {
+				return nil, NewStallError(event)
+			}
+		}
+	}
 }
 
 // QueryStreaming performs a streaming query to Claude Code.
@@ -471,16 +886,43 @@ func QueryStreaming(ctx context.Context, inputCh <-chan map[string]any, opts ...
 
 		options := NewOptions(opts...)
 
-		if options.CanUseTool != nil && options.PermissionPromptToolName != "" {
+		if permissionCallback(options, nil) != nil && options.PermissionPromptToolName != "" {
 			errors <- NewClaudeSDKError("can_use_tool callback cannot be used with permission_prompt_tool_name")
 			return
 		}
 
-		if options.CanUseTool != nil {
+		if err := validateOptions(options); err != nil {
+			errors <- err
+			return
+		}
+		if err := preflightMCP(ctx, options); err != nil {
+			errors <- err
+			return
+		}
+		files, err := loadContextFiles(options)
+		if err != nil {
+			errors <- err
+			return
+		}
+		options = files.systemOptions(options)
+
+		if permissionCallback(options, nil) != nil {
 			options.PermissionPromptToolName = "stdio"
 		}
 
 		transportOpts := toTransportOptions(options)
+		if err := applyDeadline(ctx, options, transportOpts); err != nil {
+			errors <- err
+			return
+		}
+		forks := newForkRecorder(options.ConversationTree, transportOpts)
+
+		lock, err := acquireWorkspaceLock(ctx, options)
+		if err != nil {
+			errors <- err
+			return
+		}
+		defer lock.release()
 
 		t, err := transport.NewSubprocessTransport("", true, transportOpts)
 		if err != nil {
@@ -499,45 +941,103 @@ func QueryStreaming(ctx context.Context, inputCh <-chan map[string]any, opts ...
 			sdkMCPServers = toInternalMCPServers(servers)
 		}
 
+		session := newSessionInfo(options)
 		q := protocol.NewQuery(protocol.QueryConfig{
 			Transport:       t,
 			IsStreamingMode: true,
-			CanUseTool:      toInternalCanUseTool(options.CanUseTool),
-			Hooks:           toInternalHooks(options.Hooks),
+			CanUseTool:      toInternalCanUseTool(permissionCallback(options, nil), options.Locale),
+			Hooks:           toInternalHooks(newDryRun(options).withHook(allHooks(options))),
 			SDKMCPServers:   sdkMCPServers,
+			HandlerContext:  session.context,
 		})
 		defer func() { _ = q.Close() }()
 
 		q.Start(ctx)
 
 		if _, err := q.Initialize(ctx); err != nil {
+			errors <- publicError(err)
+			return
+		}
+		if err := checkBetas(options.Betas, q.InitResult()); err != nil {
 			errors <- err
 			return
 		}
 
-		go q.StreamInput(ctx, inputCh)
-
-		for data := range q.ReceiveMessages() {
-			if data["type"] == "end" {
-				return
-			}
-			if data["type"] == "error" {
-				errMsg, _ := data["error"].(string)
-				errors <- NewClaudeSDKError(errMsg)
-				return
-			}
+		watchdog := newStallWatchdog(options)
+		costs := newCostTracker(options)
+		go q.StreamInput(ctx, watchdog.watchInput(ctx, files.input(ctx, inputCh)))
 
-			msg, err := ParseMessage(data)
-			if err != nil {
-				errors <- err
-				return
-			}
+		tick, stop := watchdog.ticker()
+		defer stop()
 
+		var partial partialTurn
+		received := q.ReceiveMessages()
+		for {
 			select {
-			case messages <- msg:
-			case <-ctx.Done():
-				errors <- ctx.Err()
-				return
+			case data, ok := <-received:
+				if !ok {
+					return
+				}
+				watchdog.touch()
+
+				if data["type"] == "end" {
+					return
+				}
+				if data["type"] == "error" {
+					errors <- partial.streamError(data)
+					return
+				}
+
+				msg, err := ParseMessage(data)
+				if err != nil {
+					errors <- err
+					return
+				}
+				partial.observe(msg)
+				session.observe(msg)
+				forks.observe(msg)
+				if result, ok := msg.(*ResultMessage); ok {
+					watchdog.end()
+					costs.observe(result)
+					costs.reset()
+				}
+
+				forward := []Message{msg}
+				for _, change := range session.takeModeChanges() {
+					forward = append(forward, change)
+				}
+				for _, m := range forward {
+					if m = options.intercept(m); m == nil {
+						continue
+					}
+					select {
+					case messages <- m:
+					case <-ctx.Done():
+						errors <- ctx.Err()
+						return
+					}
+				}
+
+			case now := <-tick:
+				event := watchdog.check(now)
+				if event == nil {
+					continue
+				}
+				if msg := options.intercept(event); msg != nil {
+					select {
+					case messages <- msg:
+					case <-ctx.Done():
+						errors <- ctx.Err()
+						return
+					}
+				}
+				switch event.Action {
+				case StallActionInterrupt:
+					go func() { _ = q.InterruptWithReason(ctx, "stall timeout") }()
+				case StallActionClose:
+					errors <- NewStallError(event)
+					return
+				}
 			}
 		}
 	}()
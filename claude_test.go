@@ -2,8 +2,16 @@ package claude
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/afsharalex/claude-agent-sdk-go/internal/transport"
 	"github.com/afsharalex/claude-agent-sdk-go/internal/types"
 )
 
@@ -58,6 +66,35 @@ func TestToTransportOptions_Betas(t *testing.T) {
 	}
 }
 
+func TestToTransportOptions_MCPServerAllowed(t *testing.T) {
+	handler := func(ctx context.Context, args map[string]any) (MCPToolResult, error) {
+		return TextResult("ok"), nil
+	}
+	calc := CreateSDKMCPServer("calculator", "1.0.0", []MCPTool{
+		Tool("add", "Add", nil, handler),
+		Tool("multiply", "Multiply", nil, handler),
+	})
+
+	opts := NewOptions(
+		WithMCPServers(map[string]MCPServerConfig{
+			"calc":  calc,
+			"files": MCPStdioServerConfig{Command: "files-server"},
+		}),
+		WithAllowedTools([]string{"Read", "mcp__calc__add"}),
+		WithMCPServerAllowed("calc", "files"),
+	)
+
+	result := toTransportOptions(opts)
+
+	want := []string{"Read", "mcp__calc__add", "mcp__calc__multiply", "mcp__files"}
+	if !reflect.DeepEqual(result.AllowedTools, want) {
+		t.Errorf("Expected allowed tools %v, got %v", want, result.AllowedTools)
+	}
+	if len(opts.AllowedTools) != 2 {
+		t.Errorf("Expected options to be unchanged, got %v", opts.AllowedTools)
+	}
+}
+
 func TestToTransportOptions_SettingSources(t *testing.T) {
 	opts := &Options{
 		SettingSources: []SettingSource{SettingSourceUser, SettingSourceProject},
@@ -440,6 +477,24 @@ func TestToPublicHookInput_Stop(t *testing.T) {
 	}
 }
 
+func TestToPublicHookInput_Raw(t *testing.T) {
+	raw := map[string]any{"hook_event_name": "PreToolUse", "tool_name": "Bash", "tool_metadata": map[string]any{"source": "plugin"}}
+	internal := types.PreToolUseHookInput{
+		BaseHookInput: types.BaseHookInput{SessionID: "session-raw", Raw: raw},
+		ToolName:      "Bash",
+	}
+
+	result := toPublicHookInput(internal)
+
+	metadata, _ := result.Raw()["tool_metadata"].(map[string]any)
+	if metadata["source"] != "plugin" {
+		t.Errorf("Expected the raw input to hold undecoded fields, got %v", result.Raw())
+	}
+	if (PreToolUseHookInput{}).Raw() != nil {
+		t.Error("Expected no raw input for an input built by hand")
+	}
+}
+
 func TestToPublicHookInput_Unknown(t *testing.T) {
 	// Use a type that's not handled
 	internal := types.SubagentStopHookInput{
@@ -492,9 +547,10 @@ func TestToInternalHookOutput_Basic(t *testing.T) {
 func TestToInternalHookOutput_PreToolUseSpecific(t *testing.T) {
 	output := HookOutput{
 		HookSpecificOutput: PreToolUseHookSpecificOutput{
-			HookEventName:      HookEventPreToolUse,
-			PermissionDecision: HookPermissionDecisionAllow,
-			UpdatedInput:       map[string]any{"modified": true},
+			HookEventName:            HookEventPreToolUse,
+			PermissionDecision:       HookPermissionDecisionAllow,
+			PermissionDecisionReason: "safe command",
+			UpdatedInput:             map[string]any{"modified": true},
 		},
 	}
 
@@ -509,6 +565,10 @@ func TestToInternalHookOutput_PreToolUseSpecific(t *testing.T) {
 	if result.UpdatedInput["modified"] != true {
 		t.Errorf("Expected UpdatedInput['modified'] to be true")
 	}
+	specific, _ := result.ToMap()["hookSpecificOutput"].(map[string]any)
+	if specific["permissionDecisionReason"] != "safe command" {
+		t.Errorf("Expected the permission decision reason sent, got %v", specific)
+	}
 }
 
 func TestToInternalHookOutput_PostToolUseSpecific(t *testing.T) {
@@ -529,8 +589,26 @@ func TestToInternalHookOutput_PostToolUseSpecific(t *testing.T) {
 	}
 }
 
+func TestToInternalHookOutput_UserPromptSubmitSpecific(t *testing.T) {
+	output := HookOutput{
+		HookSpecificOutput: UserPromptSubmitHookSpecificOutput{
+			HookEventName:     HookEventUserPromptSubmit,
+			AdditionalContext: "Remembered context",
+		},
+	}
+
+	result := toInternalHookOutput(output)
+
+	if result.HookEventName != types.HookEventUserPromptSubmit {
+		t.Errorf("Expected HookEventName 'UserPromptSubmit', got '%s'", result.HookEventName)
+	}
+	if result.AdditionalContext != "Remembered context" {
+		t.Errorf("Expected AdditionalContext 'Remembered context', got '%s'", result.AdditionalContext)
+	}
+}
+
 func TestToInternalCanUseTool_Nil(t *testing.T) {
-	result := toInternalCanUseTool(nil)
+	result := toInternalCanUseTool(nil, "")
 	if result != nil {
 		t.Errorf("Expected nil, got %v", result)
 	}
@@ -543,7 +621,7 @@ func TestToInternalCanUseTool_Allow(t *testing.T) {
 		}, nil
 	}
 
-	internal := toInternalCanUseTool(fn)
+	internal := toInternalCanUseTool(fn, "")
 	if internal == nil {
 		t.Fatal("Expected non-nil internal function")
 	}
@@ -562,6 +640,27 @@ func TestToInternalCanUseTool_Allow(t *testing.T) {
 	}
 }
 
+func TestToInternalCanUseTool_Suggestions(t *testing.T) {
+	fn := func(ctx context.Context, toolName string, input map[string]any, permCtx ToolPermissionContext) (PermissionResult, error) {
+		return permCtx.AcceptSuggestions(), nil
+	}
+	suggestions := []types.PermissionUpdate{{
+		Type:        types.PermissionUpdateTypeAddRules,
+		Rules:       []types.PermissionRuleValue{{ToolName: "Bash", RuleContent: "npm test:*"}},
+		Behavior:    types.PermissionBehaviorAllow,
+		Destination: types.PermissionUpdateDestinationLocalSettings,
+	}}
+
+	result, err := toInternalCanUseTool(fn, "")(context.Background(), "Bash", map[string]any{}, types.ToolPermissionContext{Suggestions: suggestions})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	allow, ok := result.(types.PermissionResultAllow)
+	if !ok || !reflect.DeepEqual(allow.UpdatedPermissions, suggestions) {
+		t.Errorf("Expected the suggestions passed back as updated permissions, got %+v", result)
+	}
+}
+
 func TestToInternalCanUseTool_Deny(t *testing.T) {
 	fn := func(ctx context.Context, toolName string, input map[string]any, permCtx ToolPermissionContext) (PermissionResult, error) {
 		return PermissionResultDeny{
@@ -570,7 +669,7 @@ func TestToInternalCanUseTool_Deny(t *testing.T) {
 		}, nil
 	}
 
-	internal := toInternalCanUseTool(fn)
+	internal := toInternalCanUseTool(fn, "")
 	result, err := internal(context.Background(), "Bash", map[string]any{}, types.ToolPermissionContext{})
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
@@ -588,6 +687,75 @@ func TestToInternalCanUseTool_Deny(t *testing.T) {
 	}
 }
 
+func TestToInternalCanUseTool_Ask(t *testing.T) {
+	fn := func(ctx context.Context, toolName string, input map[string]any, permCtx ToolPermissionContext) (PermissionResult, error) {
+		return PermissionResultAsk{Message: "Run the migration?"}, nil
+	}
+
+	internal := toInternalCanUseTool(fn, "")
+	result, err := internal(context.Background(), "Bash", map[string]any{}, types.ToolPermissionContext{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ask, ok := result.(types.PermissionResultAsk)
+	if !ok {
+		t.Fatalf("Expected PermissionResultAsk, got %T", result)
+	}
+	if ask.Message != "Run the migration?" {
+		t.Errorf("Expected message 'Run the migration?', got '%s'", ask.Message)
+	}
+}
+
+func TestResolveAsks(t *testing.T) {
+	// Reads are allowed, deploys are left to the resolver, the rest denied
+	fn := func(ctx context.Context, toolName string, input map[string]any, permCtx ToolPermissionContext) (PermissionResult, error) {
+		switch toolName {
+		case "Read":
+			return PermissionResultAllow{}, nil
+		case "Bash":
+			return PermissionResultAsk{Message: input["command"].(string)}, nil
+		}
+		return PermissionResultDeny{Message: "not allowed"}, nil
+	}
+	var asked []string
+	resolver := func(ctx context.Context, toolName string, input map[string]any, permCtx ToolPermissionContext, ask PermissionResultAsk) (PermissionResult, error) {
+		asked = append(asked, ask.Message)
+		if ask.Message == "make deploy" {
+			return PermissionResultAllow{}, nil
+		}
+		return ask, nil
+	}
+
+	if resolveAsks(nil, resolver) != nil {
+		t.Error("Expected no callback without CanUseTool")
+	}
+
+	resolved := resolveAsks(fn, resolver)
+	tests := []struct {
+		tool  string
+		input map[string]any
+		want  PermissionResult
+	}{
+		{"Read", nil, PermissionResultAllow{}},
+		{"Write", nil, PermissionResultDeny{Message: "not allowed"}},
+		{"Bash", map[string]any{"command": "make deploy"}, PermissionResultAllow{}},
+		{"Bash", map[string]any{"command": "rm -rf build"}, PermissionResultAsk{Message: "rm -rf build"}},
+	}
+	for _, tt := range tests {
+		result, err := resolved(context.Background(), tt.tool, tt.input, ToolPermissionContext{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(result, tt.want) {
+			t.Errorf("%s %v: expected %#v, got %#v", tt.tool, tt.input, tt.want, result)
+		}
+	}
+	if want := []string{"make deploy", "rm -rf build"}; !reflect.DeepEqual(asked, want) {
+		t.Errorf("Expected the resolver to see %v, got %v", want, asked)
+	}
+}
+
 func TestToInternalMCPServers_Nil(t *testing.T) {
 	result := toInternalMCPServers(nil)
 	if result != nil {
@@ -703,7 +871,7 @@ func TestToInternalCanUseTool_WithContext(t *testing.T) {
 		return PermissionResultAllow{}, nil
 	}
 
-	internal := toInternalCanUseTool(fn)
+	internal := toInternalCanUseTool(fn, "")
 	result, err := internal(context.Background(), "Test", map[string]any{}, types.ToolPermissionContext{
 		Signal:      nil,
 		Suggestions: []types.PermissionUpdate{},
@@ -791,7 +959,7 @@ func TestToInternalCanUseTool_Error(t *testing.T) {
 		return nil, expectedErr
 	}
 
-	internal := toInternalCanUseTool(fn)
+	internal := toInternalCanUseTool(fn, "")
 	result, err := internal(context.Background(), "Test", map[string]any{}, types.ToolPermissionContext{})
 
 	if err != expectedErr {
@@ -812,7 +980,7 @@ func TestToInternalCanUseTool_InvalidResultType(t *testing.T) {
 		return mockPermissionResult{}, nil
 	}
 
-	internal := toInternalCanUseTool(fn)
+	internal := toInternalCanUseTool(fn, "")
 	result, err := internal(context.Background(), "Test", map[string]any{}, types.ToolPermissionContext{})
 
 	if err == nil {
@@ -1066,15 +1234,19 @@ func TestToTransportOptions_DisallowedTools(t *testing.T) {
 }
 
 func TestToTransportOptions_AppendSystemPrompt(t *testing.T) {
-	t.Run("AppendSystemPrompt becomes SystemPrompt when SystemPrompt is nil", func(t *testing.T) {
+	t.Run("AppendSystemPrompt appends to the CLI default when SystemPrompt is nil", func(t *testing.T) {
 		opts := &Options{
 			AppendSystemPrompt: "Additional instructions",
 		}
 
 		result := toTransportOptions(opts)
 
-		if result.SystemPrompt != "Additional instructions" {
-			t.Errorf("Expected SystemPrompt 'Additional instructions', got '%v'", result.SystemPrompt)
+		preset, ok := result.SystemPrompt.(*transport.SystemPromptPreset)
+		if !ok {
+			t.Fatalf("Expected a preset, got %T", result.SystemPrompt)
+		}
+		if preset.Type != "preset" || preset.Preset != "claude_code" || preset.Append != "Additional instructions" {
+			t.Errorf("Expected the claude_code preset with the appended text, got %+v", preset)
 		}
 	})
 
@@ -1092,18 +1264,39 @@ func TestToTransportOptions_AppendSystemPrompt(t *testing.T) {
 		}
 	})
 
-	t.Run("AppendSystemPrompt ignored when SystemPrompt is preset", func(t *testing.T) {
-		preset := &SystemPromptPreset{Type: "preset", Preset: "claude_code"}
+	t.Run("AppendSystemPrompt appends to a preset", func(t *testing.T) {
 		opts := &Options{
-			SystemPrompt:       preset,
-			AppendSystemPrompt: "Should be ignored",
+			SystemPrompt:       &SystemPromptPreset{Type: "preset", Preset: "claude_code", Append: "Preset text"},
+			AppendSystemPrompt: "Additional instructions",
 		}
 
 		result := toTransportOptions(opts)
 
-		// Should return the preset unchanged
-		if result.SystemPrompt != preset {
-			t.Errorf("Expected SystemPrompt to be the preset, got '%v'", result.SystemPrompt)
+		preset, ok := result.SystemPrompt.(*transport.SystemPromptPreset)
+		if !ok {
+			t.Fatalf("Expected a preset, got %T", result.SystemPrompt)
+		}
+		if preset.Preset != "claude_code" || preset.Append != "Preset text\nAdditional instructions" {
+			t.Errorf("Expected the appended text after that of the preset, got %+v", preset)
+		}
+	})
+
+	t.Run("preset is passed to the transport", func(t *testing.T) {
+		opts := &Options{
+			SystemPrompt: &SystemPromptPreset{Type: "preset", Preset: "claude_code", Append: "Preset text"},
+		}
+
+		result := toTransportOptions(opts)
+
+		preset, ok := result.SystemPrompt.(*transport.SystemPromptPreset)
+		if !ok || preset.Append != "Preset text" {
+			t.Errorf("Expected the preset, got %#v", result.SystemPrompt)
+		}
+	})
+
+	t.Run("no prompt leaves SystemPrompt nil", func(t *testing.T) {
+		if result := toTransportOptions(&Options{}); result.SystemPrompt != nil {
+			t.Errorf("Expected nil SystemPrompt, got %v", result.SystemPrompt)
 		}
 	})
 
@@ -1121,6 +1314,36 @@ func TestToTransportOptions_AppendSystemPrompt(t *testing.T) {
 	})
 }
 
+func TestToTransportOptions_SystemPromptFragments(t *testing.T) {
+	// The prompt does not depend on the order the options are applied in
+	a := NewOptions(
+		WithSystemPrompt("Base prompt"),
+		WithSystemPromptFragment("persona", "Be concise.", 10),
+		WithSystemPromptFragment("guardrails", "Never delete files.", 0),
+		WithSystemPromptFragment("memory", "The user prefers Go.", 10),
+		WithAppendSystemPrompt("Additional instructions"),
+	)
+	b := NewOptions(
+		WithAppendSystemPrompt("Additional instructions"),
+		WithSystemPromptFragment("memory", "The user prefers Go.", 10),
+		WithSystemPromptFragment("guardrails", "Never delete files.", 0),
+		WithSystemPromptFragment("persona", "Be concise.", 10),
+		WithSystemPrompt("Base prompt"),
+	)
+	expected := "Base prompt\nAdditional instructions\nNever delete files.\n\nThe user prefers Go.\n\nBe concise."
+	for _, opts := range []*Options{a, b} {
+		if got := toTransportOptions(opts).SystemPrompt; got != expected {
+			t.Errorf("Expected SystemPrompt %q, got %q", expected, got)
+		}
+	}
+
+	// Without a system prompt, fragments are appended to the CLI default
+	preset, ok := toTransportOptions(NewOptions(WithSystemPromptFragment("persona", "Be concise.", 0))).SystemPrompt.(*transport.SystemPromptPreset)
+	if !ok || preset.Preset != "claude_code" || preset.Append != "Be concise." {
+		t.Errorf("Expected the fragment appended to the claude_code preset, got %+v", preset)
+	}
+}
+
 // Note: WithClient and QueryWithSession require actual CLI connection,
 // so we test their configuration behavior rather than full execution.
 
@@ -1137,3 +1360,315 @@ func TestWithClient_Signature(t *testing.T) {
 	// Verify the function signature is correct
 	var _ func(context.Context, func(*Client) error, ...Option) error = WithClient
 }
+
+func TestPartialTurn(t *testing.T) {
+	var partial partialTurn
+	partial.observe(&AssistantMessage{Model: "first"})
+	partial.observe(&ResultMessage{})
+	partial.observe(&AssistantMessage{Model: "second"})
+	partial.observe(&StreamEvent{})
+	partial.observe(&UserMessage{Content: "tool output"})
+
+	err := partial.streamError(map[string]any{"type": "error", "error": "boom", "exit_code": 2, "stderr": "panic"})
+	procErr, ok := AsProcessError(err)
+	if !ok {
+		t.Fatalf("Expected ProcessError, got %v", err)
+	}
+	if procErr.ExitCode != 2 || procErr.Stderr != "panic" {
+		t.Errorf("Unexpected ProcessError: %+v", procErr)
+	}
+	if len(procErr.PartialMessages) != 2 {
+		t.Fatalf("Expected the 2 messages of the unfinished turn, got %d", len(procErr.PartialMessages))
+	}
+	if m, _ := procErr.PartialMessages[0].(*AssistantMessage); m == nil || m.Model != "second" {
+		t.Errorf("Expected the unfinished turn's assistant message, got %+v", procErr.PartialMessages[0])
+	}
+
+	// Other stream errors are not process failures
+	if err := partial.streamError(map[string]any{"type": "error", "error": "read failed"}); IsProcessError(err) {
+		t.Errorf("Expected a plain SDK error, got %v", err)
+	}
+}
+
+func TestQuery_ProcessErrorSalvagesPartialMessages(t *testing.T) {
+	cli := writeStubCLI(t, `
+echo '{"type":"assistant","message":{"model":"m","content":[{"type":"text","text":"Half done"}]}}'
+echo 'fatal: out of memory' >&2
+exit 137
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	messages, errs := Query(ctx, "Do it", WithCLIPath(cli))
+	for range messages {
+	}
+
+	procErr, ok := AsProcessError(<-errs)
+	if !ok {
+		t.Fatal("Expected ProcessError")
+	}
+	if procErr.ExitCode != 137 || !strings.Contains(procErr.Stderr, "out of memory") {
+		t.Errorf("Unexpected ProcessError: %+v", procErr)
+	}
+	if len(procErr.PartialMessages) != 1 {
+		t.Fatalf("Expected 1 partial message, got %d", len(procErr.PartialMessages))
+	}
+}
+
+func TestClient_ProcessErrorSalvagesPartialMessages(t *testing.T) {
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+read line
+echo '{"type":"assistant","message":{"model":"m","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{}}]}}'
+echo '{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"ok"}]}}'
+exit 1
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.Query(ctx, "Do it"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	for range client.ReceiveResponse(ctx) {
+	}
+
+	procErr, ok := AsProcessError(<-client.Errors())
+	if !ok {
+		t.Fatal("Expected ProcessError")
+	}
+	if procErr.ExitCode != 1 || len(procErr.PartialMessages) != 2 {
+		t.Errorf("Expected exit code 1 and 2 partial messages, got %d and %d", procErr.ExitCode, len(procErr.PartialMessages))
+	}
+}
+
+func TestQuery_AppendSystemPrompt(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{
+			name: "default prompt",
+			opts: []Option{WithAppendSystemPrompt("Be brief.")},
+			want: []string{"--append-system-prompt", "Be brief."},
+		},
+		{
+			name: "preset",
+			opts: []Option{
+				WithSystemPromptPreset(&SystemPromptPreset{Type: "preset", Preset: "claude_code", Append: "Use Go."}),
+				WithAppendSystemPrompt("Be brief."),
+			},
+			want: []string{"--append-system-prompt", "Use Go.\nBe brief."},
+		},
+		{
+			name: "string prompt",
+			opts: []Option{WithSystemPrompt("You review code."), WithAppendSystemPrompt("Be brief.")},
+			want: []string{"--system-prompt", "You review code.\nBe brief."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cli := writeStubCLI(t, fmt.Sprintf(`
+for arg in "$@"; do echo "$arg" | tr '\n' '|' >> %[1]s/args; echo >> %[1]s/args; done
+echo '{"type":"result","subtype":"success","session_id":"sess-1","result":"done"}'
+`, dir))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			messages, errs := Query(ctx, "Hi", append([]Option{WithCLIPath(cli)}, tt.opts...)...)
+			for range messages {
+			}
+			if err := <-errs; err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+
+			data, _ := os.ReadFile(filepath.Join(dir, "args"))
+			var args []string
+			for _, arg := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+				args = append(args, strings.ReplaceAll(strings.TrimSuffix(arg, "|"), "|", "\n"))
+			}
+			found := false
+			for i := range len(args) - 1 {
+				if args[i] == tt.want[0] && args[i+1] == tt.want[1] {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("Expected %q in %q", tt.want, args)
+			}
+			if tt.want[0] == "--append-system-prompt" && slices.Contains(args, "--system-prompt") {
+				t.Errorf("Expected the default prompt to be kept, got %q", args)
+			}
+		})
+	}
+}
+
+func TestQuery_DockerRuntime(t *testing.T) {
+	dir := t.TempDir()
+	runtime := writeStubCLI(t, fmt.Sprintf(`
+echo "$*" > %[1]s/args
+echo "$ANTHROPIC_API_KEY" > %[1]s/key
+echo '{"type":"result","subtype":"success","session_id":"sess-1","result":"done"}'
+`, dir))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	messages, errs := Query(ctx, "Do it",
+		WithCwd(dir),
+		WithDockerRuntime("agent:latest", []string{"/data:/data:ro"}, map[string]string{"ANTHROPIC_API_KEY": "secret"}),
+		func(o *Options) { o.Container.Runtime = runtime },
+	)
+	var result *ResultMessage
+	for msg := range messages {
+		if r, ok := msg.(*ResultMessage); ok {
+			result = r
+		}
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if result == nil || result.Result != "done" {
+		t.Fatalf("Expected the result from the container, got %+v", result)
+	}
+
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	prefix := fmt.Sprintf("run --rm -i -v %[1]s:%[1]s -w %[1]s -v /data:/data:ro -e ANTHROPIC_API_KEY", dir)
+	if !strings.HasPrefix(string(args), prefix) || !strings.Contains(string(args), " agent:latest claude --output-format stream-json") {
+		t.Errorf("Unexpected runtime args %q", args)
+	}
+	if key, _ := os.ReadFile(filepath.Join(dir, "key")); strings.TrimSpace(string(key)) != "secret" {
+		t.Errorf("Expected the container env to reach the runtime, got %q", key)
+	}
+}
+
+func TestQuery_SSHRemote(t *testing.T) {
+	dir := t.TempDir()
+	cli := writeStubCLI(t, fmt.Sprintf(`
+pwd > %[1]s/pwd
+echo "$ANTHROPIC_API_KEY" > %[1]s/key
+while [ "$1" != "--" ]; do shift; done
+echo '{"type":"result","subtype":"success","session_id":"sess-1","result":"'"$2"'"}'
+`, dir))
+
+	// The stub client runs the remote command locally
+	ssh := filepath.Join(t.TempDir(), "ssh")
+	script := fmt.Sprintf(`#!/bin/sh
+echo "$*" > %s/args
+for arg; do last="$arg"; done
+exec sh -c "$last"
+`, dir)
+	if err := os.WriteFile(ssh, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	remoteDir := filepath.Join(dir, "remote dir")
+	if err := os.Mkdir(remoteDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	messages, errs := Query(ctx, "it's done",
+		WithCLIPath(cli),
+		WithCwd(remoteDir),
+		WithSSHRemote(&SSHRemote{Host: "devbox", User: "dev", Path: ssh, Env: map[string]string{"ANTHROPIC_API_KEY": "sk-1"}}),
+	)
+	var result *ResultMessage
+	for msg := range messages {
+		if r, ok := msg.(*ResultMessage); ok {
+			result = r
+		}
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if result == nil || result.Result != "it's done" {
+		t.Fatalf("Expected the prompt to reach the remote CLI, got %+v", result)
+	}
+
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	if !strings.HasPrefix(string(args), "-T -o BatchMode=yes -l dev -- devbox ") {
+		t.Errorf("Unexpected ssh args %q", args)
+	}
+	if strings.Contains(string(args), "sk-1") {
+		t.Errorf("Expected the remote env to stay off the command line, got %q", args)
+	}
+	if pwd, _ := os.ReadFile(filepath.Join(dir, "pwd")); strings.TrimSpace(string(pwd)) != remoteDir {
+		t.Errorf("Expected the remote CLI to run in the working directory, got %q", pwd)
+	}
+	if key, _ := os.ReadFile(filepath.Join(dir, "key")); strings.TrimSpace(string(key)) != "sk-1" {
+		t.Errorf("Expected the remote env to be set, got %q", key)
+	}
+}
+
+func TestQuery_ContainerAndSSHConflict(t *testing.T) {
+	_, errs := Query(context.Background(), "Hi",
+		WithDockerRuntime("agent", nil, nil),
+		WithSSHRemote(&SSHRemote{Host: "devbox"}))
+	if err := <-errs; err == nil || !strings.Contains(err.Error(), "cannot be combined") {
+		t.Errorf("Expected a conflict error, got %v", err)
+	}
+}
+
+func TestQuery_MessageLimit(t *testing.T) {
+	cli := writeStubCLI(t, `
+text=$(head -c 2000 /dev/zero | tr '\0' x)
+echo '{"type":"assistant","message":{"model":"m","content":[{"type":"text","text":"'$text'"}]}}'
+echo '{"type":"result","subtype":"success","session_id":"s"}'
+`)
+	messages, errs := Query(context.Background(), "Hi", WithCLIPath(cli), WithMaxBufferSize(1024))
+	for range messages {
+	}
+	limitErr, ok := AsBufferLimitError(<-errs)
+	if !ok {
+		t.Fatal("Expected a BufferLimitError")
+	}
+	if limitErr.Kind != BufferLimitMessage || limitErr.Limit != 1024 || limitErr.Size <= 2000 {
+		t.Errorf("Expected the message limit to trip, got %+v", limitErr)
+	}
+}
+
+func TestQuery_LowAllocParsing(t *testing.T) {
+	cli := writeStubCLI(t, `
+echo '{"type":"stream_event","uuid":"u1","session_id":"s","event":{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}}'
+echo '{"type":"stream_event","uuid":"u2","session_id":"s","event":{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"lo \u00e9"}}}'
+echo '{"type":"result","subtype":"success","session_id":"s","total_cost_usd":0.25}'
+`)
+	messages, errs := Query(context.Background(), "Hi", WithCLIPath(cli), WithIncludePartialMessages(true), WithLowAllocParsing())
+
+	var text string
+	var result *ResultMessage
+	for msg := range messages {
+		switch m := msg.(type) {
+		case *StreamEvent:
+			delta, _ := m.Event["delta"].(map[string]any)
+			chunk, _ := delta["text"].(string)
+			text += chunk
+		case *ResultMessage:
+			result = m
+		}
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if text != "Hello é" {
+		t.Errorf("Expected streamed text %q, got %q", "Hello é", text)
+	}
+	if result == nil || result.TotalCostUSD == nil || *result.TotalCostUSD != 0.25 {
+		t.Errorf("Expected the result with its cost, got %+v", result)
+	}
+}
@@ -0,0 +1,126 @@
+// Package claudetest helps test code written for the SDK without running
+// the CLI.
+//
+// CallTool calls a tool of an in-process MCP server as Claude would, so
+// tool handlers can be tested with table tests:
+//
+//	server := claude.CreateSDKMCPServer("calc", "1.0.0", []claude.MCPTool{addTool})
+//	result, err := claudetest.CallTool(server, "add", map[string]any{"a": 1, "b": 2})
+//	if err != nil {
+//	    t.Fatal(err)
+//	}
+//	if got := result.Content[0].Text; got != "3" {
+//	    t.Errorf("add returned %q", got)
+//	}
+package claudetest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	claude "github.com/afsharalex/claude-agent-sdk-go"
+)
+
+// CallTool calls the tool name of server with args, as the CLI does when
+// Claude uses it. The tool is looked up with tools/list, args are checked
+// against its input schema, and the call is made with tools/call, through
+// the same MCP message handling as in a session. Arguments go through
+// JSON on the way, so the handler sees them as it would from Claude:
+// numbers as float64, structs as maps.
+//
+// An error is returned if the tool is not listed, args do not match its
+// schema, or the handler fails. A result the handler marks as an error is
+// returned with IsError set and no error.
+func CallTool(server claude.MCPSDKServerConfig, name string, args map[string]any) (claude.MCPToolResult, error) {
+	if server.Server == nil {
+		return claude.MCPToolResult{}, fmt.Errorf("server %q has no in-process MCP server", server.Name)
+	}
+	c := &mcpClient{handler: claude.NewMCPHTTPHandler(server.Server)}
+
+	var list struct {
+		Tools []struct {
+			Name        string         `json:"name"`
+			InputSchema map[string]any `json:"inputSchema"`
+		} `json:"tools"`
+	}
+	if err := c.call("tools/list", map[string]any{}, &list); err != nil {
+		return claude.MCPToolResult{}, err
+	}
+	var schema map[string]any
+	found := false
+	for _, tool := range list.Tools {
+		if tool.Name == name {
+			schema, found = tool.InputSchema, true
+			break
+		}
+	}
+	if !found {
+		return claude.MCPToolResult{}, fmt.Errorf("tool %q is not listed by server %q", name, server.Name)
+	}
+
+	// Round trip args as they would come from Claude
+	if args == nil {
+		args = map[string]any{}
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		return claude.MCPToolResult{}, fmt.Errorf("cannot encode arguments: %w", err)
+	}
+	var arguments map[string]any
+	if err := json.Unmarshal(data, &arguments); err != nil {
+		return claude.MCPToolResult{}, fmt.Errorf("cannot encode arguments: %w", err)
+	}
+	if schema != nil {
+		if violations := claude.ValidateJSONSchema(schema, arguments); len(violations) > 0 {
+			return claude.MCPToolResult{}, fmt.Errorf("arguments of tool %q do not match its input schema: %s",
+				name, strings.Join(violations, "; "))
+		}
+	}
+
+	var result claude.MCPToolResult
+	if err := c.call("tools/call", map[string]any{"name": name, "arguments": arguments}, &result); err != nil {
+		return claude.MCPToolResult{}, err
+	}
+	return result, nil
+}
+
+// mcpClient sends JSON-RPC requests to an MCP server handler in process.
+type mcpClient struct {
+	handler http.Handler
+	id      int
+}
+
+// call sends a request for method and decodes its result into result.
+func (c *mcpClient) call(method string, params any, result any) error {
+	c.id++
+	body, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": c.id, "method": method, "params": params})
+	if err != nil {
+		return err
+	}
+	req := httptest.NewRequestWithContext(context.Background(), http.MethodPost, "/", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	c.handler.ServeHTTP(rec, req)
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		return fmt.Errorf("invalid response to %s: %w", method, err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("%s failed: %s (code %d)", method, response.Error.Message, response.Error.Code)
+	}
+	if err := json.Unmarshal(response.Result, result); err != nil {
+		return fmt.Errorf("invalid result of %s: %w", method, err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package claudetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	claude "github.com/afsharalex/claude-agent-sdk-go"
+)
+
+func newCalcServer() claude.MCPSDKServerConfig {
+	schema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"a": map[string]any{"type": "number"},
+			"b": map[string]any{"type": "number"},
+		},
+		"required": []string{"a", "b"},
+	}
+	add := claude.Tool("add", "Add two numbers", schema, func(ctx context.Context, args map[string]any) (claude.MCPToolResult, error) {
+		return claude.TextResult(fmt.Sprintf("%g", args["a"].(float64)+args["b"].(float64))), nil
+	})
+	divide := claude.Tool("divide", "Divide two numbers", schema, func(ctx context.Context, args map[string]any) (claude.MCPToolResult, error) {
+		if args["b"].(float64) == 0 {
+			return claude.ErrorResult("division by zero"), nil
+		}
+		return claude.JSONResult(map[string]any{"quotient": args["a"].(float64) / args["b"].(float64)})
+	})
+	fail := claude.Tool("fail", "Always fails", nil, func(ctx context.Context, args map[string]any) (claude.MCPToolResult, error) {
+		return claude.MCPToolResult{}, errors.New("backend unavailable")
+	})
+	return claude.CreateSDKMCPServer("calc", "1.0.0", []claude.MCPTool{add, divide, fail})
+}
+
+func TestCallTool(t *testing.T) {
+	server := newCalcServer()
+
+	// Integer arguments reach the handler as float64, as from Claude
+	result, err := CallTool(server, "add", map[string]any{"a": 1, "b": 2})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if len(result.Content) != 1 || result.Content[0].Type != "text" || result.Content[0].Text != "3" {
+		t.Errorf("Expected text 3, got %+v", result)
+	}
+
+	result, err = CallTool(server, "divide", map[string]any{"a": 1, "b": 4})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if structured, ok := result.Structured.(map[string]any); !ok || structured["quotient"] != 0.25 {
+		t.Errorf("Expected structured quotient 0.25, got %+v", result.Structured)
+	}
+
+	result, err = CallTool(server, "divide", map[string]any{"a": 1, "b": 0})
+	if err != nil {
+		t.Fatalf("Expected an error result rather than an error, got %v", err)
+	}
+	if !result.IsError || result.Content[0].Text != "division by zero" {
+		t.Errorf("Expected an error result, got %+v", result)
+	}
+}
+
+func TestCallTool_Errors(t *testing.T) {
+	server := newCalcServer()
+
+	tests := []struct {
+		name   string
+		server claude.MCPSDKServerConfig
+		tool   string
+		args   map[string]any
+		want   string
+	}{
+		{"unknown tool", server, "multiply", nil, `tool "multiply" is not listed`},
+		{"missing argument", server, "add", map[string]any{"a": 1}, "do not match its input schema"},
+		{"wrong type", server, "add", map[string]any{"a": 1, "b": "two"}, "do not match its input schema"},
+		{"handler error", server, "fail", nil, "backend unavailable"},
+		{"no server", claude.MCPSDKServerConfig{Name: "empty"}, "add", nil, "no in-process MCP server"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CallTool(tt.server, tt.tool, tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -3,7 +3,9 @@ package claude
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/afsharalex/claude-agent-sdk-go/internal/protocol"
 	"github.com/afsharalex/claude-agent-sdk-go/internal/transport"
@@ -47,16 +49,143 @@ type Client struct {
 
 	mu        sync.Mutex
 	connected bool
+	started   bool
 	sessionID string
+
+	// turns runs the turns of the connection one at a time.
+	turns *turnQueue
+
+	// transcript writes the session's transcript; nil unless
+	// WithTranscriptDir is set.
+	transcript *transcriptWriter
+
+	// contextFiles are the files of WithContextFiles, loaded on the first
+	// connection; nil when there are none.
+	contextFiles *contextFiles
+
+	// turnMu guards per-turn state read by callbacks running on
+	// protocol goroutines, so they never contend with mu.
+	turnMu       sync.Mutex
+	turnMetadata map[string]string
+
+	// pendingInterrupt is the reason of an interrupt sent during the
+	// current turn, annotated onto the turn's ResultMessage.
+	pendingInterrupt *string
+
+	// turn numbers turns; turnActive reports whether turn is still in
+	// progress, and stopCancelWatch stops watching its context.
+	turn            uint64
+	turnActive      bool
+	stopCancelWatch func() bool
+
+	// Conversation position, recorded by Mark for Rollback.
+	lastSessionID   string
+	lastMessageUUID string
+	marks           map[string]conversationMark
+
+	// pendingMetadata holds session metadata updates made before the
+	// session ID was known.
+	pendingMetadata []func(*SessionMetadata)
+
+	// watchdog detects stalled turns; nil when stall detection is disabled.
+	watchdog *stallWatchdog
+
+	// toolStats records tool usage across reconnects.
+	toolStats *protocol.ToolStats
+
+	// changes records the files Claude modified in the current turn.
+	changes *FileChangeTracker
+
+	// structured validates structured output; nil when validation is
+	// disabled.
+	structured *structuredOutputValidator
+
+	// watcher reports changed files to Claude; nil when no paths are
+	// watched. Created on the first connection.
+	watcher *fileWatcher
+
+	// dryRun simulates mutating tools and records the plan of the turn;
+	// nil unless WithDryRun is set.
+	dryRun *dryRun
+
+	// workspaceLock is held on the working directory while connected;
+	// nil unless WithWorkspaceLock is set.
+	workspaceLock *workspaceLock
+
+	// failover moves through the model fallback chain, and turnMessage is
+	// the user message of the current turn, sent again on failover.
+	failover    *modelFailover
+	turnMessage map[string]any
+
+	// rateLimits detects turns that end on a rate limit.
+	rateLimits *rateLimitTracker
+
+	// autoContinue follows up on turns; nil unless WithAutoContinue is set.
+	autoContinue *autoContinue
+
+	// costs calls cost alerts; nil unless WithCostAlert is set.
+	costs *costTracker
+
+	// contextUsage estimates the context filled and warns past the
+	// thresholds of WithContextWarnings.
+	contextUsage *contextTracker
+
+	// timer measures the timing of turns.
+	timer *turnTimer
+
+	// session tracks the session details passed to callbacks.
+	session *sessionInfo
+
+	// idle sends keepalives and detects idle connections; nil when both
+	// are disabled. idleResume is the session to resume after the
+	// connection was closed for being idle.
+	idle       *idleMonitor
+	idleResume string
+
+	// newSessionID is the ID a session started by NewSession is created
+	// with, and sessions are those sessions, closed along with the client.
+	newSessionID string
+	sessionsMu   sync.Mutex
+	sessions     []*Client
+
+	// events receives the lifecycle events, and exited is closed once
+	// ProcessExited was sent for the process of the connection.
+	events chan LifecycleEvent
+	exited <-chan struct{}
+
+	// notes are the notes inserted with InjectSystemNote.
+	notes *noteQueue
 }
 
 // NewClient creates a new Claude SDK client.
 func NewClient(opts ...Option) *Client {
+	return newClient(NewOptions(opts...))
+}
+
+// newClient creates a client with options.
+func newClient(options *Options) *Client {
 	return &Client{
-		options:   NewOptions(opts...),
+		options:   options,
 		messageCh: make(chan Message, 100),
 		errorCh:   make(chan error, 1),
 		sessionID: "default",
+		watchdog:  newStallWatchdog(options),
+		toolStats: protocol.NewToolStats(),
+		changes:   NewFileChangeTracker(),
+
+		structured:   newStructuredOutputValidator(options),
+		failover:     newModelFailover(options),
+		rateLimits:   newRateLimitTracker(),
+		autoContinue: newAutoContinue(options),
+		dryRun:       newDryRun(options),
+		costs:        newCostTracker(options),
+		contextUsage: newContextTracker(options),
+		timer:        newTurnTimer(),
+		session:      newSessionInfo(options),
+		idle:         newIdleMonitor(options),
+		transcript:   newTranscriptWriter(options),
+		events:       make(chan LifecycleEvent, lifecycleBuffer),
+		notes:        newNoteQueue(),
 	}
 }
 
@@ -65,6 +194,12 @@ func NewClient(opts ...Option) *Client {
 // If prompt is provided, it will be used as the initial message or stream.
 // If prompt is empty, the connection is established without sending an initial message.
 func (c *Client) Connect(ctx context.Context) error {
+	return c.connect(ctx, nil)
+}
+
+// connect connects to Claude Code. If resume is non-nil, the session is
+// forked from that mark for this connection only.
+func (c *Client) connect(ctx context.Context, resume *conversationMark) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -73,17 +208,43 @@ func (c *Client) Connect(ctx context.Context) error {
 	}
 
 	// Validate canUseTool settings
-	if c.options.CanUseTool != nil && c.options.PermissionPromptToolName != "" {
+	if permissionCallback(c.options, nil) != nil && c.options.PermissionPromptToolName != "" {
 		return NewClaudeSDKError("can_use_tool callback cannot be used with permission_prompt_tool_name")
 	}
+	if err := validateOptions(c.options); err != nil {
+		return err
+	}
+	if err := preflightMCP(ctx, c.options); err != nil {
+		return err
+	}
+	if !c.started {
+		files, err := loadContextFiles(c.options)
+		if err != nil {
+			return err
+		}
+		c.contextFiles = files
+	}
 
-	// Auto-set permission_prompt_tool_name if canUseTool is provided
-	if c.options.CanUseTool != nil {
-		c.options.PermissionPromptToolName = "stdio"
+	lock, err := acquireWorkspaceLock(ctx, c.options)
+	if err != nil {
+		return err
 	}
+	defer func() {
+		if !c.connected {
+			lock.release()
+		}
+	}()
 
-	// Convert options to transport options
-	transportOpts := toTransportOptions(c.options)
+	mcpServers, err := c.options.SharedMCPServers.share(ctx, c.options.MCPServers, c.options.Cwd, c.options.Env)
+	if err != nil {
+		return err
+	}
+
+	transportOpts := c.transportOptions(resume)
+	transportOpts.MCPServers = toTransportMCPServers(mcpServers)
+	if resume == nil && c.idleResume != "" {
+		c.emit(Reconnecting{Reason: "idle", SessionID: c.idleResume})
+	}
 
 	// Create transport - streaming mode for Client
 	t, err := transport.NewSubprocessTransport("", true, transportOpts)
@@ -93,64 +254,284 @@ func (c *Client) Connect(ctx context.Context) error {
 	c.transport = t
 
 	// Connect transport
+	started := time.Now()
 	if err := c.transport.Connect(ctx); err != nil {
 		return err
 	}
+	c.emit(ProcessStarted{PID: t.PID()})
+	exited := c.watchExit(t)
 
 	// Extract SDK MCP servers (convert to internal types)
 	var sdkMCPServers map[string]*types.MCPServer
-	if servers, ok := c.options.MCPServers.(map[string]MCPServerConfig); ok {
+	if servers, ok := mcpServers.(map[string]MCPServerConfig); ok {
 		sdkMCPServers = toInternalMCPServers(servers)
 	}
 
+	if !c.started {
+		c.watcher = newFileWatcher(c.options.WatchPaths, c.options.Cwd)
+	}
+
+	// Channels are closed when a connection ends, so reconnecting
+	// (e.g. on Rollback) needs fresh ones.
+	messageCh, errorCh := c.messageCh, c.errorCh
+	if c.started {
+		messageCh = make(chan Message, 100)
+		errorCh = make(chan error, 1)
+	}
+	errs := newErrorSink(errorCh)
+
 	// Create query handler
 	c.query = protocol.NewQuery(protocol.QueryConfig{
 		Transport:       c.transport,
 		IsStreamingMode: true,
-		CanUseTool:      toInternalCanUseTool(c.options.CanUseTool),
-		Hooks:           toInternalHooks(c.options.Hooks),
+		CanUseTool:      toInternalCanUseTool(permissionCallback(c.options, c.approvedPlan), c.options.Locale),
+		Hooks:           toInternalHooks(c.dryRun.withHook(c.watcher.withHook(allHooks(c.options)))),
 		SDKMCPServers:   sdkMCPServers,
+		HandlerContext:  c.handlerContext,
+		ToolStats:       c.toolStats,
+		OnError: func(err error) {
+			if err := publicError(err); IsBufferLimitError(err) {
+				errs.add(err)
+				return
+			}
+			errs.add(WrapClaudeSDKError("callback failed", err))
+		},
 	})
 
 	// Start reading messages
 	c.query.Start(ctx)
 
-	// Initialize
-	if _, err := c.query.Initialize(ctx); err != nil {
+	// Initialize, unless reattaching to a CLI that was initialized
+	if t.Reattached() {
+		c.query.Restore(t.SavedInit())
+	} else if _, err := c.query.Initialize(ctx); err != nil {
+		_ = c.query.Close()
+		return publicError(err)
+	} else if err := t.SaveInit(c.query.InitResult()); err != nil {
+		_ = c.query.Close()
+		return err
+	}
+	if err := checkBetas(c.options.Betas, c.query.InitResult()); err != nil {
 		_ = c.query.Close()
 		return err
 	}
 
 	c.connected = true
+	c.exited = exited
+	c.workspaceLock = lock
+	c.emit(Initialized{InitResult: c.query.InitResult(), StartupTime: time.Since(started)})
+	c.idleResume = ""
+	c.costs.newProcess()
+	c.idle.touch()
+
+	c.messageCh, c.errorCh = messageCh, errorCh
+	c.started = true
+	c.turns = newTurnQueue()
 
 	// Start message processing in background
-	go c.processMessages()
+	go c.processMessages(c.query, c.transport, c.turns, newForkRecorder(c.options.ConversationTree, transportOpts), messageCh, errs)
 
 	return nil
 }
 
-// processMessages reads from the query and sends parsed messages to the channel.
-func (c *Client) processMessages() {
-	defer close(c.messageCh)
-	defer close(c.errorCh)
+// transportOptions converts the client options to transport options for
+// a connection, optionally forking the session from a mark.
+func (c *Client) transportOptions(resume *conversationMark) *transport.Options {
+	transportOpts := toTransportOptions(c.contextFiles.systemOptions(c.options))
 
-	for data := range c.query.ReceiveMessages() {
-		if data["type"] == "end" {
-			return
-		}
-		if data["type"] == "error" {
-			errMsg, _ := data["error"].(string)
-			c.errorCh <- NewClaudeSDKError(errMsg)
-			return
+	// Auto-set permission_prompt_tool_name if canUseTool is provided
+	if permissionCallback(c.options, nil) != nil {
+		transportOpts.PermissionPromptToolName = "stdio"
+	}
+
+	if resume != nil {
+		transportOpts.ContinueConversation = false
+		transportOpts.Resume = resume.sessionID
+		transportOpts.ResumeSessionAt = resume.messageUUID
+		transportOpts.ForkSession = true
+	} else if c.idleResume != "" {
+		// Pick up where the connection closed for being idle left off
+		transportOpts.ContinueConversation = false
+		transportOpts.Resume = c.idleResume
+	} else if c.newSessionID != "" && !c.started {
+		transportOpts.SessionID = c.newSessionID
+	}
+	transportOpts.PipeDir = c.options.PipeDir
+	return transportOpts
+}
+
+// processMessages reads from the query and sends parsed messages to the channel.
+//
+// Errors are delivered through errs, which is closed before messageCh so
+// that every error of the connection is readable once Messages() closes.
+func (c *Client) processMessages(query *protocol.Query, t transport.Transport, turns *turnQueue, forks *forkRecorder, messageCh chan<- Message, errs *errorSink) {
+	defer close(messageCh)
+	defer errs.close()
+	defer turns.close()
+	defer func() { errs.add(c.transcript.close()) }()
+	deliver := func(msg Message) {
+		if msg = c.options.intercept(msg); msg != nil {
+			messageCh <- msg
 		}
+	}
 
-		msg, err := ParseMessage(data)
-		if err != nil {
-			c.errorCh <- err
-			continue
+	messages := query.ReceiveMessages()
+	tick, stop := c.watchdog.ticker()
+	defer stop()
+	idleTick, stopIdle := c.idle.ticker()
+	defer stopIdle()
+	toolInputs := newToolInputAccumulator()
+	var partial partialTurn
+	toolsChecked := false
+
+	for {
+		select {
+		case data, ok := <-messages:
+			if !ok {
+				return
+			}
+			c.watchdog.touch()
+			c.idle.touch()
+
+			if data["type"] == "end" {
+				return
+			}
+			if data["type"] == "error" {
+				errs.add(partial.streamError(data))
+				return
+			}
+			c.transcript.observe(data)
+			errs.add(c.transcript.takeErr())
+
+			msg, err := ParseMessage(data)
+			if err != nil {
+				errs.add(err)
+				continue
+			}
+			partial.observe(msg)
+
+			c.trackPosition(msg)
+			forks.observe(msg)
+			c.failover.observe(msg)
+			c.rateLimits.observe(msg)
+			c.timer.observe(msg)
+			c.session.observe(msg)
+			errs.add(c.flushSessionMetadata())
+			c.watcher.observe(msg, c.options.Cwd)
+			c.changes.Observe(msg)
+			contextEvent := c.contextUsage.observe(msg)
+			if system, ok := msg.(*SystemMessage); ok && system.Subtype == "init" && !toolsChecked {
+				toolsChecked = true
+				errs.add(checkTools(c.options, system))
+			}
+
+			var schemaErr error
+			ended := false
+			if result, ok := msg.(*ResultMessage); ok {
+				c.watchdog.end()
+				c.annotateInterrupt(result)
+				c.costs.observe(result)
+
+				// Retry the turn with the next model
+				if model, event, ok := c.failover.next(result); ok && c.retryTurn(query, t, model) == nil {
+					deliver(event)
+					c.watchdog.begin()
+					continue
+				}
+				c.failover.annotate(result)
+				abortErr := c.failover.takeAborted()
+				rateErr := c.rateLimits.result()
+				c.options.RateLimitPacer.observe(rateErr)
+				if rateErr != nil {
+					errs.add(rateErr)
+				}
+
+				if !result.IsInterrupted() && abortErr == nil {
+					var repair string
+					repair, schemaErr = c.structured.check(result)
+					// Withhold the result while Claude repairs it
+					if repair != "" && c.writePrompt(context.Background(), t, repair) == nil {
+						c.watchdog.begin()
+						continue
+					}
+				}
+
+				// Follow up on the turn in place of delivering its result
+				if schemaErr == nil && rateErr == nil && abortErr == nil {
+					prompt, event := c.autoContinue.next(result)
+					if event != nil {
+						deliver(event)
+					}
+					if prompt != "" && c.followUp(t, prompt) == nil {
+						c.watchdog.begin()
+						continue
+					}
+				}
+				c.timer.finish(result)
+				c.endTurn()
+				ended = true
+				if abortErr != nil {
+					schemaErr = abortErr
+				}
+			}
+
+			// Notes injected before msg arrived come first
+			for _, note := range c.notes.take() {
+				deliver(note)
+			}
+			deliver(msg)
+			if contextEvent != nil {
+				deliver(contextEvent)
+			}
+			// The next turn starts once this one's result is delivered
+			if ended {
+				turns.release()
+			}
+			if event, ok := msg.(*StreamEvent); ok {
+				if use := toolInputs.observe(event); use != nil {
+					deliver(use)
+				}
+			}
+			for _, change := range c.session.takeModeChanges() {
+				deliver(change)
+			}
+			errs.add(schemaErr)
+
+		case <-c.session.notify:
+			for _, change := range c.session.takeModeChanges() {
+				deliver(change)
+			}
+
+		case <-c.notes.notify:
+			for _, note := range c.notes.take() {
+				deliver(note)
+			}
+
+		case now := <-tick:
+			event := c.watchdog.check(now)
+			if event == nil {
+				continue
+			}
+			deliver(event)
+			if event.Action == StallActionClose {
+				errs.add(NewStallError(event))
+			}
+			c.handleStall(event.Action)
+
+		case now := <-idleTick:
+			c.checkIdle(now, errs)
 		}
+	}
+}
 
-		c.messageCh <- msg
+// handleStall takes the configured action for a stalled turn. Actions run
+// in the background so message processing is never blocked on them.
+func (c *Client) handleStall(action StallAction) {
+	switch action {
+	case StallActionInterrupt:
+		go func() { _ = c.InterruptWithReason(context.Background(), "stall timeout") }()
+	case StallActionClose:
+		go func() { _ = c.Close() }()
 	}
 }
 
@@ -158,15 +539,65 @@ func (c *Client) processMessages() {
 //
 // The prompt can be a simple string message. For more complex messages,
 // use QueryMessage.
+//
+// Query is safe to call from multiple goroutines. Turns run one at a
+// time: a call made while a turn is in progress waits until that turn's
+// ResultMessage has been delivered on Messages, and waiting calls are sent
+// in the order they were made, so each turn's messages arrive after the
+// previous turn's result. Cancelling ctx while waiting returns its error
+// without sending the prompt. With WithBusyBehavior(BusyBehaviorReject),
+// a BusyError is returned instead of waiting.
+//
+// Cancelling ctx before the turn ends interrupts the turn, unless
+// configured otherwise with WithCancelBehavior.
 func (c *Client) Query(ctx context.Context, prompt string) error {
+	turns, err := c.acquireTurn(ctx)
+	if err != nil {
+		return err
+	}
+
+	message := c.promptMessage(prompt)
+	c.contextFiles.message(message)
+	c.startTurn(ctx)
+	c.setTurnMessage(message)
+
+	return c.sendTurn(ctx, turns, message)
+}
+
+// acquireTurn waits for the turns queued before it, as configured with
+// WithBusyBehavior, then for the rate limit pacer.
+func (c *Client) acquireTurn(ctx context.Context) (*turnQueue, error) {
 	c.mu.Lock()
 	if !c.connected {
 		c.mu.Unlock()
-		return NewCLIConnectionError("Not connected. Call Connect() first.")
+		return nil, NewCLIConnectionError("Not connected. Call Connect() first.")
 	}
+	turns := c.turns
 	c.mu.Unlock()
 
-	message := map[string]any{
+	if err := turns.acquire(ctx, c.options.BusyBehavior != BusyBehaviorReject); err != nil {
+		return nil, err
+	}
+	if err := c.options.RateLimitPacer.Wait(ctx); err != nil {
+		turns.release()
+		return nil, err
+	}
+	return turns, nil
+}
+
+// sendTurn sends the message starting a turn, letting the next turn start
+// if it cannot be sent.
+func (c *Client) sendTurn(ctx context.Context, turns *turnQueue, message map[string]any) error {
+	if err := c.writeMessage(ctx, c.transport, message); err != nil {
+		turns.release()
+		return err
+	}
+	return nil
+}
+
+// promptMessage returns the user message for a prompt.
+func (c *Client) promptMessage(prompt string) map[string]any {
+	return map[string]any{
 		"type": "user",
 		"message": map[string]any{
 			"role":    "user",
@@ -175,44 +606,189 @@ func (c *Client) Query(ctx context.Context, prompt string) error {
 		"parent_tool_use_id": nil,
 		"session_id":         c.sessionID,
 	}
+}
+
+// writePrompt sends a user prompt over t.
+func (c *Client) writePrompt(ctx context.Context, t transport.Transport, prompt string) error {
+	return c.writeMessage(ctx, t, c.promptMessage(prompt))
+}
+
+// followUp sends prompt over t as the next message of the current turn,
+// which a failover then retries.
+func (c *Client) followUp(t transport.Transport, prompt string) error {
+	message := c.promptMessage(prompt)
+	c.setTurnMessage(message)
+	return c.writeMessage(context.Background(), t, message)
+}
 
+// writeMessage sends a message over t.
+func (c *Client) writeMessage(ctx context.Context, t transport.Transport, message map[string]any) error {
 	data, err := json.Marshal(message)
 	if err != nil {
 		return err
 	}
 
-	return c.transport.Write(ctx, string(data)+"\n")
+	if err := t.Write(ctx, string(data)+"\n"); err != nil {
+		return err
+	}
+	c.transcript.prompt(message)
+	return nil
+}
+
+// setTurnMessage records the user message of the current turn.
+func (c *Client) setTurnMessage(message map[string]any) {
+	c.turnMu.Lock()
+	c.turnMessage = message
+	c.turnMu.Unlock()
 }
 
-// QueryMessage sends a structured message to Claude.
+// retryTurn switches to model and sends the current turn's message again
+// over t.
+func (c *Client) retryTurn(query *protocol.Query, t transport.Transport, model string) error {
+	c.turnMu.Lock()
+	message := c.turnMessage
+	c.turnMu.Unlock()
+
+	if message == nil {
+		return NewClaudeSDKError("no turn to retry")
+	}
+	if err := query.SetModel(context.Background(), model); err != nil {
+		return err
+	}
+	return c.writeMessage(context.Background(), t, message)
+}
+
+// QueryMessage sends a structured message to Claude. Like Query, it
+// waits for the turns queued before it.
 func (c *Client) QueryMessage(ctx context.Context, message map[string]any) error {
-	c.mu.Lock()
-	if !c.connected {
-		c.mu.Unlock()
-		return NewCLIConnectionError("Not connected. Call Connect() first.")
+	turns, err := c.acquireTurn(ctx)
+	if err != nil {
+		return err
 	}
-	c.mu.Unlock()
+
+	c.startTurn(ctx)
 
 	// Ensure session_id is set
 	if _, ok := message["session_id"]; !ok {
 		message["session_id"] = c.sessionID
 	}
+	c.contextFiles.message(message)
+	c.setTurnMessage(message)
 
-	data, err := json.Marshal(message)
-	if err != nil {
-		return err
+	return c.sendTurn(ctx, turns, message)
+}
+
+// startTurn resets per-turn state for the turn being started and records
+// its request metadata.
+func (c *Client) startTurn(ctx context.Context) {
+	c.turnMu.Lock()
+	c.turnMetadata = RequestMetadataFromContext(ctx)
+	c.pendingInterrupt = nil
+	c.turn++
+	c.turnActive = true
+	if c.stopCancelWatch != nil {
+		c.stopCancelWatch()
+	}
+	turn := c.turn
+	stopCancel := context.AfterFunc(ctx, func() {
+		c.cancelTurn(turn, context.Cause(ctx))
+	})
+	c.stopCancelWatch = stopCancel
+	if stopDeadline := c.watchDeadline(ctx, turn); stopDeadline != nil {
+		c.stopCancelWatch = func() bool {
+			stopDeadline()
+			return stopCancel()
+		}
 	}
+	c.turnMu.Unlock()
 
-	return c.transport.Write(ctx, string(data)+"\n")
+	c.changes.Reset()
+	c.structured.reset()
+	c.autoContinue.reset()
+	c.dryRun.reset()
+	c.costs.reset()
+	c.watchdog.begin()
+	c.timer.begin()
+
+	// Return to the primary model if the previous turn fell back
+	if primary, restore := c.failover.reset(); restore && c.query != nil {
+		_ = c.query.SetModel(ctx, primary)
+	}
+}
+
+// endTurn records that the turn in progress has ended.
+func (c *Client) endTurn() {
+	c.turnMu.Lock()
+	defer c.turnMu.Unlock()
+	c.turnActive = false
+	if c.stopCancelWatch != nil {
+		c.stopCancelWatch()
+		c.stopCancelWatch = nil
+	}
+}
+
+// currentTurn returns the number of the latest turn.
+func (c *Client) currentTurn() uint64 {
+	c.turnMu.Lock()
+	defer c.turnMu.Unlock()
+	return c.turn
+}
+
+// cancelTurn takes the configured CancelBehavior if turn is still in
+// progress. Like stall actions, it runs in the background.
+func (c *Client) cancelTurn(turn uint64, cause error) {
+	c.turnMu.Lock()
+	active := c.turnActive && c.turn == turn
+	c.turnMu.Unlock()
+	if !active {
+		return
+	}
+
+	switch c.options.CancelBehavior {
+	case CancelBehaviorNone:
+	case CancelBehaviorClose:
+		go func() { _ = c.Close() }()
+	default:
+		go func() { _ = c.InterruptWithReason(context.Background(), cause.Error()) }()
+	}
+}
+
+// handlerContext decorates callback contexts with the session details and
+// the metadata of the turn in progress, on top of any metadata carried by
+// the Connect context.
+func (c *Client) handlerContext(ctx context.Context) context.Context {
+	c.turnMu.Lock()
+	md := c.turnMetadata
+	c.turnMu.Unlock()
+
+	ctx = c.session.context(ctx)
+	if len(md) == 0 {
+		return ctx
+	}
+	return WithRequestMetadata(ctx, md)
 }
 
 // Messages returns a channel for receiving messages from Claude.
 func (c *Client) Messages() <-chan Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.messageCh
 }
 
 // Errors returns a channel for receiving errors.
+//
+// No error is dropped. Errors raised while an earlier one is still unread
+// are delivered together as a single errors.Join error; use errors.As or
+// the Is* helpers to inspect them. Errors include failures of the CLI
+// process, unparseable messages, hook and permission callback failures,
+// structured output validation and stall timeouts.
+//
+// The channel is closed when the connection ends, before Messages() is
+// closed: once the message channel is closed, every error of the
+// connection can be read without blocking.
 func (c *Client) Errors() <-chan error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.errorCh
 }
 
@@ -220,8 +796,12 @@ func (c *Client) Errors() <-chan error {
 //
 // This is a convenience method for single-response workflows. It returns
 // a channel that yields all messages and closes after a ResultMessage.
+// If ctx is cancelled first, the channel closes and the turn is handled
+// as configured with WithCancelBehavior.
 func (c *Client) ReceiveResponse(ctx context.Context) <-chan Message {
 	ch := make(chan Message, 100)
+	messages := c.Messages()
+	turn := c.currentTurn()
 
 	go func() {
 		defer close(ch)
@@ -229,8 +809,9 @@ func (c *Client) ReceiveResponse(ctx context.Context) <-chan Message {
 		for {
 			select {
 			case <-ctx.Done():
+				c.cancelTurn(turn, context.Cause(ctx))
 				return
-			case msg, ok := <-c.messageCh:
+			case msg, ok := <-messages:
 				if !ok {
 					return
 				}
@@ -249,6 +830,15 @@ func (c *Client) ReceiveResponse(ctx context.Context) <-chan Message {
 
 // Interrupt sends an interrupt signal to Claude.
 func (c *Client) Interrupt(ctx context.Context) error {
+	return c.InterruptWithReason(ctx, "")
+}
+
+// InterruptWithReason sends an interrupt signal to Claude with a reason.
+//
+// The ResultMessage that ends the interrupted turn reports IsInterrupted()
+// and carries the reason in InterruptReason, so callers can distinguish
+// user aborts from errors.
+func (c *Client) InterruptWithReason(ctx context.Context, reason string) error {
 	c.mu.Lock()
 	if !c.connected {
 		c.mu.Unlock()
@@ -256,7 +846,36 @@ func (c *Client) Interrupt(ctx context.Context) error {
 	}
 	c.mu.Unlock()
 
-	return c.query.Interrupt(ctx)
+	// Record the interrupt before sending it, since the CLI may end the
+	// turn before acknowledging the request.
+	c.turnMu.Lock()
+	c.pendingInterrupt = &reason
+	c.turnMu.Unlock()
+
+	if err := c.query.InterruptWithReason(ctx, reason); err != nil {
+		c.turnMu.Lock()
+		if c.pendingInterrupt == &reason {
+			c.pendingInterrupt = nil
+		}
+		c.turnMu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// annotateInterrupt marks result as interrupted if an interrupt was sent
+// during its turn.
+func (c *Client) annotateInterrupt(result *ResultMessage) {
+	c.turnMu.Lock()
+	reason := c.pendingInterrupt
+	c.pendingInterrupt = nil
+	c.turnMu.Unlock()
+
+	if reason == nil {
+		return
+	}
+	result.Interrupted = true
+	result.InterruptReason = *reason
 }
 
 // SetPermissionMode changes the permission mode during conversation.
@@ -273,7 +892,19 @@ func (c *Client) SetPermissionMode(ctx context.Context, mode PermissionMode) err
 	}
 	c.mu.Unlock()
 
-	return c.query.SetPermissionMode(ctx, types.PermissionMode(mode))
+	if err := c.query.SetPermissionMode(ctx, types.PermissionMode(mode)); err != nil {
+		return err
+	}
+	c.session.setPermissionMode(mode, PermissionModeSourceClient)
+	return nil
+}
+
+// PermissionMode returns the permission mode in effect: the one the
+// session started with, as reported by the CLI, or the latest change made
+// with SetPermissionMode or reported by the CLI. Changes are also emitted
+// on the message stream as PermissionModeChanged messages.
+func (c *Client) PermissionMode() PermissionMode {
+	return c.session.permissionMode()
 }
 
 // SetModel changes the AI model during conversation.
@@ -287,7 +918,11 @@ func (c *Client) SetModel(ctx context.Context, model string) error {
 	}
 	c.mu.Unlock()
 
-	return c.query.SetModel(ctx, model)
+	if err := c.query.SetModel(ctx, model); err != nil {
+		return err
+	}
+	c.failover.setPrimary(model)
+	return nil
 }
 
 // RewindFiles rewinds tracked files to their state at a specific user message.
@@ -306,6 +941,94 @@ func (c *Client) RewindFiles(ctx context.Context, userMessageID string) error {
 	return c.query.RewindFiles(ctx, userMessageID)
 }
 
+// conversationMark is a point in the conversation recorded by Mark.
+type conversationMark struct {
+	sessionID   string
+	messageUUID string
+}
+
+// trackPosition records the session and message msg belongs to.
+func (c *Client) trackPosition(msg Message) {
+	c.turnMu.Lock()
+	defer c.turnMu.Unlock()
+
+	switch m := msg.(type) {
+	case *SystemMessage:
+		if id, ok := m.Data["session_id"].(string); ok && id != "" {
+			c.lastSessionID = id
+		}
+	case *ResultMessage:
+		if m.SessionID != "" {
+			c.lastSessionID = m.SessionID
+		}
+	case *AssistantMessage:
+		if m.UUID != "" {
+			c.lastMessageUUID = m.UUID
+		}
+	case *UserMessage:
+		if m.UUID != "" {
+			c.lastMessageUUID = m.UUID
+		}
+	}
+}
+
+// Mark records the current point in the conversation under label, so
+// Rollback can return to it later. Marking an existing label moves it.
+//
+// The point is the last message received, so mark between turns, after
+// the ResultMessage.
+func (c *Client) Mark(label string) error {
+	c.turnMu.Lock()
+	defer c.turnMu.Unlock()
+
+	if c.lastSessionID == "" || c.lastMessageUUID == "" {
+		return NewClaudeSDKError("no conversation to mark yet")
+	}
+	if c.marks == nil {
+		c.marks = make(map[string]conversationMark)
+	}
+	c.marks[label] = conversationMark{sessionID: c.lastSessionID, messageUUID: c.lastMessageUUID}
+	return nil
+}
+
+// Rollback returns the conversation to the point recorded by Mark(label).
+//
+// The client reconnects to a fork of the session truncated at the mark,
+// so everything said before the mark is kept and everything after it is
+// discarded. The original session is left untouched, and other marks stay
+// valid. Channels from Messages and Errors must be fetched again after a
+// rollback.
+//
+// If reconnecting fails, the client is left disconnected and Rollback can
+// be retried. The client's options are not changed, so a later Connect
+// starts from them rather than from the mark.
+//
+// Unlike RewindFiles, Rollback does not restore files changed on disk.
+func (c *Client) Rollback(ctx context.Context, label string) error {
+	c.turnMu.Lock()
+	mark, ok := c.marks[label]
+	c.turnMu.Unlock()
+
+	if !ok {
+		return NewClaudeSDKError(fmt.Sprintf("unknown mark: %q", label))
+	}
+
+	if err := c.Close(); err != nil {
+		return err
+	}
+	c.emit(Reconnecting{Reason: "rollback", SessionID: mark.sessionID})
+	if err := c.connect(ctx, &mark); err != nil {
+		return err
+	}
+
+	c.turnMu.Lock()
+	c.lastSessionID = mark.sessionID
+	c.lastMessageUUID = mark.messageUUID
+	c.pendingInterrupt = nil
+	c.turnMu.Unlock()
+	return nil
+}
+
 // GetMCPStatus gets current MCP server connection status.
 //
 // Returns a map with "mcpServers" key containing a list of server status objects.
@@ -320,6 +1043,21 @@ func (c *Client) GetMCPStatus(ctx context.Context) (map[string]any, error) {
 	return c.query.GetMCPStatus(ctx)
 }
 
+// AvailableBetas returns the betas the CLI supports, as it reports them
+// when connecting. If it reports none, the betas the SDK defines
+// constants for are returned.
+func (c *Client) AvailableBetas(ctx context.Context) ([]SdkBeta, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.connected {
+		return nil, NewCLIConnectionError("Not connected. Call Connect() first.")
+	}
+	if betas := reportedBetas(c.query.InitResult()); len(betas) > 0 {
+		return betas, nil
+	}
+	return append([]SdkBeta(nil), knownBetas...), nil
+}
+
 // GetServerInfo returns server initialization info.
 //
 // Returns information from the Claude Code server including available commands
@@ -334,24 +1072,88 @@ func (c *Client) GetServerInfo() map[string]any {
 	return c.query.InitResult()
 }
 
-// Close disconnects from Claude Code.
+// LastTurnTiming returns the timing of the last turn that ended, or nil
+// if none has. It is also attached to the turn's ResultMessage.
+func (c *Client) LastTurnTiming() *TurnTiming {
+	return c.timer.lastTiming()
+}
+
+// ToolStats returns usage statistics for each tool Claude has invoked in
+// this session, keyed by tool name. Statistics persist across Rollback.
+func (c *Client) ToolStats() map[string]ToolStats {
+	snapshot := c.toolStats.Snapshot()
+	stats := make(map[string]ToolStats, len(snapshot))
+	for name, stat := range snapshot {
+		stats[name] = ToolStats(stat)
+	}
+	return stats
+}
+
+// ContextUsage returns the estimated context usage of the conversation
+// and the tokens of the session so far.
+func (c *Client) ContextUsage() ContextUsage {
+	return c.contextUsage.snapshot()
+}
+
+// PendingChanges returns the file changes Claude made with its Write,
+// Edit, and MultiEdit tools during the current or most recent turn.
+func (c *Client) PendingChanges() []FileChange {
+	return c.changes.Changes()
+}
+
+// approvedPlan records the mode the session leaves plan mode for when a
+// plan is approved with WithPlanApprover.
+func (c *Client) approvedPlan(mode PermissionMode) {
+	c.session.setPermissionMode(mode, PermissionModeSourcePlan)
+}
+
+// Plan returns the tool calls simulated with WithDryRun during the current
+// or most recent turn, or nil without WithDryRun.
+func (c *Client) Plan() *Plan {
+	return c.dryRun.plan()
+}
+
+// Close disconnects from Claude Code, and closes the sessions started
+// with NewSession.
 func (c *Client) Close() error {
+	c.close(nil)
+	return nil
+}
+
+// close disconnects, closing the transport with closeTransport, or with
+// its Close if nil. It reports whether the client was connected.
+func (c *Client) close(closeTransport func(transport.Transport) error) bool {
+	c.closeSessions()
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	if !c.connected {
-		return nil
+		return false
 	}
 
 	c.connected = false
+	c.endTurn()
+	c.turns.close()
 
 	if c.query != nil {
-		_ = c.query.Close()
+		t := c.transport
+		if closeTransport == nil {
+			closeTransport = func(t transport.Transport) error { return t.Close() }
+		}
+		_ = c.query.CloseWith(func() error { return closeTransport(t) })
 		c.query = nil
 	}
 
 	c.transport = nil
-	return nil
+	if c.exited != nil {
+		<-c.exited
+		c.exited = nil
+	}
+	c.workspaceLock.release()
+	c.workspaceLock = nil
+	c.emit(Closed{})
+	return true
 }
 
 // SetSessionID sets the session ID for subsequent queries.
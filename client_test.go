@@ -3,10 +3,16 @@ package claude
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/protocol"
+	"github.com/afsharalex/claude-agent-sdk-go/internal/transport"
 )
 
 func TestNewClient_Defaults(t *testing.T) {
@@ -161,6 +167,375 @@ func TestClient_Interrupt_NotConnected(t *testing.T) {
 	}
 }
 
+func TestClient_InterruptWithReason_NotConnected(t *testing.T) {
+	client := NewClient()
+
+	err := client.InterruptWithReason(context.Background(), "user cancelled")
+
+	if err == nil {
+		t.Fatal("Expected error when interrupting without connection")
+	}
+
+	if _, ok := err.(*CLIConnectionError); !ok {
+		t.Fatalf("Expected *CLIConnectionError, got %T", err)
+	}
+}
+
+func TestClient_AnnotateInterrupt(t *testing.T) {
+	client := NewClient()
+
+	// No interrupt pending leaves the result untouched
+	result := &ResultMessage{Subtype: "success"}
+	client.annotateInterrupt(result)
+	if result.IsInterrupted() {
+		t.Error("Expected result without interrupt to not be interrupted")
+	}
+
+	reason := "user cancelled"
+	client.pendingInterrupt = &reason
+
+	result = &ResultMessage{Subtype: "error_during_execution", IsError: true}
+	client.annotateInterrupt(result)
+	if !result.IsInterrupted() {
+		t.Error("Expected result to be interrupted")
+	}
+	if result.InterruptReason != "user cancelled" {
+		t.Errorf("Expected reason 'user cancelled', got %q", result.InterruptReason)
+	}
+
+	// The pending interrupt only applies to one turn
+	result = &ResultMessage{Subtype: "success"}
+	client.annotateInterrupt(result)
+	if result.IsInterrupted() {
+		t.Error("Expected next turn to not be interrupted")
+	}
+}
+
+func TestClient_Mark(t *testing.T) {
+	client := NewClient()
+
+	if err := client.Mark("start"); err == nil {
+		t.Error("Expected error marking before any messages")
+	}
+
+	client.trackPosition(&SystemMessage{Subtype: "init", Data: map[string]any{"session_id": "sess-1"}})
+	client.trackPosition(&AssistantMessage{UUID: "msg-1"})
+	if err := client.Mark("first"); err != nil {
+		t.Fatalf("Mark failed: %v", err)
+	}
+
+	client.trackPosition(&UserMessage{UUID: "msg-2"})
+	client.trackPosition(&ResultMessage{SessionID: "sess-1"})
+	if err := client.Mark("second"); err != nil {
+		t.Fatalf("Mark failed: %v", err)
+	}
+
+	if got := client.marks["first"]; got.sessionID != "sess-1" || got.messageUUID != "msg-1" {
+		t.Errorf("Expected first mark at sess-1/msg-1, got %+v", got)
+	}
+	if got := client.marks["second"]; got.messageUUID != "msg-2" {
+		t.Errorf("Expected second mark at msg-2, got %+v", got)
+	}
+}
+
+func TestClient_Rollback_UnknownMark(t *testing.T) {
+	client := NewClient()
+	if err := client.Rollback(context.Background(), "missing"); err == nil {
+		t.Error("Expected error for unknown mark")
+	}
+}
+
+func TestClient_Rollback_ConnectFailure(t *testing.T) {
+	client := NewClient(WithCLIPath(filepath.Join(t.TempDir(), "missing")))
+	client.marks = map[string]conversationMark{"checkpoint": {sessionID: "sess-1", messageUUID: "msg-1"}}
+
+	if err := client.Rollback(context.Background(), "checkpoint"); err == nil {
+		t.Fatal("Expected error when reconnecting fails")
+	}
+
+	// The mark's resume settings only apply to the rollback connection
+	if client.options.Resume != "" || client.options.ResumeSessionAt != "" || client.options.ForkSession {
+		t.Errorf("Expected options to be unchanged, got %+v", client.options)
+	}
+	if _, ok := client.marks["checkpoint"]; !ok {
+		t.Error("Expected mark to survive a failed rollback")
+	}
+}
+
+func TestClient_Rollback(t *testing.T) {
+	// The stub logs its arguments, answers the initialize request, and
+	// replays one turn before waiting for input.
+	argsFile := filepath.Join(t.TempDir(), "args")
+	cli := writeStubCLI(t, `
+echo "$@" >> `+argsFile+`
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+echo '{"type":"system","subtype":"init","session_id":"sess-1"}'
+echo '{"type":"assistant","uuid":"msg-1","message":{"model":"m","content":[{"type":"text","text":"hi"}]}}'
+echo '{"type":"result","subtype":"success","session_id":"sess-1"}'
+cat > /dev/null
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	for range client.ReceiveResponse(ctx) {
+	}
+	if err := client.Mark("checkpoint"); err != nil {
+		t.Fatalf("Mark failed: %v", err)
+	}
+
+	if err := client.Rollback(ctx, "checkpoint"); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	// The new connection delivers messages on fresh channels
+	var count int
+	for range client.ReceiveResponse(ctx) {
+		count++
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 messages after rollback, got %d", count)
+	}
+
+	data, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("Failed to read args: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 CLI invocations, got %d", len(lines))
+	}
+	if strings.Contains(lines[0], "--resume") {
+		t.Errorf("Expected first invocation without --resume, got %q", lines[0])
+	}
+	for _, want := range []string{"--resume sess-1", "--resume-session-at msg-1", "--fork-session"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("Expected %q in rollback invocation, got %q", want, lines[1])
+		}
+	}
+}
+
+func TestClient_ToolStats(t *testing.T) {
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+echo '{"type":"assistant","message":{"model":"m","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{}},{"type":"tool_use","id":"t2","name":"Bash","input":{}}]}}'
+echo '{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"t1","content":"ok"},{"type":"tool_result","tool_use_id":"t2","content":"boom","is_error":true}]}}'
+echo '{"type":"result","subtype":"success","session_id":"sess-1"}'
+cat > /dev/null
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli))
+	if len(client.ToolStats()) != 0 {
+		t.Error("Expected no tool stats before connecting")
+	}
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	for range client.ReceiveResponse(ctx) {
+	}
+
+	bash := client.ToolStats()["Bash"]
+	if bash.Calls != 2 || bash.Failures != 1 {
+		t.Errorf("Expected 2 calls and 1 failure, got %+v", bash)
+	}
+	if bash.OutputBytes != 6 {
+		t.Errorf("Expected 6 output bytes, got %d", bash.OutputBytes)
+	}
+	if bash.FailureRate() != 0.5 {
+		t.Errorf("Expected failure rate 0.5, got %v", bash.FailureRate())
+	}
+}
+
+func TestClient_InterruptWithReason_ResultBeforeAck(t *testing.T) {
+	// The stub ends the interrupted turn before acknowledging the
+	// interrupt, then answers the next prompt normally.
+	cli := writeStubCLI(t, `
+respond() {
+	id=$(echo "$1" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+	echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+}
+read line; respond "$line"
+read line
+read line
+echo '{"type":"result","subtype":"error_during_execution","is_error":true,"session_id":"s"}'
+respond "$line"
+read line
+echo '{"type":"result","subtype":"success","session_id":"s"}'
+cat > /dev/null
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.Query(ctx, "long task"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if err := client.InterruptWithReason(ctx, "user cancelled"); err != nil {
+		t.Fatalf("InterruptWithReason failed: %v", err)
+	}
+
+	var result *ResultMessage
+	for msg := range client.ReceiveResponse(ctx) {
+		result, _ = msg.(*ResultMessage)
+	}
+	if result == nil || !result.IsInterrupted() || result.InterruptReason != "user cancelled" {
+		t.Errorf("Expected interrupted result with reason, got %+v", result)
+	}
+
+	// The next turn is not affected
+	if err := client.Query(ctx, "next task"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	for msg := range client.ReceiveResponse(ctx) {
+		result, _ = msg.(*ResultMessage)
+	}
+	if result == nil || result.IsInterrupted() {
+		t.Errorf("Expected next turn to not be interrupted, got %+v", result)
+	}
+}
+
+func TestClient_InterruptWithReason_SendFailure(t *testing.T) {
+	mock := transport.NewMockTransport().WithWriteError(errors.New("broken pipe"))
+	_ = mock.Connect(context.Background())
+
+	client := NewClient()
+	client.transport = mock
+	client.query = protocol.NewQuery(protocol.QueryConfig{Transport: mock, IsStreamingMode: true})
+	client.connected = true
+	defer func() { _ = client.Close() }()
+
+	if err := client.InterruptWithReason(context.Background(), "user cancelled"); err == nil {
+		t.Fatal("Expected error when the interrupt cannot be sent")
+	}
+	if client.pendingInterrupt != nil {
+		t.Error("Expected pending interrupt to be cleared after a failed send")
+	}
+}
+
+func TestClient_StartTurn_ClearsPendingInterrupt(t *testing.T) {
+	client := NewClient()
+
+	// An interrupt sent between turns does not carry over
+	reason := "late"
+	client.pendingInterrupt = &reason
+	client.startTurn(context.Background())
+
+	result := &ResultMessage{Subtype: "success"}
+	client.annotateInterrupt(result)
+	if result.IsInterrupted() {
+		t.Error("Expected new turn to not be interrupted")
+	}
+}
+
+func TestClient_CancelInterruptsTurn(t *testing.T) {
+	cli := writeStubCLI(t, `
+respond() {
+	id=$(echo "$1" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+	echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+}
+read line; respond "$line"
+read line
+read line
+case "$line" in
+*'"interrupt"'*) respond "$line"; echo '{"type":"result","subtype":"error_during_execution","is_error":true,"session_id":"s"}' ;;
+esac
+cat > /dev/null
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	turnCtx, cancelTurn := context.WithCancel(ctx)
+	if err := client.Query(turnCtx, "long task"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	cancelTurn()
+
+	var result *ResultMessage
+	for msg := range client.ReceiveResponse(ctx) {
+		result, _ = msg.(*ResultMessage)
+	}
+	if result == nil || !result.IsInterrupted() || result.InterruptReason != "context canceled" {
+		t.Errorf("Expected turn interrupted by cancellation, got %+v", result)
+	}
+}
+
+func TestClient_CancelBehavior(t *testing.T) {
+	tests := []struct {
+		name      string
+		behavior  CancelBehavior
+		endTurn   bool
+		wantWrite bool
+	}{
+		{"interrupt by default", "", false, true},
+		{"none", CancelBehaviorNone, false, false},
+		{"ended turn", "", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := transport.NewMockTransport()
+			_ = mock.Connect(context.Background())
+
+			client := NewClient(WithCancelBehavior(tt.behavior))
+			client.transport = mock
+			client.query = protocol.NewQuery(protocol.QueryConfig{Transport: mock, IsStreamingMode: true})
+			client.connected = true
+			defer func() { _ = client.Close() }()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			client.startTurn(ctx)
+			if tt.endTurn {
+				client.endTurn()
+			}
+			cancel()
+
+			deadline := time.Now().Add(time.Second)
+			for len(mock.GetWrittenData()) == 0 && tt.wantWrite && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if !tt.wantWrite {
+				time.Sleep(50 * time.Millisecond)
+			}
+
+			written := mock.GetWrittenData()
+			if tt.wantWrite && (len(written) != 1 || !strings.Contains(written[0], `"interrupt"`)) {
+				t.Errorf("Expected an interrupt request, got %q", written)
+			}
+			if !tt.wantWrite && len(written) != 0 {
+				t.Errorf("Expected nothing written, got %q", written)
+			}
+		})
+	}
+}
+
 func TestClient_SetPermissionMode_NotConnected(t *testing.T) {
 	client := NewClient()
 
@@ -423,12 +798,16 @@ func TestClient_Connect_WithCanUseTool(t *testing.T) {
 		WithCanUseTool(fn),
 	)
 
-	// Connect will fail because CLI isn't available, but we can verify the option was set
-	_ = client.Connect(context.Background())
+	// The transport uses the stdio permission prompt tool
+	if got := client.transportOptions(nil).PermissionPromptToolName; got != "stdio" {
+		t.Errorf("Expected PermissionPromptToolName 'stdio', got '%s'", got)
+	}
 
-	// The PermissionPromptToolName should have been set to "stdio" during Connect
-	if client.options.PermissionPromptToolName != "stdio" {
-		t.Errorf("Expected PermissionPromptToolName 'stdio', got '%s'", client.options.PermissionPromptToolName)
+	// Connect will fail because CLI isn't available, but must not change
+	// the user's options, so reconnecting still passes validation
+	_ = client.Connect(context.Background())
+	if client.options.PermissionPromptToolName != "" {
+		t.Errorf("Expected options to be unchanged, got '%s'", client.options.PermissionPromptToolName)
 	}
 }
 
@@ -514,3 +893,124 @@ func BenchmarkClient_SetSessionID(b *testing.B) {
 		client.SetSessionID("session-id")
 	}
 }
+
+func TestClient_HandlerContext_TurnMetadata(t *testing.T) {
+	client := NewClient()
+
+	base := WithRequestMetadata(context.Background(), map[string]string{"tenant": "acme"})
+
+	// Without turn metadata, the context is returned as is
+	if md := RequestMetadataFromContext(client.handlerContext(base)); md["tenant"] != "acme" || len(md) != 1 {
+		t.Errorf("Expected only connect metadata, got %v", md)
+	}
+
+	client.startTurn(WithRequestMetadata(context.Background(), map[string]string{"trace_id": "t-1"}))
+
+	md := RequestMetadataFromContext(client.handlerContext(base))
+	if md["tenant"] != "acme" {
+		t.Errorf("Expected connect metadata to be preserved, got %v", md)
+	}
+	if md["trace_id"] != "t-1" {
+		t.Errorf("Expected turn metadata to be applied, got %v", md)
+	}
+
+	// A turn without metadata clears the previous turn's metadata
+	client.startTurn(context.Background())
+	if md := RequestMetadataFromContext(client.handlerContext(base)); md["trace_id"] != "" {
+		t.Errorf("Expected turn metadata to be cleared, got %v", md)
+	}
+}
+
+func TestClient_ControlResponseLimit(t *testing.T) {
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+commands=$(head -c 3000 /dev/zero | tr '\0' x)
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{"commands":"'$commands'"}}}'
+sleep 5
+`)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Messages may be small while control responses are large
+	client := NewClient(WithCLIPath(cli), WithMaxBufferSize(100*1024), WithMaxControlResponseSize(2048))
+	err := client.Connect(ctx)
+	defer func() { _ = client.Close() }()
+
+	limitErr, ok := AsBufferLimitError(err)
+	if !ok {
+		t.Fatalf("Expected a BufferLimitError from Connect, got %v", err)
+	}
+	if limitErr.Kind != BufferLimitControlResponse || limitErr.Limit != 2048 {
+		t.Errorf("Expected the control response limit to trip, got %+v", limitErr)
+	}
+
+	client = NewClient(WithCLIPath(cli), WithMaxBufferSize(1024))
+	if err := client.Connect(ctx); err != nil {
+		t.Errorf("Expected the control response to fit its own limit, got %v", err)
+	}
+	_ = client.Close()
+}
+
+func TestClient_StderrLineLimit(t *testing.T) {
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+while read line; do
+  case "$line" in
+  *'"type":"user"'*)
+    head -c 5000 /dev/zero | tr '\0' e >&2
+    echo >&2
+    echo 'done' >&2
+    sleep 0.1
+    echo '{"type":"result","subtype":"success","session_id":"s"}'
+    exit 0
+    ;;
+  esac
+done
+`)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var mu sync.Mutex
+	var lines []string
+	client := NewClient(WithCLIPath(cli), WithMaxStderrLineSize(1000), WithStderr(func(line string) {
+		mu.Lock()
+		lines = append(lines, line)
+		mu.Unlock()
+	}))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.Query(ctx, "Hello"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	var result *ResultMessage
+	for msg := range client.Messages() {
+		if r, ok := msg.(*ResultMessage); ok {
+			result = r
+		}
+	}
+	if result == nil {
+		t.Error("Expected the turn to finish despite the long stderr line")
+	}
+
+	var limitErr *BufferLimitError
+	for err := range client.Errors() {
+		if e, ok := AsBufferLimitError(err); ok {
+			limitErr = e
+		}
+	}
+	if limitErr == nil || limitErr.Kind != BufferLimitStderrLine || limitErr.Limit != 1000 || limitErr.Size != 5000 {
+		t.Errorf("Expected the stderr line limit to trip, got %+v", limitErr)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(lines) != 2 || len(lines[0]) != 1000 || lines[1] != "done" {
+		t.Errorf("Expected the long line truncated and the next one intact, got %d lines", len(lines))
+	}
+}
@@ -0,0 +1,94 @@
+package claude
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+)
+
+// AddDirectory lets Claude access path for the rest of the session, as if
+// it had been passed to WithAddDirs, so that an interactive tool can widen
+// the workspace when the user opens another folder. Relative paths are
+// resolved against the working directory. The directory is kept if the
+// client reconnects, as on Rollback.
+func (c *Client) AddDirectory(ctx context.Context, path string) error {
+	dir, err := c.resolveDirectory(path)
+	if err != nil {
+		return err
+	}
+	if err := c.updatePermissions(ctx, PermissionUpdate{
+		Type:        PermissionUpdateTypeAddDirectories,
+		Directories: []string{dir},
+		Destination: PermissionUpdateDestinationSession,
+	}); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	if !slices.Contains(c.options.AddDirs, dir) {
+		c.options.AddDirs = append(c.options.AddDirs, dir)
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// RemoveDirectory withdraws access to a directory added with WithAddDirs
+// or AddDirectory for the rest of the session.
+func (c *Client) RemoveDirectory(ctx context.Context, path string) error {
+	dir, err := c.resolveDirectory(path)
+	if err != nil {
+		return err
+	}
+	if err := c.updatePermissions(ctx, PermissionUpdate{
+		Type:        PermissionUpdateTypeRemoveDirectories,
+		Directories: []string{dir},
+		Destination: PermissionUpdateDestinationSession,
+	}); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.options.AddDirs = slices.DeleteFunc(slices.Clone(c.options.AddDirs), func(d string) bool {
+		return d == dir || d == path
+	})
+	c.mu.Unlock()
+	return nil
+}
+
+// resolveDirectory makes path absolute against the working directory.
+func (c *Client) resolveDirectory(path string) (string, error) {
+	if path == "" {
+		return "", NewClaudeSDKError("directory path is empty")
+	}
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path), nil
+	}
+
+	c.mu.Lock()
+	cwd := c.options.Cwd
+	c.mu.Unlock()
+	if cwd != "" {
+		return filepath.Join(cwd, path), nil
+	}
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return "", WrapClaudeSDKError("failed to resolve directory", err)
+	}
+	return dir, nil
+}
+
+// updatePermissions applies permission updates to the running session.
+func (c *Client) updatePermissions(ctx context.Context, updates ...PermissionUpdate) error {
+	c.mu.Lock()
+	if !c.connected {
+		c.mu.Unlock()
+		return NewCLIConnectionError("Not connected. Call Connect() first.")
+	}
+	c.mu.Unlock()
+
+	encoded := make([]map[string]any, len(updates))
+	for i := range updates {
+		encoded[i] = updates[i].ToMap()
+	}
+	return c.query.UpdatePermissions(ctx, encoded)
+}
@@ -0,0 +1,80 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_AddRemoveDirectory(t *testing.T) {
+	dir := t.TempDir()
+	cli := writeStubCLI(t, fmt.Sprintf(`
+while read line; do
+  case "$line" in
+  *'"type":"control_request"'*)
+    echo "$line" >> %[1]s/requests
+    id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+    echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+    ;;
+  esac
+done
+`, dir))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli), WithCwd(dir), WithAddDirs([]string{"/shared"}))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.AddDirectory(ctx, "docs"); err != nil {
+		t.Fatalf("AddDirectory failed: %v", err)
+	}
+	if err := client.RemoveDirectory(ctx, "/shared"); err != nil {
+		t.Fatalf("RemoveDirectory failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, "requests"))
+	requests := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(requests) != 3 {
+		t.Fatalf("Expected initialize and two updates, got %q", requests)
+	}
+	for i, want := range []string{
+		`"updates":[{"destination":"session","directories":["` + filepath.Join(dir, "docs") + `"],"type":"addDirectories"}]`,
+		`"updates":[{"destination":"session","directories":["/shared"],"type":"removeDirectories"}]`,
+	} {
+		if got := requests[i+1]; !strings.Contains(got, `"subtype":"update_permissions"`) || !strings.Contains(got, want) {
+			t.Errorf("Expected request with %s, got %s", want, got)
+		}
+	}
+
+	// Reconnections use the updated directories
+	if !slices.Equal(client.options.AddDirs, []string{filepath.Join(dir, "docs")}) {
+		t.Errorf("Expected AddDirs [%s/docs], got %v", dir, client.options.AddDirs)
+	}
+}
+
+func TestClient_AddDirectory_NotConnected(t *testing.T) {
+	client := NewClient()
+	err := client.AddDirectory(context.Background(), "/tmp")
+	if _, ok := err.(*CLIConnectionError); !ok {
+		t.Errorf("Expected *CLIConnectionError, got %T", err)
+	}
+	if len(client.options.AddDirs) != 0 {
+		t.Error("Expected AddDirs to be unchanged")
+	}
+}
+
+func TestClient_AddDirectory_Empty(t *testing.T) {
+	client := NewClient()
+	if err := client.AddDirectory(context.Background(), ""); err == nil {
+		t.Error("Expected an error for an empty path")
+	}
+}
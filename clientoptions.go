@@ -0,0 +1,215 @@
+package claude
+
+import (
+	"context"
+	"maps"
+	"reflect"
+	"slices"
+)
+
+// liveOptions are the Options fields UpdateOptions can apply to a running
+// session.
+var liveOptions = map[string]bool{
+	"Model":          true,
+	"PermissionMode": true,
+	"AllowedTools":   true,
+	"Hooks":          true,
+	"NamedHooks":     true,
+	"HookRegistry":   true,
+}
+
+// UpdateOptions applies opts to the running session, so that a long-lived
+// service can reload its configuration without dropping the conversation.
+//
+// The model, permission mode and allowed tools are changed with control
+// requests. Allowed tools are added and removed as session permission
+// rules. Hooks can be swapped for others on the same events and matchers,
+// as the CLI only learns which hooks exist when the client connects.
+// Functions cannot be compared, so a callback set by opts counts as
+// changed even if it is the same one.
+//
+// If opts change anything else, or add or remove hooks, nothing is applied
+// and a ReconnectRequiredError listing those options is returned; create a
+// new client with them instead.
+func (c *Client) UpdateOptions(ctx context.Context, opts ...Option) error {
+	c.mu.Lock()
+	if !c.connected {
+		c.mu.Unlock()
+		return NewCLIConnectionError("Not connected. Call Connect() first.")
+	}
+	current := *c.options
+	c.mu.Unlock()
+
+	updated := current
+	updated.Env = maps.Clone(current.Env)
+	updated.ExtraArgs = maps.Clone(current.ExtraArgs)
+	updated.Hooks = maps.Clone(current.Hooks)
+	for _, opt := range opts {
+		opt(&updated)
+	}
+	if err := validateOptions(&updated); err != nil {
+		return err
+	}
+
+	changed := changedOptions(&current, &updated, touchedOptions(&current, opts))
+	var reconnect []string
+	for _, name := range changed {
+		if !liveOptions[name] {
+			reconnect = append(reconnect, name)
+		}
+	}
+	if len(reconnect) > 0 {
+		return NewReconnectRequiredError(reconnect)
+	}
+
+	if slices.ContainsFunc(changed, func(name string) bool {
+		return name == "Hooks" || name == "NamedHooks" || name == "HookRegistry"
+	}) {
+		if !c.query.ReplaceHooks(toInternalHooks(c.dryRun.withHook(c.watcher.withHook(allHooks(&updated))))) {
+			return NewReconnectRequiredError([]string{"Hooks"})
+		}
+		c.mu.Lock()
+		c.options.Hooks = updated.Hooks
+		c.options.NamedHooks = updated.NamedHooks
+		c.options.HookRegistry = updated.HookRegistry
+		c.mu.Unlock()
+	}
+
+	if slices.Contains(changed, "Model") {
+		if err := c.SetModel(ctx, updated.Model); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		c.options.Model = updated.Model
+		c.mu.Unlock()
+	}
+
+	if slices.Contains(changed, "PermissionMode") && updated.PermissionMode != "" {
+		if err := c.SetPermissionMode(ctx, updated.PermissionMode); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		c.options.PermissionMode = updated.PermissionMode
+		c.mu.Unlock()
+	}
+
+	if slices.Contains(changed, "AllowedTools") {
+		if err := c.updateAllowedTools(ctx, current.AllowedTools, updated.AllowedTools); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		c.options.AllowedTools = updated.AllowedTools
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// updateAllowedTools adds session allow rules for the tools in updated
+// that are not in current, and removes those for the tools no longer
+// allowed.
+func (c *Client) updateAllowedTools(ctx context.Context, current, updated []string) error {
+	rules := func(tools []string, keep func(string) bool) []PermissionRuleValue {
+		var rules []PermissionRuleValue
+		for _, tool := range tools {
+			if keep(tool) {
+				rules = append(rules, PermissionRuleValue{ToolName: tool})
+			}
+		}
+		return rules
+	}
+	added := rules(updated, func(tool string) bool { return !slices.Contains(current, tool) })
+	removed := rules(current, func(tool string) bool { return !slices.Contains(updated, tool) })
+
+	var updates []PermissionUpdate
+	if len(added) > 0 {
+		updates = append(updates, PermissionUpdate{
+			Type:        PermissionUpdateTypeAddRules,
+			Rules:       added,
+			Behavior:    PermissionBehaviorAllow,
+			Destination: PermissionUpdateDestinationSession,
+		})
+	}
+	if len(removed) > 0 {
+		updates = append(updates, PermissionUpdate{
+			Type:        PermissionUpdateTypeRemoveRules,
+			Rules:       removed,
+			Behavior:    PermissionBehaviorAllow,
+			Destination: PermissionUpdateDestinationSession,
+		})
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return c.updatePermissions(ctx, updates...)
+}
+
+// changedOptions returns the names of the fields that differ between a
+// and b, or that are in touched. Functions are compared by identity, as
+// reflect.DeepEqual only considers nil functions equal.
+func changedOptions(a, b *Options, touched map[string]bool) []string {
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	var changed []string
+	for i := range va.NumField() {
+		name := va.Type().Field(i).Name
+		fa, fb := va.Field(i), vb.Field(i)
+
+		var same bool
+		switch {
+		case touched[name]:
+		case name == "Hooks":
+			same = sameHooks(a.Hooks, b.Hooks)
+		case fa.Kind() == reflect.Func:
+			same = fa.Pointer() == fb.Pointer()
+		default:
+			same = reflect.DeepEqual(fa.Interface(), fb.Interface())
+		}
+		if !same {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// touchedOptions returns the names of the function fields, and Hooks, that
+// opts set. Their new values cannot be told from the current ones: every
+// closure made by the same function literal shares its code pointer, so
+// setting them at all counts as a change.
+func touchedOptions(current *Options, opts []Option) map[string]bool {
+	probe := *current
+	probe.Env = maps.Clone(current.Env)
+	probe.ExtraArgs = maps.Clone(current.ExtraArgs)
+	probe.Hooks = nil
+	v := reflect.ValueOf(&probe).Elem()
+	for i := range v.NumField() {
+		if f := v.Field(i); f.Kind() == reflect.Func && f.CanSet() {
+			f.SetZero()
+		}
+	}
+	for _, opt := range opts {
+		opt(&probe)
+	}
+
+	touched := map[string]bool{}
+	for i := range v.NumField() {
+		if f := v.Field(i); f.Kind() == reflect.Func && f.CanSet() && !f.IsNil() {
+			touched[v.Type().Field(i).Name] = true
+		}
+	}
+	if len(probe.Hooks) > 0 {
+		touched["Hooks"] = true
+	}
+	return touched
+}
+
+// sameHooks reports whether a and b hold the same callbacks for the same
+// events and matchers.
+func sameHooks(a, b map[HookEvent][]HookMatcher) bool {
+	return maps.EqualFunc(a, b, func(x, y []HookMatcher) bool {
+		return slices.EqualFunc(x, y, func(m, n HookMatcher) bool {
+			return m.Matcher == n.Matcher && m.Timeout == n.Timeout &&
+				slices.EqualFunc(m.Hooks, n.Hooks, func(f, g HookCallback) bool {
+					return reflect.ValueOf(f).Pointer() == reflect.ValueOf(g).Pointer()
+				})
+		})
+	})
+}
@@ -0,0 +1,214 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChangedOptions(t *testing.T) {
+	hook := func(context.Context, HookInput, string, HookContext) (HookOutput, error) {
+		return HookOutput{}, nil
+	}
+	current := NewOptions(
+		WithModel("sonnet"),
+		WithEnv(map[string]string{"A": "1"}),
+		WithPreToolUseHook("Bash", hook),
+		WithCanUseTool(func(context.Context, string, map[string]any, ToolPermissionContext) (PermissionResult, error) {
+			return PermissionResultAllow{}, nil
+		}),
+	)
+	if changed := changedOptions(current, current, nil); len(changed) != 0 {
+		t.Errorf("Expected no changes, got %v", changed)
+	}
+
+	updated := *current
+	WithModel("opus")(&updated)
+	WithSystemPrompt("Be brief")(&updated)
+	if changed := changedOptions(current, &updated, nil); !slices.Equal(changed, []string{"SystemPrompt", "Model"}) {
+		t.Errorf("Expected SystemPrompt and Model, got %v", changed)
+	}
+}
+
+func TestTouchedOptions(t *testing.T) {
+	// Closures made by the same factory share a code pointer
+	policy := func(tool string) CanUseToolFunc {
+		return func(_ context.Context, name string, _ map[string]any, _ ToolPermissionContext) (PermissionResult, error) {
+			if name == tool {
+				return PermissionResultDeny{}, nil
+			}
+			return PermissionResultAllow{}, nil
+		}
+	}
+	current := NewOptions(WithCanUseTool(policy("Bash")))
+
+	touched := touchedOptions(current, []Option{WithCanUseTool(policy("Write")), WithModel("opus")})
+	if !touched["CanUseTool"] || touched["Model"] || touched["Hooks"] {
+		t.Errorf("Expected only CanUseTool touched, got %v", touched)
+	}
+	updated := *current
+	WithCanUseTool(policy("Write"))(&updated)
+	if changed := changedOptions(current, &updated, touched); !slices.Equal(changed, []string{"CanUseTool"}) {
+		t.Errorf("Expected CanUseTool changed, got %v", changed)
+	}
+	if current.CanUseTool == nil {
+		t.Error("Expected the current options left alone")
+	}
+
+	hook := func(context.Context, HookInput, string, HookContext) (HookOutput, error) {
+		return HookOutput{}, nil
+	}
+	if touched := touchedOptions(current, []Option{WithPreToolUseHook("Bash", hook)}); !touched["Hooks"] {
+		t.Errorf("Expected Hooks touched, got %v", touched)
+	}
+}
+
+func TestClient_UpdateOptions(t *testing.T) {
+	dir := t.TempDir()
+	cli := writeStubCLI(t, fmt.Sprintf(`
+while read line; do
+  case "$line" in
+  *'"type":"control_request"'*)
+    echo "$line" >> %[1]s/requests
+    id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+    echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+    ;;
+  esac
+done
+`, dir))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli), WithCwd(dir), WithAllowedTools([]string{"Read", "Grep"}))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	err := client.UpdateOptions(ctx,
+		WithModel("opus"),
+		WithPermissionMode(PermissionModeAcceptEdits),
+		WithAllowedTools([]string{"Read", "Edit"}),
+	)
+	if err != nil {
+		t.Fatalf("UpdateOptions failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, "requests"))
+	requests := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(requests) != 4 {
+		t.Fatalf("Expected initialize and three updates, got %q", requests)
+	}
+	for i, want := range []string{
+		`"subtype":"set_model"`,
+		`"subtype":"set_permission_mode"`,
+		`"rules":[{"ruleContent":"","toolName":"Edit"}],"type":"addRules"},{"behavior":"allow","destination":"session","rules":[{"ruleContent":"","toolName":"Grep"}],"type":"removeRules"}`,
+	} {
+		if !strings.Contains(requests[i+1], want) {
+			t.Errorf("Expected request with %s, got %s", want, requests[i+1])
+		}
+	}
+
+	if client.options.Model != "opus" || !slices.Equal(client.options.AllowedTools, []string{"Read", "Edit"}) {
+		t.Errorf("Expected the options to be updated, got %q %v", client.options.Model, client.options.AllowedTools)
+	}
+	if client.PermissionMode() != PermissionModeAcceptEdits {
+		t.Errorf("Expected acceptEdits, got %q", client.PermissionMode())
+	}
+}
+
+func TestClient_UpdateOptions_ReconnectRequired(t *testing.T) {
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+cat > /dev/null
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	hook := func(context.Context, HookInput, string, HookContext) (HookOutput, error) {
+		return HookOutput{}, nil
+	}
+	client := NewClient(WithCLIPath(cli), WithPreToolUseHook("Bash", hook))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	err := client.UpdateOptions(ctx, WithModel("opus"), WithSystemPrompt("Be brief"), WithMaxTurns(3))
+	reconnectErr, ok := AsReconnectRequiredError(err)
+	if !ok {
+		t.Fatalf("Expected ReconnectRequiredError, got %v", err)
+	}
+	if !slices.Equal(reconnectErr.Options, []string{"SystemPrompt", "MaxTurns"}) {
+		t.Errorf("Expected SystemPrompt and MaxTurns, got %v", reconnectErr.Options)
+	}
+	if client.options.Model != "" {
+		t.Errorf("Expected nothing to be applied, got model %q", client.options.Model)
+	}
+
+	// Hooks on new matchers need the CLI to learn of them
+	err = client.UpdateOptions(ctx, WithPreToolUseHook("Write", hook))
+	if reconnectErr, ok := AsReconnectRequiredError(err); !ok || !slices.Equal(reconnectErr.Options, []string{"Hooks"}) {
+		t.Errorf("Expected Hooks to need reconnecting, got %v", err)
+	}
+
+	// Hooks on the same matchers are swapped in place
+	replaced := func(context.Context, HookInput, string, HookContext) (HookOutput, error) {
+		return HookOutput{Decision: HookDecisionBlock}, nil
+	}
+	hooks := map[HookEvent][]HookMatcher{HookEventPreToolUse: slices.Clone(client.options.Hooks[HookEventPreToolUse])}
+	hooks[HookEventPreToolUse][0].Hooks = []HookCallback{replaced}
+	if err := client.UpdateOptions(ctx, WithHooks(hooks)); err != nil {
+		t.Fatalf("Expected hooks to be swapped, got %v", err)
+	}
+	if !sameHooks(client.options.Hooks, hooks) {
+		t.Error("Expected the options to hold the new hooks")
+	}
+
+	// Hooks made by the same factory are swapped too
+	stopping := func(reason string) HookCallback {
+		return func(context.Context, HookInput, string, HookContext) (HookOutput, error) {
+			return HookOutput{StopReason: reason}, nil
+		}
+	}
+	hooks = map[HookEvent][]HookMatcher{HookEventPreToolUse: {{Matcher: "Bash", Hooks: []HookCallback{stopping("first")}, Timeout: 30}}}
+	if err := client.UpdateOptions(ctx, WithHooks(hooks)); err != nil {
+		t.Fatalf("Expected hooks to be swapped, got %v", err)
+	}
+	hooks = map[HookEvent][]HookMatcher{HookEventPreToolUse: {{Matcher: "Bash", Hooks: []HookCallback{stopping("second")}, Timeout: 30}}}
+	if err := client.UpdateOptions(ctx, WithHooks(hooks)); err != nil {
+		t.Fatalf("Expected hooks to be swapped, got %v", err)
+	}
+	output, _ := client.options.Hooks[HookEventPreToolUse][0].Hooks[0](ctx, nil, "", HookContext{})
+	if output.StopReason != "second" {
+		t.Errorf("Expected the second hook, got %q", output.StopReason)
+	}
+
+	// So are permission callbacks, which need reconnecting
+	allowing := func(tool string) CanUseToolFunc {
+		return func(_ context.Context, name string, _ map[string]any, _ ToolPermissionContext) (PermissionResult, error) {
+			return PermissionResultAllow{}, nil
+		}
+	}
+	client.options.CanUseTool = allowing("Bash")
+	err = client.UpdateOptions(ctx, WithCanUseTool(allowing("Write")))
+	if reconnectErr, ok := AsReconnectRequiredError(err); !ok || !slices.Equal(reconnectErr.Options, []string{"CanUseTool"}) {
+		t.Errorf("Expected CanUseTool to need reconnecting, got %v", err)
+	}
+}
+
+func TestClient_UpdateOptions_NotConnected(t *testing.T) {
+	client := NewClient()
+	if err := client.UpdateOptions(context.Background(), WithModel("opus")); !IsConnectionError(err) {
+		t.Errorf("Expected a connection error, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package claude
+
+import "context"
+
+// NewSession starts a new session from c: a connected Client with the
+// current options of c plus opts, its own message stream, and a new session ID.
+// Options that would continue or resume an earlier conversation are
+// dropped, so every session starts fresh. This lets a server application
+// configure one Client and hand out a session per conversation.
+//
+// The CLI runs one conversation per process, so each session currently
+// runs its own CLI process. Sessions are closed when c is, and can also
+// be closed on their own.
+func (c *Client) NewSession(ctx context.Context, opts ...Option) (*Client, error) {
+	id, err := newUUID()
+	if err != nil {
+		return nil, WrapClaudeSDKError("failed to create session ID", err)
+	}
+
+	options := c.currentOptions()
+	options.ContinueConversation = false
+	options.Resume = ""
+	options.ResumeSessionAt = ""
+	options.ForkSession = false
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	session := newClient(options)
+	session.sessionID = id
+	session.newSessionID = id
+	if err := session.Connect(ctx); err != nil {
+		return nil, err
+	}
+
+	c.sessionsMu.Lock()
+	c.sessions = append(c.sessions, session)
+	c.sessionsMu.Unlock()
+	return session, nil
+}
+
+// Fork returns a new connected Client on a fork of c's current session,
+// with the current options of c plus opts. The fork starts with the conversation
+// of c so far under a new session ID, and what is said in either client
+// after that does not reach the other, so one conversation can be
+// branched to explore alternatives.
+//
+// Fork between turns: a turn in progress is only partly kept. Unlike
+// sessions started with NewSession, the fork is not closed with c.
+func (c *Client) Fork(ctx context.Context, opts ...Option) (*Client, error) {
+	sessionID := c.SessionID()
+	if sessionID == "" {
+		return nil, NewClaudeSDKError("no session to fork yet")
+	}
+
+	options := c.currentOptions()
+	options.ContinueConversation = false
+	options.ResumeSessionAt = ""
+	fork := []Option{WithResume(sessionID), WithForkSession(true)}
+	for _, opt := range append(fork, opts...) {
+		opt(options)
+	}
+
+	forked := newClient(options)
+	if err := forked.Connect(ctx); err != nil {
+		return nil, err
+	}
+	return forked, nil
+}
+
+// currentOptions returns a copy of the options of c, as changed by
+// UpdateOptions, AddDirectory and RemoveDirectory, whose maps and slices
+// the options of a session can change without changing those of c.
+func (c *Client) currentOptions() *Options {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	options := cloneOptions(c.options)
+	return &options
+}
+
+// SessionID returns the ID of the current session: the one reported by
+// the CLI, or, before it has reported one, the ID the session was started
+// with by NewSession.
+func (c *Client) SessionID() string {
+	c.turnMu.Lock()
+	defer c.turnMu.Unlock()
+	if c.lastSessionID != "" {
+		return c.lastSessionID
+	}
+	return c.newSessionID
+}
+
+// closeSessions closes the sessions started by NewSession.
+func (c *Client) closeSessions() {
+	c.sessionsMu.Lock()
+	sessions := c.sessions
+	c.sessions = nil
+	c.sessionsMu.Unlock()
+
+	for _, session := range sessions {
+		_ = session.Close()
+	}
+}
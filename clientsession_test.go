@@ -0,0 +1,194 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_NewSession(t *testing.T) {
+	dir := t.TempDir()
+	cli := writeStubCLI(t, fmt.Sprintf(`
+echo "$*" >> %[1]s/args
+while [ "$1" != "--session-id" ] && [ $# -gt 0 ]; do shift; done
+session="$2"
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+while read line; do
+  case "$line" in
+  *'"type":"user"'*)
+    echo '{"type":"system","subtype":"init","session_id":"'$session'"}'
+    echo '{"type":"result","subtype":"success","session_id":"'$session'","result":"'$session'"}'
+    ;;
+  esac
+done
+`, dir))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli), WithModel("sonnet"), WithResume("old-session"))
+	first, err := client.NewSession(ctx)
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	second, err := client.NewSession(ctx, WithModel("opus"))
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	if first.SessionID() == "" || first.SessionID() == second.SessionID() {
+		t.Fatalf("Expected distinct session IDs, got %q and %q", first.SessionID(), second.SessionID())
+	}
+
+	// Each session has its own message stream
+	for _, session := range []*Client{first, second} {
+		if err := session.Query(ctx, "Hello"); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		var result *ResultMessage
+		for msg := range session.ReceiveResponse(ctx) {
+			if r, ok := msg.(*ResultMessage); ok {
+				result = r
+			}
+		}
+		if result == nil || result.Result != session.SessionID() {
+			t.Errorf("Expected the result of session %s, got %+v", session.SessionID(), result)
+		}
+	}
+
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	lines := strings.Split(strings.TrimSpace(string(args)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected a CLI process per session, got %q", lines)
+	}
+	if !strings.Contains(lines[0], "--model sonnet") || !strings.Contains(lines[1], "--model opus") {
+		t.Errorf("Expected session options to apply, got %q", lines)
+	}
+	if strings.Contains(string(args), "--resume") {
+		t.Errorf("Expected sessions to start fresh, got %q", lines)
+	}
+	if client.options.Model != "sonnet" {
+		t.Error("Expected the options of the client to be unchanged")
+	}
+
+	// Closing the client closes its sessions
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for range second.Messages() {
+	}
+	if err := first.Query(ctx, "Again"); err == nil {
+		t.Error("Expected a closed session to reject queries")
+	}
+}
+
+func TestClient_NewSession_AfterUpdateOptions(t *testing.T) {
+	dir := t.TempDir()
+	cli := writeStubCLI(t, fmt.Sprintf(`
+echo "$*" >> %[1]s/args
+while read line; do
+  case "$line" in
+  *'"type":"control_request"'*)
+    id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+    echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+    ;;
+  esac
+done
+`, dir))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli), WithModel("sonnet"), WithEnv(map[string]string{"A": "1"}))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	if err := client.UpdateOptions(ctx, WithModel("opus")); err != nil {
+		t.Fatalf("UpdateOptions failed: %v", err)
+	}
+
+	session, err := client.NewSession(ctx, WithEnvVar("B", "2"))
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	if session.options.Model != "opus" {
+		t.Errorf("Expected the session to have the updated model, got %q", session.options.Model)
+	}
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	lines := strings.Split(strings.TrimSpace(string(args)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], "--model opus") {
+		t.Errorf("Expected the session started with the updated model, got %q", lines)
+	}
+	if _, ok := client.options.Env["B"]; ok {
+		t.Error("Expected the options of the session not to change those of the client")
+	}
+}
+
+func TestClient_Fork(t *testing.T) {
+	dir := t.TempDir()
+	cli := writeStubCLI(t, fmt.Sprintf(`
+echo "$*" >> %[1]s/args
+session=sess-1
+case "$*" in *--fork-session*) session=fork-1 ;; esac
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+while read line; do
+  case "$line" in
+  *'"type":"user"'*)
+    echo '{"type":"system","subtype":"init","session_id":"'$session'"}'
+    echo '{"type":"result","subtype":"success","session_id":"'$session'"}'
+    ;;
+  esac
+done
+`, dir))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli), WithModel("sonnet"))
+	if _, err := client.Fork(ctx); err == nil {
+		t.Error("Expected an error forking before there is a session")
+	}
+
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	if err := client.Query(ctx, "Hello"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	for range client.ReceiveResponse(ctx) {
+	}
+
+	fork, err := client.Fork(ctx, WithModel("opus"))
+	if err != nil {
+		t.Fatalf("Fork failed: %v", err)
+	}
+	defer func() { _ = fork.Close() }()
+	if err := fork.Query(ctx, "Try another way"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	for range fork.ReceiveResponse(ctx) {
+	}
+
+	if fork.SessionID() != "fork-1" || client.SessionID() != "sess-1" {
+		t.Errorf("Expected the fork to have its own session, got %q and %q", fork.SessionID(), client.SessionID())
+	}
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	lines := strings.Split(strings.TrimSpace(string(args)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected a CLI process for the fork, got %q", lines)
+	}
+	for _, want := range []string{"--resume sess-1", "--fork-session", "--model opus"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("Expected %q in the fork's invocation, got %q", want, lines[1])
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Command claude-bench load-tests the SDK's protocol layer with fake
+// sessions, reporting throughput, latency, allocations and goroutines.
+//
+// Usage:
+//
+//	claude-bench -sessions 100 -messages 1000 -rate 500
+//	claude-bench -sessions 100 -lowalloc -json
+//
+// It exits with status 1 if the run fails or leaks goroutines.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/afsharalex/claude-agent-sdk-go/bench"
+)
+
+func main() {
+	var cfg bench.Config
+	flag.IntVar(&cfg.Sessions, "sessions", 10, "number of concurrent sessions")
+	flag.IntVar(&cfg.Messages, "messages", 1000, "stream events per session")
+	flag.Float64Var(&cfg.Rate, "rate", 0, "messages per second per session (0 for unpaced)")
+	flag.IntVar(&cfg.PayloadSize, "payload", 32, "text bytes per stream event")
+	flag.BoolVar(&cfg.LowAllocParsing, "lowalloc", false, "decode with the low-allocation decoder")
+	asJSON := flag.Bool("json", false, "print the result as JSON")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	result, err := bench.Run(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "claude-bench:", err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(result)
+	} else {
+		fmt.Println(result)
+	}
+	if result.LeakedGoroutines > 0 {
+		fmt.Fprintf(os.Stderr, "claude-bench: %d goroutines leaked\n", result.LeakedGoroutines)
+		os.Exit(1)
+	}
+}
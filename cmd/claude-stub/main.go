@@ -0,0 +1,645 @@
+// Command claude-stub stands in for the Claude Code CLI in tests. It
+// speaks enough of the stream-json protocol to exercise the SDK's
+// connect, initialize, hook, permission, SDK MCP server and interrupt
+// paths, without an Anthropic account or network access.
+//
+// Each prompt is a script for the turn it starts:
+//
+//	tool <name> [json]  calls the tool with the JSON input: PreToolUse
+//	                    hooks and the permission callback are asked
+//	                    first, and tools of SDK MCP servers are called
+//	                    through the SDK. The permission callback is
+//	                    offered a rule allowing the tool, after which it
+//	                    is not asked about the tool again
+//	wait                streams a message and waits for an interrupt
+//	fail                ends the turn with an error result
+//	anything else       is echoed back as "echo: <prompt>"
+//
+// Responses report their token usage as if a token were four bytes.
+//
+// The doctor and update subcommands print a healthy report and report
+// the stub as up to date.
+//
+// Usage:
+//
+//	go build -o /tmp/claude ./cmd/claude-stub
+//	claude.NewClient(claude.WithCLIPath("/tmp/claude"))
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// version is reported for -v, above the SDK's minimum.
+const version = "2.1.0 (Claude Code stub)"
+
+// builtinTools are the tools reported in the init message besides those
+// of SDK MCP servers.
+var builtinTools = []string{"Bash", "Edit", "Glob", "Grep", "Read", "Task", "WebFetch", "WebSearch", "Write"}
+
+// config holds the flags the stub acts on.
+type config struct {
+	print          bool
+	prompt         string
+	hasPrompt      bool
+	sessionID      string
+	model          string
+	permissionMode string
+	promptTool     string
+	mcpServers     map[string]map[string]any
+}
+
+func main() {
+	args := os.Args[1:]
+	if len(args) == 1 && (args[0] == "-v" || args[0] == "--version") {
+		fmt.Println(version)
+		return
+	}
+	if len(args) > 0 && (args[0] == "doctor" || args[0] == "update") {
+		subcommand(args[0])
+		return
+	}
+
+	cfg, err := parseArgs(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "claude-stub:", err)
+		os.Exit(2)
+	}
+
+	s := &stub{
+		cfg:       cfg,
+		out:       bufio.NewWriter(os.Stdout),
+		pending:   make(map[string]chan map[string]any),
+		allowed:   make(map[string]bool),
+		interrupt: make(chan struct{}, 1),
+		closed:    make(chan struct{}),
+	}
+	if cfg.print {
+		prompt := cfg.prompt
+		if !cfg.hasPrompt {
+			data, _ := io.ReadAll(os.Stdin)
+			prompt = string(data)
+		}
+		// There is no input to wait on in print mode
+		close(s.closed)
+		s.turn(strings.TrimSpace(prompt))
+		return
+	}
+	s.serve(os.Stdin)
+}
+
+// subcommand runs the doctor or update subcommand.
+func subcommand(name string) {
+	number, _, _ := strings.Cut(version, " ")
+	if name == "update" {
+		fmt.Printf("Current version: %s\nChecking for updates...\nClaude Code is up to date (%s)\n", number, number)
+		return
+	}
+	fmt.Printf(" Diagnostics\n └ Currently running: stub (%s)\n └ Path: %s\n └ Auto-updates: disabled\n\n Press Enter to continue…\n", number, os.Args[0])
+	_, _ = bufio.NewReader(os.Stdin).ReadString('\n')
+}
+
+// parseArgs reads the flags of the CLI command line the stub acts on,
+// skipping the others.
+func parseArgs(args []string) (*config, error) {
+	cfg := &config{sessionID: fmt.Sprintf("stub-session-%d", os.Getpid()), model: "claude-stub", permissionMode: "default"}
+	for i := 0; i < len(args); i++ {
+		value := func() string {
+			if i+1 < len(args) {
+				i++
+				return args[i]
+			}
+			return ""
+		}
+		switch args[i] {
+		case "--print", "-p":
+			cfg.print = true
+		case "--":
+			cfg.prompt = strings.Join(args[i+1:], " ")
+			cfg.hasPrompt = true
+			return cfg, nil
+		case "--session-id", "--resume":
+			cfg.sessionID = value()
+		case "--model":
+			cfg.model = value()
+		case "--permission-mode":
+			cfg.permissionMode = value()
+		case "--permission-prompt-tool":
+			cfg.promptTool = value()
+		case "--mcp-config":
+			var mcp struct {
+				MCPServers map[string]map[string]any `json:"mcpServers"`
+			}
+			if err := json.Unmarshal([]byte(value()), &mcp); err != nil {
+				return nil, fmt.Errorf("invalid --mcp-config: %w", err)
+			}
+			cfg.mcpServers = mcp.MCPServers
+		}
+	}
+	return cfg, nil
+}
+
+// stub is a running stub CLI.
+type stub struct {
+	cfg *config
+
+	outMu sync.Mutex
+	out   *bufio.Writer
+
+	mu        sync.Mutex
+	nextID    int
+	pending   map[string]chan map[string]any
+	hooks     map[string][]hookMatcher
+	allowed   map[string]bool // tools allowed by accepted suggestions
+	servers   []map[string]any
+	started   bool
+	interrupt chan struct{}
+	closed    chan struct{} // closed when stdin is
+	turns     sync.WaitGroup
+
+	// tokens counts the tokens in context, at four bytes a token, and
+	// turnInput and turnOutput the tokens of the turn in progress.
+	tokens     int
+	turnInput  int
+	turnOutput int
+}
+
+// hookMatcher is a matcher of the hooks registered at initialize.
+type hookMatcher struct {
+	Matcher     string   `json:"matcher"`
+	CallbackIDs []string `json:"hookCallbackIds"`
+}
+
+// serve reads messages from r until it is closed, running a turn for each
+// user message and answering control requests.
+func (s *stub) serve(r io.Reader) {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			var msg map[string]any
+			if json.Unmarshal(line, &msg) == nil {
+				s.handle(msg)
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	close(s.closed)
+	s.turns.Wait()
+}
+
+func (s *stub) handle(msg map[string]any) {
+	switch msg["type"] {
+	case "control_response":
+		response, _ := msg["response"].(map[string]any)
+		id, _ := response["request_id"].(string)
+		s.mu.Lock()
+		ch := s.pending[id]
+		delete(s.pending, id)
+		s.mu.Unlock()
+		if ch != nil {
+			ch <- response
+		}
+	case "control_request":
+		id, _ := msg["request_id"].(string)
+		request, _ := msg["request"].(map[string]any)
+		s.control(id, request)
+	case "user":
+		message, _ := msg["message"].(map[string]any)
+		s.turns.Add(1)
+		go func() {
+			defer s.turns.Done()
+			s.turn(strings.TrimSpace(textOf(message["content"])))
+		}()
+	}
+}
+
+// control answers a control request from the SDK.
+func (s *stub) control(id string, request map[string]any) {
+	response := map[string]any{}
+	switch request["subtype"] {
+	case "initialize":
+		if hooks, ok := request["hooks"]; ok {
+			data, _ := json.Marshal(hooks)
+			var parsed map[string][]hookMatcher
+			_ = json.Unmarshal(data, &parsed)
+			s.mu.Lock()
+			s.hooks = parsed
+			s.mu.Unlock()
+		}
+		response = map[string]any{
+			"commands":                []any{map[string]any{"name": "compact", "description": "Compact the conversation", "argumentHint": ""}},
+			"output_style":            "default",
+			"available_output_styles": []any{"default"},
+			"models":                  []any{map[string]any{"value": s.cfg.model, "displayName": "Stub", "description": "The stub model"}},
+		}
+	case "interrupt":
+		select {
+		case s.interrupt <- struct{}{}:
+		default:
+		}
+	case "set_model":
+		if model, ok := request["model"].(string); ok {
+			s.mu.Lock()
+			s.cfg.model = model
+			s.mu.Unlock()
+		}
+	case "set_permission_mode":
+		if mode, ok := request["mode"].(string); ok {
+			s.mu.Lock()
+			s.cfg.permissionMode = mode
+			s.mu.Unlock()
+		}
+	case "mcp_status":
+		s.mu.Lock()
+		response = map[string]any{"mcpServers": s.servers}
+		s.mu.Unlock()
+	}
+	s.send(map[string]any{
+		"type":     "control_response",
+		"response": map[string]any{"subtype": "success", "request_id": id, "response": response},
+	})
+}
+
+// request sends a control request to the SDK and returns its response,
+// or nil if the SDK reported an error.
+func (s *stub) request(request map[string]any) map[string]any {
+	if s.cfg.print {
+		return nil
+	}
+	ch := make(chan map[string]any, 1)
+	s.mu.Lock()
+	s.nextID++
+	id := fmt.Sprintf("stub_req_%d", s.nextID)
+	s.pending[id] = ch
+	s.mu.Unlock()
+
+	s.send(map[string]any{"type": "control_request", "request_id": id, "request": request})
+	var response map[string]any
+	select {
+	case response = <-ch:
+	case <-s.closed:
+	}
+	if response["subtype"] != "success" {
+		return nil
+	}
+	data, _ := response["response"].(map[string]any)
+	return data
+}
+
+// send writes msg as a JSON line.
+func (s *stub) send(msg map[string]any) {
+	data, _ := json.Marshal(msg)
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	_, _ = s.out.Write(append(data, '\n'))
+	_ = s.out.Flush()
+}
+
+// start sends the init message on the first turn, listing the tools of
+// the SDK MCP servers.
+func (s *stub) start() {
+	s.mu.Lock()
+	started := s.started
+	s.started = true
+	s.mu.Unlock()
+	if started {
+		return
+	}
+
+	tools := append([]string(nil), builtinTools...)
+	servers := []map[string]any{}
+	for name, server := range s.cfg.mcpServers {
+		status := "failed"
+		if server["type"] == "sdk" {
+			if listed := s.mcp(name, "tools/list", nil); listed != nil {
+				status = "connected"
+				list, _ := listed["tools"].([]any)
+				for _, t := range list {
+					tool, _ := t.(map[string]any)
+					if toolName, ok := tool["name"].(string); ok {
+						tools = append(tools, "mcp__"+name+"__"+toolName)
+					}
+				}
+			}
+		}
+		servers = append(servers, map[string]any{"name": name, "status": status})
+	}
+	s.mu.Lock()
+	s.servers = servers
+	model, mode := s.cfg.model, s.cfg.permissionMode
+	s.mu.Unlock()
+
+	cwd, _ := os.Getwd()
+	s.send(map[string]any{
+		"type":           "system",
+		"subtype":        "init",
+		"session_id":     s.cfg.sessionID,
+		"cwd":            cwd,
+		"model":          model,
+		"permissionMode": mode,
+		"tools":          tools,
+		"mcp_servers":    servers,
+		"apiKeySource":   "none",
+	})
+}
+
+// turn runs the script of prompt.
+func (s *stub) turn(prompt string) {
+	s.start()
+	// An interrupt sent between turns does not carry over
+	select {
+	case <-s.interrupt:
+	default:
+	}
+
+	s.mu.Lock()
+	s.tokens += tokenCount(prompt)
+	s.turnInput, s.turnOutput = 0, 0
+	s.mu.Unlock()
+
+	fields := strings.SplitN(prompt, " ", 3)
+	switch fields[0] {
+	case "tool":
+		if len(fields) < 2 {
+			s.text("tool needs a name")
+			s.result("success", false, "tool needs a name")
+			return
+		}
+		input := map[string]any{}
+		if len(fields) == 3 {
+			if err := json.Unmarshal([]byte(fields[2]), &input); err != nil {
+				s.result("error_during_execution", true, "invalid tool input: "+err.Error())
+				return
+			}
+		}
+		s.tool(fields[1], input)
+	case "wait":
+		s.text("waiting for an interrupt")
+		select {
+		case <-s.interrupt:
+		case <-s.closed:
+		}
+		s.result("error_during_execution", false, "")
+	case "fail":
+		s.result("error_during_execution", true, "the stub was asked to fail")
+	default:
+		s.text("echo: " + prompt)
+		s.result("success", false, "echo: "+prompt)
+	}
+}
+
+// tool runs a call of the tool name with input.
+func (s *stub) tool(name string, input map[string]any) {
+	s.mu.Lock()
+	s.nextID++
+	toolUseID := fmt.Sprintf("toolu_stub_%d", s.nextID)
+	s.mu.Unlock()
+
+	s.assistant([]any{map[string]any{"type": "tool_use", "id": toolUseID, "name": name, "input": input}})
+
+	output, isError := s.runTool(name, toolUseID, input)
+	s.mu.Lock()
+	s.tokens += tokenCount(output)
+	s.mu.Unlock()
+	s.send(map[string]any{
+		"type":       "user",
+		"session_id": s.cfg.sessionID,
+		"message": map[string]any{"role": "user", "content": []any{map[string]any{
+			"type": "tool_result", "tool_use_id": toolUseID, "content": output, "is_error": isError,
+		}}},
+	})
+
+	s.text("tool " + name + " done")
+	s.result("success", false, output)
+}
+
+// runTool asks the hooks and the permission callback about a call of the
+// tool name, then runs it, returning its output and whether it failed.
+func (s *stub) runTool(name, toolUseID string, input map[string]any) (string, bool) {
+	hookInput := map[string]any{
+		"hook_event_name": "PreToolUse",
+		"session_id":      s.cfg.sessionID,
+		"tool_name":       name,
+		"tool_input":      input,
+		"tool_use_id":     toolUseID,
+	}
+	for _, output := range s.runHooks("PreToolUse", name, toolUseID, hookInput) {
+		if reason, denied := hookDenial(output); denied {
+			return "Blocked by hook: " + reason, true
+		}
+	}
+
+	s.mu.Lock()
+	allowed := s.allowed[name]
+	s.mu.Unlock()
+	if s.cfg.promptTool == "stdio" && !allowed {
+		response := s.request(map[string]any{
+			"subtype":     "can_use_tool",
+			"tool_name":   name,
+			"input":       input,
+			"tool_use_id": toolUseID,
+			"permission_suggestions": []any{map[string]any{
+				"type":        "addRules",
+				"rules":       []any{map[string]any{"toolName": name}},
+				"behavior":    "allow",
+				"destination": "session",
+			}},
+		})
+		if response == nil {
+			return "Permission request failed", true
+		}
+		if response["behavior"] != "allow" {
+			message, _ := response["message"].(string)
+			return "Permission denied: " + message, true
+		}
+		if updated, ok := response["updatedInput"].(map[string]any); ok {
+			input = updated
+		}
+		s.applyPermissions(response["updatedPermissions"])
+	}
+
+	var output string
+	isError := false
+	if server, tool, ok := strings.Cut(strings.TrimPrefix(name, "mcp__"), "__"); ok && strings.HasPrefix(name, "mcp__") {
+		result := s.mcp(server, "tools/call", map[string]any{"name": tool, "arguments": input})
+		if result == nil {
+			return "MCP tool call failed", true
+		}
+		output = textOf(result["content"])
+		isError, _ = result["isError"].(bool)
+	} else {
+		data, _ := json.Marshal(input)
+		output = fmt.Sprintf("stub ran %s with %s", name, data)
+	}
+
+	hookInput["hook_event_name"] = "PostToolUse"
+	hookInput["tool_response"] = output
+	s.runHooks("PostToolUse", name, toolUseID, hookInput)
+	return output, isError
+}
+
+// applyPermissions applies the setMode updates of an allow response, and
+// records the tools allowed by its addRules updates, which are then not
+// asked about again.
+func (s *stub) applyPermissions(updates any) {
+	list, _ := updates.([]any)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range list {
+		update, _ := u.(map[string]any)
+		if mode, ok := update["mode"].(string); ok && update["type"] == "setMode" {
+			s.cfg.permissionMode = mode
+			continue
+		}
+		if update["type"] != "addRules" || update["behavior"] != "allow" {
+			continue
+		}
+		rules, _ := update["rules"].([]any)
+		for _, r := range rules {
+			rule, _ := r.(map[string]any)
+			if name, ok := rule["toolName"].(string); ok {
+				s.allowed[name] = true
+			}
+		}
+	}
+}
+
+// runHooks calls the callbacks of the hooks for event matching tool, and
+// returns their outputs.
+func (s *stub) runHooks(event, tool, toolUseID string, input map[string]any) []map[string]any {
+	s.mu.Lock()
+	matchers := s.hooks[event]
+	s.mu.Unlock()
+
+	var outputs []map[string]any
+	for _, m := range matchers {
+		if m.Matcher != "" && m.Matcher != "*" {
+			if re, err := regexp.Compile("^(?:" + m.Matcher + ")$"); err != nil || !re.MatchString(tool) {
+				continue
+			}
+		}
+		for _, id := range m.CallbackIDs {
+			output := s.request(map[string]any{
+				"subtype":     "hook_callback",
+				"callback_id": id,
+				"input":       input,
+				"tool_use_id": toolUseID,
+			})
+			if output != nil {
+				outputs = append(outputs, output)
+			}
+		}
+	}
+	return outputs
+}
+
+// hookDenial reports whether a PreToolUse hook output blocks the tool
+// call, and why.
+func hookDenial(output map[string]any) (string, bool) {
+	reason, _ := output["reason"].(string)
+	if output["decision"] == "block" {
+		return reason, true
+	}
+	specific, _ := output["hookSpecificOutput"].(map[string]any)
+	if specific["permissionDecision"] == "deny" {
+		if r, ok := specific["permissionDecisionReason"].(string); ok {
+			reason = r
+		}
+		return reason, true
+	}
+	return "", false
+}
+
+// mcp sends a JSON-RPC request to the SDK MCP server and returns its
+// result, or nil if it failed.
+func (s *stub) mcp(server, method string, params map[string]any) map[string]any {
+	s.mu.Lock()
+	s.nextID++
+	id := s.nextID
+	s.mu.Unlock()
+
+	message := map[string]any{"jsonrpc": "2.0", "id": id, "method": method}
+	if params != nil {
+		message["params"] = params
+	}
+	response := s.request(map[string]any{"subtype": "mcp_message", "server_name": server, "message": message})
+	reply, _ := response["mcp_response"].(map[string]any)
+	result, _ := reply["result"].(map[string]any)
+	return result
+}
+
+// text sends an assistant message with text.
+func (s *stub) text(text string) {
+	s.assistant([]any{map[string]any{"type": "text", "text": text}})
+}
+
+func (s *stub) assistant(content []any) {
+	data, _ := json.Marshal(content)
+	output := tokenCount(string(data))
+	s.mu.Lock()
+	model := s.cfg.model
+	input := s.tokens
+	s.tokens += output
+	s.turnInput += input
+	s.turnOutput += output
+	s.mu.Unlock()
+	s.send(map[string]any{
+		"type":       "assistant",
+		"session_id": s.cfg.sessionID,
+		"message": map[string]any{
+			"role": "assistant", "model": model, "content": content,
+			"usage": map[string]any{"input_tokens": input, "output_tokens": output},
+		},
+	})
+}
+
+// tokenCount returns the tokens of text, at four bytes a token.
+func tokenCount(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// result ends the turn.
+func (s *stub) result(subtype string, isError bool, result string) {
+	s.mu.Lock()
+	input, output := s.turnInput, s.turnOutput
+	s.mu.Unlock()
+	s.send(map[string]any{
+		"type":            "result",
+		"subtype":         subtype,
+		"is_error":        isError,
+		"result":          result,
+		"session_id":      s.cfg.sessionID,
+		"num_turns":       1,
+		"duration_ms":     1,
+		"duration_api_ms": 0,
+		"total_cost_usd":  0,
+		"usage":           map[string]any{"input_tokens": input, "output_tokens": output},
+	})
+}
+
+// textOf returns the text of message content: a string, or the text of
+// its text blocks.
+func textOf(content any) string {
+	if text, ok := content.(string); ok {
+		return text
+	}
+	blocks, _ := content.([]any)
+	var parts []string
+	for _, b := range blocks {
+		block, _ := b.(map[string]any)
+		if text, ok := block["text"].(string); ok {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
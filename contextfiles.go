@@ -0,0 +1,293 @@
+package claude
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ContextFilePlacement is where the files of WithContextFiles are put.
+type ContextFilePlacement string
+
+const (
+	// ContextFilePlacementPrompt puts the files before the first user
+	// prompt. This is the default.
+	ContextFilePlacementPrompt ContextFilePlacement = "prompt"
+	// ContextFilePlacementSystemPrompt appends the files to the system
+	// prompt.
+	ContextFilePlacementSystemPrompt ContextFilePlacement = "system_prompt"
+)
+
+// ContextFileTruncation is how the files of WithContextFiles are cut down
+// to fit their token limit. Files are given equal shares of the limit,
+// with the share a small file does not use going to the larger ones.
+type ContextFileTruncation string
+
+const (
+	// ContextFileTruncateEnd keeps the start of each file. This is the
+	// default.
+	ContextFileTruncateEnd ContextFileTruncation = "end"
+	// ContextFileTruncateMiddle keeps the start and end of each file.
+	ContextFileTruncateMiddle ContextFileTruncation = "middle"
+	// ContextFileOmit leaves out the files that do not fit whole, in the
+	// order they were given.
+	ContextFileOmit ContextFileTruncation = "omit"
+)
+
+// DefaultContextFileMaxTokens is the token limit of the files of
+// WithContextFiles if none is set.
+const DefaultContextFileMaxTokens = 50_000
+
+// bytesPerToken estimates the size of a token of file content.
+const bytesPerToken = 4
+
+// contextFilesHeader introduces the files in the prompt.
+const contextFilesHeader = "The following files are provided as context."
+
+// contextFiles holds the files of WithContextFiles, rendered once per
+// session. With ContextFilePlacementPrompt, they are put before the first
+// prompt sent. A nil contextFiles leaves prompts unchanged.
+type contextFiles struct {
+	placement ContextFilePlacement
+	text      string
+
+	mu      sync.Mutex
+	pending bool
+}
+
+// loadContextFiles reads and renders the files of o, returning nil if
+// there are none.
+func loadContextFiles(o *Options) (*contextFiles, error) {
+	if len(o.ContextFiles) == 0 {
+		return nil, nil
+	}
+	switch o.ContextFilePlacement {
+	case "", ContextFilePlacementPrompt, ContextFilePlacementSystemPrompt:
+	default:
+		return nil, NewClaudeSDKError(fmt.Sprintf("unsupported context file placement %q", o.ContextFilePlacement))
+	}
+	switch o.ContextFileTruncation {
+	case "", ContextFileTruncateEnd, ContextFileTruncateMiddle, ContextFileOmit:
+	default:
+		return nil, NewClaudeSDKError(fmt.Sprintf("unsupported context file truncation %q", o.ContextFileTruncation))
+	}
+
+	contents := make([][]byte, len(o.ContextFiles))
+	for i, path := range o.ContextFiles {
+		if !filepath.IsAbs(path) && o.Cwd != "" {
+			path = filepath.Join(o.Cwd, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, WrapClaudeSDKError("cannot read context file", err)
+		}
+		if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+			return nil, NewClaudeSDKError(fmt.Sprintf("context file %s is not text", o.ContextFiles[i]))
+		}
+		contents[i] = data
+	}
+
+	maxTokens := o.ContextFileMaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultContextFileMaxTokens
+	}
+	return &contextFiles{
+		placement: o.ContextFilePlacement,
+		text:      renderContextFiles(o.ContextFiles, contents, maxTokens*bytesPerToken, o.ContextFileTruncation),
+		pending:   o.ContextFilePlacement != ContextFilePlacementSystemPrompt,
+	}, nil
+}
+
+// renderContextFiles fences each file with its path, fitting the contents
+// in budget bytes.
+func renderContextFiles(paths []string, contents [][]byte, budget int, truncation ContextFileTruncation) string {
+	sizes := make([]int, len(contents))
+	for i, data := range contents {
+		sizes[i] = len(data)
+	}
+	shares := fairShares(sizes, budget)
+
+	var b strings.Builder
+	b.WriteString(contextFilesHeader)
+	for i, path := range paths {
+		data := contents[i]
+		b.WriteString("\n\n")
+		if truncation == ContextFileOmit {
+			if len(data) > budget {
+				fmt.Fprintf(&b, "%s was omitted: its %d bytes do not fit the context limit.", path, len(data))
+				continue
+			}
+			budget -= len(data)
+			writeFenced(&b, path, string(data))
+			continue
+		}
+
+		if shares[i] >= len(data) {
+			writeFenced(&b, path, string(data))
+			continue
+		}
+		writeFenced(&b, path, truncate(data, shares[i], truncation))
+	}
+	return b.String()
+}
+
+// fairShares divides budget between files of sizes, giving each an equal
+// share and passing on what a file does not need.
+func fairShares(sizes []int, budget int) []int {
+	order := make([]int, len(sizes))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return cmp.Compare(sizes[a], sizes[b]) })
+
+	shares := make([]int, len(sizes))
+	for n, i := range order {
+		share := budget / (len(order) - n)
+		shares[i] = min(sizes[i], share)
+		budget -= shares[i]
+	}
+	return shares
+}
+
+// truncate cuts data down to about size bytes, breaking at lines where it
+// can, and marks the cut.
+func truncate(data []byte, size int, truncation ContextFileTruncation) string {
+	if truncation == ContextFileTruncateMiddle {
+		head := cutHead(data, size/2)
+		tail := cutTail(data, size-size/2)
+		return fmt.Sprintf("%s\n[... %d bytes truncated ...]\n%s",
+			head, len(data)-len(head)-len(tail), tail)
+	}
+	head := cutHead(data, size)
+	return fmt.Sprintf("%s\n[... %d bytes truncated ...]", head, len(data)-len(head))
+}
+
+// cutHead returns the start of data up to size bytes, ending at a line
+// break in the second half if there is one.
+func cutHead(data []byte, size int) []byte {
+	head := data[:size]
+	for len(head) > 0 && !utf8.Valid(head) {
+		head = head[:len(head)-1]
+	}
+	if i := bytes.LastIndexByte(head, '\n'); i >= len(head)/2 {
+		head = head[:i]
+	}
+	return head
+}
+
+// cutTail returns the end of data up to size bytes, starting after a line
+// break in the first half if there is one.
+func cutTail(data []byte, size int) []byte {
+	tail := data[len(data)-size:]
+	for len(tail) > 0 && !utf8.Valid(tail) {
+		tail = tail[1:]
+	}
+	if i := bytes.IndexByte(tail, '\n'); i >= 0 && i < len(tail)/2 {
+		tail = tail[i+1:]
+	}
+	return tail
+}
+
+// writeFenced writes content in a code fence annotated with path. The
+// fence is longer than any run of backticks in content.
+func writeFenced(b *strings.Builder, path, content string) {
+	longest, run := 0, 0
+	for _, c := range content {
+		if c == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	fmt.Fprintf(b, "%s%s\n%s", fence, path, content)
+	if !strings.HasSuffix(content, "\n") {
+		b.WriteString("\n")
+	}
+	b.WriteString(fence)
+}
+
+// systemOptions returns o with the files appended to the system prompt if
+// they are placed there.
+func (f *contextFiles) systemOptions(o *Options) *Options {
+	if f == nil || f.placement != ContextFilePlacementSystemPrompt {
+		return o
+	}
+	with := *o
+	with.AppendSystemPrompt = joinPrompt(o.AppendSystemPrompt, f.text)
+	return &with
+}
+
+// take returns the files if they are still to be put before a prompt.
+func (f *contextFiles) take() string {
+	if f == nil {
+		return ""
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.pending {
+		return ""
+	}
+	f.pending = false
+	return f.text
+}
+
+// prompt puts the files before prompt if it is the first.
+func (f *contextFiles) prompt(prompt string) string {
+	if text := f.take(); text != "" {
+		return text + "\n\n" + prompt
+	}
+	return prompt
+}
+
+// message puts the files before the content of a user message if it is
+// the first.
+func (f *contextFiles) message(message map[string]any) {
+	if f == nil || message["type"] != "user" {
+		return
+	}
+	inner, ok := message["message"].(map[string]any)
+	if !ok {
+		return
+	}
+	switch content := inner["content"].(type) {
+	case string:
+		inner["content"] = f.prompt(content)
+	case []any:
+		if text := f.take(); text != "" {
+			inner["content"] = append([]any{map[string]any{"type": "text", "text": text}}, content...)
+		}
+	case []map[string]any:
+		if text := f.take(); text != "" {
+			inner["content"] = append([]map[string]any{{"type": "text", "text": text}}, content...)
+		}
+	}
+}
+
+// input puts the files before the first user message read from in.
+func (f *contextFiles) input(ctx context.Context, in <-chan map[string]any) <-chan map[string]any {
+	if f == nil || f.placement == ContextFilePlacementSystemPrompt {
+		return in
+	}
+	out := make(chan map[string]any)
+	go func() {
+		defer close(out)
+		for message := range in {
+			f.message(message)
+			select {
+			case out <- message:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
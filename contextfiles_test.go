@@ -0,0 +1,223 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeContextFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoadContextFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeContextFile(t, dir, "main.go", "package main\n")
+	writeContextFile(t, dir, "README.md", "Use ```go fences``` here")
+
+	files, err := loadContextFiles(NewOptions(WithCwd(dir), WithContextFiles("main.go", "README.md")))
+	if err != nil {
+		t.Fatalf("loadContextFiles failed: %v", err)
+	}
+	want := contextFilesHeader + "\n\n" +
+		"```main.go\npackage main\n```\n\n" +
+		"````README.md\nUse ```go fences``` here\n````"
+	if files.text != want {
+		t.Errorf("Expected rendered files\n%s\ngot\n%s", want, files.text)
+	}
+
+	if files, err := loadContextFiles(NewOptions()); files != nil || err != nil {
+		t.Errorf("Expected nothing without context files, got %v, %v", files, err)
+	}
+}
+
+func TestLoadContextFiles_Errors(t *testing.T) {
+	dir := t.TempDir()
+	writeContextFile(t, dir, "image.png", "\x89PNG\x00\x00")
+	writeContextFile(t, dir, "notes.txt", "notes")
+
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"missing", []Option{WithContextFiles(filepath.Join(dir, "missing.txt"))}, "cannot read context file"},
+		{"binary", []Option{WithContextFiles(filepath.Join(dir, "image.png"))}, "is not text"},
+		{"placement", []Option{WithContextFiles(filepath.Join(dir, "notes.txt")), WithContextFilePlacement("footer")}, "unsupported context file placement"},
+		{"truncation", []Option{WithContextFiles(filepath.Join(dir, "notes.txt")), WithContextFileLimit(10, "random")}, "unsupported context file truncation"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadContextFiles(NewOptions(tt.opts...))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestFairShares(t *testing.T) {
+	tests := []struct {
+		sizes  []int
+		budget int
+		want   []int
+	}{
+		{[]int{10, 20}, 100, []int{10, 20}},
+		{[]int{100, 100}, 100, []int{50, 50}},
+		{[]int{1000, 10, 1000}, 110, []int{50, 10, 50}},
+		{[]int{30, 1000}, 100, []int{30, 70}},
+	}
+	for _, tt := range tests {
+		if got := fairShares(tt.sizes, tt.budget); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("fairShares(%v, %d) = %v, want %v", tt.sizes, tt.budget, got, tt.want)
+		}
+	}
+}
+
+func TestRenderContextFiles_Truncation(t *testing.T) {
+	var lines []string
+	for i := range 100 {
+		lines = append(lines, fmt.Sprintf("line %02d", i))
+	}
+	large := []byte(strings.Join(lines, "\n") + "\n")
+	small := []byte("small\n")
+	paths := []string{"large.txt", "small.txt"}
+	contents := [][]byte{large, small}
+
+	end := renderContextFiles(paths, contents, 86, ContextFileTruncateEnd)
+	if !strings.Contains(end, "```large.txt\nline 00\n") || strings.Contains(end, "line 99") {
+		t.Errorf("Expected the start of the large file, got\n%s", end)
+	}
+	if !strings.Contains(end, "line 09\n[... 721 bytes truncated ...]\n```") {
+		t.Errorf("Expected the cut at a line break to be marked, got\n%s", end)
+	}
+	if !strings.Contains(end, "```small.txt\nsmall\n```") {
+		t.Errorf("Expected the small file whole, got\n%s", end)
+	}
+
+	middle := renderContextFiles(paths, contents, 86, ContextFileTruncateMiddle)
+	if !strings.Contains(middle, "line 00") || !strings.Contains(middle, "line 99") || strings.Contains(middle, "line 50") {
+		t.Errorf("Expected the start and end of the large file, got\n%s", middle)
+	}
+	if !strings.Contains(middle, "bytes truncated ...]\nline 9") {
+		t.Errorf("Expected the cut to be marked in the middle, got\n%s", middle)
+	}
+
+	omit := renderContextFiles(paths, contents, 86, ContextFileOmit)
+	if strings.Contains(omit, "line 00") || !strings.Contains(omit, "large.txt was omitted: its 800 bytes do not fit") {
+		t.Errorf("Expected the large file to be omitted, got\n%s", omit)
+	}
+	if !strings.Contains(omit, "```small.txt\nsmall\n```") {
+		t.Errorf("Expected the small file whole, got\n%s", omit)
+	}
+}
+
+func TestCutHead_RuneBoundary(t *testing.T) {
+	data := []byte("héllo")
+	if got := string(cutHead(data, 2)); got != "h" {
+		t.Errorf("Expected the cut to keep whole runes, got %q", got)
+	}
+	if got := string(cutTail(data, 4)); got != "llo" {
+		t.Errorf("Expected the cut to keep whole runes, got %q", got)
+	}
+}
+
+func TestContextFiles_Placement(t *testing.T) {
+	files := &contextFiles{placement: ContextFilePlacementPrompt, text: "FILES", pending: true}
+	if got := files.systemOptions(NewOptions()); got.AppendSystemPrompt != "" {
+		t.Errorf("Expected no system prompt for prompt placement, got %q", got.AppendSystemPrompt)
+	}
+
+	blocks := map[string]any{
+		"type":    "user",
+		"message": map[string]any{"role": "user", "content": []any{map[string]any{"type": "text", "text": "Review"}}},
+	}
+	files.message(blocks)
+	content := blocks["message"].(map[string]any)["content"].([]any)
+	if len(content) != 2 || content[0].(map[string]any)["text"] != "FILES" {
+		t.Errorf("Expected the files as the first content block, got %v", content)
+	}
+
+	// Only the first prompt gets the files
+	if got := files.prompt("Next"); got != "Next" {
+		t.Errorf("Expected later prompts unchanged, got %q", got)
+	}
+
+	files = &contextFiles{placement: ContextFilePlacementSystemPrompt, text: "FILES"}
+	opts := NewOptions(WithAppendSystemPrompt("Be brief."))
+	if got := files.systemOptions(opts); got.AppendSystemPrompt != "Be brief.\nFILES" {
+		t.Errorf("Expected the files appended to the system prompt, got %q", got.AppendSystemPrompt)
+	}
+	if opts.AppendSystemPrompt != "Be brief." {
+		t.Error("Expected the options to be left unchanged")
+	}
+	if got := files.prompt("Review"); got != "Review" {
+		t.Errorf("Expected the prompt unchanged for system prompt placement, got %q", got)
+	}
+
+	var none *contextFiles
+	if got := none.prompt("Review"); got != "Review" {
+		t.Errorf("Expected a nil contextFiles to leave prompts unchanged, got %q", got)
+	}
+}
+
+func TestClient_ContextFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeContextFile(t, dir, "handler.go", "package handler\n")
+	log := filepath.Join(dir, "stdin.log")
+
+	cli := writeStubCLI(t, fmt.Sprintf(`
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+while read -r line; do
+  case "$line" in
+  *'"type":"user"'*)
+    printf '%%s\n' "$line" >> %s
+    echo '{"type":"result","subtype":"success","session_id":"s"}'
+    ;;
+  esac
+done
+`, log))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli), WithCwd(dir), WithContextFiles("handler.go"))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	for _, prompt := range []string{"Review this file", "Thanks"} {
+		if err := client.Query(ctx, prompt); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		for range client.ReceiveResponse(ctx) {
+		}
+	}
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatalf("Failed to read stub log: %v", err)
+	}
+	sent := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(sent) != 2 {
+		t.Fatalf("Expected 2 prompts, got %q", sent)
+	}
+	if !strings.Contains(sent[0], "```handler.go\\npackage handler\\n```\\n\\nReview this file") {
+		t.Errorf("Expected the file before the first prompt, got %s", sent[0])
+	}
+	if strings.Contains(sent[1], "handler.go") {
+		t.Errorf("Expected the second prompt without the file, got %s", sent[1])
+	}
+}
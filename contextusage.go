@@ -0,0 +1,166 @@
+package claude
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// DefaultContextWindow is the context window, in tokens, assumed for a
+// model unless its 1M token context is enabled or WithContextWindow sets
+// another.
+const DefaultContextWindow = 200_000
+
+// extendedContextWindow is the context window of models run with the 1M
+// token context, through SdkBetaContext1M or a "[1m]" model suffix.
+const extendedContextWindow = 1_000_000
+
+// ContextUsage estimates how much of the context window of its model a
+// conversation fills, and counts the tokens of the session so far.
+type ContextUsage struct {
+	// Model is the model of the latest response, or the configured model
+	// before the first.
+	Model string
+
+	// Window is the context window of Model, in tokens.
+	Window int
+
+	// ContextTokens estimates the tokens in context: the input and output
+	// tokens of the latest response of the main conversation. It drops
+	// once the CLI compacts the conversation.
+	ContextTokens int
+
+	// InputTokens and OutputTokens add up the tokens of each turn, cached
+	// input included, across the session.
+	InputTokens  int
+	OutputTokens int
+}
+
+// Remaining returns the tokens of the window not yet in context.
+func (u ContextUsage) Remaining() int {
+	return max(u.Window-u.ContextTokens, 0)
+}
+
+// Fraction returns the share of the window in context, from 0 to 1, or
+// 0 if the window is unknown.
+func (u ContextUsage) Fraction() float64 {
+	if u.Window <= 0 {
+		return 0
+	}
+	return min(float64(u.ContextTokens)/float64(u.Window), 1)
+}
+
+// validateContextUsage checks the context window and warning thresholds
+// of o.
+func validateContextUsage(o *Options) error {
+	if o.ContextWindow < 0 {
+		return NewClaudeSDKError(fmt.Sprintf("context window must not be negative, got %d", o.ContextWindow))
+	}
+	for _, threshold := range o.ContextWarnings {
+		if threshold <= 0 || threshold > 1 {
+			return NewClaudeSDKError(fmt.Sprintf("context warning thresholds must be greater than 0 and at most 1, got %g", threshold))
+		}
+	}
+	return nil
+}
+
+// contextTracker follows the usage reported by messages, and warns when
+// the context fills past the thresholds of WithContextWarnings.
+type contextTracker struct {
+	window     int
+	extended   bool
+	thresholds []float64
+	locale     string
+
+	mu    sync.Mutex
+	usage ContextUsage
+	// turnUsage reports whether a response of the current turn reported
+	// its usage; crossed is the number of thresholds the context is past.
+	turnUsage bool
+	crossed   int
+}
+
+func newContextTracker(o *Options) *contextTracker {
+	thresholds := slices.Clone(o.ContextWarnings)
+	slices.Sort(thresholds)
+	return &contextTracker{
+		window:     o.ContextWindow,
+		extended:   slices.Contains(o.Betas, SdkBetaContext1M),
+		thresholds: thresholds,
+		locale:     o.Locale,
+		usage:      ContextUsage{Model: o.Model},
+	}
+}
+
+// windowFor returns the context window of model.
+func (t *contextTracker) windowFor(model string) int {
+	switch {
+	case t.window > 0:
+		return t.window
+	case t.extended || strings.HasSuffix(strings.ToLower(model), "[1m]"):
+		return extendedContextWindow
+	default:
+		return DefaultContextWindow
+	}
+}
+
+// observe records the usage msg reports, returning a warning if it takes
+// the context past a threshold.
+func (t *contextTracker) observe(msg Message) *DiagnosticEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch m := msg.(type) {
+	case *AssistantMessage:
+		if m.ParentToolUseID != "" || len(m.Usage) == 0 {
+			return nil
+		}
+		tokens := m.Tokens()
+		t.usage.Model = m.Model
+		t.usage.ContextTokens = tokens.TotalInputTokens() + tokens.OutputTokens
+		t.turnUsage = true
+	case *ResultMessage:
+		tokens := m.Tokens()
+		t.usage.InputTokens += tokens.TotalInputTokens()
+		t.usage.OutputTokens += tokens.OutputTokens
+		if m.Model != "" {
+			t.usage.Model = m.Model
+		}
+		// Without the usage of its responses, the turn's usage stands in
+		if !t.turnUsage && len(m.Usage) > 0 {
+			t.usage.ContextTokens = tokens.TotalInputTokens() + tokens.OutputTokens
+		}
+		t.turnUsage = false
+	default:
+		return nil
+	}
+	t.usage.Window = t.windowFor(t.usage.Model)
+
+	fraction := t.usage.Fraction()
+	crossed := 0
+	for crossed < len(t.thresholds) && fraction >= t.thresholds[crossed] {
+		crossed++
+	}
+	// Thresholds left behind, such as by compaction, warn again
+	previous := t.crossed
+	t.crossed = crossed
+	if crossed <= previous {
+		return nil
+	}
+	usage := t.usage
+	return &DiagnosticEvent{
+		Kind:    DiagnosticKindContext,
+		Message: localize(t.locale, MessageContextUsage, fraction*100, usage.ContextTokens, usage.Window),
+		Context: &usage,
+	}
+}
+
+// snapshot returns the usage recorded so far.
+func (t *contextTracker) snapshot() ContextUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	usage := t.usage
+	usage.Window = t.windowFor(usage.Model)
+	return usage
+}
@@ -0,0 +1,105 @@
+package claude
+
+import (
+	"testing"
+)
+
+// usage returns an API usage object with the given token counts.
+func usage(input, cacheRead, output int) map[string]any {
+	return map[string]any{
+		"input_tokens":            float64(input),
+		"cache_read_input_tokens": float64(cacheRead),
+		"output_tokens":           float64(output),
+	}
+}
+
+func TestContextTracker_Usage(t *testing.T) {
+	tracker := newContextTracker(NewOptions(WithModel("claude-sonnet-4-5")))
+	if u := tracker.snapshot(); u.Model != "claude-sonnet-4-5" || u.Window != DefaultContextWindow || u.ContextTokens != 0 {
+		t.Errorf("Expected the configured model and no tokens before a response, got %+v", u)
+	}
+
+	tracker.observe(&AssistantMessage{Model: "claude-sonnet-4-5", Usage: usage(100, 20_000, 50)})
+	tracker.observe(&AssistantMessage{Model: "claude-sonnet-4-5", Usage: usage(10, 30_000, 40)})
+	// Subagents have a context of their own
+	tracker.observe(&AssistantMessage{Model: "claude-haiku-4-5", ParentToolUseID: "t1", Usage: usage(90_000, 0, 10)})
+	tracker.observe(&ResultMessage{Usage: usage(110, 50_000, 90)})
+
+	u := tracker.snapshot()
+	if u.ContextTokens != 30_050 || u.Remaining() != DefaultContextWindow-30_050 {
+		t.Errorf("Expected the context of the latest response, got %+v", u)
+	}
+	if u.InputTokens != 50_110 || u.OutputTokens != 90 {
+		t.Errorf("Expected the tokens of the turn added up, got %+v", u)
+	}
+
+	// A turn whose responses report no usage is estimated from its result
+	tracker.observe(&AssistantMessage{Model: "claude-sonnet-4-5"})
+	tracker.observe(&ResultMessage{Usage: usage(40_000, 0, 1_000)})
+	if u := tracker.snapshot(); u.ContextTokens != 41_000 || u.InputTokens != 90_110 {
+		t.Errorf("Expected the context estimated from the result, got %+v", u)
+	}
+}
+
+func TestContextTracker_Window(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []Option
+		model string
+		want  int
+	}{
+		{"default", nil, "claude-opus-4-1", DefaultContextWindow},
+		{"1M suffix", nil, "claude-sonnet-4-5[1m]", 1_000_000},
+		{"1M beta", []Option{WithBetas([]SdkBeta{SdkBetaContext1M})}, "claude-sonnet-4-5", 1_000_000},
+		{"set", []Option{WithContextWindow(64_000)}, "claude-sonnet-4-5[1m]", 64_000},
+	}
+	for _, tt := range tests {
+		tracker := newContextTracker(NewOptions(tt.opts...))
+		tracker.observe(&AssistantMessage{Model: tt.model, Usage: usage(1, 0, 1)})
+		if w := tracker.snapshot().Window; w != tt.want {
+			t.Errorf("%s: expected a window of %d, got %d", tt.name, tt.want, w)
+		}
+	}
+}
+
+func TestContextTracker_Warnings(t *testing.T) {
+	tracker := newContextTracker(NewOptions(WithContextWindow(1000), WithContextWarnings(0.9, 0.5)))
+
+	if event := tracker.observe(&AssistantMessage{Usage: usage(400, 0, 50)}); event != nil {
+		t.Errorf("Expected no warning below the thresholds, got %+v", event)
+	}
+	event := tracker.observe(&AssistantMessage{Usage: usage(500, 0, 50)})
+	if event == nil || event.Kind != DiagnosticKindContext || event.Context.ContextTokens != 550 {
+		t.Fatalf("Expected a warning past 50%%, got %+v", event)
+	}
+	if want := localize("", MessageContextUsage, 55.0, 550, 1000); event.Message != want {
+		t.Errorf("Expected message %q, got %q", want, event.Message)
+	}
+	if event := tracker.observe(&AssistantMessage{Usage: usage(600, 0, 50)}); event != nil {
+		t.Errorf("Expected a threshold to warn once, got %+v", event)
+	}
+	if event := tracker.observe(&AssistantMessage{Usage: usage(900, 0, 50)}); event == nil || event.Context.Fraction() != 0.95 {
+		t.Errorf("Expected a warning past 90%%, got %+v", event)
+	}
+
+	// After compaction, the thresholds warn again
+	tracker.observe(&AssistantMessage{Usage: usage(100, 0, 50)})
+	if event := tracker.observe(&AssistantMessage{Usage: usage(950, 0, 100)}); event == nil || event.Context.Fraction() != 1 {
+		t.Errorf("Expected a warning after the context fell and filled again, got %+v", event)
+	}
+}
+
+func TestValidateContextUsage(t *testing.T) {
+	for _, opts := range [][]Option{
+		{WithContextWindow(-1)},
+		{WithContextWarnings(0)},
+		{WithContextWarnings(0.5, 1.5)},
+	} {
+		if err := validateOptions(NewOptions(opts...)); err == nil {
+			t.Errorf("Expected options %d to be invalid", len(opts))
+		}
+	}
+	if err := validateOptions(NewOptions(WithContextWindow(100_000), WithContextWarnings(0.8, 1))); err != nil {
+		t.Errorf("Expected valid options, got %v", err)
+	}
+}
@@ -0,0 +1,266 @@
+package claude
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/transport"
+)
+
+// ConversationBranch is a session in a ConversationTree.
+type ConversationBranch struct {
+	SessionID string
+	// ParentID is the session the branch was forked from, or empty for a
+	// root.
+	ParentID string
+	// ForkedAt is the UUID of the last message of the parent kept by the
+	// fork, as set with WithResumeSessionAt or Client.Mark. It is empty if
+	// the fork kept the whole parent as it was then.
+	ForkedAt string
+	// Children are the sessions forked from the branch, oldest first.
+	Children  []string
+	CreatedAt time.Time
+}
+
+// ConversationTree tracks the sessions forked from one another, so that
+// many branches of the same conversation can be explored and cleaned up.
+// Pass it to WithConversationTree to record the forks made with
+// WithForkSession, Client.Fork and Client.Rollback, or record them with
+// AddFork.
+//
+// A ConversationTree is safe for concurrent use.
+type ConversationTree struct {
+	mu       sync.Mutex
+	branches map[string]*ConversationBranch
+	roots    []string
+}
+
+// NewConversationTree returns an empty tree.
+func NewConversationTree() *ConversationTree {
+	return &ConversationTree{branches: make(map[string]*ConversationBranch)}
+}
+
+// AddRoot records sessionID as a conversation not forked from another.
+func (t *ConversationTree) AddRoot(sessionID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if sessionID == "" {
+		return NewClaudeSDKError("session ID must not be empty")
+	}
+	if _, ok := t.branches[sessionID]; ok {
+		return NewClaudeSDKError(fmt.Sprintf("session %s is already in the tree", sessionID))
+	}
+	t.addRoot(sessionID)
+	return nil
+}
+
+func (t *ConversationTree) addRoot(sessionID string) {
+	t.branches[sessionID] = &ConversationBranch{SessionID: sessionID, CreatedAt: time.Now()}
+	t.roots = append(t.roots, sessionID)
+}
+
+// AddFork records sessionID as forked from parentID after the message
+// with UUID forkedAt, or from the whole of parentID if forkedAt is empty.
+// A parent not in the tree is added as a root.
+func (t *ConversationTree) AddFork(parentID, sessionID, forkedAt string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if parentID == "" || sessionID == "" {
+		return NewClaudeSDKError("session ID must not be empty")
+	}
+	if parentID == sessionID {
+		return NewClaudeSDKError(fmt.Sprintf("session %s cannot be forked from itself", sessionID))
+	}
+	if _, ok := t.branches[sessionID]; ok {
+		return NewClaudeSDKError(fmt.Sprintf("session %s is already in the tree", sessionID))
+	}
+
+	parent, ok := t.branches[parentID]
+	if !ok {
+		t.addRoot(parentID)
+		parent = t.branches[parentID]
+	}
+	parent.Children = append(parent.Children, sessionID)
+	t.branches[sessionID] = &ConversationBranch{
+		SessionID: sessionID,
+		ParentID:  parentID,
+		ForkedAt:  forkedAt,
+		CreatedAt: time.Now(),
+	}
+	return nil
+}
+
+// Branch returns the branch of sessionID, if it is in the tree.
+func (t *ConversationTree) Branch(sessionID string) (ConversationBranch, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, ok := t.branches[sessionID]
+	if !ok {
+		return ConversationBranch{}, false
+	}
+	return b.copy(), true
+}
+
+func (b *ConversationBranch) copy() ConversationBranch {
+	c := *b
+	c.Children = slices.Clone(b.Children)
+	return c
+}
+
+// Roots returns the sessions not forked from another, oldest first.
+func (t *ConversationTree) Roots() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Clone(t.roots)
+}
+
+// Len returns the number of sessions in the tree.
+func (t *ConversationTree) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.branches)
+}
+
+// Path returns the branches from the root of sessionID's conversation down
+// to sessionID, or nil if it is not in the tree.
+func (t *ConversationTree) Path(sessionID string) []ConversationBranch {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var path []ConversationBranch
+	for id := sessionID; id != ""; {
+		b, ok := t.branches[id]
+		if !ok {
+			break
+		}
+		path = append(path, b.copy())
+		id = b.ParentID
+	}
+	slices.Reverse(path)
+	return path
+}
+
+// Walk calls fn for sessionID and the sessions forked from it, depth
+// first, each before its children and children oldest first. When fn
+// returns false, the children of that branch are skipped. Walk holds no
+// lock while calling fn, so fn may change the tree; the branches visited
+// are those in the tree when Walk was called.
+func (t *ConversationTree) Walk(sessionID string, fn func(ConversationBranch) bool) {
+	t.mu.Lock()
+	var branches []ConversationBranch
+	var skip []int // number of descendants of each branch
+	var collect func(id string) int
+	collect = func(id string) int {
+		b, ok := t.branches[id]
+		if !ok {
+			return 0
+		}
+		i := len(branches)
+		branches = append(branches, b.copy())
+		skip = append(skip, 0)
+		n := 0
+		for _, child := range b.Children {
+			n += collect(child)
+		}
+		skip[i] = n
+		return n + 1
+	}
+	collect(sessionID)
+	t.mu.Unlock()
+
+	for i := 0; i < len(branches); i++ {
+		if !fn(branches[i]) {
+			i += skip[i]
+		}
+	}
+}
+
+// Prune removes sessionID and the sessions forked from it from the tree,
+// returning their IDs, sessionID first. The sessions themselves are left
+// to the CLI.
+func (t *ConversationTree) Prune(sessionID string) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, ok := t.branches[sessionID]
+	if !ok {
+		return nil
+	}
+	if parent, ok := t.branches[b.ParentID]; ok {
+		parent.Children = slices.DeleteFunc(parent.Children, func(id string) bool { return id == sessionID })
+	} else {
+		t.roots = slices.DeleteFunc(t.roots, func(id string) bool { return id == sessionID })
+	}
+
+	var removed []string
+	var remove func(id string)
+	remove = func(id string) {
+		b := t.branches[id]
+		delete(t.branches, id)
+		removed = append(removed, id)
+		for _, child := range b.Children {
+			remove(child)
+		}
+	}
+	remove(sessionID)
+	return removed
+}
+
+// forkRecorder records the session of a CLI invocation in a
+// ConversationTree once its ID is known: as a fork of the session it
+// resumed if it forked it, and as a root otherwise. A nil forkRecorder
+// records nothing.
+type forkRecorder struct {
+	tree     *ConversationTree
+	parentID string
+	forkedAt string
+	done     bool
+}
+
+// newForkRecorder returns a recorder for an invocation with opts, or nil
+// if tree is nil.
+func newForkRecorder(tree *ConversationTree, opts *transport.Options) *forkRecorder {
+	if tree == nil {
+		return nil
+	}
+	r := &forkRecorder{tree: tree}
+	if opts.ForkSession && opts.Resume != "" {
+		r.parentID = opts.Resume
+		r.forkedAt = opts.ResumeSessionAt
+	}
+	return r
+}
+
+// observe records the session of msg, if it is the first message to
+// report one.
+func (r *forkRecorder) observe(msg Message) {
+	if r == nil || r.done {
+		return
+	}
+	var sessionID string
+	switch m := msg.(type) {
+	case *SystemMessage:
+		sessionID, _ = m.Data["session_id"].(string)
+	case *ResultMessage:
+		sessionID = m.SessionID
+	}
+	if sessionID == "" {
+		return
+	}
+	r.done = true
+
+	if r.parentID != "" && r.parentID != sessionID {
+		_ = r.tree.AddFork(r.parentID, sessionID, r.forkedAt)
+		return
+	}
+	r.tree.mu.Lock()
+	if _, ok := r.tree.branches[sessionID]; !ok {
+		r.tree.addRoot(sessionID)
+	}
+	r.tree.mu.Unlock()
+}
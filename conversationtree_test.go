@@ -0,0 +1,192 @@
+package claude
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/transport"
+)
+
+// branchIDs returns the session IDs of branches.
+func branchIDs(branches []ConversationBranch) []string {
+	var ids []string
+	for _, b := range branches {
+		ids = append(ids, b.SessionID)
+	}
+	return ids
+}
+
+// exampleTree returns a tree with root r, its forks a and b, and a's fork c.
+func exampleTree(t *testing.T) *ConversationTree {
+	t.Helper()
+	tree := NewConversationTree()
+	if err := tree.AddRoot("r"); err != nil {
+		t.Fatalf("AddRoot failed: %v", err)
+	}
+	for _, fork := range [][3]string{{"r", "a", "m1"}, {"r", "b", ""}, {"a", "c", "m2"}} {
+		if err := tree.AddFork(fork[0], fork[1], fork[2]); err != nil {
+			t.Fatalf("AddFork failed: %v", err)
+		}
+	}
+	return tree
+}
+
+func TestConversationTree(t *testing.T) {
+	tree := exampleTree(t)
+
+	if tree.Len() != 4 || !reflect.DeepEqual(tree.Roots(), []string{"r"}) {
+		t.Fatalf("Expected 4 sessions under r, got %d under %v", tree.Len(), tree.Roots())
+	}
+	a, ok := tree.Branch("a")
+	if !ok || a.ParentID != "r" || a.ForkedAt != "m1" || !reflect.DeepEqual(a.Children, []string{"c"}) {
+		t.Errorf("Unexpected branch a: %+v", a)
+	}
+	if got := branchIDs(tree.Path("c")); !reflect.DeepEqual(got, []string{"r", "a", "c"}) {
+		t.Errorf("Expected path r, a, c, got %v", got)
+	}
+	if tree.Path("missing") != nil {
+		t.Error("Expected no path for a session not in the tree")
+	}
+
+	// Branches returned are copies
+	a.Children[0] = "changed"
+	if a, _ := tree.Branch("a"); a.Children[0] != "c" {
+		t.Error("Expected the tree to be unaffected by changes to a returned branch")
+	}
+}
+
+func TestConversationTree_Errors(t *testing.T) {
+	tree := exampleTree(t)
+
+	if err := tree.AddRoot("a"); err == nil {
+		t.Error("Expected an error adding a session twice")
+	}
+	if err := tree.AddFork("r", "c", ""); err == nil {
+		t.Error("Expected an error forking to a session already in the tree")
+	}
+	if err := tree.AddFork("r", "r", ""); err == nil {
+		t.Error("Expected an error forking a session from itself")
+	}
+	if err := tree.AddFork("", "d", ""); err == nil {
+		t.Error("Expected an error for an empty session ID")
+	}
+
+	// An unknown parent becomes a root
+	if err := tree.AddFork("other", "d", ""); err != nil {
+		t.Fatalf("AddFork failed: %v", err)
+	}
+	if !reflect.DeepEqual(tree.Roots(), []string{"r", "other"}) {
+		t.Errorf("Expected the parent to be added as a root, got %v", tree.Roots())
+	}
+}
+
+func TestConversationTree_Walk(t *testing.T) {
+	tree := exampleTree(t)
+
+	var visited []string
+	tree.Walk("r", func(b ConversationBranch) bool {
+		visited = append(visited, b.SessionID)
+		return true
+	})
+	if !reflect.DeepEqual(visited, []string{"r", "a", "c", "b"}) {
+		t.Errorf("Expected depth-first order r, a, c, b, got %v", visited)
+	}
+
+	// Returning false skips the branch's descendants
+	visited = nil
+	tree.Walk("r", func(b ConversationBranch) bool {
+		visited = append(visited, b.SessionID)
+		return b.SessionID != "a"
+	})
+	if !reflect.DeepEqual(visited, []string{"r", "a", "b"}) {
+		t.Errorf("Expected c to be skipped, got %v", visited)
+	}
+}
+
+func TestConversationTree_Prune(t *testing.T) {
+	tree := exampleTree(t)
+
+	if removed := tree.Prune("a"); !reflect.DeepEqual(removed, []string{"a", "c"}) {
+		t.Errorf("Expected a and c to be removed, got %v", removed)
+	}
+	if r, _ := tree.Branch("r"); !reflect.DeepEqual(r.Children, []string{"b"}) {
+		t.Errorf("Expected only b left under r, got %v", r.Children)
+	}
+	if tree.Prune("a") != nil {
+		t.Error("Expected nothing to prune for a session not in the tree")
+	}
+
+	tree.Prune("r")
+	if tree.Len() != 0 || len(tree.Roots()) != 0 {
+		t.Errorf("Expected an empty tree, got %d sessions under %v", tree.Len(), tree.Roots())
+	}
+}
+
+func TestForkRecorder(t *testing.T) {
+	tree := NewConversationTree()
+	init := func(sessionID string) Message {
+		return &SystemMessage{Subtype: "init", Data: map[string]any{"session_id": sessionID}}
+	}
+
+	newForkRecorder(tree, &transport.Options{}).observe(init("r"))
+	r := newForkRecorder(tree, &transport.Options{Resume: "r", ResumeSessionAt: "m1", ForkSession: true})
+	r.observe(&AssistantMessage{})
+	r.observe(init("f"))
+	r.observe(&ResultMessage{SessionID: "other"})
+	// Resuming without forking continues the same session
+	newForkRecorder(tree, &transport.Options{Resume: "f"}).observe(init("f"))
+
+	f, ok := tree.Branch("f")
+	if !ok || f.ParentID != "r" || f.ForkedAt != "m1" {
+		t.Errorf("Expected f forked from r at m1, got %+v", f)
+	}
+	if tree.Len() != 2 {
+		t.Errorf("Expected 2 sessions, got %d", tree.Len())
+	}
+
+	var none *forkRecorder
+	none.observe(init("x"))
+}
+
+func TestClient_ConversationTree(t *testing.T) {
+	cli := writeStubCLI(t, `
+session=sess-1
+case "$*" in *--fork-session*) session=sess-2 ;; esac
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+echo '{"type":"system","subtype":"init","session_id":"'$session'"}'
+echo '{"type":"assistant","uuid":"msg-1","message":{"model":"m","content":[{"type":"text","text":"hi"}]}}'
+echo '{"type":"result","subtype":"success","session_id":"'$session'"}'
+cat > /dev/null
+`)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tree := NewConversationTree()
+	client := NewClient(WithCLIPath(cli), WithConversationTree(tree))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	for range client.ReceiveResponse(ctx) {
+	}
+	if err := client.Mark("checkpoint"); err != nil {
+		t.Fatalf("Mark failed: %v", err)
+	}
+	if err := client.Rollback(ctx, "checkpoint"); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	for range client.ReceiveResponse(ctx) {
+	}
+
+	if got := branchIDs(tree.Path("sess-2")); !reflect.DeepEqual(got, []string{"sess-1", "sess-2"}) {
+		t.Fatalf("Expected sess-2 forked from sess-1, got %v", got)
+	}
+	if b, _ := tree.Branch("sess-2"); b.ForkedAt != "msg-1" {
+		t.Errorf("Expected the fork at msg-1, got %q", b.ForkedAt)
+	}
+}
@@ -0,0 +1,169 @@
+package claude
+
+import (
+	"context"
+	"sync"
+)
+
+// ToolCall is a tool use paired with its outcome by a Correlator.
+type ToolCall struct {
+	ToolUseID string
+	// Name and Input are those of the ToolUseBlock, if it was observed.
+	Name  string
+	Input map[string]any
+
+	// Result is the tool result sent back to Claude, if it was observed.
+	Result *ToolResultBlock
+	// Response is the tool response passed to the PostToolUse hook, if
+	// the Correlator's hook is registered.
+	Response any
+
+	// Done reports whether the call has completed.
+	Done bool
+}
+
+// IsError reports whether the tool result is an error.
+func (c ToolCall) IsError() bool {
+	return c.Result != nil && c.Result.IsError != nil && *c.Result.IsError
+}
+
+// Correlator pairs tool uses with their results by tool use ID, so that
+// monitoring code can wait for a tool call to complete without keeping
+// its own maps. Feed it messages with Observe or Stage, and optionally
+// register PostToolUseHook to also complete calls as soon as the tool
+// has run:
+//
+//	correlator := claude.NewCorrelator()
+//	client := claude.NewClient(claude.WithPostToolUseHook("", correlator.PostToolUseHook()))
+//	...
+//	for msg := range claude.Pipe(client.Messages(), correlator.Stage()) {
+//	    ...
+//	}
+//
+// A call completes on whichever of its result or its hook is seen first;
+// Get returns it with what has been seen since. Calls are kept until
+// Forget is called for them. A Correlator is safe for concurrent use.
+type Correlator struct {
+	mu    sync.Mutex
+	calls map[string]*correlatedCall
+}
+
+// correlatedCall is a call and the channel closed when it completes.
+type correlatedCall struct {
+	call ToolCall
+	done chan struct{}
+}
+
+// NewCorrelator creates an empty Correlator.
+func NewCorrelator() *Correlator {
+	return &Correlator{calls: make(map[string]*correlatedCall)}
+}
+
+// Observe records the tool uses of assistant messages and the tool results
+// of user messages.
+func (c *Correlator) Observe(msg Message) {
+	switch m := msg.(type) {
+	case *AssistantMessage:
+		for _, block := range m.Content {
+			if use, ok := block.(ToolUseBlock); ok {
+				c.update(use.ID, false, func(call *ToolCall) {
+					call.Name = use.Name
+					call.Input = use.Input
+				})
+			}
+		}
+	case *UserMessage:
+		for _, block := range m.GetContentBlocks() {
+			if result, ok := block.(ToolResultBlock); ok {
+				c.update(result.ToolUseID, true, func(call *ToolCall) {
+					call.Result = &result
+				})
+			}
+		}
+	}
+}
+
+// Stage returns a pipeline stage observing the messages passing through
+// it, unchanged.
+func (c *Correlator) Stage() Stage {
+	return Map(func(msg Message) Message {
+		c.Observe(msg)
+		return msg
+	})
+}
+
+// PostToolUseHook returns a hook completing calls when their tool has run,
+// recording its response. Register it for PostToolUse events.
+func (c *Correlator) PostToolUseHook() HookCallback {
+	return func(ctx context.Context, input HookInput, toolUseID string, hookCtx HookContext) (HookOutput, error) {
+		if post, ok := input.(PostToolUseHookInput); ok && toolUseID != "" {
+			c.update(toolUseID, true, func(call *ToolCall) {
+				if call.Name == "" {
+					call.Name = post.ToolName
+					call.Input = post.ToolInput
+				}
+				call.Response = post.ToolResponse
+			})
+		}
+		return HookOutput{}, nil
+	}
+}
+
+// Await waits for the call with toolUseID to complete and returns it. The
+// call may be awaited before its tool use is observed.
+func (c *Correlator) Await(ctx context.Context, toolUseID string) (ToolCall, error) {
+	c.mu.Lock()
+	entry := c.entry(toolUseID)
+	c.mu.Unlock()
+
+	select {
+	case <-entry.done:
+		call, _ := c.Get(toolUseID)
+		return call, nil
+	case <-ctx.Done():
+		return ToolCall{}, ctx.Err()
+	}
+}
+
+// Get returns the call with toolUseID as observed so far.
+func (c *Correlator) Get(toolUseID string) (ToolCall, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.calls[toolUseID]
+	if !ok {
+		return ToolCall{}, false
+	}
+	return entry.call, true
+}
+
+// Forget drops the call with toolUseID. Pending Awaits of it keep waiting
+// until their context is done.
+func (c *Correlator) Forget(toolUseID string) {
+	c.mu.Lock()
+	delete(c.calls, toolUseID)
+	c.mu.Unlock()
+}
+
+// update applies fn to the call with toolUseID, completing it if complete
+// is true.
+func (c *Correlator) update(toolUseID string, complete bool, fn func(*ToolCall)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.entry(toolUseID)
+	fn(&entry.call)
+	if complete && !entry.call.Done {
+		entry.call.Done = true
+		close(entry.done)
+	}
+}
+
+// entry returns the call with toolUseID, creating it if needed. c.mu must
+// be held.
+func (c *Correlator) entry(toolUseID string) *correlatedCall {
+	entry, ok := c.calls[toolUseID]
+	if !ok {
+		entry = &correlatedCall{call: ToolCall{ToolUseID: toolUseID}, done: make(chan struct{})}
+		c.calls[toolUseID] = entry
+	}
+	return entry
+}
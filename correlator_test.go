@@ -0,0 +1,92 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCorrelator_Observe(t *testing.T) {
+	c := NewCorrelator()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Awaiting before the tool use is seen
+	done := make(chan ToolCall)
+	go func() {
+		call, err := c.Await(ctx, "tu_1")
+		if err != nil {
+			t.Errorf("Await failed: %v", err)
+		}
+		done <- call
+	}()
+
+	isError := true
+	messages := make(chan Message, 2)
+	messages <- &AssistantMessage{Content: []ContentBlock{
+		TextBlock{Text: "Listing files"},
+		ToolUseBlock{ID: "tu_1", Name: "Bash", Input: map[string]any{"command": "ls"}},
+	}}
+	messages <- &UserMessage{Content: []ContentBlock{
+		ToolResultBlock{ToolUseID: "tu_1", Content: "no such file", IsError: &isError},
+	}}
+	close(messages)
+	for range Pipe(messages, c.Stage()) {
+	}
+
+	call := <-done
+	if call.Name != "Bash" || call.Input["command"] != "ls" || !call.Done {
+		t.Errorf("Unexpected call: %+v", call)
+	}
+	if call.Result == nil || call.Result.Content != "no such file" || !call.IsError() {
+		t.Errorf("Expected the error result, got %+v", call.Result)
+	}
+
+	// Completed calls are returned right away until forgotten
+	if call, err := c.Await(ctx, "tu_1"); err != nil || !call.Done {
+		t.Errorf("Expected the completed call, got %+v %v", call, err)
+	}
+	c.Forget("tu_1")
+	if _, ok := c.Get("tu_1"); ok {
+		t.Error("Expected the call to be forgotten")
+	}
+}
+
+func TestCorrelator_PostToolUseHook(t *testing.T) {
+	c := NewCorrelator()
+	c.Observe(&AssistantMessage{Content: []ContentBlock{ToolUseBlock{ID: "tu_2", Name: "Read"}}})
+
+	if call, _ := c.Get("tu_2"); call.Done {
+		t.Fatal("Expected the call to be pending")
+	}
+
+	hook := c.PostToolUseHook()
+	if _, err := hook(context.Background(), PostToolUseHookInput{ToolName: "Read", ToolResponse: "contents"}, "tu_2", HookContext{}); err != nil {
+		t.Fatalf("Hook failed: %v", err)
+	}
+
+	call, err := c.Await(context.Background(), "tu_2")
+	if err != nil {
+		t.Fatalf("Await failed: %v", err)
+	}
+	if call.Response != "contents" || call.Result != nil || call.IsError() {
+		t.Errorf("Expected the hook response, got %+v", call)
+	}
+
+	// The result arriving later is added to the call
+	c.Observe(&UserMessage{Content: []ContentBlock{ToolResultBlock{ToolUseID: "tu_2", Content: "contents"}}})
+	if call, _ := c.Get("tu_2"); call.Result == nil || call.Response != "contents" {
+		t.Errorf("Expected both the response and the result, got %+v", call)
+	}
+}
+
+func TestCorrelator_AwaitCancelled(t *testing.T) {
+	c := NewCorrelator()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.Await(ctx, "tu_missing"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to end the wait, got %v", err)
+	}
+}
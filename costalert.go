@@ -0,0 +1,123 @@
+package claude
+
+import "sync"
+
+// CostScope tells which cost crossed the threshold of a cost alert.
+type CostScope string
+
+const (
+	// CostScopeTurn is the cost of a single turn, including its
+	// follow-ups, retries and repairs.
+	CostScopeTurn CostScope = "turn"
+	// CostScopeSession is the cost of the session so far.
+	CostScopeSession CostScope = "session"
+)
+
+// SessionCost is passed to the callback of WithCostAlert when a cost
+// crosses its threshold.
+type SessionCost struct {
+	SessionID string
+	// Scope tells whether TurnUSD or TotalUSD crossed Threshold.
+	Scope     CostScope
+	Threshold float64
+	// TurnUSD is the cost of the current turn and TotalUSD that of the
+	// session, both as of Result.
+	TurnUSD  float64
+	TotalUSD float64
+	// Result is the result that took the cost over the threshold.
+	Result *ResultMessage
+}
+
+// CostAlert is a threshold set with WithCostAlert.
+type CostAlert struct {
+	Threshold float64
+	Fn        func(SessionCost)
+}
+
+// costTracker adds up the cost reported by results and calls the alerts
+// whose thresholds are crossed.
+type costTracker struct {
+	alerts []CostAlert
+
+	mu sync.Mutex
+	// processUSD is the total reported by the running CLI process, which
+	// counts from zero when it starts, and earlierUSD the cost of the
+	// processes before it. turnStartUSD is the session cost when the
+	// current turn started.
+	processUSD   float64
+	earlierUSD   float64
+	turnStartUSD float64
+}
+
+// newCostTracker returns the cost tracker for options, or nil if no alert
+// is set.
+func newCostTracker(o *Options) *costTracker {
+	if len(o.CostAlerts) == 0 {
+		return nil
+	}
+	return &costTracker{alerts: o.CostAlerts}
+}
+
+// observe adds the cost of result and calls the alerts it sets off.
+func (c *costTracker) observe(result *ResultMessage) {
+	if c == nil || result.TotalCostUSD == nil {
+		return
+	}
+
+	c.mu.Lock()
+	if *result.TotalCostUSD < c.processUSD {
+		// The CLI was restarted without newProcess being called
+		c.earlierUSD += c.processUSD
+	}
+	prevTotal := c.earlierUSD + c.processUSD
+	c.processUSD = *result.TotalCostUSD
+	total := c.earlierUSD + c.processUSD
+	prevTurn, turn := prevTotal-c.turnStartUSD, total-c.turnStartUSD
+
+	var fired []func()
+	for _, alert := range c.alerts {
+		cost := SessionCost{
+			SessionID: result.SessionID,
+			Threshold: alert.Threshold,
+			TurnUSD:   turn,
+			TotalUSD:  total,
+			Result:    result,
+		}
+		if prevTurn < alert.Threshold && turn >= alert.Threshold {
+			turnCost := cost
+			turnCost.Scope = CostScopeTurn
+			fired = append(fired, func() { alert.Fn(turnCost) })
+		}
+		if prevTotal < alert.Threshold && total >= alert.Threshold {
+			cost.Scope = CostScopeSession
+			fired = append(fired, func() { alert.Fn(cost) })
+		}
+	}
+	c.mu.Unlock()
+
+	for _, fn := range fired {
+		fn()
+	}
+}
+
+// reset starts adding up the cost of a new turn.
+func (c *costTracker) reset() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.turnStartUSD = c.earlierUSD + c.processUSD
+	c.mu.Unlock()
+}
+
+// newProcess notes that a new CLI process was started, whose totals count
+// from zero.
+func (c *costTracker) newProcess() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.earlierUSD += c.processUSD
+	c.processUSD = 0
+	c.mu.Unlock()
+}
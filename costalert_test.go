@@ -0,0 +1,120 @@
+package claude
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func costResult(total float64) *ResultMessage {
+	return &ResultMessage{SessionID: "s", TotalCostUSD: &total}
+}
+
+func TestCostTracker_Disabled(t *testing.T) {
+	if c := newCostTracker(NewOptions()); c != nil {
+		t.Error("Expected no tracker without alerts")
+	}
+	var c *costTracker
+	c.observe(costResult(1))
+	c.reset()
+	c.newProcess()
+}
+
+func TestCostTracker_Turn(t *testing.T) {
+	var alerts []SessionCost
+	c := newCostTracker(NewOptions(WithCostAlert(1, func(cost SessionCost) {
+		if cost.Scope == CostScopeTurn {
+			alerts = append(alerts, cost)
+		}
+	})))
+
+	// The CLI reports the total of its process; turns cost the difference
+	c.observe(costResult(0.6))
+	c.reset()
+	c.observe(costResult(1.8))
+	c.reset()
+	c.observe(costResult(2.0))
+
+	if len(alerts) != 1 {
+		t.Fatalf("Expected one turn alert, got %+v", alerts)
+	}
+	if alerts[0].TotalUSD != 1.8 || alerts[0].TurnUSD < 1.19 || alerts[0].TurnUSD > 1.21 {
+		t.Errorf("Expected a $1.2 turn of $1.8 total, got %+v", alerts[0])
+	}
+
+	// Follow-ups within a turn add up
+	c.reset()
+	c.observe(costResult(2.6))
+	c.observe(costResult(3.2))
+	if len(alerts) != 2 {
+		t.Errorf("Expected a second turn alert, got %+v", alerts)
+	}
+}
+
+func TestCostTracker_Session(t *testing.T) {
+	var alerts []SessionCost
+	c := newCostTracker(NewOptions(WithCostAlert(1, func(cost SessionCost) {
+		if cost.Scope == CostScopeSession {
+			alerts = append(alerts, cost)
+		}
+	})))
+
+	c.observe(costResult(0.6))
+	c.reset()
+
+	// A new process counts from zero
+	c.newProcess()
+	c.observe(costResult(0.5))
+	c.reset()
+	c.observe(costResult(0.9))
+
+	if len(alerts) != 1 || alerts[0].TotalUSD != 1.1 || alerts[0].Threshold != 1 || alerts[0].SessionID != "s" {
+		t.Errorf("Expected one alert at $1.1, got %+v", alerts)
+	}
+}
+
+func TestCostTracker_NoCost(t *testing.T) {
+	fired := false
+	c := newCostTracker(NewOptions(WithCostAlert(0.5, func(SessionCost) { fired = true })))
+	c.observe(&ResultMessage{})
+	if fired {
+		t.Error("Expected no alert for a result without a cost")
+	}
+}
+
+func TestClient_CostAlert(t *testing.T) {
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+total=0
+while read line; do
+  total=$((total+3))
+  echo '{"type":"result","subtype":"success","session_id":"s","total_cost_usd":'$total'}'
+done
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var alerts []SessionCost
+	client := NewClient(WithCLIPath(cli), WithCostAlert(5, func(cost SessionCost) {
+		alerts = append(alerts, cost)
+	}))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	for range 2 {
+		if err := client.Query(ctx, "Hello"); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		for range client.ReceiveResponse(ctx) {
+		}
+	}
+
+	if len(alerts) != 1 || alerts[0].Scope != CostScopeSession || alerts[0].TotalUSD != 6 || alerts[0].TurnUSD != 3 {
+		t.Errorf("Expected a session alert at $6, got %+v", alerts)
+	}
+}
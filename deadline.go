@@ -0,0 +1,84 @@
+package claude
+
+import (
+	"context"
+	"maps"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/transport"
+)
+
+// deadlineEnv are the environment variables of the CLI bounding, in
+// milliseconds, how long an API request, a Bash command and an MCP tool
+// call may take.
+var deadlineEnv = []string{"API_TIMEOUT_MS", "BASH_DEFAULT_TIMEOUT_MS", "BASH_MAX_TIMEOUT_MS", "MCP_TOOL_TIMEOUT"}
+
+// deadlineReason is the reason of the interrupt ending a Client turn
+// whose deadline has come.
+const deadlineReason = "context deadline"
+
+// turnBudget returns the time a turn run with ctx has under o: the time
+// left before the deadline of ctx, less DeadlineMargin. It reports false
+// if o does not propagate deadlines or ctx has none.
+func turnBudget(ctx context.Context, o *Options) (time.Duration, bool) {
+	if !o.PropagateDeadline {
+		return 0, false
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline) - o.DeadlineMargin, true
+}
+
+// applyDeadline caps the timeouts of the CLI run with opts to the budget
+// of a turn run with ctx, so that no step of the turn outlives it.
+// Lower timeouts already set are kept.
+func applyDeadline(ctx context.Context, o *Options, opts *transport.Options) error {
+	budget, ok := turnBudget(ctx, o)
+	if !ok {
+		return nil
+	}
+	if budget <= 0 {
+		return WrapClaudeSDKError("no time left for the turn before the context deadline", context.DeadlineExceeded)
+	}
+
+	limit := max(budget.Milliseconds(), 1)
+	env := maps.Clone(opts.Env)
+	if env == nil {
+		env = make(map[string]string)
+	}
+	for _, key := range deadlineEnv {
+		value, set := env[key]
+		if !set {
+			value = os.Getenv(key)
+		}
+		if ms, err := strconv.ParseInt(value, 10, 64); err == nil && ms > 0 && ms <= limit {
+			continue
+		}
+		env[key] = strconv.FormatInt(limit, 10)
+	}
+	opts.Env = env
+	return nil
+}
+
+// watchDeadline interrupts turn of c when the budget of a turn run with
+// ctx runs out, so that its ResultMessage arrives before the deadline. It
+// returns a function stopping the watch, or nil if there is no budget.
+func (c *Client) watchDeadline(ctx context.Context, turn uint64) func() bool {
+	budget, ok := turnBudget(ctx, c.options)
+	if !ok {
+		return nil
+	}
+	timer := time.AfterFunc(max(budget, 0), func() {
+		c.turnMu.Lock()
+		active := c.turnActive && c.turn == turn
+		c.turnMu.Unlock()
+		if active {
+			_ = c.InterruptWithReason(context.Background(), deadlineReason)
+		}
+	})
+	return timer.Stop
+}
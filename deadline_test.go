@@ -0,0 +1,90 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/transport"
+)
+
+func TestApplyDeadline(t *testing.T) {
+	for _, key := range deadlineEnv {
+		t.Setenv(key, "")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	options := NewOptions(WithDeadlinePropagation(2*time.Second), WithEnv(map[string]string{"MCP_TOOL_TIMEOUT": "1000"}))
+	opts := toTransportOptions(options)
+	if err := applyDeadline(ctx, options, opts); err != nil {
+		t.Fatalf("applyDeadline failed: %v", err)
+	}
+	for _, key := range []string{"API_TIMEOUT_MS", "BASH_DEFAULT_TIMEOUT_MS", "BASH_MAX_TIMEOUT_MS"} {
+		ms, err := strconv.Atoi(opts.Env[key])
+		if err != nil || ms <= 7000 || ms > 8000 {
+			t.Errorf("Expected %s capped to about 8s, got %q", key, opts.Env[key])
+		}
+	}
+	if opts.Env["MCP_TOOL_TIMEOUT"] != "1000" {
+		t.Errorf("Expected a lower timeout to be kept, got %q", opts.Env["MCP_TOOL_TIMEOUT"])
+	}
+	if _, ok := options.Env["API_TIMEOUT_MS"]; ok {
+		t.Error("Expected the options to be left unchanged")
+	}
+
+	// Nothing changes without the option or a deadline
+	opts = &transport.Options{}
+	if err := applyDeadline(ctx, NewOptions(), opts); err != nil || opts.Env != nil {
+		t.Errorf("Expected no change without the option, got %v, %v", opts.Env, err)
+	}
+	if err := applyDeadline(context.Background(), options, opts); err != nil || opts.Env != nil {
+		t.Errorf("Expected no change without a deadline, got %v, %v", opts.Env, err)
+	}
+
+	// No time left within the margin
+	options = NewOptions(WithDeadlinePropagation(time.Minute))
+	if err := applyDeadline(ctx, options, &transport.Options{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestClient_DeadlinePropagation(t *testing.T) {
+	cli := writeStubCLI(t, `
+respond() {
+	id=$(echo "$1" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+	echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+}
+read line; respond "$line"
+read line
+read line
+case "$line" in
+*'"interrupt"'*) respond "$line"; echo '{"type":"result","subtype":"error_during_execution","is_error":true,"session_id":"s"}' ;;
+esac
+cat > /dev/null
+`)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli), WithDeadlinePropagation(9*time.Second+800*time.Millisecond))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.Query(ctx, "long task"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	var result *ResultMessage
+	for msg := range client.ReceiveResponse(ctx) {
+		result, _ = msg.(*ResultMessage)
+	}
+	if result == nil || !result.IsInterrupted() || result.InterruptReason != deadlineReason {
+		t.Fatalf("Expected the turn interrupted at its deadline, got %+v", result)
+	}
+	if ctx.Err() != nil {
+		t.Error("Expected the result before the context expired")
+	}
+}
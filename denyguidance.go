@@ -0,0 +1,70 @@
+package claude
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// DenyGuidance tells Claude how to go on after a tool call was denied,
+// rather than leaving it to guess from a bare message. Set fields are
+// added to the deny message, one line each.
+type DenyGuidance struct {
+	// AlternativeTool is a tool to use instead, such as ToolGrep for a
+	// denied Bash grep.
+	AlternativeTool ToolName
+	// AlternativeInput is suggested input for AlternativeTool.
+	AlternativeInput map[string]any
+	// DocsURL links to the policy or documentation behind the denial.
+	DocsURL string
+	// Hint is free-form advice, such as "ask the user for approval first".
+	Hint string
+}
+
+// DenyWithGuidance returns a denial with message, adding guidance for
+// Claude to recover.
+//
+// Example:
+//
+//	if toolName == "Bash" && strings.HasPrefix(command, "grep ") {
+//	    return claude.DenyWithGuidance("Bash is disabled", claude.DenyGuidance{
+//	        AlternativeTool: claude.ToolGrep,
+//	        DocsURL:         "https://wiki.example.com/agent-policy",
+//	    }), nil
+//	}
+func DenyWithGuidance(message string, guidance DenyGuidance) PermissionResultDeny {
+	return PermissionResultDeny{Message: message, Guidance: &guidance}
+}
+
+// denyMessage returns the message of r with its guidance in locale, as
+// sent to Claude.
+func (r PermissionResultDeny) denyMessage(locale string) string {
+	g := r.Guidance
+	if g == nil {
+		return r.Message
+	}
+
+	var lines []string
+	if g.AlternativeTool != "" {
+		line := localize(locale, MessageDenyUseTool, g.AlternativeTool)
+		if len(g.AlternativeInput) > 0 {
+			if input, err := json.Marshal(g.AlternativeInput); err == nil {
+				line = localize(locale, MessageDenyUseToolWithInput, g.AlternativeTool, string(input))
+			}
+		}
+		lines = append(lines, line)
+	}
+	if g.Hint != "" {
+		lines = append(lines, g.Hint)
+	}
+	if g.DocsURL != "" {
+		lines = append(lines, localize(locale, MessageDenySeeDocs, g.DocsURL))
+	}
+	if len(lines) == 0 {
+		return r.Message
+	}
+	guidance := strings.Join(lines, "\n")
+	if r.Message == "" {
+		return guidance
+	}
+	return r.Message + "\n\n" + guidance
+}
@@ -0,0 +1,59 @@
+package claude
+
+import (
+	"context"
+	"testing"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/types"
+)
+
+func TestDenyWithGuidance(t *testing.T) {
+	tests := []struct {
+		name   string
+		result PermissionResultDeny
+		want   string
+	}{
+		{"no guidance", PermissionResultDeny{Message: "Bash is disabled"}, "Bash is disabled"},
+		{"empty guidance", DenyWithGuidance("Bash is disabled", DenyGuidance{}), "Bash is disabled"},
+		{
+			"alternative tool",
+			DenyWithGuidance("Bash is disabled", DenyGuidance{
+				AlternativeTool:  ToolGrep,
+				AlternativeInput: map[string]any{"pattern": "TODO"},
+			}),
+			"Bash is disabled\n\nUse the Grep tool instead, with input {\"pattern\":\"TODO\"}.",
+		},
+		{
+			"all fields",
+			DenyWithGuidance("Writes outside the workspace are not allowed", DenyGuidance{
+				AlternativeTool: ToolEdit,
+				Hint:            "Edit files under ./src only.",
+				DocsURL:         "https://example.com/policy",
+			}),
+			"Writes outside the workspace are not allowed\n\nUse the Edit tool instead.\nEdit files under ./src only.\nSee https://example.com/policy",
+		},
+		{"no message", DenyWithGuidance("", DenyGuidance{Hint: "Ask first."}), "Ask first."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.result.denyMessage(""); got != tt.want {
+				t.Errorf("Expected message %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestToInternalCanUseTool_DenyWithGuidance(t *testing.T) {
+	fn := func(ctx context.Context, toolName string, input map[string]any, permCtx ToolPermissionContext) (PermissionResult, error) {
+		return DenyWithGuidance("Bash is disabled", DenyGuidance{AlternativeTool: ToolGlob}), nil
+	}
+
+	result, err := toInternalCanUseTool(fn, "")(context.Background(), "Bash", map[string]any{}, types.ToolPermissionContext{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	deny, ok := result.(types.PermissionResultDeny)
+	if !ok || deny.Message != "Bash is disabled\n\nUse the Glob tool instead." {
+		t.Errorf("Expected the guidance in the deny message, got %+v", result)
+	}
+}
@@ -0,0 +1,334 @@
+package claude
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/transport"
+)
+
+// Files kept in a detached session's state directory.
+const (
+	detachedStateFile  = "session.json"
+	detachedOutputFile = "output.jsonl"
+	detachedStderrFile = "stderr.log"
+	detachedPromptFile = "prompt.txt"
+)
+
+// detachedPollInterval is how often a running session's output is polled.
+const detachedPollInterval = 200 * time.Millisecond
+
+// DetachedSession is a query running in a background CLI process that
+// outlives the program that started it.
+//
+// Its state is persisted under the state directory, so another process can
+// re-attach with Attach to collect the results. The session ID doubles as
+// the resume token: pass it to WithResume to continue the conversation.
+type DetachedSession struct {
+	// ID is the CLI session ID.
+	ID string `json:"session_id"`
+	// PID is the process ID of the background CLI.
+	PID int `json:"pid"`
+	// ProcessIdentity identifies the background CLI process beyond its
+	// PID, so a reused PID is not mistaken for the session.
+	ProcessIdentity string `json:"process_identity,omitempty"`
+	// StartedAt is when the session was started.
+	StartedAt time.Time `json:"started_at"`
+	// Cwd is the working directory of the session, if set.
+	Cwd string `json:"cwd,omitempty"`
+
+	dir string
+}
+
+// StartDetached starts prompt in a background CLI process and persists the
+// session to disk. The process keeps running after the calling program
+// exits; use Attach with the returned session's ID to collect its results.
+//
+// The state directory defaults to a directory under os.UserCacheDir and can
+// be changed with WithDetachedStateDir.
+//
+// With WithResume or WithContinueConversation, the new session is forked
+// from the resumed one, since the CLI requires a fresh session ID.
+//
+// Example:
+//
+//	session, err := claude.StartDetached(ctx, "Refactor the billing module",
+//	    claude.WithPermissionMode(claude.PermissionModeAcceptEdits))
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	fmt.Println(session.ID) // Save for later
+func StartDetached(ctx context.Context, prompt string, opts ...Option) (*DetachedSession, error) {
+	options := NewOptions(opts...)
+
+	if permissionCallback(options, nil) != nil || len(options.Hooks) > 0 || len(options.NamedHooks) > 0 || options.DryRun || options.WorkspaceLock != "" {
+		return nil, NewClaudeSDKError("detached sessions cannot use CanUseTool, PlanApprover or Hooks callbacks, nor WithDryRun or WithWorkspaceLock")
+	}
+	if servers, ok := options.MCPServers.(map[string]MCPServerConfig); ok {
+		for name, config := range servers {
+			if _, ok := config.(MCPSDKServerConfig); ok {
+				return nil, NewClaudeSDKError(fmt.Sprintf("detached sessions cannot use in-process SDK MCP server %q", name))
+			}
+		}
+	}
+	if err := validateOptions(options); err != nil {
+		return nil, err
+	}
+
+	id, err := newUUID()
+	if err != nil {
+		return nil, WrapClaudeSDKError("failed to generate session ID", err)
+	}
+
+	root, err := detachedStateRoot(options)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(root, id)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, WrapClaudeSDKError("failed to create session state directory", err)
+	}
+
+	transportOpts := toTransportOptions(options)
+	transportOpts.SessionID = id
+	if options.Resume != "" || options.ContinueConversation {
+		transportOpts.ForkSession = true
+	}
+
+	t, err := transport.NewSubprocessTransport(prompt, false, transportOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := os.OpenFile(filepath.Join(dir, detachedOutputFile), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, WrapClaudeSDKError("failed to create session output file", err)
+	}
+	defer func() { _ = stdout.Close() }()
+
+	stderr, err := os.OpenFile(filepath.Join(dir, detachedStderrFile), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, WrapClaudeSDKError("failed to create session stderr file", err)
+	}
+	defer func() { _ = stderr.Close() }()
+
+	pid, err := t.StartDetached(ctx, stdout, stderr, filepath.Join(dir, detachedPromptFile))
+	if err != nil {
+		return nil, WrapCLIConnectionError("failed to start detached session", err)
+	}
+
+	session := &DetachedSession{
+		ID:              id,
+		PID:             pid,
+		ProcessIdentity: transport.ProcessIdentity(pid),
+		StartedAt:       time.Now(),
+		Cwd:             options.Cwd,
+		dir:             dir,
+	}
+	if err := session.save(); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Attach loads a detached session started by StartDetached, possibly in
+// another process. Pass the same WithDetachedStateDir option used to start
+// the session, if any.
+func Attach(sessionID string, opts ...Option) (*DetachedSession, error) {
+	if sessionID == "" || strings.ContainsAny(sessionID, `/\`) || sessionID == "." || sessionID == ".." {
+		return nil, NewClaudeSDKError(fmt.Sprintf("invalid session ID: %q", sessionID))
+	}
+
+	root, err := detachedStateRoot(NewOptions(opts...))
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(root, sessionID)
+
+	data, err := os.ReadFile(filepath.Join(dir, detachedStateFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, NewClaudeSDKError(fmt.Sprintf("detached session not found: %s", sessionID))
+		}
+		return nil, WrapClaudeSDKError("failed to read session state", err)
+	}
+
+	var session DetachedSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, NewJSONDecodeError(string(data), err)
+	}
+	session.dir = dir
+	return &session, nil
+}
+
+// Running reports whether the background CLI process is still running.
+//
+// A process with the session's PID only counts if it matches the recorded
+// ProcessIdentity, so a PID reused after the CLI exits or the machine
+// reboots is not mistaken for the session. If the identity could not be
+// recorded, only the PID is checked.
+func (s *DetachedSession) Running() bool {
+	if !transport.ProcessAlive(s.PID) {
+		return false
+	}
+	if s.ProcessIdentity == "" {
+		return true
+	}
+	return transport.ProcessIdentity(s.PID) == s.ProcessIdentity
+}
+
+// Messages streams the session's messages from the beginning. If the
+// session is still running, the channel follows new output until the
+// ResultMessage arrives or the process exits.
+func (s *DetachedSession) Messages(ctx context.Context) (<-chan Message, <-chan error) {
+	messages := make(chan Message, 100)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(messages)
+		defer close(errs)
+
+		if err := s.readOutput(ctx, messages); err != nil {
+			errs <- err
+		}
+	}()
+
+	return messages, errs
+}
+
+// Wait blocks until the session finishes and returns its ResultMessage.
+func (s *DetachedSession) Wait(ctx context.Context) (*ResultMessage, error) {
+	messages, errs := s.Messages(ctx)
+
+	var result *ResultMessage
+	for msg := range messages {
+		if r, ok := msg.(*ResultMessage); ok {
+			result = r
+		}
+	}
+	if err := <-errs; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Remove deletes the session's persisted state. It does not stop a
+// running process.
+func (s *DetachedSession) Remove() error {
+	return os.RemoveAll(s.dir)
+}
+
+// readOutput parses the output file into messages, following it while the
+// process runs.
+func (s *DetachedSession) readOutput(ctx context.Context, messages chan<- Message) error {
+	f, err := os.Open(filepath.Join(s.dir, detachedOutputFile))
+	if err != nil {
+		return WrapClaudeSDKError("failed to open session output", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	reader := bufio.NewReader(f)
+	var partial strings.Builder
+
+	for {
+		// Check liveness before reading so output written just before
+		// exit is never missed.
+		running := s.Running()
+
+		line, err := reader.ReadString('\n')
+		partial.WriteString(line)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return WrapClaudeSDKError("failed to read session output", err)
+		}
+
+		if errors.Is(err, io.EOF) {
+			if !running {
+				if strings.TrimSpace(partial.String()) != "" {
+					return NewJSONDecodeError(partial.String(), io.ErrUnexpectedEOF)
+				}
+				return s.exitError()
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(detachedPollInterval):
+			}
+			continue
+		}
+
+		raw := strings.TrimSpace(partial.String())
+		partial.Reset()
+		if raw == "" {
+			continue
+		}
+
+		var data map[string]any
+		if err := json.Unmarshal([]byte(raw), &data); err != nil {
+			return NewJSONDecodeError(raw, err)
+		}
+		msg, err := ParseMessage(data)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case messages <- msg:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if _, ok := msg.(*ResultMessage); ok {
+			return nil
+		}
+	}
+}
+
+// exitError reports a process that exited without producing a result.
+func (s *DetachedSession) exitError() error {
+	stderr, _ := os.ReadFile(filepath.Join(s.dir, detachedStderrFile))
+	return NewProcessError("detached session exited without a result", -1, strings.TrimSpace(string(stderr)))
+}
+
+// save persists the session state.
+func (s *DetachedSession) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, detachedStateFile), data, 0o600); err != nil {
+		return WrapClaudeSDKError("failed to write session state", err)
+	}
+	return nil
+}
+
+// detachedStateRoot returns the directory holding detached session state.
+func detachedStateRoot(options *Options) (string, error) {
+	if options.DetachedStateDir != "" {
+		return options.DetachedStateDir, nil
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", WrapClaudeSDKError("failed to locate user cache directory", err)
+	}
+	return filepath.Join(cache, "claude-agent-sdk-go", "sessions"), nil
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
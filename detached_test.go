@@ -0,0 +1,210 @@
+package claude
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/transport"
+)
+
+// writeStubCLI writes a shell script standing in for the CLI.
+func writeStubCLI(t *testing.T, body string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	t.Setenv("CLAUDE_AGENT_SDK_SKIP_VERSION_CHECK", "1")
+
+	path := filepath.Join(t.TempDir(), "claude")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+		t.Fatalf("Failed to write stub CLI: %v", err)
+	}
+	return path
+}
+
+func TestStartDetached_AttachAndWait(t *testing.T) {
+	// The stub echoes its session ID back in the result after a short delay,
+	// so the session is still running when it is attached.
+	cli := writeStubCLI(t, `
+while [ "$1" != "--session-id" ]; do shift; done
+id="$2"
+echo '{"type":"assistant","message":{"model":"m","content":[{"type":"text","text":"working"}]}}'
+sleep 0.3
+echo '{"type":"result","subtype":"success","session_id":"'$id'","result":"done"}'
+`)
+	stateDir := t.TempDir()
+
+	session, err := StartDetached(context.Background(), "long task",
+		WithCLIPath(cli),
+		WithDetachedStateDir(stateDir),
+	)
+	if err != nil {
+		t.Fatalf("StartDetached failed: %v", err)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(session.ID) {
+		t.Errorf("Expected a UUID session ID, got %q", session.ID)
+	}
+	if session.PID <= 0 {
+		t.Errorf("Expected a PID, got %d", session.PID)
+	}
+
+	attached, err := Attach(session.ID, WithDetachedStateDir(stateDir))
+	if err != nil {
+		t.Fatalf("Attach failed: %v", err)
+	}
+	if attached.PID != session.PID {
+		t.Errorf("Expected PID %d, got %d", session.PID, attached.PID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := attached.Wait(ctx)
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if result == nil || result.Result != "done" {
+		t.Fatalf("Expected result 'done', got %+v", result)
+	}
+	if result.SessionID != session.ID {
+		t.Errorf("Expected CLI to receive session ID %q, got %q", session.ID, result.SessionID)
+	}
+
+	// Messages can be replayed after completion
+	messages, errs := attached.Messages(ctx)
+	var count int
+	for range messages {
+		count++
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("Unexpected error replaying messages: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 messages, got %d", count)
+	}
+
+	if err := attached.Remove(); err != nil {
+		t.Errorf("Remove failed: %v", err)
+	}
+	if _, err := Attach(session.ID, WithDetachedStateDir(stateDir)); err == nil {
+		t.Error("Expected Attach to fail after Remove")
+	}
+}
+
+func TestStartDetached_ExitWithoutResult(t *testing.T) {
+	cli := writeStubCLI(t, `echo "auth failed" >&2; exit 1`)
+	stateDir := t.TempDir()
+
+	session, err := StartDetached(context.Background(), "task",
+		WithCLIPath(cli),
+		WithDetachedStateDir(stateDir),
+	)
+	if err != nil {
+		t.Fatalf("StartDetached failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = session.Wait(ctx)
+	if !IsProcessError(err) {
+		t.Fatalf("Expected ProcessError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "auth failed") {
+		t.Errorf("Expected stderr in error, got %v", err)
+	}
+}
+
+func TestStartDetached_RejectsCallbacks(t *testing.T) {
+	_, err := StartDetached(context.Background(), "task",
+		WithDetachedStateDir(t.TempDir()),
+		WithCanUseTool(func(ctx context.Context, toolName string, input map[string]any, permCtx ToolPermissionContext) (PermissionResult, error) {
+			return PermissionResultAllow{}, nil
+		}),
+	)
+	if err == nil {
+		t.Fatal("Expected error for CanUseTool in detached mode")
+	}
+
+	_, err = StartDetached(context.Background(), "task", WithDetachedStateDir(t.TempDir()), WithDryRun())
+	if err == nil {
+		t.Fatal("Expected error for WithDryRun in detached mode")
+	}
+
+	_, err = StartDetached(context.Background(), "task", WithDetachedStateDir(t.TempDir()), WithWorkspaceLock(WorkspaceLockQueue))
+	if err == nil {
+		t.Fatal("Expected error for WithWorkspaceLock in detached mode")
+	}
+}
+
+func TestAttach_InvalidSessionID(t *testing.T) {
+	stateDir := t.TempDir()
+	for _, id := range []string{"", "..", "../etc", `a\b`, "missing"} {
+		if _, err := Attach(id, WithDetachedStateDir(stateDir)); err == nil {
+			t.Errorf("Expected error attaching to %q", id)
+		}
+	}
+}
+
+func TestStartDetached_ResumeForks(t *testing.T) {
+	cli := writeStubCLI(t, `echo "$@" > "$(dirname "$0")/args"
+echo '{"type":"result","subtype":"success","session_id":"s"}'
+`)
+	stateDir := t.TempDir()
+
+	for _, opt := range []Option{WithResume("earlier-session"), WithContinueConversation(true)} {
+		session, err := StartDetached(context.Background(), "continue",
+			WithCLIPath(cli),
+			WithDetachedStateDir(stateDir),
+			opt,
+		)
+		if err != nil {
+			t.Fatalf("StartDetached failed: %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if _, err := session.Wait(ctx); err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+		cancel()
+
+		args, err := os.ReadFile(filepath.Join(filepath.Dir(cli), "args"))
+		if err != nil {
+			t.Fatalf("Failed to read args: %v", err)
+		}
+		if !strings.Contains(string(args), "--fork-session") || !strings.Contains(string(args), "--session-id "+session.ID) {
+			t.Errorf("Expected a forked session with its own ID, got %q", args)
+		}
+	}
+}
+
+func TestStartDetached_RejectsSDKMCPServers(t *testing.T) {
+	_, err := StartDetached(context.Background(), "task",
+		WithDetachedStateDir(t.TempDir()),
+		WithMCPServers(map[string]MCPServerConfig{
+			"calc": MCPSDKServerConfig{Name: "calc"},
+		}),
+	)
+	if err == nil {
+		t.Fatal("Expected error for SDK MCP server in detached mode")
+	}
+}
+
+func TestDetachedSession_Running_ReusedPID(t *testing.T) {
+	// The test process stands in for an unrelated process that reused the PID
+	session := &DetachedSession{PID: os.Getpid(), ProcessIdentity: "stale"}
+	if session.Running() {
+		t.Error("Expected a reused PID to not count as running")
+	}
+
+	session.ProcessIdentity = transport.ProcessIdentity(os.Getpid())
+	if !session.Running() {
+		t.Error("Expected matching process to be running")
+	}
+}
@@ -0,0 +1,375 @@
+package claude
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines around each change in a
+// unified diff.
+const diffContext = 3
+
+// diffOp is one line of a line diff: ' ' unchanged, '-' removed, '+' added.
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// unifiedDiff returns a unified diff of before and after, or "" if they
+// are equal.
+func unifiedDiff(path, before, after string) string {
+	ops := diffLines(splitLines(before), splitLines(after))
+	path = strings.TrimPrefix(path, "/")
+
+	var b strings.Builder
+	for _, h := range diffHunks(ops) {
+		if b.Len() == 0 {
+			fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n", path, path)
+		}
+		b.WriteString(h)
+	}
+	return b.String()
+}
+
+// splitLines splits text into lines without their line endings.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines computes a shortest line diff of a and b using Myers'
+// algorithm.
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	maxD := n + m
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+
+	// trace[d] holds the furthest x reached on each diagonal before
+	// step d, for walking the edit path back
+	var trace [][]int
+search:
+	for d := 0; d <= maxD; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	var ops []diffOp
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{' ', a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, diffOp{'+', b[y-1]})
+			} else {
+				ops = append(ops, diffOp{'-', a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// diffHunks groups a line diff into unified diff hunks.
+func diffHunks(ops []diffOp) []string {
+	// Line numbers in a and b before each op
+	aLine := make([]int, len(ops)+1)
+	bLine := make([]int, len(ops)+1)
+	for i, op := range ops {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if op.kind != '+' {
+			aLine[i+1]++
+		}
+		if op.kind != '-' {
+			bLine[i+1]++
+		}
+	}
+
+	var hunks []string
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		start := max(0, i-diffContext)
+		end := i
+		for {
+			for end < len(ops) && ops[end].kind != ' ' {
+				end++
+			}
+			// Merge with the next change if the hunks would touch
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' && next-end < 2*diffContext {
+				next++
+			}
+			if next < len(ops) && ops[next].kind != ' ' {
+				end = next
+				continue
+			}
+			break
+		}
+		stop := min(len(ops), end+diffContext)
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n",
+			hunkRange(aLine[start], aLine[stop]-aLine[start]),
+			hunkRange(bLine[start], bLine[stop]-bLine[start]))
+		for _, op := range ops[start:stop] {
+			b.WriteByte(op.kind)
+			b.WriteString(op.line)
+			b.WriteByte('\n')
+		}
+		hunks = append(hunks, b.String())
+		i = stop
+	}
+	return hunks
+}
+
+// hunkRange formats the line range of a hunk starting after line start.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// devNull is the path of a missing file in a unified diff: the old path of
+// a file created, or the new path of a file deleted.
+const devNull = "/dev/null"
+
+// filePatch is the part of a unified diff that changes one file.
+type filePatch struct {
+	oldPath, newPath string
+	hunks            []patchHunk
+}
+
+// patchHunk is a hunk of a unified diff. oldStart is the line of the old
+// file it starts at, or, if it has no old lines, the line after which it
+// adds its lines.
+type patchHunk struct {
+	oldStart int
+	ops      []diffOp
+}
+
+// parsePatch parses a unified diff, as made by diff -u or git diff, into
+// the changes to each file. Lines outside the file headers and hunks, such
+// as the "diff --git" and "index" lines of git, are skipped.
+func parsePatch(patch string) ([]filePatch, error) {
+	lines := splitLines(strings.ReplaceAll(patch, "\r\n", "\n"))
+	var patches []filePatch
+	for i := 0; i < len(lines); i++ {
+		if !strings.HasPrefix(lines[i], "--- ") || i+1 >= len(lines) || !strings.HasPrefix(lines[i+1], "+++ ") {
+			continue
+		}
+		fp := filePatch{oldPath: patchPath(lines[i][4:]), newPath: patchPath(lines[i+1][4:])}
+		i += 2
+		for i < len(lines) && strings.HasPrefix(lines[i], "@@ ") {
+			hunk, n, err := parseHunk(lines[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", fp.newPath, err)
+			}
+			fp.hunks = append(fp.hunks, hunk)
+			i += n
+		}
+		if len(fp.hunks) == 0 {
+			return nil, fmt.Errorf("%s: no hunks", fp.newPath)
+		}
+		patches = append(patches, fp)
+		i--
+	}
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("no file changes found in patch")
+	}
+	return patches, nil
+}
+
+// patchPath returns the path of a file header line of a unified diff,
+// without a timestamp or the "a/" and "b/" prefixes of git.
+func patchPath(header string) string {
+	path, _, _ := strings.Cut(header, "\t")
+	path = strings.TrimSpace(path)
+	if path == devNull {
+		return path
+	}
+	if strings.HasPrefix(path, "a/") || strings.HasPrefix(path, "b/") {
+		return path[2:]
+	}
+	return path
+}
+
+// parseHunk parses the hunk starting at lines[0], returning it and the
+// number of lines it takes.
+func parseHunk(lines []string) (patchHunk, int, error) {
+	header := lines[0]
+	fields := strings.Fields(header)
+	if len(fields) < 4 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") || fields[3] != "@@" {
+		return patchHunk{}, 0, fmt.Errorf("malformed hunk header %q", header)
+	}
+	oldStart, oldCount, err1 := parseHunkRange(fields[1][1:])
+	_, newCount, err2 := parseHunkRange(fields[2][1:])
+	if err1 != nil || err2 != nil {
+		return patchHunk{}, 0, fmt.Errorf("malformed hunk header %q", header)
+	}
+
+	hunk := patchHunk{oldStart: oldStart}
+	n := 1
+	for oldCount > 0 || newCount > 0 {
+		if n >= len(lines) {
+			return patchHunk{}, 0, fmt.Errorf("hunk %q ends early", header)
+		}
+		line := lines[n]
+		n++
+		kind := byte(' ')
+		if line != "" {
+			kind = line[0]
+			line = line[1:]
+		}
+		switch kind {
+		case ' ':
+			oldCount--
+			newCount--
+		case '-':
+			oldCount--
+		case '+':
+			newCount--
+		case '\\':
+			// "\ No newline at end of file"
+			continue
+		default:
+			return patchHunk{}, 0, fmt.Errorf("hunk %q ends early", header)
+		}
+		if oldCount < 0 || newCount < 0 {
+			return patchHunk{}, 0, fmt.Errorf("hunk %q has more lines than its header counts", header)
+		}
+		hunk.ops = append(hunk.ops, diffOp{kind, line})
+	}
+	for n < len(lines) && strings.HasPrefix(lines[n], "\\") {
+		n++
+	}
+	return hunk, n, nil
+}
+
+// parseHunkRange parses a line range of a hunk header, such as "3,4" or
+// "3", where the count defaults to 1.
+func parseHunkRange(r string) (start, count int, err error) {
+	first, rest, found := strings.Cut(r, ",")
+	if start, err = strconv.Atoi(first); err != nil {
+		return 0, 0, err
+	}
+	count = 1
+	if found {
+		if count, err = strconv.Atoi(rest); err != nil {
+			return 0, 0, err
+		}
+	}
+	return start, count, nil
+}
+
+// applyHunks applies hunks to text. A hunk whose old lines are not at the
+// line it names is applied where they are found nearest to it, after the
+// previous hunk, as patch does when files have shifted.
+func applyHunks(text string, hunks []patchHunk) (string, error) {
+	lines := splitLines(text)
+	var out []string
+	pos := 0
+	for i, hunk := range hunks {
+		var old, updated []string
+		for _, op := range hunk.ops {
+			if op.kind != '+' {
+				old = append(old, op.line)
+			}
+			if op.kind != '-' {
+				updated = append(updated, op.line)
+			}
+		}
+		want := hunk.oldStart - 1
+		if len(old) == 0 {
+			want = hunk.oldStart
+		}
+		at := findLines(lines, old, pos, want)
+		if at < 0 {
+			return "", fmt.Errorf("hunk %d does not apply: its lines were not found", i+1)
+		}
+		out = append(out, lines[pos:at]...)
+		out = append(out, updated...)
+		pos = at + len(old)
+	}
+	out = append(out, lines[pos:]...)
+
+	if len(out) == 0 {
+		return "", nil
+	}
+	result := strings.Join(out, "\n")
+	if text == "" || strings.HasSuffix(text, "\n") {
+		result += "\n"
+	}
+	return result, nil
+}
+
+// findLines returns the index of the occurrence of want in lines at or
+// after from that is nearest to near, or -1 if there is none.
+func findLines(lines, want []string, from, near int) int {
+	matches := func(at int) bool {
+		if at < from || at+len(want) > len(lines) {
+			return false
+		}
+		for i, line := range want {
+			if lines[at+i] != line {
+				return false
+			}
+		}
+		return true
+	}
+	near = min(max(near, from), len(lines))
+	for offset := 0; near-offset >= from || near+offset <= len(lines); offset++ {
+		if matches(near - offset) {
+			return near - offset
+		}
+		if matches(near + offset) {
+			return near + offset
+		}
+	}
+	return -1
+}
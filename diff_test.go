@@ -0,0 +1,136 @@
+package claude
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   string
+	}{
+		{
+			name:   "equal",
+			before: "a\nb\n",
+			after:  "a\nb\n",
+			want:   "",
+		},
+		{
+			name:   "create",
+			before: "",
+			after:  "one\ntwo\n",
+			want:   "--- a/f.txt\n+++ b/f.txt\n@@ -0,0 +1,2 @@\n+one\n+two\n",
+		},
+		{
+			name:   "delete all",
+			before: "one\n",
+			after:  "",
+			want:   "--- a/f.txt\n+++ b/f.txt\n@@ -1 +0,0 @@\n-one\n",
+		},
+		{
+			name:   "replace with context",
+			before: "1\n2\n3\n4\n5\n6\n7\n8\n",
+			after:  "1\n2\n3\n4\nfive\n6\n7\n8\n",
+			want:   "--- a/f.txt\n+++ b/f.txt\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name:   "separate hunks",
+			before: "a\n1\n2\n3\n4\n5\n6\n7\nb\n",
+			after:  "A\n1\n2\n3\n4\n5\n6\n7\nB\n",
+			want: "--- a/f.txt\n+++ b/f.txt\n" +
+				"@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n" +
+				"@@ -6,4 +6,4 @@\n 5\n 6\n 7\n-b\n+B\n",
+		},
+		{
+			name:   "merged hunks",
+			before: "a\n1\n2\n3\n4\n5\n6\nb\n",
+			after:  "A\n1\n2\n3\n4\n5\n6\nB\n",
+			want:   "--- a/f.txt\n+++ b/f.txt\n@@ -1,8 +1,8 @@\n-a\n+A\n 1\n 2\n 3\n 4\n 5\n 6\n-b\n+B\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff("f.txt", tt.before, tt.after); got != tt.want {
+				t.Errorf("Expected diff:\n%s\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDiffLines_Reconstructs(t *testing.T) {
+	a := strings.Split("the quick brown fox jumps over the lazy dog", " ")
+	b := strings.Split("a quick red fox leaps over the dog today", " ")
+
+	var before, after []string
+	for _, op := range diffLines(a, b) {
+		if op.kind != '+' {
+			before = append(before, op.line)
+		}
+		if op.kind != '-' {
+			after = append(after, op.line)
+		}
+	}
+	if strings.Join(before, " ") != strings.Join(a, " ") {
+		t.Errorf("Diff does not reproduce a: %v", before)
+	}
+	if strings.Join(after, " ") != strings.Join(b, " ") {
+		t.Errorf("Diff does not reproduce b: %v", after)
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	before := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	after := "1\ntwo\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\nthirteen\n"
+	patches, err := parsePatch("diff --git a/f.txt b/f.txt\nindex 1..2 100644\n" + unifiedDiff("f.txt", before, after))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 1 || patches[0].oldPath != "f.txt" || patches[0].newPath != "f.txt" || len(patches[0].hunks) != 2 {
+		t.Fatalf("Unexpected patches %+v", patches)
+	}
+	if got, err := applyHunks(before, patches[0].hunks); err != nil || got != after {
+		t.Errorf("Expected the patch to give %q, got %q, %v", after, got, err)
+	}
+
+	// Lines added above the hunks shift them
+	shifted := "0\n0\n" + before
+	if got, err := applyHunks(shifted, patches[0].hunks); err != nil || got != "0\n0\n"+after {
+		t.Errorf("Expected the patch to apply to shifted lines, got %q, %v", got, err)
+	}
+	if _, err := applyHunks("1\n2\n", patches[0].hunks); err == nil {
+		t.Error("Expected a patch to fail on lines it does not match")
+	}
+}
+
+func TestParsePatch(t *testing.T) {
+	patch := "--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1,2 @@\n+a\n+b\n\\ No newline at end of file\n" +
+		"--- a/old.txt\t2024-01-01 00:00:00\n+++ /dev/null\n@@ -1 +0,0 @@\n-gone\n"
+	patches, err := parsePatch(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 2 || patches[0].oldPath != devNull || patches[0].newPath != "new.txt" || patches[1].oldPath != "old.txt" || patches[1].newPath != devNull {
+		t.Fatalf("Unexpected patches %+v", patches)
+	}
+	if got, _ := applyHunks("", patches[0].hunks); got != "a\nb\n" {
+		t.Errorf("Expected the file created, got %q", got)
+	}
+	if got, _ := applyHunks("gone\n", patches[1].hunks); got != "" {
+		t.Errorf("Expected the file emptied, got %q", got)
+	}
+
+	for _, invalid := range []string{
+		"not a patch",
+		"--- a/f\n+++ b/f\n",
+		"--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n-a\n",
+		"--- a/f\n+++ b/f\n@@ -x +1 @@\n-a\n+b\n",
+	} {
+		if _, err := parsePatch(invalid); err == nil {
+			t.Errorf("Expected %q to be invalid", invalid)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package claude
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// dryRunReadOnlyTools are the built-in tools a dry run lets Claude use,
+// since they change nothing.
+var dryRunReadOnlyTools = []ToolName{
+	ToolRead,
+	ToolGlob,
+	ToolGrep,
+	ToolWebFetch,
+	ToolWebSearch,
+	ToolTask,
+	ToolTodoWrite,
+	ToolExitPlanMode,
+	ToolBashOutput,
+	ToolListMcpResources,
+	ToolReadMcpResource,
+}
+
+// PlannedAction is a tool call a dry run simulated instead of running.
+type PlannedAction struct {
+	ToolUseID string
+	ToolName  string
+	Input     map[string]any
+}
+
+// Path returns the file the action writes to, or "" if it is not a file
+// edit.
+func (a PlannedAction) Path() string {
+	field, ok := workspaceWriteTools[a.ToolName]
+	if !ok {
+		return ""
+	}
+	path, _ := a.Input[field].(string)
+	return path
+}
+
+// Command returns the command of a Bash action, or "" for other tools.
+func (a PlannedAction) Command() string {
+	if a.ToolName != string(ToolBash) {
+		return ""
+	}
+	command, _ := a.Input["command"].(string)
+	return command
+}
+
+// Plan is what a turn run with WithDryRun would have done: the tool calls
+// that were simulated, in the order Claude made them.
+type Plan struct {
+	Actions []PlannedAction
+}
+
+// Files returns the files the plan writes to, each once, in the order
+// they are first written.
+func (p *Plan) Files() []string {
+	var files []string
+	for _, action := range p.Actions {
+		if path := action.Path(); path != "" && !slices.Contains(files, path) {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// Commands returns the Bash commands the plan runs, in order.
+func (p *Plan) Commands() []string {
+	var commands []string
+	for _, action := range p.Actions {
+		if command := action.Command(); command != "" {
+			commands = append(commands, command)
+		}
+	}
+	return commands
+}
+
+// dryRun denies the tools that are not read-only and records the calls
+// it denied as the plan of the current turn.
+type dryRun struct {
+	readOnly []ToolName
+	locale   string
+
+	mu      sync.Mutex
+	actions []PlannedAction
+}
+
+// newDryRun returns a dry run for o, or nil if WithDryRun is not set.
+func newDryRun(o *Options) *dryRun {
+	if !o.DryRun {
+		return nil
+	}
+	return &dryRun{
+		readOnly: append(slices.Clone(dryRunReadOnlyTools), o.DryRunReadOnlyTools...),
+		locale:   o.Locale,
+	}
+}
+
+// hook returns a PreToolUse hook simulating the calls of tools that are
+// not read-only.
+func (d *dryRun) hook() HookCallback {
+	return func(ctx context.Context, input HookInput, toolUseID string, _ HookContext) (HookOutput, error) {
+		pre, ok := input.(PreToolUseHookInput)
+		if !ok || slices.Contains(d.readOnly, ToolName(pre.ToolName)) {
+			return HookOutput{}, nil
+		}
+		d.mu.Lock()
+		d.actions = append(d.actions, PlannedAction{
+			ToolUseID: toolUseID,
+			ToolName:  pre.ToolName,
+			Input:     pre.ToolInput,
+		})
+		d.mu.Unlock()
+		return HookOutput{
+			HookSpecificOutput: PreToolUseHookSpecificOutput{
+				HookEventName:            HookEventPreToolUse,
+				PermissionDecision:       HookPermissionDecisionDeny,
+				PermissionDecisionReason: localize(d.locale, MessageDryRunSimulated, pre.ToolName),
+			},
+		}, nil
+	}
+}
+
+// withHook returns a copy of hooks with the dry run's hook added.
+func (d *dryRun) withHook(hooks map[HookEvent][]HookMatcher) map[HookEvent][]HookMatcher {
+	if d == nil {
+		return hooks
+	}
+	merged := make(map[HookEvent][]HookMatcher, len(hooks)+1)
+	for event, matchers := range hooks {
+		merged[event] = matchers
+	}
+	merged[HookEventPreToolUse] = append(
+		append([]HookMatcher(nil), hooks[HookEventPreToolUse]...),
+		HookMatcher{Hooks: []HookCallback{d.hook()}},
+	)
+	return merged
+}
+
+// plan returns the calls simulated in the current or most recent turn.
+func (d *dryRun) plan() *Plan {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return &Plan{Actions: slices.Clone(d.actions)}
+}
+
+// reset starts the plan of a new turn.
+func (d *dryRun) reset() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.actions = nil
+	d.mu.Unlock()
+}
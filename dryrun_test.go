@@ -0,0 +1,87 @@
+package claude
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestNewDryRun_Disabled(t *testing.T) {
+	if d := newDryRun(NewOptions()); d != nil {
+		t.Errorf("Expected no dry run without WithDryRun, got %+v", d)
+	}
+	if plan := NewClient().Plan(); plan != nil {
+		t.Errorf("Expected no plan without WithDryRun, got %+v", plan)
+	}
+}
+
+func TestDryRun_Hook(t *testing.T) {
+	d := newDryRun(NewOptions(WithDryRun("mcp__docs__search")))
+	hook := d.hook()
+
+	for i, input := range []PreToolUseHookInput{
+		{ToolName: "Write", ToolInput: map[string]any{"file_path": "a.go", "content": "package a"}},
+		{ToolName: "Bash", ToolInput: map[string]any{"command": "go test ./..."}},
+		{ToolName: "Edit", ToolInput: map[string]any{"file_path": "a.go"}},
+		{ToolName: "mcp__db__insert", ToolInput: map[string]any{"row": 1}},
+	} {
+		output, err := hook(context.Background(), input, string(rune('a'+i)), HookContext{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		specific, ok := output.HookSpecificOutput.(PreToolUseHookSpecificOutput)
+		if !ok || specific.PermissionDecision != HookPermissionDecisionDeny {
+			t.Fatalf("Expected %s denied, got %+v", input.ToolName, output)
+		}
+		if want := localize("", MessageDryRunSimulated, input.ToolName); specific.PermissionDecisionReason != want {
+			t.Errorf("Expected reason %q, got %q", want, specific.PermissionDecisionReason)
+		}
+	}
+
+	// Read-only tools run, including those passed to WithDryRun
+	for _, name := range []string{"Read", "Grep", "mcp__docs__search"} {
+		output, _ := hook(context.Background(), PreToolUseHookInput{ToolName: name}, "r", HookContext{})
+		if output.HookSpecificOutput != nil {
+			t.Errorf("Expected %s to run, got %+v", name, output)
+		}
+	}
+
+	plan := d.plan()
+	if len(plan.Actions) != 4 || plan.Actions[0].ToolUseID != "a" || plan.Actions[3].ToolName != "mcp__db__insert" {
+		t.Fatalf("Expected the four simulated calls in order, got %+v", plan.Actions)
+	}
+	if files := plan.Files(); !slices.Equal(files, []string{"a.go"}) {
+		t.Errorf("Expected a.go written once, got %v", files)
+	}
+	if commands := plan.Commands(); !slices.Equal(commands, []string{"go test ./..."}) {
+		t.Errorf("Expected the Bash command, got %v", commands)
+	}
+
+	d.reset()
+	if plan := d.plan(); len(plan.Actions) != 0 {
+		t.Errorf("Expected an empty plan after reset, got %+v", plan.Actions)
+	}
+}
+
+func TestDryRun_WithHook(t *testing.T) {
+	own := func(ctx context.Context, input HookInput, toolUseID string, hookCtx HookContext) (HookOutput, error) {
+		return HookOutput{}, nil
+	}
+	hooks := map[HookEvent][]HookMatcher{
+		HookEventPreToolUse: {{Matcher: "Bash", Hooks: []HookCallback{own}}},
+	}
+
+	var d *dryRun
+	if merged := d.withHook(hooks); len(merged[HookEventPreToolUse]) != 1 {
+		t.Errorf("Expected the hooks unchanged without a dry run, got %+v", merged)
+	}
+
+	d = newDryRun(NewOptions(WithDryRun()))
+	merged := d.withHook(hooks)
+	if len(merged[HookEventPreToolUse]) != 2 || merged[HookEventPreToolUse][1].Matcher != "" {
+		t.Errorf("Expected the dry run hook added for every tool, got %+v", merged[HookEventPreToolUse])
+	}
+	if len(hooks[HookEventPreToolUse]) != 1 {
+		t.Error("Expected the hooks passed in left unchanged")
+	}
+}
@@ -4,6 +4,10 @@ package claude
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/transport"
 )
 
 // ClaudeSDKError is the base error type for all Claude SDK errors.
@@ -65,14 +69,45 @@ func NewCLINotFoundError(message string, cliPath string) *CLINotFoundError {
 	}
 }
 
+// ProcessErrorKind classifies why the CLI process failed.
+type ProcessErrorKind string
+
+const (
+	// ProcessErrorKindUnknown is a failure the SDK does not recognize.
+	ProcessErrorKindUnknown ProcessErrorKind = ""
+	// ProcessErrorKindAuthentication means the CLI has no valid
+	// credentials and needs a login or an API key.
+	ProcessErrorKindAuthentication ProcessErrorKind = "authentication"
+	// ProcessErrorKindInvalidFlag means the CLI rejected a command-line
+	// flag, usually because it is older than the SDK.
+	ProcessErrorKindInvalidFlag ProcessErrorKind = "invalid_flag"
+	// ProcessErrorKindInvalidModel means the requested model does not
+	// exist or is not available.
+	ProcessErrorKindInvalidModel ProcessErrorKind = "invalid_model"
+	// ProcessErrorKindUpdateRequired means the CLI must be updated.
+	ProcessErrorKindUpdateRequired ProcessErrorKind = "update_required"
+)
+
 // ProcessError is raised when the CLI process fails.
 type ProcessError struct {
 	ClaudeSDKError
 	ExitCode int
 	Stderr   string
+
+	// Kind classifies the failure from the error the CLI reported, as a
+	// JSON payload or as text. Hint suggests how to fix it, and DocURL
+	// links to documentation, when known.
+	Kind   ProcessErrorKind
+	Hint   string
+	DocURL string
+
+	// PartialMessages holds the assistant and user messages of the turn
+	// the process failed in, so callers can keep the work done so far.
+	PartialMessages []Message
 }
 
-// NewProcessError creates a new ProcessError.
+// NewProcessError creates a new ProcessError, classifying the failure from
+// stderr.
 func NewProcessError(message string, exitCode int, stderr string) *ProcessError {
 	fullMessage := message
 	if exitCode != 0 {
@@ -81,10 +116,14 @@ func NewProcessError(message string, exitCode int, stderr string) *ProcessError
 	if stderr != "" {
 		fullMessage = fmt.Sprintf("%s\nError output: %s", fullMessage, stderr)
 	}
+	failure := parseProcessFailure(stderr)
 	return &ProcessError{
 		ClaudeSDKError: ClaudeSDKError{Message: fullMessage},
 		ExitCode:       exitCode,
 		Stderr:         stderr,
+		Kind:           failure.kind,
+		Hint:           failure.hint,
+		DocURL:         failure.docURL,
 	}
 }
 
@@ -125,6 +164,293 @@ func NewMessageParseError(message string, data map[string]any) *MessageParseErro
 	}
 }
 
+// StallError is returned when a stalled turn ends a query or session.
+type StallError struct {
+	ClaudeSDKError
+	Event *DiagnosticEvent
+}
+
+// NewStallError creates a new StallError for the stall event.
+func NewStallError(event *DiagnosticEvent) *StallError {
+	return &StallError{
+		ClaudeSDKError: ClaudeSDKError{
+			Message: fmt.Sprintf("turn stalled: no message for %s", event.Idle.Round(time.Millisecond)),
+		},
+		Event: event,
+	}
+}
+
+// SchemaValidationError is returned when structured output is missing or
+// does not match the JSON schema set with WithJSONSchema.
+type SchemaValidationError struct {
+	ClaudeSDKError
+	// Output is the structured output received, nil if missing.
+	Output any
+	// Errors describes each violation of the schema.
+	Errors []string
+}
+
+// NewSchemaValidationError creates a new SchemaValidationError.
+func NewSchemaValidationError(output any, violations []string) *SchemaValidationError {
+	return &SchemaValidationError{
+		ClaudeSDKError: ClaudeSDKError{
+			Message: "structured output does not match schema: " + strings.Join(violations, "; "),
+		},
+		Output: output,
+		Errors: violations,
+	}
+}
+
+// RateLimitError is reported when a turn fails because a rate or usage
+// limit was reached.
+type RateLimitError struct {
+	ClaudeSDKError
+	// ResetAt is when the limit resets, zero if the CLI did not say.
+	ResetAt time.Time
+}
+
+// NewRateLimitError creates a new RateLimitError.
+func NewRateLimitError(resetAt time.Time) *RateLimitError {
+	message := "rate limit reached"
+	if !resetAt.IsZero() {
+		message += "; resets at " + resetAt.Format(time.RFC3339)
+	}
+	return &RateLimitError{
+		ClaudeSDKError: ClaudeSDKError{Message: message},
+		ResetAt:        resetAt,
+	}
+}
+
+// AssistantError is reported when a turn is aborted by the handler set
+// with WithAssistantErrorHandler.
+type AssistantError struct {
+	ClaudeSDKError
+	Kind AssistantMessageError
+	// Message is the assistant message that failed.
+	Message *AssistantMessage
+}
+
+// NewAssistantError creates a new AssistantError.
+func NewAssistantError(kind AssistantMessageError, message *AssistantMessage) *AssistantError {
+	return &AssistantError{
+		ClaudeSDKError: ClaudeSDKError{Message: fmt.Sprintf("turn aborted on assistant error: %s", kind)},
+		Kind:           kind,
+		Message:        message,
+	}
+}
+
+// MCPPreflightError is returned when MCP servers checked with
+// WithMCPPreflight or PreflightMCPServers are missing or broken.
+type MCPPreflightError struct {
+	ClaudeSDKError
+	Failures []MCPServerFailure
+}
+
+// NewMCPPreflightError creates a new MCPPreflightError.
+func NewMCPPreflightError(failures []MCPServerFailure) *MCPPreflightError {
+	parts := make([]string, len(failures))
+	for i, f := range failures {
+		parts[i] = fmt.Sprintf("%s: %v", f.Name, f.Err)
+	}
+	return &MCPPreflightError{
+		ClaudeSDKError: ClaudeSDKError{
+			Message: "MCP servers failed preflight: " + strings.Join(parts, "; "),
+		},
+		Failures: failures,
+	}
+}
+
+// IdleTimeoutError is reported when a Client is closed because its
+// session was idle for longer than the timeout set with WithIdleTimeout.
+type IdleTimeoutError struct {
+	ClaudeSDKError
+	// SessionID is the session the next Connect resumes, empty if no
+	// session was started.
+	SessionID string
+	// Idle is how long the session was idle.
+	Idle time.Duration
+}
+
+// NewIdleTimeoutError creates a new IdleTimeoutError.
+func NewIdleTimeoutError(sessionID string, idle time.Duration) *IdleTimeoutError {
+	return &IdleTimeoutError{
+		ClaudeSDKError: ClaudeSDKError{
+			Message: fmt.Sprintf("connection closed after %s idle", idle.Round(time.Millisecond)),
+		},
+		SessionID: sessionID,
+		Idle:      idle,
+	}
+}
+
+// UnsupportedBetaError is returned by Connect when betas set with
+// WithBetas are not among those the CLI reports it supports.
+type UnsupportedBetaError struct {
+	ClaudeSDKError
+	// Betas are the requested betas the CLI does not support.
+	Betas []SdkBeta
+	// Available are the betas the CLI supports.
+	Available []SdkBeta
+}
+
+// NewUnsupportedBetaError creates a new UnsupportedBetaError.
+func NewUnsupportedBetaError(betas, available []SdkBeta) *UnsupportedBetaError {
+	names := make([]string, len(betas))
+	for i, beta := range betas {
+		names[i] = string(beta)
+	}
+	return &UnsupportedBetaError{
+		ClaudeSDKError: ClaudeSDKError{
+			Message: "unsupported betas: " + strings.Join(names, ", "),
+		},
+		Betas:     betas,
+		Available: available,
+	}
+}
+
+// ToolMismatchError is reported on the Errors channel of a Client when
+// the tools and MCP servers named in its options differ from those the
+// CLI reports at the start of the session, as when a tool name has a typo
+// and Claude would never use it. It is a warning: the session goes on.
+type ToolMismatchError struct {
+	ClaudeSDKError
+	// Unknown are the tool names from WithTools, WithAllowedTools, and
+	// WithDisallowedTools that the CLI does not offer.
+	Unknown []string
+	// MissingMCPServers are the configured MCP servers the CLI does not
+	// report as connected.
+	MissingMCPServers []string
+}
+
+// NewToolMismatchError creates a new ToolMismatchError.
+func NewToolMismatchError(unknown, missingMCPServers []string) *ToolMismatchError {
+	var parts []string
+	if len(unknown) > 0 {
+		parts = append(parts, "unknown tools: "+strings.Join(unknown, ", "))
+	}
+	if len(missingMCPServers) > 0 {
+		parts = append(parts, "MCP servers not connected: "+strings.Join(missingMCPServers, ", "))
+	}
+	return &ToolMismatchError{
+		ClaudeSDKError: ClaudeSDKError{
+			Message: strings.Join(parts, "; "),
+		},
+		Unknown:           unknown,
+		MissingMCPServers: missingMCPServers,
+	}
+}
+
+// ReconnectRequiredError is returned by Client.UpdateOptions when options
+// cannot be changed while connected.
+type ReconnectRequiredError struct {
+	ClaudeSDKError
+	// Options are the names of the Options fields that need a new
+	// connection, such as "SystemPrompt".
+	Options []string
+}
+
+// NewReconnectRequiredError creates a new ReconnectRequiredError.
+func NewReconnectRequiredError(options []string) *ReconnectRequiredError {
+	return &ReconnectRequiredError{
+		ClaudeSDKError: ClaudeSDKError{
+			Message: "options require reconnecting: " + strings.Join(options, ", "),
+		},
+		Options: options,
+	}
+}
+
+// BufferLimitKind identifies the size limit a BufferLimitError tripped.
+type BufferLimitKind string
+
+const (
+	// BufferLimitMessage is the limit on messages from the CLI, set with
+	// WithMaxBufferSize.
+	BufferLimitMessage BufferLimitKind = "message"
+	// BufferLimitStderrLine is the limit on lines of stderr, set with
+	// WithMaxStderrLineSize.
+	BufferLimitStderrLine BufferLimitKind = "stderr_line"
+	// BufferLimitControlResponse is the limit on control responses, set
+	// with WithMaxControlResponseSize.
+	BufferLimitControlResponse BufferLimitKind = "control_response"
+)
+
+// BufferLimitError reports output of the CLI that exceeded a size limit.
+// An oversized message or control response ends the stream; an oversized
+// stderr line is truncated to the limit and reported on the Client's
+// error channel.
+type BufferLimitError struct {
+	ClaudeSDKError
+	// Kind is the limit that tripped.
+	Kind BufferLimitKind
+	// Limit is the limit in bytes and Size the size of the output.
+	Limit int
+	Size  int
+}
+
+// NewBufferLimitError creates a new BufferLimitError.
+func NewBufferLimitError(kind BufferLimitKind, limit, size int) *BufferLimitError {
+	return &BufferLimitError{
+		ClaudeSDKError: ClaudeSDKError{
+			Message: fmt.Sprintf("%s exceeded maximum size of %d bytes (size: %d)", kind, limit, size),
+		},
+		Kind:  kind,
+		Limit: limit,
+		Size:  size,
+	}
+}
+
+// BusyError is returned by Client.Query and Client.QueryMessage when a
+// turn is in progress and the client is configured with
+// WithBusyBehavior(BusyBehaviorReject).
+type BusyError struct {
+	ClaudeSDKError
+}
+
+// NewBusyError creates a new BusyError.
+func NewBusyError() *BusyError {
+	return &BusyError{
+		ClaudeSDKError: ClaudeSDKError{
+			Message: "a turn is already in progress",
+		},
+	}
+}
+
+// WorkspaceBusyError is returned when the working directory is locked by
+// another Client or process and WithWorkspaceLock is set to
+// WorkspaceLockReject, or to WorkspaceLockQueue and the context ended
+// while waiting, in which case Cause is the context's error.
+type WorkspaceBusyError struct {
+	ClaudeSDKError
+	// Cwd is the working directory that is locked.
+	Cwd string
+	// PID is the process holding the lock, or 0 if unknown.
+	PID int
+}
+
+// NewWorkspaceBusyError creates a new WorkspaceBusyError.
+func NewWorkspaceBusyError(cwd string, pid int) *WorkspaceBusyError {
+	message := "workspace " + cwd + " is in use"
+	if pid > 0 {
+		message = fmt.Sprintf("workspace %s is in use by process %d", cwd, pid)
+	}
+	return &WorkspaceBusyError{
+		ClaudeSDKError: ClaudeSDKError{
+			Message: message,
+		},
+		Cwd: cwd,
+		PID: pid,
+	}
+}
+
+// publicError converts the transport's errors that have a public
+// counterpart, leaving others unchanged.
+func publicError(err error) error {
+	var limitErr *transport.BufferLimitError
+	if errors.As(err, &limitErr) {
+		return NewBufferLimitError(BufferLimitKind(limitErr.Kind), limitErr.Limit, limitErr.Size)
+	}
+	return err
+}
+
 // IsConnectionError reports whether err is a CLIConnectionError.
 func IsConnectionError(err error) bool {
 	var connErr *CLIConnectionError
@@ -204,3 +530,195 @@ func AsMessageParseError(err error) (*MessageParseError, bool) {
 	}
 	return nil, false
 }
+
+// IsStallError reports whether err is a StallError.
+func IsStallError(err error) bool {
+	var stallErr *StallError
+	return errors.As(err, &stallErr)
+}
+
+// AsStallError extracts a StallError from err.
+// Returns the error and true if found, nil and false otherwise.
+func AsStallError(err error) (*StallError, bool) {
+	var stallErr *StallError
+	if errors.As(err, &stallErr) {
+		return stallErr, true
+	}
+	return nil, false
+}
+
+// IsSchemaValidationError reports whether err is a SchemaValidationError.
+func IsSchemaValidationError(err error) bool {
+	var schemaErr *SchemaValidationError
+	return errors.As(err, &schemaErr)
+}
+
+// AsSchemaValidationError extracts a SchemaValidationError from err.
+// Returns the error and true if found, nil and false otherwise.
+func AsSchemaValidationError(err error) (*SchemaValidationError, bool) {
+	var schemaErr *SchemaValidationError
+	if errors.As(err, &schemaErr) {
+		return schemaErr, true
+	}
+	return nil, false
+}
+
+// IsRateLimitError reports whether err is a RateLimitError.
+func IsRateLimitError(err error) bool {
+	var rateErr *RateLimitError
+	return errors.As(err, &rateErr)
+}
+
+// AsRateLimitError extracts a RateLimitError from err.
+// Returns the error and true if found, nil and false otherwise.
+func AsRateLimitError(err error) (*RateLimitError, bool) {
+	var rateErr *RateLimitError
+	if errors.As(err, &rateErr) {
+		return rateErr, true
+	}
+	return nil, false
+}
+
+// IsAssistantError reports whether err is an AssistantError.
+func IsAssistantError(err error) bool {
+	var assistantErr *AssistantError
+	return errors.As(err, &assistantErr)
+}
+
+// AsAssistantError extracts an AssistantError from err.
+// Returns the error and true if found, nil and false otherwise.
+func AsAssistantError(err error) (*AssistantError, bool) {
+	var assistantErr *AssistantError
+	if errors.As(err, &assistantErr) {
+		return assistantErr, true
+	}
+	return nil, false
+}
+
+// IsMCPPreflightError reports whether err is an MCPPreflightError.
+func IsMCPPreflightError(err error) bool {
+	var preflightErr *MCPPreflightError
+	return errors.As(err, &preflightErr)
+}
+
+// AsMCPPreflightError extracts an MCPPreflightError from err.
+// Returns the error and true if found, nil and false otherwise.
+func AsMCPPreflightError(err error) (*MCPPreflightError, bool) {
+	var preflightErr *MCPPreflightError
+	if errors.As(err, &preflightErr) {
+		return preflightErr, true
+	}
+	return nil, false
+}
+
+// IsIdleTimeoutError reports whether err is an IdleTimeoutError.
+func IsIdleTimeoutError(err error) bool {
+	var idleErr *IdleTimeoutError
+	return errors.As(err, &idleErr)
+}
+
+// AsIdleTimeoutError extracts an IdleTimeoutError from err.
+// Returns the error and true if found, nil and false otherwise.
+func AsIdleTimeoutError(err error) (*IdleTimeoutError, bool) {
+	var idleErr *IdleTimeoutError
+	if errors.As(err, &idleErr) {
+		return idleErr, true
+	}
+	return nil, false
+}
+
+// IsUnsupportedBetaError reports whether err is an UnsupportedBetaError.
+func IsUnsupportedBetaError(err error) bool {
+	var betaErr *UnsupportedBetaError
+	return errors.As(err, &betaErr)
+}
+
+// AsUnsupportedBetaError extracts an UnsupportedBetaError from err.
+// Returns the error and true if found, nil and false otherwise.
+func AsUnsupportedBetaError(err error) (*UnsupportedBetaError, bool) {
+	var betaErr *UnsupportedBetaError
+	if errors.As(err, &betaErr) {
+		return betaErr, true
+	}
+	return nil, false
+}
+
+// IsToolMismatchError reports whether err is a ToolMismatchError.
+func IsToolMismatchError(err error) bool {
+	var mismatchErr *ToolMismatchError
+	return errors.As(err, &mismatchErr)
+}
+
+// AsToolMismatchError extracts a ToolMismatchError from err.
+// Returns the error and true if found, nil and false otherwise.
+func AsToolMismatchError(err error) (*ToolMismatchError, bool) {
+	var mismatchErr *ToolMismatchError
+	if errors.As(err, &mismatchErr) {
+		return mismatchErr, true
+	}
+	return nil, false
+}
+
+// IsReconnectRequiredError reports whether err is a ReconnectRequiredError.
+func IsReconnectRequiredError(err error) bool {
+	var reconnectErr *ReconnectRequiredError
+	return errors.As(err, &reconnectErr)
+}
+
+// AsReconnectRequiredError extracts a ReconnectRequiredError from err.
+// Returns the error and true if found, nil and false otherwise.
+func AsReconnectRequiredError(err error) (*ReconnectRequiredError, bool) {
+	var reconnectErr *ReconnectRequiredError
+	if errors.As(err, &reconnectErr) {
+		return reconnectErr, true
+	}
+	return nil, false
+}
+
+// IsBufferLimitError reports whether err is a BufferLimitError.
+func IsBufferLimitError(err error) bool {
+	var limitErr *BufferLimitError
+	return errors.As(err, &limitErr)
+}
+
+// AsBufferLimitError extracts a BufferLimitError from err.
+// Returns the error and true if found, nil and false otherwise.
+func AsBufferLimitError(err error) (*BufferLimitError, bool) {
+	var limitErr *BufferLimitError
+	if errors.As(err, &limitErr) {
+		return limitErr, true
+	}
+	return nil, false
+}
+
+// IsBusyError reports whether err is a BusyError.
+func IsBusyError(err error) bool {
+	var busyErr *BusyError
+	return errors.As(err, &busyErr)
+}
+
+// AsBusyError extracts a BusyError from err.
+// Returns the error and true if found, nil and false otherwise.
+func AsBusyError(err error) (*BusyError, bool) {
+	var busyErr *BusyError
+	if errors.As(err, &busyErr) {
+		return busyErr, true
+	}
+	return nil, false
+}
+
+// IsWorkspaceBusyError reports whether err is a WorkspaceBusyError.
+func IsWorkspaceBusyError(err error) bool {
+	var busyErr *WorkspaceBusyError
+	return errors.As(err, &busyErr)
+}
+
+// AsWorkspaceBusyError extracts a WorkspaceBusyError from err.
+// Returns the error and true if found, nil and false otherwise.
+func AsWorkspaceBusyError(err error) (*WorkspaceBusyError, bool) {
+	var busyErr *WorkspaceBusyError
+	if errors.As(err, &busyErr) {
+		return busyErr, true
+	}
+	return nil, false
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestClaudeSDKError_Error(t *testing.T) {
@@ -802,3 +803,52 @@ func TestMessageParseError_DeepNesting(t *testing.T) {
 		t.Errorf("Expected Data['deep']='data', got '%v'", parseErr.Data["deep"])
 	}
 }
+
+func TestBufferLimitError(t *testing.T) {
+	err := WrapClaudeSDKError("stream failed", NewBufferLimitError(BufferLimitControlResponse, 1024, 4096))
+	if !IsBufferLimitError(err) {
+		t.Fatal("Expected IsBufferLimitError to find the wrapped BufferLimitError")
+	}
+	limitErr, _ := AsBufferLimitError(err)
+	if limitErr.Kind != BufferLimitControlResponse || limitErr.Limit != 1024 || limitErr.Size != 4096 {
+		t.Errorf("Unexpected error fields %+v", limitErr)
+	}
+	if !strings.Contains(limitErr.Error(), "control_response exceeded maximum size of 1024 bytes") {
+		t.Errorf("Unexpected message %q", limitErr.Error())
+	}
+	if IsBufferLimitError(errors.New("other")) {
+		t.Error("Expected IsBufferLimitError to be false for other errors")
+	}
+}
+
+func TestAsStallError(t *testing.T) {
+	event := &DiagnosticEvent{Kind: DiagnosticKindStall, Idle: 90 * time.Second, Action: StallActionClose}
+	err := WrapClaudeSDKError("query failed", NewStallError(event))
+	stallErr, ok := AsStallError(err)
+	if !ok || stallErr.Event.Idle != 90*time.Second || stallErr.Event.Action != StallActionClose {
+		t.Fatalf("Expected the wrapped StallError, got %v", err)
+	}
+	if stallErr.Error() != "turn stalled: no message for 1m30s" {
+		t.Errorf("Unexpected message %q", stallErr.Error())
+	}
+	if _, ok := AsStallError(NewClaudeSDKError("other")); ok {
+		t.Error("Expected AsStallError to be false for other errors")
+	}
+}
+
+func TestWorkspaceBusyError(t *testing.T) {
+	err := WrapClaudeSDKError("connect failed", NewWorkspaceBusyError("/repo", 42))
+	busyErr, ok := AsWorkspaceBusyError(err)
+	if !ok || busyErr.Cwd != "/repo" || busyErr.PID != 42 {
+		t.Fatalf("Expected the wrapped WorkspaceBusyError, got %v", err)
+	}
+	if busyErr.Error() != "workspace /repo is in use by process 42" {
+		t.Errorf("Unexpected message %q", busyErr.Error())
+	}
+	if msg := NewWorkspaceBusyError("/repo", 0).Error(); msg != "workspace /repo is in use" {
+		t.Errorf("Unexpected message without a holder %q", msg)
+	}
+	if IsWorkspaceBusyError(NewBusyError()) {
+		t.Error("Expected IsWorkspaceBusyError to be false for other errors")
+	}
+}
@@ -0,0 +1,82 @@
+package claude
+
+import (
+	"errors"
+	"sync"
+)
+
+// errorSink delivers the errors of a connection on its error channel.
+//
+// Sending never blocks message processing and no error is dropped: errors
+// raised while an earlier one is still unread are held back, and delivered
+// joined with errors.Join once the channel is free or the connection ends.
+type errorSink struct {
+	mu      sync.Mutex
+	ch      chan error
+	pending []error
+	closed  bool
+}
+
+func newErrorSink(ch chan error) *errorSink {
+	return &errorSink{ch: ch}
+}
+
+// add records err and delivers pending errors if the channel is free.
+// Errors added after close are discarded.
+func (s *errorSink) add(err error) {
+	if err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.pending = append(s.pending, err)
+	s.flush()
+}
+
+// flush delivers pending errors if the channel is free. Called with mu held.
+func (s *errorSink) flush() {
+	if len(s.pending) == 0 {
+		return
+	}
+	select {
+	case s.ch <- joinErrors(s.pending):
+		s.pending = nil
+	default:
+	}
+}
+
+// close delivers all remaining errors, merging them with any error still
+// unread on the channel, and closes the channel.
+func (s *errorSink) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+
+	if len(s.pending) > 0 {
+		select {
+		case unread := <-s.ch:
+			s.pending = append([]error{unread}, s.pending...)
+		default:
+		}
+		s.flush()
+	}
+	close(s.ch)
+}
+
+// joinErrors joins errs, returning a single error as is so that it can
+// still be inspected with a type assertion.
+func joinErrors(errs []error) error {
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,158 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestErrorSink_DeliversWhenFree(t *testing.T) {
+	ch := make(chan error, 1)
+	sink := newErrorSink(ch)
+
+	first := errors.New("first")
+	sink.add(first)
+	if got := <-ch; got != first {
+		t.Errorf("Expected first error as is, got %v", got)
+	}
+
+	second := errors.New("second")
+	sink.add(second)
+	if got := <-ch; got != second {
+		t.Errorf("Expected second error as is, got %v", got)
+	}
+}
+
+func TestErrorSink_JoinsUnreadErrors(t *testing.T) {
+	ch := make(chan error, 1)
+	sink := newErrorSink(ch)
+
+	first := errors.New("first")
+	second := errors.New("second")
+	third := errors.New("third")
+	sink.add(first)
+	sink.add(second)
+	sink.add(third)
+
+	// The first error was delivered; the others wait for the channel
+	if got := <-ch; got != first {
+		t.Errorf("Expected first error, got %v", got)
+	}
+
+	sink.close()
+	got, ok := <-ch
+	if !ok {
+		t.Fatal("Expected pending errors before the channel closes")
+	}
+	if !errors.Is(got, second) || !errors.Is(got, third) {
+		t.Errorf("Expected joined second and third errors, got %v", got)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("Expected channel to be closed")
+	}
+}
+
+func TestErrorSink_CloseMergesUnread(t *testing.T) {
+	ch := make(chan error, 1)
+	sink := newErrorSink(ch)
+
+	first := errors.New("first")
+	second := errors.New("second")
+	sink.add(first)
+	sink.add(second)
+	sink.close()
+
+	got := <-ch
+	if got.Error() != "first\nsecond" {
+		t.Errorf("Expected errors joined in order, got %q", got)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("Expected channel to be closed")
+	}
+}
+
+func TestErrorSink_IgnoresNilAndClosed(t *testing.T) {
+	ch := make(chan error, 1)
+	sink := newErrorSink(ch)
+
+	sink.add(nil)
+	sink.close()
+	sink.close()
+	sink.add(errors.New("late"))
+
+	if err, ok := <-ch; ok {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestClient_ErrorsNotDropped(t *testing.T) {
+	// Two unparseable messages and a crash, with nobody reading Errors()
+	// until Messages() closes
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+read line
+echo '{"type":"assistant","message":{"content":[]}}'
+echo '{"type":"user"}'
+echo '{"type":"assistant","message":{"model":"m","content":[{"type":"text","text":"hi"}]}}'
+exit 1
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.Query(ctx, "Hello"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	var assistant int
+	for msg := range client.Messages() {
+		if _, ok := msg.(*AssistantMessage); ok {
+			assistant++
+		}
+	}
+	if assistant != 1 {
+		t.Errorf("Expected processing to continue past errors, got %d assistant messages", assistant)
+	}
+
+	// Once Messages() is closed, all errors are readable without blocking
+	var errs []error
+	for err := range client.Errors() {
+		errs = append(errs, err)
+	}
+	joined := errors.Join(errs...)
+
+	parseErr, ok := AsMessageParseError(joined)
+	if !ok {
+		t.Fatalf("Expected MessageParseError, got %v", joined)
+	}
+	if parseErr.Data["type"] != "assistant" {
+		t.Errorf("Expected the first parse error first, got %v", parseErr.Data)
+	}
+	if procErr, ok := AsProcessError(joined); !ok || procErr.ExitCode != 1 {
+		t.Errorf("Expected ProcessError with exit code 1, got %v", joined)
+	}
+	if got := len(flattenErrors(joined)); got != 3 {
+		t.Errorf("Expected 3 errors, got %d: %v", got, joined)
+	}
+}
+
+// flattenErrors lists the errors joined in err.
+func flattenErrors(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var errs []error
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, flattenErrors(e)...)
+		}
+		return errs
+	}
+	return []error{err}
+}
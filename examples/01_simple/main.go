@@ -51,12 +51,7 @@ func main() {
 			switch m := msg.(type) {
 			case *claude.AssistantMessage:
 				// Print text content from assistant messages
-				for _, block := range m.Content {
-					if text, ok := block.(claude.TextBlock); ok {
-						fmt.Print(text.Text)
-					}
-				}
-				fmt.Println()
+				fmt.Println(m.Text())
 
 			case *claude.ResultMessage:
 				// Print cost information when the query completes
@@ -36,7 +36,7 @@ func main() {
 	// Create client with options
 	client := claude.NewClient(
 	// claude.WithCwd("/path/to/project"), // Uncomment to set working directory
-	// claude.WithModel("claude-sonnet-4-5"), // Uncomment to set model
+	// claude.WithModel(claude.ModelSonnet), // Uncomment to set model
 	)
 
 	// Connect to Claude
@@ -87,7 +87,7 @@ func main() {
 		claude.WithMCPServers(map[string]claude.MCPServerConfig{
 			"calculator": calculatorServer,
 		}),
-		claude.WithAllowedTools([]string{"add", "multiply", "sqrt"}),
+		claude.WithMCPServerAllowed("calculator"),
 	)
 
 	// Connect
@@ -42,31 +42,14 @@ func main() {
 	}()
 
 	// Define the JSON schema for our expected output
-	schema := map[string]any{
-		"type": "object",
-		"properties": map[string]any{
-			"name": map[string]any{
-				"type":        "string",
-				"description": "The person's full name",
-			},
-			"age": map[string]any{
-				"type":        "integer",
-				"description": "The person's age in years",
-			},
-			"occupation": map[string]any{
-				"type":        "string",
-				"description": "The person's job or profession",
-			},
-			"skills": map[string]any{
-				"type": "array",
-				"items": map[string]any{
-					"type": "string",
-				},
-				"description": "List of skills the person has",
-			},
-		},
-		"required": []string{"name", "age", "occupation", "skills"},
-	}
+	sb := claude.Schema()
+	schema := sb.Object().
+		Prop("name", sb.String().Desc("The person's full name")).
+		Prop("age", sb.Integer().Desc("The person's age in years")).
+		Prop("occupation", sb.String().Desc("The person's job or profession")).
+		Prop("skills", sb.Array(sb.String()).Desc("List of skills the person has")).
+		Required("name", "age", "occupation", "skills").
+		Build()
 
 	// Create client with JSON schema output format
 	client := claude.NewClient(
@@ -32,20 +32,21 @@ func main() {
 		cancel()
 	}()
 
-	// Create a temporary directory for this example
-	tmpDir, err := os.MkdirTemp("", "checkpointing-example-*")
+	// Create a temporary workspace for this example, with checkpointing
+	// enabled and writes confined to it
+	workspace, err := claude.NewTempWorkspace("checkpointing-example-*")
 	if err != nil {
-		log.Fatalf("Failed to create temp dir: %v\n", err)
+		log.Fatalf("Failed to create workspace: %v\n", err)
 	}
-	defer func() { _ = os.RemoveAll(tmpDir) }()
+	defer func() { _ = workspace.Close() }()
+	workspace.Checkpointing = true
 
-	fmt.Printf("Working directory: %s\n\n", tmpDir)
+	fmt.Printf("Working directory: %s\n\n", workspace.Dir)
 
 	// Create client with file checkpointing enabled
 	client := claude.NewClient(
-		claude.WithCwd(tmpDir),
-		claude.WithFileCheckpointing(), // Enable checkpointing
-		// Also available as: claude.WithEnableFileCheckpointing(true)
+		claude.WithWorkspace(workspace),
+		// Also available as: claude.WithCwd(dir), claude.WithFileCheckpointing()
 	)
 
 	fmt.Println("Connecting to Claude Code with file checkpointing enabled...")
@@ -106,7 +107,7 @@ func main() {
 	}
 
 	// Verify the file was created
-	content, err := os.ReadFile(tmpDir + "/hello.txt")
+	content, err := os.ReadFile(workspace.Dir + "/hello.txt")
 	if err != nil {
 		fmt.Printf("\nFile not created (expected if Claude didn't have permission)\n")
 	} else {
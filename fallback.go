@@ -0,0 +1,233 @@
+package claude
+
+import "sync"
+
+// ErrorActionKind is the kind of an ErrorAction.
+type ErrorActionKind string
+
+const (
+	// ErrorActionContinue handles the error as without a handler: the
+	// message is delivered, and the turn is retried with the next model of
+	// WithModelFallbacks if the error is a rate limit or server error.
+	ErrorActionContinue ErrorActionKind = ""
+	// ErrorActionRetry sends the turn's prompt again with the same model
+	// once the turn ends.
+	ErrorActionRetry ErrorActionKind = "retry"
+	// ErrorActionSwitchModel switches to Model and sends the turn's prompt
+	// again once the turn ends.
+	ErrorActionSwitchModel ErrorActionKind = "switch_model"
+	// ErrorActionAbort ends the turn with an AssistantError, skipping
+	// model fallbacks, structured output repairs and auto-continue.
+	ErrorActionAbort ErrorActionKind = "abort"
+)
+
+// ErrorAction tells the SDK what to do about an assistant message that
+// failed, as returned by the handler set with WithAssistantErrorHandler.
+type ErrorAction struct {
+	Kind ErrorActionKind
+	// Model is the model to switch to with ErrorActionSwitchModel, the
+	// default model if empty.
+	Model string
+}
+
+// The actions of an assistant error handler.
+var (
+	ActionContinue = ErrorAction{Kind: ErrorActionContinue}
+	ActionRetry    = ErrorAction{Kind: ErrorActionRetry}
+	ActionAbort    = ErrorAction{Kind: ErrorActionAbort}
+)
+
+// ActionSwitchModel returns the action switching to model and retrying the
+// turn.
+func ActionSwitchModel(model string) ErrorAction {
+	return ErrorAction{Kind: ErrorActionSwitchModel, Model: model}
+}
+
+// modelFailover walks the chain of Options.Model followed by
+// Options.ModelFallbacks, moving to the next model when a turn fails with
+// a rate limit or server error, or takes the action of the assistant
+// error handler. It also records which model served each turn.
+type modelFailover struct {
+	mu      sync.Mutex
+	chain   []string
+	current int
+	locale  string
+	handler func(AssistantMessageError, *AssistantMessage) ErrorAction
+
+	// failed is the error that ended the current attempt, if no assistant
+	// message succeeded after it, and action what the handler decided
+	// about it. failed is only set for errors that are retried by default
+	// or that the handler acted on.
+	failed AssistantMessageError
+	action ErrorAction
+	// failedMessage is the message of failed, for an AssistantError.
+	failedMessage *AssistantMessage
+	// switched is the model the handler switched to, if any, which stands
+	// in for the current model of the chain until the next turn.
+	switched *string
+	// aborted is the error the turn was aborted with, until taken.
+	aborted *AssistantError
+	// served is the model of the last successful assistant message.
+	served string
+}
+
+func newModelFailover(o *Options) *modelFailover {
+	return &modelFailover{
+		chain:   append([]string{o.Model}, o.ModelFallbacks...),
+		locale:  o.Locale,
+		handler: o.AssistantErrorHandler,
+	}
+}
+
+// observe records the outcome of assistant messages, asking the handler
+// what to do about those that failed.
+func (f *modelFailover) observe(msg Message) {
+	m, ok := msg.(*AssistantMessage)
+	if !ok {
+		return
+	}
+
+	// The handler may call back into the client
+	action := ActionContinue
+	if m.Error != "" && f.handler != nil {
+		action = f.handler(m.Error, m)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case m.Error == "":
+		f.failed, f.action, f.failedMessage = "", ActionContinue, nil
+		if m.Model != "" {
+			f.served = m.Model
+		}
+	case action.Kind != ErrorActionContinue,
+		m.Error == AssistantMessageErrorRateLimit, m.Error == AssistantMessageErrorServerError:
+		f.failed, f.action, f.failedMessage = m.Error, action, m
+	}
+}
+
+// next returns the model to retry the turn that produced result with, and
+// a DiagnosticEvent describing the retry: that of the handler's action, or
+// the next model of the chain if the attempt failed with a retryable error
+// and the chain has models left. If the handler aborted the turn, next
+// returns false and aborted returns the error.
+func (f *modelFailover) next(result *ResultMessage) (string, *DiagnosticEvent, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	failed, action, message := f.failed, f.action, f.failedMessage
+	f.failed, f.action, f.failedMessage = "", ActionContinue, nil
+	if failed == "" || result.IsInterrupted() {
+		return "", nil, false
+	}
+
+	from := f.model()
+	switch action.Kind {
+	case ErrorActionRetry:
+		event := &DiagnosticEvent{
+			Kind:    DiagnosticKindRetry,
+			Message: localize(f.locale, MessageModelFallback, failed, modelName(from), modelName(from)),
+		}
+		return from, event, true
+	case ErrorActionSwitchModel:
+		to := action.Model
+		f.switched = &to
+		event := &DiagnosticEvent{
+			Kind:    DiagnosticKindModelFallback,
+			Message: localize(f.locale, MessageModelFallback, failed, modelName(from), modelName(to)),
+		}
+		return to, event, true
+	case ErrorActionAbort:
+		f.aborted = NewAssistantError(failed, message)
+		return "", nil, false
+	}
+
+	if f.current+1 >= len(f.chain) {
+		return "", nil, false
+	}
+	f.current++
+	f.switched = nil
+	to := f.chain[f.current]
+	event := &DiagnosticEvent{
+		Kind:    DiagnosticKindModelFallback,
+		Message: localize(f.locale, MessageModelFallback, failed, modelName(from), modelName(to)),
+	}
+	return to, event, true
+}
+
+// takeAborted returns the error the handler aborted the turn with, if
+// any, and clears it.
+func (f *modelFailover) takeAborted() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.aborted == nil {
+		return nil
+	}
+	err := f.aborted
+	f.aborted = nil
+	return err
+}
+
+// model returns the model of the current attempt. f.mu must be held.
+func (f *modelFailover) model() string {
+	if f.switched != nil {
+		return *f.switched
+	}
+	return f.chain[f.current]
+}
+
+// annotate sets the model that served result's turn.
+func (f *modelFailover) annotate(result *ResultMessage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.served != "" {
+		result.Model = f.served
+	} else {
+		result.Model = f.model()
+	}
+}
+
+// reset starts a new turn at the head of the chain. It returns the model
+// to switch back to, if a previous turn fell back.
+func (f *modelFailover) reset() (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	restore := f.current != 0 || f.switched != nil
+	f.current = 0
+	f.switched = nil
+	f.failed, f.action, f.failedMessage = "", ActionContinue, nil
+	f.aborted = nil
+	f.served = ""
+	return f.chain[0], restore
+}
+
+// setPrimary replaces the head of the chain, after the model is changed
+// with Client.SetModel.
+func (f *modelFailover) setPrimary(model string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.chain[0] = model
+	f.current = 0
+	f.switched = nil
+}
+
+// primary returns the head of the chain, the model turns start with.
+func (f *modelFailover) primary() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.chain[0]
+}
+
+// modelName describes a model of the chain for messages.
+func modelName(model string) string {
+	if model == "" {
+		return "the default model"
+	}
+	return model
+}
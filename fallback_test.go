@@ -0,0 +1,330 @@
+package claude
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestModelFailover(t *testing.T) {
+	f := newModelFailover(NewOptions(WithModel(ModelOpus), WithModelFallbacks([]string{ModelSonnet, ModelHaiku})))
+
+	// A successful turn is served by the primary model
+	f.observe(&AssistantMessage{Model: "claude-opus-4-1-20250805"})
+	result := &ResultMessage{}
+	if _, _, ok := f.next(result); ok {
+		t.Fatal("Expected no failover after a successful turn")
+	}
+	f.annotate(result)
+	if result.Model != "claude-opus-4-1-20250805" {
+		t.Errorf("Expected served model, got %q", result.Model)
+	}
+
+	f.reset()
+	f.observe(&AssistantMessage{Error: AssistantMessageErrorRateLimit})
+	model, event, ok := f.next(&ResultMessage{IsError: true})
+	if !ok || model != ModelSonnet {
+		t.Fatalf("Expected failover to sonnet, got %q, %v", model, ok)
+	}
+	if event.Kind != DiagnosticKindModelFallback || event.Message != "rate_limit from opus; retrying with sonnet" {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+
+	// Errors other than rate limits and server errors are not retried
+	f.observe(&AssistantMessage{Error: AssistantMessageErrorInvalidRequest})
+	if _, _, ok := f.next(&ResultMessage{IsError: true}); ok {
+		t.Error("Expected no failover for an invalid request")
+	}
+
+	f.observe(&AssistantMessage{Error: AssistantMessageErrorServerError})
+	if model, _, ok := f.next(&ResultMessage{IsError: true}); !ok || model != ModelHaiku {
+		t.Fatalf("Expected failover to haiku, got %q, %v", model, ok)
+	}
+	f.observe(&AssistantMessage{Error: AssistantMessageErrorServerError})
+	if _, _, ok := f.next(&ResultMessage{IsError: true}); ok {
+		t.Error("Expected no failover once the chain is exhausted")
+	}
+
+	result = &ResultMessage{IsError: true}
+	f.annotate(result)
+	if result.Model != ModelHaiku {
+		t.Errorf("Expected the last model tried, got %q", result.Model)
+	}
+
+	if primary, restore := f.reset(); !restore || primary != ModelOpus {
+		t.Errorf("Expected to restore opus, got %q, %v", primary, restore)
+	}
+	if _, restore := f.reset(); restore {
+		t.Error("Expected nothing to restore at the head of the chain")
+	}
+}
+
+func TestModelFailover_RecoveredError(t *testing.T) {
+	f := newModelFailover(NewOptions(WithModelFallbacks([]string{ModelHaiku})))
+
+	// The CLI retried on its own and the turn went on
+	f.observe(&AssistantMessage{Error: AssistantMessageErrorRateLimit})
+	f.observe(&AssistantMessage{Model: "claude-sonnet-4-5"})
+	if _, _, ok := f.next(&ResultMessage{}); ok {
+		t.Error("Expected no failover after a later successful message")
+	}
+}
+
+func TestQuery_ModelFallbacks(t *testing.T) {
+	cli := writeStubCLI(t, `
+case "$*" in
+*"--resume sess-1"*"--model claude-haiku-4-5"*|*"--model claude-haiku-4-5"*"--resume sess-1"*)
+	echo '{"type":"assistant","message":{"model":"claude-haiku-4-5-20251001","content":[{"type":"text","text":"Hi"}]}}'
+	echo '{"type":"result","subtype":"success","session_id":"sess-1"}' ;;
+*)
+	echo '{"type":"assistant","message":{"model":"<synthetic>","error":"rate_limit","content":[{"type":"text","text":"Rate limited"}]}}'
+	echo '{"type":"result","subtype":"success","is_error":true,"session_id":"sess-1"}' ;;
+esac
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	messages, errs := Query(ctx, "Hi", WithCLIPath(cli),
+		WithModel(ModelOpus), WithModelFallbacks([]string{"claude-haiku-4-5"}))
+
+	var results []*ResultMessage
+	var events []*DiagnosticEvent
+	for msg := range messages {
+		switch m := msg.(type) {
+		case *ResultMessage:
+			results = append(results, m)
+		case *DiagnosticEvent:
+			events = append(events, m)
+		}
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(events) != 1 || !strings.Contains(events[0].Message, "retrying with claude-haiku-4-5") {
+		t.Errorf("Expected one fallback event, got %+v", events)
+	}
+	if len(results) != 1 || results[0].IsError || results[0].Model != "claude-haiku-4-5-20251001" {
+		t.Fatalf("Expected one result served by haiku, got %+v", results)
+	}
+}
+
+func TestClient_ModelFallbacks(t *testing.T) {
+	cli := writeStubCLI(t, `
+respond() {
+	id=$(echo "$1" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+	echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+}
+read line; respond "$line"
+read line
+echo '{"type":"assistant","message":{"model":"<synthetic>","error":"server_error","content":[{"type":"text","text":"Overloaded"}]}}'
+echo '{"type":"result","subtype":"success","is_error":true,"session_id":"s"}'
+read line
+case "$line" in
+*'"model":"claude-haiku-4-5","subtype":"set_model"'*) respond "$line" ;;
+*) exit 1 ;;
+esac
+read line
+echo '{"type":"assistant","message":{"model":"claude-haiku-4-5-20251001","content":[{"type":"text","text":"Done"}]}}'
+echo '{"type":"result","subtype":"success","session_id":"s"}'
+cat > /dev/null
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli), WithModelFallbacks([]string{"claude-haiku-4-5"}))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.Query(ctx, "Do it"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	var results []*ResultMessage
+	var fallbacks int
+	for msg := range client.ReceiveResponse(ctx) {
+		switch m := msg.(type) {
+		case *ResultMessage:
+			results = append(results, m)
+		case *DiagnosticEvent:
+			if m.Kind == DiagnosticKindModelFallback {
+				fallbacks++
+			}
+		}
+	}
+
+	if fallbacks != 1 {
+		t.Errorf("Expected one fallback event, got %d", fallbacks)
+	}
+	if len(results) != 1 || results[0].Model != "claude-haiku-4-5-20251001" {
+		t.Fatalf("Expected one result served by haiku, got %+v", results)
+	}
+}
+
+func TestModelFailover_ErrorHandler(t *testing.T) {
+	var kinds []AssistantMessageError
+	f := newModelFailover(NewOptions(WithModel(ModelOpus), WithModelFallbacks([]string{ModelHaiku}),
+		WithAssistantErrorHandler(func(kind AssistantMessageError, msg *AssistantMessage) ErrorAction {
+			kinds = append(kinds, kind)
+			switch kind {
+			case AssistantMessageErrorServerError:
+				if len(kinds) == 1 {
+					return ActionRetry
+				}
+				return ActionSwitchModel(ModelSonnet)
+			case AssistantMessageErrorBillingError:
+				return ActionAbort
+			}
+			return ActionContinue
+		})))
+
+	f.observe(&AssistantMessage{Error: AssistantMessageErrorServerError})
+	model, event, ok := f.next(&ResultMessage{IsError: true})
+	if !ok || model != ModelOpus || event.Kind != DiagnosticKindRetry {
+		t.Fatalf("Expected a retry with opus, got %q, %+v, %v", model, event, ok)
+	}
+	f.observe(&AssistantMessage{Error: AssistantMessageErrorServerError})
+	model, event, ok = f.next(&ResultMessage{IsError: true})
+	if !ok || model != ModelSonnet || event.Message != "server_error from opus; retrying with sonnet" {
+		t.Fatalf("Expected a switch to sonnet, got %q, %+v, %v", model, event, ok)
+	}
+
+	// Continue falls back along the chain as without a handler
+	f.observe(&AssistantMessage{Error: AssistantMessageErrorRateLimit})
+	model, event, ok = f.next(&ResultMessage{IsError: true})
+	if !ok || model != ModelHaiku || event.Message != "rate_limit from sonnet; retrying with haiku" {
+		t.Fatalf("Expected a fallback to haiku, got %q, %+v, %v", model, event, ok)
+	}
+
+	msg := &AssistantMessage{Error: AssistantMessageErrorBillingError}
+	f.observe(msg)
+	if _, _, ok := f.next(&ResultMessage{IsError: true}); ok {
+		t.Error("Expected no retry for an aborted turn")
+	}
+	err := f.takeAborted()
+	if assistantErr, ok := AsAssistantError(err); !ok || assistantErr.Kind != AssistantMessageErrorBillingError || assistantErr.Message != msg {
+		t.Errorf("Expected an AssistantError for the billing error, got %v", err)
+	}
+	if err := f.takeAborted(); err != nil {
+		t.Errorf("Expected the error taken once, got %v", err)
+	}
+
+	// Errors handled with Continue that are not retried by default are
+	// left alone
+	f.observe(&AssistantMessage{Error: AssistantMessageErrorInvalidRequest})
+	if _, _, ok := f.next(&ResultMessage{IsError: true}); ok {
+		t.Error("Expected no retry for an invalid request")
+	}
+	if len(kinds) != 5 {
+		t.Errorf("Expected the handler called for each failed message, got %v", kinds)
+	}
+	if primary, restore := f.reset(); !restore || primary != ModelOpus {
+		t.Errorf("Expected to restore opus, got %q, %v", primary, restore)
+	}
+}
+
+func TestModelFailover_SwitchModelRestored(t *testing.T) {
+	f := newModelFailover(NewOptions(WithModel(ModelOpus),
+		WithAssistantErrorHandler(func(AssistantMessageError, *AssistantMessage) ErrorAction {
+			return ActionSwitchModel(ModelHaiku)
+		})))
+	f.observe(&AssistantMessage{Error: AssistantMessageErrorServerError})
+	if model, _, ok := f.next(&ResultMessage{IsError: true}); !ok || model != ModelHaiku {
+		t.Fatalf("Expected a switch to haiku, got %q, %v", model, ok)
+	}
+	result := &ResultMessage{}
+	f.annotate(result)
+	if result.Model != ModelHaiku {
+		t.Errorf("Expected the model switched to, got %q", result.Model)
+	}
+	if primary, restore := f.reset(); !restore || primary != ModelOpus {
+		t.Errorf("Expected the next turn to start with opus, got %q, %v", primary, restore)
+	}
+}
+
+func TestClient_AssistantErrorHandler(t *testing.T) {
+	cli := writeStubCLI(t, `
+respond() {
+	id=$(echo "$1" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+	echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+}
+read line; respond "$line"
+read line
+echo '{"type":"assistant","message":{"model":"<synthetic>","error":"server_error","content":[{"type":"text","text":"Overloaded"}]}}'
+echo '{"type":"result","subtype":"success","is_error":true,"session_id":"s"}'
+read line; respond "$line"
+read line
+echo '{"type":"assistant","message":{"model":"<synthetic>","error":"billing_error","content":[{"type":"text","text":"Credit balance is too low"}]}}'
+echo '{"type":"result","subtype":"success","is_error":true,"session_id":"s"}'
+cat > /dev/null
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli), WithAssistantErrorHandler(func(kind AssistantMessageError, msg *AssistantMessage) ErrorAction {
+		if kind == AssistantMessageErrorServerError {
+			return ActionRetry
+		}
+		return ActionAbort
+	}))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.Query(ctx, "Do it"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	var results, retries int
+	for msg := range client.ReceiveResponse(ctx) {
+		switch m := msg.(type) {
+		case *ResultMessage:
+			results++
+		case *DiagnosticEvent:
+			if m.Kind == DiagnosticKindRetry {
+				retries++
+			}
+		}
+	}
+	if results != 1 || retries != 1 {
+		t.Errorf("Expected one retry and one result, got %d and %d", retries, results)
+	}
+	select {
+	case err := <-client.Errors():
+		if assistantErr, ok := AsAssistantError(err); !ok || assistantErr.Kind != AssistantMessageErrorBillingError {
+			t.Errorf("Expected an AssistantError for the billing error, got %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("Expected the turn aborted with an error")
+	}
+}
+
+func TestQuery_AssistantErrorHandler_Abort(t *testing.T) {
+	cli := writeStubCLI(t, `
+echo '{"type":"assistant","message":{"model":"<synthetic>","error":"authentication_failed","content":[{"type":"text","text":"Invalid API key"}]}}'
+echo '{"type":"result","subtype":"success","is_error":true,"session_id":"s"}'
+`)
+
+	messages, errs := Query(context.Background(), "Hi", WithCLIPath(cli), WithModelFallbacks([]string{ModelHaiku}),
+		WithAssistantErrorHandler(func(AssistantMessageError, *AssistantMessage) ErrorAction {
+			return ActionAbort
+		}))
+	var results int
+	for msg := range messages {
+		if _, ok := msg.(*ResultMessage); ok {
+			results++
+		}
+	}
+	if err := <-errs; !IsAssistantError(err) {
+		t.Errorf("Expected an AssistantError, got %v", err)
+	}
+	if results != 1 {
+		t.Errorf("Expected the result delivered, got %d", results)
+	}
+}
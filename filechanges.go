@@ -0,0 +1,203 @@
+package claude
+
+import (
+	"strings"
+	"sync"
+)
+
+// FileChange describes a file modified by one of Claude's Write, Edit, or
+// MultiEdit tool uses.
+type FileChange struct {
+	// ToolUseID is the ID of the tool use that made the change.
+	ToolUseID string
+	// Tool is the name of the tool, such as "Edit".
+	Tool string
+	// Path is the path of the modified file.
+	Path string
+	// Before and After are the file contents before and after the change.
+	// Before is empty for a newly created file.
+	Before string
+	After  string
+	// Partial reports that the original file was not available, so Before
+	// and After hold only the replaced and replacement text of an edit.
+	Partial bool
+	// Diff is a unified diff of Before and After.
+	Diff string
+}
+
+// FileChangeTracker pairs file editing tool uses with their results to
+// record the changes Claude made. It is safe for concurrent use.
+//
+// Example:
+//
+//	tracker := claude.NewFileChangeTracker()
+//	for msg := range messages {
+//	    tracker.Observe(msg)
+//	}
+//	for _, change := range tracker.Changes() {
+//	    fmt.Print(change.Diff)
+//	}
+type FileChangeTracker struct {
+	mu      sync.Mutex
+	pending map[string]ToolUseBlock
+	changes []FileChange
+}
+
+// NewFileChangeTracker creates an empty FileChangeTracker.
+func NewFileChangeTracker() *FileChangeTracker {
+	return &FileChangeTracker{pending: make(map[string]ToolUseBlock)}
+}
+
+// Observe records file editing tool uses in assistant messages and the
+// successful results that complete them in user messages.
+func (t *FileChangeTracker) Observe(msg Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch m := msg.(type) {
+	case *AssistantMessage:
+		for _, block := range m.Content {
+			if toolUse, ok := block.(ToolUseBlock); ok && isFileChangeTool(toolUse.Name) {
+				t.pending[toolUse.ID] = toolUse
+			}
+		}
+	case *UserMessage:
+		blocks := m.GetContentBlocks()
+		for _, block := range blocks {
+			result, ok := block.(ToolResultBlock)
+			if !ok {
+				continue
+			}
+			toolUse, ok := t.pending[result.ToolUseID]
+			if !ok {
+				continue
+			}
+			delete(t.pending, result.ToolUseID)
+			if result.IsError != nil && *result.IsError {
+				continue
+			}
+
+			// The structured result describes the message's only tool result
+			var toolResult map[string]any
+			if len(blocks) == 1 {
+				toolResult = m.ToolUseResult
+			}
+			t.changes = append(t.changes, newFileChange(toolUse, toolResult))
+		}
+	}
+}
+
+// Changes returns the changes recorded so far, in the order they were
+// made.
+func (t *FileChangeTracker) Changes() []FileChange {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]FileChange(nil), t.changes...)
+}
+
+// Reset discards the recorded changes and pending tool uses.
+func (t *FileChangeTracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	clear(t.pending)
+	t.changes = nil
+}
+
+// ExtractFileChanges returns the file changes made by the tool uses in
+// messages.
+func ExtractFileChanges(messages []Message) []FileChange {
+	tracker := NewFileChangeTracker()
+	for _, msg := range messages {
+		tracker.Observe(msg)
+	}
+	return tracker.Changes()
+}
+
+// isFileChangeTool reports whether name is a tool whose changes are
+// tracked.
+func isFileChangeTool(name string) bool {
+	switch ToolName(name) {
+	case ToolWrite, ToolEdit, ToolMultiEdit:
+		return true
+	}
+	return false
+}
+
+// fileEdit is one replacement made by Edit or MultiEdit.
+type fileEdit struct {
+	oldString  string
+	newString  string
+	replaceAll bool
+}
+
+// newFileChange builds the change made by a tool use. toolResult is the
+// CLI's structured tool result, which holds the original file when known.
+func newFileChange(toolUse ToolUseBlock, toolResult map[string]any) FileChange {
+	change := FileChange{
+		ToolUseID: toolUse.ID,
+		Tool:      toolUse.Name,
+	}
+	change.Path, _ = toolUse.Input["file_path"].(string)
+	original, hasOriginal := toolResult["originalFile"].(string)
+
+	switch ToolName(toolUse.Name) {
+	case ToolWrite:
+		change.Before = original
+		change.After, _ = toolUse.Input["content"].(string)
+	case ToolEdit, ToolMultiEdit:
+		edits := toolEdits(toolUse)
+		if hasOriginal {
+			change.Before = original
+			change.After = applyEdits(original, edits)
+		} else {
+			change.Partial = true
+			var before, after []string
+			for _, e := range edits {
+				before = append(before, e.oldString)
+				after = append(after, e.newString)
+			}
+			change.Before = strings.Join(before, "\n")
+			change.After = strings.Join(after, "\n")
+		}
+	}
+
+	change.Diff = unifiedDiff(change.Path, change.Before, change.After)
+	return change
+}
+
+// toolEdits returns the replacements requested by an Edit or MultiEdit
+// tool use.
+func toolEdits(toolUse ToolUseBlock) []fileEdit {
+	if toolUse.Name == string(ToolEdit) {
+		return []fileEdit{parseFileEdit(toolUse.Input)}
+	}
+
+	var edits []fileEdit
+	items, _ := toolUse.Input["edits"].([]any)
+	for _, item := range items {
+		if input, ok := item.(map[string]any); ok {
+			edits = append(edits, parseFileEdit(input))
+		}
+	}
+	return edits
+}
+
+func parseFileEdit(input map[string]any) fileEdit {
+	var e fileEdit
+	e.oldString, _ = input["old_string"].(string)
+	e.newString, _ = input["new_string"].(string)
+	e.replaceAll, _ = input["replace_all"].(bool)
+	return e
+}
+
+// applyEdits applies edits to content in order.
+func applyEdits(content string, edits []fileEdit) string {
+	for _, e := range edits {
+		if e.replaceAll {
+			content = strings.ReplaceAll(content, e.oldString, e.newString)
+		} else {
+			content = strings.Replace(content, e.oldString, e.newString, 1)
+		}
+	}
+	return content
+}
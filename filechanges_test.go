@@ -0,0 +1,124 @@
+package claude
+
+import "testing"
+
+func toolUse(id, name string, input map[string]any) *AssistantMessage {
+	return &AssistantMessage{Content: []ContentBlock{ToolUseBlock{ID: id, Name: name, Input: input}}}
+}
+
+func toolResult(id string, isError bool, structured map[string]any) *UserMessage {
+	return &UserMessage{
+		Content:       []ContentBlock{ToolResultBlock{ToolUseID: id, IsError: &isError}},
+		ToolUseResult: structured,
+	}
+}
+
+func TestExtractFileChanges_Write(t *testing.T) {
+	changes := ExtractFileChanges([]Message{
+		toolUse("t1", "Write", map[string]any{"file_path": "/p/new.txt", "content": "hello\n"}),
+		toolResult("t1", false, map[string]any{"type": "create", "filePath": "/p/new.txt"}),
+	})
+
+	if len(changes) != 1 {
+		t.Fatalf("Expected 1 change, got %d", len(changes))
+	}
+	change := changes[0]
+	if change.Path != "/p/new.txt" || change.Tool != "Write" || change.ToolUseID != "t1" {
+		t.Errorf("Unexpected change: %+v", change)
+	}
+	if change.Before != "" || change.After != "hello\n" {
+		t.Errorf("Expected creation of 'hello', got %q -> %q", change.Before, change.After)
+	}
+	want := "--- a/p/new.txt\n+++ b/p/new.txt\n@@ -0,0 +1 @@\n+hello\n"
+	if change.Diff != want {
+		t.Errorf("Expected diff:\n%s\ngot:\n%s", want, change.Diff)
+	}
+}
+
+func TestExtractFileChanges_EditWithOriginal(t *testing.T) {
+	changes := ExtractFileChanges([]Message{
+		toolUse("t1", "Edit", map[string]any{
+			"file_path":  "main.go",
+			"old_string": "foo",
+			"new_string": "bar",
+		}),
+		toolResult("t1", false, map[string]any{"originalFile": "foo\nfoo\n"}),
+	})
+
+	if len(changes) != 1 {
+		t.Fatalf("Expected 1 change, got %d", len(changes))
+	}
+	if changes[0].Partial {
+		t.Error("Expected full change when the original file is known")
+	}
+	if changes[0].After != "bar\nfoo\n" {
+		t.Errorf("Expected first occurrence replaced, got %q", changes[0].After)
+	}
+}
+
+func TestExtractFileChanges_MultiEdit(t *testing.T) {
+	edits := []any{
+		map[string]any{"old_string": "a", "new_string": "b", "replace_all": true},
+		map[string]any{"old_string": "b\nc", "new_string": "d"},
+	}
+
+	changes := ExtractFileChanges([]Message{
+		toolUse("t1", "MultiEdit", map[string]any{"file_path": "f", "edits": edits}),
+		toolResult("t1", false, map[string]any{"originalFile": "a\na\nc\n"}),
+		toolUse("t2", "MultiEdit", map[string]any{"file_path": "g", "edits": edits}),
+		toolResult("t2", false, nil),
+	})
+
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %d", len(changes))
+	}
+	if changes[0].After != "b\nd\n" {
+		t.Errorf("Expected edits applied in order, got %q", changes[0].After)
+	}
+	partial := changes[1]
+	if !partial.Partial || partial.Before != "a\nb\nc" || partial.After != "b\nd" {
+		t.Errorf("Expected partial change of edit fragments, got %+v", partial)
+	}
+}
+
+func TestFileChangeTracker_SkipsFailuresAndOtherTools(t *testing.T) {
+	tracker := NewFileChangeTracker()
+	tracker.Observe(toolUse("t1", "Edit", map[string]any{"file_path": "f", "old_string": "a", "new_string": "b"}))
+	tracker.Observe(toolResult("t1", true, nil))
+	tracker.Observe(toolUse("t2", "Read", map[string]any{"file_path": "f"}))
+	tracker.Observe(toolResult("t2", false, nil))
+	tracker.Observe(toolResult("unknown", false, nil))
+
+	if changes := tracker.Changes(); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %+v", changes)
+	}
+}
+
+func TestFileChangeTracker_Reset(t *testing.T) {
+	tracker := NewFileChangeTracker()
+	tracker.Observe(toolUse("t1", "Write", map[string]any{"file_path": "f", "content": "x"}))
+	tracker.Observe(toolResult("t1", false, nil))
+	tracker.Observe(toolUse("t2", "Write", map[string]any{"file_path": "g", "content": "y"}))
+
+	tracker.Reset()
+	tracker.Observe(toolResult("t2", false, nil))
+
+	if changes := tracker.Changes(); len(changes) != 0 {
+		t.Errorf("Expected no changes after reset, got %+v", changes)
+	}
+}
+
+func TestClient_PendingChanges(t *testing.T) {
+	client := NewClient()
+	client.changes.Observe(toolUse("t1", "Write", map[string]any{"file_path": "f", "content": "x"}))
+	client.changes.Observe(toolResult("t1", false, nil))
+
+	if got := len(client.PendingChanges()); got != 1 {
+		t.Fatalf("Expected 1 pending change, got %d", got)
+	}
+
+	client.startTurn(t.Context())
+	if got := len(client.PendingChanges()); got != 0 {
+		t.Errorf("Expected pending changes cleared by a new turn, got %d", got)
+	}
+}
@@ -0,0 +1,433 @@
+package claude
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileOpsServerName is the name of the server returned by FileOpsServer.
+// Its tools are "mcp__files__read_file" and so on, see MCPToolRef.
+const FileOpsServerName = "files"
+
+// fileOpsMaxRead is the size above which read_file returns part of a file.
+const fileOpsMaxRead = 256 * 1024
+
+// FileOpsServer returns an in-process MCP server whose tools read, list,
+// write, edit and patch the files under root:
+//
+//   - read_file reads a file, optionally a range of its lines
+//   - list_files lists a directory
+//   - write_file creates or replaces a file
+//   - edit_file replaces an exact string in a file
+//   - apply_patch applies a unified diff to one or more files
+//
+// Paths are relative to root, or absolute within it. The tools cannot
+// reach outside root, through ".." or symbolic links alike: files are
+// accessed through an os.Root. The tools that change files return a
+// unified diff of each change, so that they can be audited, such as from a
+// PostToolUse hook. A patch is checked against all its files before any
+// is changed.
+//
+// It suits deployments that disable the CLI's file tools but still let
+// Claude change files in a controlled directory.
+//
+// Example:
+//
+//	client := claude.NewClient(
+//	    claude.WithMCPServers(map[string]claude.MCPServerConfig{
+//	        claude.FileOpsServerName: claude.FileOpsServer("/srv/checkout"),
+//	    }),
+//	    claude.WithDisallowedTools([]string{"Read", "Write", "Edit", "MultiEdit", "NotebookEdit"}),
+//	    claude.WithMCPServerAllowed(claude.FileOpsServerName),
+//	)
+func FileOpsServer(root string) MCPSDKServerConfig {
+	f := fileOps{root: root}
+	server := NewMCPServer(FileOpsServerName, "1.0.0", []MCPTool{
+		Tool("read_file", "Read a text file. Paths are relative to the root directory. Large files are returned in part; use offset and limit to read ranges of lines.",
+			objectSchema(map[string]any{
+				"path":   stringProperty("Path of the file"),
+				"offset": integerProperty("Line to start reading at, from 1"),
+				"limit":  integerProperty("Number of lines to read"),
+			}, "path"), f.readFile),
+		Tool("list_files", "List the entries of a directory. Directories end with a slash.",
+			objectSchema(map[string]any{
+				"path": stringProperty("Path of the directory; the root directory if empty"),
+			}), f.listFiles),
+		Tool("write_file", "Create a file, or replace its contents, creating parent directories as needed. Returns a diff of the change.",
+			objectSchema(map[string]any{
+				"path":    stringProperty("Path of the file"),
+				"content": stringProperty("New contents of the file"),
+			}, "path", "content"), f.writeFile),
+		Tool("edit_file", "Replace an exact string in a file. old_string must occur exactly once unless replace_all is set. Returns a diff of the change.",
+			objectSchema(map[string]any{
+				"path":        stringProperty("Path of the file"),
+				"old_string":  stringProperty("Text to replace, including enough context to be unique"),
+				"new_string":  stringProperty("Text to replace it with"),
+				"replace_all": map[string]any{"type": "boolean", "description": "Replace every occurrence"},
+			}, "path", "old_string", "new_string"), f.editFile),
+		Tool("apply_patch", "Apply a unified diff, as made by diff -u or git diff, to one or more files. Use /dev/null as the old path to create a file and as the new path to delete one. List each file once. No file is changed unless the patch applies to every file.",
+			objectSchema(map[string]any{
+				"patch": stringProperty("The unified diff"),
+			}, "patch"), f.applyPatch),
+	})
+	return MCPSDKServerConfig{
+		Type:   "sdk",
+		Name:   FileOpsServerName,
+		Server: server.ValidateInputs(true),
+	}
+}
+
+// objectSchema returns the JSON schema of an object with properties, of
+// which required are required.
+func objectSchema(properties map[string]any, required ...string) map[string]any {
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// stringProperty and integerProperty return the schemas of properties.
+func stringProperty(description string) map[string]any {
+	return map[string]any{"type": "string", "description": description}
+}
+
+func integerProperty(description string) map[string]any {
+	return map[string]any{"type": "integer", "description": description}
+}
+
+// fileOps implements the tools of FileOpsServer.
+type fileOps struct {
+	root string
+}
+
+// open opens the root directory. It is opened for each call, so that the
+// server keeps no descriptor open and follows the directory if replaced.
+func (f fileOps) open() (*os.Root, error) {
+	root, err := os.OpenRoot(f.root)
+	if err != nil {
+		return nil, fmt.Errorf("opening root directory: %w", err)
+	}
+	return root, nil
+}
+
+// name converts path to a name relative to the root. Absolute paths must
+// be within the root.
+func (f fileOps) name(path string) (string, error) {
+	if path == "" {
+		return ".", nil
+	}
+	if !filepath.IsAbs(path) {
+		return filepath.Clean(path), nil
+	}
+	root, err := filepath.Abs(f.root)
+	if err != nil {
+		return "", err
+	}
+	abs := filepath.Clean(path)
+	if !withinDir(abs, root) {
+		// The path may reach the root through symbolic links, as on
+		// macOS, where /tmp is /private/tmp
+		root, abs = resolveWorkspacePath(root, ""), resolveWorkspacePath(abs, "")
+	}
+	if !withinDir(abs, root) {
+		return "", fmt.Errorf("path %s is outside the root directory", path)
+	}
+	rel, _ := filepath.Rel(root, abs)
+	return rel, nil
+}
+
+// call runs fn with the root and the name of the path argument, converting
+// errors to error results for Claude.
+func (f fileOps) call(args map[string]any, fn func(root *os.Root, name string) (MCPToolResult, error)) (MCPToolResult, error) {
+	path, _ := args["path"].(string)
+	name, err := f.name(path)
+	if err != nil {
+		return ErrorResult(err.Error()), nil
+	}
+	root, err := f.open()
+	if err != nil {
+		return ErrorResult(err.Error()), nil
+	}
+	defer func() { _ = root.Close() }()
+	result, err := fn(root, name)
+	if err != nil {
+		return ErrorResult(err.Error()), nil
+	}
+	return result, nil
+}
+
+func (f fileOps) readFile(ctx context.Context, args map[string]any) (MCPToolResult, error) {
+	return f.call(args, func(root *os.Root, name string) (MCPToolResult, error) {
+		file, err := root.Open(name)
+		if err != nil {
+			return MCPToolResult{}, err
+		}
+		defer func() { _ = file.Close() }()
+		if info, err := file.Stat(); err == nil && info.IsDir() {
+			return MCPToolResult{}, fmt.Errorf("%s is a directory; use list_files", name)
+		}
+
+		offset, _ := args["offset"].(float64)
+		limit, _ := args["limit"].(float64)
+		if offset <= 0 && limit <= 0 {
+			data, err := io.ReadAll(io.LimitReader(file, fileOpsMaxRead+1))
+			if err != nil {
+				return MCPToolResult{}, err
+			}
+			if len(data) > fileOpsMaxRead {
+				return TextResult(fmt.Sprintf("%s\n[truncated at %d bytes; use offset and limit to read more]", data[:fileOpsMaxRead], fileOpsMaxRead)), nil
+			}
+			return TextResult(string(data)), nil
+		}
+
+		// Read no further than the lines asked for
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(nil, fileOpsMaxRead)
+		scanner.Split(scanFileLines)
+		start := max(int(offset), 1)
+		var lines []string
+		size := 0
+		for n := 1; (limit <= 0 || n < start+int(limit)) && size <= fileOpsMaxRead && scanner.Scan(); n++ {
+			if n >= start {
+				lines = append(lines, scanner.Text())
+				size += len(scanner.Bytes()) + 1
+			}
+		}
+		if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+			return MCPToolResult{}, fmt.Errorf("%s has a line longer than %d bytes; read it without offset and limit", name, fileOpsMaxRead)
+		} else if scanner.Err() != nil {
+			return MCPToolResult{}, scanner.Err()
+		}
+		text := strings.Join(lines, "\n")
+		if len(text) > fileOpsMaxRead {
+			text = text[:fileOpsMaxRead] + fmt.Sprintf("\n[truncated at %d bytes]", fileOpsMaxRead)
+		}
+		return TextResult(text), nil
+	})
+}
+
+// scanFileLines is a bufio.SplitFunc for lines ending in "\n", which,
+// unlike bufio.ScanLines, keeps a "\r" before it as read_file does for
+// whole files.
+func scanFileLines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+func (f fileOps) listFiles(ctx context.Context, args map[string]any) (MCPToolResult, error) {
+	return f.call(args, func(root *os.Root, name string) (MCPToolResult, error) {
+		entries, err := fs.ReadDir(root.FS(), filepath.ToSlash(name))
+		if err != nil {
+			return MCPToolResult{}, err
+		}
+		names := make([]string, len(entries))
+		for i, entry := range entries {
+			names[i] = entry.Name()
+			if entry.IsDir() {
+				names[i] += "/"
+			}
+		}
+		return TextResult(strings.Join(names, "\n")), nil
+	})
+}
+
+func (f fileOps) writeFile(ctx context.Context, args map[string]any) (MCPToolResult, error) {
+	return f.call(args, func(root *os.Root, name string) (MCPToolResult, error) {
+		content, _ := args["content"].(string)
+		before, exists, err := readRootFile(root, name)
+		if err != nil {
+			return MCPToolResult{}, err
+		}
+		action := "modified"
+		if !exists {
+			action = "created"
+		}
+		if err := writeRootFile(root, name, content); err != nil {
+			return MCPToolResult{}, err
+		}
+		return JSONResult(fileChange(name, action, before, content))
+	})
+}
+
+func (f fileOps) editFile(ctx context.Context, args map[string]any) (MCPToolResult, error) {
+	return f.call(args, func(root *os.Root, name string) (MCPToolResult, error) {
+		oldString, _ := args["old_string"].(string)
+		newString, _ := args["new_string"].(string)
+		replaceAll, _ := args["replace_all"].(bool)
+		before, exists, err := readRootFile(root, name)
+		if err != nil {
+			return MCPToolResult{}, err
+		}
+		if !exists {
+			return MCPToolResult{}, fmt.Errorf("%s does not exist; use write_file to create it", name)
+		}
+
+		switch n := strings.Count(before, oldString); {
+		case oldString == "" || oldString == newString:
+			return MCPToolResult{}, errors.New("old_string must not be empty or equal to new_string")
+		case n == 0:
+			return MCPToolResult{}, fmt.Errorf("old_string was not found in %s", name)
+		case n > 1 && !replaceAll:
+			return MCPToolResult{}, fmt.Errorf("old_string occurs %d times in %s; add context to make it unique, or set replace_all", n, name)
+		}
+		after := strings.Replace(before, oldString, newString, 1)
+		if replaceAll {
+			after = strings.ReplaceAll(before, oldString, newString)
+		}
+		if err := writeRootFile(root, name, after); err != nil {
+			return MCPToolResult{}, err
+		}
+		return JSONResult(fileChange(name, "modified", before, after))
+	})
+}
+
+func (f fileOps) applyPatch(ctx context.Context, args map[string]any) (MCPToolResult, error) {
+	text, _ := args["patch"].(string)
+	patches, err := parsePatch(text)
+	if err != nil {
+		return ErrorResult("invalid patch: " + err.Error()), nil
+	}
+	root, err := f.open()
+	if err != nil {
+		return ErrorResult(err.Error()), nil
+	}
+	defer func() { _ = root.Close() }()
+
+	// Work out every change before making any
+	type pending struct {
+		name, removed string
+		action        string
+		before, after string
+	}
+	var changes []pending
+	// Each hunk applies to the contents before the patch, so a file may
+	// only appear once
+	touched := map[string]bool{}
+	touch := func(name string) error {
+		if touched[name] {
+			return fmt.Errorf("%s appears more than once in the patch; combine its changes", name)
+		}
+		touched[name] = true
+		return nil
+	}
+	for _, patch := range patches {
+		path, created := patch.oldPath, patch.oldPath == devNull
+		if created {
+			path = patch.newPath
+		}
+		name, err := f.name(path)
+		if err != nil {
+			return ErrorResult(err.Error()), nil
+		}
+		if err := touch(name); err != nil {
+			return ErrorResult(err.Error()), nil
+		}
+		before, exists, err := readRootFile(root, name)
+		if err != nil {
+			return ErrorResult(err.Error()), nil
+		}
+		switch {
+		case created && exists:
+			return ErrorResult(fmt.Sprintf("%s already exists", name)), nil
+		case !created && !exists:
+			return ErrorResult(fmt.Sprintf("%s does not exist", name)), nil
+		}
+		after, err := applyHunks(before, patch.hunks)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("%s: %v", name, err)), nil
+		}
+
+		change := pending{name: name, action: "modified", before: before, after: after}
+		switch {
+		case created:
+			change.action = "created"
+		case patch.newPath == devNull:
+			if after != "" {
+				return ErrorResult(fmt.Sprintf("%s: deleting a file must remove all its lines", name)), nil
+			}
+			change.name, change.removed, change.action = "", name, "deleted"
+		case patch.newPath != patch.oldPath:
+			if change.name, err = f.name(patch.newPath); err != nil {
+				return ErrorResult(err.Error()), nil
+			}
+			if err := touch(change.name); err != nil {
+				return ErrorResult(err.Error()), nil
+			}
+			if _, err := root.Lstat(change.name); err == nil {
+				return ErrorResult(fmt.Sprintf("cannot rename %s to %s, which already exists", name, change.name)), nil
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return ErrorResult(err.Error()), nil
+			}
+			change.removed, change.action = name, "renamed"
+		}
+		changes = append(changes, change)
+	}
+
+	var files []map[string]any
+	for _, change := range changes {
+		if change.name != "" {
+			if err := writeRootFile(root, change.name, change.after); err != nil {
+				return ErrorResult(fmt.Sprintf("%v; applied to %d of %d files", err, len(files), len(changes))), nil
+			}
+		}
+		if change.removed != "" {
+			if err := root.Remove(change.removed); err != nil {
+				return ErrorResult(fmt.Sprintf("%v; applied to %d of %d files", err, len(files), len(changes))), nil
+			}
+		}
+		path := change.name
+		if path == "" {
+			path = change.removed
+		}
+		files = append(files, fileChange(path, change.action, change.before, change.after))
+	}
+	return JSONResult(map[string]any{"files": files})
+}
+
+// readRootFile reads the file name under root, reporting whether it exists.
+func readRootFile(root *os.Root, name string) (string, bool, error) {
+	data, err := root.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return string(data), true, nil
+}
+
+// writeRootFile writes the file name under root, creating its directory.
+// An existing file keeps its permissions.
+func writeRootFile(root *os.Root, name, content string) error {
+	if dir := filepath.Dir(name); dir != "." {
+		if err := root.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	return root.WriteFile(name, []byte(content), 0o644)
+}
+
+// fileChange describes a change to the file at path, for a tool result:
+// its action, "created", "modified", "renamed" or "deleted", and its diff.
+func fileChange(path, action, before, after string) map[string]any {
+	path = filepath.ToSlash(path)
+	return map[string]any{
+		"path":   path,
+		"action": action,
+		"diff":   unifiedDiff(path, before, after),
+	}
+}
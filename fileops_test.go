@@ -0,0 +1,173 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// callFileOps calls tool of a FileOpsServer on root, returning the text
+// of its result and whether it is an error.
+func callFileOps(t *testing.T, root, tool string, args map[string]any) (string, bool) {
+	t.Helper()
+	for _, candidate := range FileOpsServer(root).Server.Tools() {
+		if candidate.Name == tool {
+			result, err := candidate.Handler(context.Background(), args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return result.Content[0].Text, result.IsError
+		}
+	}
+	t.Fatalf("No tool %s", tool)
+	return "", false
+}
+
+func TestFileOpsServer_ReadAndList(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "src"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "src", "main.go"), []byte("package main\n\nfunc main() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if text, isErr := callFileOps(t, root, "read_file", map[string]any{"path": "src/main.go"}); isErr || text != "package main\n\nfunc main() {}\n" {
+		t.Errorf("Expected the file read, got %q", text)
+	}
+	abs := filepath.Join(root, "src", "main.go")
+	if text, _ := callFileOps(t, root, "read_file", map[string]any{"path": abs, "offset": float64(3), "limit": float64(1)}); text != "func main() {}" {
+		t.Errorf("Expected a line read by absolute path, got %q", text)
+	}
+	if text, _ := callFileOps(t, root, "read_file", map[string]any{"path": "src/main.go", "offset": float64(2)}); text != "\nfunc main() {}" {
+		t.Errorf("Expected the rest of the file read, got %q", text)
+	}
+	long := strings.Repeat("x", fileOpsMaxRead/2) + "\n"
+	if err := os.WriteFile(filepath.Join(root, "long.txt"), []byte("first\n"+strings.Repeat(long, 4)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if text, _ := callFileOps(t, root, "read_file", map[string]any{"path": "long.txt", "offset": float64(2)}); !strings.HasSuffix(text, fmt.Sprintf("[truncated at %d bytes]", fileOpsMaxRead)) {
+		t.Errorf("Expected a long range truncated, got %d bytes", len(text))
+	}
+	if text, _ := callFileOps(t, root, "list_files", map[string]any{}); text != "long.txt\nsrc/" {
+		t.Errorf("Expected the root listed, got %q", text)
+	}
+	if text, isErr := callFileOps(t, root, "read_file", map[string]any{"path": "src"}); !isErr || !strings.Contains(text, "list_files") {
+		t.Errorf("Expected reading a directory to fail, got %q", text)
+	}
+}
+
+func TestFileOpsServer_Escape(t *testing.T) {
+	root, outside := t.TempDir(), t.TempDir()
+	secret := filepath.Join(outside, "secret")
+	if err := os.WriteFile(secret, []byte("s3cret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"../" + filepath.Base(outside) + "/secret", secret, "link/secret"} {
+		if text, isErr := callFileOps(t, root, "read_file", map[string]any{"path": path}); !isErr {
+			t.Errorf("Expected %s not to be read, got %q", path, text)
+		}
+		if _, isErr := callFileOps(t, root, "write_file", map[string]any{"path": path, "content": "x"}); !isErr {
+			t.Errorf("Expected %s not to be written", path)
+		}
+	}
+	patch := "--- a/link/secret\n+++ b/link/secret\n@@ -1 +1 @@\n-s3cret\n+leaked\n"
+	if _, isErr := callFileOps(t, root, "apply_patch", map[string]any{"patch": patch}); !isErr {
+		t.Error("Expected a patch through a link not to apply")
+	}
+	if data, _ := os.ReadFile(secret); string(data) != "s3cret" {
+		t.Errorf("Expected the file outside the root unchanged, got %q", data)
+	}
+}
+
+func TestFileOpsServer_WriteAndEdit(t *testing.T) {
+	root := t.TempDir()
+
+	text, isErr := callFileOps(t, root, "write_file", map[string]any{"path": "a/b.txt", "content": "one\ntwo\n"})
+	if isErr || !strings.Contains(text, `"action":"created"`) || !strings.Contains(text, `+one`) {
+		t.Fatalf("Expected the file created with a diff, got %q", text)
+	}
+
+	text, isErr = callFileOps(t, root, "edit_file", map[string]any{"path": "a/b.txt", "old_string": "two", "new_string": "2"})
+	if isErr || !strings.Contains(text, `"action":"modified"`) || !strings.Contains(text, `-two\n+2`) {
+		t.Errorf("Expected the file edited with a diff, got %q", text)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "a", "b.txt")); string(data) != "one\n2\n" {
+		t.Errorf("Expected the edit written, got %q", data)
+	}
+
+	callFileOps(t, root, "write_file", map[string]any{"path": "c.txt", "content": "x x\n"})
+	if text, isErr := callFileOps(t, root, "edit_file", map[string]any{"path": "c.txt", "old_string": "x", "new_string": "y"}); !isErr || !strings.Contains(text, "2 times") {
+		t.Errorf("Expected an ambiguous edit to fail, got %q", text)
+	}
+	if _, isErr := callFileOps(t, root, "edit_file", map[string]any{"path": "c.txt", "old_string": "x", "new_string": "y", "replace_all": true}); isErr {
+		t.Error("Expected replace_all to edit every occurrence")
+	}
+	if text, isErr := callFileOps(t, root, "edit_file", map[string]any{"path": "missing.txt", "old_string": "x", "new_string": "y"}); !isErr || !strings.Contains(text, "write_file") {
+		t.Errorf("Expected editing a missing file to fail, got %q", text)
+	}
+}
+
+func TestFileOpsServer_ApplyPatch(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("keep.txt", "a\nb\nc\n")
+	write("old.txt", "gone\n")
+
+	patch := unifiedDiff("keep.txt", "a\nb\nc\n", "a\nB\nc\n") +
+		"--- /dev/null\n+++ b/dir/new.txt\n@@ -0,0 +1 @@\n+fresh\n" +
+		"--- a/old.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-gone\n"
+	text, isErr := callFileOps(t, root, "apply_patch", map[string]any{"patch": patch})
+	if isErr {
+		t.Fatalf("Expected the patch applied, got %q", text)
+	}
+	for _, want := range []string{`"action":"modified"`, `"action":"created"`, `"action":"deleted"`} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %s in %q", want, text)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "keep.txt")); string(data) != "a\nB\nc\n" {
+		t.Errorf("Expected keep.txt patched, got %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "dir", "new.txt")); string(data) != "fresh\n" {
+		t.Errorf("Expected new.txt created, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(root, "old.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected old.txt deleted, got %v", err)
+	}
+
+	// A patch that does not apply to one file changes none
+	patch = unifiedDiff("keep.txt", "a\nB\nc\n", "a\nb\nc\n") + unifiedDiff("dir/new.txt", "stale\n", "x\n")
+	if text, isErr := callFileOps(t, root, "apply_patch", map[string]any{"patch": patch}); !isErr || !strings.Contains(text, "dir/new.txt") {
+		t.Errorf("Expected the patch rejected, got %q", text)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "keep.txt")); string(data) != "a\nB\nc\n" {
+		t.Errorf("Expected keep.txt unchanged, got %q", data)
+	}
+
+	// A file patched twice would lose the first change
+	patch = unifiedDiff("keep.txt", "a\nB\nc\n", "A\nB\nc\n") + unifiedDiff("keep.txt", "a\nB\nc\n", "a\nB\nC\n")
+	if text, isErr := callFileOps(t, root, "apply_patch", map[string]any{"patch": patch}); !isErr || !strings.Contains(text, "more than once") {
+		t.Errorf("Expected a file patched twice rejected, got %q", text)
+	}
+
+	// A rename does not replace an existing file
+	patch = "--- a/keep.txt\n+++ b/dir/new.txt\n@@ -1 +1 @@\n-a\n+z\n"
+	if text, isErr := callFileOps(t, root, "apply_patch", map[string]any{"patch": patch}); !isErr || !strings.Contains(text, "already exists") {
+		t.Errorf("Expected a rename onto an existing file rejected, got %q", text)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "dir", "new.txt")); string(data) != "fresh\n" {
+		t.Errorf("Expected new.txt unchanged, got %q", data)
+	}
+}
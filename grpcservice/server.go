@@ -0,0 +1,333 @@
+// Package grpcservice exposes Claude agent conversations as a gRPC service,
+// so services written in other languages can share one centrally managed
+// agent host.
+//
+// The service is defined in proto/agent.proto. It uses google.protobuf.Struct
+// envelopes that mirror the SDK's JSON message format, so callers only need
+// the well-known protobuf types.
+//
+// This package is a separate module so the core SDK stays free of
+// dependencies.
+//
+// Example:
+//
+//	lis, _ := net.Listen("tcp", ":50051")
+//	s := grpc.NewServer()
+//	grpcservice.RegisterAgentServiceServer(s, grpcservice.NewServer(grpcservice.Options{
+//	    ClientOptions: []claude.Option{claude.WithModel("claude-sonnet-4-5")},
+//	}))
+//	s.Serve(lis)
+package grpcservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	claude "github.com/afsharalex/claude-agent-sdk-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Request types sent by callers.
+const (
+	RequestTypePrompt             = "prompt"
+	RequestTypeInterrupt          = "interrupt"
+	RequestTypePermissionResponse = "permission_response"
+)
+
+// Event types sent to callers.
+const (
+	EventTypeMessage           = "message"
+	EventTypePermissionRequest = "permission_request"
+	EventTypeError             = "error"
+)
+
+// maxPendingPrompts limits how many prompts may queue behind a running
+// turn on one Session stream.
+const maxPendingPrompts = 16
+
+// Session is a conversation backing a Session stream.
+// *claude.Client satisfies this interface.
+type Session interface {
+	Query(ctx context.Context, prompt string) error
+	ReceiveResponse(ctx context.Context) <-chan claude.Message
+	Interrupt(ctx context.Context) error
+	Close() error
+}
+
+var _ Session = (*claude.Client)(nil)
+
+// QueryFunc runs a one-shot query. claude.Query satisfies this type.
+type QueryFunc func(ctx context.Context, prompt string, opts ...claude.Option) (<-chan claude.Message, <-chan error)
+
+// Options configures the server.
+type Options struct {
+	// ClientOptions configures the queries and sessions run by the server.
+	ClientOptions []claude.Option
+
+	// Query runs one-shot queries. Defaults to claude.Query.
+	Query QueryFunc
+
+	// NewSession creates the session for a Session stream. canUseTool
+	// forwards permission requests to the caller and must be installed on
+	// the session. Defaults to a connected claude.Client created from
+	// ClientOptions.
+	NewSession func(ctx context.Context, canUseTool claude.CanUseToolFunc) (Session, error)
+}
+
+// Server implements AgentServiceServer.
+type Server struct {
+	opts Options
+}
+
+// NewServer creates a new Server.
+func NewServer(opts Options) *Server {
+	if opts.Query == nil {
+		opts.Query = claude.Query
+	}
+	if opts.NewSession == nil {
+		clientOpts := opts.ClientOptions
+		opts.NewSession = func(ctx context.Context, canUseTool claude.CanUseToolFunc) (Session, error) {
+			options := append(append([]claude.Option{}, clientOpts...), claude.WithCanUseTool(canUseTool))
+			client := claude.NewClient(options...)
+			if err := client.Connect(ctx); err != nil {
+				return nil, err
+			}
+			return client, nil
+		}
+	}
+	return &Server{opts: opts}
+}
+
+// Query runs a one-shot query and streams its messages.
+func (s *Server) Query(req *structpb.Struct, stream grpc.ServerStreamingServer[structpb.Struct]) error {
+	prompt, _ := req.AsMap()["prompt"].(string)
+	if prompt == "" {
+		return status.Error(codes.InvalidArgument, "missing prompt")
+	}
+
+	ctx := stream.Context()
+	messages, errs := s.opts.Query(ctx, prompt, s.opts.ClientOptions...)
+
+	for msg := range messages {
+		event, err := messageEvent(msg)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+	if err := <-errs; err != nil {
+		return status.Error(codes.Unknown, err.Error())
+	}
+	return nil
+}
+
+// Session runs an interactive conversation over a bidirectional stream.
+func (s *Server) Session(stream grpc.BidiStreamingServer[structpb.Struct, structpb.Struct]) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	ss := &sessionStream{stream: stream, pending: make(map[string]chan claude.PermissionResult)}
+
+	session, err := s.opts.NewSession(ctx, ss.canUseTool)
+	if err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	defer func() { _ = session.Close() }()
+
+	// Receive requests in the background so interrupts and permission
+	// responses are handled while a response is streaming. Prompts are
+	// queued for the turn loop below; the receiver never blocks on a full
+	// queue, since a pending permission response may be needed to finish
+	// the running turn.
+	prompts := make(chan string, maxPendingPrompts)
+	recvErr := make(chan error, 1)
+	go func() {
+		defer close(prompts)
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			data := req.AsMap()
+			switch data["type"] {
+			case RequestTypePrompt:
+				prompt, _ := data["prompt"].(string)
+				if prompt == "" {
+					_ = ss.sendError("missing prompt")
+					continue
+				}
+				select {
+				case prompts <- prompt:
+				default:
+					_ = ss.sendError("too many pending prompts")
+				}
+			case RequestTypeInterrupt:
+				if err := session.Interrupt(ctx); err != nil {
+					_ = ss.sendError(err.Error())
+				}
+			case RequestTypePermissionResponse:
+				if err := ss.resolve(data); err != nil {
+					_ = ss.sendError(err.Error())
+				}
+			default:
+				_ = ss.sendError(fmt.Sprintf("unsupported request type: %v", data["type"]))
+			}
+		}
+	}()
+
+	for prompt := range prompts {
+		if err := session.Query(ctx, prompt); err != nil {
+			if err := ss.sendError(err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+		for msg := range session.ReceiveResponse(ctx) {
+			event, err := messageEvent(msg)
+			if err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+			if err := ss.send(event); err != nil {
+				return err
+			}
+		}
+	}
+
+	select {
+	case err := <-recvErr:
+		if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+			return err
+		}
+	default:
+	}
+	return nil
+}
+
+// sessionStream serializes sends on a Session stream and tracks permission
+// requests awaiting a response from the caller.
+type sessionStream struct {
+	stream grpc.BidiStreamingServer[structpb.Struct, structpb.Struct]
+	sendMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[string]chan claude.PermissionResult
+	nextID  atomic.Int64
+}
+
+// send sends event on the stream.
+func (s *sessionStream) send(event *structpb.Struct) error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	return s.stream.Send(event)
+}
+
+// sendError sends an error event.
+func (s *sessionStream) sendError(message string) error {
+	event, err := newStruct(map[string]any{"type": EventTypeError, "error": message})
+	if err != nil {
+		return err
+	}
+	return s.send(event)
+}
+
+// canUseTool forwards a permission request to the caller and waits for
+// the matching permission_response.
+func (s *sessionStream) canUseTool(ctx context.Context, toolName string, input map[string]any, _ claude.ToolPermissionContext) (claude.PermissionResult, error) {
+	id := strconv.FormatInt(s.nextID.Add(1), 10)
+	ch := make(chan claude.PermissionResult, 1)
+
+	s.mu.Lock()
+	s.pending[id] = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+	}()
+
+	event, err := newStruct(map[string]any{
+		"type":       EventTypePermissionRequest,
+		"request_id": id,
+		"tool_name":  toolName,
+		"input":      input,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.send(event); err != nil {
+		return nil, err
+	}
+
+	select {
+	case result := <-ch:
+		return result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// resolve delivers a permission_response to the waiting request.
+func (s *sessionStream) resolve(data map[string]any) error {
+	id, _ := data["request_id"].(string)
+
+	s.mu.Lock()
+	ch, ok := s.pending[id]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown permission request: %q", id)
+	}
+
+	var result claude.PermissionResult
+	if allow, _ := data["allow"].(bool); allow {
+		updatedInput, _ := data["updated_input"].(map[string]any)
+		result = claude.PermissionResultAllow{UpdatedInput: updatedInput}
+	} else {
+		message, _ := data["message"].(string)
+		result = claude.PermissionResultDeny{Message: message}
+	}
+
+	select {
+	case ch <- result:
+		return nil
+	default:
+		return fmt.Errorf("permission request %q already answered", id)
+	}
+}
+
+// messageEvent builds the message event for msg.
+func messageEvent(msg claude.Message) (*structpb.Struct, error) {
+	data, err := claude.EncodeMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+	return newStruct(map[string]any{
+		"type":    EventTypeMessage,
+		"message": data,
+	})
+}
+
+// newStruct converts data into a Struct. Values are normalized through
+// JSON first, since structpb only accepts generic maps, slices, and numbers.
+func newStruct(data map[string]any) (*structpb.Struct, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var normalized map[string]any
+	if err := json.Unmarshal(raw, &normalized); err != nil {
+		return nil, err
+	}
+	return structpb.NewStruct(normalized)
+}
@@ -0,0 +1,368 @@
+package grpcservice
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	claude "github.com/afsharalex/claude-agent-sdk-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// fakeSession answers each prompt with an echo and a result. If the prompt
+// is "use tool", it asks for permission first and reports the decision.
+type fakeSession struct {
+	canUseTool claude.CanUseToolFunc
+
+	mu         sync.Mutex
+	prompts    []string
+	interrupts int
+	closed     bool
+}
+
+func (s *fakeSession) Query(ctx context.Context, prompt string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prompts = append(s.prompts, prompt)
+	return nil
+}
+
+func (s *fakeSession) ReceiveResponse(ctx context.Context) <-chan claude.Message {
+	s.mu.Lock()
+	prompt := s.prompts[len(s.prompts)-1]
+	s.mu.Unlock()
+
+	ch := make(chan claude.Message, 2)
+	go func() {
+		defer close(ch)
+		text := "echo: " + prompt
+		if prompt == "use tool" {
+			result, err := s.canUseTool(ctx, "Bash", map[string]any{"command": "ls"}, claude.ToolPermissionContext{})
+			switch r := result.(type) {
+			case claude.PermissionResultAllow:
+				text = "allowed"
+			case claude.PermissionResultDeny:
+				text = "denied: " + r.Message
+			default:
+				text = "error: " + err.Error()
+			}
+		}
+		ch <- &claude.AssistantMessage{Model: "test-model", Content: []claude.ContentBlock{claude.TextBlock{Text: text}}}
+		ch <- &claude.ResultMessage{Subtype: "success", SessionID: "s-1"}
+	}()
+	return ch
+}
+
+func (s *fakeSession) Interrupt(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interrupts++
+	return nil
+}
+
+func (s *fakeSession) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+// dial starts srv on an in-memory listener and returns a connected client.
+func dial(t *testing.T, srv *Server) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	RegisterAgentServiceServer(s, srv)
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func mustStruct(t *testing.T, data map[string]any) *structpb.Struct {
+	t.Helper()
+	s, err := structpb.NewStruct(data)
+	if err != nil {
+		t.Fatalf("Failed to build struct: %v", err)
+	}
+	return s
+}
+
+// messageText returns the first text block of a message event.
+func messageText(event map[string]any) string {
+	message, _ := event["message"].(map[string]any)
+	inner, _ := message["message"].(map[string]any)
+	content, _ := inner["content"].([]any)
+	if len(content) == 0 {
+		return ""
+	}
+	block, _ := content[0].(map[string]any)
+	text, _ := block["text"].(string)
+	return text
+}
+
+func TestServer_Query(t *testing.T) {
+	var gotPrompt string
+	srv := NewServer(Options{
+		Query: func(ctx context.Context, prompt string, opts ...claude.Option) (<-chan claude.Message, <-chan error) {
+			gotPrompt = prompt
+			messages := make(chan claude.Message, 2)
+			errs := make(chan error, 1)
+			messages <- &claude.AssistantMessage{Model: "m", Content: []claude.ContentBlock{claude.TextBlock{Text: "4"}}}
+			messages <- &claude.ResultMessage{Subtype: "success"}
+			close(messages)
+			close(errs)
+			return messages, errs
+		},
+	})
+	conn := dial(t, srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := grpc.NewClientStream(ctx, &serviceDesc.Streams[0], conn, QueryMethod)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	if err := stream.SendMsg(mustStruct(t, map[string]any{"prompt": "2+2"})); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	_ = stream.CloseSend()
+
+	var events []map[string]any
+	for {
+		event := new(structpb.Struct)
+		if err := stream.RecvMsg(event); err != nil {
+			break
+		}
+		events = append(events, event.AsMap())
+	}
+
+	if gotPrompt != "2+2" {
+		t.Errorf("Expected prompt '2+2', got %q", gotPrompt)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	if messageText(events[0]) != "4" {
+		t.Errorf("Expected text '4', got %v", events[0])
+	}
+}
+
+func TestServer_Query_Errors(t *testing.T) {
+	srv := NewServer(Options{
+		Query: func(ctx context.Context, prompt string, opts ...claude.Option) (<-chan claude.Message, <-chan error) {
+			messages := make(chan claude.Message)
+			errs := make(chan error, 1)
+			errs <- errors.New("cli exited")
+			close(messages)
+			close(errs)
+			return messages, errs
+		},
+	})
+	conn := dial(t, srv)
+
+	tests := []struct {
+		name   string
+		req    map[string]any
+		wantCd codes.Code
+	}{
+		{"missing prompt", map[string]any{}, codes.InvalidArgument},
+		{"query error", map[string]any{"prompt": "hi"}, codes.Unknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			stream, err := grpc.NewClientStream(ctx, &serviceDesc.Streams[0], conn, QueryMethod)
+			if err != nil {
+				t.Fatalf("Failed to open stream: %v", err)
+			}
+			_ = stream.SendMsg(mustStruct(t, tt.req))
+			_ = stream.CloseSend()
+
+			err = stream.RecvMsg(new(structpb.Struct))
+			if status.Code(err) != tt.wantCd {
+				t.Errorf("Expected code %v, got %v", tt.wantCd, err)
+			}
+		})
+	}
+}
+
+func TestServer_Session(t *testing.T) {
+	session := &fakeSession{}
+	srv := NewServer(Options{
+		NewSession: func(ctx context.Context, canUseTool claude.CanUseToolFunc) (Session, error) {
+			session.canUseTool = canUseTool
+			return session, nil
+		},
+	})
+	conn := dial(t, srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := grpc.NewClientStream(ctx, &serviceDesc.Streams[1], conn, SessionMethod)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	recv := func() map[string]any {
+		t.Helper()
+		event := new(structpb.Struct)
+		if err := stream.RecvMsg(event); err != nil {
+			t.Fatalf("Failed to receive: %v", err)
+		}
+		return event.AsMap()
+	}
+
+	// Plain prompt
+	_ = stream.SendMsg(mustStruct(t, map[string]any{"type": RequestTypePrompt, "prompt": "hello"}))
+	if text := messageText(recv()); text != "echo: hello" {
+		t.Errorf("Expected echo, got %q", text)
+	}
+	recv() // result
+
+	// Tool permission round trip
+	_ = stream.SendMsg(mustStruct(t, map[string]any{"type": RequestTypePrompt, "prompt": "use tool"}))
+	req := recv()
+	if req["type"] != EventTypePermissionRequest || req["tool_name"] != "Bash" {
+		t.Fatalf("Expected permission request for Bash, got %v", req)
+	}
+	_ = stream.SendMsg(mustStruct(t, map[string]any{
+		"type":       RequestTypePermissionResponse,
+		"request_id": req["request_id"],
+		"allow":      false,
+		"message":    "not today",
+	}))
+	if text := messageText(recv()); text != "denied: not today" {
+		t.Errorf("Expected denial, got %q", text)
+	}
+	recv() // result
+
+	// Interrupt and invalid requests
+	_ = stream.SendMsg(mustStruct(t, map[string]any{"type": RequestTypeInterrupt}))
+	_ = stream.SendMsg(mustStruct(t, map[string]any{"type": "bogus"}))
+	if event := recv(); event["type"] != EventTypeError {
+		t.Errorf("Expected error event, got %v", event)
+	}
+	_ = stream.SendMsg(mustStruct(t, map[string]any{"type": RequestTypePrompt, "prompt": ""}))
+	if event := recv(); event["type"] != EventTypeError || event["error"] != "missing prompt" {
+		t.Errorf("Expected missing prompt error, got %v", event)
+	}
+
+	_ = stream.CloseSend()
+	if err := stream.RecvMsg(new(structpb.Struct)); err == nil {
+		t.Error("Expected stream to end")
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if len(session.prompts) != 2 {
+		t.Errorf("Expected 2 prompts to reach the session, got %v", session.prompts)
+	}
+	if session.interrupts != 1 {
+		t.Errorf("Expected 1 interrupt, got %d", session.interrupts)
+	}
+	if !session.closed {
+		t.Error("Expected session to be closed")
+	}
+}
+
+func TestServer_Session_PromptQueueFull(t *testing.T) {
+	session := &fakeSession{}
+	srv := NewServer(Options{
+		NewSession: func(ctx context.Context, canUseTool claude.CanUseToolFunc) (Session, error) {
+			session.canUseTool = canUseTool
+			return session, nil
+		},
+	})
+	conn := dial(t, srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := grpc.NewClientStream(ctx, &serviceDesc.Streams[1], conn, SessionMethod)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	recv := func() map[string]any {
+		t.Helper()
+		event := new(structpb.Struct)
+		if err := stream.RecvMsg(event); err != nil {
+			t.Fatalf("Failed to receive: %v", err)
+		}
+		return event.AsMap()
+	}
+
+	// The turn waits on a permission response while prompts pile up
+	_ = stream.SendMsg(mustStruct(t, map[string]any{"type": RequestTypePrompt, "prompt": "use tool"}))
+	req := recv()
+	if req["type"] != EventTypePermissionRequest {
+		t.Fatalf("Expected permission request, got %v", req)
+	}
+	for i := 0; i <= maxPendingPrompts; i++ {
+		_ = stream.SendMsg(mustStruct(t, map[string]any{"type": RequestTypePrompt, "prompt": "queued"}))
+	}
+	if event := recv(); event["type"] != EventTypeError || event["error"] != "too many pending prompts" {
+		t.Fatalf("Expected queue full error, got %v", event)
+	}
+
+	// The receiver is not blocked, so the permission response gets through
+	_ = stream.SendMsg(mustStruct(t, map[string]any{
+		"type":       RequestTypePermissionResponse,
+		"request_id": req["request_id"],
+		"allow":      true,
+	}))
+	if text := messageText(recv()); text != "allowed" {
+		t.Errorf("Expected allowed, got %q", text)
+	}
+}
+
+func TestServer_Session_NewSessionError(t *testing.T) {
+	srv := NewServer(Options{
+		NewSession: func(ctx context.Context, canUseTool claude.CanUseToolFunc) (Session, error) {
+			return nil, errors.New("cli not found")
+		},
+	})
+	conn := dial(t, srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := grpc.NewClientStream(ctx, &serviceDesc.Streams[1], conn, SessionMethod)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	err = stream.RecvMsg(new(structpb.Struct))
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("Expected Unavailable, got %v", err)
+	}
+}
+
+func TestSessionStream_ResolveUnknown(t *testing.T) {
+	ss := &sessionStream{pending: make(map[string]chan claude.PermissionResult)}
+	if err := ss.resolve(map[string]any{"request_id": "missing"}); err == nil {
+		t.Error("Expected error for unknown request")
+	}
+}
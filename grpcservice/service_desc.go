@@ -0,0 +1,60 @@
+package grpcservice
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// This file contains the service descriptor for proto/agent.proto. The
+// service only uses well-known types, so no protoc-generated code is needed.
+
+// ServiceName is the fully qualified gRPC service name.
+const ServiceName = "claude.agent.v1.AgentService"
+
+// Full method names of the service.
+const (
+	QueryMethod   = "/" + ServiceName + "/Query"
+	SessionMethod = "/" + ServiceName + "/Session"
+)
+
+// AgentServiceServer is the server API for AgentService.
+type AgentServiceServer interface {
+	Query(*structpb.Struct, grpc.ServerStreamingServer[structpb.Struct]) error
+	Session(grpc.BidiStreamingServer[structpb.Struct, structpb.Struct]) error
+}
+
+// RegisterAgentServiceServer registers srv with r.
+func RegisterAgentServiceServer(r grpc.ServiceRegistrar, srv AgentServiceServer) {
+	r.RegisterService(&serviceDesc, srv)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*AgentServiceServer)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Query",
+			Handler:       queryHandler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Session",
+			Handler:       sessionHandler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "proto/agent.proto",
+}
+
+func queryHandler(srv any, stream grpc.ServerStream) error {
+	req := new(structpb.Struct)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(AgentServiceServer).Query(req, &grpc.GenericServerStream[structpb.Struct, structpb.Struct]{ServerStream: stream})
+}
+
+func sessionHandler(srv any, stream grpc.ServerStream) error {
+	return srv.(AgentServiceServer).Session(&grpc.GenericServerStream[structpb.Struct, structpb.Struct]{ServerStream: stream})
+}
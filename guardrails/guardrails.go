@@ -0,0 +1,134 @@
+// Package guardrails provides ready-made hooks and permission policies
+// that keep an agent within safe bounds:
+//
+//   - Workspace denies file writes outside the workspace directories.
+//   - Network denies Bash commands that make network calls.
+//   - Injection blocks tool results that look like prompt injections.
+//
+// Workspace and Network are Rules, checked before a tool runs either by a
+// PreToolUse hook or by a canUseTool callback:
+//
+//	rules := []guardrails.Rule{
+//	    guardrails.Workspace{Root: "/srv/project"},
+//	    guardrails.Network{},
+//	}
+//	client := claude.NewClient(
+//	    claude.WithPreToolUseHook("", guardrails.PreToolUseHook(rules...)),
+//	    claude.WithPostToolUseHook("", guardrails.Injection{}.Hook()),
+//	)
+//
+// The guardrails can also be added to a claude.HookRegistry with Register
+// and referenced by name from a hook configuration file.
+//
+// Guardrails inspect tool input, not what a command actually does: they
+// stop common mistakes and naive attacks, and are no substitute for an OS
+// sandbox (see claude.WithSandbox).
+package guardrails
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	claude "github.com/afsharalex/claude-agent-sdk-go"
+)
+
+// Names under which Register adds the guardrails to a hook registry.
+const (
+	HookWorkspace = "guardrails.workspace"
+	HookNetwork   = "guardrails.network"
+	HookInjection = "guardrails.injection"
+)
+
+// Rule checks a tool call before it runs.
+type Rule interface {
+	// Check returns an error explaining why the call is not allowed, or
+	// nil to let other rules decide. cwd is the session's working
+	// directory, used to resolve relative paths.
+	Check(toolName string, input map[string]any, cwd string) error
+}
+
+// PreToolUseHook returns a PreToolUse hook that denies a tool call if any
+// of rules rejects it.
+func PreToolUseHook(rules ...Rule) claude.HookCallback {
+	return func(ctx context.Context, input claude.HookInput, toolUseID string, hookCtx claude.HookContext) (claude.HookOutput, error) {
+		pre, ok := input.(claude.PreToolUseHookInput)
+		if !ok {
+			return claude.HookOutput{}, nil
+		}
+
+		if err := check(rules, pre.ToolName, pre.ToolInput, pre.Cwd); err != nil {
+			return claude.HookOutput{
+				HookSpecificOutput: claude.PreToolUseHookSpecificOutput{
+					HookEventName:            claude.HookEventPreToolUse,
+					PermissionDecision:       claude.HookPermissionDecisionDeny,
+					PermissionDecisionReason: err.Error(),
+				},
+			}, nil
+		}
+		return claude.HookOutput{}, nil
+	}
+}
+
+// CanUseTool returns a canUseTool callback that denies a tool call if any
+// of rules rejects it and allows it otherwise. Relative paths are
+// resolved against the current directory of the process.
+func CanUseTool(rules ...Rule) claude.CanUseToolFunc {
+	return func(ctx context.Context, toolName string, input map[string]any, permCtx claude.ToolPermissionContext) (claude.PermissionResult, error) {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		if err := check(rules, toolName, input, cwd); err != nil {
+			return claude.PermissionResultDeny{Message: err.Error()}, nil
+		}
+		return claude.PermissionResultAllow{}, nil
+	}
+}
+
+// check returns the first rejection of rules.
+func check(rules []Rule, toolName string, input map[string]any, cwd string) error {
+	for _, rule := range rules {
+		if err := rule.Check(toolName, input, cwd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Register adds the guardrails with their default configuration to
+// registry, as HookWorkspace and HookNetwork for PreToolUse and
+// HookInjection for PostToolUse. The workspace is the session's working
+// directory.
+func Register(registry *claude.HookRegistry) error {
+	hooks := []struct {
+		name     string
+		callback claude.HookCallback
+	}{
+		{HookWorkspace, PreToolUseHook(Workspace{})},
+		{HookNetwork, PreToolUseHook(Network{})},
+		{HookInjection, Injection{}.Hook()},
+	}
+	for _, h := range hooks {
+		if err := registry.Register(h.name, h.callback); err != nil {
+			return fmt.Errorf("guardrails: %w", err)
+		}
+	}
+	return nil
+}
+
+// stringField returns input[key] if it is a non-empty string.
+func stringField(input map[string]any, key string) (string, bool) {
+	s, ok := input[key].(string)
+	return s, ok && s != ""
+}
+
+// contains reports whether list contains s.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package guardrails
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	claude "github.com/afsharalex/claude-agent-sdk-go"
+)
+
+func TestPreToolUseHook(t *testing.T) {
+	hook := PreToolUseHook(Workspace{}, Network{})
+	cwd := t.TempDir()
+
+	tests := []struct {
+		name  string
+		tool  string
+		input map[string]any
+		deny  bool
+	}{
+		{"allowed write", "Write", map[string]any{"file_path": "a.go"}, false},
+		{"write outside", "Write", map[string]any{"file_path": "/etc/a.go"}, true},
+		{"network", "Bash", map[string]any{"command": "curl x"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := claude.PreToolUseHookInput{
+				BaseHookInput: claude.BaseHookInput{Cwd: cwd},
+				ToolName:      tt.tool,
+				ToolInput:     tt.input,
+			}
+			output, err := hook(context.Background(), input, "t1", claude.HookContext{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			specific, denied := output.HookSpecificOutput.(claude.PreToolUseHookSpecificOutput)
+			if denied != tt.deny {
+				t.Fatalf("Expected deny=%v, got %+v", tt.deny, output)
+			}
+			if denied && (specific.PermissionDecision != claude.HookPermissionDecisionDeny || specific.PermissionDecisionReason == "") {
+				t.Errorf("Expected a deny decision with a reason, got %+v", specific)
+			}
+		})
+	}
+}
+
+func TestCanUseTool(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	canUseTool := CanUseTool(Workspace{Root: cwd}, Network{})
+
+	result, err := canUseTool(context.Background(), "Bash", map[string]any{"command": "wget x"}, claude.ToolPermissionContext{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deny, ok := result.(claude.PermissionResultDeny); !ok || deny.Message == "" {
+		t.Errorf("Expected a deny with a message, got %#v", result)
+	}
+
+	result, _ = canUseTool(context.Background(), "Write", map[string]any{"file_path": "out.txt"}, claude.ToolPermissionContext{})
+	if _, ok := result.(claude.PermissionResultAllow); !ok {
+		t.Errorf("Expected allow, got %#v", result)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	registry := claude.NewHookRegistry()
+	if err := Register(registry); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	for _, name := range []string{HookWorkspace, HookNetwork, HookInjection} {
+		if _, ok := registry.Lookup(name); !ok {
+			t.Errorf("Expected %s to be registered", name)
+		}
+	}
+	if err := Register(registry); err == nil {
+		t.Error("Expected registering twice to fail")
+	}
+}
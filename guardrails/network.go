@@ -0,0 +1,117 @@
+package guardrails
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DefaultNetworkCommands are the commands Network denies by default. An
+// entry of several words, such as "git push", matches a command starting
+// with those words.
+var DefaultNetworkCommands = []string{
+	"curl", "wget", "http", "https",
+	"nc", "ncat", "netcat", "socat", "telnet",
+	"ssh", "scp", "sftp", "ftp", "rsync",
+	"git clone", "git fetch", "git pull", "git push", "git ls-remote",
+	"npm install", "npm i", "npx", "pip install", "pip3 install",
+	"go get", "go install",
+}
+
+// Network is a Rule that denies Bash commands that make network calls.
+//
+// Each command of a pipeline or list is checked, including commands run
+// through wrappers such as sudo, env, or xargs and in command
+// substitutions. Bash's /dev/tcp and /dev/udp redirections are denied as
+// well. Commands hidden from plain inspection, for example in a script,
+// are not detected.
+type Network struct {
+	// Commands are the commands to deny. Defaults to
+	// DefaultNetworkCommands.
+	Commands []string
+
+	// Allow lists exceptions to Commands, in the same form, such as
+	// "git fetch" in a repository with a trusted remote.
+	Allow []string
+}
+
+// commandSeparators split a shell command line into simple commands.
+var commandSeparators = regexp.MustCompile("&&|\\|\\||[;&|\\n()`{}]|\\$\\(")
+
+// commandWrappers run the command that follows them.
+var commandWrappers = []string{"sudo", "env", "command", "exec", "nohup", "time", "xargs", "nice", "timeout", "builtin"}
+
+// wrapperArg matches numeric wrapper arguments such as durations.
+var wrapperArg = regexp.MustCompile(`^[0-9][0-9.]*[smhd]?$`)
+
+// Check implements Rule.
+func (n Network) Check(toolName string, input map[string]any, cwd string) error {
+	if toolName != "Bash" {
+		return nil
+	}
+	line, ok := stringField(input, "command")
+	if !ok {
+		return nil
+	}
+
+	if strings.Contains(line, "/dev/tcp/") || strings.Contains(line, "/dev/udp/") {
+		return fmt.Errorf("command denied: network redirection in %q", line)
+	}
+
+	commands := n.Commands
+	if commands == nil {
+		commands = DefaultNetworkCommands
+	}
+	for _, simple := range commandSeparators.Split(line, -1) {
+		words := commandWords(strings.Fields(simple))
+		if len(words) == 0 {
+			continue
+		}
+		if match := matchCommand(words, commands); match != "" && matchCommand(words, n.Allow) == "" {
+			return fmt.Errorf("command denied: %q makes network calls", match)
+		}
+	}
+	return nil
+}
+
+// commandWords returns the words of a simple command from its program
+// name on, skipping variable assignments, wrappers and their flags.
+func commandWords(words []string) []string {
+	for len(words) > 0 {
+		word := words[0]
+		switch {
+		case strings.Contains(word, "=") && !strings.HasPrefix(word, "="):
+			// Variable assignment such as FOO=bar
+		case contains(commandWrappers, filepath.Base(word)):
+		case strings.HasPrefix(word, "-") && len(words) > 1, wrapperArg.MatchString(word):
+			// Flag or argument of a preceding wrapper, as in timeout 5s
+		default:
+			words[0] = filepath.Base(strings.Trim(word, `"'`))
+			return words
+		}
+		words = words[1:]
+	}
+	return nil
+}
+
+// matchCommand returns the entry of commands that words start with.
+func matchCommand(words, commands []string) string {
+	for _, command := range commands {
+		fields := strings.Fields(command)
+		if len(fields) == 0 || len(fields) > len(words) {
+			continue
+		}
+		matched := true
+		for i, field := range fields {
+			if words[i] != field {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return command
+		}
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package guardrails
+
+import "testing"
+
+func TestNetwork_Check(t *testing.T) {
+	tests := []struct {
+		command string
+		deny    bool
+	}{
+		{"ls -la", false},
+		{"go test ./...", false},
+		{"git status && git diff", false},
+		{"echo curl", false},
+		{"curl https://example.com", true},
+		{"/usr/bin/wget -q https://example.com", true},
+		{"cat file | nc example.com 80", true},
+		{"make build; git push origin main", true},
+		{"sudo -E curl https://example.com", true},
+		{"HTTPS_PROXY=x curl https://example.com", true},
+		{"timeout 5s ssh host", true},
+		{"echo $(curl -s https://example.com)", true},
+		{"echo hi > /dev/tcp/example.com/80", true},
+		{"npm install left-pad", true},
+		{"npm test", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			err := Network{}.Check("Bash", map[string]any{"command": tt.command}, "")
+			if (err != nil) != tt.deny {
+				t.Errorf("Expected deny=%v, got %v", tt.deny, err)
+			}
+		})
+	}
+}
+
+func TestNetwork_CustomCommandsAndAllow(t *testing.T) {
+	n := Network{Commands: []string{"git fetch", "kubectl"}, Allow: []string{"git fetch origin"}}
+
+	if err := n.Check("Bash", map[string]any{"command": "kubectl apply -f x.yaml"}, ""); err == nil {
+		t.Error("Expected custom command to be denied")
+	}
+	if err := n.Check("Bash", map[string]any{"command": "git fetch upstream"}, ""); err == nil {
+		t.Error("Expected git fetch upstream to be denied")
+	}
+	if err := n.Check("Bash", map[string]any{"command": "git fetch origin"}, ""); err != nil {
+		t.Errorf("Expected allowed command to pass, got %v", err)
+	}
+	if err := n.Check("Bash", map[string]any{"command": "curl https://example.com"}, ""); err != nil {
+		t.Errorf("Expected default commands to be replaced, got %v", err)
+	}
+}
+
+func TestNetwork_IgnoresOtherTools(t *testing.T) {
+	if err := (Network{}).Check("WebFetch", map[string]any{"command": "curl x"}, ""); err != nil {
+		t.Errorf("Expected other tools to be ignored, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package guardrails
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultWriteTools are the tools Workspace checks by default, with the
+// input fields holding the path they write to.
+var DefaultWriteTools = map[string]string{
+	"Write":        "file_path",
+	"Edit":         "file_path",
+	"MultiEdit":    "file_path",
+	"NotebookEdit": "notebook_path",
+}
+
+// Workspace is a Rule that denies file writes outside the workspace.
+//
+// Paths are cleaned and symlinks resolved before they are compared, so
+// writes cannot escape through ".." or a link pointing outside. Writes
+// made by Bash commands are not checked.
+type Workspace struct {
+	// Root is the workspace directory. Defaults to the session's working
+	// directory.
+	Root string
+
+	// AllowedDirs are other directories writes may go to, such as a
+	// scratch directory.
+	AllowedDirs []string
+
+	// Tools maps the names of the tools to check to the input field
+	// holding the path they write to. Defaults to DefaultWriteTools.
+	Tools map[string]string
+}
+
+// Check implements Rule.
+func (w Workspace) Check(toolName string, input map[string]any, cwd string) error {
+	tools := w.Tools
+	if tools == nil {
+		tools = DefaultWriteTools
+	}
+	field, ok := tools[toolName]
+	if !ok {
+		return nil
+	}
+	path, ok := stringField(input, field)
+	if !ok {
+		return nil
+	}
+
+	root := w.Root
+	if root == "" {
+		root = cwd
+	}
+	if root == "" {
+		return fmt.Errorf("writing to %s is denied: no workspace is set", path)
+	}
+
+	target := resolvePath(path, cwd)
+	for _, dir := range append([]string{root}, w.AllowedDirs...) {
+		if within(target, resolvePath(dir, cwd)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("writing to %s is denied: it is outside the workspace %s", path, root)
+}
+
+// resolvePath makes path absolute against cwd, cleans it, and resolves
+// symlinks in the part of it that exists.
+func resolvePath(path, cwd string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cwd, path)
+	}
+	path = filepath.Clean(path)
+
+	// Resolve the longest existing prefix, since the target of a write
+	// usually does not exist yet.
+	var rest []string
+	for dir := path; ; dir = filepath.Dir(dir) {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir || !errors.Is(err, fs.ErrNotExist) {
+			return path
+		}
+		rest = append([]string{filepath.Base(dir)}, rest...)
+	}
+}
+
+// within reports whether path is dir or inside it.
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator)))
+}
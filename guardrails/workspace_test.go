@@ -0,0 +1,72 @@
+package guardrails
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkspace_Check(t *testing.T) {
+	root := t.TempDir()
+	scratch := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	w := Workspace{Root: root, AllowedDirs: []string{scratch}}
+	tests := []struct {
+		name  string
+		tool  string
+		input map[string]any
+		deny  bool
+	}{
+		{"inside", "Write", map[string]any{"file_path": filepath.Join(root, "a.go")}, false},
+		{"new subdirectory", "Write", map[string]any{"file_path": filepath.Join(root, "new", "dir", "a.go")}, false},
+		{"relative", "Edit", map[string]any{"file_path": "pkg/a.go"}, false},
+		{"root itself", "Write", map[string]any{"file_path": root}, false},
+		{"allowed dir", "MultiEdit", map[string]any{"file_path": filepath.Join(scratch, "notes.md")}, false},
+		{"outside", "Write", map[string]any{"file_path": filepath.Join(outside, "a.go")}, true},
+		{"dot dot", "Edit", map[string]any{"file_path": "../escape.go"}, true},
+		{"sibling prefix", "Write", map[string]any{"file_path": root + "-other/a.go"}, true},
+		{"through symlink", "Write", map[string]any{"file_path": filepath.Join(root, "link", "a.go")}, true},
+		{"notebook", "NotebookEdit", map[string]any{"notebook_path": "/etc/nb.ipynb"}, true},
+		{"read tool", "Read", map[string]any{"file_path": "/etc/passwd"}, false},
+		{"no path", "Write", map[string]any{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := w.Check(tt.tool, tt.input, root)
+			if (err != nil) != tt.deny {
+				t.Errorf("Expected deny=%v, got %v", tt.deny, err)
+			}
+		})
+	}
+}
+
+func TestWorkspace_DefaultsToCwd(t *testing.T) {
+	cwd := t.TempDir()
+	input := map[string]any{"file_path": "a.go"}
+
+	if err := (Workspace{}).Check("Write", input, cwd); err != nil {
+		t.Errorf("Expected write in cwd to be allowed, got %v", err)
+	}
+	if err := (Workspace{}).Check("Write", map[string]any{"file_path": "/a.go"}, cwd); err == nil {
+		t.Error("Expected write outside cwd to be denied")
+	}
+	if err := (Workspace{}).Check("Write", input, ""); err == nil {
+		t.Error("Expected write without a workspace to be denied")
+	}
+}
+
+func TestWorkspace_CustomTools(t *testing.T) {
+	w := Workspace{Root: t.TempDir(), Tools: map[string]string{"mcp__fs__write": "path"}}
+
+	if err := w.Check("mcp__fs__write", map[string]any{"path": "/etc/hosts"}, ""); err == nil {
+		t.Error("Expected custom tool to be checked")
+	}
+	if err := w.Check("Write", map[string]any{"file_path": "/etc/hosts"}, ""); err != nil {
+		t.Errorf("Expected default tools to be replaced, got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// HookRegistry holds hook callbacks registered by name, so that hooks can
+// be shipped as a library and referenced from a HookConfig instead of
+// being wired inline.
+//
+// Example:
+//
+//	registry := claude.NewHookRegistry()
+//	registry.MustRegister("audit", auditHook)
+//	registry.MustRegister("no-force-push", noForcePushHook)
+//
+//	config, err := claude.LoadHookConfig("hooks.json")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	client := claude.NewClient(claude.WithNamedHooks(registry, config))
+type HookRegistry struct {
+	mu    sync.RWMutex
+	hooks map[string]HookCallback
+}
+
+// NewHookRegistry creates an empty HookRegistry.
+func NewHookRegistry() *HookRegistry {
+	return &HookRegistry{hooks: make(map[string]HookCallback)}
+}
+
+// Register adds a hook under name. It fails if name is empty, callback is
+// nil, or name is already registered.
+func (r *HookRegistry) Register(name string, callback HookCallback) error {
+	if name == "" {
+		return NewClaudeSDKError("hook name cannot be empty")
+	}
+	if callback == nil {
+		return NewClaudeSDKError(fmt.Sprintf("hook %q has no callback", name))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.hooks[name]; ok {
+		return NewClaudeSDKError(fmt.Sprintf("hook %q is already registered", name))
+	}
+	r.hooks[name] = callback
+	return nil
+}
+
+// MustRegister is like Register but panics on error. It is intended for
+// registering hooks at package initialization.
+func (r *HookRegistry) MustRegister(name string, callback HookCallback) {
+	if err := r.Register(name, callback); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns the hook registered under name.
+func (r *HookRegistry) Lookup(name string) (HookCallback, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	callback, ok := r.hooks[name]
+	return callback, ok
+}
+
+// Names returns the registered hook names, sorted.
+func (r *HookRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.hooks))
+	for name := range r.hooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve turns config into hook matchers using the registered hooks. It
+// fails if config references a hook that is not registered.
+func (r *HookRegistry) Resolve(config HookConfig) (map[HookEvent][]HookMatcher, error) {
+	if len(config) == 0 {
+		return nil, nil
+	}
+	if r == nil {
+		return nil, NewClaudeSDKError("named hooks configured without a hook registry")
+	}
+
+	hooks := make(map[HookEvent][]HookMatcher, len(config))
+	for event, matchers := range config {
+		for _, m := range matchers {
+			matcher := HookMatcher{Matcher: m.Matcher, Timeout: m.Timeout}
+			for _, name := range m.Hooks {
+				callback, ok := r.Lookup(name)
+				if !ok {
+					return nil, NewClaudeSDKError(fmt.Sprintf("%s hook %q is not registered", event, name))
+				}
+				matcher.Hooks = append(matcher.Hooks, callback)
+			}
+			hooks[event] = append(hooks[event], matcher)
+		}
+	}
+	return hooks, nil
+}
+
+// HookConfig declares which registered hooks run for each event. It
+// decodes from JSON such as:
+//
+//	{
+//	  "PreToolUse": [
+//	    {"matcher": "Bash", "hooks": ["audit", "no-force-push"], "timeout": 30}
+//	  ],
+//	  "Stop": [{"hooks": ["audit"]}]
+//	}
+type HookConfig map[HookEvent][]NamedHookMatcher
+
+// NamedHookMatcher is a HookMatcher that references hooks by name.
+type NamedHookMatcher struct {
+	// Matcher is a pattern to match tool names, as in HookMatcher.
+	Matcher string `json:"matcher,omitempty"`
+
+	// Hooks are the names of the hooks to run, in order.
+	Hooks []string `json:"hooks"`
+
+	// Timeout is the timeout in seconds for all hooks in this matcher.
+	Timeout float64 `json:"timeout,omitempty"`
+}
+
+// LoadHookConfig reads a HookConfig from a JSON file.
+func LoadHookConfig(path string) (HookConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, WrapClaudeSDKError("failed to read hook config", err)
+	}
+
+	var config HookConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, WrapClaudeSDKError(fmt.Sprintf("invalid hook config %s", path), err)
+	}
+	return config, nil
+}
+
+// allHooks returns the hooks of o, including its named hooks. Named hooks
+// are checked by validateOptions, so resolution errors are ignored here.
+func allHooks(o *Options) map[HookEvent][]HookMatcher {
+	named, _ := o.HookRegistry.Resolve(o.NamedHooks)
+	if len(named) == 0 {
+		return o.Hooks
+	}
+
+	merged := make(map[HookEvent][]HookMatcher, len(o.Hooks)+len(named))
+	for event, matchers := range o.Hooks {
+		merged[event] = matchers
+	}
+	for event, matchers := range named {
+		merged[event] = append(append([]HookMatcher(nil), merged[event]...), matchers...)
+	}
+	return merged
+}
@@ -0,0 +1,154 @@
+package claude
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func namedHook(name string, calls *[]string) HookCallback {
+	return func(ctx context.Context, input HookInput, toolUseID string, hookCtx HookContext) (HookOutput, error) {
+		*calls = append(*calls, name)
+		return HookOutput{}, nil
+	}
+}
+
+func TestHookRegistry_Register(t *testing.T) {
+	var calls []string
+	registry := NewHookRegistry()
+
+	if err := registry.Register("audit", namedHook("audit", &calls)); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := registry.Register("audit", namedHook("audit", &calls)); err == nil {
+		t.Error("Expected a duplicate name to be rejected")
+	}
+	if err := registry.Register("", namedHook("", &calls)); err == nil {
+		t.Error("Expected an empty name to be rejected")
+	}
+	if err := registry.Register("nil", nil); err == nil {
+		t.Error("Expected a nil callback to be rejected")
+	}
+
+	registry.MustRegister("guard", namedHook("guard", &calls))
+	if got := registry.Names(); !reflect.DeepEqual(got, []string{"audit", "guard"}) {
+		t.Errorf("Expected sorted names, got %v", got)
+	}
+	if _, ok := registry.Lookup("missing"); ok {
+		t.Error("Expected lookup of an unregistered hook to fail")
+	}
+}
+
+func TestHookRegistry_MustRegisterPanics(t *testing.T) {
+	registry := NewHookRegistry()
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected MustRegister to panic")
+		}
+	}()
+	registry.MustRegister("", nil)
+}
+
+func TestHookRegistry_Resolve(t *testing.T) {
+	var calls []string
+	registry := NewHookRegistry()
+	registry.MustRegister("audit", namedHook("audit", &calls))
+	registry.MustRegister("guard", namedHook("guard", &calls))
+
+	hooks, err := registry.Resolve(HookConfig{
+		HookEventPreToolUse: {{Matcher: "Bash", Hooks: []string{"guard", "audit"}, Timeout: 30}},
+	})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	matchers := hooks[HookEventPreToolUse]
+	if len(matchers) != 1 || matchers[0].Matcher != "Bash" || matchers[0].Timeout != 30 {
+		t.Fatalf("Unexpected matchers: %+v", matchers)
+	}
+	for _, hook := range matchers[0].Hooks {
+		_, _ = hook(context.Background(), nil, "", HookContext{})
+	}
+	if !reflect.DeepEqual(calls, []string{"guard", "audit"}) {
+		t.Errorf("Expected hooks in configured order, got %v", calls)
+	}
+
+	_, err = registry.Resolve(HookConfig{HookEventStop: {{Hooks: []string{"missing"}}}})
+	if err == nil || !strings.Contains(err.Error(), `"missing"`) {
+		t.Errorf("Expected an error naming the unregistered hook, got %v", err)
+	}
+
+	var nilRegistry *HookRegistry
+	if _, err := nilRegistry.Resolve(HookConfig{HookEventStop: {{Hooks: []string{"audit"}}}}); err == nil {
+		t.Error("Expected named hooks without a registry to be rejected")
+	}
+	if hooks, err := nilRegistry.Resolve(nil); hooks != nil || err != nil {
+		t.Errorf("Expected an empty config to resolve to nothing, got %v, %v", hooks, err)
+	}
+}
+
+func TestLoadHookConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hooks.json")
+	data := `{
+  "PreToolUse": [{"matcher": "Bash", "hooks": ["guard", "audit"], "timeout": 30}],
+  "Stop": [{"hooks": ["audit"]}]
+}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadHookConfig(path)
+	if err != nil {
+		t.Fatalf("LoadHookConfig failed: %v", err)
+	}
+	want := HookConfig{
+		HookEventPreToolUse: {{Matcher: "Bash", Hooks: []string{"guard", "audit"}, Timeout: 30}},
+		HookEventStop:       {{Hooks: []string{"audit"}}},
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("Expected %+v, got %+v", want, config)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadHookConfig(path); err == nil {
+		t.Error("Expected invalid JSON to be rejected")
+	}
+	if _, err := LoadHookConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected a missing file to be rejected")
+	}
+}
+
+func TestAllHooks(t *testing.T) {
+	var calls []string
+	registry := NewHookRegistry()
+	registry.MustRegister("audit", namedHook("audit", &calls))
+
+	inline := HookMatcher{Hooks: []HookCallback{namedHook("inline", &calls)}}
+	opts := NewOptions(
+		WithHook(HookEventStop, inline),
+		WithNamedHooks(registry, HookConfig{HookEventStop: {{Hooks: []string{"audit"}}}}),
+	)
+
+	hooks := allHooks(opts)
+	for _, m := range hooks[HookEventStop] {
+		for _, hook := range m.Hooks {
+			_, _ = hook(context.Background(), nil, "", HookContext{})
+		}
+	}
+	if !reflect.DeepEqual(calls, []string{"inline", "audit"}) {
+		t.Errorf("Expected inline hooks before named hooks, got %v", calls)
+	}
+	if len(opts.Hooks[HookEventStop]) != 1 {
+		t.Error("Expected Options.Hooks to be left unchanged")
+	}
+
+	plain := NewOptions(WithHook(HookEventStop, inline))
+	if got := allHooks(plain); len(got[HookEventStop]) != 1 {
+		t.Errorf("Expected only inline hooks, got %v", got)
+	}
+}
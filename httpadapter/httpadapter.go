@@ -0,0 +1,152 @@
+// Package httpadapter serves Claude conversations to browser clients over
+// HTTP, using either Server-Sent Events or WebSockets.
+//
+// Each connection gets its own session, by default a claude.Client created
+// from Options.ClientOptions. Messages are streamed to the browser as JSON
+// events in the wire format produced by claude.EncodeMessage.
+//
+// Server-Sent Events serve a single prompt per request:
+//
+//	http.Handle("/chat", httpadapter.NewSSEHandler(httpadapter.Options{
+//	    ClientOptions: []claude.Option{claude.WithModel("claude-sonnet-4-5")},
+//	}))
+//
+// WebSockets serve a multi-turn conversation per connection:
+//
+//	http.Handle("/ws", httpadapter.NewWebSocketHandler(httpadapter.Options{}))
+//
+// # Protocol
+//
+// Browsers send requests as JSON objects:
+//
+//	{"type": "prompt", "prompt": "Hello"}
+//	{"type": "interrupt"}
+//
+// The SSE handler accepts a single prompt as the JSON body of a POST
+// request. Interrupts are only available over WebSockets. Both handlers
+// reject cross-origin requests unless Options.CheckOrigin allows them.
+//
+// Servers send events as JSON objects:
+//
+//	{"type": "message", "message": {...}}
+//	{"type": "error", "error": "..."}
+//
+// Over SSE the event type is also used as the SSE event name.
+package httpadapter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	claude "github.com/afsharalex/claude-agent-sdk-go"
+)
+
+// Request types sent by browser clients.
+const (
+	RequestTypePrompt    = "prompt"
+	RequestTypeInterrupt = "interrupt"
+)
+
+// Event types sent to browser clients.
+const (
+	EventTypeMessage = "message"
+	EventTypeError   = "error"
+)
+
+// Request is a message sent by a browser client.
+type Request struct {
+	Type   string `json:"type"`
+	Prompt string `json:"prompt,omitempty"`
+}
+
+// Event is a message sent to a browser client.
+type Event struct {
+	Type    string         `json:"type"`
+	Message map[string]any `json:"message,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// Session is a conversation backing a single connection.
+// *claude.Client satisfies this interface.
+type Session interface {
+	Query(ctx context.Context, prompt string) error
+	ReceiveResponse(ctx context.Context) <-chan claude.Message
+	Interrupt(ctx context.Context) error
+	Close() error
+}
+
+var _ Session = (*claude.Client)(nil)
+
+// Options configures the HTTP handlers.
+type Options struct {
+	// ClientOptions configures the claude.Client created for each connection.
+	ClientOptions []claude.Option
+
+	// NewSession creates the session for a connection. If nil, a
+	// claude.Client is created from ClientOptions and connected.
+	NewSession func(ctx context.Context, r *http.Request) (Session, error)
+
+	// CheckOrigin reports whether a request from the request's origin is
+	// allowed. If nil, only same-origin requests are allowed.
+	CheckOrigin func(r *http.Request) bool
+}
+
+// maxPendingPrompts limits how many prompts may queue behind a running
+// turn on one connection.
+const maxPendingPrompts = 16
+
+// allowOrigin reports whether r's origin is allowed.
+func (o *Options) allowOrigin(r *http.Request) bool {
+	if o.CheckOrigin != nil {
+		return o.CheckOrigin(r)
+	}
+	return sameOrigin(r)
+}
+
+// newSession creates the session for r.
+func (o *Options) newSession(ctx context.Context, r *http.Request) (Session, error) {
+	if o.NewSession != nil {
+		return o.NewSession(ctx, r)
+	}
+	client := claude.NewClient(o.ClientOptions...)
+	if err := client.Connect(ctx); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// messageEvent builds the event for msg.
+func messageEvent(msg claude.Message) Event {
+	data, err := claude.EncodeMessage(msg)
+	if err != nil {
+		return errorEvent(err)
+	}
+	return Event{Type: EventTypeMessage, Message: data}
+}
+
+// errorEvent builds the event for err.
+func errorEvent(err error) Event {
+	return Event{Type: EventTypeError, Error: err.Error()}
+}
+
+// streamTurn sends prompt to session and passes each event of the response
+// to send. It returns when the response completes or send fails.
+func streamTurn(ctx context.Context, session Session, prompt string, send func(Event) error) error {
+	if err := session.Query(ctx, prompt); err != nil {
+		return send(errorEvent(err))
+	}
+	for msg := range session.ReceiveResponse(ctx) {
+		if err := send(messageEvent(msg)); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// decodeRequest parses a browser request.
+func decodeRequest(data []byte) (Request, error) {
+	var req Request
+	err := json.Unmarshal(data, &req)
+	return req, err
+}
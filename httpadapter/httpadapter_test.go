@@ -0,0 +1,143 @@
+package httpadapter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	claude "github.com/afsharalex/claude-agent-sdk-go"
+)
+
+// fakeSession answers every prompt with an echo and a result.
+type fakeSession struct {
+	mu         sync.Mutex
+	prompts    []string
+	interrupts int
+	closed     bool
+	queryErr   error
+
+	// block, if set, holds each response open until it is closed.
+	block chan struct{}
+}
+
+func (s *fakeSession) Query(ctx context.Context, prompt string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.queryErr != nil {
+		return s.queryErr
+	}
+	s.prompts = append(s.prompts, prompt)
+	return nil
+}
+
+func (s *fakeSession) ReceiveResponse(ctx context.Context) <-chan claude.Message {
+	s.mu.Lock()
+	prompt := s.prompts[len(s.prompts)-1]
+	block := s.block
+	s.mu.Unlock()
+
+	ch := make(chan claude.Message, 2)
+	go func() {
+		defer close(ch)
+		ch <- &claude.AssistantMessage{
+			Model:   "test-model",
+			Content: []claude.ContentBlock{claude.TextBlock{Text: "echo: " + prompt}},
+		}
+		if block != nil {
+			select {
+			case <-block:
+			case <-ctx.Done():
+				return
+			}
+		}
+		ch <- &claude.ResultMessage{Subtype: "success", SessionID: "s-1"}
+	}()
+	return ch
+}
+
+func (s *fakeSession) Interrupt(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interrupts++
+	if s.block != nil {
+		close(s.block)
+		s.block = nil
+	}
+	return nil
+}
+
+func (s *fakeSession) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *fakeSession) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func fakeOptions(session *fakeSession) Options {
+	return Options{
+		NewSession: func(ctx context.Context, r *http.Request) (Session, error) {
+			return session, nil
+		},
+	}
+}
+
+func TestStreamTurn(t *testing.T) {
+	session := &fakeSession{}
+
+	var events []Event
+	err := streamTurn(context.Background(), session, "hi", func(e Event) error {
+		events = append(events, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	if events[0].Type != EventTypeMessage || events[0].Message["type"] != "assistant" {
+		t.Errorf("Expected assistant message event, got %+v", events[0])
+	}
+	if events[1].Message["type"] != "result" {
+		t.Errorf("Expected result message event, got %+v", events[1])
+	}
+}
+
+func TestStreamTurn_QueryError(t *testing.T) {
+	session := &fakeSession{queryErr: errors.New("boom")}
+
+	var events []Event
+	_ = streamTurn(context.Background(), session, "hi", func(e Event) error {
+		events = append(events, e)
+		return nil
+	})
+
+	if len(events) != 1 || events[0].Type != EventTypeError || events[0].Error != "boom" {
+		t.Errorf("Expected a single error event, got %+v", events)
+	}
+}
+
+func TestOptions_NewSessionError(t *testing.T) {
+	opts := Options{
+		NewSession: func(ctx context.Context, r *http.Request) (Session, error) {
+			return nil, errors.New("no cli")
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	NewSSEHandler(opts).ServeHTTP(rec, newPromptRequest(`{"prompt":"hi"}`))
+
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("Expected status %d, got %d", http.StatusBadGateway, rec.Code)
+	}
+}
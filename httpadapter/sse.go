@@ -0,0 +1,101 @@
+package httpadapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// maxRequestBody limits the size of prompt request bodies.
+const maxRequestBody = 1 << 20
+
+// NewSSEHandler returns a handler that answers a single prompt per request,
+// streaming the response as Server-Sent Events.
+//
+// The prompt is read from the body of a POST request with Content-Type
+// application/json. Browsers cannot send such a request cross-site without
+// a CORS preflight, and requests from other origins are rejected unless
+// allowed by Options.CheckOrigin. The session is closed when the response
+// completes or the client disconnects.
+func NewSSEHandler(opts Options) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !opts.allowOrigin(r) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "unsupported method: "+r.Method, http.StatusMethodNotAllowed)
+			return
+		}
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+			http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		prompt, err := ssePrompt(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		ctx := r.Context()
+		session, err := opts.newSession(ctx, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer func() { _ = session.Close() }()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		_ = streamTurn(ctx, session, prompt, func(event Event) error {
+			if err := writeSSEEvent(w, event); err != nil {
+				return err
+			}
+			flusher.Flush()
+			return nil
+		})
+	})
+}
+
+// ssePrompt extracts the prompt from the body of r.
+func ssePrompt(r *http.Request) (string, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to read request body: %w", err)
+	}
+	req, err := decodeRequest(body)
+	if err != nil {
+		return "", fmt.Errorf("invalid request body: %w", err)
+	}
+	if req.Type != "" && req.Type != RequestTypePrompt {
+		return "", fmt.Errorf("unsupported request type: %s", req.Type)
+	}
+	if req.Prompt == "" {
+		return "", fmt.Errorf("missing prompt")
+	}
+	return req.Prompt, nil
+}
+
+// writeSSEEvent writes event as a single Server-Sent Event.
+func writeSSEEvent(w io.Writer, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
@@ -0,0 +1,151 @@
+package httpadapter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// parseSSE splits a Server-Sent Events body into events.
+func parseSSE(t *testing.T, body string) []Event {
+	t.Helper()
+
+	var events []Event
+	for _, chunk := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		var name, data string
+		for _, line := range strings.Split(chunk, "\n") {
+			if v, ok := strings.CutPrefix(line, "event: "); ok {
+				name = v
+			}
+			if v, ok := strings.CutPrefix(line, "data: "); ok {
+				data = v
+			}
+		}
+		var event Event
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatalf("Failed to decode event data %q: %v", data, err)
+		}
+		if event.Type != name {
+			t.Errorf("Expected event name %q to match type %q", name, event.Type)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// newPromptRequest builds a JSON prompt request for the SSE handler.
+func newPromptRequest(body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestSSEHandler_Stream(t *testing.T) {
+	session := &fakeSession{}
+	handler := NewSSEHandler(fakeOptions(session))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newPromptRequest(`{"type":"prompt","prompt":"hello"}`))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected Content-Type text/event-stream, got %q", ct)
+	}
+
+	events := parseSSE(t, rec.Body.String())
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	message, _ := events[0].Message["message"].(map[string]any)
+	content, _ := message["content"].([]any)
+	if len(content) != 1 {
+		t.Fatalf("Expected 1 content block, got %v", message["content"])
+	}
+	if block, _ := content[0].(map[string]any); block["text"] != "echo: hello" {
+		t.Errorf("Expected echoed prompt, got %v", block)
+	}
+	if !session.isClosed() {
+		t.Error("Expected session to be closed after the response")
+	}
+}
+
+func TestSSEHandler_ContentTypeParams(t *testing.T) {
+	session := &fakeSession{}
+	handler := NewSSEHandler(fakeOptions(session))
+
+	rec := httptest.NewRecorder()
+	req := newPromptRequest(`{"prompt":"from body"}`)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if len(session.prompts) != 1 || session.prompts[0] != "from body" {
+		t.Errorf("Expected prompt 'from body', got %v", session.prompts)
+	}
+}
+
+func TestSSEHandler_BadRequests(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		origin      string
+		body        string
+		wantStatus  int
+	}{
+		{"get", http.MethodGet, "/chat?prompt=hello", "", "", "", http.StatusMethodNotAllowed},
+		{"unsupported method", http.MethodPut, "/chat", "application/json", "", `{"prompt":"hi"}`, http.StatusMethodNotAllowed},
+		{"form content type", http.MethodPost, "/chat", "application/x-www-form-urlencoded", "", `{"prompt":"hi"}`, http.StatusUnsupportedMediaType},
+		{"text content type", http.MethodPost, "/chat", "text/plain", "", `{"prompt":"hi"}`, http.StatusUnsupportedMediaType},
+		{"cross origin", http.MethodPost, "/chat", "application/json", "https://evil.example", `{"prompt":"hi"}`, http.StatusForbidden},
+		{"invalid json", http.MethodPost, "/chat", "application/json", "", "{", http.StatusBadRequest},
+		{"missing body prompt", http.MethodPost, "/chat", "application/json", "", `{"type":"prompt"}`, http.StatusBadRequest},
+		{"interrupt", http.MethodPost, "/chat", "application/json", "", `{"type":"interrupt"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &fakeSession{}
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			NewSSEHandler(fakeOptions(session)).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if len(session.prompts) != 0 {
+				t.Errorf("Expected no prompts, got %v", session.prompts)
+			}
+		})
+	}
+}
+
+func TestSSEHandler_CheckOrigin(t *testing.T) {
+	session := &fakeSession{}
+	opts := fakeOptions(session)
+	opts.CheckOrigin = func(r *http.Request) bool {
+		return r.Header.Get("Origin") == "https://app.example.com"
+	}
+
+	rec := httptest.NewRecorder()
+	req := newPromptRequest(`{"prompt":"hello"}`)
+	req.Header.Set("Origin", "https://app.example.com")
+	NewSSEHandler(opts).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for allowed origin, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,113 @@
+package httpadapter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// NewWebSocketHandler returns a handler that serves a multi-turn
+// conversation over a WebSocket connection.
+//
+// The browser sends prompt and interrupt requests as text frames. Prompts
+// are answered in order; each response is streamed as message events. The
+// session is closed when the connection closes.
+func NewWebSocketHandler(opts Options) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !opts.allowOrigin(r) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+
+		conn, err := upgradeWebSocket(w, r)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.close() }()
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		session, err := opts.newSession(ctx, r)
+		if err != nil {
+			_ = conn.writeJSON(errorEvent(err))
+			return
+		}
+		defer func() { _ = session.Close() }()
+
+		serveWebSocket(ctx, cancel, conn, session)
+	})
+}
+
+// serveWebSocket runs the conversation on conn until it closes.
+func serveWebSocket(ctx context.Context, cancel context.CancelFunc, conn *wsConn, session Session) {
+	send := func(event Event) error {
+		return conn.writeJSON(event)
+	}
+
+	// Read requests in the background so interrupts are handled while a
+	// response is streaming. Prompts are queued for the turn loop below;
+	// the reader never blocks on a full queue, so it keeps handling
+	// interrupts and pings.
+	prompts := make(chan string, maxPendingPrompts)
+	go func() {
+		defer cancel()
+		defer close(prompts)
+		for {
+			data, err := conn.readMessage()
+			if err != nil {
+				return
+			}
+			req, err := decodeRequest(data)
+			if err != nil {
+				_ = send(Event{Type: EventTypeError, Error: "invalid request: " + err.Error()})
+				continue
+			}
+			switch req.Type {
+			case RequestTypePrompt:
+				select {
+				case prompts <- req.Prompt:
+				default:
+					_ = send(Event{Type: EventTypeError, Error: "too many pending prompts"})
+				}
+			case RequestTypeInterrupt:
+				if err := session.Interrupt(ctx); err != nil {
+					_ = send(errorEvent(err))
+				}
+			default:
+				_ = send(Event{Type: EventTypeError, Error: "unsupported request type: " + req.Type})
+			}
+		}
+	}()
+
+	for prompt := range prompts {
+		if err := streamTurn(ctx, session, prompt, send); err != nil {
+			return
+		}
+	}
+}
+
+// sameOrigin reports whether the request's Origin header, if any, matches
+// its Host.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// writeJSON sends v as a text frame.
+func (c *wsConn) writeJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(wsOpText, data)
+}
@@ -0,0 +1,280 @@
+package httpadapter
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsTestClient is a minimal WebSocket client for exercising the handler.
+type wsTestClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialWebSocket(t *testing.T, server *httptest.Server, origin string) *wsTestClient {
+	t.Helper()
+
+	addr := strings.TrimPrefix(server.URL, "http://")
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	req := "GET /ws HTTP/1.1\r\n" +
+		"Host: " + addr + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\n" +
+		"Sec-WebSocket-Version: 13\r\n"
+	if origin != "" {
+		req += "Origin: " + origin + "\r\n"
+	}
+	req += "\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		t.Fatalf("Failed to write handshake: %v", err)
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatalf("Failed to read handshake response: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected status 101, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Unexpected Sec-WebSocket-Accept %q", got)
+	}
+
+	return &wsTestClient{conn: conn, r: r}
+}
+
+func (c *wsTestClient) send(t *testing.T, opcode byte, payload []byte) {
+	t.Helper()
+
+	frame := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, 0x80|byte(n))
+	default:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	}
+	mask := [4]byte{1, 2, 3, 4}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatalf("Failed to write frame: %v", err)
+	}
+}
+
+func (c *wsTestClient) sendJSON(t *testing.T, v any) {
+	t.Helper()
+	data, _ := json.Marshal(v)
+	c.send(t, wsOpText, data)
+}
+
+func (c *wsTestClient) readFrame(t *testing.T) (byte, []byte) {
+	t.Helper()
+
+	var header [2]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		t.Fatalf("Failed to read frame: %v", err)
+	}
+	length := int(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		_, _ = io.ReadFull(c.r, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, _ = io.ReadFull(c.r, ext[:])
+		length = int(binary.BigEndian.Uint64(ext[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		t.Fatalf("Failed to read payload: %v", err)
+	}
+	return header[0] & 0x0F, payload
+}
+
+func (c *wsTestClient) readEvent(t *testing.T) Event {
+	t.Helper()
+
+	opcode, payload := c.readFrame(t)
+	if opcode != wsOpText {
+		t.Fatalf("Expected text frame, got opcode %d", opcode)
+	}
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		t.Fatalf("Failed to decode event: %v", err)
+	}
+	return event
+}
+
+func TestWebSocketHandler_Conversation(t *testing.T) {
+	session := &fakeSession{}
+	server := httptest.NewServer(NewWebSocketHandler(fakeOptions(session)))
+	defer server.Close()
+
+	client := dialWebSocket(t, server, "")
+
+	for i, prompt := range []string{"first", "second"} {
+		client.sendJSON(t, Request{Type: RequestTypePrompt, Prompt: prompt})
+
+		event := client.readEvent(t)
+		message, _ := event.Message["message"].(map[string]any)
+		content, _ := message["content"].([]any)
+		if len(content) != 1 {
+			t.Fatalf("Turn %d: expected 1 content block, got %v", i, event)
+		}
+		if block, _ := content[0].(map[string]any); block["text"] != "echo: "+prompt {
+			t.Errorf("Turn %d: expected echoed prompt, got %v", i, block)
+		}
+
+		event = client.readEvent(t)
+		if event.Message["type"] != "result" {
+			t.Errorf("Turn %d: expected result event, got %+v", i, event)
+		}
+	}
+
+	// Closing the connection closes the session
+	client.send(t, wsOpClose, nil)
+	if opcode, _ := client.readFrame(t); opcode != wsOpClose {
+		t.Errorf("Expected close frame, got opcode %d", opcode)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !session.isClosed() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !session.isClosed() {
+		t.Error("Expected session to be closed")
+	}
+}
+
+func TestWebSocketHandler_Interrupt(t *testing.T) {
+	session := &fakeSession{block: make(chan struct{})}
+	server := httptest.NewServer(NewWebSocketHandler(fakeOptions(session)))
+	defer server.Close()
+
+	client := dialWebSocket(t, server, "")
+	client.sendJSON(t, Request{Type: RequestTypePrompt, Prompt: "long task"})
+
+	if event := client.readEvent(t); event.Message["type"] != "assistant" {
+		t.Fatalf("Expected assistant event, got %+v", event)
+	}
+
+	// The response is blocked until the interrupt is handled
+	client.sendJSON(t, Request{Type: RequestTypeInterrupt})
+	if event := client.readEvent(t); event.Message["type"] != "result" {
+		t.Errorf("Expected result event after interrupt, got %+v", event)
+	}
+
+	session.mu.Lock()
+	interrupts := session.interrupts
+	session.mu.Unlock()
+	if interrupts != 1 {
+		t.Errorf("Expected 1 interrupt, got %d", interrupts)
+	}
+}
+
+func TestWebSocketHandler_PromptQueueFull(t *testing.T) {
+	session := &fakeSession{block: make(chan struct{})}
+	server := httptest.NewServer(NewWebSocketHandler(fakeOptions(session)))
+	defer server.Close()
+
+	client := dialWebSocket(t, server, "")
+	client.sendJSON(t, Request{Type: RequestTypePrompt, Prompt: "long task"})
+	if event := client.readEvent(t); event.Message["type"] != "assistant" {
+		t.Fatalf("Expected assistant event, got %+v", event)
+	}
+
+	// Fill the queue behind the running turn, then overflow it
+	for i := 0; i <= maxPendingPrompts; i++ {
+		client.sendJSON(t, Request{Type: RequestTypePrompt, Prompt: fmt.Sprintf("queued %d", i)})
+	}
+	if event := client.readEvent(t); event.Type != EventTypeError {
+		t.Fatalf("Expected error event for a full queue, got %+v", event)
+	}
+
+	// The reader is not blocked, so the interrupt still gets through
+	client.sendJSON(t, Request{Type: RequestTypeInterrupt})
+	if event := client.readEvent(t); event.Message["type"] != "result" {
+		t.Errorf("Expected result event after interrupt, got %+v", event)
+	}
+}
+
+func TestWebSocketHandler_Ping(t *testing.T) {
+	server := httptest.NewServer(NewWebSocketHandler(fakeOptions(&fakeSession{})))
+	defer server.Close()
+
+	client := dialWebSocket(t, server, "")
+	client.send(t, wsOpPing, []byte("hi"))
+
+	opcode, payload := client.readFrame(t)
+	if opcode != wsOpPong || string(payload) != "hi" {
+		t.Errorf("Expected pong 'hi', got opcode %d payload %q", opcode, payload)
+	}
+}
+
+func TestWebSocketHandler_InvalidRequest(t *testing.T) {
+	server := httptest.NewServer(NewWebSocketHandler(fakeOptions(&fakeSession{})))
+	defer server.Close()
+
+	client := dialWebSocket(t, server, "")
+	client.send(t, wsOpText, []byte("not json"))
+
+	if event := client.readEvent(t); event.Type != EventTypeError {
+		t.Errorf("Expected error event, got %+v", event)
+	}
+}
+
+func TestWebSocketHandler_Origin(t *testing.T) {
+	server := httptest.NewServer(NewWebSocketHandler(fakeOptions(&fakeSession{})))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Origin", "https://evil.example")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", resp.StatusCode)
+	}
+
+	// Same-origin requests are allowed
+	dialWebSocket(t, server, fmt.Sprintf("http://%s", strings.TrimPrefix(server.URL, "http://")))
+}
+
+func TestWebSocketHandler_NotUpgrade(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	NewWebSocketHandler(fakeOptions(&fakeSession{})).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rec.Code)
+	}
+}
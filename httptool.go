@@ -0,0 +1,274 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// HTTPToolServerName is the name of the server returned by
+// HTTPToolServer. Its tools are "mcp__http__get" and "mcp__http__post",
+// see MCPToolRef.
+const HTTPToolServerName = "http"
+
+// DefaultHTTPToolTimeout and DefaultHTTPToolMaxResponse are the limits of
+// a URLPolicy that sets none.
+const (
+	DefaultHTTPToolTimeout     = 30 * time.Second
+	DefaultHTTPToolMaxResponse = 100_000
+)
+
+// URLPolicy governs the requests made by HTTPToolServer.
+type URLPolicy struct {
+	// Allow lists the domains requests may go to. A domain covers its
+	// subdomains; a leading "*." is optional. A request to a domain
+	// matching no entry is refused. Deny lists exceptions, which take
+	// precedence.
+	Allow []string
+	Deny  []string
+
+	// AllowHTTP permits plain http URLs. Only https is permitted
+	// otherwise.
+	AllowHTTP bool
+
+	// Headers are added to the requests to each domain, keyed like Allow,
+	// such as to authenticate with an internal API. They replace headers
+	// of the same name set by Claude, and are not sent to other domains,
+	// including on redirects.
+	Headers map[string]map[string]string
+
+	// Timeout limits each request. Defaults to DefaultHTTPToolTimeout.
+	Timeout time.Duration
+
+	// MaxResponse caps the bytes of a response body returned to Claude.
+	// Longer bodies are truncated. Defaults to DefaultHTTPToolMaxResponse.
+	MaxResponse int
+}
+
+// timeout returns the timeout of a request.
+func (p URLPolicy) timeout() time.Duration {
+	if p.Timeout > 0 {
+		return p.Timeout
+	}
+	return DefaultHTTPToolTimeout
+}
+
+// maxResponse returns the cap on response bodies.
+func (p URLPolicy) maxResponse() int {
+	if p.MaxResponse > 0 {
+		return p.MaxResponse
+	}
+	return DefaultHTTPToolMaxResponse
+}
+
+// check returns why u is outside the policy, or nil if it is not.
+func (p URLPolicy) check(u *url.URL) error {
+	switch {
+	case u.Scheme != "https" && (u.Scheme != "http" || !p.AllowHTTP):
+		return fmt.Errorf("URL scheme %q is not permitted", u.Scheme)
+	case len(p.Allow) == 0 || !(webDomainPolicy{allow: p.Allow, deny: p.Deny}).permits(u.Hostname()):
+		return fmt.Errorf("domain %s is not permitted; permitted domains: %s", u.Hostname(), strings.Join(p.Allow, ", "))
+	}
+	return nil
+}
+
+// headers returns the headers added to requests to host.
+func (p URLPolicy) headers(host string) map[string]string {
+	headers := map[string]string{}
+	for domain, values := range p.Headers {
+		if matchesDomain(host, domain) {
+			for name, value := range values {
+				headers[name] = value
+			}
+		}
+	}
+	return headers
+}
+
+// HTTPToolServer returns an in-process MCP server with tools, get and
+// post, that make HTTP requests with client under policy, so that Claude
+// calls internal APIs through a tool governed in Go rather than with curl.
+// A nil client is http.DefaultClient.
+//
+// Requests, and the redirects they follow, are refused outside the
+// domains of policy. The headers of policy, such as credentials, are added
+// to the requests to their domain, and never sent elsewhere. Each result
+// holds the status and body of the response, truncated to
+// policy.MaxResponse, and is an error result for statuses of 400 and over.
+//
+// Example:
+//
+//	client := claude.NewClient(
+//	    claude.WithMCPServers(map[string]claude.MCPServerConfig{
+//	        claude.HTTPToolServerName: claude.HTTPToolServer(nil, claude.URLPolicy{
+//	            Allow:   []string{"api.internal.example.com"},
+//	            Headers: map[string]map[string]string{
+//	                "api.internal.example.com": {"Authorization": "Bearer " + token},
+//	            },
+//	        }),
+//	    }),
+//	    claude.WithMCPServerAllowed(claude.HTTPToolServerName),
+//	)
+func HTTPToolServer(client *http.Client, policy URLPolicy) MCPSDKServerConfig {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	h := httpTool{client: policy.governed(client), policy: policy}
+	domains := fmt.Sprintf(" Permitted domains: %s.", strings.Join(policy.Allow, ", "))
+	headers := map[string]any{
+		"type":                 "object",
+		"description":          "Request headers",
+		"additionalProperties": map[string]any{"type": "string"},
+	}
+	server := NewMCPServer(HTTPToolServerName, "1.0.0", []MCPTool{
+		Tool("get", "Make an HTTP GET request and return the status and body of the response."+domains,
+			objectSchema(map[string]any{
+				"url":     stringProperty("The URL"),
+				"headers": headers,
+			}, "url"), h.get),
+		Tool("post", "Make an HTTP POST request and return the status and body of the response."+domains,
+			objectSchema(map[string]any{
+				"url":          stringProperty("The URL"),
+				"body":         stringProperty("The request body"),
+				"content_type": stringProperty("Content type of the body; application/json if empty"),
+				"headers":      headers,
+			}, "url"), h.post),
+	})
+	return MCPSDKServerConfig{
+		Type:   "sdk",
+		Name:   HTTPToolServerName,
+		Server: server.ValidateInputs(true),
+	}
+}
+
+// governed returns a copy of client whose redirects follow the policy:
+// redirects outside it are refused, and the headers of the policy are
+// replaced by those of the domain redirected to.
+func (p URLPolicy) governed(client *http.Client) *http.Client {
+	governed := *client
+	checkRedirect := client.CheckRedirect
+	governed.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if err := p.check(req.URL); err != nil {
+			return fmt.Errorf("redirect refused: %w", err)
+		}
+		// A redirect carries the headers of the first request, so remove
+		// those of every domain before adding those of the new one
+		for _, headers := range p.Headers {
+			for name := range headers {
+				req.Header.Del(name)
+			}
+		}
+		for name, value := range p.headers(req.URL.Hostname()) {
+			req.Header.Set(name, value)
+		}
+		if checkRedirect != nil {
+			return checkRedirect(req, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return &governed
+}
+
+// httpTool implements the tools of HTTPToolServer.
+type httpTool struct {
+	client *http.Client
+	policy URLPolicy
+}
+
+func (h httpTool) get(ctx context.Context, args map[string]any) (MCPToolResult, error) {
+	return h.do(ctx, http.MethodGet, args, nil)
+}
+
+func (h httpTool) post(ctx context.Context, args map[string]any) (MCPToolResult, error) {
+	body, _ := args["body"].(string)
+	return h.do(ctx, http.MethodPost, args, strings.NewReader(body))
+}
+
+// do makes a request from the arguments of a tool call.
+func (h httpTool) do(ctx context.Context, method string, args map[string]any, body io.Reader) (MCPToolResult, error) {
+	raw, _ := args["url"].(string)
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("invalid URL %q: %v", raw, err)), nil
+	}
+	if err := h.policy.check(u); err != nil {
+		return ErrorResult("request refused: " + err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.policy.timeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return ErrorResult(err.Error()), nil
+	}
+	if headers, ok := args["headers"].(map[string]any); ok {
+		for name, value := range headers {
+			if s, ok := value.(string); ok {
+				req.Header.Set(name, s)
+			}
+		}
+	}
+	if body != nil && req.Header.Get("Content-Type") == "" {
+		contentType, _ := args["content_type"].(string)
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		req.Header.Set("Content-Type", contentType)
+	}
+	for name, value := range h.policy.headers(u.Hostname()) {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("request failed: %v", err)), nil
+	}
+	defer func() { _ = resp.Body.Close() }()
+	limit := h.policy.maxResponse()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(limit)+1))
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("reading response failed: %v", err)), nil
+	}
+	truncated := len(data) > limit
+	if truncated {
+		data = data[:limit]
+		// Do not end on part of a character
+		for i := 0; i < utf8.UTFMax-1 && len(data) > 0 && !utf8.Valid(data); i++ {
+			data = data[:len(data)-1]
+		}
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "HTTP %s\n", resp.Status)
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		fmt.Fprintf(&text, "Content-Type: %s\n", contentType)
+	}
+	text.WriteString("\n")
+	if utf8.Valid(data) {
+		text.Write(data)
+	} else {
+		fmt.Fprintf(&text, "[%d bytes of binary content not shown]", len(data))
+	}
+	if truncated {
+		fmt.Fprintf(&text, "\n[response truncated at %d bytes]", limit)
+	}
+	return MCPToolResult{
+		Content: []MCPContent{{Type: "text", Text: text.String()}},
+		IsError: resp.StatusCode >= 400,
+		Structured: map[string]any{
+			"status":       resp.StatusCode,
+			"url":          resp.Request.URL.String(),
+			"content_type": resp.Header.Get("Content-Type"),
+			"truncated":    truncated,
+		},
+	}, nil
+}
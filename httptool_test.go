@@ -0,0 +1,177 @@
+package claude
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// callHTTPTool calls tool of an HTTPToolServer with client and policy.
+func callHTTPTool(t *testing.T, client *http.Client, policy URLPolicy, tool string, args map[string]any) MCPToolResult {
+	t.Helper()
+	for _, candidate := range HTTPToolServer(client, policy).Server.Tools() {
+		if candidate.Name == tool {
+			result, err := candidate.Handler(context.Background(), args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return result
+		}
+	}
+	t.Fatalf("No tool %s", tool)
+	return MCPToolResult{}
+}
+
+func TestURLPolicy_Check(t *testing.T) {
+	policy := URLPolicy{Allow: []string{"example.com"}, Deny: []string{"admin.example.com"}}
+	for raw, permitted := range map[string]bool{
+		"https://example.com/v1":        true,
+		"https://api.example.com/v1":    true,
+		"http://example.com/v1":         false,
+		"https://admin.example.com/":    false,
+		"https://example.org/":          false,
+		"file:///etc/passwd":            false,
+		"https://example.com.evil.net/": false,
+	} {
+		u, _ := url.Parse(raw)
+		if err := policy.check(u); (err == nil) != permitted {
+			t.Errorf("check(%s) = %v, want permitted %v", raw, err, permitted)
+		}
+	}
+	u, _ := url.Parse("https://example.com")
+	if err := (URLPolicy{}).check(u); err == nil {
+		t.Error("Expected a policy allowing nothing to refuse every request")
+	}
+}
+
+func TestHTTPToolServer_Requests(t *testing.T) {
+	var got *http.Request
+	var gotBody string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		if r.URL.Path == "/missing" {
+			http.Error(w, "no such item", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"items":[1,2,3]}`)
+	}))
+	defer server.Close()
+
+	policy := URLPolicy{
+		Allow:   []string{"127.0.0.1"},
+		Headers: map[string]map[string]string{"127.0.0.1": {"Authorization": "Bearer secret"}},
+	}
+	result := callHTTPTool(t, server.Client(), policy, "get", map[string]any{
+		"url":     server.URL + "/items",
+		"headers": map[string]any{"Authorization": "Bearer forged", "Accept": "application/json"},
+	})
+	if result.IsError || result.Content[0].Text != "HTTP 200 OK\nContent-Type: application/json\n\n{\"items\":[1,2,3]}" {
+		t.Errorf("Expected the response returned, got %+v", result)
+	}
+	if got.Header.Get("Authorization") != "Bearer secret" || got.Header.Get("Accept") != "application/json" {
+		t.Errorf("Expected the policy headers to replace Claude's, got %v", got.Header)
+	}
+
+	result = callHTTPTool(t, server.Client(), policy, "post", map[string]any{"url": server.URL + "/items", "body": `{"n":4}`})
+	if result.IsError || got.Method != http.MethodPost || gotBody != `{"n":4}` || got.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected the body posted as JSON, got %s %q %v", got.Method, gotBody, got.Header)
+	}
+
+	result = callHTTPTool(t, server.Client(), policy, "get", map[string]any{"url": server.URL + "/missing"})
+	if !result.IsError || result.Structured.(map[string]any)["status"] != http.StatusNotFound {
+		t.Errorf("Expected an error result for a 404, got %+v", result)
+	}
+
+	result = callHTTPTool(t, server.Client(), URLPolicy{Allow: []string{"example.com"}}, "get", map[string]any{"url": server.URL})
+	if !result.IsError || !strings.Contains(result.Content[0].Text, "not permitted") {
+		t.Errorf("Expected a domain outside the policy refused, got %+v", result)
+	}
+}
+
+func TestHTTPToolServer_Redirects(t *testing.T) {
+	var leaked http.Header
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = r.Header
+		_, _ = io.WriteString(w, "other")
+	}))
+	defer other.Close()
+	// localhost and 127.0.0.1 are different domains to the policy
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, otherURL, http.StatusFound)
+	}))
+	defer server.Close()
+
+	headers := map[string]map[string]string{"127.0.0.1": {"X-Api-Key": "secret"}}
+	result := callHTTPTool(t, nil, URLPolicy{Allow: []string{"127.0.0.1"}, AllowHTTP: true, Headers: headers}, "get", map[string]any{"url": server.URL})
+	if !result.IsError || !strings.Contains(result.Content[0].Text, "redirect refused") || leaked != nil {
+		t.Errorf("Expected a redirect outside the policy refused, got %+v", result)
+	}
+
+	result = callHTTPTool(t, nil, URLPolicy{Allow: []string{"127.0.0.1", "localhost"}, AllowHTTP: true, Headers: headers}, "get", map[string]any{"url": server.URL})
+	if result.IsError || !strings.HasSuffix(result.Content[0].Text, "other") {
+		t.Fatalf("Expected the redirect followed, got %+v", result)
+	}
+	if leaked.Get("X-Api-Key") != "" {
+		t.Errorf("Expected the policy headers not sent to another domain, got %v", leaked)
+	}
+}
+
+func TestHTTPToolServer_MultiHopRedirects(t *testing.T) {
+	// a.test redirects to b.test, which redirects to c.test
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Host {
+		case "a.test":
+			http.Redirect(w, r, "http://b.test/", http.StatusFound)
+		case "b.test":
+			http.Redirect(w, r, "http://c.test/", http.StatusFound)
+		default:
+			got = r.Header
+		}
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+		},
+	}}
+
+	policy := URLPolicy{
+		Allow:     []string{"a.test", "b.test", "c.test"},
+		AllowHTTP: true,
+		Headers: map[string]map[string]string{
+			"a.test": {"X-Api-Key": "a-secret"},
+			"b.test": {"Authorization": "Bearer b-secret"},
+		},
+	}
+	result := callHTTPTool(t, client, policy, "get", map[string]any{"url": "http://a.test/"})
+	if result.IsError || result.Structured.(map[string]any)["url"] != "http://c.test/" {
+		t.Fatalf("Expected both redirects followed, got %+v", result)
+	}
+	if got.Get("X-Api-Key") != "" || got.Get("Authorization") != "" {
+		t.Errorf("Expected no policy headers sent to c.test, got %v", got)
+	}
+}
+
+func TestHTTPToolServer_MaxResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, strings.Repeat("é", 10))
+	}))
+	defer server.Close()
+
+	policy := URLPolicy{Allow: []string{"127.0.0.1"}, AllowHTTP: true, MaxResponse: 5}
+	result := callHTTPTool(t, nil, policy, "get", map[string]any{"url": server.URL})
+	text := result.Content[0].Text
+	if !strings.Contains(text, "\n\néé\n[response truncated at 5 bytes]") || result.Structured.(map[string]any)["truncated"] != true {
+		t.Errorf("Expected the body truncated on a character boundary, got %q", text)
+	}
+}
@@ -0,0 +1,113 @@
+package claude
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// idleMonitor sends keepalives and detects idle connections for a Client.
+// A nil monitor is valid and does nothing.
+type idleMonitor struct {
+	keepAlive time.Duration
+	timeout   time.Duration
+
+	mu            sync.Mutex
+	lastActivity  time.Time
+	lastKeepAlive time.Time
+}
+
+// newIdleMonitor returns a monitor for the options, or nil if neither
+// keepalives nor the idle timeout are enabled.
+func newIdleMonitor(o *Options) *idleMonitor {
+	if o.KeepAliveInterval <= 0 && o.IdleTimeout <= 0 {
+		return nil
+	}
+	return &idleMonitor{keepAlive: o.KeepAliveInterval, timeout: o.IdleTimeout}
+}
+
+// interval returns how often the monitor should be checked.
+func (m *idleMonitor) interval() time.Duration {
+	interval := m.keepAlive
+	if m.timeout > 0 && (interval <= 0 || m.timeout/4 < interval) {
+		interval = m.timeout / 4
+	}
+	return max(interval, time.Millisecond)
+}
+
+// ticker returns a ticker channel for the monitor and a stop function.
+// For a nil monitor the channel is nil and never fires.
+func (m *idleMonitor) ticker() (<-chan time.Time, func()) {
+	if m == nil {
+		return nil, func() {}
+	}
+	t := time.NewTicker(m.interval())
+	return t.C, t.Stop
+}
+
+// touch records activity on the connection.
+func (m *idleMonitor) touch() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastActivity = time.Now()
+}
+
+// check reports whether a keepalive is due at now, and for how long the
+// connection has been idle if that exceeds the timeout. A connection
+// with a turn in progress is never idle.
+func (m *idleMonitor) check(now time.Time, turnActive bool) (keepAlive bool, idle time.Duration) {
+	if m == nil {
+		return false, 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.keepAlive > 0 && now.Sub(m.lastKeepAlive) >= m.keepAlive {
+		m.lastKeepAlive = now
+		keepAlive = true
+	}
+	if turnActive {
+		m.lastActivity = now
+	} else if m.timeout > 0 && now.Sub(m.lastActivity) >= m.timeout {
+		idle = now.Sub(m.lastActivity)
+		// Report once, not on every check until the connection closes
+		m.lastActivity = now
+	}
+	return keepAlive, idle
+}
+
+// checkIdle sends a keepalive if one is due and closes the connection if
+// it has been idle for too long.
+func (c *Client) checkIdle(now time.Time, errs *errorSink) {
+	c.turnMu.Lock()
+	active := c.turnActive
+	sessionID := c.lastSessionID
+	c.turnMu.Unlock()
+
+	keepAlive, idle := c.idle.check(now, active)
+	if keepAlive {
+		go func() {
+			c.mu.Lock()
+			query := c.query
+			c.mu.Unlock()
+			if query != nil {
+				_ = query.KeepAlive(context.Background())
+			}
+		}()
+	}
+	if idle > 0 {
+		errs.add(NewIdleTimeoutError(sessionID, idle))
+		go c.closeIdle(sessionID)
+	}
+}
+
+// closeIdle closes an idle connection, to be resumed by the next Connect.
+func (c *Client) closeIdle(sessionID string) {
+	c.mu.Lock()
+	c.idleResume = sessionID
+	c.mu.Unlock()
+	_ = c.Close()
+}
@@ -0,0 +1,124 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdleMonitor_Disabled(t *testing.T) {
+	if m := newIdleMonitor(NewOptions()); m != nil {
+		t.Errorf("Expected nil monitor, got %+v", m)
+	}
+	var m *idleMonitor
+	m.touch()
+	if keepAlive, idle := m.check(time.Now(), false); keepAlive || idle != 0 {
+		t.Error("Expected nil monitor to do nothing")
+	}
+}
+
+func TestIdleMonitor_Check(t *testing.T) {
+	m := newIdleMonitor(NewOptions(WithKeepAlive(10*time.Second), WithIdleTimeout(time.Minute)))
+	if got := m.interval(); got != 10*time.Second {
+		t.Errorf("Expected the keepalive interval, got %v", got)
+	}
+
+	start := time.Now()
+	m.lastActivity = start
+	m.lastKeepAlive = start
+
+	if keepAlive, idle := m.check(start.Add(5*time.Second), false); keepAlive || idle != 0 {
+		t.Error("Expected nothing due after 5s")
+	}
+	if keepAlive, _ := m.check(start.Add(10*time.Second), false); !keepAlive {
+		t.Error("Expected a keepalive after 10s")
+	}
+
+	// A turn in progress is activity
+	if _, idle := m.check(start.Add(50*time.Second), true); idle != 0 {
+		t.Error("Expected a connection with a turn in progress not to be idle")
+	}
+	if _, idle := m.check(start.Add(109*time.Second), false); idle != 0 {
+		t.Error("Expected idle time to count from the end of the turn")
+	}
+	if _, idle := m.check(start.Add(110*time.Second), false); idle != time.Minute {
+		t.Errorf("Expected idle for a minute, got %v", idle)
+	}
+	if _, idle := m.check(start.Add(111*time.Second), false); idle != 0 {
+		t.Error("Expected idleness to be reported once")
+	}
+}
+
+func TestIdleMonitor_IntervalFollowsTimeout(t *testing.T) {
+	m := newIdleMonitor(NewOptions(WithIdleTimeout(time.Minute)))
+	if got := m.interval(); got != 15*time.Second {
+		t.Errorf("Expected a quarter of the timeout, got %v", got)
+	}
+}
+
+func TestClient_IdleTimeout(t *testing.T) {
+	dir := t.TempDir()
+	cli := writeStubCLI(t, fmt.Sprintf(`
+echo "$*" >> %[1]s/args
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+while read line; do
+  echo "$line" >> %[1]s/stdin
+  case "$line" in
+  *'"type":"user"'*)
+    echo '{"type":"system","subtype":"init","session_id":"sess-1"}'
+    echo '{"type":"result","subtype":"success","session_id":"sess-1"}'
+    ;;
+  esac
+done
+`, dir))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli), WithKeepAlive(20*time.Millisecond), WithIdleTimeout(300*time.Millisecond))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.Query(ctx, "Hello"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	for range client.Messages() {
+		// Drained once the idle connection is closed
+	}
+
+	var idleErr *IdleTimeoutError
+	for err := range client.Errors() {
+		if e, ok := AsIdleTimeoutError(err); ok {
+			idleErr = e
+		}
+	}
+	if idleErr == nil {
+		t.Fatal("Expected an IdleTimeoutError")
+	}
+	if idleErr.SessionID != "sess-1" || idleErr.Idle < 300*time.Millisecond {
+		t.Errorf("Unexpected error %+v", idleErr)
+	}
+
+	stdin, _ := os.ReadFile(filepath.Join(dir, "stdin"))
+	if !strings.Contains(string(stdin), `{"type":"keep_alive"}`) {
+		t.Errorf("Expected keepalives to be sent, got %s", stdin)
+	}
+
+	// Connecting again resumes the session
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Reconnect failed: %v", err)
+	}
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	lines := strings.Split(strings.TrimSpace(string(args)), "\n")
+	if len(lines) != 2 || strings.Contains(lines[0], "--resume") || !strings.Contains(lines[1], "--resume sess-1") {
+		t.Errorf("Expected the second connection to resume sess-1, got %q", lines)
+	}
+}
@@ -0,0 +1,153 @@
+package claude
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // GIF decoder
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Limits that ImageBytesResult scales images down to. The API resizes
+// larger images anyway, and rejects images above its size limit.
+const (
+	// MaxImageDimension is the longest side of an image in pixels.
+	MaxImageDimension = 1568
+
+	// MaxImageBytes is the size of an encoded image, before base64.
+	MaxImageBytes = 3 << 20
+)
+
+// jpegQuality is the quality of images re-encoded as JPEG.
+const jpegQuality = 85
+
+// ImageBytesResult creates an MCPToolResult with an image from its
+// encoded bytes. The MIME type is detected from the data. PNG, JPEG and
+// GIF images larger than MaxImageDimension or MaxImageBytes are scaled
+// down first; GIFs are converted to PNG when they are.
+//
+// Example:
+//
+//	png, err := renderChart(data)
+//	if err != nil {
+//		return claude.ErrorResult(err.Error()), nil
+//	}
+//	return claude.ImageBytesResult(png)
+func ImageBytesResult(data []byte) (MCPToolResult, error) {
+	mimeType := http.DetectContentType(data)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return MCPToolResult{}, fmt.Errorf("not an image: detected %s", mimeType)
+	}
+
+	data, mimeType, err := fitImage(data, mimeType)
+	if err != nil {
+		return MCPToolResult{}, err
+	}
+	return ImageResult(base64.StdEncoding.EncodeToString(data), mimeType), nil
+}
+
+// ImageResultFromFile creates an MCPToolResult with the image in the file
+// at path, as ImageBytesResult does.
+func ImageResultFromFile(path string) (MCPToolResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return MCPToolResult{}, err
+	}
+	result, err := ImageBytesResult(data)
+	if err != nil {
+		return MCPToolResult{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return result, nil
+}
+
+// fitImage scales an image down to the limits if needed and returns it
+// re-encoded, with its MIME type.
+func fitImage(data []byte, mimeType string) ([]byte, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		// Formats the standard library cannot decode, such as WebP, are
+		// passed on as they are
+		if len(data) > MaxImageBytes {
+			return nil, "", fmt.Errorf("%s image of %d bytes exceeds %d bytes", mimeType, len(data), MaxImageBytes)
+		}
+		return data, mimeType, nil
+	}
+	if max(config.Width, config.Height) <= MaxImageDimension && len(data) <= MaxImageBytes {
+		return data, mimeType, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("decoding %s image: %w", format, err)
+	}
+
+	width, height := config.Width, config.Height
+	if longest := max(width, height); longest > MaxImageDimension {
+		width = max(1, width*MaxImageDimension/longest)
+		height = max(1, height*MaxImageDimension/longest)
+	}
+
+	// Shrink until the encoded image fits
+	for {
+		scaled := scaleImage(img, width, height)
+
+		var buf bytes.Buffer
+		if format == "jpeg" {
+			err = jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: jpegQuality})
+			mimeType = "image/jpeg"
+		} else {
+			err = png.Encode(&buf, scaled)
+			mimeType = "image/png"
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if buf.Len() <= MaxImageBytes || (width == 1 && height == 1) {
+			return buf.Bytes(), mimeType, nil
+		}
+		width, height = max(1, width*3/4), max(1, height*3/4)
+	}
+}
+
+// scaleImage scales img down to width by height pixels, averaging the
+// source pixels covered by each target pixel.
+func scaleImage(img image.Image, width, height int) *image.RGBA {
+	bounds := img.Bounds()
+	src, ok := img.(*image.RGBA)
+	if !ok || bounds.Min != (image.Point{}) {
+		src = image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	}
+	srcW, srcH := src.Bounds().Dx(), src.Bounds().Dy()
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		y0, y1 := y*srcH/height, max((y+1)*srcH/height, y*srcH/height+1)
+		for x := range width {
+			x0, x1 := x*srcW/width, max((x+1)*srcW/width, x*srcW/width+1)
+
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride+x0*4 : sy*src.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			i := y*dst.Stride + x*4
+			for c := range 4 {
+				dst.Pix[i+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
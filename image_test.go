@@ -0,0 +1,131 @@
+package claude
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func encodeTestImage(t *testing.T, format string, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decodeResultImage(t *testing.T, result MCPToolResult) (image.Config, string) {
+	t.Helper()
+	if len(result.Content) != 1 || result.Content[0].Type != "image" {
+		t.Fatalf("Expected one image, got %+v", result.Content)
+	}
+	data, err := base64.StdEncoding.DecodeString(result.Content[0].Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config, result.Content[0].MimeType
+}
+
+func TestImageBytesResult(t *testing.T) {
+	small := encodeTestImage(t, "png", 40, 30)
+	result, err := ImageBytesResult(small)
+	if err != nil {
+		t.Fatalf("ImageBytesResult failed: %v", err)
+	}
+	if result.Content[0].MimeType != "image/png" || result.Content[0].Data != base64.StdEncoding.EncodeToString(small) {
+		t.Error("Expected a small image to be passed on unchanged")
+	}
+
+	tests := []struct {
+		format   string
+		mimeType string
+	}{
+		{"png", "image/png"},
+		{"jpeg", "image/jpeg"},
+		{"gif", "image/png"},
+	}
+	for _, tt := range tests {
+		result, err := ImageBytesResult(encodeTestImage(t, tt.format, 2000, 500))
+		if err != nil {
+			t.Fatalf("%s: ImageBytesResult failed: %v", tt.format, err)
+		}
+		config, mimeType := decodeResultImage(t, result)
+		if config.Width != MaxImageDimension || config.Height != 392 {
+			t.Errorf("%s: expected %dx392, got %dx%d", tt.format, MaxImageDimension, config.Width, config.Height)
+		}
+		if mimeType != tt.mimeType {
+			t.Errorf("%s: expected %s, got %s", tt.format, tt.mimeType, mimeType)
+		}
+	}
+}
+
+func TestImageBytesResult_NotAnImage(t *testing.T) {
+	if _, err := ImageBytesResult([]byte("hello")); err == nil || !strings.Contains(err.Error(), "not an image") {
+		t.Errorf("Expected not an image error, got %v", err)
+	}
+}
+
+func TestScaleImage(t *testing.T) {
+	// Halving a checkerboard averages each 2x2 block
+	src := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := range 4 {
+		for x := range 4 {
+			if (x+y)%2 == 0 {
+				src.Set(x, y, color.RGBA{R: 200, G: 100, A: 255})
+			}
+		}
+	}
+
+	dst := scaleImage(src, 2, 2)
+	if got := dst.RGBAAt(1, 1); got != (color.RGBA{R: 100, G: 50, A: 127}) {
+		t.Errorf("Expected averaged pixel, got %v", got)
+	}
+}
+
+func TestImageResultFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chart.png")
+	if err := os.WriteFile(path, encodeTestImage(t, "png", 10, 10), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ImageResultFromFile(path)
+	if err != nil {
+		t.Fatalf("ImageResultFromFile failed: %v", err)
+	}
+	if config, _ := decodeResultImage(t, result); config.Width != 10 {
+		t.Errorf("Unexpected image %+v", config)
+	}
+
+	if _, err := ImageResultFromFile(filepath.Join(t.TempDir(), "missing.png")); err == nil {
+		t.Error("Expected error for a missing file")
+	}
+}
@@ -0,0 +1,125 @@
+package claude
+
+// InitInfo is what the CLI reports about itself when a Client connects,
+// in its response to the initialize request of the control protocol.
+// Fields the CLI does not report are left empty, except as noted; Raw
+// holds the whole response.
+type InitInfo struct {
+	// ProtocolVersion is the version of the control protocol the CLI
+	// speaks, if it reports one.
+	ProtocolVersion string
+	// Commands are the slash commands available in the session, including
+	// custom and plugin commands.
+	Commands []SlashCommand
+	// OutputStyle is the output style in effect, and OutputStyles those
+	// available.
+	OutputStyle  string
+	OutputStyles []string
+	// PermissionModes are the permission modes the CLI accepts, or those
+	// the SDK defines constants for if it reports none.
+	PermissionModes []PermissionMode
+	Models          []ModelInfo
+	// Betas are the betas the CLI reports, or those the SDK knows of if it
+	// reports none.
+	Betas []SdkBeta
+	Raw   map[string]any
+}
+
+// SlashCommand is a slash command available in a session.
+type SlashCommand struct {
+	// Name is the command without its leading slash.
+	Name        string
+	Description string
+	// ArgumentHint describes the arguments of the command, if any, such
+	// as "<file>".
+	ArgumentHint string
+}
+
+// HasCommand reports whether the slash command name, with or without its
+// leading slash, is available.
+func (i *InitInfo) HasCommand(name string) bool {
+	if len(name) > 0 && name[0] == '/' {
+		name = name[1:]
+	}
+	for _, command := range i.Commands {
+		if command.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// knownPermissionModes lists the permission modes the SDK defines
+// constants for.
+var knownPermissionModes = []PermissionMode{
+	PermissionModeDefault,
+	PermissionModeAcceptEdits,
+	PermissionModePlan,
+	PermissionModeBypassPermissions,
+}
+
+// parseInitInfo reads the CLI's initialize response.
+func parseInitInfo(info map[string]any) *InitInfo {
+	i := &InitInfo{Raw: info}
+	i.ProtocolVersion, _ = info["protocolVersion"].(string)
+
+	commands, _ := info["commands"].([]any)
+	for _, c := range commands {
+		command, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		var sc SlashCommand
+		sc.Name, _ = command["name"].(string)
+		sc.Description, _ = command["description"].(string)
+		sc.ArgumentHint, _ = command["argumentHint"].(string)
+		if sc.Name != "" {
+			i.Commands = append(i.Commands, sc)
+		}
+	}
+
+	i.OutputStyle, _ = info["output_style"].(string)
+	styles, _ := info["available_output_styles"].([]any)
+	for _, s := range styles {
+		if style, ok := s.(string); ok {
+			i.OutputStyles = append(i.OutputStyles, style)
+		}
+	}
+
+	modes, _ := info["permissionModes"].([]any)
+	for _, m := range modes {
+		if mode, ok := m.(string); ok {
+			i.PermissionModes = append(i.PermissionModes, PermissionMode(mode))
+		}
+	}
+	if len(i.PermissionModes) == 0 {
+		i.PermissionModes = append([]PermissionMode(nil), knownPermissionModes...)
+	}
+
+	catalog := parseModelCatalog(info)
+	i.Models, i.Betas = catalog.Models, catalog.Betas
+	return i
+}
+
+// InitInfo returns what the CLI reported about itself when the client
+// connected: its protocol version, slash commands, output styles,
+// permission modes, models, and betas. It is useful to enable features
+// of an application only when the CLI supports them.
+//
+// Example:
+//
+//	info, err := client.InitInfo()
+//	if err != nil {
+//	    return err
+//	}
+//	if info.HasCommand("compact") {
+//	    showCompactButton()
+//	}
+func (c *Client) InitInfo() (*InitInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.connected {
+		return nil, NewCLIConnectionError("Not connected. Call Connect() first.")
+	}
+	return parseInitInfo(c.query.InitResult()), nil
+}
@@ -0,0 +1,79 @@
+package claude
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseInitInfo(t *testing.T) {
+	info := parseInitInfo(map[string]any{
+		"protocolVersion": "1",
+		"commands": []any{
+			map[string]any{"name": "compact", "description": "Compact the conversation", "argumentHint": "<instructions>"},
+			map[string]any{"description": "No name"},
+			"invalid",
+		},
+		"output_style":            "default",
+		"available_output_styles": []any{"default", "Explanatory"},
+		"permissionModes":         []any{"default", "plan"},
+		"models":                  []any{map[string]any{"value": "sonnet"}},
+	})
+
+	if info.ProtocolVersion != "1" || info.OutputStyle != "default" {
+		t.Errorf("Unexpected info: %+v", info)
+	}
+	want := []SlashCommand{{Name: "compact", Description: "Compact the conversation", ArgumentHint: "<instructions>"}}
+	if !reflect.DeepEqual(info.Commands, want) {
+		t.Errorf("Expected commands %+v, got %+v", want, info.Commands)
+	}
+	if !info.HasCommand("/compact") || !info.HasCommand("compact") || info.HasCommand("clear") {
+		t.Error("Expected HasCommand to find only compact, with or without its slash")
+	}
+	if !reflect.DeepEqual(info.OutputStyles, []string{"default", "Explanatory"}) {
+		t.Errorf("Unexpected output styles: %v", info.OutputStyles)
+	}
+	if !reflect.DeepEqual(info.PermissionModes, []PermissionMode{PermissionModeDefault, PermissionModePlan}) {
+		t.Errorf("Expected reported permission modes, got %v", info.PermissionModes)
+	}
+	if len(info.Models) != 1 || info.Models[0].Value != ModelSonnet {
+		t.Errorf("Unexpected models: %+v", info.Models)
+	}
+
+	// Defaults when the CLI reports nothing
+	info = parseInitInfo(nil)
+	if !reflect.DeepEqual(info.PermissionModes, knownPermissionModes) || !reflect.DeepEqual(info.Betas, knownBetas) {
+		t.Errorf("Expected known modes and betas, got %v and %v", info.PermissionModes, info.Betas)
+	}
+}
+
+func TestClient_InitInfo(t *testing.T) {
+	client := NewClient()
+	if _, err := client.InitInfo(); err == nil {
+		t.Error("Expected an error before Connect")
+	}
+
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{"commands":[{"name":"review","description":"Review a pull request"}],"output_style":"default"}}}'
+cat > /dev/null
+`)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client = NewClient(WithCLIPath(cli))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	info, err := client.InitInfo()
+	if err != nil {
+		t.Fatalf("InitInfo failed: %v", err)
+	}
+	if !info.HasCommand("review") || info.OutputStyle != "default" || info.Raw["output_style"] != "default" {
+		t.Errorf("Unexpected info: %+v", info)
+	}
+}
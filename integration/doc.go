@@ -0,0 +1,9 @@
+// Package integration holds end-to-end tests of the SDK against
+// claude-stub, a stand-in for the Claude Code CLI built from
+// cmd/claude-stub. They start real CLI processes, so they only run with
+// the integration build tag:
+//
+//	go test -tags integration ./integration
+//
+// No Anthropic account or network access is needed.
+package integration
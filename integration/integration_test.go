@@ -0,0 +1,564 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	claude "github.com/afsharalex/claude-agent-sdk-go"
+)
+
+// stubCLI is the path of the built claude-stub.
+var stubCLI string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "claude-stub-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "integration:", err)
+		os.Exit(1)
+	}
+	stubCLI = filepath.Join(dir, "claude")
+	build := exec.Command("go", "build", "-o", stubCLI, "../cmd/claude-stub")
+	build.Stdout, build.Stderr = os.Stderr, os.Stderr
+	if err := build.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "integration: building claude-stub:", err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// connect returns a connected Client running the stub with opts.
+func connect(t *testing.T, ctx context.Context, opts ...claude.Option) *claude.Client {
+	t.Helper()
+	client := claude.NewClient(append([]claude.Option{claude.WithCLIPath(stubCLI)}, opts...)...)
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// turn sends prompt on client and returns the messages of its response.
+func turn(t *testing.T, ctx context.Context, client *claude.Client, prompt string) []claude.Message {
+	t.Helper()
+	if err := client.Query(ctx, prompt); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	var messages []claude.Message
+	for msg := range client.ReceiveResponse(ctx) {
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+// toolResult returns the first tool result block in messages.
+func toolResult(t *testing.T, messages []claude.Message) claude.ToolResultBlock {
+	t.Helper()
+	for _, msg := range messages {
+		if m, ok := msg.(*claude.UserMessage); ok {
+			if blocks, ok := m.Content.([]claude.ContentBlock); ok {
+				for _, block := range blocks {
+					if result, ok := block.(claude.ToolResultBlock); ok {
+						return result
+					}
+				}
+			}
+		}
+	}
+	t.Fatalf("Expected a tool result, got %+v", messages)
+	return claude.ToolResultBlock{}
+}
+
+func TestQuery(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	messages, errs := claude.Query(ctx, "hello", claude.WithCLIPath(stubCLI))
+	var text string
+	var result *claude.ResultMessage
+	for msg := range messages {
+		switch m := msg.(type) {
+		case *claude.AssistantMessage:
+			text = m.Text()
+		case *claude.ResultMessage:
+			result = m
+		}
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if text != "echo: hello" || result == nil || result.IsError {
+		t.Errorf("Expected the prompt echoed and a successful result, got %q, %+v", text, result)
+	}
+}
+
+func TestClient_Initialize(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client := connect(t, ctx)
+	info, err := client.InitInfo()
+	if err != nil || !info.HasCommand("compact") {
+		t.Errorf("Expected the commands of the initialize response, got %+v, %v", info, err)
+	}
+
+	messages := turn(t, ctx, client, "hi")
+	if len(messages) != 3 {
+		t.Fatalf("Expected the init message, the reply and the result, got %+v", messages)
+	}
+	if init, ok := messages[0].(*claude.SystemMessage); !ok || init.Subtype != "init" {
+		t.Errorf("Expected the init message first, got %+v", messages[0])
+	}
+	if id := client.SessionID(); !strings.HasPrefix(id, "stub-session-") {
+		t.Errorf("Expected the session ID of the stub, got %q", id)
+	}
+
+	// The session goes on over several turns
+	messages = turn(t, ctx, client, "again")
+	if m, ok := messages[0].(*claude.AssistantMessage); !ok || m.Text() != "echo: again" {
+		t.Errorf("Expected the second prompt echoed, got %+v", messages)
+	}
+}
+
+func TestClient_Hooks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var calls atomic.Int32
+	deny := func(ctx context.Context, input claude.HookInput, toolUseID string, hookCtx claude.HookContext) (claude.HookOutput, error) {
+		calls.Add(1)
+		if pre, ok := input.(claude.PreToolUseHookInput); !ok || pre.ToolInput["command"] != "rm -rf /" {
+			return claude.HookOutput{}, fmt.Errorf("unexpected hook input %+v", input)
+		}
+		return claude.HookOutput{
+			HookSpecificOutput: claude.PreToolUseHookSpecificOutput{
+				HookEventName:            claude.HookEventPreToolUse,
+				PermissionDecision:       claude.HookPermissionDecisionDeny,
+				PermissionDecisionReason: "destructive command",
+			},
+		}, nil
+	}
+	client := connect(t, ctx, claude.WithHooks(map[claude.HookEvent][]claude.HookMatcher{
+		claude.HookEventPreToolUse: {{Matcher: "Bash", Hooks: []claude.HookCallback{deny}}},
+	}))
+
+	result := toolResult(t, turn(t, ctx, client, `tool Bash {"command":"rm -rf /"}`))
+	if result.IsError == nil || !*result.IsError || result.Content != "Blocked by hook: destructive command" {
+		t.Errorf("Expected the hook to block the tool, got %+v", result)
+	}
+
+	// Tools the matcher does not match are not sent to the hook
+	result = toolResult(t, turn(t, ctx, client, `tool Read {"file_path":"go.mod"}`))
+	if result.IsError != nil && *result.IsError {
+		t.Errorf("Expected Read to run, got %+v", result)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected the hook called once, got %d", n)
+	}
+}
+
+func TestClient_DryRun(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client := connect(t, ctx, claude.WithDryRun())
+
+	result := toolResult(t, turn(t, ctx, client, `tool Write {"file_path":"main.go","content":"package main"}`))
+	if result.IsError == nil || !*result.IsError || !strings.Contains(fmt.Sprint(result.Content), "simulated") {
+		t.Errorf("Expected Write simulated, got %+v", result)
+	}
+	if files := client.Plan().Files(); len(files) != 1 || files[0] != "main.go" {
+		t.Errorf("Expected main.go in the plan, got %v", files)
+	}
+
+	// Read-only tools run, and each turn has its own plan
+	result = toolResult(t, turn(t, ctx, client, `tool Read {"file_path":"go.mod"}`))
+	if result.IsError != nil && *result.IsError {
+		t.Errorf("Expected Read to run, got %+v", result)
+	}
+	if plan := client.Plan(); len(plan.Actions) != 0 {
+		t.Errorf("Expected an empty plan, got %+v", plan.Actions)
+	}
+}
+
+func TestClient_PlanMode(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var plans []string
+	client := connect(t, ctx, claude.WithPlanMode(), claude.WithPlanApprover(func(ctx context.Context, p claude.PlanProposal) (claude.PlanDecision, error) {
+		plans = append(plans, p.Plan)
+		if len(plans) == 1 {
+			return claude.RejectPlan("add tests first"), nil
+		}
+		return claude.ApprovePlan(claude.PermissionModeAcceptEdits), nil
+	}))
+
+	result := toolResult(t, turn(t, ctx, client, `tool ExitPlanMode {"plan":"edit main.go"}`))
+	if result.Content != "Permission denied: add tests first" {
+		t.Errorf("Expected the plan rejected, got %+v", result)
+	}
+
+	messages := turn(t, ctx, client, `tool ExitPlanMode {"plan":"add tests, then edit main.go"}`)
+	if result := toolResult(t, messages); result.IsError != nil && *result.IsError {
+		t.Errorf("Expected the plan approved, got %+v", result)
+	}
+	var change *claude.PermissionModeChanged
+	for _, msg := range messages {
+		if m, ok := msg.(*claude.PermissionModeChanged); ok {
+			change = m
+		}
+	}
+	if change == nil || change.Mode != claude.PermissionModeAcceptEdits || change.Previous != claude.PermissionModePlan || change.Source != claude.PermissionModeSourcePlan {
+		t.Errorf("Expected a change from plan mode to acceptEdits, got %+v", change)
+	}
+	if len(plans) != 2 || plans[1] != "add tests, then edit main.go" {
+		t.Errorf("Expected both plans passed to the approver, got %q", plans)
+	}
+}
+
+func TestClient_WebDomainPolicy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var fetched []claude.WebResult
+	client := connect(t, ctx,
+		claude.WithWebDomainPolicy([]string{"go.dev"}, nil),
+		claude.WithWebResultHook(func(ctx context.Context, r claude.WebResult) (claude.HookOutput, error) {
+			fetched = append(fetched, r)
+			return claude.HookOutput{}, nil
+		}),
+	)
+
+	result := toolResult(t, turn(t, ctx, client, `tool WebFetch {"url":"https://example.com"}`))
+	if result.IsError == nil || !*result.IsError || !strings.HasPrefix(fmt.Sprint(result.Content), "Blocked by hook: ") {
+		t.Errorf("Expected the fetch of example.com blocked, got %+v", result)
+	}
+	result = toolResult(t, turn(t, ctx, client, `tool WebFetch {"url":"https://pkg.go.dev/net/http"}`))
+	if result.IsError != nil && *result.IsError {
+		t.Errorf("Expected the fetch of pkg.go.dev to run, got %+v", result)
+	}
+	if len(fetched) != 1 || fetched[0].URL != "https://pkg.go.dev/net/http" || fetched[0].Size == 0 {
+		t.Errorf("Expected the result of the fetch that ran, got %+v", fetched)
+	}
+}
+
+func TestClient_ContextUsage(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client := connect(t, ctx, claude.WithContextWindow(100), claude.WithContextWarnings(0.5))
+
+	turn(t, ctx, client, "hi")
+	first := client.ContextUsage()
+	if first.ContextTokens == 0 || first.InputTokens+first.OutputTokens == 0 || first.Window != 100 || first.Model != "claude-stub" {
+		t.Fatalf("Expected the usage of the first turn, got %+v", first)
+	}
+
+	var warning *claude.DiagnosticEvent
+	for range 10 {
+		for _, msg := range turn(t, ctx, client, "tell me more") {
+			if event, ok := msg.(*claude.DiagnosticEvent); ok && event.Kind == claude.DiagnosticKindContext {
+				warning = event
+			}
+		}
+		if warning != nil {
+			break
+		}
+	}
+	if warning == nil || warning.Context.Fraction() < 0.5 {
+		t.Fatalf("Expected a warning once the context was half full, got %+v", warning)
+	}
+	if usage := client.ContextUsage(); usage.ContextTokens <= first.ContextTokens || usage.OutputTokens <= first.OutputTokens {
+		t.Errorf("Expected the usage to grow over the turns, got %+v", usage)
+	}
+}
+
+func TestClient_WorkspaceLock(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cwd, locks := t.TempDir(), t.TempDir()
+	lock := []claude.Option{claude.WithCwd(cwd), claude.WithWorkspaceLockDir(locks)}
+	first := connect(t, ctx, append(lock, claude.WithWorkspaceLock(claude.WorkspaceLockReject))...)
+
+	second := claude.NewClient(append(lock, claude.WithCLIPath(stubCLI), claude.WithWorkspaceLock(claude.WorkspaceLockReject))...)
+	err := second.Connect(ctx)
+	if busy, ok := claude.AsWorkspaceBusyError(err); !ok || busy.PID != os.Getpid() {
+		t.Fatalf("Expected a WorkspaceBusyError while the first client is connected, got %v", err)
+	}
+
+	// A queued query runs once the first client closes
+	start := time.Now()
+	time.AfterFunc(200*time.Millisecond, func() { _ = first.Close() })
+	result, err := claude.QueryOnce(ctx, "hello", append(lock, claude.WithCLIPath(stubCLI), claude.WithWorkspaceLock(claude.WorkspaceLockQueue))...)
+	if err != nil || result == nil {
+		t.Fatalf("Expected the queued query to run, got %v", err)
+	}
+	if waited := time.Since(start); waited < 200*time.Millisecond {
+		t.Errorf("Expected the query to wait for the first client to close, ran after %s", waited)
+	}
+
+	if err := second.Connect(ctx); err != nil {
+		t.Fatalf("Expected the lock free once the query ended, got %v", err)
+	}
+	_ = second.Close()
+}
+
+func TestClient_CanUseTool(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client := connect(t, ctx, claude.WithCanUseTool(func(ctx context.Context, toolName string, input map[string]any, permCtx claude.ToolPermissionContext) (claude.PermissionResult, error) {
+		if toolName == "Write" {
+			return claude.PermissionResultDeny{Message: "read-only session"}, nil
+		}
+		return claude.PermissionResultAllow{UpdatedInput: map[string]any{"command": "ls -la"}}, nil
+	}))
+
+	result := toolResult(t, turn(t, ctx, client, `tool Write {"file_path":"x"}`))
+	if result.Content != "Permission denied: read-only session" {
+		t.Errorf("Expected the callback to deny Write, got %+v", result)
+	}
+	result = toolResult(t, turn(t, ctx, client, `tool Bash {"command":"ls"}`))
+	if result.Content != `stub ran Bash with {"command":"ls -la"}` {
+		t.Errorf("Expected Bash run with the updated input, got %+v", result)
+	}
+}
+
+func TestClient_AcceptSuggestions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var asked atomic.Int32
+	client := connect(t, ctx, claude.WithCanUseTool(func(ctx context.Context, toolName string, input map[string]any, permCtx claude.ToolPermissionContext) (claude.PermissionResult, error) {
+		asked.Add(1)
+		if len(permCtx.Suggestions) == 0 || permCtx.Suggestions[0].Rules[0].ToolName != toolName {
+			return claude.PermissionResultDeny{Message: "expected a suggested rule"}, nil
+		}
+		return permCtx.AcceptSuggestionsForSession(), nil
+	}))
+
+	for range 2 {
+		if result := toolResult(t, turn(t, ctx, client, `tool Bash {"command":"npm test"}`)); result.IsError != nil && *result.IsError {
+			t.Fatalf("Expected Bash allowed, got %+v", result)
+		}
+	}
+	if n := asked.Load(); n != 1 {
+		t.Errorf("Expected the accepted rule to allow the second call without asking, asked %d times", n)
+	}
+}
+
+func TestClient_SDKMCPServer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	add := claude.Tool("add", "Add two numbers",
+		claude.SimpleInputSchema(map[string]string{"a": "number", "b": "number"}),
+		func(ctx context.Context, args map[string]any) (claude.MCPToolResult, error) {
+			a, _ := args["a"].(float64)
+			b, _ := args["b"].(float64)
+			return claude.TextResult(fmt.Sprint(a + b)), nil
+		},
+	)
+	client := connect(t, ctx,
+		claude.WithMCPServers(map[string]claude.MCPServerConfig{
+			"calc": claude.CreateSDKMCPServer("calc", "1.0.0", []claude.MCPTool{add}),
+		}),
+		claude.WithAllowedTools([]string{"mcp__calc__add"}),
+	)
+
+	messages := turn(t, ctx, client, `tool mcp__calc__add {"a":1,"b":2}`)
+	init, _ := messages[0].(*claude.SystemMessage)
+	if init == nil || !strings.Contains(fmt.Sprint(init.Data["tools"]), "mcp__calc__add") {
+		t.Errorf("Expected the tools of the server listed at init, got %+v", messages[0])
+	}
+	if result := toolResult(t, messages); result.Content != "3" {
+		t.Errorf("Expected the tool called through the SDK, got %+v", result)
+	}
+}
+
+func TestClient_FileOpsServer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "notes.txt"), []byte("draft\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	client := connect(t, ctx,
+		claude.WithMCPServers(map[string]claude.MCPServerConfig{
+			claude.FileOpsServerName: claude.FileOpsServer(root),
+		}),
+		claude.WithMCPServerAllowed(claude.FileOpsServerName),
+	)
+
+	patch := `--- a/notes.txt\n+++ b/notes.txt\n@@ -1 +1 @@\n-draft\n+final\n`
+	result := toolResult(t, turn(t, ctx, client, `tool mcp__files__apply_patch {"patch":"`+patch+`"}`))
+	if result.IsError != nil && *result.IsError {
+		t.Fatalf("Expected the patch applied, got %+v", result)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "notes.txt")); string(data) != "final\n" {
+		t.Errorf("Expected the file patched, got %q", data)
+	}
+
+	result = toolResult(t, turn(t, ctx, client, `tool mcp__files__read_file {"path":"../outside.txt"}`))
+	if result.IsError == nil || !*result.IsError {
+		t.Errorf("Expected a path outside the root refused, got %+v", result)
+	}
+}
+
+func TestClient_ShellServer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client := connect(t, ctx,
+		claude.WithMCPServers(map[string]claude.MCPServerConfig{
+			claude.ShellServerName: claude.ShellServer(claude.ShellPolicy{Allow: []string{"echo"}, Dir: t.TempDir()}),
+		}),
+		claude.WithMCPServerAllowed(claude.ShellServerName),
+	)
+
+	result := toolResult(t, turn(t, ctx, client, `tool mcp__shell__run {"command":"echo governed"}`))
+	if result.Content != "governed\n" {
+		t.Errorf("Expected the command run, got %+v", result)
+	}
+	result = toolResult(t, turn(t, ctx, client, `tool mcp__shell__run {"command":"rm -rf ."}`))
+	if result.IsError == nil || !*result.IsError {
+		t.Errorf("Expected a command outside the allowlist refused, got %+v", result)
+	}
+}
+
+func TestClient_HTTPToolServer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	client := connect(t, ctx,
+		claude.WithMCPServers(map[string]claude.MCPServerConfig{
+			claude.HTTPToolServerName: claude.HTTPToolServer(server.Client(), claude.URLPolicy{
+				Allow:     []string{"127.0.0.1"},
+				AllowHTTP: true,
+				Headers:   map[string]map[string]string{"127.0.0.1": {"Authorization": "Bearer secret"}},
+			}),
+		}),
+		claude.WithMCPServerAllowed(claude.HTTPToolServerName),
+	)
+
+	result := toolResult(t, turn(t, ctx, client, fmt.Sprintf(`tool mcp__http__get {"url":%q}`, server.URL)))
+	if content, _ := result.Content.(string); !strings.HasSuffix(content, "\n\nBearer secret") {
+		t.Errorf("Expected the request made with the policy headers, got %+v", result)
+	}
+	result = toolResult(t, turn(t, ctx, client, `tool mcp__http__get {"url":"https://example.com"}`))
+	if result.IsError == nil || !*result.IsError {
+		t.Errorf("Expected a domain outside the policy refused, got %+v", result)
+	}
+}
+
+func TestClient_Interrupt(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client := connect(t, ctx)
+	if err := client.Query(ctx, "wait"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	var result *claude.ResultMessage
+	for msg := range client.ReceiveResponse(ctx) {
+		switch m := msg.(type) {
+		case *claude.AssistantMessage:
+			if err := client.InterruptWithReason(ctx, "user abort"); err != nil {
+				t.Fatalf("Interrupt failed: %v", err)
+			}
+		case *claude.ResultMessage:
+			result = m
+		}
+	}
+	if result == nil || !result.IsInterrupted() || result.InterruptReason != "user abort" {
+		t.Errorf("Expected the turn interrupted, got %+v", result)
+	}
+
+	// The client is usable after the interrupt
+	if m, ok := turn(t, ctx, client, "still there?")[0].(*claude.AssistantMessage); !ok || m.Text() != "echo: still there?" {
+		t.Error("Expected the next turn to run after the interrupt")
+	}
+}
+
+func TestClient_NamedPipes(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var calls atomic.Int32
+	hooks := claude.WithHooks(map[claude.HookEvent][]claude.HookMatcher{
+		claude.HookEventPreToolUse: {{Matcher: "Bash", Hooks: []claude.HookCallback{
+			func(ctx context.Context, input claude.HookInput, toolUseID string, hookCtx claude.HookContext) (claude.HookOutput, error) {
+				calls.Add(1)
+				return claude.HookOutput{}, nil
+			},
+		}}},
+	})
+	dir := t.TempDir()
+
+	first := claude.NewClient(claude.WithCLIPath(stubCLI), claude.WithNamedPipes(dir), hooks)
+	if err := first.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	turn(t, ctx, first, "hi")
+	session := first.SessionID()
+	if err := first.Detach(); err != nil {
+		t.Fatalf("Detach failed: %v", err)
+	}
+
+	// The stub keeps its session, and the hooks registered by the first
+	// client are called on the second
+	second := connect(t, ctx, claude.WithNamedPipes(dir), hooks)
+	messages := turn(t, ctx, second, `tool Bash {"command":"ls"}`)
+	if result := toolResult(t, messages); result.IsError != nil && *result.IsError {
+		t.Errorf("Expected Bash to run, got %+v", result)
+	}
+	if got := second.SessionID(); got != session {
+		t.Errorf("Expected the session %q to go on, got %q", session, got)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected the hook called once, got %d", n)
+	}
+}
+
+func TestDoctorAndUpdate(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	report, err := claude.Doctor(ctx, claude.WithCLIPath(stubCLI))
+	if err != nil {
+		t.Fatalf("Doctor failed: %v", err)
+	}
+	if check, ok := report.Check("Path"); !ok || check.Value != stubCLI || report.Version != "2.1.0" || !report.Healthy() {
+		t.Errorf("Expected a healthy report for the stub, got %+v", report)
+	}
+
+	result, err := claude.CLIUpdate(ctx, claude.WithCLIPath(stubCLI))
+	if err != nil {
+		t.Fatalf("CLIUpdate failed: %v", err)
+	}
+	if result.Updated || result.Version != "2.1.0" {
+		t.Errorf("Expected the stub up to date, got %+v", result)
+	}
+}
@@ -0,0 +1,13 @@
+package claude
+
+// intercept passes msg through the interceptors of WithMessageInterceptor,
+// in order, and returns the message to deliver, or nil if one of them
+// dropped it.
+func (o *Options) intercept(msg Message) Message {
+	for _, fn := range o.MessageInterceptors {
+		if msg = fn(msg); msg == nil {
+			return nil
+		}
+	}
+	return msg
+}
@@ -0,0 +1,159 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// upperText returns assistant messages with their text in upper case.
+func upperText(msg Message) Message {
+	m, ok := msg.(*AssistantMessage)
+	if !ok {
+		return msg
+	}
+	upper := *m
+	upper.Content = nil
+	for _, block := range m.Content {
+		if text, ok := block.(TextBlock); ok {
+			block = TextBlock{Text: strings.ToUpper(text.Text)}
+		}
+		upper.Content = append(upper.Content, block)
+	}
+	return &upper
+}
+
+// dropSystem drops system messages.
+func dropSystem(msg Message) Message {
+	if _, ok := msg.(*SystemMessage); ok {
+		return nil
+	}
+	return msg
+}
+
+func TestOptions_Intercept(t *testing.T) {
+	var calls []string
+	o := NewOptions(
+		WithMessageInterceptor(func(msg Message) Message {
+			calls = append(calls, "first")
+			return &AssistantMessage{Content: []ContentBlock{TextBlock{Text: "replaced"}}}
+		}),
+		WithMessageInterceptor(func(msg Message) Message {
+			calls = append(calls, "second")
+			return upperText(msg)
+		}),
+	)
+	msg := o.intercept(&UserMessage{Content: "hi"})
+	if m, ok := msg.(*AssistantMessage); !ok || m.Text() != "REPLACED" {
+		t.Errorf("Expected the interceptors applied in order, got %+v", msg)
+	}
+	if strings.Join(calls, ",") != "first,second" {
+		t.Errorf("Expected both interceptors called in order, got %v", calls)
+	}
+
+	// A dropped message is not passed on
+	calls = nil
+	o = NewOptions(WithMessageInterceptor(dropSystem), WithMessageInterceptor(func(msg Message) Message {
+		calls = append(calls, "after")
+		return msg
+	}))
+	if msg := o.intercept(&SystemMessage{Subtype: "init"}); msg != nil || len(calls) != 0 {
+		t.Errorf("Expected the message dropped by the first interceptor, got %+v, %v", msg, calls)
+	}
+
+	if msg := NewOptions().intercept(&UserMessage{}); msg == nil {
+		t.Error("Expected messages passed through without interceptors")
+	}
+}
+
+func TestQuery_MessageInterceptor(t *testing.T) {
+	dir := t.TempDir()
+	cli := writeStubCLI(t, `
+echo '{"type":"system","subtype":"init","session_id":"s"}'
+echo '{"type":"assistant","message":{"model":"m","content":[{"type":"text","text":"hello"}]}}'
+echo '{"type":"result","subtype":"success","session_id":"s","total_cost_usd":0.01,"result":"hello"}'
+`)
+	annotate := func(msg Message) Message {
+		if r, ok := msg.(*ResultMessage); ok {
+			annotated := *r
+			annotated.Result += " (reviewed)"
+			return &annotated
+		}
+		return msg
+	}
+
+	// The second query replays the first from the cache, and is
+	// intercepted the same
+	cache := FileCache{Dir: filepath.Join(dir, "cache")}
+	for i := range 2 {
+		messages, errs := Query(context.Background(), "hi",
+			WithCLIPath(cli),
+			WithCache(cache),
+			WithMessageInterceptor(dropSystem),
+			WithMessageInterceptor(upperText),
+			WithMessageInterceptor(annotate),
+		)
+		var received []string
+		for msg := range messages {
+			switch m := msg.(type) {
+			case *AssistantMessage:
+				received = append(received, m.Text())
+			case *ResultMessage:
+				received = append(received, m.Result)
+			default:
+				received = append(received, fmt.Sprintf("%T", msg))
+			}
+		}
+		if err := <-errs; err != nil {
+			t.Fatalf("Query %d failed: %v", i, err)
+		}
+		if got := strings.Join(received, "|"); got != "HELLO|hello (reviewed)" {
+			t.Errorf("Query %d: expected the intercepted messages, got %q", i, got)
+		}
+	}
+}
+
+func TestClient_MessageInterceptor(t *testing.T) {
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+while read line; do
+  echo '{"type":"system","subtype":"init","session_id":"s"}'
+  echo '{"type":"assistant","message":{"model":"m","content":[{"type":"text","text":"hello"},{"type":"tool_use","id":"t1","name":"Read","input":{}}]}}'
+  echo '{"type":"result","subtype":"success","session_id":"s","result":"done"}'
+done
+`)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli), WithMessageInterceptor(dropSystem), WithMessageInterceptor(upperText))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	// Turns still end when their result is delivered
+	for range 2 {
+		if err := client.Query(ctx, "hi"); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		var received []Message
+		for msg := range client.ReceiveResponse(ctx) {
+			received = append(received, msg)
+		}
+		if len(received) != 2 {
+			t.Fatalf("Expected the system message dropped, got %+v", received)
+		}
+		m, ok := received[0].(*AssistantMessage)
+		if !ok || m.Text() != "HELLO" || len(m.Content) != 2 {
+			t.Errorf("Expected the assistant text intercepted and its tool use kept, got %+v", received[0])
+		}
+	}
+	if client.SessionID() != "s" {
+		t.Errorf("Expected the SDK to still see dropped messages, got session %q", client.SessionID())
+	}
+}
@@ -33,6 +33,7 @@ const (
 	RequestSubtypeMCPMessage        = "mcp_message"
 	RequestSubtypeMCPStatus         = "mcp_status"
 	RequestSubtypeRewindFiles       = "rewind_files"
+	RequestSubtypeUpdatePermissions = "update_permissions"
 )
 
 // PermissionRequest is the data for a can_use_tool control request.
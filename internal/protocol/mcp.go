@@ -0,0 +1,156 @@
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/types"
+)
+
+// mcpProtocolVersions are the MCP protocol versions HandleMCPMessage
+// speaks, oldest first. Only tools are supported, which all of them
+// handle alike.
+var mcpProtocolVersions = []string{"2024-11-05", "2025-03-26", "2025-06-18"}
+
+// HandleMCPMessage answers a JSON-RPC message for an in-process MCP
+// server. It returns nil for notifications, which have no answer.
+func HandleMCPMessage(ctx context.Context, server *types.MCPServer, message map[string]any) map[string]any {
+	method, _ := message["method"].(string)
+	params, _ := message["params"].(map[string]any)
+
+	if _, ok := message["id"]; !ok {
+		// Notifications, such as notifications/initialized, need no answer
+		return nil
+	}
+
+	var result map[string]any
+
+	switch method {
+	case "initialize":
+		result = map[string]any{
+			"jsonrpc": "2.0",
+			"id":      message["id"],
+			"result": map[string]any{
+				"protocolVersion": negotiateMCPVersion(params),
+				"capabilities": map[string]any{
+					"tools": map[string]any{},
+				},
+				"serverInfo": map[string]any{
+					"name":    server.Name,
+					"version": server.Version,
+				},
+			},
+		}
+
+	case "tools/list":
+		toolsList := make([]map[string]any, 0, len(server.Tools))
+		for _, tool := range server.Tools {
+			toolsList = append(toolsList, map[string]any{
+				"name":        tool.Name,
+				"description": tool.Description,
+				"inputSchema": tool.InputSchema,
+			})
+		}
+		result = map[string]any{
+			"jsonrpc": "2.0",
+			"id":      message["id"],
+			"result": map[string]any{
+				"tools": toolsList,
+			},
+		}
+
+	case "tools/call":
+		name, _ := params["name"].(string)
+		arguments, _ := params["arguments"].(map[string]any)
+
+		var tool *types.MCPTool
+		for i := range server.Tools {
+			if server.Tools[i].Name == name {
+				tool = &server.Tools[i]
+				break
+			}
+		}
+
+		if tool == nil {
+			result = map[string]any{
+				"jsonrpc": "2.0",
+				"id":      message["id"],
+				"error": map[string]any{
+					"code":    -32601,
+					"message": fmt.Sprintf("Tool '%s' not found", name),
+				},
+			}
+		} else {
+			toolResult, err := tool.Handler(ctx, arguments)
+			if err != nil {
+				result = map[string]any{
+					"jsonrpc": "2.0",
+					"id":      message["id"],
+					"error": map[string]any{
+						"code":    -32603,
+						"message": err.Error(),
+					},
+				}
+			} else {
+				content := make([]map[string]any, 0, len(toolResult.Content))
+				for _, c := range toolResult.Content {
+					item := map[string]any{"type": c.Type}
+					switch c.Type {
+					case "text":
+						item["text"] = c.Text
+					case "image":
+						item["data"] = c.Data
+						item["mimeType"] = c.MimeType
+					case "resource_link":
+						item["uri"] = c.URI
+						item["name"] = c.Name
+						if c.MimeType != "" {
+							item["mimeType"] = c.MimeType
+						}
+						if c.Size > 0 {
+							item["size"] = c.Size
+						}
+					}
+					content = append(content, item)
+				}
+				responseData := map[string]any{"content": content}
+				if toolResult.Structured != nil {
+					responseData["structuredContent"] = toolResult.Structured
+				}
+				if toolResult.IsError {
+					// isError is the MCP field; the CLI also reads is_error
+					responseData["is_error"] = true
+					responseData["isError"] = true
+				}
+				result = map[string]any{
+					"jsonrpc": "2.0",
+					"id":      message["id"],
+					"result":  responseData,
+				}
+			}
+		}
+
+	default:
+		result = map[string]any{
+			"jsonrpc": "2.0",
+			"id":      message["id"],
+			"error": map[string]any{
+				"code":    -32601,
+				"message": fmt.Sprintf("Method '%s' not found", method),
+			},
+		}
+	}
+
+	return result
+}
+
+// negotiateMCPVersion returns the protocol version requested in the
+// params of an initialize request if it is supported, and the oldest
+// supported version otherwise.
+func negotiateMCPVersion(params map[string]any) string {
+	if requested, ok := params["protocolVersion"].(string); ok && slices.Contains(mcpProtocolVersions, requested) {
+		return requested
+	}
+	return mcpProtocolVersions[0]
+}
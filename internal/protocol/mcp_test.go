@@ -0,0 +1,106 @@
+package protocol
+
+import (
+	"context"
+	"testing"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/types"
+)
+
+func TestHandleMCPMessage_Notification(t *testing.T) {
+	server := &types.MCPServer{Name: "test", Version: "1.0.0"}
+
+	response := HandleMCPMessage(context.Background(), server, map[string]any{
+		"jsonrpc": "2.0",
+		"method":  "notifications/initialized",
+	})
+	if response != nil {
+		t.Errorf("Expected no response to a notification, got %v", response)
+	}
+}
+
+func TestHandleMCPMessage_NegotiatesVersion(t *testing.T) {
+	server := &types.MCPServer{Name: "test", Version: "1.0.0"}
+
+	tests := map[string]string{
+		"2025-06-18": "2025-06-18",
+		"2024-11-05": "2024-11-05",
+		"2099-01-01": "2024-11-05",
+		"":           "2024-11-05",
+	}
+	for requested, want := range tests {
+		response := HandleMCPMessage(context.Background(), server, map[string]any{
+			"jsonrpc": "2.0",
+			"id":      1,
+			"method":  "initialize",
+			"params":  map[string]any{"protocolVersion": requested},
+		})
+		result, _ := response["result"].(map[string]any)
+		if got := result["protocolVersion"]; got != want {
+			t.Errorf("Requested %q: expected %s, got %v", requested, want, got)
+		}
+	}
+}
+
+func TestHandleMCPMessage_ResourceLink(t *testing.T) {
+	server := &types.MCPServer{
+		Name: "test",
+		Tools: []types.MCPTool{{
+			Name: "dump",
+			Handler: func(ctx context.Context, args map[string]any) (types.MCPToolResult, error) {
+				return types.MCPToolResult{Content: []types.MCPContent{{
+					Type:     "resource_link",
+					URI:      "file:///tmp/out.txt",
+					Name:     "out.txt",
+					MimeType: "text/plain",
+					Size:     42,
+				}}}, nil
+			},
+		}},
+	}
+
+	response := HandleMCPMessage(context.Background(), server, map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params":  map[string]any{"name": "dump"},
+	})
+	result, _ := response["result"].(map[string]any)
+	content, _ := result["content"].([]map[string]any)
+	if len(content) != 1 {
+		t.Fatalf("Expected one content item, got %v", response)
+	}
+	want := map[string]any{"type": "resource_link", "uri": "file:///tmp/out.txt", "name": "out.txt", "mimeType": "text/plain", "size": int64(42)}
+	for k, v := range want {
+		if content[0][k] != v {
+			t.Errorf("Expected %s=%v, got %v", k, v, content[0][k])
+		}
+	}
+}
+
+func TestHandleMCPMessage_StructuredContent(t *testing.T) {
+	server := &types.MCPServer{
+		Name: "test",
+		Tools: []types.MCPTool{{
+			Name: "weather",
+			Handler: func(ctx context.Context, args map[string]any) (types.MCPToolResult, error) {
+				return types.MCPToolResult{
+					Content:    []types.MCPContent{{Type: "text", Text: `{"temperature":21}`}},
+					Structured: map[string]any{"temperature": 21},
+				}, nil
+			},
+		}},
+	}
+
+	response := HandleMCPMessage(context.Background(), server, map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params":  map[string]any{"name": "weather"},
+	})
+	result, _ := response["result"].(map[string]any)
+	structured, ok := result["structuredContent"].(map[string]any)
+	if !ok || structured["temperature"] != 21 {
+		t.Errorf("Expected structuredContent, got %v", result)
+	}
+}
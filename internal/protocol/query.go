@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -21,12 +22,22 @@ type Query struct {
 	canUseTool      types.CanUseToolFunc
 	hooks           map[types.HookEvent][]types.HookMatcher
 	sdkMCPServers   map[string]*types.MCPServer
+	handlerContext  func(context.Context) context.Context
+	toolStats       *ToolStats
+	onError         func(error)
 
 	pendingResponses sync.Map
+	hookMu           sync.RWMutex
 	hookCallbacks    map[string]types.HookCallback
+	hookIDs          map[types.HookEvent][][]string
 	nextCallbackID   atomic.Int64
 	requestCounter   atomic.Int64
 
+	// readErr is the error that ended the message stream, failing the
+	// control requests still pending.
+	readErrMu sync.Mutex
+	readErr   error
+
 	messageChan     chan map[string]any
 	initialized     bool
 	closed          atomic.Bool
@@ -51,6 +62,21 @@ type QueryConfig struct {
 	SDKMCPServers      map[string]*types.MCPServer
 	InitializeTimeout  time.Duration
 	StreamCloseTimeout time.Duration
+
+	// HandlerContext, if set, decorates the context passed to hook,
+	// canUseTool, and MCP tool handlers for each control request.
+	HandlerContext func(context.Context) context.Context
+
+	// ToolStats, if set, records tool usage across queries. A Query
+	// creates its own tracker otherwise.
+	ToolStats *ToolStats
+
+	// OnError, if set, is called with the error of each control request
+	// that fails, such as a hook or canUseTool callback returning an
+	// error. The CLI is sent an error response either way. It is also
+	// called with a *transport.BufferLimitError for each stderr line that
+	// was truncated.
+	OnError func(error)
 }
 
 // NewQuery creates a new Query with the given configuration.
@@ -67,13 +93,22 @@ func NewQuery(cfg QueryConfig) *Query {
 		streamCloseTimeout = 60 * time.Second
 	}
 
+	toolStats := cfg.ToolStats
+	if toolStats == nil {
+		toolStats = NewToolStats()
+	}
+
 	return &Query{
 		transport:          cfg.Transport,
 		isStreamingMode:    cfg.IsStreamingMode,
 		canUseTool:         cfg.CanUseTool,
 		hooks:              cfg.Hooks,
 		sdkMCPServers:      cfg.SDKMCPServers,
+		handlerContext:     cfg.HandlerContext,
+		toolStats:          toolStats,
+		onError:            cfg.OnError,
 		hookCallbacks:      make(map[string]types.HookCallback),
+		hookIDs:            make(map[types.HookEvent][][]string),
 		messageChan:        make(chan map[string]any, 100),
 		firstResultCh:      make(chan struct{}),
 		initTimeout:        initTimeout,
@@ -89,7 +124,38 @@ func (q *Query) Initialize(ctx context.Context) (map[string]any, error) {
 		return nil, nil
 	}
 
+	request := map[string]any{
+		"subtype": RequestSubtypeInitialize,
+	}
+	if hooksConfig := q.registerHooks(); len(hooksConfig) > 0 {
+		request["hooks"] = hooksConfig
+	}
+
+	response, err := q.sendControlRequest(ctx, request, q.initTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	q.initialized = true
+	q.initResult = response
+	return response, nil
+}
+
+// Restore sets up the query as Initialize does for a CLI process that was
+// initialized by an earlier query with the same hooks, as when reattaching
+// to it, with initResult as the response of the CLI. Nothing is sent.
+func (q *Query) Restore(initResult map[string]any) {
+	q.registerHooks()
+	q.initialized = true
+	q.initResult = initResult
+}
+
+// registerHooks assigns callback IDs to the hooks, and returns their
+// configuration for initialize. The IDs depend only on the order of the
+// hooks.
+func (q *Query) registerHooks() map[string]any {
 	hooksConfig := make(map[string]any)
+	q.hookMu.Lock()
 	for event, matchers := range q.hooks {
 		if len(matchers) > 0 {
 			matcherConfigs := make([]map[string]any, 0, len(matchers))
@@ -100,6 +166,7 @@ func (q *Query) Initialize(ctx context.Context) (map[string]any, error) {
 					q.hookCallbacks[callbackID] = callback
 					callbackIDs = append(callbackIDs, callbackID)
 				}
+				q.hookIDs[event] = append(q.hookIDs[event], callbackIDs)
 				matcherConfig := map[string]any{
 					"matcher":         matcher.Matcher,
 					"hookCallbackIds": callbackIDs,
@@ -112,22 +179,8 @@ func (q *Query) Initialize(ctx context.Context) (map[string]any, error) {
 			hooksConfig[string(event)] = matcherConfigs
 		}
 	}
-
-	request := map[string]any{
-		"subtype": RequestSubtypeInitialize,
-	}
-	if len(hooksConfig) > 0 {
-		request["hooks"] = hooksConfig
-	}
-
-	response, err := q.sendControlRequest(ctx, request, q.initTimeout)
-	if err != nil {
-		return nil, err
-	}
-
-	q.initialized = true
-	q.initResult = response
-	return response, nil
+	q.hookMu.Unlock()
+	return hooksConfig
 }
 
 // Start starts reading messages from transport.
@@ -152,13 +205,36 @@ func (q *Query) readMessages(ctx context.Context) {
 				return
 			}
 
+			var limitErr *transport.BufferLimitError
+			if errors.As(result.Error, &limitErr) && limitErr.Kind == transport.BufferLimitStderrLine {
+				// Long stderr lines are truncated, which does not end the stream
+				if q.onError != nil {
+					q.onError(limitErr)
+				}
+				continue
+			}
+
 			if result.Error != nil {
+				q.readErrMu.Lock()
+				q.readErr = result.Error
+				q.readErrMu.Unlock()
 				q.pendingResponses.Range(func(key, value any) bool {
 					ch := value.(chan map[string]any)
 					close(ch)
 					return true
 				})
-				q.messageChan <- map[string]any{"type": "error", "error": result.Error.Error()}
+				errMsg := map[string]any{"type": "error", "error": result.Error.Error()}
+				var exitErr *transport.ExitError
+				if errors.As(result.Error, &exitErr) {
+					errMsg["exit_code"] = exitErr.ExitCode
+					errMsg["stderr"] = exitErr.Stderr
+				}
+				if limitErr != nil {
+					errMsg["limit_kind"] = string(limitErr.Kind)
+					errMsg["limit"] = limitErr.Limit
+					errMsg["size"] = limitErr.Size
+				}
+				q.messageChan <- errMsg
 				return
 			}
 
@@ -170,9 +246,10 @@ func (q *Query) readMessages(ctx context.Context) {
 				q.handleControlResponse(message)
 			case "control_request":
 				go q.handleControlRequest(ctx, message)
-			case "control_cancel_request":
+			case "control_cancel_request", "keep_alive":
 				continue
 			default:
+				q.toolStats.Observe(message)
 				if msgType == "result" {
 					q.firstResultOnce.Do(func() {
 						close(q.firstResultCh)
@@ -217,6 +294,13 @@ func (q *Query) handleControlRequest(ctx context.Context, request map[string]any
 		return
 	}
 
+	if q.handlerContext != nil {
+		ctx = q.handlerContext(ctx)
+	}
+	if id := controlToolUseID(requestData); id != "" {
+		ctx = WithToolUseID(ctx, id)
+	}
+
 	subtype, _ := requestData["subtype"].(string)
 	var responseData map[string]any
 	var err error
@@ -233,6 +317,9 @@ func (q *Query) handleControlRequest(ctx context.Context, request map[string]any
 	}
 
 	if err != nil {
+		if q.onError != nil {
+			q.onError(fmt.Errorf("%s: %w", subtype, err))
+		}
 		q.sendErrorResponse(ctx, requestID, err.Error())
 		return
 	}
@@ -250,7 +337,8 @@ func (q *Query) handleCanUseTool(ctx context.Context, request map[string]any) (m
 	originalInput := input
 
 	permCtx := types.ToolPermissionContext{
-		Signal: nil,
+		Signal:      nil,
+		Suggestions: parsePermissionUpdates(request["permission_suggestions"]),
 	}
 
 	result, err := q.canUseTool(ctx, toolName, input, permCtx)
@@ -278,7 +366,20 @@ func (q *Query) handleCanUseTool(ctx context.Context, request map[string]any) (m
 		return response, nil
 	}
 
-	deny := result.(types.PermissionResultDeny)
+	if ask, ok := result.(types.PermissionResultAsk); ok {
+		response := map[string]any{
+			"behavior": "ask",
+		}
+		if ask.Message != "" {
+			response["message"] = ask.Message
+		}
+		return response, nil
+	}
+
+	deny, ok := result.(types.PermissionResultDeny)
+	if !ok {
+		return nil, fmt.Errorf("unsupported permission result %T", result)
+	}
 	response := map[string]any{
 		"behavior": "deny",
 		"message":  deny.Message,
@@ -289,9 +390,64 @@ func (q *Query) handleCanUseTool(ctx context.Context, request map[string]any) (m
 	return response, nil
 }
 
+// ReplaceHooks swaps the callbacks of the hooks registered at initialize
+// for those of hooks. The CLI only knows the events and matchers it was
+// sent then, so hooks must have the same events, matchers, timeouts and
+// number of callbacks per matcher; if not, nothing is replaced and false
+// is returned.
+func (q *Query) ReplaceHooks(hooks map[types.HookEvent][]types.HookMatcher) bool {
+	q.hookMu.Lock()
+	defer q.hookMu.Unlock()
+
+	if !sameHookLayout(q.hooks, hooks) {
+		return false
+	}
+	for event, matchers := range hooks {
+		for i, matcher := range matchers {
+			for j, callback := range matcher.Hooks {
+				q.hookCallbacks[q.hookIDs[event][i][j]] = callback
+			}
+		}
+	}
+	q.hooks = hooks
+	return true
+}
+
+// sameHookLayout reports whether a and b register callbacks for the same
+// events and matchers.
+func sameHookLayout(a, b map[types.HookEvent][]types.HookMatcher) bool {
+	count := func(hooks map[types.HookEvent][]types.HookMatcher) int {
+		n := 0
+		for _, matchers := range hooks {
+			if len(matchers) > 0 {
+				n++
+			}
+		}
+		return n
+	}
+	if count(a) != count(b) {
+		return false
+	}
+	for event, matchers := range a {
+		other := b[event]
+		if len(matchers) != len(other) {
+			return false
+		}
+		for i, matcher := range matchers {
+			if matcher.Matcher != other[i].Matcher || matcher.Timeout != other[i].Timeout ||
+				len(matcher.Hooks) != len(other[i].Hooks) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 func (q *Query) handleHookCallback(ctx context.Context, request map[string]any) (map[string]any, error) {
 	callbackID, _ := request["callback_id"].(string)
+	q.hookMu.RLock()
 	callback, ok := q.hookCallbacks[callbackID]
+	q.hookMu.RUnlock()
 	if !ok {
 		return nil, fmt.Errorf("no hook callback found for ID: %s", callbackID)
 	}
@@ -328,6 +484,7 @@ func parseHookInput(data any) (types.HookInput, error) {
 		TranscriptPath: getString(m, "transcript_path"),
 		Cwd:            getString(m, "cwd"),
 		PermissionMode: getString(m, "permission_mode"),
+		Raw:            m,
 	}
 
 	switch eventName {
@@ -390,6 +547,42 @@ func parseHookInput(data any) (types.HookInput, error) {
 	}
 }
 
+// parsePermissionUpdates parses the permission_suggestions of a
+// can_use_tool request, in the format of PermissionUpdate.ToMap.
+func parsePermissionUpdates(data any) []types.PermissionUpdate {
+	items, _ := data.([]any)
+	var updates []types.PermissionUpdate
+	for _, item := range items {
+		m, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		update := types.PermissionUpdate{
+			Type:        types.PermissionUpdateType(getString(m, "type")),
+			Behavior:    types.PermissionBehavior(getString(m, "behavior")),
+			Mode:        types.PermissionMode(getString(m, "mode")),
+			Destination: types.PermissionUpdateDestination(getString(m, "destination")),
+		}
+		rules, _ := m["rules"].([]any)
+		for _, r := range rules {
+			if rule, ok := r.(map[string]any); ok {
+				update.Rules = append(update.Rules, types.PermissionRuleValue{
+					ToolName:    getString(rule, "toolName"),
+					RuleContent: getString(rule, "ruleContent"),
+				})
+			}
+		}
+		dirs, _ := m["directories"].([]any)
+		for _, d := range dirs {
+			if dir, ok := d.(string); ok {
+				update.Directories = append(update.Directories, dir)
+			}
+		}
+		updates = append(updates, update)
+	}
+	return updates
+}
+
 func getString(m map[string]any, key string) string {
 	if v, ok := m[key].(string); ok {
 		return v
@@ -433,117 +626,12 @@ func (q *Query) handleMCPMessage(ctx context.Context, request map[string]any) (m
 		}, nil
 	}
 
-	method, _ := message["method"].(string)
-	params, _ := message["params"].(map[string]any)
-
-	var result map[string]any
-
-	switch method {
-	case "initialize":
-		result = map[string]any{
-			"jsonrpc": "2.0",
-			"id":      message["id"],
-			"result": map[string]any{
-				"protocolVersion": "2024-11-05",
-				"capabilities": map[string]any{
-					"tools": map[string]any{},
-				},
-				"serverInfo": map[string]any{
-					"name":    server.Name,
-					"version": server.Version,
-				},
-			},
-		}
-
-	case "tools/list":
-		toolsList := make([]map[string]any, 0, len(server.Tools))
-		for _, tool := range server.Tools {
-			toolsList = append(toolsList, map[string]any{
-				"name":        tool.Name,
-				"description": tool.Description,
-				"inputSchema": tool.InputSchema,
-			})
-		}
-		result = map[string]any{
-			"jsonrpc": "2.0",
-			"id":      message["id"],
-			"result": map[string]any{
-				"tools": toolsList,
-			},
-		}
-
-	case "tools/call":
-		name, _ := params["name"].(string)
-		arguments, _ := params["arguments"].(map[string]any)
-
-		var tool *types.MCPTool
-		for i := range server.Tools {
-			if server.Tools[i].Name == name {
-				tool = &server.Tools[i]
-				break
-			}
-		}
-
-		if tool == nil {
-			result = map[string]any{
-				"jsonrpc": "2.0",
-				"id":      message["id"],
-				"error": map[string]any{
-					"code":    -32601,
-					"message": fmt.Sprintf("Tool '%s' not found", name),
-				},
-			}
-		} else {
-			toolResult, err := tool.Handler(ctx, arguments)
-			if err != nil {
-				result = map[string]any{
-					"jsonrpc": "2.0",
-					"id":      message["id"],
-					"error": map[string]any{
-						"code":    -32603,
-						"message": err.Error(),
-					},
-				}
-			} else {
-				content := make([]map[string]any, 0, len(toolResult.Content))
-				for _, c := range toolResult.Content {
-					item := map[string]any{"type": c.Type}
-					switch c.Type {
-					case "text":
-						item["text"] = c.Text
-					case "image":
-						item["data"] = c.Data
-						item["mimeType"] = c.MimeType
-					}
-					content = append(content, item)
-				}
-				responseData := map[string]any{"content": content}
-				if toolResult.IsError {
-					responseData["is_error"] = true
-				}
-				result = map[string]any{
-					"jsonrpc": "2.0",
-					"id":      message["id"],
-					"result":  responseData,
-				}
-			}
-		}
-
-	case "notifications/initialized":
-		result = map[string]any{"jsonrpc": "2.0", "result": map[string]any{}}
-
-	default:
-		result = map[string]any{
-			"jsonrpc": "2.0",
-			"id":      message["id"],
-			"error": map[string]any{
-				"code":    -32601,
-				"message": fmt.Sprintf("Method '%s' not found", method),
-			},
-		}
+	response := HandleMCPMessage(ctx, server, message)
+	if response == nil {
+		// The CLI expects a reply to notifications too
+		response = map[string]any{"jsonrpc": "2.0", "result": map[string]any{}}
 	}
-
-	return map[string]any{"mcp_response": result}, nil
+	return map[string]any{"mcp_response": response}, nil
 }
 
 func (q *Query) sendControlRequest(ctx context.Context, request map[string]any, timeout time.Duration) (map[string]any, error) {
@@ -588,6 +676,12 @@ func (q *Query) sendControlRequest(ctx context.Context, request map[string]any,
 
 	case response, ok := <-responseCh:
 		if !ok {
+			q.readErrMu.Lock()
+			readErr := q.readErr
+			q.readErrMu.Unlock()
+			if readErr != nil {
+				return nil, fmt.Errorf("control request failed: %w", readErr)
+			}
 			return nil, fmt.Errorf("control request channel closed unexpectedly")
 		}
 
@@ -639,6 +733,12 @@ func (q *Query) sendErrorResponse(ctx context.Context, requestID string, errMsg
 	_ = q.transport.Write(ctx, string(data)+"\n")
 }
 
+// KeepAlive sends a keep_alive message, so the CLI and anything between
+// it and the SDK see the connection in use.
+func (q *Query) KeepAlive(ctx context.Context) error {
+	return q.transport.Write(ctx, `{"type":"keep_alive"}`+"\n")
+}
+
 // GetMCPStatus gets current MCP server connection status.
 func (q *Query) GetMCPStatus(ctx context.Context) (map[string]any, error) {
 	return q.sendControlRequest(ctx, map[string]any{"subtype": RequestSubtypeMCPStatus}, 60*time.Second)
@@ -646,7 +746,19 @@ func (q *Query) GetMCPStatus(ctx context.Context) (map[string]any, error) {
 
 // Interrupt sends an interrupt control request.
 func (q *Query) Interrupt(ctx context.Context) error {
-	_, err := q.sendControlRequest(ctx, map[string]any{"subtype": RequestSubtypeInterrupt}, 60*time.Second)
+	return q.InterruptWithReason(ctx, "")
+}
+
+// InterruptWithReason sends an interrupt control request carrying a reason.
+// An empty reason sends a bare interrupt.
+func (q *Query) InterruptWithReason(ctx context.Context, reason string) error {
+	req := map[string]any{
+		"subtype": RequestSubtypeInterrupt,
+	}
+	if reason != "" {
+		req["reason"] = reason
+	}
+	_, err := q.sendControlRequest(ctx, req, 60*time.Second)
 	return err
 }
 
@@ -680,6 +792,16 @@ func (q *Query) RewindFiles(ctx context.Context, userMessageID string) error {
 	return err
 }
 
+// UpdatePermissions applies permission updates, in the format of ToMap,
+// to the running session.
+func (q *Query) UpdatePermissions(ctx context.Context, updates []map[string]any) error {
+	_, err := q.sendControlRequest(ctx, map[string]any{
+		"subtype": RequestSubtypeUpdatePermissions,
+		"updates": updates,
+	}, 60*time.Second)
+	return err
+}
+
 // StreamInput streams input messages to transport.
 func (q *Query) StreamInput(ctx context.Context, messages <-chan map[string]any) {
 	for {
@@ -717,6 +839,11 @@ func (q *Query) ReceiveMessages() <-chan map[string]any {
 	return q.messageChan
 }
 
+// ToolStats returns the tool usage statistics recorded so far.
+func (q *Query) ToolStats() map[string]ToolStat {
+	return q.toolStats.Snapshot()
+}
+
 // InitResult returns the initialization result.
 func (q *Query) InitResult() map[string]any {
 	return q.initResult
@@ -724,10 +851,16 @@ func (q *Query) InitResult() map[string]any {
 
 // Close closes the query and transport.
 func (q *Query) Close() error {
+	return q.CloseWith(q.transport.Close)
+}
+
+// CloseWith closes the query like Close, with closeTransport closing its
+// transport in place of Transport.Close.
+func (q *Query) CloseWith(closeTransport func() error) error {
 	if q.closed.Swap(true) {
 		return nil
 	}
 	q.cancel()
 	q.wg.Wait()
-	return q.transport.Close()
+	return closeTransport()
 }
@@ -2,7 +2,10 @@ package protocol
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -190,6 +193,27 @@ func TestParseHookInput_PreToolUse(t *testing.T) {
 	}
 }
 
+func TestParseHookInput_Raw(t *testing.T) {
+	data := map[string]any{
+		"hook_event_name": "Stop",
+		"session_id":      "session-123",
+		"new_cli_field":   "value",
+	}
+
+	result, err := parseHookInput(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	input, ok := result.(types.StopHookInput)
+	if !ok {
+		t.Fatalf("Expected StopHookInput, got %T", result)
+	}
+	if input.Raw["new_cli_field"] != "value" {
+		t.Errorf("Expected the raw input to keep unknown fields, got %v", input.Raw)
+	}
+}
+
 func TestParseHookInput_PostToolUse(t *testing.T) {
 	data := map[string]any{
 		"hook_event_name": "PostToolUse",
@@ -914,6 +938,59 @@ func TestQuery_handleCanUseTool_AllowWithUpdatedPermissions(t *testing.T) {
 	}
 }
 
+func TestQuery_handleCanUseTool_Suggestions(t *testing.T) {
+	mock := transport.NewMockTransport()
+
+	var got []types.PermissionUpdate
+	canUseTool := func(ctx context.Context, toolName string, input map[string]any, permCtx types.ToolPermissionContext) (types.PermissionResult, error) {
+		got = permCtx.Suggestions
+		return types.PermissionResultAllow{}, nil
+	}
+
+	q := NewQuery(QueryConfig{
+		Transport:       mock,
+		IsStreamingMode: true,
+		CanUseTool:      canUseTool,
+	})
+	defer func() { _ = q.Close() }()
+
+	request := map[string]any{
+		"tool_name": "Bash",
+		"input":     map[string]any{"command": "npm test"},
+		"permission_suggestions": []any{
+			map[string]any{
+				"type":        "addRules",
+				"rules":       []any{map[string]any{"toolName": "Bash", "ruleContent": "npm test:*"}},
+				"behavior":    "allow",
+				"destination": "localSettings",
+			},
+			map[string]any{"type": "addDirectories", "directories": []any{"/tmp/build"}, "destination": "session"},
+			"not an update",
+		},
+	}
+
+	if _, err := q.handleCanUseTool(context.Background(), request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := []types.PermissionUpdate{
+		{
+			Type:        types.PermissionUpdateTypeAddRules,
+			Rules:       []types.PermissionRuleValue{{ToolName: "Bash", RuleContent: "npm test:*"}},
+			Behavior:    types.PermissionBehaviorAllow,
+			Destination: types.PermissionUpdateDestinationLocalSettings,
+		},
+		{
+			Type:        types.PermissionUpdateTypeAddDirectories,
+			Directories: []string{"/tmp/build"},
+			Destination: types.PermissionUpdateDestinationSession,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected suggestions %+v, got %+v", want, got)
+	}
+}
+
 func TestQuery_handleCanUseTool_Deny(t *testing.T) {
 	mock := transport.NewMockTransport()
 
@@ -980,6 +1057,43 @@ func TestQuery_handleCanUseTool_DenyInterrupt(t *testing.T) {
 	}
 }
 
+func TestQuery_handleCanUseTool_Ask(t *testing.T) {
+	mock := transport.NewMockTransport()
+
+	canUseTool := func(ctx context.Context, toolName string, input map[string]any, permCtx types.ToolPermissionContext) (types.PermissionResult, error) {
+		return types.PermissionResultAsk{
+			Message: "Deploy to production?",
+		}, nil
+	}
+
+	q := NewQuery(QueryConfig{
+		Transport:       mock,
+		IsStreamingMode: true,
+		CanUseTool:      canUseTool,
+	})
+	defer func() { _ = q.Close() }()
+
+	request := map[string]any{
+		"tool_name": "Bash",
+		"input":     map[string]any{"command": "make deploy"},
+	}
+
+	result, err := q.handleCanUseTool(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if result["behavior"] != "ask" {
+		t.Errorf("Expected behavior 'ask', got %v", result["behavior"])
+	}
+	if result["message"] != "Deploy to production?" {
+		t.Errorf("Expected message 'Deploy to production?', got %v", result["message"])
+	}
+	if _, ok := result["updatedInput"]; ok {
+		t.Error("Expected no updatedInput for ask")
+	}
+}
+
 func TestQuery_handleCanUseTool_NoCallback(t *testing.T) {
 	mock := transport.NewMockTransport()
 
@@ -1091,6 +1205,49 @@ func TestQuery_handleHookCallback_UnknownID(t *testing.T) {
 	}
 }
 
+func TestQuery_ReplaceHooks(t *testing.T) {
+	decide := func(decision types.HookDecision) types.HookCallback {
+		return func(ctx context.Context, input types.HookInput, toolUseID string, hookCtx types.HookContext) (types.HookOutput, error) {
+			return types.HookOutput{Decision: decision}, nil
+		}
+	}
+	hooks := func(matcher string, callback types.HookCallback) map[types.HookEvent][]types.HookMatcher {
+		return map[types.HookEvent][]types.HookMatcher{
+			types.HookEventPreToolUse: {{Matcher: matcher, Hooks: []types.HookCallback{callback}}},
+		}
+	}
+
+	mock := transport.NewMockTransport()
+	q := NewQuery(QueryConfig{
+		Transport:       mock,
+		IsStreamingMode: true,
+		Hooks:           hooks("Bash", decide("")),
+	})
+	defer func() { _ = q.Close() }()
+
+	// Register the callbacks as Initialize does
+	q.hookCallbacks["hook_1"] = q.hooks[types.HookEventPreToolUse][0].Hooks[0]
+	q.hookIDs[types.HookEventPreToolUse] = [][]string{{"hook_1"}}
+
+	if q.ReplaceHooks(hooks("Write", decide(types.HookDecisionBlock))) {
+		t.Error("Expected hooks with another matcher not to be replaced")
+	}
+	if !q.ReplaceHooks(hooks("Bash", decide(types.HookDecisionBlock))) {
+		t.Fatal("Expected hooks with the same layout to be replaced")
+	}
+
+	result, err := q.handleHookCallback(context.Background(), map[string]any{
+		"callback_id": "hook_1",
+		"input":       map[string]any{"hook_event_name": "PreToolUse", "tool_name": "Bash"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result["decision"] != "block" {
+		t.Errorf("Expected the replaced callback to run, got %v", result)
+	}
+}
+
 func TestQuery_handleHookCallback_CallbackError(t *testing.T) {
 	mock := transport.NewMockTransport()
 	expectedErr := errors.New("hook callback error")
@@ -1120,6 +1277,50 @@ func TestQuery_handleHookCallback_CallbackError(t *testing.T) {
 	}
 }
 
+func TestQuery_handleControlRequest_OnError(t *testing.T) {
+	mock := transport.NewMockTransport()
+	_ = mock.Connect(context.Background())
+	expectedErr := errors.New("hook callback error")
+
+	var reported []error
+	q := NewQuery(QueryConfig{
+		Transport:       mock,
+		IsStreamingMode: true,
+		OnError:         func(err error) { reported = append(reported, err) },
+	})
+	defer func() { _ = q.Close() }()
+
+	q.hookCallbacks["error-callback"] = func(ctx context.Context, input types.HookInput, toolUseID string, hookCtx types.HookContext) (types.HookOutput, error) {
+		return types.HookOutput{}, expectedErr
+	}
+
+	q.handleControlRequest(context.Background(), map[string]any{
+		"request_id": "req-1",
+		"request": map[string]any{
+			"subtype":     "hook_callback",
+			"callback_id": "error-callback",
+			"input": map[string]any{
+				"hook_event_name": "PreToolUse",
+				"session_id":      "session-123",
+				"tool_name":       "Bash",
+			},
+		},
+	})
+
+	if len(reported) != 1 {
+		t.Fatalf("Expected 1 reported error, got %d", len(reported))
+	}
+	if !errors.Is(reported[0], expectedErr) {
+		t.Errorf("Expected reported error to wrap %v, got %v", expectedErr, reported[0])
+	}
+	if got := reported[0].Error(); got != "hook_callback: hook callback error" {
+		t.Errorf("Unexpected error message %q", got)
+	}
+	if len(mock.GetWrittenData()) == 0 {
+		t.Error("Expected error response to be written")
+	}
+}
+
 // Tests for handleMCPMessage
 
 func TestQuery_handleMCPMessage_Initialize(t *testing.T) {
@@ -1602,6 +1803,40 @@ func TestQuery_Interrupt(t *testing.T) {
 	}
 }
 
+func TestQuery_InterruptWithReason(t *testing.T) {
+	mock := transport.NewMockTransport()
+	_ = mock.Connect(context.Background())
+
+	q := NewQuery(QueryConfig{
+		Transport:       mock,
+		IsStreamingMode: true,
+	})
+	defer func() { _ = q.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_ = q.InterruptWithReason(ctx, "user cancelled")
+
+	time.Sleep(10 * time.Millisecond)
+	written := mock.GetWrittenData()
+	if len(written) == 0 {
+		t.Fatal("Expected interrupt request to be written")
+	}
+
+	var req map[string]any
+	if err := json.Unmarshal([]byte(written[0]), &req); err != nil {
+		t.Fatalf("Failed to unmarshal request: %v", err)
+	}
+	inner, _ := req["request"].(map[string]any)
+	if inner["subtype"] != RequestSubtypeInterrupt {
+		t.Errorf("Expected subtype interrupt, got %v", inner["subtype"])
+	}
+	if inner["reason"] != "user cancelled" {
+		t.Errorf("Expected reason 'user cancelled', got %v", inner["reason"])
+	}
+}
+
 func TestQuery_SetPermissionMode(t *testing.T) {
 	mock := transport.NewMockTransport()
 	_ = mock.Connect(context.Background())
@@ -1690,6 +1925,33 @@ func TestQuery_RewindFiles(t *testing.T) {
 	}
 }
 
+func TestQuery_UpdatePermissions(t *testing.T) {
+	mock := transport.NewMockTransport()
+	_ = mock.Connect(context.Background())
+
+	q := NewQuery(QueryConfig{
+		Transport:       mock,
+		IsStreamingMode: true,
+	})
+	defer func() { _ = q.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_ = q.UpdatePermissions(ctx, []map[string]any{
+		{"type": "addDirectories", "directories": []string{"/work/docs"}, "destination": "session"},
+	})
+
+	time.Sleep(10 * time.Millisecond)
+	written := mock.GetWrittenData()
+	if len(written) == 0 {
+		t.Fatal("Expected update permissions request to be written")
+	}
+	if !strings.Contains(written[0], `"subtype":"update_permissions"`) || !strings.Contains(written[0], `"directories":["/work/docs"]`) {
+		t.Errorf("Unexpected request: %s", written[0])
+	}
+}
+
 // Tests for StreamInput
 
 func TestQuery_StreamInput_WritesMessages(t *testing.T) {
@@ -1828,6 +2090,36 @@ func TestQuery_Initialize_WithHooks(t *testing.T) {
 	}
 }
 
+func TestQuery_Restore(t *testing.T) {
+	mock := transport.NewMockTransport()
+	_ = mock.Connect(context.Background())
+
+	noop := func(ctx context.Context, input types.HookInput, toolUseID string, hookCtx types.HookContext) (types.HookOutput, error) {
+		return types.HookOutput{}, nil
+	}
+	q := NewQuery(QueryConfig{
+		Transport:       mock,
+		IsStreamingMode: true,
+		Hooks: map[types.HookEvent][]types.HookMatcher{
+			types.HookEventPreToolUse: {{Matcher: "Bash", Hooks: []types.HookCallback{noop, noop}}},
+		},
+	})
+	defer func() { _ = q.Close() }()
+
+	init := map[string]any{"output_style": "default"}
+	q.Restore(init)
+	if len(mock.GetWrittenData()) != 0 {
+		t.Error("Expected nothing sent on Restore")
+	}
+	// The callbacks get the IDs Initialize would have given them
+	if _, ok := q.hookCallbacks["hook_1"]; !ok || len(q.hookCallbacks) != 2 {
+		t.Errorf("Expected the hooks registered, got %v", q.hookCallbacks)
+	}
+	if q.InitResult()["output_style"] != "default" {
+		t.Errorf("Expected the restored init result, got %v", q.InitResult())
+	}
+}
+
 func TestQuery_handleMCPMessage_ToolsCall_ImageContent(t *testing.T) {
 	mock := transport.NewMockTransport()
 
@@ -1945,3 +2237,67 @@ func TestQuery_handleMCPMessage_ToolsCall_IsError(t *testing.T) {
 		t.Error("Expected is_error to be true")
 	}
 }
+
+func TestQuery_handleControlRequest_HandlerContext(t *testing.T) {
+	mock := transport.NewMockTransport()
+	_ = mock.Connect(context.Background())
+
+	type ctxKey struct{}
+	var got any
+	canUseTool := func(ctx context.Context, toolName string, input map[string]any, permCtx types.ToolPermissionContext) (types.PermissionResult, error) {
+		got = ctx.Value(ctxKey{})
+		return types.PermissionResultAllow{}, nil
+	}
+
+	q := NewQuery(QueryConfig{
+		Transport:       mock,
+		IsStreamingMode: true,
+		CanUseTool:      canUseTool,
+		HandlerContext: func(ctx context.Context) context.Context {
+			return context.WithValue(ctx, ctxKey{}, "decorated")
+		},
+	})
+	defer func() { _ = q.Close() }()
+
+	q.handleControlRequest(context.Background(), map[string]any{
+		"request_id": "req_1",
+		"request": map[string]any{
+			"subtype":   RequestSubtypeCanUseTool,
+			"tool_name": "Bash",
+			"input":     map[string]any{},
+		},
+	})
+
+	if got != "decorated" {
+		t.Errorf("Expected decorated context value, got %v", got)
+	}
+}
+
+func TestQuery_KeepAlive(t *testing.T) {
+	mock := transport.NewMockTransport().WithMessages(
+		map[string]any{"type": "keep_alive"},
+		map[string]any{"type": "result", "subtype": "success"},
+	)
+	_ = mock.Connect(context.Background())
+
+	q := NewQuery(QueryConfig{Transport: mock, IsStreamingMode: true})
+	defer func() { _ = q.Close() }()
+
+	if err := q.KeepAlive(context.Background()); err != nil {
+		t.Fatalf("KeepAlive failed: %v", err)
+	}
+	if written := mock.GetWrittenData(); len(written) != 1 || written[0] != `{"type":"keep_alive"}`+"\n" {
+		t.Errorf("Expected a keep_alive message, got %q", written)
+	}
+
+	// keep_alive messages from the CLI are not passed on
+	q.Start(context.Background())
+	select {
+	case msg := <-q.ReceiveMessages():
+		if msg["type"] != "result" {
+			t.Errorf("Expected keep_alive to be skipped, got %v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for message")
+	}
+}
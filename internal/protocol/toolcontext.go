@@ -0,0 +1,35 @@
+package protocol
+
+import "context"
+
+// toolUseIDKey is the context key for the tool use ID of a control request.
+type toolUseIDKey struct{}
+
+// mcpToolUseIDMeta is the _meta key under which the CLI passes the tool
+// use ID of an MCP tool call.
+const mcpToolUseIDMeta = "claudecode/toolUseId"
+
+// WithToolUseID returns a copy of ctx carrying the ID of the tool use a
+// callback runs for.
+func WithToolUseID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, toolUseIDKey{}, id)
+}
+
+// ToolUseIDFromContext returns the tool use ID carried by ctx, or "".
+func ToolUseIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(toolUseIDKey{}).(string)
+	return id
+}
+
+// controlToolUseID returns the ID of the tool use a control request is
+// about, or "" if there is none.
+func controlToolUseID(request map[string]any) string {
+	if id, ok := request["tool_use_id"].(string); ok {
+		return id
+	}
+	message, _ := request["message"].(map[string]any)
+	params, _ := message["params"].(map[string]any)
+	meta, _ := params["_meta"].(map[string]any)
+	id, _ := meta[mcpToolUseIDMeta].(string)
+	return id
+}
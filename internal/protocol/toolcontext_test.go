@@ -0,0 +1,35 @@
+package protocol
+
+import (
+	"context"
+	"testing"
+)
+
+func TestControlToolUseID(t *testing.T) {
+	tests := []struct {
+		name    string
+		request map[string]any
+		want    string
+	}{
+		{"can_use_tool", map[string]any{"subtype": "can_use_tool", "tool_use_id": "toolu_1"}, "toolu_1"},
+		{"mcp_message", map[string]any{
+			"subtype": "mcp_message",
+			"message": map[string]any{"params": map[string]any{"_meta": map[string]any{"claudecode/toolUseId": "toolu_2"}}},
+		}, "toolu_2"},
+		{"none", map[string]any{"subtype": "mcp_message", "message": map[string]any{"method": "tools/list"}}, ""},
+	}
+	for _, tt := range tests {
+		if got := controlToolUseID(tt.request); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestWithToolUseID(t *testing.T) {
+	if id := ToolUseIDFromContext(context.Background()); id != "" {
+		t.Errorf("Expected no tool use ID, got %q", id)
+	}
+	if id := ToolUseIDFromContext(WithToolUseID(context.Background(), "toolu_1")); id != "toolu_1" {
+		t.Errorf("Expected toolu_1, got %q", id)
+	}
+}
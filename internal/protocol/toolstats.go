@@ -0,0 +1,134 @@
+package protocol
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// ToolStat holds usage statistics for one tool.
+type ToolStat struct {
+	Calls         int
+	Failures      int
+	TotalDuration time.Duration
+	OutputBytes   int64
+}
+
+// ToolStats tracks tool usage by pairing tool_use blocks in assistant
+// messages with the tool_result blocks that answer them.
+type ToolStats struct {
+	mu      sync.Mutex
+	pending map[string]pendingToolUse
+	stats   map[string]*ToolStat
+	now     func() time.Time
+}
+
+type pendingToolUse struct {
+	name  string
+	start time.Time
+}
+
+// NewToolStats creates an empty ToolStats.
+func NewToolStats() *ToolStats {
+	return &ToolStats{
+		pending: make(map[string]pendingToolUse),
+		stats:   make(map[string]*ToolStat),
+		now:     time.Now,
+	}
+}
+
+// Observe records the tool uses and results in a CLI message.
+func (s *ToolStats) Observe(message map[string]any) {
+	msgType, _ := message["type"].(string)
+	if msgType != "assistant" && msgType != "user" && msgType != "result" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if msgType == "result" {
+		// Tool uses still pending when the turn ends were never answered
+		clear(s.pending)
+		return
+	}
+
+	inner, _ := message["message"].(map[string]any)
+	blocks, _ := inner["content"].([]any)
+	now := s.now()
+
+	for _, item := range blocks {
+		block, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		switch block["type"] {
+		case "tool_use":
+			id, _ := block["id"].(string)
+			name, _ := block["name"].(string)
+			if id != "" && name != "" {
+				s.pending[id] = pendingToolUse{name: name, start: now}
+			}
+		case "tool_result":
+			id, _ := block["tool_use_id"].(string)
+			use, ok := s.pending[id]
+			if !ok {
+				continue
+			}
+			delete(s.pending, id)
+
+			stat := s.stats[use.name]
+			if stat == nil {
+				stat = &ToolStat{}
+				s.stats[use.name] = stat
+			}
+			stat.Calls++
+			stat.TotalDuration += now.Sub(use.start)
+			stat.OutputBytes += contentSize(block["content"])
+			if isError, _ := block["is_error"].(bool); isError {
+				stat.Failures++
+			}
+		}
+	}
+}
+
+// Snapshot returns a copy of the statistics, keyed by tool name.
+func (s *ToolStats) Snapshot() map[string]ToolStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := make(map[string]ToolStat, len(s.stats))
+	for name, stat := range s.stats {
+		snapshot[name] = *stat
+	}
+	return snapshot
+}
+
+// contentSize returns the size of tool result content in bytes.
+func contentSize(content any) int64 {
+	switch c := content.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(c))
+	case []any:
+		// Count text directly and other blocks by their JSON size
+		var size int64
+		for _, item := range c {
+			if block, ok := item.(map[string]any); ok {
+				if text, ok := block["text"].(string); ok {
+					size += int64(len(text))
+					continue
+				}
+			}
+			size += contentSize(item)
+		}
+		return size
+	default:
+		data, err := json.Marshal(c)
+		if err != nil {
+			return 0
+		}
+		return int64(len(data))
+	}
+}
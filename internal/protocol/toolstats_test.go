@@ -0,0 +1,112 @@
+package protocol
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/transport"
+)
+
+func toolUseMessage(id, name string) map[string]any {
+	return map[string]any{
+		"type": "assistant",
+		"message": map[string]any{
+			"content": []any{
+				map[string]any{"type": "tool_use", "id": id, "name": name, "input": map[string]any{}},
+			},
+		},
+	}
+}
+
+func toolResultMessage(id string, content any, isError bool) map[string]any {
+	return map[string]any{
+		"type": "user",
+		"message": map[string]any{
+			"content": []any{
+				map[string]any{"type": "tool_result", "tool_use_id": id, "content": content, "is_error": isError},
+			},
+		},
+	}
+}
+
+func TestToolStats_Observe(t *testing.T) {
+	stats := NewToolStats()
+	now := time.Unix(0, 0)
+	stats.now = func() time.Time { return now }
+
+	stats.Observe(toolUseMessage("t1", "Bash"))
+	now = now.Add(2 * time.Second)
+	stats.Observe(toolResultMessage("t1", "hello", false))
+
+	stats.Observe(toolUseMessage("t2", "Bash"))
+	now = now.Add(time.Second)
+	stats.Observe(toolResultMessage("t2", []any{
+		map[string]any{"type": "text", "text": "failed"},
+	}, true))
+
+	stats.Observe(toolUseMessage("t3", "Read"))
+	stats.Observe(toolResultMessage("t3", nil, false))
+
+	got := stats.Snapshot()
+	bash := got["Bash"]
+	if bash.Calls != 2 || bash.Failures != 1 {
+		t.Errorf("Bash calls/failures = %d/%d, want 2/1", bash.Calls, bash.Failures)
+	}
+	if bash.TotalDuration != 3*time.Second {
+		t.Errorf("Bash duration = %v, want 3s", bash.TotalDuration)
+	}
+	if bash.OutputBytes != int64(len("hello")+len("failed")) {
+		t.Errorf("Bash output bytes = %d, want 11", bash.OutputBytes)
+	}
+	if got["Read"].Calls != 1 {
+		t.Errorf("Read calls = %d, want 1", got["Read"].Calls)
+	}
+}
+
+func TestToolStats_UnmatchedResult(t *testing.T) {
+	stats := NewToolStats()
+
+	stats.Observe(toolResultMessage("missing", "output", false))
+	stats.Observe(toolUseMessage("t1", "Bash"))
+	stats.Observe(map[string]any{"type": "result"})
+	stats.Observe(toolResultMessage("t1", "late", false))
+
+	if got := stats.Snapshot(); len(got) != 0 {
+		t.Errorf("Expected no stats, got %v", got)
+	}
+}
+
+func TestQuery_ToolStats(t *testing.T) {
+	shared := NewToolStats()
+	mock := transport.NewMockTransport().WithMessages(
+		toolUseMessage("t1", "Grep"),
+		toolResultMessage("t1", "match", false),
+		map[string]any{"type": "result"},
+	)
+	_ = mock.Connect(context.Background())
+
+	q := NewQuery(QueryConfig{
+		Transport:       mock,
+		IsStreamingMode: true,
+		ToolStats:       shared,
+	})
+	defer func() { _ = q.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	q.Start(ctx)
+	for msg := range q.ReceiveMessages() {
+		if msg["type"] == "result" {
+			break
+		}
+	}
+
+	if got := q.ToolStats()["Grep"]; got.Calls != 1 || got.OutputBytes != 5 {
+		t.Errorf("Grep stats = %+v, want 1 call and 5 bytes", got)
+	}
+	if got := shared.Snapshot()["Grep"]; got.Calls != 1 {
+		t.Errorf("Shared tracker Grep calls = %d, want 1", got.Calls)
+	}
+}
@@ -0,0 +1,101 @@
+package transport
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+)
+
+// containerRuntimes are the runtimes tried, in order, when
+// ContainerOptions.Runtime is empty.
+var containerRuntimes = []string{"docker", "podman"}
+
+// findContainerRuntime returns the path of the container runtime to use.
+func findContainerRuntime(runtime string) (string, error) {
+	if runtime != "" {
+		path, err := exec.LookPath(runtime)
+		if err != nil {
+			return "", fmt.Errorf("container runtime not found: %s", runtime)
+		}
+		return path, nil
+	}
+	for _, name := range containerRuntimes {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("container runtime not found, install docker or podman")
+}
+
+// containerCommand wraps the CLI command cmd in a `run` of the container
+// runtime. The working directory, the directories of AddDirs and the
+// transport's temporary files are mounted at the same paths inside the
+// container, so that paths on the command line resolve the same way.
+//
+// Environment variables are passed by name only, with their values taken
+// from the runtime's own environment (see buildEnv), so that secrets do
+// not appear on the command line.
+func (t *SubprocessTransport) containerCommand(cmd []string) ([]string, error) {
+	c := t.options.Container
+	if c.Image == "" {
+		return nil, fmt.Errorf("container image is required")
+	}
+
+	cwd := t.cwd
+	if cwd == "" {
+		var err error
+		if cwd, err = os.Getwd(); err != nil {
+			return nil, err
+		}
+	}
+
+	args := []string{t.launcherPath, "run", "--rm", "-i", "-v", cwd + ":" + cwd, "-w", cwd}
+	for _, dir := range t.options.AddDirs {
+		args = append(args, "-v", dir+":"+dir)
+	}
+	for _, file := range t.tempFiles {
+		args = append(args, "-v", file+":"+file+":ro")
+	}
+	for _, mount := range c.Mounts {
+		args = append(args, "-v", mount)
+	}
+	for _, name := range t.containerEnvNames() {
+		args = append(args, "-e", name)
+	}
+
+	args = append(args, c.Image)
+	return append(args, cmd...), nil
+}
+
+// containerEnvNames returns the sorted names of the variables passed into
+// the container: those of ContainerOptions.Env and the ones of cliEnv.
+func (t *SubprocessTransport) containerEnvNames() []string {
+	seen := t.cliEnv()
+	for name := range t.options.Container.Env {
+		seen[name] = ""
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// cliEnv returns the variables that must reach a CLI that does not inherit
+// the environment of the started process: those of Options.Env, the proxy
+// settings and the ones the SDK sets.
+func (t *SubprocessTransport) cliEnv() map[string]string {
+	env := t.proxyEnv()
+	env["CLAUDE_CODE_ENTRYPOINT"] = "sdk-go"
+	env["CLAUDE_AGENT_SDK_VERSION"] = sdkVersion
+	if t.options.EnableFileCheckpointing {
+		env["CLAUDE_CODE_ENABLE_SDK_FILE_CHECKPOINTING"] = "true"
+	}
+	for k, v := range t.options.Env {
+		env[k] = v
+	}
+	return env
+}
@@ -0,0 +1,94 @@
+package transport
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSubprocessTransport_BuildCommand_Container(t *testing.T) {
+	transport := &SubprocessTransport{
+		cliPath:      "claude",
+		launcherPath: "/usr/bin/podman",
+		cwd:          "/work",
+		prompt:       "Hello",
+		options: &Options{
+			AddDirs: []string{"/shared"},
+			Env:     map[string]string{"DEBUG": "1"},
+			Container: &ContainerOptions{
+				Image:  "agent:latest",
+				Mounts: []string{"/cache:/cache:ro"},
+				Env:    map[string]string{"ANTHROPIC_API_KEY": "secret"},
+			},
+		},
+	}
+
+	cmd, err := transport.buildCommand()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	image := slices.Index(cmd, "agent:latest")
+	if image < 0 || cmd[image+1] != "claude" {
+		t.Fatalf("Expected the CLI to follow the image, got %v", cmd)
+	}
+	run := strings.Join(cmd[:image], " ")
+	expected := "/usr/bin/podman run --rm -i -v /work:/work -w /work -v /shared:/shared -v /cache:/cache:ro " +
+		"-e ANTHROPIC_API_KEY -e CLAUDE_AGENT_SDK_VERSION -e CLAUDE_CODE_ENTRYPOINT -e DEBUG"
+	if run != expected {
+		t.Errorf("Expected %q, got %q", expected, run)
+	}
+	if strings.Contains(strings.Join(cmd, " "), "secret") {
+		t.Error("Expected env values to stay off the command line")
+	}
+	if cmd[len(cmd)-1] != "Hello" {
+		t.Errorf("Expected the prompt last, got %v", cmd)
+	}
+
+	env := transport.buildEnv()
+	if !slices.Contains(env, "ANTHROPIC_API_KEY=secret") {
+		t.Error("Expected container env in the runtime's environment")
+	}
+}
+
+func TestSubprocessTransport_BuildCommand_ContainerNoImage(t *testing.T) {
+	transport := &SubprocessTransport{
+		cliPath:      "claude",
+		launcherPath: "docker",
+		cwd:          "/work",
+		isStreaming:  true,
+		options:      &Options{Container: &ContainerOptions{}},
+	}
+	if _, err := transport.buildCommand(); err == nil {
+		t.Error("Expected error without an image")
+	}
+}
+
+func TestNewSubprocessTransport_Container(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "podman")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	// docker is not on PATH, so podman is used
+	transport, err := NewSubprocessTransport("", true, &Options{Container: &ContainerOptions{Image: "agent"}})
+	if err != nil {
+		t.Fatalf("NewSubprocessTransport failed: %v", err)
+	}
+	if transport.launcherPath != path || transport.cliPath != "claude" {
+		t.Errorf("Unexpected runtime %q and CLI %q", transport.launcherPath, transport.cliPath)
+	}
+
+	_, err = NewSubprocessTransport("", true, &Options{Container: &ContainerOptions{Runtime: "docker", Image: "agent"}})
+	if err == nil || !strings.Contains(err.Error(), "docker") {
+		t.Errorf("Expected runtime not found error, got %v", err)
+	}
+}
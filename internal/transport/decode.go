@@ -0,0 +1,410 @@
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// decodeJSON decodes a message with encoding/json.
+func decodeJSON(data []byte) (map[string]any, error) {
+	var message map[string]any
+	err := json.Unmarshal(data, &message)
+	return message, err
+}
+
+// NewDecoder returns the function the subprocess transport decodes
+// messages with, using the low-allocation decoder if lowAlloc is set, for
+// other readers of the CLI's output. release returns the decoder to its
+// pool once done.
+func NewDecoder(lowAlloc bool) (decode func([]byte) (map[string]any, error), release func()) {
+	if !lowAlloc {
+		return decodeJSON, func() {}
+	}
+	d := decoderPool.Get().(*decoder)
+	return d.decode, func() { decoderPool.Put(d) }
+}
+
+// errDecodeFallback is returned by decoder for input it leaves to
+// encoding/json, which may still accept it.
+var errDecodeFallback = errors.New("decode with encoding/json")
+
+// decoderPool holds decoders between streams, keeping their interned
+// strings.
+var decoderPool = sync.Pool{New: func() any { return newDecoder() }}
+
+const (
+	// internLimit bounds the strings a decoder interns; the table is
+	// cleared when full.
+	internLimit = 1024
+	// internMaxLen is the length of the longest value interned. Keys are
+	// interned whatever their length.
+	internMaxLen = 40
+)
+
+// smallInts holds boxed float64 values of small integers, such as content
+// block indexes and token counts, so that decoding them does not allocate.
+var smallInts = func() []any {
+	values := make([]any, 1024)
+	for i := range values {
+		values[i] = float64(i)
+	}
+	return values
+}()
+
+// decoder decodes JSON objects into the values encoding/json produces for
+// map[string]any, allocating less: keys and short values such as types and
+// session IDs are interned, small integers are shared, and maps are sized
+// for the objects of stream events. It handles the messages of the CLI,
+// leaving anything unusual, such as invalid UTF-8, to encoding/json. A
+// decoder is not safe for concurrent use.
+type decoder struct {
+	data   []byte
+	pos    int
+	intern map[string]string
+}
+
+func newDecoder() *decoder {
+	return &decoder{intern: make(map[string]string, internLimit)}
+}
+
+// decode decodes data, falling back to encoding/json for input the
+// decoder does not handle.
+func (d *decoder) decode(data []byte) (map[string]any, error) {
+	if message, err := d.decodeObject(data); err == nil {
+		return message, nil
+	}
+	return decodeJSON(data)
+}
+
+// decodeObject decodes data, which must hold a single JSON object.
+func (d *decoder) decodeObject(data []byte) (map[string]any, error) {
+	d.data, d.pos = data, 0
+	defer func() { d.data = nil }()
+
+	d.skipSpace()
+	if d.peek() != '{' {
+		return nil, errDecodeFallback
+	}
+	value, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+	d.skipSpace()
+	if d.pos != len(d.data) {
+		return nil, errDecodeFallback
+	}
+	return value.(map[string]any), nil
+}
+
+func (d *decoder) peek() byte {
+	if d.pos < len(d.data) {
+		return d.data[d.pos]
+	}
+	return 0
+}
+
+func (d *decoder) skipSpace() {
+	for d.pos < len(d.data) {
+		switch d.data[d.pos] {
+		case ' ', '\t', '\n', '\r':
+			d.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (d *decoder) value() (any, error) {
+	d.skipSpace()
+	switch c := d.peek(); {
+	case c == '{':
+		return d.object()
+	case c == '[':
+		return d.array()
+	case c == '"':
+		raw, escaped, err := d.stringBytes()
+		if err != nil {
+			return nil, err
+		}
+		return d.str(raw, escaped, false)
+	case c == 't':
+		return true, d.literal("true")
+	case c == 'f':
+		return false, d.literal("false")
+	case c == 'n':
+		return nil, d.literal("null")
+	case c == '-' || (c >= '0' && c <= '9'):
+		return d.number()
+	default:
+		return nil, errDecodeFallback
+	}
+}
+
+func (d *decoder) object() (any, error) {
+	d.pos++ // {
+	object := make(map[string]any, 4)
+	d.skipSpace()
+	if d.peek() == '}' {
+		d.pos++
+		return object, nil
+	}
+	for {
+		d.skipSpace()
+		if d.peek() != '"' {
+			return nil, errDecodeFallback
+		}
+		raw, escaped, err := d.stringBytes()
+		if err != nil {
+			return nil, err
+		}
+		key, err := d.str(raw, escaped, true)
+		if err != nil {
+			return nil, err
+		}
+		d.skipSpace()
+		if d.peek() != ':' {
+			return nil, errDecodeFallback
+		}
+		d.pos++
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		object[key] = value
+
+		d.skipSpace()
+		switch d.peek() {
+		case ',':
+			d.pos++
+		case '}':
+			d.pos++
+			return object, nil
+		default:
+			return nil, errDecodeFallback
+		}
+	}
+}
+
+func (d *decoder) array() (any, error) {
+	d.pos++ // [
+	d.skipSpace()
+	if d.peek() == ']' {
+		d.pos++
+		return []any{}, nil
+	}
+	array := make([]any, 0, 4)
+	for {
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, value)
+
+		d.skipSpace()
+		switch d.peek() {
+		case ',':
+			d.pos++
+		case ']':
+			d.pos++
+			return array, nil
+		default:
+			return nil, errDecodeFallback
+		}
+	}
+}
+
+// stringBytes returns the raw contents of the string at the current
+// position and whether they hold escapes.
+func (d *decoder) stringBytes() ([]byte, bool, error) {
+	d.pos++ // opening quote
+	start := d.pos
+	escaped := false
+	for d.pos < len(d.data) {
+		switch c := d.data[d.pos]; {
+		case c == '"':
+			raw := d.data[start:d.pos]
+			d.pos++
+			return raw, escaped, nil
+		case c == '\\':
+			escaped = true
+			d.pos += 2
+		case c < 0x20:
+			return nil, false, errDecodeFallback
+		default:
+			d.pos++
+		}
+	}
+	return nil, false, errDecodeFallback
+}
+
+// str converts raw string contents, interning keys and short values.
+func (d *decoder) str(raw []byte, escaped, key bool) (string, error) {
+	if escaped {
+		return unescape(raw)
+	}
+	if !utf8.Valid(raw) {
+		// encoding/json replaces invalid bytes
+		return "", errDecodeFallback
+	}
+	if !key && len(raw) > internMaxLen {
+		return string(raw), nil
+	}
+	if s, ok := d.intern[string(raw)]; ok {
+		return s, nil
+	}
+	if len(d.intern) >= internLimit {
+		clear(d.intern)
+	}
+	s := string(raw)
+	d.intern[s] = s
+	return s, nil
+}
+
+// unescape decodes string contents holding escapes.
+func unescape(raw []byte) (string, error) {
+	out := make([]byte, 0, len(raw))
+	for i := 0; i < len(raw); {
+		c := raw[i]
+		if c != '\\' {
+			out = append(out, c)
+			i++
+			continue
+		}
+		if i+1 >= len(raw) {
+			return "", errDecodeFallback
+		}
+		switch raw[i+1] {
+		case '"', '\\', '/':
+			out = append(out, raw[i+1])
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'u':
+			r, ok := hexRune(raw[i+2:])
+			if !ok {
+				return "", errDecodeFallback
+			}
+			i += 6
+			if utf16.IsSurrogate(r) {
+				// Unpaired surrogates become U+FFFD in encoding/json
+				if len(raw) < i+6 || raw[i] != '\\' || raw[i+1] != 'u' {
+					return "", errDecodeFallback
+				}
+				low, ok := hexRune(raw[i+2:])
+				if r = utf16.DecodeRune(r, low); !ok || r == utf8.RuneError {
+					return "", errDecodeFallback
+				}
+				i += 6
+			}
+			out = utf8.AppendRune(out, r)
+			continue
+		default:
+			return "", errDecodeFallback
+		}
+		i += 2
+	}
+	if !utf8.Valid(out) {
+		return "", errDecodeFallback
+	}
+	return string(out), nil
+}
+
+// hexRune decodes the four hex digits at the start of b.
+func hexRune(b []byte) (rune, bool) {
+	if len(b) < 4 {
+		return 0, false
+	}
+	var r rune
+	for _, c := range b[:4] {
+		switch {
+		case c >= '0' && c <= '9':
+			c -= '0'
+		case c >= 'a' && c <= 'f':
+			c = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return 0, false
+		}
+		r = r<<4 | rune(c)
+	}
+	return r, true
+}
+
+func (d *decoder) literal(name string) error {
+	if len(d.data)-d.pos < len(name) || string(d.data[d.pos:d.pos+len(name)]) != name {
+		return errDecodeFallback
+	}
+	d.pos += len(name)
+	return nil
+}
+
+// number decodes a number as a float64, as encoding/json does.
+func (d *decoder) number() (any, error) {
+	start := d.pos
+	if d.peek() == '-' {
+		d.pos++
+	}
+	switch c := d.peek(); {
+	case c == '0':
+		d.pos++
+	case c >= '1' && c <= '9':
+		d.digits()
+	default:
+		return nil, errDecodeFallback
+	}
+	integer := true
+	if d.peek() == '.' {
+		integer = false
+		d.pos++
+		if !d.digits() {
+			return nil, errDecodeFallback
+		}
+	}
+	if c := d.peek(); c == 'e' || c == 'E' {
+		integer = false
+		d.pos++
+		if c := d.peek(); c == '+' || c == '-' {
+			d.pos++
+		}
+		if !d.digits() {
+			return nil, errDecodeFallback
+		}
+	}
+
+	raw := d.data[start:d.pos]
+	if integer && raw[0] != '-' && len(raw) <= 4 {
+		n := 0
+		for _, c := range raw {
+			n = n*10 + int(c-'0')
+		}
+		if n < len(smallInts) {
+			return smallInts[n], nil
+		}
+	}
+	f, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil {
+		return nil, errDecodeFallback
+	}
+	return f, nil
+}
+
+// digits skips a run of digits, reporting whether there was one.
+func (d *decoder) digits() bool {
+	start := d.pos
+	for c := d.peek(); c >= '0' && c <= '9'; c = d.peek() {
+		d.pos++
+	}
+	return d.pos > start
+}
@@ -0,0 +1,120 @@
+package transport
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+// streamEventLine is a typical message of partial streaming.
+var streamEventLine = []byte(`{"type":"stream_event","uuid":"7f1c2e0a-5b1d-4c3e-9a8f-2d6b0e4f1a3c",` +
+	`"session_id":"0b6a3f52-8e1d-4f7a-a2c9-5d3e8b1f6c04","parent_tool_use_id":null,` +
+	`"event":{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Hello, world"}}}`)
+
+func TestDecoder_MatchesEncodingJSON(t *testing.T) {
+	inputs := []string{
+		string(streamEventLine),
+		`{}`,
+		`{"a":[],"b":{},"c":[1,2.5,-3,1e3,-0,0.1,1023,1024,123456789012],"d":[true,false,null]}`,
+		`{"text":"quote \" slash \/ backslash \\ tab \t newline \n \u00e9 \ud83d\ude00 \u2028"}`,
+		`{"dup":1,"dup":2}`,
+		"  {\"spaced\" : [ 1 , { \"x\" : \"y\" } ] }  ",
+		`{"long":"abcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyz"}`,
+		`{"unicode":"héllo wörld ✓"}`,
+	}
+	d := newDecoder()
+	for _, input := range inputs {
+		want, err := decodeJSON([]byte(input))
+		if err != nil {
+			t.Fatalf("encoding/json rejected %s: %v", input, err)
+		}
+		got, err := d.decodeObject([]byte(input))
+		if err != nil {
+			t.Errorf("Decoder rejected %s: %v", input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Decoder differs for %s:\ngot  %#v\nwant %#v", input, got, want)
+		}
+	}
+}
+
+func TestDecoder_FallsBack(t *testing.T) {
+	// Input the decoder leaves to encoding/json: the result, or the error,
+	// must be that of encoding/json
+	inputs := []string{
+		`{"a":1`,
+		`{"a":1}}`,
+		`{"a":01}`,
+		`{"a":1.}`,
+		`{"a":tru}`,
+		`{"a":"\x"}`,
+		`{"a":"\ud83d"}`,
+		"{\"a\":\"\xff\"}",
+		"{\"a\":\"line\nbreak\"}",
+		`{a:1}`,
+		`[1,2]`,
+		`null`,
+		`{"a":1e999}`,
+	}
+	d := newDecoder()
+	for _, input := range inputs {
+		if _, err := d.decodeObject([]byte(input)); err == nil {
+			t.Errorf("Expected the decoder to leave %q to encoding/json", input)
+		}
+		want, wantErr := decodeJSON([]byte(input))
+		got, err := d.decode([]byte(input))
+		if (err == nil) != (wantErr == nil) || !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %q to decode as with encoding/json: got %v, %v, want %v, %v", input, got, err, want, wantErr)
+		}
+	}
+}
+
+func TestDecoder_Interning(t *testing.T) {
+	d := newDecoder()
+	first, _ := d.decodeObject(streamEventLine)
+	second, _ := d.decodeObject(streamEventLine)
+	a, b := first["session_id"].(string), second["session_id"].(string)
+	if unsafe.StringData(a) != unsafe.StringData(b) {
+		t.Error("Expected session IDs to be interned")
+	}
+
+	// The table is bounded
+	for i := range internLimit * 2 {
+		_, _ = d.decodeObject(fmt.Appendf(nil, `{"v":"%04d"}`, i))
+	}
+	if len(d.intern) > internLimit {
+		t.Errorf("Expected at most %d interned strings, got %d", internLimit, len(d.intern))
+	}
+}
+
+func TestDecoder_Allocations(t *testing.T) {
+	baseline := testing.AllocsPerRun(100, func() {
+		_, _ = decodeJSON(streamEventLine)
+	})
+	d := newDecoder()
+	lowAlloc := testing.AllocsPerRun(100, func() {
+		_, _ = d.decode(streamEventLine)
+	})
+	t.Logf("allocations per stream event: encoding/json %.0f, decoder %.0f", baseline, lowAlloc)
+	if lowAlloc > baseline/2 {
+		t.Errorf("Expected at most half the allocations of encoding/json (%.0f), got %.0f", baseline, lowAlloc)
+	}
+}
+
+func BenchmarkDecodeStreamEvent(b *testing.B) {
+	b.Run("encoding/json", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			_, _ = decodeJSON(streamEventLine)
+		}
+	})
+	b.Run("decoder", func(b *testing.B) {
+		d := newDecoder()
+		b.ReportAllocs()
+		for b.Loop() {
+			_, _ = d.decode(streamEventLine)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// StartDetached starts a non-streaming CLI process that outlives the
+// caller. The process runs in its own session, with stdout and stderr
+// written to the given files. It is not tied to any context; use
+// ProcessAlive to check on it.
+//
+// If the prompt, or the environment of a remote CLI, must be sent over
+// stdin, it is written to promptPath and the file is used as the process's
+// stdin.
+func (t *SubprocessTransport) StartDetached(ctx context.Context, stdout, stderr *os.File, promptPath string) (int, error) {
+	if t.isStreaming {
+		return 0, fmt.Errorf("detached mode requires a non-streaming transport")
+	}
+
+	if err := t.checkClaudeVersion(ctx); err != nil {
+		return 0, err
+	}
+
+	args, err := t.buildCommand()
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = t.buildEnv()
+	cmd.Dir = t.localDir()
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.SysProcAttr = detachedSysProcAttr()
+
+	if t.stdinPrompt || t.sshEnv != "" {
+		input := t.sshEnv
+		if t.stdinPrompt {
+			input += t.prompt
+		}
+		if err := os.WriteFile(promptPath, []byte(input), 0o600); err != nil {
+			return 0, fmt.Errorf("failed to write prompt file: %w", err)
+		}
+		stdin, err := os.Open(promptPath)
+		if err != nil {
+			return 0, fmt.Errorf("failed to open prompt file: %w", err)
+		}
+		defer func() { _ = stdin.Close() }()
+		cmd.Stdin = stdin
+	}
+
+	if err := cmd.Start(); err != nil {
+		if os.IsNotExist(err) {
+			return 0, fmt.Errorf("claude code not found at: %s", args[0])
+		}
+		return 0, fmt.Errorf("failed to start claude code: %w", err)
+	}
+	if t.sshEnv != "" {
+		// The process has the file open: do not leave the environment on
+		// disk
+		_ = os.Remove(promptPath)
+	}
+
+	// Reap the process if it exits while this process is still running.
+	go func() { _ = cmd.Wait() }()
+
+	return cmd.Process.Pid, nil
+}
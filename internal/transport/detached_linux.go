@@ -0,0 +1,33 @@
+package transport
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ProcessIdentity returns an opaque token identifying the process instance
+// with the given PID, or "" if it cannot be determined. Unlike the PID, the
+// token changes when the PID is reused or the machine reboots.
+func ProcessIdentity(pid int) string {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return ""
+	}
+	bootID, err := os.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return ""
+	}
+
+	// The command name may contain spaces, so fields are counted from the
+	// closing parenthesis. The start time is field 22 of the stat line.
+	end := strings.LastIndexByte(string(stat), ')')
+	if end < 0 {
+		return ""
+	}
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) < 20 {
+		return ""
+	}
+	return strings.TrimSpace(string(bootID)) + ":" + fields[19]
+}
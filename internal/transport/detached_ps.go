@@ -0,0 +1,20 @@
+//go:build !linux && !windows
+
+package transport
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ProcessIdentity returns an opaque token identifying the process instance
+// with the given PID, or "" if it cannot be determined. Unlike the PID, the
+// token changes when the PID is reused.
+func ProcessIdentity(pid int) string {
+	out, err := exec.Command("ps", "-o", "lstart=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
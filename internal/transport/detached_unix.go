@@ -0,0 +1,23 @@
+//go:build !windows
+
+package transport
+
+import (
+	"errors"
+	"syscall"
+)
+
+// detachedSysProcAttr starts the process in a new session so it survives
+// the parent exiting and does not receive the terminal's signals.
+func detachedSysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// ProcessAlive reports whether a process with the given PID is running.
+func ProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
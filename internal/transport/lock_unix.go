@@ -0,0 +1,26 @@
+//go:build !windows
+
+package transport
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// TryLockFile takes an exclusive advisory lock on f without waiting. It
+// reports false if the lock is held through another open file, in this
+// process or another. The lock is released by UnlockFile or when f is
+// closed, including when the process exits.
+func TryLockFile(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// UnlockFile releases the lock taken on f by TryLockFile.
+func UnlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
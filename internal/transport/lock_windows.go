@@ -0,0 +1,52 @@
+//go:build windows
+
+package transport
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	lockfileFailImmediately = 0x00000001
+	lockfileExclusiveLock   = 0x00000002
+	errorLockViolation      = syscall.Errno(33)
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+// lockRegion returns the byte range locked by TryLockFile. It lies past
+// the content of the file, which the lock would otherwise keep other
+// processes from reading.
+func lockRegion() *syscall.Overlapped {
+	return &syscall.Overlapped{OffsetHigh: 0x7fffffff}
+}
+
+// TryLockFile takes an exclusive advisory lock on f without waiting. It
+// reports false if the lock is held through another open file, in this
+// process or another. The lock is released by UnlockFile or when f is
+// closed, including when the process exits.
+func TryLockFile(f *os.File) (bool, error) {
+	r, _, err := procLockFileEx.Call(f.Fd(), lockfileExclusiveLock|lockfileFailImmediately, 0, 1, 0, uintptr(unsafe.Pointer(lockRegion())))
+	if r != 0 {
+		return true, nil
+	}
+	if err == errorLockViolation {
+		return false, nil
+	}
+	return false, err
+}
+
+// UnlockFile releases the lock taken on f by TryLockFile.
+func UnlockFile(f *os.File) error {
+	r, _, err := procUnlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(lockRegion())))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
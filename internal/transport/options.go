@@ -11,6 +11,8 @@ type Options struct {
 	PermissionMode           string
 	ContinueConversation     bool
 	Resume                   string
+	ResumeSessionAt          string
+	SessionID                string
 	MaxTurns                 int
 	MaxBudgetUSD             *float64
 	DisallowedTools          []string
@@ -25,6 +27,9 @@ type Options struct {
 	Env                      map[string]string
 	ExtraArgs                map[string]*string
 	MaxBufferSize            int
+	MaxStderrLineSize        int
+	MaxControlResponseSize   int
+	LowAllocParsing          bool
 	DebugStderr              io.Writer
 	Stderr                   func(line string)
 	User                     string
@@ -37,6 +42,44 @@ type Options struct {
 	MaxThinkingTokens        int
 	OutputFormat             map[string]any
 	EnableFileCheckpointing  bool
+	Temperature              *float64
+	TopP                     *float64
+	Seed                     *int64
+	Container                *ContainerOptions
+	SSH                      *SSHOptions
+	Proxy                    *ProxyOptions
+	JSONOutput               bool // single JSON result instead of stream-json
+	TempDir                  string
+	WireLog                  io.Writer
+	WireLogRedact            []string
+	PipeDir                  string // named pipes for a streaming transport
+}
+
+// ContainerOptions runs the CLI inside a container.
+type ContainerOptions struct {
+	Runtime string
+	Image   string
+	Mounts  []string
+	Env     map[string]string
+}
+
+// SSHOptions runs the CLI on a remote host over SSH.
+type SSHOptions struct {
+	Path         string
+	Host         string
+	Port         int
+	User         string
+	IdentityFile string
+	Options      []string
+	Env          map[string]string
+}
+
+// ProxyOptions routes the CLI's network traffic through a proxy and
+// trusts additional certificate authorities.
+type ProxyOptions struct {
+	HTTPProxy string
+	NoProxy   []string
+	CABundle  string
 }
 
 // AgentDefinition defines a custom agent.
@@ -0,0 +1,176 @@
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Names of the files in the directory of a transport over named pipes.
+const (
+	pipeStdin     = "stdin"
+	pipeStdout    = "stdout"
+	pipeStderr    = "stderr"
+	pipeStateFile = "process.json"
+)
+
+// pipeBufferSize is the size the buffers of named pipes are grown to where
+// the system allows it, so that the CLI can write a large message without
+// waiting for it to be read.
+const pipeBufferSize = 1024 * 1024
+
+// pipePollInterval is how often a reattached process, which cannot be
+// waited for, is checked for having exited.
+const pipePollInterval = 100 * time.Millisecond
+
+// pipeState is what is kept of a CLI process that talks over named pipes,
+// so that a transport in another process can reattach to it: the process,
+// the process of the transport attached to it, if any, and the response
+// of the CLI to initialize.
+type pipeState struct {
+	PID           int            `json:"pid"`
+	Identity      string         `json:"identity,omitempty"`
+	Owner         int            `json:"owner,omitempty"`
+	OwnerIdentity string         `json:"owner_identity,omitempty"`
+	Init          map[string]any `json:"init,omitempty"`
+}
+
+func loadPipeState(dir string) (*pipeState, error) {
+	data, err := os.ReadFile(filepath.Join(dir, pipeStateFile))
+	if err != nil {
+		return nil, err
+	}
+	var state pipeState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// save writes the state to dir, replacing the previous state at once.
+func (s *pipeState) save(dir string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, pipeStateFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write pipe state: %w", err)
+	}
+	return os.Rename(tmp, filepath.Join(dir, pipeStateFile))
+}
+
+// running reports whether the process of the state is still running.
+func (s *pipeState) running() bool {
+	return ProcessAlive(s.PID) && ProcessIdentity(s.PID) == s.Identity
+}
+
+// owned reports whether a transport is attached to the process of the
+// state, from a process that is still running.
+func (s *pipeState) owned() bool {
+	return s.Owner > 0 && ProcessAlive(s.Owner) && ProcessIdentity(s.Owner) == s.OwnerIdentity
+}
+
+// own records this process as the one attached.
+func (s *pipeState) own() {
+	s.Owner = os.Getpid()
+	s.OwnerIdentity = ProcessIdentity(s.Owner)
+}
+
+// removePipes removes the named pipes and the state from dir.
+func removePipes(dir string) {
+	for _, name := range []string{pipeStdin, pipeStdout, pipeStderr, pipeStateFile} {
+		_ = os.Remove(filepath.Join(dir, name))
+	}
+}
+
+// waitExit waits for the process with the given PID, which is not a child
+// of this process, to exit.
+func waitExit(pid int) {
+	for ProcessAlive(pid) {
+		time.Sleep(pipePollInterval)
+	}
+}
+
+// Reattached reports whether the transport reattached to a CLI process
+// left running over named pipes, rather than starting one. The CLI has
+// then already been initialized; SavedInit returns its response.
+func (t *SubprocessTransport) Reattached() bool {
+	return t.reattached
+}
+
+// SavedInit returns the response of the CLI to initialize saved with
+// SaveInit, or nil if there is none.
+func (t *SubprocessTransport) SavedInit() map[string]any {
+	if t.pipes == nil {
+		return nil
+	}
+	return t.pipes.Init
+}
+
+// SaveInit keeps the response of the CLI to initialize with the named
+// pipes, for the transports that reattach to the process. It does nothing
+// without named pipes.
+func (t *SubprocessTransport) SaveInit(init map[string]any) error {
+	if t.pipes == nil {
+		return nil
+	}
+	t.pipes.Init = init
+	return t.pipes.save(t.options.PipeDir)
+}
+
+// Detached returns a channel closed on Detach, or nil if the transport has
+// not connected.
+func (t *SubprocessTransport) Detached() <-chan struct{} {
+	return t.detached
+}
+
+// Detach closes the transport like Close, but leaves a CLI process that
+// talks over named pipes running, so that a transport with the same
+// PipeDir, in this process or another, can reattach to it. Without named
+// pipes, it is Close.
+func (t *SubprocessTransport) Detach() error {
+	if t.pipes == nil {
+		return t.Close()
+	}
+
+	t.closeMu.Lock()
+	defer t.closeMu.Unlock()
+
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+
+	for _, f := range t.tempFiles {
+		_ = os.Remove(f)
+	}
+	t.tempFiles = nil
+
+	var errs []error
+	t.writeMu.Lock()
+	t.ready = false
+	for _, c := range []io.Closer{t.stdin, t.stdout, t.stderr} {
+		if c != nil {
+			errs = append(errs, c.Close())
+		}
+	}
+	t.stdin = nil
+	process := t.process
+	t.process = nil
+	t.writeMu.Unlock()
+	t.stdout, t.stderr = nil, nil
+
+	if !t.reattached {
+		// Reap the process once it exits, while this process runs
+		go t.wait(process)
+	}
+	t.pipes.Owner, t.pipes.OwnerIdentity = 0, ""
+	errs = append(errs, t.pipes.save(t.options.PipeDir))
+	close(t.detached)
+	return errors.Join(errs...)
+}
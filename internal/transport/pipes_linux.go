@@ -0,0 +1,21 @@
+package transport
+
+import (
+	"os"
+	"syscall"
+)
+
+// fSetPipeSize is F_SETPIPE_SZ, which sets the buffer size of a pipe.
+const fSetPipeSize = 1031
+
+// setPipeSize grows the buffer of the pipe f to size bytes, if the system
+// allows it.
+func setPipeSize(f *os.File, size int) {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return
+	}
+	_ = conn.Control(func(fd uintptr) {
+		_, _, _ = syscall.Syscall(syscall.SYS_FCNTL, fd, fSetPipeSize, uintptr(size))
+	})
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !windows
+
+package transport
+
+import "os"
+
+// setPipeSize does nothing: the buffer size of pipes is fixed.
+func setPipeSize(f *os.File, size int) {}
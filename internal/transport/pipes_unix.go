@@ -0,0 +1,130 @@
+//go:build !windows
+
+package transport
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+// connectPipes connects to the CLI over named pipes in PipeDir: it
+// reattaches to the process left running there, or starts one that runs
+// in its own session, so that it outlives this process.
+func (t *SubprocessTransport) connectPipes(ctx context.Context) error {
+	dir := t.options.PipeDir
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create pipe directory: %w", err)
+	}
+
+	if state, err := loadPipeState(dir); err == nil && state.running() {
+		if state.owned() {
+			return fmt.Errorf("named pipes in %s are in use by process %d", dir, state.Owner)
+		}
+		process, err := os.FindProcess(state.PID)
+		if err == nil {
+			err = t.openPipes(dir)
+		}
+		if err == nil {
+			t.process = &exec.Cmd{Process: process}
+			t.pid = state.PID
+			t.pipes = state
+			t.reattached = true
+			state.own()
+			return state.save(dir)
+		}
+		// The process exited in the meantime: start another
+	}
+	return t.startPiped(ctx, dir)
+}
+
+// startPiped starts the CLI with named pipes in dir for its stdin, stdout
+// and stderr.
+func (t *SubprocessTransport) startPiped(ctx context.Context, dir string) error {
+	if err := t.checkClaudeVersion(ctx); err != nil {
+		return err
+	}
+
+	args, err := t.buildCommand()
+	if err != nil {
+		return err
+	}
+
+	removePipes(dir)
+	// The process holds both ends of each pipe, so that it sees neither the
+	// end of its input nor a broken pipe while no transport is attached;
+	// it waits for one instead.
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	for _, name := range []string{pipeStdin, pipeStdout, pipeStderr} {
+		path := filepath.Join(dir, name)
+		if err := syscall.Mkfifo(path, 0o600); err != nil {
+			return fmt.Errorf("failed to create named pipe: %w", err)
+		}
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			return fmt.Errorf("failed to open named pipe: %w", err)
+		}
+		setPipeSize(f, pipeBufferSize)
+		files = append(files, f)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = t.buildEnv()
+	cmd.Dir = t.localDir()
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = files[0], files[1], files[2]
+	cmd.SysProcAttr = detachedSysProcAttr()
+
+	if err := cmd.Start(); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("claude code not found at: %s", args[0])
+		}
+		return fmt.Errorf("failed to start claude code: %w", err)
+	}
+
+	pid := cmd.Process.Pid
+	state := &pipeState{PID: pid, Identity: ProcessIdentity(pid)}
+	state.own()
+	if err := t.openPipes(dir); err == nil {
+		err = state.save(dir)
+	}
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return err
+	}
+
+	t.process = cmd
+	t.pid = pid
+	t.pipes = state
+	return nil
+}
+
+// openPipes opens this end of the named pipes in dir. The process must
+// hold the other ends: opening fails rather than waits for it.
+func (t *SubprocessTransport) openPipes(dir string) error {
+	stdin, err := os.OpenFile(filepath.Join(dir, pipeStdin), os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open named pipe: %w", err)
+	}
+	stdout, err := os.OpenFile(filepath.Join(dir, pipeStdout), os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		_ = stdin.Close()
+		return fmt.Errorf("failed to open named pipe: %w", err)
+	}
+	stderr, err := os.OpenFile(filepath.Join(dir, pipeStderr), os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		_ = stdin.Close()
+		_ = stdout.Close()
+		return fmt.Errorf("failed to open named pipe: %w", err)
+	}
+	t.stdin, t.stdout, t.stderr = stdin, stdout, stderr
+	return nil
+}
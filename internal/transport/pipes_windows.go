@@ -0,0 +1,13 @@
+//go:build windows
+
+package transport
+
+import (
+	"context"
+	"fmt"
+)
+
+// connectPipes fails: named pipes are not supported on Windows.
+func (t *SubprocessTransport) connectPipes(ctx context.Context) error {
+	return fmt.Errorf("named pipes are not supported on Windows")
+}
@@ -0,0 +1,153 @@
+package transport
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// redacted replaces the secrets in a previewed command.
+const redacted = "[REDACTED]"
+
+// secretWords are the name endings that mark a flag, variable or JSON key
+// as holding a secret.
+var secretWords = []string{"key", "token", "secret", "password", "passwd", "auth", "authorization", "credential", "credentials", "cookie"}
+
+// PreviewCommand returns the command Connect would run, without running
+// it, with secrets redacted: values of flags and JSON keys named like
+// secrets, the env and headers of MCP servers and settings, and
+// passwords in URLs. Temp files written for the command are removed, so
+// the paths it names no longer exist.
+func (t *SubprocessTransport) PreviewCommand() ([]string, error) {
+	defer func() {
+		for _, f := range t.tempFiles {
+			_ = os.Remove(f)
+		}
+		t.tempFiles = nil
+	}()
+
+	cmd, err := t.buildCommand()
+	if err != nil {
+		return nil, err
+	}
+	return redactCommand(cmd), nil
+}
+
+// redactCommand returns cmd with the values of secret flags and the
+// secrets in JSON arguments redacted.
+func redactCommand(cmd []string) []string {
+	out := make([]string, len(cmd))
+	for i, arg := range cmd {
+		out[i] = arg
+		if name, _, ok := strings.Cut(arg, "="); ok && strings.HasPrefix(name, "--") && isSecretName(name) {
+			out[i] = name + "=" + redacted
+			continue
+		}
+		if i == 0 || !strings.HasPrefix(cmd[i-1], "--") || strings.HasPrefix(arg, "--") {
+			continue
+		}
+		switch flag := cmd[i-1]; {
+		case flag == "--mcp-config" || flag == "--settings":
+			out[i] = redactJSON(arg)
+		case isSecretName(flag):
+			out[i] = redacted
+		}
+	}
+	return out
+}
+
+// redactJSON returns s with its secrets redacted if it is a JSON object,
+// and s otherwise.
+func redactJSON(s string) string {
+	var v any
+	if !strings.HasPrefix(strings.TrimSpace(s), "{") || json.Unmarshal([]byte(s), &v) != nil {
+		return s
+	}
+	data, err := json.Marshal(redactValue(v, false))
+	if err != nil {
+		return s
+	}
+	return string(data)
+}
+
+// RedactJSON returns s with the secrets of a previewed command redacted if
+// it is a JSON object, and s otherwise.
+func RedactJSON(s string) string {
+	return redactJSON(s)
+}
+
+// RedactValue returns v, a value decoded from JSON, with the secrets of a
+// previewed command redacted: values of keys named like secrets, env and
+// headers, and passwords in URLs. Maps and slices are redacted in place.
+func RedactValue(v any) any {
+	return redactValue(v, false)
+}
+
+// redactValue returns v with its secrets redacted, or with all its
+// strings redacted if all is set.
+func redactValue(v any, all bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, elem := range v {
+			v[k] = redactValue(elem, all || k == "env" || k == "headers" || isSecretName(k))
+		}
+		return v
+	case []any:
+		for i, elem := range v {
+			v[i] = redactValue(elem, all)
+		}
+		return v
+	case string:
+		if all {
+			return redacted
+		}
+		return redactURL(v)
+	}
+	return v
+}
+
+// redactURL returns s with its password redacted if it is a URL with one.
+func redactURL(s string) string {
+	if !strings.Contains(s, "://") {
+		return s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, ok := u.User.Password(); !ok {
+		return s
+	}
+	return u.Redacted()
+}
+
+// isSecretName reports whether a word of name, split at punctuation and
+// case changes, ends like one of secretWords.
+func isSecretName(name string) bool {
+	var words []string
+	start := 0
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			words = append(words, string(runes[start:i]))
+			start = i + 1
+		case i > start && unicode.IsUpper(r) && unicode.IsLower(runes[i-1]):
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	words = append(words, string(runes[start:]))
+
+	for _, word := range words {
+		word = strings.ToLower(word)
+		for _, secret := range secretWords {
+			if strings.HasSuffix(word, secret) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package transport
+
+import "strings"
+
+// proxyEnv returns the variables routing the CLI, and the commands it
+// runs, through the configured proxy. Both spellings of the proxy
+// variables are set, since tools differ in which they read.
+func (t *SubprocessTransport) proxyEnv() map[string]string {
+	env := make(map[string]string)
+	p := t.options.Proxy
+	if p == nil {
+		return env
+	}
+	if p.HTTPProxy != "" {
+		for _, key := range []string{"HTTPS_PROXY", "HTTP_PROXY", "https_proxy", "http_proxy"} {
+			env[key] = p.HTTPProxy
+		}
+	}
+	if len(p.NoProxy) > 0 {
+		noProxy := strings.Join(p.NoProxy, ",")
+		env["NO_PROXY"] = noProxy
+		env["no_proxy"] = noProxy
+	}
+	if p.CABundle != "" {
+		env["NODE_EXTRA_CA_CERTS"] = p.CABundle
+	}
+	return env
+}
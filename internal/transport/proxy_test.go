@@ -0,0 +1,45 @@
+package transport
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSubprocessTransport_ProxyEnv(t *testing.T) {
+	transport := &SubprocessTransport{options: &Options{
+		Env: map[string]string{"NO_PROXY": "override"},
+		Proxy: &ProxyOptions{
+			HTTPProxy: "http://proxy.corp:3128",
+			NoProxy:   []string{"localhost", ".corp"},
+			CABundle:  "/etc/ssl/corp.pem",
+		},
+	}}
+
+	env := transport.buildEnv()
+	for _, want := range []string{
+		"HTTPS_PROXY=http://proxy.corp:3128",
+		"http_proxy=http://proxy.corp:3128",
+		"no_proxy=localhost,.corp",
+		"NODE_EXTRA_CA_CERTS=/etc/ssl/corp.pem",
+	} {
+		if !slices.Contains(env, want) {
+			t.Errorf("Expected %s in the environment", want)
+		}
+	}
+	// Later entries win, so Options.Env overrides the proxy settings
+	if slices.Index(env, "NO_PROXY=override") < slices.Index(env, "NO_PROXY=localhost,.corp") {
+		t.Error("Expected Options.Env to override NO_PROXY")
+	}
+
+	cliEnv := transport.cliEnv()
+	if cliEnv["HTTPS_PROXY"] != "http://proxy.corp:3128" || cliEnv["NO_PROXY"] != "override" {
+		t.Errorf("Expected proxy settings to reach a remote CLI, got %v", cliEnv)
+	}
+}
+
+func TestSubprocessTransport_NoProxyEnv(t *testing.T) {
+	transport := &SubprocessTransport{options: &Options{}}
+	if env := transport.proxyEnv(); len(env) != 0 {
+		t.Errorf("Expected no proxy variables, got %v", env)
+	}
+}
@@ -0,0 +1,96 @@
+package transport
+
+import (
+	"fmt"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// findSSHClient returns the path of the ssh client to use.
+func findSSHClient(path string) (string, error) {
+	if path == "" {
+		path = "ssh"
+	}
+	found, err := exec.LookPath(path)
+	if err != nil {
+		return "", fmt.Errorf("ssh client not found: %s", path)
+	}
+	return found, nil
+}
+
+// sshEnvCommand is the remote command that reads the script setting the
+// environment of the CLI, of the given length, from stdin and then runs its
+// arguments. dd reads a byte at a time so as to leave the rest of stdin to
+// the CLI.
+const sshEnvCommand = `eval "$(dd bs=1 count=%d 2>/dev/null)" && exec "$@"`
+
+// sshCommand wraps the CLI command cmd in an ssh invocation that runs it
+// on the remote host, in the working directory if one is set. The
+// variables of cliEnv and SSHOptions.Env are sent as a script ahead of the
+// CLI's input on stdin, as sshd passes no others through by default, so
+// that their values, such as API keys, are not on the command line of
+// either host for other users to see.
+//
+// BatchMode is set so that a missing key or unknown host fails instead of
+// prompting on the terminal of the local program.
+func (t *SubprocessTransport) sshCommand(cmd []string) ([]string, error) {
+	s := t.options.SSH
+	if s.Host == "" {
+		return nil, fmt.Errorf("ssh host is required")
+	}
+	if len(t.tempFiles) > 0 {
+		return nil, fmt.Errorf("agent definitions are too large to pass to a remote CLI")
+	}
+
+	args := []string{t.launcherPath, "-T", "-o", "BatchMode=yes"}
+	if s.Port > 0 {
+		args = append(args, "-p", strconv.Itoa(s.Port))
+	}
+	if s.User != "" {
+		args = append(args, "-l", s.User)
+	}
+	if s.IdentityFile != "" {
+		args = append(args, "-i", s.IdentityFile)
+	}
+	for _, option := range s.Options {
+		args = append(args, "-o", option)
+	}
+
+	env := t.cliEnv()
+	for k, v := range s.Env {
+		env[k] = v
+	}
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var script strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&script, "export %s\n", shellQuote(name+"="+env[name]))
+	}
+	t.sshEnv = script.String()
+
+	var remote []string
+	if t.cwd != "" {
+		remote = append(remote, "cd", shellQuote(t.cwd), "&&")
+	}
+	remote = append(remote, "exec", "sh", "-c", shellQuote(fmt.Sprintf(sshEnvCommand, len(t.sshEnv))), "sh")
+	for _, arg := range cmd {
+		remote = append(remote, shellQuote(arg))
+	}
+
+	return append(args, "--", s.Host, strings.Join(remote, " ")), nil
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:,+@%", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,93 @@
+package transport
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestSubprocessTransport_BuildCommand_SSH(t *testing.T) {
+	transport := &SubprocessTransport{
+		cliPath:      "/opt/claude/bin/claude",
+		launcherPath: "/usr/bin/ssh",
+		cwd:          "/home/dev/my repo",
+		prompt:       "What's new?",
+		options: &Options{
+			Env: map[string]string{"DEBUG": "1"},
+			SSH: &SSHOptions{
+				Host:         "devbox",
+				Port:         2222,
+				User:         "dev",
+				IdentityFile: "/keys/id_ed25519",
+				Options:      []string{"StrictHostKeyChecking=yes"},
+				Env:          map[string]string{"ANTHROPIC_API_KEY": "sk-1"},
+			},
+		},
+	}
+
+	cmd, err := transport.buildCommand()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "/usr/bin/ssh -T -o BatchMode=yes -p 2222 -l dev -i /keys/id_ed25519 -o StrictHostKeyChecking=yes -- devbox"
+	if got := strings.Join(cmd[:len(cmd)-1], " "); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	remote := cmd[len(cmd)-1]
+	env := "export ANTHROPIC_API_KEY=sk-1\nexport CLAUDE_AGENT_SDK_VERSION=" + sdkVersion +
+		"\nexport CLAUDE_CODE_ENTRYPOINT=sdk-go\nexport DEBUG=1\n"
+	if transport.sshEnv != env {
+		t.Errorf("Expected env script %q, got %q", env, transport.sshEnv)
+	}
+	prefix := fmt.Sprintf(`cd '/home/dev/my repo' && exec sh -c 'eval "$(dd bs=1 count=%d 2>/dev/null)" && exec "$@"' sh `, len(env)) +
+		"/opt/claude/bin/claude --output-format stream-json"
+	if !strings.HasPrefix(remote, prefix) {
+		t.Errorf("Expected remote command to start with %q, got %q", prefix, remote)
+	}
+	if strings.Contains(remote, "sk-1") {
+		t.Errorf("Expected the env values to stay off the command line, got %q", remote)
+	}
+	if !strings.HasSuffix(remote, ` -- 'What'\''s new?'`) {
+		t.Errorf("Expected the quoted prompt last, got %q", remote)
+	}
+	if transport.localDir() != "" {
+		t.Error("Expected the process to start in the current directory")
+	}
+}
+
+func TestSubprocessTransport_BuildCommand_SSHNoHost(t *testing.T) {
+	transport := &SubprocessTransport{
+		cliPath:      "claude",
+		launcherPath: "ssh",
+		isStreaming:  true,
+		options:      &Options{SSH: &SSHOptions{}},
+	}
+	if _, err := transport.buildCommand(); err == nil {
+		t.Error("Expected error without a host")
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"plain":       "plain",
+		"a/b.c=d":     "a/b.c=d",
+		"":            "''",
+		"two words":   "'two words'",
+		"it's":        `'it'\''s'`,
+		`{"a":"$x"}`:  `'{"a":"$x"}'`,
+		"line\nbreak": "'line\nbreak'",
+	}
+	for in, expected := range tests {
+		if got := shellQuote(in); got != expected {
+			t.Errorf("shellQuote(%q) = %q, expected %q", in, got, expected)
+		}
+		// The shell must read the quoted string back as the original
+		out, err := exec.Command("sh", "-c", "printf %s "+shellQuote(in)).Output()
+		if err == nil && string(out) != in {
+			t.Errorf("Shell read %q back as %q", in, out)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// RunSubcommand runs the CLI with args, such as "doctor", with input on
+// its stdin, and returns what it wrote to stdout and stderr. The CLI runs
+// with the environment and working directory of a session, through the
+// container runtime or SSH client if one is configured.
+func (t *SubprocessTransport) RunSubcommand(ctx context.Context, input string, args ...string) (stdout, stderr []byte, err error) {
+	cmd, err := t.launchCommand(append([]string{t.cliPath}, args...))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	process := exec.CommandContext(ctx, cmd[0], cmd[1:]...)
+	process.Env = t.buildEnv()
+	process.Dir = t.localDir()
+	process.Stdin = strings.NewReader(input)
+	var out, errOut bytes.Buffer
+	process.Stdout, process.Stderr = &out, &errOut
+
+	if err := process.Run(); err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, exec.ErrNotFound) {
+			return nil, nil, fmt.Errorf("claude code not found at: %s", cmd[0])
+		}
+		return out.Bytes(), errOut.Bytes(), err
+	}
+	return out.Bytes(), errOut.Bytes(), nil
+}
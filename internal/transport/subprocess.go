@@ -2,8 +2,10 @@ package transport
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -14,10 +16,16 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 const (
 	defaultMaxBufferSize     = 1024 * 1024 // 1MB buffer limit
+	defaultMaxStderrLineSize = 1024 * 1024
+	defaultMaxControlSize    = 10 * 1024 * 1024
+	// retainedBufferSize is the capacity of the message buffer kept for
+	// the next message; larger buffers are released.
+	retainedBufferSize       = 64 * 1024
 	minimumClaudeCodeVersion = "2.0.0"
 	sdkVersion               = "0.1.0"
 )
@@ -35,6 +43,7 @@ type SubprocessTransport struct {
 	isStreaming   bool
 	options       *Options
 	cliPath       string
+	launcherPath  string // container runtime or SSH client, if any
 	cwd           string
 	process       *exec.Cmd
 	stdin         io.WriteCloser
@@ -43,10 +52,85 @@ type SubprocessTransport struct {
 	ready         bool
 	exitError     error
 	maxBufferSize int
+	maxStderrLine int
+	maxControl    int
 	tempFiles     []string
+	stdinPrompt   bool
+	sshEnv        string // environment script sent to a remote CLI, see sshCommand
+	wire          *wireLog
 	writeMu       sync.Mutex
 	closeMu       sync.Mutex
 	closed        bool
+
+	// waitOnce guards process.Wait, which both ReadMessages and Close call.
+	// pid is the process ID once started; exited is closed once the
+	// process has been waited for, after exitCode is set.
+	waitOnce sync.Once
+	pid      int
+	exited   chan struct{}
+	exitCode int
+
+	// pipes is set when the CLI talks over named pipes, and reattached
+	// when the process was started by another transport. detached is
+	// closed on Detach.
+	pipes      *pipeState
+	reattached bool
+	detached   chan struct{}
+
+	// stderrTail keeps the last lines of stderr for ExitError, and
+	// stderrDone is closed once stderr is drained.
+	stderrMu   sync.Mutex
+	stderrTail []string
+	stderrDone chan struct{}
+	// stderrOverflows holds the stderr lines that exceeded their limit,
+	// until ReadMessages reports them.
+	stderrOverflows []error
+}
+
+// stderrTailLines is how many lines of stderr an ExitError carries.
+const stderrTailLines = 20
+
+// stderrDrainTimeout bounds the wait for stderr to be drained after stdout
+// closes, in case a child process keeps the pipe open.
+const stderrDrainTimeout = time.Second
+
+// ExitError reports that the CLI process exited with a non-zero code.
+type ExitError struct {
+	ExitCode int
+	// Stderr holds the last lines the process wrote to stderr.
+	Stderr string
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("command failed with exit code %d", e.ExitCode)
+}
+
+// BufferLimitKind identifies a size limit of the CLI's output.
+type BufferLimitKind string
+
+const (
+	BufferLimitMessage         BufferLimitKind = "message"
+	BufferLimitStderrLine      BufferLimitKind = "stderr_line"
+	BufferLimitControlResponse BufferLimitKind = "control_response"
+)
+
+// BufferLimitError reports output of the CLI that exceeded a size limit.
+// Stderr lines are truncated to their limit rather than dropped.
+type BufferLimitError struct {
+	Kind  BufferLimitKind
+	Limit int
+	Size  int
+}
+
+func (e *BufferLimitError) Error() string {
+	return fmt.Sprintf("%s exceeded maximum size of %d bytes (size: %d)", e.Kind, e.Limit, e.Size)
+}
+
+// isControlResponse reports whether the start of a message marks it as a
+// control response. Only the start is checked, so that oversized messages
+// can be classified without being parsed.
+func isControlResponse(message []byte) bool {
+	return bytes.Contains(message[:min(len(message), 64)], []byte(`"control_response"`))
 }
 
 // NewSubprocessTransport creates a new subprocess transport.
@@ -56,17 +140,44 @@ func NewSubprocessTransport(prompt string, isStreaming bool, options *Options) (
 		isStreaming:   isStreaming,
 		options:       options,
 		maxBufferSize: defaultMaxBufferSize,
+		maxStderrLine: defaultMaxStderrLineSize,
+		maxControl:    defaultMaxControlSize,
+		wire:          newWireLog(options.WireLog, options.WireLogRedact),
 	}
 
 	if options.MaxBufferSize > 0 {
 		t.maxBufferSize = options.MaxBufferSize
 	}
+	if options.MaxStderrLineSize > 0 {
+		t.maxStderrLine = options.MaxStderrLineSize
+	}
+	if options.MaxControlResponseSize > 0 {
+		t.maxControl = options.MaxControlResponseSize
+	}
 
 	if options.Cwd != "" {
 		t.cwd = options.Cwd
 	}
 
-	if options.CLIPath != "" {
+	if options.Container != nil || options.SSH != nil {
+		// The CLI runs in the container or on the remote host, where
+		// CLIPath names it
+		var path string
+		var err error
+		if options.Container != nil {
+			path, err = findContainerRuntime(options.Container.Runtime)
+		} else {
+			path, err = findSSHClient(options.SSH.Path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		t.launcherPath = path
+		t.cliPath = "claude"
+		if options.CLIPath != "" {
+			t.cliPath = options.CLIPath
+		}
+	} else if options.CLIPath != "" {
 		t.cliPath = options.CLIPath
 	} else {
 		path, err := t.findCLI()
@@ -150,6 +261,9 @@ func (t *SubprocessTransport) buildSettingsValue() (string, error) {
 
 func (t *SubprocessTransport) buildCommand() ([]string, error) {
 	cmd := []string{t.cliPath, "--output-format", "stream-json", "--verbose"}
+	if t.options.JSONOutput && !t.isStreaming {
+		cmd = []string{t.cliPath, "--output-format", "json"}
+	}
 
 	if t.options.SystemPrompt == nil {
 		cmd = append(cmd, "--system-prompt", "")
@@ -217,6 +331,14 @@ func (t *SubprocessTransport) buildCommand() ([]string, error) {
 		cmd = append(cmd, "--resume", t.options.Resume)
 	}
 
+	if t.options.ResumeSessionAt != "" {
+		cmd = append(cmd, "--resume-session-at", t.options.ResumeSessionAt)
+	}
+
+	if t.options.SessionID != "" {
+		cmd = append(cmd, "--session-id", t.options.SessionID)
+	}
+
 	settingsValue, err := t.buildSettingsValue()
 	if err != nil {
 		return nil, err
@@ -284,6 +406,18 @@ func (t *SubprocessTransport) buildCommand() ([]string, error) {
 		cmd = append(cmd, "--max-thinking-tokens", strconv.Itoa(t.options.MaxThinkingTokens))
 	}
 
+	if t.options.Temperature != nil {
+		cmd = append(cmd, "--temperature", strconv.FormatFloat(*t.options.Temperature, 'g', -1, 64))
+	}
+
+	if t.options.TopP != nil {
+		cmd = append(cmd, "--top-p", strconv.FormatFloat(*t.options.TopP, 'g', -1, 64))
+	}
+
+	if t.options.Seed != nil {
+		cmd = append(cmd, "--seed", strconv.FormatInt(*t.options.Seed, 10))
+	}
+
 	if t.options.OutputFormat != nil {
 		if t.options.OutputFormat["type"] == "json_schema" {
 			if schema, ok := t.options.OutputFormat["schema"]; ok {
@@ -299,36 +433,80 @@ func (t *SubprocessTransport) buildCommand() ([]string, error) {
 	if t.isStreaming {
 		cmd = append(cmd, "--input-format", "stream-json")
 	} else {
-		cmd = append(cmd, "--print", "--", t.prompt)
+		cmd = append(cmd, "--print")
 	}
 
-	cmdStr := strings.Join(cmd, " ")
-	if len(cmdStr) > cmdLengthLimit && len(t.options.Agents) > 0 {
+	// The prompt is counted even when it ends up on stdin, so oversized
+	// agent definitions still move to a file.
+	if commandLength(cmd)+len(t.prompt) > cmdLengthLimit && len(t.options.Agents) > 0 {
 		agentsJSON, _ := json.Marshal(t.options.Agents)
-		tempFile, err := os.CreateTemp("", "claude-agents-*.json")
+		agentsFile, err := t.createTempFile("agents", agentsJSON)
 		if err != nil {
 			return nil, err
 		}
-		if _, err := tempFile.Write(agentsJSON); err != nil {
-			_ = tempFile.Close()
-			return nil, err
-		}
-		_ = tempFile.Close()
-		t.tempFiles = append(t.tempFiles, tempFile.Name())
 
 		for i, arg := range cmd {
 			if arg == "--agents" && i+1 < len(cmd) {
-				cmd[i+1] = "@" + tempFile.Name()
+				cmd[i+1] = "@" + agentsFile
 				break
 			}
 		}
 	}
 
+	// Prompts that would exceed the OS argument limit, or that cannot be
+	// passed as an argument at all, are written to stdin instead. The CLI
+	// reads the prompt from stdin in print mode when none is given.
+	if !t.isStreaming {
+		launched, err := t.launchCommand(cmd)
+		if err != nil {
+			return nil, err
+		}
+		t.stdinPrompt = promptNeedsStdin(launched, t.prompt)
+		if !t.stdinPrompt {
+			cmd = append(cmd, "--", t.prompt)
+		}
+	}
+
+	return t.launchCommand(cmd)
+}
+
+// launchCommand wraps cmd in the command of the container runtime or SSH
+// client the CLI is started through, if any.
+func (t *SubprocessTransport) launchCommand(cmd []string) ([]string, error) {
+	switch {
+	case t.options.Container != nil:
+		return t.containerCommand(cmd)
+	case t.options.SSH != nil:
+		return t.sshCommand(cmd)
+	}
 	return cmd, nil
 }
 
+// commandLength returns the length of cmd joined by spaces.
+func commandLength(cmd []string) int {
+	n := len(cmd) - 1
+	for _, arg := range cmd {
+		n += len(arg)
+	}
+	return max(n, 0)
+}
+
+// promptNeedsStdin reports whether prompt must be sent over stdin rather
+// than appended to cmd: when the full command line would exceed
+// cmdLengthLimit, or when the prompt contains a NUL byte, which cannot
+// appear in an argument.
+func promptNeedsStdin(cmd []string, prompt string) bool {
+	// Account for the "--" separator and the spaces around it.
+	if commandLength(cmd)+len(" -- ")+len(prompt) > cmdLengthLimit {
+		return true
+	}
+	return strings.ContainsRune(prompt, 0)
+}
+
 func (t *SubprocessTransport) checkClaudeVersion(ctx context.Context) error {
-	if os.Getenv("CLAUDE_AGENT_SDK_SKIP_VERSION_CHECK") != "" {
+	// Checking the CLI of a container or remote host would mean starting
+	// one just for it
+	if os.Getenv("CLAUDE_AGENT_SDK_SKIP_VERSION_CHECK") != "" || t.launcherPath != "" {
 		return nil
 	}
 
@@ -381,12 +559,53 @@ func compareVersions(a, b string) int {
 	return 0
 }
 
+// buildEnv returns the environment for the CLI process.
+func (t *SubprocessTransport) buildEnv() []string {
+	env := os.Environ()
+	if t.options.Container != nil {
+		for k, v := range t.options.Container.Env {
+			env = append(env, k+"="+v)
+		}
+	}
+	for k, v := range t.proxyEnv() {
+		env = append(env, k+"="+v)
+	}
+	for k, v := range t.options.Env {
+		env = append(env, k+"="+v)
+	}
+	env = append(env, "CLAUDE_CODE_ENTRYPOINT=sdk-go")
+	env = append(env, "CLAUDE_AGENT_SDK_VERSION="+sdkVersion)
+	if t.options.EnableFileCheckpointing {
+		env = append(env, "CLAUDE_CODE_ENABLE_SDK_FILE_CHECKPOINTING=true")
+	}
+	if t.cwd != "" {
+		env = append(env, "PWD="+t.cwd)
+	}
+	return env
+}
+
+// localDir returns the directory to start the process in. Over SSH the
+// working directory is on the remote host.
+func (t *SubprocessTransport) localDir() string {
+	if t.options.SSH != nil {
+		return ""
+	}
+	return t.cwd
+}
+
 // Connect starts the subprocess.
 func (t *SubprocessTransport) Connect(ctx context.Context) error {
 	if t.process != nil {
 		return nil
 	}
 
+	if t.isStreaming && t.options.PipeDir != "" {
+		if err := t.connectPipes(ctx); err != nil {
+			return err
+		}
+		return t.started()
+	}
+
 	if err := t.checkClaudeVersion(ctx); err != nil {
 		return err
 	}
@@ -397,24 +616,9 @@ func (t *SubprocessTransport) Connect(ctx context.Context) error {
 	}
 
 	t.process = exec.CommandContext(ctx, args[0], args[1:]...)
+	t.process.Env = t.buildEnv()
 
-	env := os.Environ()
-	for k, v := range t.options.Env {
-		env = append(env, k+"="+v)
-	}
-	env = append(env, "CLAUDE_CODE_ENTRYPOINT=sdk-go")
-	env = append(env, "CLAUDE_AGENT_SDK_VERSION="+sdkVersion)
-	if t.options.EnableFileCheckpointing {
-		env = append(env, "CLAUDE_CODE_ENABLE_SDK_FILE_CHECKPOINTING=true")
-	}
-	if t.cwd != "" {
-		env = append(env, "PWD="+t.cwd)
-	}
-	t.process.Env = env
-
-	if t.cwd != "" {
-		t.process.Dir = t.cwd
-	}
+	t.process.Dir = t.localDir()
 
 	var stdinErr, stdoutErr, stderrErr error
 	t.stdin, stdinErr = t.process.StdinPipe()
@@ -427,34 +631,82 @@ func (t *SubprocessTransport) Connect(ctx context.Context) error {
 
 	if err := t.process.Start(); err != nil {
 		if os.IsNotExist(err) {
-			return fmt.Errorf("claude code not found at: %s", t.cliPath)
+			return fmt.Errorf("claude code not found at: %s", args[0])
 		}
 		return fmt.Errorf("failed to start claude code: %w", err)
 	}
+	t.pid = t.process.Process.Pid
+	if t.sshEnv != "" {
+		// The remote command reads its environment before starting the CLI
+		if _, err := io.WriteString(t.stdin, t.sshEnv); err != nil {
+			return fmt.Errorf("failed to send environment to remote host: %w", err)
+		}
+	}
+	return t.started()
+}
 
-	go t.handleStderr()
+// started reads the stderr of the process once it has started, and sends
+// the prompt of a non-streaming transport.
+func (t *SubprocessTransport) started() error {
+	t.exited = make(chan struct{})
+	t.detached = make(chan struct{})
+
+	done := make(chan struct{})
+	t.stderrDone = done
+	stderr := t.stderr
+	go func() {
+		defer close(done)
+		t.handleStderr(stderr)
+	}()
 
 	if !t.isStreaming {
-		_ = t.stdin.Close()
+		stdin := t.stdin
 		t.stdin = nil
+		if t.stdinPrompt {
+			// Write in the background: the CLI may start writing to stdout
+			// before it has consumed a large prompt.
+			go func() {
+				_, _ = io.WriteString(stdin, t.prompt)
+				_ = stdin.Close()
+			}()
+		} else {
+			_ = stdin.Close()
+		}
 	}
 
 	t.ready = true
 	return nil
 }
 
-func (t *SubprocessTransport) handleStderr() {
-	if t.stderr == nil {
+func (t *SubprocessTransport) handleStderr(stderr io.Reader) {
+	if stderr == nil {
 		return
 	}
 
-	scanner := bufio.NewScanner(t.stderr)
-	for scanner.Scan() {
-		line := scanner.Text()
+	reader := bufio.NewReader(stderr)
+	for {
+		line, size, err := readLimitedLine(reader, t.maxStderrLine)
+		if err != nil && size == 0 {
+			return
+		}
+		if size > t.maxStderrLine {
+			t.stderrMu.Lock()
+			t.stderrOverflows = append(t.stderrOverflows,
+				&BufferLimitError{Kind: BufferLimitStderrLine, Limit: t.maxStderrLine, Size: size})
+			t.stderrMu.Unlock()
+		}
+		line = strings.TrimRight(line, "\r\n")
 		if line == "" {
 			continue
 		}
 
+		t.stderrMu.Lock()
+		t.stderrTail = append(t.stderrTail, line)
+		if len(t.stderrTail) > stderrTailLines {
+			t.stderrTail = t.stderrTail[len(t.stderrTail)-stderrTailLines:]
+		}
+		t.stderrMu.Unlock()
+
 		if t.options.Stderr != nil {
 			t.options.Stderr(line)
 		} else if t.options.DebugStderr != nil {
@@ -463,6 +715,44 @@ func (t *SubprocessTransport) handleStderr() {
 	}
 }
 
+// readLimitedLine reads a line of at most limit bytes from r, discarding
+// the rest of longer lines. It returns the line and its full size.
+func readLimitedLine(r *bufio.Reader, limit int) (string, int, error) {
+	var line []byte
+	size := 0
+	for {
+		chunk, err := r.ReadSlice('\n')
+		size += len(chunk)
+		if room := limit - len(line); room > 0 {
+			line = append(line, chunk[:min(room, len(chunk))]...)
+		}
+		if err != bufio.ErrBufferFull {
+			if size > limit && bytes.HasSuffix(chunk, []byte("\n")) {
+				// The newline does not count towards the limit
+				size--
+			}
+			return string(line), size, err
+		}
+	}
+}
+
+// takeStderrOverflows returns and clears the stderr lines that exceeded
+// their limit.
+func (t *SubprocessTransport) takeStderrOverflows() []error {
+	t.stderrMu.Lock()
+	defer t.stderrMu.Unlock()
+	errs := t.stderrOverflows
+	t.stderrOverflows = nil
+	return errs
+}
+
+// stderrOutput returns the last lines written to stderr.
+func (t *SubprocessTransport) stderrOutput() string {
+	t.stderrMu.Lock()
+	defer t.stderrMu.Unlock()
+	return strings.Join(t.stderrTail, "\n")
+}
+
 // Write sends raw data to the transport.
 func (t *SubprocessTransport) Write(ctx context.Context, data string) error {
 	t.writeMu.Lock()
@@ -481,6 +771,7 @@ func (t *SubprocessTransport) Write(ctx context.Context, data string) error {
 		return fmt.Errorf("cannot write to process that exited with error: %w", t.exitError)
 	}
 
+	t.wire.log(wireSend, []byte(data))
 	if _, err := io.WriteString(t.stdin, data); err != nil {
 		t.ready = false
 		t.exitError = fmt.Errorf("failed to write to process stdin: %w", err)
@@ -497,13 +788,25 @@ func (t *SubprocessTransport) ReadMessages(ctx context.Context) <-chan ReadResul
 	go func() {
 		defer close(ch)
 
-		if t.process == nil || t.stdout == nil {
+		process, stdout := t.process, t.stdout
+		if process == nil || stdout == nil {
 			ch <- ReadResult{Error: fmt.Errorf("not connected")}
 			return
 		}
 
-		reader := bufio.NewReader(t.stdout)
-		var jsonBuffer strings.Builder
+		decode, release := NewDecoder(t.options.LowAllocParsing)
+		defer release()
+
+		reader := bufio.NewReader(stdout)
+		// jsonBuffer holds the lines of a message until they parse
+		var jsonBuffer []byte
+		reset := func() {
+			if cap(jsonBuffer) > retainedBufferSize {
+				jsonBuffer = nil
+			} else {
+				jsonBuffer = jsonBuffer[:0]
+			}
+		}
 
 		for {
 			select {
@@ -512,47 +815,86 @@ func (t *SubprocessTransport) ReadMessages(ctx context.Context) <-chan ReadResul
 			default:
 			}
 
-			line, err := reader.ReadString('\n')
+			start := len(jsonBuffer)
+			var err error
+			for {
+				var chunk []byte
+				chunk, err = reader.ReadSlice('\n')
+				jsonBuffer = append(jsonBuffer, chunk...)
+				if err != bufio.ErrBufferFull {
+					break
+				}
+			}
+			for _, overflow := range t.takeStderrOverflows() {
+				ch <- ReadResult{Error: overflow}
+			}
 			if err != nil {
 				if err == io.EOF {
 					break
 				}
+				if errors.Is(err, os.ErrClosed) {
+					// Detached
+					return
+				}
 				ch <- ReadResult{Error: fmt.Errorf("error reading stdout: %w", err)}
 				return
 			}
 
-			line = strings.TrimSpace(line)
-			if line == "" {
+			line := bytes.TrimSpace(jsonBuffer[start:])
+			jsonBuffer = append(jsonBuffer[:start], line...)
+			if len(line) == 0 {
 				continue
 			}
+			t.wire.log(wireRecv, line)
 
-			jsonBuffer.WriteString(line)
-
-			if jsonBuffer.Len() > t.maxBufferSize {
-				bufLen := jsonBuffer.Len()
-				jsonBuffer.Reset()
-				ch <- ReadResult{Error: fmt.Errorf("JSON message exceeded maximum buffer size of %d bytes (size: %d)",
-					t.maxBufferSize, bufLen)}
+			if size := len(jsonBuffer); size > max(t.maxBufferSize, t.maxControl) {
+				kind, limit := BufferLimitMessage, t.maxBufferSize
+				if isControlResponse(jsonBuffer) {
+					kind, limit = BufferLimitControlResponse, t.maxControl
+				}
+				reset()
+				ch <- ReadResult{Error: &BufferLimitError{Kind: kind, Limit: limit, Size: size}}
 				continue
 			}
 
-			var data map[string]any
-			if err := json.Unmarshal([]byte(jsonBuffer.String()), &data); err != nil {
+			data, err := decode(jsonBuffer)
+			if err != nil {
 				continue
 			}
 
-			jsonBuffer.Reset()
+			size := len(jsonBuffer)
+			reset()
+			if data["type"] == "control_response" {
+				if size > t.maxControl {
+					ch <- ReadResult{Error: &BufferLimitError{Kind: BufferLimitControlResponse, Limit: t.maxControl, Size: size}}
+					continue
+				}
+			} else if size > t.maxBufferSize {
+				ch <- ReadResult{Error: &BufferLimitError{Kind: BufferLimitMessage, Limit: t.maxBufferSize, Size: size}}
+				continue
+			}
 			ch <- ReadResult{Data: data}
 		}
 
-		if t.process != nil {
-			_ = t.process.Wait()
-			if t.process.ProcessState != nil && !t.process.ProcessState.Success() {
-				exitCode := t.process.ProcessState.ExitCode()
-				if exitCode != 0 {
-					t.exitError = fmt.Errorf("command failed with exit code %d", exitCode)
-					ch <- ReadResult{Error: t.exitError}
-				}
+		if t.stderrDone != nil {
+			select {
+			case <-t.stderrDone:
+			case <-time.After(stderrDrainTimeout):
+			}
+		}
+		for _, overflow := range t.takeStderrOverflows() {
+			ch <- ReadResult{Error: overflow}
+		}
+
+		t.wait(process)
+		if process.ProcessState != nil && !process.ProcessState.Success() {
+			exitCode := process.ProcessState.ExitCode()
+			if exitCode != 0 {
+				err := &ExitError{ExitCode: exitCode, Stderr: t.stderrOutput()}
+				t.writeMu.Lock()
+				t.exitError = err
+				t.writeMu.Unlock()
+				ch <- ReadResult{Error: err}
 			}
 		}
 	}()
@@ -562,11 +904,32 @@ func (t *SubprocessTransport) ReadMessages(ctx context.Context) <-chan ReadResul
 
 // Close closes the transport and cleans up resources.
 func (t *SubprocessTransport) Close() error {
+	_, _ = t.Shutdown(context.Background(), nil)
+	return nil
+}
+
+// StopStep is a step in ending the CLI process on Shutdown: a signal sent
+// to it, and how long to wait for it to exit before the next step.
+type StopStep struct {
+	Signal os.Signal
+	Grace  time.Duration
+}
+
+// Shutdown closes the transport like Close, ending the CLI process by
+// taking steps in turn rather than killing it at once. The process is
+// killed if it is still running after the last step, or once ctx is done.
+// A signal that cannot be sent, as SIGINT on Windows, moves on to the next
+// step.
+//
+// Shutdown returns the index of the step after which the process exited,
+// len(steps) if it was killed, or -1 if it had already exited or there is
+// no process, and the error killing it, if any.
+func (t *SubprocessTransport) Shutdown(ctx context.Context, steps []StopStep) (int, error) {
 	t.closeMu.Lock()
 	defer t.closeMu.Unlock()
 
 	if t.closed {
-		return nil
+		return -1, nil
 	}
 	t.closed = true
 
@@ -575,13 +938,14 @@ func (t *SubprocessTransport) Close() error {
 	}
 	t.tempFiles = nil
 
-	t.ready = false
-
+	// Writes check ready and process under writeMu
 	t.writeMu.Lock()
+	t.ready = false
 	if t.stdin != nil {
 		_ = t.stdin.Close()
 		t.stdin = nil
 	}
+	t.exitError = nil
 	t.writeMu.Unlock()
 
 	if t.stderr != nil {
@@ -589,16 +953,98 @@ func (t *SubprocessTransport) Close() error {
 		t.stderr = nil
 	}
 
+	stage := -1
+	var err error
 	if t.process != nil && t.process.Process != nil {
-		_ = t.process.Process.Kill()
-		_ = t.process.Wait()
+		stage, err = t.stop(ctx, t.process, steps)
 	}
 
+	t.writeMu.Lock()
 	t.process = nil
+	t.writeMu.Unlock()
+	if t.pipes != nil {
+		// Unlike the pipes of exec.Cmd, named pipes are not closed on Wait
+		if t.stdout != nil {
+			_ = t.stdout.Close()
+		}
+		removePipes(t.options.PipeDir)
+	}
 	t.stdout = nil
-	t.exitError = nil
 
-	return nil
+	return stage, err
+}
+
+// stop takes steps to end process, then kills it, and waits for it. It
+// returns the index of the step that ended it, as for Shutdown.
+func (t *SubprocessTransport) stop(ctx context.Context, process *exec.Cmd, steps []StopStep) (int, error) {
+	go t.wait(process)
+	select {
+	case <-t.exited:
+		return -1, nil
+	default:
+	}
+
+	for i, step := range steps {
+		if process.Process.Signal(step.Signal) != nil {
+			continue
+		}
+		timer := time.NewTimer(step.Grace)
+		select {
+		case <-t.exited:
+			timer.Stop()
+			return i, nil
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if err := process.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		// Do not wait on a process that could not be killed
+		return len(steps), fmt.Errorf("failed to kill claude code: %w", err)
+	}
+	<-t.exited
+	return len(steps), nil
+}
+
+// wait waits for process to exit. It is safe to call more than once.
+func (t *SubprocessTransport) wait(process *exec.Cmd) {
+	t.waitOnce.Do(func() {
+		if t.reattached {
+			waitExit(process.Process.Pid)
+		} else {
+			_ = process.Wait()
+		}
+		t.exitCode = -1
+		if process.ProcessState != nil {
+			t.exitCode = process.ProcessState.ExitCode()
+		}
+		if t.exited != nil {
+			close(t.exited)
+		}
+	})
+}
+
+// PID returns the process ID of the CLI, or 0 if it has not started.
+func (t *SubprocessTransport) PID() int {
+	return t.pid
+}
+
+// Exited returns a channel closed once the CLI process has exited and
+// been waited for, by ReadMessages or Close, or nil if it has not
+// started.
+func (t *SubprocessTransport) Exited() <-chan struct{} {
+	return t.exited
+}
+
+// ExitCode returns the exit code of the CLI process, or -1 if it was
+// ended by a signal or, when reattached to over named pipes, is unknown.
+// It is valid once Exited is closed.
+func (t *SubprocessTransport) ExitCode() int {
+	return t.exitCode
 }
 
 // IsReady returns true if the transport is ready for communication.
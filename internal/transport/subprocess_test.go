@@ -1,11 +1,18 @@
 package transport
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestCompareVersions(t *testing.T) {
@@ -131,6 +138,57 @@ func TestNewSubprocessTransport_DefaultBufferSize(t *testing.T) {
 	}
 }
 
+func TestNewSubprocessTransport_BufferLimits(t *testing.T) {
+	transport, _ := NewSubprocessTransport("test", false, &Options{CLIPath: "/nonexistent/path/claude"})
+	if transport == nil {
+		return // CLI not found, skip
+	}
+	if transport.maxStderrLine != defaultMaxStderrLineSize || transport.maxControl != defaultMaxControlSize {
+		t.Errorf("Expected default limits, got %d and %d", transport.maxStderrLine, transport.maxControl)
+	}
+
+	transport, _ = NewSubprocessTransport("test", false, &Options{
+		CLIPath:                "/nonexistent/path/claude",
+		MaxStderrLineSize:      512,
+		MaxControlResponseSize: 4096,
+	})
+	if transport.maxStderrLine != 512 || transport.maxControl != 4096 {
+		t.Errorf("Expected limits 512 and 4096, got %d and %d", transport.maxStderrLine, transport.maxControl)
+	}
+}
+
+func TestReadLimitedLine(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	// A small reader buffer makes long lines span several reads
+	reader := bufio.NewReaderSize(strings.NewReader("short\n"+long+"\n"+long), 16)
+
+	tests := []struct {
+		wantLine string
+		wantSize int
+		wantErr  error
+	}{
+		{"short\n", 6, nil},
+		{long[:40], 100, nil},
+		{long[:40], 100, io.EOF},
+	}
+	for i, tt := range tests {
+		line, size, err := readLimitedLine(reader, 40)
+		if line != tt.wantLine || size != tt.wantSize || err != tt.wantErr {
+			t.Errorf("Line %d: expected %q, %d, %v, got %q, %d, %v", i, tt.wantLine, tt.wantSize, tt.wantErr, line, size, err)
+		}
+	}
+}
+
+func TestIsControlResponse(t *testing.T) {
+	if !isControlResponse([]byte(`{"type":"control_response","response":{}}`)) {
+		t.Error("Expected a control response")
+	}
+	assistant := `{"type":"assistant","message":{"model":"claude-sonnet-4-5","content":"control_response"}}`
+	if isControlResponse([]byte(assistant)) {
+		t.Error("Expected the type to be read from the start of the message only")
+	}
+}
+
 func TestSubprocessTransport_BuildCommand_Basic(t *testing.T) {
 	transport := &SubprocessTransport{
 		cliPath:     "/usr/local/bin/claude",
@@ -700,6 +758,34 @@ func TestSubprocessTransport_EndInput_NilStdin(t *testing.T) {
 	}
 }
 
+func TestSubprocessTransport_BuildCommand_Sampling(t *testing.T) {
+	temperature, topP, seed := 0.0, 0.9, int64(42)
+	transport := &SubprocessTransport{
+		cliPath:     "/usr/local/bin/claude",
+		isStreaming: true,
+		options: &Options{
+			Temperature: &temperature,
+			TopP:        &topP,
+			Seed:        &seed,
+		},
+	}
+
+	cmd, err := transport.buildCommand()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := map[string]string{"--temperature": "0", "--top-p": "0.9", "--seed": "42"}
+	for i, arg := range cmd {
+		if value, ok := want[arg]; ok && i+1 < len(cmd) && cmd[i+1] == value {
+			delete(want, arg)
+		}
+	}
+	if len(want) > 0 {
+		t.Errorf("Expected flags %v in command %v", want, cmd)
+	}
+}
+
 func TestSubprocessTransport_BuildCommand_MaxThinkingTokens(t *testing.T) {
 	transport := &SubprocessTransport{
 		cliPath:     "/usr/local/bin/claude",
@@ -838,6 +924,33 @@ func TestSubprocessTransport_BuildCommand_Resume(t *testing.T) {
 	}
 }
 
+func TestSubprocessTransport_BuildCommand_ResumeSessionAt(t *testing.T) {
+	transport := &SubprocessTransport{
+		cliPath:     "/usr/local/bin/claude",
+		isStreaming: true,
+		options: &Options{
+			Resume:          "session-123",
+			ResumeSessionAt: "msg-1",
+		},
+	}
+
+	cmd, err := transport.buildCommand()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	found := false
+	for i, arg := range cmd {
+		if arg == "--resume-session-at" && i+1 < len(cmd) && cmd[i+1] == "msg-1" {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Error("Expected --resume-session-at flag with message UUID")
+	}
+}
+
 func TestSubprocessTransport_BuildCommand_DisallowedTools(t *testing.T) {
 	transport := &SubprocessTransport{
 		cliPath:     "/usr/local/bin/claude",
@@ -1723,3 +1836,417 @@ func TestSubprocessTransport_Close_Ready(t *testing.T) {
 		t.Error("Expected ready to be false after Close")
 	}
 }
+
+func TestSubprocessTransport_BuildCommand_LargePromptUsesStdin(t *testing.T) {
+	prompt := strings.Repeat("x", cmdLengthLimit+1)
+	transport := &SubprocessTransport{
+		cliPath:     "/usr/local/bin/claude",
+		isStreaming: false,
+		prompt:      prompt,
+		options:     &Options{},
+	}
+
+	cmd, err := transport.buildCommand()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !transport.stdinPrompt {
+		t.Error("Expected large prompt to be sent over stdin")
+	}
+	for _, arg := range cmd {
+		if arg == prompt || arg == "--" {
+			t.Fatal("Expected prompt to be omitted from the command line")
+		}
+	}
+	if cmd[len(cmd)-1] != "--print" {
+		t.Errorf("Expected --print as last argument, got %q", cmd[len(cmd)-1])
+	}
+}
+
+func TestSubprocessTransport_BuildCommand_PromptLengthBoundary(t *testing.T) {
+	base := &SubprocessTransport{
+		cliPath:     "/usr/local/bin/claude",
+		isStreaming: false,
+		options:     &Options{},
+	}
+	cmd, err := base.buildCommand()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// cmd ends with "--" and the empty prompt
+	room := cmdLengthLimit - commandLength(cmd[:len(cmd)-2]) - len(" -- ")
+
+	tests := []struct {
+		name      string
+		length    int
+		wantStdin bool
+	}{
+		{"at limit", room, false},
+		{"over limit", room + 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &SubprocessTransport{
+				cliPath:     "/usr/local/bin/claude",
+				isStreaming: false,
+				prompt:      strings.Repeat("y", tt.length),
+				options:     &Options{},
+			}
+			cmd, err := transport.buildCommand()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if transport.stdinPrompt != tt.wantStdin {
+				t.Errorf("Expected stdinPrompt %v, got %v", tt.wantStdin, transport.stdinPrompt)
+			}
+			if !tt.wantStdin && commandLength(cmd) > cmdLengthLimit {
+				t.Errorf("Command length %d exceeds limit %d", commandLength(cmd), cmdLengthLimit)
+			}
+		})
+	}
+}
+
+func TestSubprocessTransport_BuildCommand_PromptEdgeCases(t *testing.T) {
+	tests := []struct {
+		name      string
+		prompt    string
+		wantStdin bool
+	}{
+		{"leading dash", "--help me", false},
+		{"quotes and newlines", "say \"hi\"\n'there'", false},
+		{"nul byte", "before\x00after", true},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &SubprocessTransport{
+				cliPath:     "/usr/local/bin/claude",
+				isStreaming: false,
+				prompt:      tt.prompt,
+				options:     &Options{},
+			}
+			cmd, err := transport.buildCommand()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if transport.stdinPrompt != tt.wantStdin {
+				t.Errorf("Expected stdinPrompt %v, got %v", tt.wantStdin, transport.stdinPrompt)
+			}
+			if !tt.wantStdin {
+				// The prompt is passed verbatim after the separator
+				if cmd[len(cmd)-2] != "--" || cmd[len(cmd)-1] != tt.prompt {
+					t.Errorf("Expected prompt after --, got %q", cmd[len(cmd)-2:])
+				}
+			}
+		})
+	}
+}
+
+func TestSubprocessTransport_BuildCommand_StreamingIgnoresPromptLength(t *testing.T) {
+	transport := &SubprocessTransport{
+		cliPath:     "/usr/local/bin/claude",
+		isStreaming: true,
+		prompt:      strings.Repeat("x", cmdLengthLimit+1),
+		options:     &Options{},
+	}
+
+	if _, err := transport.buildCommand(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if transport.stdinPrompt {
+		t.Error("Expected streaming mode to never send the prompt over stdin")
+	}
+}
+
+func TestSubprocessTransport_Connect_LargePromptOverStdin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	t.Setenv("CLAUDE_AGENT_SDK_SKIP_VERSION_CHECK", "1")
+
+	// A stub CLI that reports how many bytes it read from stdin.
+	script := filepath.Join(t.TempDir(), "claude")
+	stub := "#!/bin/sh\nn=$(wc -c | tr -d ' ')\necho '{\"type\":\"result\",\"subtype\":\"success\",\"result\":\"'$n'\"}'\n"
+	if err := os.WriteFile(script, []byte(stub), 0o755); err != nil {
+		t.Fatalf("Failed to write stub CLI: %v", err)
+	}
+
+	prompt := strings.Repeat("z", cmdLengthLimit*2)
+	transport, err := NewSubprocessTransport(prompt, false, &Options{CLIPath: script})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := transport.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = transport.Close() }()
+
+	var results []ReadResult
+	for result := range transport.ReadMessages(ctx) {
+		results = append(results, result)
+	}
+
+	if len(results) == 0 {
+		t.Fatal("Expected a message from the stub CLI")
+	}
+	if results[0].Error != nil {
+		t.Fatalf("Unexpected read error: %v", results[0].Error)
+	}
+	if got := results[0].Data["result"]; got != fmt.Sprint(len(prompt)) {
+		t.Errorf("Expected CLI to read %d bytes from stdin, got %v", len(prompt), got)
+	}
+}
+
+func TestSubprocessTransport_ReadMessages_ExitError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	t.Setenv("CLAUDE_AGENT_SDK_SKIP_VERSION_CHECK", "1")
+
+	// A stub CLI that writes a message, complains on stderr, and crashes.
+	script := filepath.Join(t.TempDir(), "claude")
+	stub := "#!/bin/sh\necho '{\"type\":\"assistant\"}'\nfor i in $(seq 1 30); do echo \"line $i\" >&2; done\nexit 3\n"
+	if err := os.WriteFile(script, []byte(stub), 0o755); err != nil {
+		t.Fatalf("Failed to write stub CLI: %v", err)
+	}
+
+	transport, err := NewSubprocessTransport("hi", false, &Options{CLIPath: script})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := transport.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = transport.Close() }()
+
+	var results []ReadResult
+	for result := range transport.ReadMessages(ctx) {
+		results = append(results, result)
+	}
+
+	if len(results) != 2 || results[0].Error != nil {
+		t.Fatalf("Expected a message then an error, got %+v", results)
+	}
+	var exitErr *ExitError
+	if !errors.As(results[1].Error, &exitErr) {
+		t.Fatalf("Expected ExitError, got %v", results[1].Error)
+	}
+	if exitErr.ExitCode != 3 {
+		t.Errorf("Expected exit code 3, got %d", exitErr.ExitCode)
+	}
+	select {
+	case <-transport.Exited():
+	default:
+		t.Error("Expected Exited closed once the process was waited for")
+	}
+	if transport.PID() <= 0 || transport.ExitCode() != 3 {
+		t.Errorf("Expected a PID and exit code 3, got %d and %d", transport.PID(), transport.ExitCode())
+	}
+	lines := strings.Split(exitErr.Stderr, "\n")
+	if len(lines) != stderrTailLines || lines[0] != "line 11" || lines[len(lines)-1] != "line 30" {
+		t.Errorf("Expected the last %d stderr lines, got %q", stderrTailLines, exitErr.Stderr)
+	}
+}
+
+func TestSubprocessTransport_BuildCommand_SessionID(t *testing.T) {
+	transport := &SubprocessTransport{
+		cliPath:     "/usr/local/bin/claude",
+		isStreaming: true,
+		options: &Options{
+			SessionID: "123e4567-e89b-42d3-a456-426614174000",
+		},
+	}
+
+	cmd, err := transport.buildCommand()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	found := false
+	for i, arg := range cmd {
+		if arg == "--session-id" && i+1 < len(cmd) && cmd[i+1] == "123e4567-e89b-42d3-a456-426614174000" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected --session-id flag in command")
+	}
+}
+
+func TestProcessAlive(t *testing.T) {
+	if !ProcessAlive(os.Getpid()) {
+		t.Error("Expected current process to be alive")
+	}
+	if ProcessAlive(0) || ProcessAlive(-1) {
+		t.Error("Expected invalid PIDs to not be alive")
+	}
+}
+
+func TestProcessIdentity(t *testing.T) {
+	id := ProcessIdentity(os.Getpid())
+	if id == "" {
+		t.Fatal("Expected an identity for the current process")
+	}
+	if again := ProcessIdentity(os.Getpid()); again != id {
+		t.Errorf("Expected a stable identity, got %q and %q", id, again)
+	}
+	if ProcessIdentity(-1) != "" {
+		t.Error("Expected no identity for an invalid PID")
+	}
+}
+
+func TestSubprocessTransport_BuildCommand_JSONOutput(t *testing.T) {
+	transport := &SubprocessTransport{
+		cliPath: "claude",
+		prompt:  "Hello",
+		options: &Options{JSONOutput: true},
+	}
+
+	cmd, err := transport.buildCommand()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(cmd[:3], " ") != "claude --output-format json" || strings.Contains(strings.Join(cmd, " "), "--verbose") {
+		t.Errorf("Expected single JSON output mode, got %v", cmd)
+	}
+
+	// Streaming always uses stream-json
+	transport.isStreaming = true
+	cmd, _ = transport.buildCommand()
+	if cmd[2] != "stream-json" {
+		t.Errorf("Expected stream-json when streaming, got %v", cmd)
+	}
+}
+
+// writeEchoCLI writes a stub CLI that echoes each line of its stdin.
+func writeEchoCLI(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	t.Setenv("CLAUDE_AGENT_SDK_SKIP_VERSION_CHECK", "1")
+	script := filepath.Join(t.TempDir(), "claude")
+	stub := "#!/bin/sh\nwhile read -r line; do echo \"$line\"; done\n"
+	if err := os.WriteFile(script, []byte(stub), 0o755); err != nil {
+		t.Fatalf("Failed to write stub CLI: %v", err)
+	}
+	return script
+}
+
+// echo writes a message with n to transport and reads it back.
+func echo(t *testing.T, ctx context.Context, transport *SubprocessTransport, messages <-chan ReadResult, n int) {
+	t.Helper()
+	if err := transport.Write(ctx, fmt.Sprintf(`{"n":%d}`+"\n", n)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	select {
+	case result := <-messages:
+		if result.Error != nil || result.Data["n"] != float64(n) {
+			t.Fatalf("Expected the message echoed, got %+v", result)
+		}
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for the echo")
+	}
+}
+
+func TestSubprocessTransport_Pipes_Reattach(t *testing.T) {
+	script := writeEchoCLI(t)
+	dir := filepath.Join(t.TempDir(), "pipes")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	first, err := NewSubprocessTransport("", true, &Options{CLIPath: script, PipeDir: dir})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := first.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if first.Reattached() {
+		t.Error("Expected the first transport to start the process")
+	}
+	echo(t, ctx, first, first.ReadMessages(ctx), 1)
+	if err := first.SaveInit(map[string]any{"models": []any{}}); err != nil {
+		t.Fatalf("SaveInit failed: %v", err)
+	}
+	pid := first.PID()
+	if err := first.Detach(); err != nil {
+		t.Fatalf("Detach failed: %v", err)
+	}
+	select {
+	case <-first.Detached():
+	default:
+		t.Error("Expected Detached closed")
+	}
+	if !ProcessAlive(pid) {
+		t.Fatal("Expected the process to keep running once detached")
+	}
+
+	second, err := NewSubprocessTransport("", true, &Options{CLIPath: script, PipeDir: dir})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := second.Connect(ctx); err != nil {
+		t.Fatalf("Reattaching failed: %v", err)
+	}
+	if !second.Reattached() || second.PID() != pid || second.SavedInit() == nil {
+		t.Errorf("Expected to reattach to process %d with its init, got %d, %v", pid, second.PID(), second.SavedInit())
+	}
+	echo(t, ctx, second, second.ReadMessages(ctx), 2)
+
+	// Only one transport is attached at a time
+	third, _ := NewSubprocessTransport("", true, &Options{CLIPath: script, PipeDir: dir})
+	if err := third.Connect(ctx); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Errorf("Expected the pipes in use, got %v", err)
+	}
+
+	if err := second.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if ProcessAlive(pid) {
+		t.Error("Expected Close to end the process")
+	}
+	if _, err := os.Stat(filepath.Join(dir, pipeStdin)); !os.IsNotExist(err) {
+		t.Errorf("Expected Close to remove the pipes, got %v", err)
+	}
+}
+
+func TestSubprocessTransport_Pipes_ExitedWhileDetached(t *testing.T) {
+	script := writeEchoCLI(t)
+	dir := t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	first, _ := NewSubprocessTransport("", true, &Options{CLIPath: script, PipeDir: dir})
+	if err := first.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	pid := first.PID()
+	if err := first.Detach(); err != nil {
+		t.Fatalf("Detach failed: %v", err)
+	}
+	process, _ := os.FindProcess(pid)
+	if err := process.Kill(); err != nil {
+		t.Fatalf("Failed to kill the process: %v", err)
+	}
+	<-first.Exited()
+
+	second, _ := NewSubprocessTransport("", true, &Options{CLIPath: script, PipeDir: dir})
+	if err := second.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = second.Close() }()
+	if second.Reattached() || second.PID() == pid {
+		t.Error("Expected a new process once the previous one exited")
+	}
+	echo(t, ctx, second, second.ReadMessages(ctx), 1)
+}
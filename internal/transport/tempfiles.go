@@ -0,0 +1,109 @@
+package transport
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// tempDirName is the directory the SDK keeps its temp files in, under
+	// Options.TempDir or the system temp directory.
+	tempDirName = "claude-agent-sdk"
+	// tempFileMaxAge is the age after which a temp file is swept even if
+	// the process that created it seems alive, in case its PID was reused.
+	tempFileMaxAge = 24 * time.Hour
+)
+
+// tempFileName matches the names given by createTempFile and captures the
+// PID of the process that created the file.
+var tempFileName = regexp.MustCompile(`^claude-[a-z]+-(\d+)-`)
+
+// unsafeNameChars are replaced in session IDs put in file names.
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// sweptTempDirs holds the temp directories swept by this process.
+var sweptTempDirs sync.Map
+
+// tempDir returns the directory for the temp files of t, creating it and
+// sweeping the files left there by processes that have exited, once per
+// process.
+func (t *SubprocessTransport) tempDir() (string, error) {
+	base := t.options.TempDir
+	if base == "" {
+		base = os.TempDir()
+	}
+	dir := filepath.Join(base, tempDirName)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	if _, swept := sweptTempDirs.LoadOrStore(dir, true); !swept {
+		sweepTempFiles(dir, time.Now())
+	}
+	return dir, nil
+}
+
+// createTempFile writes data to a new temp file named after kind, the
+// current PID and the session of t, and records it for removal on Close.
+// The PID in the name lets a later run remove the file if this process
+// crashes before Close.
+func (t *SubprocessTransport) createTempFile(kind string, data []byte) (string, error) {
+	dir, err := t.tempDir()
+	if err != nil {
+		return "", err
+	}
+
+	session := t.options.SessionID
+	if session == "" {
+		session = t.options.Resume
+	}
+	if session == "" {
+		session = "new"
+	}
+	session = unsafeNameChars.ReplaceAllString(session, "_")
+
+	pattern := "claude-" + kind + "-" + strconv.Itoa(os.Getpid()) + "-" + session + "-*.json"
+	file, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+	t.tempFiles = append(t.tempFiles, file.Name())
+	return file.Name(), nil
+}
+
+// sweepTempFiles removes the temp files in dir whose creating process is
+// no longer running, or that are older than tempFileMaxAge.
+func sweepTempFiles(dir string, now time.Time) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		m := tempFileName.FindStringSubmatch(entry.Name())
+		if m == nil || entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		pid, _ := strconv.Atoi(m[1])
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if ProcessAlive(pid) && now.Sub(info.ModTime()) < tempFileMaxAge {
+			continue
+		}
+		_ = os.Remove(filepath.Join(dir, entry.Name()))
+	}
+}
@@ -0,0 +1,94 @@
+package transport
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubprocessTransport_BuildCommand_AgentsTempFile(t *testing.T) {
+	base := t.TempDir()
+	transport := &SubprocessTransport{
+		cliPath:     "/usr/local/bin/claude",
+		isStreaming: true,
+		options: &Options{
+			TempDir:   base,
+			SessionID: "abc/1",
+			Agents: map[string]AgentDefinition{
+				"reviewer": {Description: "Reviews code", Prompt: strings.Repeat("x", cmdLengthLimit)},
+			},
+		},
+	}
+
+	cmd, err := transport.buildCommand()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var path string
+	for i, arg := range cmd {
+		if arg == "--agents" && i+1 < len(cmd) {
+			path = strings.TrimPrefix(cmd[i+1], "@")
+		}
+	}
+
+	if filepath.Dir(path) != filepath.Join(base, tempDirName) {
+		t.Errorf("Expected the agents file under %s, got %q", filepath.Join(base, tempDirName), path)
+	}
+	prefix := "claude-agents-" + strconv.Itoa(os.Getpid()) + "-abc_1-"
+	if !strings.HasPrefix(filepath.Base(path), prefix) {
+		t.Errorf("Expected the agents file named %s*, got %q", prefix, filepath.Base(path))
+	}
+	if data, err := os.ReadFile(path); err != nil || !strings.Contains(string(data), `"reviewer"`) {
+		t.Errorf("Expected the agents written to the file, got %q, %v", data, err)
+	}
+
+	if err := transport.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected the agents file removed on Close")
+	}
+}
+
+func TestSweepTempFiles(t *testing.T) {
+	// The PID of an exited process stands in for one that crashed
+	exited := exec.Command("true")
+	if err := exited.Run(); err != nil {
+		t.Skipf("cannot run true: %v", err)
+	}
+	deadPID := strconv.Itoa(exited.Process.Pid)
+	ownPID := strconv.Itoa(os.Getpid())
+
+	dir := t.TempDir()
+	files := map[string]bool{ // name: kept
+		"claude-agents-" + deadPID + "-new-1.json": false,
+		"claude-agents-" + ownPID + "-new-2.json":  true,
+		"claude-agents-" + ownPID + "-old-3.json":  false,
+		"unrelated.json": true,
+	}
+	for name := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	old := time.Now().Add(-2 * tempFileMaxAge)
+	if err := os.Chtimes(filepath.Join(dir, "claude-agents-"+ownPID+"-old-3.json"), old, old); err != nil {
+		t.Fatalf("Failed to age file: %v", err)
+	}
+
+	sweepTempFiles(dir, time.Now())
+
+	for name, kept := range files {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if kept && err != nil {
+			t.Errorf("Expected %s kept, got %v", name, err)
+		}
+		if !kept && !os.IsNotExist(err) {
+			t.Errorf("Expected %s removed", name)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Directions of the lines in a wire log.
+const (
+	wireSend = "send"
+	wireRecv = "recv"
+)
+
+// wireLog writes the JSON lines exchanged with the CLI to a writer, with
+// the values of the redacted fields replaced. A nil wireLog logs nothing.
+type wireLog struct {
+	mu     sync.Mutex
+	w      io.Writer
+	redact map[string]bool
+}
+
+// newWireLog returns a wireLog writing to w, or nil if w is nil.
+func newWireLog(w io.Writer, redact []string) *wireLog {
+	if w == nil {
+		return nil
+	}
+	l := &wireLog{w: w, redact: make(map[string]bool, len(redact))}
+	for _, field := range redact {
+		l.redact[field] = true
+	}
+	return l
+}
+
+// log writes line, sent or received as dir, prefixed with the time and
+// dir. Write errors are ignored, so that logging cannot fail the
+// connection.
+func (l *wireLog) log(dir string, line []byte) {
+	if l == nil {
+		return
+	}
+	line = l.redacted(bytes.TrimSpace(line))
+
+	buf := make([]byte, 0, len(line)+32)
+	buf = time.Now().AppendFormat(buf, "15:04:05.000000")
+	buf = append(buf, ' ')
+	buf = append(buf, dir...)
+	buf = append(buf, ' ')
+	buf = append(buf, line...)
+	buf = append(buf, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.w.Write(buf)
+}
+
+// redacted returns line with the values of the redacted fields, at any
+// depth, replaced. Lines without such fields are returned as is.
+func (l *wireLog) redacted(line []byte) []byte {
+	found := false
+	for field := range l.redact {
+		if bytes.Contains(line, []byte(`"`+field+`"`)) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return line
+	}
+
+	var v any
+	if json.Unmarshal(line, &v) != nil {
+		return line
+	}
+	data, err := json.Marshal(l.redactValue(v))
+	if err != nil {
+		return line
+	}
+	return data
+}
+
+func (l *wireLog) redactValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, elem := range v {
+			if l.redact[k] {
+				v[k] = redacted
+			} else {
+				v[k] = l.redactValue(elem)
+			}
+		}
+	case []any:
+		for i, elem := range v {
+			v[i] = l.redactValue(elem)
+		}
+	}
+	return v
+}
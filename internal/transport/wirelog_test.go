@@ -0,0 +1,31 @@
+package transport
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWireLog(t *testing.T) {
+	var buf bytes.Buffer
+	l := newWireLog(&buf, []string{"api_key"})
+	l.log(wireSend, []byte(`{"type":"control_request","request":{"api_key":"sk-ant-secret","subtype":"initialize"}}`+"\n"))
+	l.log(wireRecv, []byte(`{"type":"result", "api_keys":1}`))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %q", buf.String())
+	}
+	if !strings.HasSuffix(lines[0], ` send {"request":{"api_key":"[REDACTED]","subtype":"initialize"},"type":"control_request"}`) {
+		t.Errorf("Expected the sent line with api_key redacted, got %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], ` recv {"type":"result", "api_keys":1}`) {
+		t.Errorf("Expected the received line logged as is, got %q", lines[1])
+	}
+
+	var nilLog *wireLog
+	nilLog.log(wireSend, []byte("{}"))
+	if newWireLog(nil, []string{"api_key"}) != nil {
+		t.Error("Expected no wire log without a writer")
+	}
+}
@@ -127,6 +127,13 @@ type PermissionResultDeny struct {
 
 func (PermissionResultDeny) IsAllow() bool { return false }
 
+// PermissionResultAsk leaves the decision to the CLI's permission prompt.
+type PermissionResultAsk struct {
+	Message string
+}
+
+func (PermissionResultAsk) IsAllow() bool { return false }
+
 // CanUseToolFunc is the callback type for tool permission requests.
 type CanUseToolFunc func(ctx context.Context, toolName string, input map[string]any, permCtx ToolPermissionContext) (PermissionResult, error)
 
@@ -155,6 +162,10 @@ type BaseHookInput struct {
 	TranscriptPath string `json:"transcript_path"`
 	Cwd            string `json:"cwd"`
 	PermissionMode string `json:"permission_mode,omitempty"`
+
+	// Raw is the input as sent by the CLI, including fields not decoded
+	// into the typed input.
+	Raw map[string]any `json:"-"`
 }
 
 func (b BaseHookInput) GetSessionID() string { return b.SessionID }
@@ -259,6 +270,7 @@ type HookOutput struct {
 	Reason             string                 `json:"reason,omitempty"`
 	HookEventName      HookEvent              `json:"hookEventName,omitempty"`
 	PermissionDecision HookPermissionDecision `json:"permissionDecision,omitempty"`
+	PermissionReason   string                 `json:"permissionDecisionReason,omitempty"`
 	UpdatedInput       map[string]any         `json:"updatedInput,omitempty"`
 	AdditionalContext  string                 `json:"additionalContext,omitempty"`
 }
@@ -300,6 +312,9 @@ func (h *HookOutput) ToMap() map[string]any {
 		if h.PermissionDecision != "" {
 			specific["permissionDecision"] = string(h.PermissionDecision)
 		}
+		if h.PermissionReason != "" {
+			specific["permissionDecisionReason"] = h.PermissionReason
+		}
 		if h.UpdatedInput != nil {
 			specific["updatedInput"] = h.UpdatedInput
 		}
@@ -347,8 +362,9 @@ type MCPToolHandler func(ctx context.Context, args map[string]any) (MCPToolResul
 
 // MCPToolResult represents the result of an MCP tool call.
 type MCPToolResult struct {
-	Content []MCPContent `json:"content"`
-	IsError bool         `json:"is_error,omitempty"`
+	Content    []MCPContent `json:"content"`
+	IsError    bool         `json:"is_error,omitempty"`
+	Structured any          `json:"structuredContent,omitempty"`
 }
 
 // MCPContent represents content in an MCP tool result.
@@ -357,4 +373,7 @@ type MCPContent struct {
 	Text     string `json:"text,omitempty"`
 	Data     string `json:"data,omitempty"`
 	MimeType string `json:"mimeType,omitempty"`
+	URI      string `json:"uri,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Size     int64  `json:"size,omitempty"`
 }
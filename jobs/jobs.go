@@ -0,0 +1,247 @@
+// Package jobs runs batches of Claude queries, such as the same code mod
+// across many repositories, with bounded concurrency, retries and
+// persisted progress.
+//
+// A Job is a prompt with its options and callbacks. A Runner runs jobs with
+// claude.Query, a few at a time, retrying failed attempts with backoff:
+//
+//	runner := &jobs.Runner{Concurrency: 8, Retries: 2, Store: jobs.FileStore{Dir: "progress"}}
+//	results := runner.Run(ctx, []*jobs.Job{
+//	    {ID: "api", Prompt: "Migrate to the v2 client", Options: []claude.Option{claude.WithCwd("repos/api")}},
+//	    {ID: "web", Prompt: "Migrate to the v2 client", Options: []claude.Option{claude.WithCwd("repos/web")}},
+//	})
+//
+// With a Store, the outcome of each job is saved as it finishes, and jobs
+// that already succeeded are skipped when the batch is run again, so an
+// interrupted batch can be resumed.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	claude "github.com/afsharalex/claude-agent-sdk-go"
+)
+
+// Status is the state of a job.
+type Status string
+
+const (
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	// StatusSkipped is reported for jobs that a Store records as having
+	// succeeded in an earlier run.
+	StatusSkipped Status = "skipped"
+)
+
+// Job is a query to run.
+type Job struct {
+	// ID identifies the job in results and the Store. It must be unique
+	// within a batch.
+	ID string
+
+	// Prompt is the prompt of the query.
+	Prompt string
+
+	// Options apply to this job, after those of the Runner.
+	Options []claude.Option
+
+	// OnMessage, if set, is called with each message of each attempt.
+	OnMessage func(job *Job, msg claude.Message)
+
+	// OnDone, if set, is called when the job has finished, whether it
+	// succeeded or not. It is not called for skipped jobs.
+	OnDone func(job *Job, result Result)
+}
+
+// Result is the outcome of a job.
+type Result struct {
+	JobID    string                `json:"job_id"`
+	Status   Status                `json:"status"`
+	Attempts int                   `json:"attempts"`
+	Result   *claude.ResultMessage `json:"result,omitempty"`
+
+	// Err is the error of the last attempt of a failed job. Error holds
+	// its message, which is what a Store keeps.
+	Err   error  `json:"-"`
+	Error string `json:"error,omitempty"`
+
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+}
+
+// QueryFunc runs a query, as claude.Query does.
+type QueryFunc func(ctx context.Context, prompt string, opts ...claude.Option) (<-chan claude.Message, <-chan error)
+
+// Runner runs jobs. The zero value runs one job at a time without
+// retries.
+type Runner struct {
+	// Concurrency is how many jobs run at once. Defaults to 1.
+	Concurrency int
+
+	// Retries is how many times a failed attempt is retried.
+	Retries int
+
+	// Backoff is the delay before the first retry, doubled for each
+	// further one. Defaults to one second. A rate limit delays the retry
+	// until the limit resets, if that is later.
+	Backoff time.Duration
+
+	// Retryable reports whether a failed attempt is retried. Defaults to
+	// retrying all errors except a missing CLI and the cancellation of
+	// the context passed to Run.
+	Retryable func(err error) bool
+
+	// Options apply to every job, before its own.
+	Options []claude.Option
+
+	// Store, if set, records the outcome of each job. Jobs it reports as
+	// succeeded are skipped.
+	Store Store
+
+	// Query runs the attempts of jobs. Defaults to claude.Query.
+	Query QueryFunc
+}
+
+// Run runs jobs, in order, and returns their results in the same order.
+// It returns once every job has finished; cancelling ctx fails the jobs
+// not yet finished.
+func (r *Runner) Run(ctx context.Context, jobs []*Job) []Result {
+	results := make([]Result, len(jobs))
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for range min(max(1, r.Concurrency), len(jobs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1)) - 1
+				if i >= len(jobs) {
+					return
+				}
+				results[i] = r.runJob(ctx, jobs[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// runJob runs job until an attempt succeeds or no retry is left.
+func (r *Runner) runJob(ctx context.Context, job *Job) Result {
+	if err := ctx.Err(); err != nil {
+		return r.finish(ctx, job, Result{JobID: job.ID, Status: StatusFailed, Err: err})
+	}
+	if r.Store != nil {
+		if saved, ok, err := r.Store.Load(ctx, job.ID); err == nil && ok && saved.Status == StatusSucceeded {
+			saved.Status = StatusSkipped
+			return saved
+		}
+	}
+
+	result := Result{JobID: job.ID, Status: StatusRunning, Started: time.Now()}
+	r.save(ctx, result)
+
+	backoff := r.Backoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	for {
+		result.Attempts++
+		msg, err := r.attempt(ctx, job)
+		if err == nil {
+			result.Status = StatusSucceeded
+			result.Result = msg
+			return r.finish(ctx, job, result)
+		}
+		result.Result = msg
+		result.Err = err
+
+		if result.Attempts > r.Retries || !r.retryable(ctx, err) {
+			result.Status = StatusFailed
+			return r.finish(ctx, job, result)
+		}
+
+		delay := backoff
+		if limit, ok := claude.AsRateLimitError(err); ok {
+			delay = max(delay, time.Until(limit.ResetAt))
+		}
+		backoff *= 2
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			result.Status = StatusFailed
+			result.Err = ctx.Err()
+			return r.finish(ctx, job, result)
+		}
+	}
+}
+
+// attempt runs job once and returns its result. A result reporting an
+// error fails the attempt.
+func (r *Runner) attempt(ctx context.Context, job *Job) (*claude.ResultMessage, error) {
+	query := r.Query
+	if query == nil {
+		query = claude.Query
+	}
+	opts := append(append([]claude.Option{}, r.Options...), job.Options...)
+
+	messages, errs := query(ctx, job.Prompt, opts...)
+	var result *claude.ResultMessage
+	for msg := range messages {
+		if job.OnMessage != nil {
+			job.OnMessage(job, msg)
+		}
+		if m, ok := msg.(*claude.ResultMessage); ok {
+			result = m
+		}
+	}
+	if err := <-errs; err != nil {
+		return result, err
+	}
+	if result == nil {
+		return nil, errors.New("no result received")
+	}
+	if result.IsError {
+		return result, fmt.Errorf("result reported an error: %s", result.Subtype)
+	}
+	return result, nil
+}
+
+// retryable reports whether an attempt failing with err is retried.
+func (r *Runner) retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if r.Retryable != nil {
+		return r.Retryable(err)
+	}
+	return !claude.IsCLINotFoundError(err)
+}
+
+// finish records the end of a job and reports it to OnDone.
+func (r *Runner) finish(ctx context.Context, job *Job, result Result) Result {
+	result.Finished = time.Now()
+	if result.Err != nil {
+		result.Error = result.Err.Error()
+	}
+	r.save(ctx, result)
+	if job.OnDone != nil {
+		job.OnDone(job, result)
+	}
+	return result
+}
+
+// save records result in the Store. Jobs keep running if it fails, as
+// losing their progress is better than failing them.
+func (r *Runner) save(ctx context.Context, result Result) {
+	if r.Store != nil {
+		_ = r.Store.Save(context.WithoutCancel(ctx), result)
+	}
+}
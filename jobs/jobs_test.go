@@ -0,0 +1,215 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	claude "github.com/afsharalex/claude-agent-sdk-go"
+)
+
+// fakeQuery answers each query with an assistant message and a result, or
+// fails it while fail returns an error for the prompt.
+type fakeQuery struct {
+	mu       sync.Mutex
+	calls    map[string]int
+	running  atomic.Int32
+	peak     atomic.Int32
+	fail     func(prompt string, call int) error
+	isError  bool
+	duration time.Duration
+}
+
+func (f *fakeQuery) query(ctx context.Context, prompt string, opts ...claude.Option) (<-chan claude.Message, <-chan error) {
+	f.mu.Lock()
+	if f.calls == nil {
+		f.calls = make(map[string]int)
+	}
+	f.calls[prompt]++
+	call := f.calls[prompt]
+	f.mu.Unlock()
+
+	messages := make(chan claude.Message, 2)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(messages)
+		defer close(errs)
+
+		running := f.running.Add(1)
+		defer f.running.Add(-1)
+		for {
+			peak := f.peak.Load()
+			if running <= peak || f.peak.CompareAndSwap(peak, running) {
+				break
+			}
+		}
+		select {
+		case <-time.After(f.duration):
+		case <-ctx.Done():
+			errs <- ctx.Err()
+			return
+		}
+
+		if f.fail != nil {
+			if err := f.fail(prompt, call); err != nil {
+				errs <- err
+				return
+			}
+		}
+		messages <- &claude.AssistantMessage{Content: []claude.ContentBlock{claude.TextBlock{Text: prompt}}}
+		messages <- &claude.ResultMessage{Subtype: "success", IsError: f.isError, Result: "done: " + prompt}
+	}()
+	return messages, errs
+}
+
+func (f *fakeQuery) callsFor(prompt string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[prompt]
+}
+
+func TestRunner_Run(t *testing.T) {
+	fake := &fakeQuery{duration: 20 * time.Millisecond}
+	runner := &Runner{Concurrency: 2, Query: fake.query}
+
+	var mu sync.Mutex
+	var seen, done []string
+	var jobs []*Job
+	for _, id := range []string{"a", "b", "c", "d"} {
+		jobs = append(jobs, &Job{
+			ID:     id,
+			Prompt: "prompt " + id,
+			OnMessage: func(job *Job, msg claude.Message) {
+				mu.Lock()
+				defer mu.Unlock()
+				seen = append(seen, job.ID)
+			},
+			OnDone: func(job *Job, result Result) {
+				mu.Lock()
+				defer mu.Unlock()
+				done = append(done, result.JobID)
+			},
+		})
+	}
+
+	results := runner.Run(context.Background(), jobs)
+	for i, result := range results {
+		if result.JobID != jobs[i].ID || result.Status != StatusSucceeded || result.Attempts != 1 {
+			t.Errorf("Unexpected result for %s: %+v", jobs[i].ID, result)
+		}
+		if result.Result == nil || result.Result.Result != "done: "+jobs[i].Prompt {
+			t.Errorf("Expected the result of %s, got %+v", jobs[i].ID, result.Result)
+		}
+		if result.Started.IsZero() || result.Finished.Before(result.Started) {
+			t.Errorf("Unexpected times for %s: %v to %v", jobs[i].ID, result.Started, result.Finished)
+		}
+	}
+	if peak := fake.peak.Load(); peak != 2 {
+		t.Errorf("Expected 2 jobs at once, got %d", peak)
+	}
+	if len(seen) != 8 || len(done) != 4 {
+		t.Errorf("Expected 8 messages and 4 completions, got %d and %d", len(seen), len(done))
+	}
+}
+
+func TestRunner_Retries(t *testing.T) {
+	fake := &fakeQuery{fail: func(prompt string, call int) error {
+		if call < 3 {
+			return errors.New("transient")
+		}
+		return nil
+	}}
+	runner := &Runner{Retries: 2, Backoff: time.Millisecond, Query: fake.query}
+
+	results := runner.Run(context.Background(), []*Job{{ID: "a", Prompt: "p"}})
+	if results[0].Status != StatusSucceeded || results[0].Attempts != 3 {
+		t.Errorf("Expected success on the third attempt, got %+v", results[0])
+	}
+}
+
+func TestRunner_RetriesExhausted(t *testing.T) {
+	fake := &fakeQuery{fail: func(string, int) error { return errors.New("broken") }}
+	runner := &Runner{Retries: 1, Backoff: time.Millisecond, Query: fake.query}
+
+	results := runner.Run(context.Background(), []*Job{{ID: "a", Prompt: "p"}})
+	if results[0].Status != StatusFailed || results[0].Attempts != 2 || results[0].Error != "broken" {
+		t.Errorf("Expected failure after 2 attempts, got %+v", results[0])
+	}
+}
+
+func TestRunner_NotRetryable(t *testing.T) {
+	fake := &fakeQuery{fail: func(string, int) error { return claude.NewCLINotFoundError("not found", "claude") }}
+	runner := &Runner{Retries: 3, Backoff: time.Millisecond, Query: fake.query}
+
+	results := runner.Run(context.Background(), []*Job{{ID: "a", Prompt: "p"}})
+	if results[0].Status != StatusFailed || results[0].Attempts != 1 || !claude.IsCLINotFoundError(results[0].Err) {
+		t.Errorf("Expected a single failed attempt, got %+v", results[0])
+	}
+
+	runner.Retryable = func(err error) bool { return true }
+	results = runner.Run(context.Background(), []*Job{{ID: "b", Prompt: "q"}})
+	if results[0].Attempts != 4 {
+		t.Errorf("Expected Retryable to allow retries, got %d attempts", results[0].Attempts)
+	}
+}
+
+func TestRunner_ErrorResult(t *testing.T) {
+	fake := &fakeQuery{isError: true}
+	runner := &Runner{Query: fake.query}
+
+	results := runner.Run(context.Background(), []*Job{{ID: "a", Prompt: "p"}})
+	if results[0].Status != StatusFailed || results[0].Result == nil {
+		t.Errorf("Expected an error result to fail the job, got %+v", results[0])
+	}
+}
+
+func TestRunner_Cancelled(t *testing.T) {
+	fake := &fakeQuery{duration: 50 * time.Millisecond}
+	runner := &Runner{Query: fake.query}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	results := runner.Run(ctx, []*Job{{ID: "a", Prompt: "p"}, {ID: "b", Prompt: "q"}})
+	for _, result := range results {
+		if result.Status != StatusFailed || !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("Expected %s to fail with cancellation, got %+v", result.JobID, result)
+		}
+	}
+	if results[1].Attempts != 0 || fake.callsFor("q") != 0 {
+		t.Error("Expected the queued job not to run")
+	}
+}
+
+func TestRunner_StoreResumes(t *testing.T) {
+	store := NewMemoryStore()
+	fake := &fakeQuery{fail: func(prompt string, call int) error {
+		if prompt == "q" && call == 1 {
+			return errors.New("broken")
+		}
+		return nil
+	}}
+	runner := &Runner{Store: store, Query: fake.query}
+	jobs := []*Job{{ID: "a", Prompt: "p"}, {ID: "b", Prompt: "q"}}
+
+	first := runner.Run(context.Background(), jobs)
+	if first[0].Status != StatusSucceeded || first[1].Status != StatusFailed {
+		t.Fatalf("Unexpected first run: %+v", first)
+	}
+	if saved, ok, _ := store.Load(context.Background(), "b"); !ok || saved.Status != StatusFailed || saved.Error != "broken" {
+		t.Errorf("Expected the failure to be saved, got %+v", saved)
+	}
+
+	second := runner.Run(context.Background(), jobs)
+	if second[0].Status != StatusSkipped || second[1].Status != StatusSucceeded {
+		t.Errorf("Unexpected second run: %+v", second)
+	}
+	if fake.callsFor("p") != 1 {
+		t.Errorf("Expected the succeeded job to run once, got %d", fake.callsFor("p"))
+	}
+}
@@ -0,0 +1,104 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store persists the outcome of jobs, so that a batch can be resumed and
+// its progress inspected. Save is called when a job starts and when it
+// finishes.
+type Store interface {
+	// Save records result under its JobID, replacing what was recorded.
+	Save(ctx context.Context, result Result) error
+	// Load returns what is recorded for a job, if anything.
+	Load(ctx context.Context, jobID string) (Result, bool, error)
+}
+
+// MemoryStore is a Store held in memory. It is safe for concurrent use.
+type MemoryStore struct {
+	mu      sync.Mutex
+	results map[string]Result
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{results: make(map[string]Result)}
+}
+
+// Save records result.
+func (s *MemoryStore) Save(ctx context.Context, result Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[result.JobID] = result
+	return nil
+}
+
+// Load returns the result recorded for jobID.
+func (s *MemoryStore) Load(ctx context.Context, jobID string) (Result, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result, ok := s.results[jobID]
+	return result, ok, nil
+}
+
+// FileStore is a Store keeping one JSON file per job in a directory.
+type FileStore struct {
+	// Dir is the directory the files are written to. It is created if
+	// needed.
+	Dir string
+}
+
+// Save writes result, replacing the file of the job atomically.
+func (s FileStore) Save(ctx context.Context, result Result) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0o700); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(s.Dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), s.path(result.JobID))
+}
+
+// Load reads the result recorded for jobID.
+func (s FileStore) Load(ctx context.Context, jobID string) (Result, bool, error) {
+	data, err := os.ReadFile(s.path(jobID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Result{}, false, nil
+	}
+	if err != nil {
+		return Result{}, false, err
+	}
+	var result Result
+	if err := json.Unmarshal(data, &result); err != nil {
+		return Result{}, false, err
+	}
+	return result, true, nil
+}
+
+// path returns the file of a job. IDs are escaped so that any ID names a
+// file within Dir.
+func (s FileStore) path(jobID string) string {
+	return filepath.Join(s.Dir, url.PathEscape(jobID)+".json")
+}
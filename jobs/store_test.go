@@ -0,0 +1,56 @@
+package jobs
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	claude "github.com/afsharalex/claude-agent-sdk-go"
+)
+
+func TestFileStore(t *testing.T) {
+	store := FileStore{Dir: t.TempDir()}
+	ctx := context.Background()
+
+	if _, ok, err := store.Load(ctx, "missing"); ok || err != nil {
+		t.Errorf("Expected nothing for a missing job, got %v %v", ok, err)
+	}
+
+	saved := Result{
+		JobID:    "repos/api",
+		Status:   StatusSucceeded,
+		Attempts: 2,
+		Result:   &claude.ResultMessage{Subtype: "success", Result: "done"},
+		Started:  time.Now().Truncate(time.Second),
+		Finished: time.Now().Truncate(time.Second),
+	}
+	if err := store.Save(ctx, saved); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, ok, err := store.Load(ctx, "repos/api")
+	if err != nil || !ok {
+		t.Fatalf("Load failed: %v %v", ok, err)
+	}
+	if loaded.Status != StatusSucceeded || loaded.Attempts != 2 || loaded.Result.Result != "done" || !loaded.Started.Equal(saved.Started) {
+		t.Errorf("Unexpected result: %+v", loaded)
+	}
+
+	// The ID is escaped, not used as a path
+	entries, _ := os.ReadDir(store.Dir)
+	if len(entries) != 1 || entries[0].IsDir() {
+		t.Errorf("Expected a single file, got %v", entries)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	_ = store.Save(ctx, Result{JobID: "a", Status: StatusRunning})
+	_ = store.Save(ctx, Result{JobID: "a", Status: StatusFailed})
+
+	if result, ok, _ := store.Load(ctx, "a"); !ok || result.Status != StatusFailed {
+		t.Errorf("Expected the latest result, got %+v", result)
+	}
+}
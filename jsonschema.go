@@ -0,0 +1,352 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidateJSONSchema checks value against a JSON schema and returns a
+// description of each violation, or nil if value is valid. Each
+// description starts with the JSON path of the offending value, such as
+// "$.items[0].name".
+//
+// The common validation keywords are supported: type, enum, const,
+// properties, required, additionalProperties, items, minItems, maxItems,
+// uniqueItems, minLength, maxLength, pattern, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum, multipleOf, allOf, anyOf, oneOf, not,
+// and local $ref. Other keywords, such as format, are ignored.
+func ValidateJSONSchema(schema map[string]any, value any) []string {
+	// Round trip through JSON so Go-typed schemas and values, such as
+	// []string or int, compare like decoded JSON
+	root, err := normalizeJSON(schema)
+	if err != nil {
+		return []string{"$: invalid schema: " + err.Error()}
+	}
+	normalized, err := normalizeJSON(value)
+	if err != nil {
+		return []string{"$: value is not JSON: " + err.Error()}
+	}
+
+	v := &schemaValidator{root: root}
+	v.validate(root, normalized, "$")
+	return v.errors
+}
+
+// normalizeJSON converts v to the types produced by decoding JSON.
+func normalizeJSON(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var normalized any
+	err = json.Unmarshal(data, &normalized)
+	return normalized, err
+}
+
+type schemaValidator struct {
+	root   any
+	errors []string
+	// depth guards against cyclic $ref chains.
+	depth int
+}
+
+func (v *schemaValidator) fail(path, format string, args ...any) {
+	v.errors = append(v.errors, path+": "+fmt.Sprintf(format, args...))
+}
+
+// matches reports whether value is valid against schema, without
+// recording errors.
+func (v *schemaValidator) matches(schema, value any, path string) bool {
+	sub := &schemaValidator{root: v.root, depth: v.depth}
+	sub.validate(schema, value, path)
+	return len(sub.errors) == 0
+}
+
+func (v *schemaValidator) validate(schemaValue, value any, path string) {
+	schema, ok := schemaValue.(map[string]any)
+	if !ok {
+		// Boolean schemas accept or reject everything
+		if accept, ok := schemaValue.(bool); ok && !accept {
+			v.fail(path, "no value is allowed")
+		}
+		return
+	}
+
+	if ref, ok := schema["$ref"].(string); ok {
+		target, found := v.resolve(ref)
+		if !found {
+			v.fail(path, "unresolved $ref %q", ref)
+			return
+		}
+		if v.depth > 64 {
+			v.fail(path, "$ref %q nests too deeply", ref)
+			return
+		}
+		v.depth++
+		v.validate(target, value, path)
+		v.depth--
+	}
+
+	if t, ok := schema["type"]; ok && !matchesType(t, value) {
+		v.fail(path, "expected %s, got %s", typeNames(t), jsonTypeName(value))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, allowed := range enum {
+			if reflect.DeepEqual(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, "value must be one of %s", formatJSON(enum))
+		}
+	}
+	if constant, ok := schema["const"]; ok && !reflect.DeepEqual(constant, value) {
+		v.fail(path, "value must be %s", formatJSON(constant))
+	}
+
+	switch val := value.(type) {
+	case map[string]any:
+		v.validateObject(schema, val, path)
+	case []any:
+		v.validateArray(schema, val, path)
+	case string:
+		v.validateString(schema, val, path)
+	case float64:
+		v.validateNumber(schema, val, path)
+	}
+
+	v.validateCombinators(schema, value, path)
+}
+
+func (v *schemaValidator) validateObject(schema, object map[string]any, path string) {
+	for _, name := range stringList(schema["required"]) {
+		if _, ok := object[name]; !ok {
+			v.fail(path, "missing required property %q", name)
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]any)
+	for _, name := range sortedKeys(object) {
+		propPath := path + "." + name
+		if propSchema, ok := properties[name]; ok {
+			v.validate(propSchema, object[name], propPath)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.fail(path, "unexpected property %q", name)
+			}
+		case map[string]any:
+			v.validate(additional, object[name], propPath)
+		}
+	}
+}
+
+func (v *schemaValidator) validateArray(schema map[string]any, array []any, path string) {
+	if n, ok := schema["minItems"].(float64); ok && float64(len(array)) < n {
+		v.fail(path, "expected at least %v items, got %d", n, len(array))
+	}
+	if n, ok := schema["maxItems"].(float64); ok && float64(len(array)) > n {
+		v.fail(path, "expected at most %v items, got %d", n, len(array))
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := range array {
+			for j := i + 1; j < len(array); j++ {
+				if reflect.DeepEqual(array[i], array[j]) {
+					v.fail(path, "items %d and %d are equal", i, j)
+				}
+			}
+		}
+	}
+	if items, ok := schema["items"]; ok {
+		for i, item := range array {
+			v.validate(items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+func (v *schemaValidator) validateString(schema map[string]any, s, path string) {
+	length := utf8.RuneCountInString(s)
+	if n, ok := schema["minLength"].(float64); ok && float64(length) < n {
+		v.fail(path, "expected at least %v characters, got %d", n, length)
+	}
+	if n, ok := schema["maxLength"].(float64); ok && float64(length) > n {
+		v.fail(path, "expected at most %v characters, got %d", n, length)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			v.fail(path, "invalid pattern %q: %v", pattern, err)
+		} else if !re.MatchString(s) {
+			v.fail(path, "value does not match pattern %q", pattern)
+		}
+	}
+}
+
+func (v *schemaValidator) validateNumber(schema map[string]any, n float64, path string) {
+	if limit, ok := schema["minimum"].(float64); ok && n < limit {
+		v.fail(path, "value %v is less than the minimum %v", n, limit)
+	}
+	if limit, ok := schema["maximum"].(float64); ok && n > limit {
+		v.fail(path, "value %v is greater than the maximum %v", n, limit)
+	}
+	if limit, ok := schema["exclusiveMinimum"].(float64); ok && n <= limit {
+		v.fail(path, "value %v must be greater than %v", n, limit)
+	}
+	if limit, ok := schema["exclusiveMaximum"].(float64); ok && n >= limit {
+		v.fail(path, "value %v must be less than %v", n, limit)
+	}
+	if m, ok := schema["multipleOf"].(float64); ok && m > 0 {
+		if q := n / m; math.Abs(q-math.Round(q)) > 1e-9 {
+			v.fail(path, "value %v is not a multiple of %v", n, m)
+		}
+	}
+}
+
+func (v *schemaValidator) validateCombinators(schema map[string]any, value any, path string) {
+	if all, ok := schema["allOf"].([]any); ok {
+		for _, sub := range all {
+			v.validate(sub, value, path)
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		matched := false
+		for _, sub := range anyOf {
+			if v.matches(sub, value, path) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(path, "value does not match any allowed schema")
+		}
+	}
+	if oneOf, ok := schema["oneOf"].([]any); ok {
+		count := 0
+		for _, sub := range oneOf {
+			if v.matches(sub, value, path) {
+				count++
+			}
+		}
+		if count != 1 {
+			v.fail(path, "value must match exactly one schema, matched %d", count)
+		}
+	}
+	if not, ok := schema["not"]; ok && v.matches(not, value, path) {
+		v.fail(path, "value must not match the schema")
+	}
+}
+
+// resolve finds the schema a local reference such as "#/$defs/item"
+// points to.
+func (v *schemaValidator) resolve(ref string) (any, bool) {
+	if ref == "#" {
+		return v.root, true
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, false
+	}
+
+	current := v.root
+	for _, part := range strings.Split(ref[2:], "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// matchesType reports whether value has one of the JSON types in t, a type
+// name or a list of them.
+func matchesType(t, value any) bool {
+	for _, name := range stringList(t) {
+		switch name {
+		case "integer":
+			if n, ok := value.(float64); ok && n == math.Trunc(n) {
+				return true
+			}
+		case "number":
+			if _, ok := value.(float64); ok {
+				return true
+			}
+		default:
+			if jsonTypeName(value) == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonTypeName returns the JSON type of a decoded value.
+func jsonTypeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func typeNames(t any) string {
+	return strings.Join(stringList(t), " or ")
+}
+
+// stringList returns a string, or the strings in a list.
+func stringList(v any) []string {
+	switch val := v.(type) {
+	case string:
+		return []string{val}
+	case []any:
+		var list []string
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,148 @@
+package claude
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateJSONSchema(t *testing.T) {
+	person := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"name": map[string]any{"type": "string", "minLength": 1},
+			"age":  map[string]any{"type": "integer", "minimum": 0},
+			"role": map[string]any{"enum": []string{"admin", "user"}},
+			"tags": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"maxItems":    2,
+				"uniqueItems": true,
+			},
+		},
+		"required":             []string{"name"},
+		"additionalProperties": false,
+	}
+
+	tests := []struct {
+		name   string
+		schema map[string]any
+		value  any
+		want   []string
+	}{
+		{
+			name:   "valid",
+			schema: person,
+			value:  map[string]any{"name": "Ada", "age": 36, "role": "admin", "tags": []string{"x"}},
+		},
+		{
+			name:   "wrong root type",
+			schema: person,
+			value:  []any{},
+			want:   []string{"$: expected object, got array"},
+		},
+		{
+			name:   "missing required and extra property",
+			schema: person,
+			value:  map[string]any{"age": 1.5, "nick": "A"},
+			want: []string{
+				`$: missing required property "name"`,
+				"$.age: expected integer, got number",
+				`$: unexpected property "nick"`,
+			},
+		},
+		{
+			name:   "nested violations",
+			schema: person,
+			value:  map[string]any{"name": "", "role": "guest", "tags": []any{"a", "a", 3}},
+			want: []string{
+				"$.name: expected at least 1 characters, got 0",
+				`$.role: value must be one of ["admin","user"]`,
+				"$.tags: expected at most 2 items, got 3",
+				"$.tags: items 0 and 1 are equal",
+				"$.tags[2]: expected string, got number",
+			},
+		},
+		{
+			name:   "numbers",
+			schema: map[string]any{"type": "number", "exclusiveMaximum": 10, "multipleOf": 0.5},
+			value:  10.25,
+			want: []string{
+				"$: value 10.25 must be less than 10",
+				"$: value 10.25 is not a multiple of 0.5",
+			},
+		},
+		{
+			name:   "pattern",
+			schema: map[string]any{"type": "string", "pattern": "^[a-z]+$"},
+			value:  "ABC",
+			want:   []string{`$: value does not match pattern "^[a-z]+$"`},
+		},
+		{
+			name:   "nullable type list",
+			schema: map[string]any{"type": []string{"string", "null"}},
+			value:  nil,
+		},
+		{
+			name: "anyOf and oneOf",
+			schema: map[string]any{
+				"anyOf": []any{map[string]any{"type": "string"}, map[string]any{"type": "integer"}},
+				"oneOf": []any{map[string]any{"type": "number"}, map[string]any{"minimum": 0}},
+			},
+			value: 5,
+			want:  []string{"$: value must match exactly one schema, matched 2"},
+		},
+		{
+			name:   "not and const",
+			schema: map[string]any{"not": map[string]any{"const": "x"}},
+			value:  "x",
+			want:   []string{"$: value must not match the schema"},
+		},
+		{
+			name: "ref",
+			schema: map[string]any{
+				"$defs": map[string]any{"id": map[string]any{"type": "string"}},
+				"type":  "array",
+				"items": map[string]any{"$ref": "#/$defs/id"},
+			},
+			value: []any{"a", true},
+			want:  []string{"$[1]: expected string, got boolean"},
+		},
+		{
+			name:   "unresolved ref",
+			schema: map[string]any{"$ref": "#/$defs/missing"},
+			value:  "a",
+			want:   []string{`$: unresolved $ref "#/$defs/missing"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ValidateJSONSchema(tt.schema, tt.value)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateJSONSchema_RecursiveRef(t *testing.T) {
+	tree := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"value":    map[string]any{"type": "integer"},
+			"children": map[string]any{"type": "array", "items": map[string]any{"$ref": "#"}},
+		},
+	}
+	value := map[string]any{
+		"value": 1,
+		"children": []any{
+			map[string]any{"value": 2},
+			map[string]any{"value": "three"},
+		},
+	}
+
+	want := []string{"$.children[1].value: expected integer, got string"}
+	if got := ValidateJSONSchema(tree, value); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,94 @@
+package claude
+
+import (
+	"time"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/transport"
+)
+
+// lifecycleBuffer is the number of lifecycle events a Client holds for a
+// reader of Events before dropping new ones.
+const lifecycleBuffer = 64
+
+// LifecycleEvent is an event in the life of the CLI process behind a
+// Client, received from Client.Events. It is one of ProcessStarted,
+// Initialized, ProcessExited, Reconnecting, or Closed.
+type LifecycleEvent interface {
+	lifecycleEvent()
+}
+
+// ProcessStarted is sent when a CLI process has been started.
+type ProcessStarted struct {
+	PID int
+}
+
+// Initialized is sent when the CLI has answered the initialize request of
+// the control protocol, and the Client is connected.
+type Initialized struct {
+	// InitResult is the response of the CLI to the initialize request.
+	InitResult map[string]any
+	// StartupTime is the time from starting the process to this event.
+	StartupTime time.Duration
+}
+
+// ProcessExited is sent when a CLI process has exited, whether on its own
+// or because the Client closed it.
+type ProcessExited struct {
+	PID int
+	// ExitCode is the exit code of the process, or -1 if it was ended by a
+	// signal, as when the Client kills it on Close, or is unknown, as for
+	// a process reconnected to over named pipes.
+	ExitCode int
+}
+
+// Reconnecting is sent before the Client starts a new CLI process for a
+// connection it ended itself: Reason is "rollback" for Rollback, and
+// "idle" for the first Connect after a connection was closed for being
+// idle. SessionID is the session the new process resumes.
+type Reconnecting struct {
+	Reason    string
+	SessionID string
+}
+
+// Closed is sent when Close has disconnected the Client, after the
+// ProcessExited of its process.
+type Closed struct{}
+
+func (ProcessStarted) lifecycleEvent() {}
+func (Initialized) lifecycleEvent()    {}
+func (ProcessExited) lifecycleEvent()  {}
+func (Reconnecting) lifecycleEvent()   {}
+func (Closed) lifecycleEvent()         {}
+
+// Events returns the channel on which the lifecycle events of c are sent,
+// in order, across every connection of c. The channel is never closed.
+//
+// Events are sent without blocking: they are held for the reader up to a
+// buffer of 64, beyond which new events are dropped. Reading is optional.
+func (c *Client) Events() <-chan LifecycleEvent {
+	return c.events
+}
+
+// emit sends event to Events, or drops it if the buffer is full.
+func (c *Client) emit(event LifecycleEvent) {
+	select {
+	case c.events <- event:
+	default:
+	}
+}
+
+// watchExit sends ProcessExited once the process of t has exited. The
+// returned channel is closed after that, or once t is detached from the
+// process.
+func (c *Client) watchExit(t *transport.SubprocessTransport) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		select {
+		case <-t.Exited():
+			c.emit(ProcessExited{PID: t.PID(), ExitCode: t.ExitCode()})
+		case <-t.Detached():
+		}
+	}()
+	return done
+}
@@ -0,0 +1,119 @@
+package claude
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// nextEvent returns the next lifecycle event of client, failing the test
+// if none comes.
+func nextEvent(t *testing.T, client *Client) LifecycleEvent {
+	t.Helper()
+	select {
+	case event := <-client.Events():
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a lifecycle event")
+		return nil
+	}
+}
+
+func TestClient_Events(t *testing.T) {
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{"commands":[]}}}'
+cat > /dev/null
+`)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	started, ok := nextEvent(t, client).(ProcessStarted)
+	if !ok || started.PID <= 0 {
+		t.Fatalf("Expected ProcessStarted with a PID, got %+v", started)
+	}
+	initialized, ok := nextEvent(t, client).(Initialized)
+	if !ok || initialized.InitResult["commands"] == nil || initialized.StartupTime <= 0 {
+		t.Fatalf("Expected Initialized with the init result, got %+v", initialized)
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	exited, ok := nextEvent(t, client).(ProcessExited)
+	if !ok || exited.PID != started.PID {
+		t.Fatalf("Expected ProcessExited of PID %d, got %+v", started.PID, exited)
+	}
+	if _, ok := nextEvent(t, client).(Closed); !ok {
+		t.Fatal("Expected Closed after ProcessExited")
+	}
+}
+
+func TestClient_EventsProcessCrash(t *testing.T) {
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+read line
+exit 3
+`)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.Query(ctx, "hello"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	nextEvent(t, client) // ProcessStarted
+	nextEvent(t, client) // Initialized
+	exited, ok := nextEvent(t, client).(ProcessExited)
+	if !ok || exited.ExitCode != 3 {
+		t.Fatalf("Expected ProcessExited with exit code 3, got %+v", exited)
+	}
+}
+
+func TestClient_EventsRollback(t *testing.T) {
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+cat > /dev/null
+`)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	client.turnMu.Lock()
+	client.marks = map[string]conversationMark{"start": {sessionID: "s1", messageUUID: "m1"}}
+	client.turnMu.Unlock()
+	if err := client.Rollback(ctx, "start"); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	var got []LifecycleEvent
+	for range 7 {
+		got = append(got, nextEvent(t, client))
+	}
+	if event, ok := got[4].(Reconnecting); !ok || event.Reason != "rollback" || event.SessionID != "s1" {
+		t.Errorf("Expected Reconnecting for the rollback after Closed, got %+v", got)
+	}
+	if _, ok := got[6].(Initialized); !ok {
+		t.Errorf("Expected the new process initialized, got %+v", got)
+	}
+}
@@ -0,0 +1,186 @@
+package claude
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// MessageID identifies a string the SDK writes for Claude or for users,
+// such as the guidance added to a denial or the text of a
+// DiagnosticEvent. Each is a fmt format string; the arguments it is given
+// are listed with its ID, and can be reordered with explicit indexes such
+// as %[2]s.
+type MessageID string
+
+const (
+	// MessageDenyUseTool is added to denials with an alternative tool:
+	// the tool name.
+	MessageDenyUseTool MessageID = "deny.use_tool"
+	// MessageDenyUseToolWithInput is added to denials with an alternative
+	// tool and input: the tool name and the input as JSON.
+	MessageDenyUseToolWithInput MessageID = "deny.use_tool_with_input"
+	// MessageDenySeeDocs is added to denials with a docs URL: the URL.
+	MessageDenySeeDocs MessageID = "deny.see_docs"
+	// MessageWorkspaceWriteDenied is the reason writes outside a
+	// Workspace are denied: the path.
+	MessageWorkspaceWriteDenied MessageID = "workspace.write_denied"
+	// MessageAutoContinueFollowUp reports a follow-up of WithAutoContinue:
+	// the follow-up number, the loop budget and the prompt.
+	MessageAutoContinueFollowUp MessageID = "auto_continue.follow_up"
+	// MessageAutoContinueLoopBudget reports a follow-up loop stopped at
+	// its budget: the number of follow-ups.
+	MessageAutoContinueLoopBudget MessageID = "auto_continue.loop_budget"
+	// MessageAutoContinueCostCeiling reports a follow-up loop stopped at
+	// its cost ceiling: the number of follow-ups, the cost and the
+	// ceiling in USD.
+	MessageAutoContinueCostCeiling MessageID = "auto_continue.cost_ceiling"
+	// MessageModelFallback reports a switch to a fallback model: the
+	// error, the failed model and the next model.
+	MessageModelFallback MessageID = "model.fallback"
+	// MessageStall reports a stalled turn: the time without messages.
+	MessageStall MessageID = "stall"
+	// MessageDryRunSimulated is the reason tool calls are denied in a dry
+	// run: the tool name.
+	MessageDryRunSimulated MessageID = "dry_run.simulated"
+	// MessageWebFetchDenied is the reason WebFetch calls outside the web
+	// domain policy are denied: the URL.
+	MessageWebFetchDenied MessageID = "web.fetch_denied"
+	// MessageWebSearchAllowedDomains is the reason WebSearch calls are
+	// denied for lacking allowed domains: the allowed domains.
+	MessageWebSearchAllowedDomains MessageID = "web.search_allowed_domains"
+	// MessageWebSearchBlockedDomains is the reason WebSearch calls are
+	// denied for not blocking the denied domains: the denied domains.
+	MessageWebSearchBlockedDomains MessageID = "web.search_blocked_domains"
+	// MessageContextUsage reports a context filled past a threshold: the
+	// percentage filled, the tokens in context and the context window.
+	MessageContextUsage MessageID = "context.usage"
+)
+
+// Catalog holds the format strings of a locale. Messages it lacks are
+// written in English.
+type Catalog map[MessageID]string
+
+// englishCatalog is the catalog of the default locale, "en".
+var englishCatalog = Catalog{
+	MessageDenyUseTool:             "Use the %[1]s tool instead.",
+	MessageDenyUseToolWithInput:    "Use the %[1]s tool instead, with input %[2]s.",
+	MessageDenySeeDocs:             "See %[1]s",
+	MessageWorkspaceWriteDenied:    "writing to %[1]s is denied: it is outside the workspace",
+	MessageAutoContinueFollowUp:    "follow-up %[1]d of %[2]d: %[3]s",
+	MessageAutoContinueLoopBudget:  "stopped after %[1]d follow-ups: loop budget reached",
+	MessageAutoContinueCostCeiling: "stopped after %[1]d follow-ups: cost $%.4[2]f reached the ceiling of $%.4[3]f",
+	MessageModelFallback:           "%[1]s from %[2]s; retrying with %[3]s",
+	MessageStall:                   "no messages received for %[1]s",
+	MessageDryRunSimulated:         "dry run: the %[1]s call was simulated, not run; continue as if it succeeded",
+	MessageWebFetchDenied:          "fetching %[1]s is denied: its domain is not allowed",
+	MessageWebSearchAllowedDomains: "searches are limited to the domains %[1]s: pass some of them as allowed_domains",
+	MessageWebSearchBlockedDomains: "searches must exclude the domains %[1]s: pass them as blocked_domains",
+	MessageContextUsage:            "context %.0[1]f%% full: %[2]d of %[3]d tokens",
+}
+
+var catalogs = struct {
+	sync.RWMutex
+	byTag map[string]Catalog
+}{byTag: map[string]Catalog{
+	"en": englishCatalog,
+	"de": {
+		MessageDenyUseTool:             "Verwende stattdessen das Tool %[1]s.",
+		MessageDenyUseToolWithInput:    "Verwende stattdessen das Tool %[1]s mit der Eingabe %[2]s.",
+		MessageDenySeeDocs:             "Siehe %[1]s",
+		MessageWorkspaceWriteDenied:    "Schreiben nach %[1]s ist verboten: der Pfad liegt außerhalb des Arbeitsbereichs",
+		MessageAutoContinueFollowUp:    "Folgeanfrage %[1]d von %[2]d: %[3]s",
+		MessageAutoContinueLoopBudget:  "nach %[1]d Folgeanfragen beendet: Limit erreicht",
+		MessageAutoContinueCostCeiling: "nach %[1]d Folgeanfragen beendet: Kosten von $%.4[2]f erreichen die Obergrenze von $%.4[3]f",
+		MessageModelFallback:           "%[1]s von %[2]s; neuer Versuch mit %[3]s",
+		MessageStall:                   "seit %[1]s keine Nachrichten empfangen",
+		MessageDryRunSimulated:         "Probelauf: der Aufruf von %[1]s wurde simuliert, nicht ausgeführt; fahre fort, als wäre er gelungen",
+		MessageWebFetchDenied:          "Abrufen von %[1]s ist verboten: die Domain ist nicht erlaubt",
+		MessageWebSearchAllowedDomains: "Suchen sind auf die Domains %[1]s beschränkt: gib einige davon als allowed_domains an",
+		MessageWebSearchBlockedDomains: "Suchen müssen die Domains %[1]s ausschließen: gib sie als blocked_domains an",
+		MessageContextUsage:            "Kontext zu %.0[1]f%% gefüllt: %[2]d von %[3]d Tokens",
+	},
+	"es": {
+		MessageDenyUseTool:             "Usa la herramienta %[1]s en su lugar.",
+		MessageDenyUseToolWithInput:    "Usa la herramienta %[1]s en su lugar, con la entrada %[2]s.",
+		MessageDenySeeDocs:             "Consulta %[1]s",
+		MessageWorkspaceWriteDenied:    "no se permite escribir en %[1]s: está fuera del espacio de trabajo",
+		MessageAutoContinueFollowUp:    "seguimiento %[1]d de %[2]d: %[3]s",
+		MessageAutoContinueLoopBudget:  "detenido tras %[1]d seguimientos: límite alcanzado",
+		MessageAutoContinueCostCeiling: "detenido tras %[1]d seguimientos: el coste de $%.4[2]f alcanzó el máximo de $%.4[3]f",
+		MessageModelFallback:           "%[1]s de %[2]s; reintentando con %[3]s",
+		MessageStall:                   "no se han recibido mensajes en %[1]s",
+		MessageDryRunSimulated:         "simulacro: la llamada a %[1]s se simuló, no se ejecutó; continúa como si hubiera funcionado",
+		MessageWebFetchDenied:          "no se permite acceder a %[1]s: su dominio no está permitido",
+		MessageWebSearchAllowedDomains: "las búsquedas se limitan a los dominios %[1]s: indica algunos de ellos en allowed_domains",
+		MessageWebSearchBlockedDomains: "las búsquedas deben excluir los dominios %[1]s: indícalos en blocked_domains",
+		MessageContextUsage:            "contexto lleno al %.0[1]f%%: %[2]d de %[3]d tokens",
+	},
+	"fr": {
+		MessageDenyUseTool:             "Utilise plutôt l'outil %[1]s.",
+		MessageDenyUseToolWithInput:    "Utilise plutôt l'outil %[1]s, avec l'entrée %[2]s.",
+		MessageDenySeeDocs:             "Voir %[1]s",
+		MessageWorkspaceWriteDenied:    "l'écriture dans %[1]s est refusée : le chemin est hors de l'espace de travail",
+		MessageAutoContinueFollowUp:    "relance %[1]d sur %[2]d : %[3]s",
+		MessageAutoContinueLoopBudget:  "arrêté après %[1]d relances : limite atteinte",
+		MessageAutoContinueCostCeiling: "arrêté après %[1]d relances : le coût de $%.4[2]f atteint le plafond de $%.4[3]f",
+		MessageModelFallback:           "%[1]s de %[2]s ; nouvel essai avec %[3]s",
+		MessageStall:                   "aucun message reçu depuis %[1]s",
+		MessageDryRunSimulated:         "simulation : l'appel à %[1]s a été simulé, pas exécuté ; continue comme s'il avait réussi",
+		MessageWebFetchDenied:          "l'accès à %[1]s est refusé : son domaine n'est pas autorisé",
+		MessageWebSearchAllowedDomains: "les recherches sont limitées aux domaines %[1]s : indique certains d'entre eux dans allowed_domains",
+		MessageWebSearchBlockedDomains: "les recherches doivent exclure les domaines %[1]s : indique-les dans blocked_domains",
+		MessageContextUsage:            "contexte rempli à %.0[1]f %% : %[2]d jetons sur %[3]d",
+	},
+}}
+
+// RegisterCatalog adds the messages of catalog to the locale tag, a BCP
+// 47 language tag such as "pt-BR", replacing those it already has. The
+// SDK includes catalogs for "en", "de", "es" and "fr".
+//
+// Example:
+//
+//	claude.RegisterCatalog("pt", claude.Catalog{
+//	    claude.MessageDenyUseTool: "Use a ferramenta %[1]s em vez disso.",
+//	    claude.MessageDenySeeDocs: "Veja %[1]s",
+//	})
+func RegisterCatalog(tag string, catalog Catalog) {
+	tag = normalizeTag(tag)
+	catalogs.Lock()
+	defer catalogs.Unlock()
+	merged := Catalog{}
+	for id, format := range catalogs.byTag[tag] {
+		merged[id] = format
+	}
+	for id, format := range catalog {
+		merged[id] = format
+	}
+	catalogs.byTag[tag] = merged
+}
+
+// localize formats the message id in the locale tag. A message missing
+// from the catalog of tag is looked up in that of its language, such as
+// "pt" for "pt-BR", and then in English.
+func localize(tag string, id MessageID, args ...any) string {
+	format := englishCatalog[id]
+	catalogs.RLock()
+	for tag = normalizeTag(tag); tag != ""; {
+		if f, ok := catalogs.byTag[tag][id]; ok {
+			format = f
+			break
+		}
+		i := strings.LastIndex(tag, "-")
+		if i < 0 {
+			break
+		}
+		tag = tag[:i]
+	}
+	catalogs.RUnlock()
+	return fmt.Sprintf(format, args...)
+}
+
+// normalizeTag returns tag in lower case with "-" separators, so that
+// "pt_BR" and "pt-br" name the same locale.
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+}
@@ -0,0 +1,107 @@
+package claude
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/types"
+)
+
+// catalogArgs are sample arguments for each message.
+var catalogArgs = map[MessageID][]any{
+	MessageDenyUseTool:             {ToolGrep},
+	MessageDenyUseToolWithInput:    {ToolGrep, `{"pattern":"TODO"}`},
+	MessageDenySeeDocs:             {"https://example.com"},
+	MessageWorkspaceWriteDenied:    {"/etc/passwd"},
+	MessageAutoContinueFollowUp:    {1, 3, "Keep going"},
+	MessageAutoContinueLoopBudget:  {3},
+	MessageAutoContinueCostCeiling: {2, 1.5, 1.0},
+	MessageModelFallback:           {AssistantMessageErrorRateLimit, "opus", "sonnet"},
+	MessageStall:                   {30 * time.Second},
+	MessageDryRunSimulated:         {ToolWrite},
+	MessageWebFetchDenied:          {"https://example.com/a"},
+	MessageWebSearchAllowedDomains: {"go.dev, github.com"},
+	MessageWebSearchBlockedDomains: {"example.com"},
+	MessageContextUsage:            {81.5, 163000, 200000},
+}
+
+func TestCatalogs_Complete(t *testing.T) {
+	for _, tag := range []string{"en", "de", "es", "fr"} {
+		for id, args := range catalogArgs {
+			format, ok := catalogs.byTag[tag][id]
+			if !ok {
+				t.Errorf("Catalog %q lacks %q", tag, id)
+				continue
+			}
+			if got := localize(tag, id, args...); strings.Contains(got, "%!") {
+				t.Errorf("Catalog %q: %q does not format its arguments: %q", tag, format, got)
+			}
+		}
+	}
+	if len(englishCatalog) != len(catalogArgs) {
+		t.Errorf("Expected sample arguments for each of the %d messages", len(englishCatalog))
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	RegisterCatalog("x-test", Catalog{MessageDenySeeDocs: "Voir %[1]s"})
+	RegisterCatalog("X_Test", Catalog{MessageStall: "silence depuis %[1]s"})
+
+	tests := []struct {
+		tag  string
+		id   MessageID
+		args []any
+		want string
+	}{
+		{"", MessageDenySeeDocs, []any{"u"}, "See u"},
+		{"de", MessageDenyUseTool, []any{ToolGrep}, "Verwende stattdessen das Tool Grep."},
+		{"de-AT", MessageDenyUseTool, []any{ToolGrep}, "Verwende stattdessen das Tool Grep."},
+		{"x-test", MessageDenySeeDocs, []any{"u"}, "Voir u"},
+		// Catalogs registered for the same tag are merged
+		{"x-test", MessageStall, []any{time.Second}, "silence depuis 1s"},
+		// Messages missing from a catalog are in English
+		{"x-test", MessageDenyUseTool, []any{ToolGrep}, "Use the Grep tool instead."},
+		{"zz", MessageDenySeeDocs, []any{"u"}, "See u"},
+		{"en", MessageAutoContinueCostCeiling, []any{2, 1.5, 1.0}, "stopped after 2 follow-ups: cost $1.5000 reached the ceiling of $1.0000"},
+	}
+	for _, tt := range tests {
+		if got := localize(tt.tag, tt.id, tt.args...); got != tt.want {
+			t.Errorf("localize(%q, %q): expected %q, got %q", tt.tag, tt.id, tt.want, got)
+		}
+	}
+}
+
+func TestWithLocale(t *testing.T) {
+	opts := NewOptions(WithLocale("es"))
+	if opts.Locale != "es" {
+		t.Errorf("Expected Locale to be set, got %q", opts.Locale)
+	}
+
+	fn := func(ctx context.Context, toolName string, input map[string]any, permCtx ToolPermissionContext) (PermissionResult, error) {
+		return DenyWithGuidance("Bash está desactivado", DenyGuidance{AlternativeTool: ToolGlob}), nil
+	}
+	result, err := toInternalCanUseTool(fn, opts.Locale)(context.Background(), "Bash", map[string]any{}, types.ToolPermissionContext{})
+	if deny, ok := result.(types.PermissionResultDeny); err != nil || !ok || deny.Message != "Bash está desactivado\n\nUsa la herramienta Glob en su lugar." {
+		t.Errorf("Expected the guidance in Spanish, got %+v, %v", result, err)
+	}
+
+	a := newAutoContinue(NewOptions(WithLocale("fr"), WithAutoContinue(func(*ResultMessage) (string, bool) { return "Continue", true })))
+	if _, event := a.next(&ResultMessage{}); event == nil || event.Message != "relance 1 sur 10 : Continue" {
+		t.Errorf("Expected the follow-up reported in French, got %+v", event)
+	}
+}
+
+func TestWithLocale_Workspace(t *testing.T) {
+	opts := NewOptions(WithWorkspace(&Workspace{Dir: t.TempDir()}), WithLocale("de"))
+	hook := opts.Hooks[HookEventPreToolUse][0].Hooks[0]
+	out, err := hook(context.Background(), PreToolUseHookInput{ToolName: "Write", ToolInput: map[string]any{"file_path": "/etc/passwd"}}, "", HookContext{})
+	if err != nil {
+		t.Fatalf("Hook failed: %v", err)
+	}
+	specific, _ := out.HookSpecificOutput.(PreToolUseHookSpecificOutput)
+	if !strings.HasPrefix(specific.PermissionDecisionReason, "Schreiben nach /etc/passwd ist verboten") {
+		t.Errorf("Expected the reason in the locale set after the workspace, got %q", specific.PermissionDecisionReason)
+	}
+}
@@ -1,5 +1,11 @@
 package claude
 
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
 // Tool is a convenience function for creating an MCPTool.
 // This provides a pattern similar to the Python SDK's @tool decorator.
 //
@@ -101,3 +107,63 @@ func ImageResult(data, mimeType string) MCPToolResult {
 		}},
 	}
 }
+
+// JSONResult creates an MCPToolResult with v as structured content, which
+// Claude can reason over without parsing text. The JSON encoding of v is
+// also returned as text content, for clients that do not read structured
+// content. Values that do not encode to a JSON object, such as slices, are
+// wrapped in an object under "result".
+//
+// Example:
+//
+//	return claude.JSONResult(map[string]any{"temperature": 21.5, "unit": "C"})
+func JSONResult(v any) (MCPToolResult, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return MCPToolResult{}, fmt.Errorf("encoding tool result: %w", err)
+	}
+
+	// Decode to plain JSON values, as structured content read from a
+	// server would be
+	var decoded any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return MCPToolResult{}, fmt.Errorf("encoding tool result: %w", err)
+	}
+	structured, ok := decoded.(map[string]any)
+	if !ok {
+		structured = map[string]any{"result": decoded}
+		data, _ = json.Marshal(structured)
+	}
+	return MCPToolResult{
+		Content:    []MCPContent{{Type: "text", Text: string(data)}},
+		Structured: structured,
+	}, nil
+}
+
+// checkInput returns the violations of the input schema of tool by args,
+// if s validates inputs.
+func (s *MCPServer) checkInput(tool MCPTool, args map[string]any) []string {
+	if !s.validateInputs || tool.InputSchema == nil {
+		return nil
+	}
+	if args == nil {
+		args = map[string]any{}
+	}
+	return ValidateJSONSchema(tool.InputSchema, args)
+}
+
+// invalidInputResult is the error result of a call to tool with arguments
+// that violate its input schema.
+func invalidInputResult(tool string, violations []string) MCPToolResult {
+	return MCPToolResult{
+		Content: []MCPContent{{
+			Type: "text",
+			Text: fmt.Sprintf("Invalid arguments for tool %s:\n- %s", tool, strings.Join(violations, "\n- ")),
+		}},
+		IsError: true,
+		Structured: map[string]any{
+			"error":      "invalid_arguments",
+			"violations": violations,
+		},
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 )
 
@@ -300,8 +301,90 @@ func TestImageResult_DifferentMimeTypes(t *testing.T) {
 	}
 }
 
+func TestJSONResult(t *testing.T) {
+	type forecast struct {
+		City        string  `json:"city"`
+		Temperature float64 `json:"temperature"`
+	}
+
+	result, err := JSONResult(forecast{City: "Oslo", Temperature: 21.5})
+	if err != nil {
+		t.Fatalf("JSONResult failed: %v", err)
+	}
+	structured, ok := result.Structured.(map[string]any)
+	if !ok || structured["city"] != "Oslo" || structured["temperature"] != 21.5 {
+		t.Errorf("Unexpected structured content %#v", result.Structured)
+	}
+	if len(result.Content) != 1 || result.Content[0].Text != `{"city":"Oslo","temperature":21.5}` {
+		t.Errorf("Expected JSON text content, got %+v", result.Content)
+	}
+}
+
+func TestJSONResult_NotAnObject(t *testing.T) {
+	result, err := JSONResult([]int{1, 2})
+	if err != nil {
+		t.Fatalf("JSONResult failed: %v", err)
+	}
+	structured, _ := result.Structured.(map[string]any)
+	if items, ok := structured["result"].([]any); !ok || len(items) != 2 {
+		t.Errorf("Expected slice wrapped under result, got %#v", result.Structured)
+	}
+	if result.Content[0].Text != `{"result":[1,2]}` {
+		t.Errorf("Unexpected text %s", result.Content[0].Text)
+	}
+
+	if _, err := JSONResult(make(chan int)); err == nil {
+		t.Error("Expected error for a value that cannot be encoded")
+	}
+}
+
 // Benchmark tests
 
+func TestMCPServer_ValidateInputs(t *testing.T) {
+	calls := 0
+	tool := Tool("greet", "Greet someone", map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"name": map[string]any{"type": "string"}},
+		"required":   []string{"name"},
+	}, func(ctx context.Context, args map[string]any) (MCPToolResult, error) {
+		calls++
+		return TextResult("Hello, " + args["name"].(string)), nil
+	})
+
+	server := toInternalMCPServer(NewMCPServer("greeter", "1.0.0", []MCPTool{tool}).ValidateInputs(true))
+	handler := server.Tools[0].Handler
+
+	result, err := handler(context.Background(), map[string]any{"name": 42.0})
+	if err != nil {
+		t.Fatalf("Expected an error result rather than an error, got %v", err)
+	}
+	if !result.IsError || calls != 0 {
+		t.Fatalf("Expected an error result without calling the handler, got %+v after %d calls", result, calls)
+	}
+	if want := "Invalid arguments for tool greet:\n- $.name: "; len(result.Content) != 1 || !strings.HasPrefix(result.Content[0].Text, want) {
+		t.Errorf("Expected the violations in the text, got %+v", result.Content)
+	}
+	structured, _ := result.Structured.(map[string]any)
+	if structured["error"] != "invalid_arguments" || len(structured["violations"].([]string)) != 1 {
+		t.Errorf("Expected structured violations, got %v", result.Structured)
+	}
+
+	if _, err := handler(context.Background(), nil); err != nil || calls != 0 {
+		t.Errorf("Expected missing arguments to be rejected, got %v after %d calls", err, calls)
+	}
+
+	result, err = handler(context.Background(), map[string]any{"name": "Ada"})
+	if err != nil || result.IsError || calls != 1 {
+		t.Errorf("Expected valid arguments to reach the handler, got %+v, %v", result, err)
+	}
+
+	// Without validation, arguments go straight to the handler
+	unchecked := toInternalMCPServer(NewMCPServer("greeter", "1.0.0", []MCPTool{tool}))
+	if _, err := unchecked.Tools[0].Handler(context.Background(), map[string]any{"name": "Bo"}); err != nil || calls != 2 {
+		t.Errorf("Expected the handler to be called, got %v after %d calls", err, calls)
+	}
+}
+
 func BenchmarkSimpleInputSchema(b *testing.B) {
 	fields := map[string]string{
 		"name":   "string",
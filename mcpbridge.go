@@ -0,0 +1,305 @@
+package claude
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// MCPMessageHandler sends one JSON-RPC message to an MCP server and
+// returns its response. The response to a notification is ignored.
+//
+// It bridges MCP servers written with other Go MCP libraries. With
+// mark3labs/mcp-go:
+//
+//	handler := func(ctx context.Context, message json.RawMessage) (json.RawMessage, error) {
+//		return json.Marshal(mcpServer.HandleMessage(ctx, message))
+//	}
+//
+// Servers that are run on a transport, such as those of the official MCP
+// Go SDK, can be bridged with NewMCPStreamHandler.
+type MCPMessageHandler func(ctx context.Context, message json.RawMessage) (json.RawMessage, error)
+
+// WrapMCPServer creates an in-process SDK MCP server from an MCP server
+// implemented with another Go MCP library, so that its tools can be
+// given to Claude without declaring each of them again with Tool.
+//
+// It initializes the server through handler and lists its tools. Calls
+// to the returned server's tools are forwarded to handler.
+//
+// Example:
+//
+//	weather, err := claude.WrapMCPServer(ctx, "weather", handler)
+//	if err != nil {
+//		return err
+//	}
+//	client := claude.NewClient(claude.WithMCPServers(map[string]claude.MCPServerConfig{
+//		"weather": weather,
+//	}))
+func WrapMCPServer(ctx context.Context, name string, handler MCPMessageHandler) (MCPSDKServerConfig, error) {
+	bridge := &mcpBridge{handler: handler}
+
+	var initialized struct {
+		ServerInfo struct {
+			Version string `json:"version"`
+		} `json:"serverInfo"`
+	}
+	err := bridge.call(ctx, "initialize", map[string]any{
+		"protocolVersion": "2024-11-05",
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "claude-agent-sdk-go", "version": Version},
+	}, &initialized)
+	if err != nil {
+		return MCPSDKServerConfig{}, fmt.Errorf("initializing MCP server %s: %w", name, err)
+	}
+	if err := bridge.notify(ctx, "notifications/initialized"); err != nil {
+		return MCPSDKServerConfig{}, fmt.Errorf("initializing MCP server %s: %w", name, err)
+	}
+
+	var tools []MCPTool
+	cursor := ""
+	for {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var page struct {
+			Tools []struct {
+				Name        string         `json:"name"`
+				Description string         `json:"description"`
+				InputSchema map[string]any `json:"inputSchema"`
+			} `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		if err := bridge.call(ctx, "tools/list", params, &page); err != nil {
+			return MCPSDKServerConfig{}, fmt.Errorf("listing tools of MCP server %s: %w", name, err)
+		}
+		for _, t := range page.Tools {
+			tools = append(tools, Tool(t.Name, t.Description, t.InputSchema, bridge.toolHandler(t.Name)))
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	return MCPSDKServerConfig{
+		Type:   "sdk",
+		Name:   name,
+		Server: NewMCPServer(name, initialized.ServerInfo.Version, tools),
+	}, nil
+}
+
+// mcpBridge sends JSON-RPC requests through an MCPMessageHandler.
+type mcpBridge struct {
+	handler MCPMessageHandler
+	nextID  atomic.Int64
+}
+
+// call sends a request and decodes its result into result.
+func (b *mcpBridge) call(ctx context.Context, method string, params any, result any) error {
+	request, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      b.nextID.Add(1),
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := b.handler(ctx, request)
+	if err != nil {
+		return err
+	}
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return fmt.Errorf("invalid response to %s: %w", method, err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("%s failed: %s (code %d)", method, response.Error.Message, response.Error.Code)
+	}
+	if len(response.Result) == 0 {
+		return fmt.Errorf("no result in response to %s", method)
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+// notify sends a notification.
+func (b *mcpBridge) notify(ctx context.Context, method string) error {
+	notification, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "method": method})
+	_, err := b.handler(ctx, notification)
+	return err
+}
+
+// toolHandler forwards calls of a tool to the server.
+func (b *mcpBridge) toolHandler(name string) MCPToolHandler {
+	return func(ctx context.Context, args map[string]any) (MCPToolResult, error) {
+		var result struct {
+			Content    []json.RawMessage `json:"content"`
+			IsError    bool              `json:"isError"`
+			Structured map[string]any    `json:"structuredContent"`
+		}
+		err := b.call(ctx, "tools/call", map[string]any{"name": name, "arguments": args}, &result)
+		if err != nil {
+			return MCPToolResult{}, err
+		}
+
+		toolResult := MCPToolResult{IsError: result.IsError}
+		if result.Structured != nil {
+			toolResult.Structured = result.Structured
+		}
+		for _, raw := range result.Content {
+			var content MCPContent
+			if err := json.Unmarshal(raw, &content); err != nil {
+				return MCPToolResult{}, err
+			}
+			if content.Type != "text" && content.Type != "image" && content.Type != "resource_link" {
+				// Content types the SDK cannot represent are passed on
+				// as their JSON
+				content = MCPContent{Type: "text", Text: string(raw)}
+			}
+			toolResult.Content = append(toolResult.Content, content)
+		}
+		return toolResult, nil
+	}
+}
+
+// NewMCPStreamHandler returns an MCPMessageHandler that talks to an MCP
+// server over a stream of newline-delimited JSON-RPC messages, such as a
+// pair of io.Pipe connected to a server of the official MCP Go SDK:
+//
+//	serverIn, clientOut := io.Pipe()
+//	clientIn, serverOut := io.Pipe()
+//	go server.Run(ctx, &mcp.IOTransport{Reader: serverIn, Writer: serverOut})
+//	handler := claude.NewMCPStreamHandler(clientIn, clientOut)
+//
+// Requests and notifications from the server are ignored. Handling stops
+// when r returns an error.
+func NewMCPStreamHandler(r io.Reader, w io.Writer) MCPMessageHandler {
+	stream := &mcpStream{
+		reader:  r,
+		writer:  w,
+		pending: make(map[string]chan json.RawMessage),
+		done:    make(chan struct{}),
+	}
+	return stream.send
+}
+
+// mcpStream matches responses read from a stream to the requests sent.
+type mcpStream struct {
+	reader io.Reader
+	start  sync.Once
+
+	// writeMu serializes writes, which can block until the server reads
+	writeMu sync.Mutex
+	writer  io.Writer
+
+	mu      sync.Mutex
+	pending map[string]chan json.RawMessage
+	err     error
+	done    chan struct{}
+}
+
+// send writes message and, if it is a request, waits for its response.
+func (s *mcpStream) send(ctx context.Context, message json.RawMessage) (json.RawMessage, error) {
+	s.start.Do(func() { go s.read() })
+
+	var envelope struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(message, &envelope); err != nil {
+		return nil, err
+	}
+
+	var response chan json.RawMessage
+	id := mcpMessageID(envelope.ID)
+	if id != "" {
+		response = make(chan json.RawMessage, 1)
+		s.mu.Lock()
+		if s.err != nil {
+			s.mu.Unlock()
+			return nil, s.err
+		}
+		s.pending[id] = response
+		s.mu.Unlock()
+		defer func() {
+			s.mu.Lock()
+			delete(s.pending, id)
+			s.mu.Unlock()
+		}()
+	}
+
+	s.writeMu.Lock()
+	_, err := s.writer.Write(append(bytes.Clone(message), '\n'))
+	s.writeMu.Unlock()
+	if err != nil || response == nil {
+		return nil, err
+	}
+
+	select {
+	case data := <-response:
+		return data, nil
+	case <-s.done:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return nil, s.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// read delivers responses to the requests waiting for them.
+func (s *mcpStream) read() {
+	scanner := bufio.NewScanner(s.reader)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var envelope struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &envelope) != nil || envelope.Method != "" {
+			// Requests and notifications from the server
+			continue
+		}
+		id := mcpMessageID(envelope.ID)
+
+		s.mu.Lock()
+		if response, ok := s.pending[id]; ok {
+			response <- bytes.Clone(scanner.Bytes())
+			delete(s.pending, id)
+		}
+		s.mu.Unlock()
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+	s.mu.Lock()
+	s.err = fmt.Errorf("MCP server stream closed: %w", err)
+	s.mu.Unlock()
+	close(s.done)
+}
+
+// mcpMessageID returns a JSON-RPC id as a string, so that a numeric id
+// matches one echoed back as a string.
+func mcpMessageID(raw json.RawMessage) string {
+	id := string(raw)
+	if unquoted, err := strconv.Unquote(id); err == nil {
+		return unquoted
+	}
+	return id
+}
@@ -0,0 +1,176 @@
+package claude
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/protocol"
+)
+
+// fakeMCPLibraryServer stands in for a server of another MCP library:
+// it answers JSON-RPC messages for a server built with this SDK.
+func fakeMCPLibraryServer() MCPMessageHandler {
+	server := toInternalMCPServer(NewMCPServer("weather", "2.1.0", []MCPTool{
+		Tool("forecast", "Get the forecast", SimpleInputSchema(map[string]string{"city": "string"}),
+			func(ctx context.Context, args map[string]any) (MCPToolResult, error) {
+				return TextResult("Sunny in " + args["city"].(string)), nil
+			}),
+		Tool("alerts", "Get weather alerts", nil,
+			func(ctx context.Context, args map[string]any) (MCPToolResult, error) {
+				return ErrorResult("alerts unavailable"), nil
+			}),
+		Tool("stats", "Get weather statistics", nil,
+			func(ctx context.Context, args map[string]any) (MCPToolResult, error) {
+				return JSONResult(map[string]any{"rainy_days": 12})
+			}),
+	}))
+	return func(ctx context.Context, message json.RawMessage) (json.RawMessage, error) {
+		var request map[string]any
+		if err := json.Unmarshal(message, &request); err != nil {
+			return nil, err
+		}
+		return json.Marshal(protocol.HandleMCPMessage(ctx, server, request))
+	}
+}
+
+func TestWrapMCPServer(t *testing.T) {
+	config, err := WrapMCPServer(context.Background(), "weather", fakeMCPLibraryServer())
+	if err != nil {
+		t.Fatalf("WrapMCPServer failed: %v", err)
+	}
+	if config.Type != "sdk" || config.Name != "weather" || config.Server.Version() != "2.1.0" {
+		t.Errorf("Unexpected config: %+v", config)
+	}
+
+	tools := config.Server.Tools()
+	if len(tools) != 3 || tools[0].Name != "forecast" || tools[0].Description != "Get the forecast" {
+		t.Fatalf("Unexpected tools: %+v", tools)
+	}
+	if tools[0].InputSchema["type"] != "object" {
+		t.Errorf("Expected input schema to be kept, got %v", tools[0].InputSchema)
+	}
+
+	result, err := tools[0].Handler(context.Background(), map[string]any{"city": "Oslo"})
+	if err != nil || len(result.Content) != 1 || result.Content[0].Text != "Sunny in Oslo" {
+		t.Errorf("Unexpected forecast result: %+v, %v", result, err)
+	}
+
+	result, err = tools[1].Handler(context.Background(), nil)
+	if err != nil || !result.IsError {
+		t.Errorf("Expected error result, got %+v, %v", result, err)
+	}
+
+	result, err = tools[2].Handler(context.Background(), nil)
+	if structured, ok := result.Structured.(map[string]any); err != nil || !ok || structured["rainy_days"] != float64(12) {
+		t.Errorf("Expected structured content, got %+v, %v", result, err)
+	}
+}
+
+func TestWrapMCPServer_PaginationAndContent(t *testing.T) {
+	handler := func(ctx context.Context, message json.RawMessage) (json.RawMessage, error) {
+		var request struct {
+			ID     int            `json:"id"`
+			Method string         `json:"method"`
+			Params map[string]any `json:"params"`
+		}
+		_ = json.Unmarshal(message, &request)
+
+		var result any
+		switch request.Method {
+		case "initialize":
+			result = map[string]any{"serverInfo": map[string]any{"name": "docs", "version": "1"}}
+		case "tools/list":
+			if request.Params["cursor"] == "page2" {
+				result = map[string]any{"tools": []any{map[string]any{"name": "read"}}}
+			} else {
+				result = map[string]any{"tools": []any{map[string]any{"name": "search"}}, "nextCursor": "page2"}
+			}
+		case "tools/call":
+			if request.Params["name"] == "read" {
+				return json.Marshal(map[string]any{"jsonrpc": "2.0", "id": request.ID,
+					"error": map[string]any{"code": -32602, "message": "missing path"}})
+			}
+			result = map[string]any{"content": []any{
+				map[string]any{"type": "resource", "resource": map[string]any{"uri": "file:///a.md"}},
+			}}
+		default:
+			return json.Marshal(nil)
+		}
+		return json.Marshal(map[string]any{"jsonrpc": "2.0", "id": request.ID, "result": result})
+	}
+
+	config, err := WrapMCPServer(context.Background(), "docs", handler)
+	if err != nil {
+		t.Fatalf("WrapMCPServer failed: %v", err)
+	}
+	tools := config.Server.Tools()
+	if len(tools) != 2 || tools[0].Name != "search" || tools[1].Name != "read" {
+		t.Fatalf("Expected tools of all pages, got %+v", tools)
+	}
+
+	result, err := tools[0].Handler(context.Background(), nil)
+	if err != nil || len(result.Content) != 1 || result.Content[0].Type != "text" ||
+		!strings.Contains(result.Content[0].Text, "file:///a.md") {
+		t.Errorf("Expected resource content as JSON text, got %+v, %v", result, err)
+	}
+
+	if _, err := tools[1].Handler(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "missing path") {
+		t.Errorf("Expected JSON-RPC error, got %v", err)
+	}
+}
+
+func TestWrapMCPServer_InitializeError(t *testing.T) {
+	handler := func(ctx context.Context, message json.RawMessage) (json.RawMessage, error) {
+		return nil, errors.New("server not started")
+	}
+	if _, err := WrapMCPServer(context.Background(), "broken", handler); err == nil || !strings.Contains(err.Error(), "server not started") {
+		t.Errorf("Expected initialize error, got %v", err)
+	}
+}
+
+func TestNewMCPStreamHandler(t *testing.T) {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	t.Cleanup(func() {
+		clientOut.Close()
+		serverOut.Close()
+	})
+
+	// A server on a stream that logs, and sends requests of its own
+	server := fakeMCPLibraryServer()
+	go func() {
+		scanner := bufio.NewScanner(serverIn)
+		for scanner.Scan() {
+			response, _ := server(context.Background(), scanner.Bytes())
+			_, _ = io.WriteString(serverOut, `{"jsonrpc":"2.0","method":"notifications/message","params":{}}`+"\n")
+			_, _ = io.WriteString(serverOut, `{"jsonrpc":"2.0","id":"s1","method":"roots/list"}`+"\n")
+			if string(response) != "null" {
+				_, _ = serverOut.Write(append(response, '\n'))
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	config, err := WrapMCPServer(ctx, "weather", NewMCPStreamHandler(clientIn, clientOut))
+	if err != nil {
+		t.Fatalf("WrapMCPServer failed: %v", err)
+	}
+	result, err := config.Server.Tools()[0].Handler(ctx, map[string]any{"city": "Lima"})
+	if err != nil || result.Content[0].Text != "Sunny in Lima" {
+		t.Errorf("Unexpected result: %+v, %v", result, err)
+	}
+
+	// Closing the stream fails waiting calls
+	serverOut.Close()
+	if _, err := config.Server.Tools()[0].Handler(ctx, map[string]any{"city": "Lima"}); err == nil {
+		t.Error("Expected error after the stream closed")
+	}
+}
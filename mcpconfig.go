@@ -0,0 +1,129 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// mcpConfigFile is the format of .mcp.json files.
+type mcpConfigFile struct {
+	MCPServers map[string]json.RawMessage `json:"mcpServers"`
+}
+
+// mcpConfigEntry holds the fields of any server entry of .mcp.json.
+type mcpConfigEntry struct {
+	Type    string            `json:"type"`
+	Command string            `json:"command"`
+	Args    []string          `json:"args"`
+	Env     map[string]string `json:"env"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+}
+
+// envReference matches ${VAR} and ${VAR:-default} in .mcp.json values.
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// LoadMCPConfig reads MCP servers from a file in the .mcp.json format
+// shared with the CLI and other SDKs, for use with WithMCPServers:
+//
+//	{
+//	  "mcpServers": {
+//	    "github": {"command": "github-mcp", "args": ["--stdio"], "env": {"TOKEN": "${GITHUB_TOKEN}"}},
+//	    "docs": {"type": "http", "url": "${DOCS_URL:-https://docs.example.com/mcp}"}
+//	  }
+//	}
+//
+// Entries are stdio servers unless their type is "sse" or "http".
+// References to environment variables, ${VAR} or ${VAR:-default}, are
+// expanded in commands, arguments, environment values, URLs and headers,
+// as the CLI does. Loading fails if a variable without a default is not
+// set.
+func LoadMCPConfig(path string) (map[string]MCPServerConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, WrapClaudeSDKError("failed to read MCP config", err)
+	}
+
+	var file mcpConfigFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, WrapClaudeSDKError(fmt.Sprintf("invalid MCP config %s", path), err)
+	}
+
+	servers := make(map[string]MCPServerConfig, len(file.MCPServers))
+	for _, name := range slices.Sorted(maps.Keys(file.MCPServers)) {
+		var entry mcpConfigEntry
+		if err := json.Unmarshal(file.MCPServers[name], &entry); err != nil {
+			return nil, WrapClaudeSDKError(fmt.Sprintf("invalid MCP server %q in %s", name, path), err)
+		}
+		server, err := entry.config()
+		if err != nil {
+			return nil, NewClaudeSDKError(fmt.Sprintf("MCP server %q in %s: %v", name, path, err))
+		}
+		servers[name] = server
+	}
+	return servers, nil
+}
+
+// config converts the entry to a typed config, expanding environment
+// variables.
+func (e mcpConfigEntry) config() (MCPServerConfig, error) {
+	var missing []string
+	expand := func(s string) string {
+		return envReference.ReplaceAllStringFunc(s, func(ref string) string {
+			m := envReference.FindStringSubmatch(ref)
+			if value, ok := os.LookupEnv(m[1]); ok {
+				return value
+			}
+			if m[2] != "" {
+				return m[3]
+			}
+			missing = append(missing, m[1])
+			return ""
+		})
+	}
+	expandMap := func(m map[string]string) map[string]string {
+		if m == nil {
+			return nil
+		}
+		expanded := make(map[string]string, len(m))
+		for k, v := range m {
+			expanded[k] = expand(v)
+		}
+		return expanded
+	}
+
+	var config MCPServerConfig
+	switch e.Type {
+	case "", "stdio":
+		if e.Command == "" {
+			return nil, fmt.Errorf("stdio server has no command")
+		}
+		var args []string
+		for _, arg := range e.Args {
+			args = append(args, expand(arg))
+		}
+		config = MCPStdioServerConfig{Type: e.Type, Command: expand(e.Command), Args: args, Env: expandMap(e.Env)}
+	case "sse", "http":
+		if e.URL == "" {
+			return nil, fmt.Errorf("%s server has no url", e.Type)
+		}
+		if e.Type == "sse" {
+			config = MCPSSEServerConfig{Type: e.Type, URL: expand(e.URL), Headers: expandMap(e.Headers)}
+		} else {
+			config = MCPHTTPServerConfig{Type: e.Type, URL: expand(e.URL), Headers: expandMap(e.Headers)}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type %q", e.Type)
+	}
+
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return nil, fmt.Errorf("environment variables not set: %s", strings.Join(slices.Compact(missing), ", "))
+	}
+	return config, nil
+}
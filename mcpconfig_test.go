@@ -0,0 +1,76 @@
+package claude
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeMCPConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".mcp.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadMCPConfig(t *testing.T) {
+	t.Setenv("MCP_TEST_TOKEN", "secret")
+	t.Setenv("MCP_TEST_DIR", "/data")
+	path := writeMCPConfig(t, `{
+  "mcpServers": {
+    "files": {"command": "files-mcp", "args": ["--root", "${MCP_TEST_DIR}/files"], "env": {"TOKEN": "${MCP_TEST_TOKEN}"}},
+    "events": {"type": "sse", "url": "https://events.example.com/sse"},
+    "docs": {"type": "http", "url": "${MCP_TEST_UNSET:-https://docs.example.com}/mcp", "headers": {"Authorization": "Bearer ${MCP_TEST_TOKEN}"}}
+  }
+}`)
+
+	servers, err := LoadMCPConfig(path)
+	if err != nil {
+		t.Fatalf("LoadMCPConfig failed: %v", err)
+	}
+	want := map[string]MCPServerConfig{
+		"files": MCPStdioServerConfig{
+			Command: "files-mcp",
+			Args:    []string{"--root", "/data/files"},
+			Env:     map[string]string{"TOKEN": "secret"},
+		},
+		"events": MCPSSEServerConfig{Type: "sse", URL: "https://events.example.com/sse"},
+		"docs": MCPHTTPServerConfig{
+			Type:    "http",
+			URL:     "https://docs.example.com/mcp",
+			Headers: map[string]string{"Authorization": "Bearer secret"},
+		},
+	}
+	if !reflect.DeepEqual(servers, want) {
+		t.Errorf("Expected %+v, got %+v", want, servers)
+	}
+}
+
+func TestLoadMCPConfig_Errors(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		want    string
+	}{
+		"invalid json":  {`{`, "invalid MCP config"},
+		"no command":    {`{"mcpServers": {"a": {"args": ["x"]}}}`, "no command"},
+		"no url":        {`{"mcpServers": {"a": {"type": "http"}}}`, "no url"},
+		"unknown type":  {`{"mcpServers": {"a": {"type": "ws", "url": "ws://x"}}}`, `unsupported type "ws"`},
+		"unset env var": {`{"mcpServers": {"a": {"command": "${MCP_TEST_UNSET}", "args": ["${MCP_TEST_UNSET}"]}}}`, "not set: MCP_TEST_UNSET"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadMCPConfig(writeMCPConfig(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	if _, err := LoadMCPConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
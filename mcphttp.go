@@ -0,0 +1,122 @@
+package claude
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/protocol"
+	"github.com/afsharalex/claude-agent-sdk-go/internal/types"
+)
+
+// mcpHTTPMaxBody bounds the size of a request to an MCP HTTP handler.
+const mcpHTTPMaxBody = 4 << 20
+
+// NewMCPHTTPHandler serves an in-process MCP server over the MCP
+// streamable HTTP transport, so that the tools given to Claude with
+// CreateSDKMCPServer can also be used by other MCP clients over the
+// network.
+//
+// The handler is stateless: it answers each POSTed JSON-RPC message or
+// batch with a JSON response and does not offer a server-sent event
+// stream. Tool handlers receive the request's context. Requests from
+// browsers on another origin are rejected to prevent DNS rebinding
+// attacks; put the handler behind your own middleware for
+// authentication.
+//
+// Example:
+//
+//	server := claude.NewMCPServer("calc", "1.0.0", []claude.MCPTool{addTool})
+//	http.Handle("/mcp", claude.NewMCPHTTPHandler(server))
+func NewMCPHTTPHandler(server *MCPServer) http.Handler {
+	return &mcpHTTPHandler{server: toInternalMCPServer(server)}
+}
+
+type mcpHTTPHandler struct {
+	server *types.MCPServer
+}
+
+func (h *mcpHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin request rejected", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body json.RawMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, mcpHTTPMaxBody)).Decode(&body); err != nil {
+		writeMCPHTTPError(w, -32700, "parse error")
+		return
+	}
+
+	// A batch is a JSON array of messages
+	var messages []map[string]any
+	batch := len(body) > 0 && body[0] == '['
+	if batch {
+		if err := json.Unmarshal(body, &messages); err != nil || len(messages) == 0 {
+			writeMCPHTTPError(w, -32600, "invalid request")
+			return
+		}
+	} else {
+		var message map[string]any
+		if err := json.Unmarshal(body, &message); err != nil {
+			writeMCPHTTPError(w, -32600, "invalid request")
+			return
+		}
+		messages = []map[string]any{message}
+	}
+
+	var responses []map[string]any
+	for _, message := range messages {
+		if _, ok := message["method"]; !ok {
+			// Responses from the client to server requests, which this
+			// server never sends
+			continue
+		}
+		if response := protocol.HandleMCPMessage(r.Context(), h.server, message); response != nil {
+			responses = append(responses, response)
+		}
+	}
+
+	if len(responses) == 0 {
+		// Only notifications and responses
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if batch {
+		_ = json.NewEncoder(w).Encode(responses)
+	} else {
+		_ = json.NewEncoder(w).Encode(responses[0])
+	}
+}
+
+// writeMCPHTTPError writes a JSON-RPC error that is not tied to a request.
+func writeMCPHTTPError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      nil,
+		"error":   map[string]any{"code": code, "message": message},
+	})
+}
+
+// sameOrigin reports whether r has no Origin header or one matching its
+// host.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return u.Host == r.Host
+}
@@ -0,0 +1,199 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestMCPHTTPServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := NewMCPServer("calc", "1.0.0", []MCPTool{
+		{
+			Name:        "add",
+			Description: "Add two numbers",
+			InputSchema: map[string]any{"type": "object"},
+			Handler: func(ctx context.Context, args map[string]any) (MCPToolResult, error) {
+				a, _ := args["a"].(float64)
+				b, _ := args["b"].(float64)
+				return MCPToolResult{Content: []MCPContent{{Type: "text", Text: fmt.Sprint(a + b)}}}, nil
+			},
+		},
+		{
+			Name: "divide",
+			Handler: func(ctx context.Context, args map[string]any) (MCPToolResult, error) {
+				return MCPToolResult{Content: []MCPContent{{Type: "text", Text: "division by zero"}}, IsError: true}, nil
+			},
+		},
+		{
+			Name: "broken",
+			Handler: func(ctx context.Context, args map[string]any) (MCPToolResult, error) {
+				return MCPToolResult{}, errors.New("database unavailable")
+			},
+		},
+	})
+	ts := httptest.NewServer(NewMCPHTTPHandler(server))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func postMCP(t *testing.T, url, body string) (*http.Response, string) {
+	t.Helper()
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Reading response failed: %v", err)
+	}
+	return resp, strings.TrimSpace(string(data))
+}
+
+func TestMCPHTTPHandler_Initialize(t *testing.T) {
+	ts := newTestMCPHTTPServer(t)
+
+	resp, body := postMCP(t, ts.URL, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{}}}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected application/json, got %q", ct)
+	}
+
+	var response struct {
+		ID     int `json:"id"`
+		Result struct {
+			ProtocolVersion string `json:"protocolVersion"`
+			ServerInfo      struct {
+				Name string `json:"name"`
+			} `json:"serverInfo"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		t.Fatalf("Invalid response %s: %v", body, err)
+	}
+	if response.ID != 1 || response.Result.ProtocolVersion != "2025-03-26" || response.Result.ServerInfo.Name != "calc" {
+		t.Errorf("Unexpected initialize response: %s", body)
+	}
+
+	// Unknown versions fall back to one the server speaks
+	_, body = postMCP(t, ts.URL, `{"jsonrpc":"2.0","id":2,"method":"initialize","params":{"protocolVersion":"1999-01-01"}}`)
+	if !strings.Contains(body, `"protocolVersion":"2024-11-05"`) {
+		t.Errorf("Expected fallback protocol version, got %s", body)
+	}
+}
+
+func TestMCPHTTPHandler_Tools(t *testing.T) {
+	ts := newTestMCPHTTPServer(t)
+
+	_, body := postMCP(t, ts.URL, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	for _, name := range []string{"add", "divide", "broken"} {
+		if !strings.Contains(body, `"name":"`+name+`"`) {
+			t.Errorf("Expected tool %s in %s", name, body)
+		}
+	}
+
+	_, body = postMCP(t, ts.URL, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"add","arguments":{"a":2,"b":3}}}`)
+	if !strings.Contains(body, `"text":"5"`) {
+		t.Errorf("Expected sum in %s", body)
+	}
+
+	_, body = postMCP(t, ts.URL, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"divide","arguments":{}}}`)
+	if !strings.Contains(body, `"isError":true`) {
+		t.Errorf("Expected isError in %s", body)
+	}
+
+	_, body = postMCP(t, ts.URL, `{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"broken","arguments":{}}}`)
+	if !strings.Contains(body, `"code":-32603`) || !strings.Contains(body, "database unavailable") {
+		t.Errorf("Expected internal error in %s", body)
+	}
+
+	_, body = postMCP(t, ts.URL, `{"jsonrpc":"2.0","id":5,"method":"resources/list"}`)
+	if !strings.Contains(body, `"code":-32601`) {
+		t.Errorf("Expected method not found in %s", body)
+	}
+}
+
+func TestMCPHTTPHandler_Notification(t *testing.T) {
+	ts := newTestMCPHTTPServer(t)
+
+	resp, body := postMCP(t, ts.URL, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("Expected 202, got %d", resp.StatusCode)
+	}
+	if body != "" {
+		t.Errorf("Expected no body, got %s", body)
+	}
+}
+
+func TestMCPHTTPHandler_Batch(t *testing.T) {
+	ts := newTestMCPHTTPServer(t)
+
+	_, body := postMCP(t, ts.URL, `[
+		{"jsonrpc":"2.0","method":"notifications/initialized"},
+		{"jsonrpc":"2.0","id":1,"method":"tools/list"},
+		{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"add","arguments":{"a":1,"b":1}}}
+	]`)
+
+	var responses []map[string]any
+	if err := json.Unmarshal([]byte(body), &responses); err != nil {
+		t.Fatalf("Expected a batch response, got %s: %v", body, err)
+	}
+	if len(responses) != 2 {
+		t.Fatalf("Expected 2 responses, got %d", len(responses))
+	}
+	if responses[0]["id"] != float64(1) || responses[1]["id"] != float64(2) {
+		t.Errorf("Expected responses in request order, got %s", body)
+	}
+}
+
+func TestMCPHTTPHandler_InvalidRequests(t *testing.T) {
+	ts := newTestMCPHTTPServer(t)
+
+	resp, body := postMCP(t, ts.URL, `{not json`)
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, `"code":-32700`) {
+		t.Errorf("Expected parse error, got %d %s", resp.StatusCode, body)
+	}
+
+	resp, body = postMCP(t, ts.URL, `[]`)
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, `"code":-32600`) {
+		t.Errorf("Expected invalid request, got %d %s", resp.StatusCode, body)
+	}
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for GET, got %d", resp.StatusCode)
+	}
+}
+
+func TestMCPHTTPHandler_Origin(t *testing.T) {
+	ts := newTestMCPHTTPServer(t)
+
+	for origin, want := range map[string]int{
+		"https://evil.example.com": http.StatusForbidden,
+		ts.URL:                     http.StatusOK,
+	} {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+		req.Header.Set("Origin", origin)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("Origin %s: expected %d, got %d", origin, want, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package claude
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mcpPreflightStderrLimit bounds the stderr kept from a server that fails
+// preflight.
+const mcpPreflightStderrLimit = 2048
+
+// MCPServerFailure describes an MCP server that failed preflight.
+type MCPServerFailure struct {
+	// Name is the server's key in the MCP servers map.
+	Name    string
+	Command string
+	// Stderr is the end of what the server wrote to stderr.
+	Stderr string
+	Err    error
+}
+
+// PreflightMCPServers starts each stdio MCP server in servers, checks
+// that it completes the MCP initialize handshake within timeout, and
+// stops it. Servers are checked concurrently. Servers of other types are
+// skipped.
+//
+// It returns an MCPPreflightError listing the servers that are missing
+// or broken, or nil if all of them answered. WithEnv and WithCwd apply to
+// the servers as they do when the CLI starts them.
+func PreflightMCPServers(ctx context.Context, servers map[string]MCPServerConfig, timeout time.Duration, opts ...Option) error {
+	return checkMCPServers(ctx, servers, timeout, NewOptions(opts...))
+}
+
+// checkMCPServers implements PreflightMCPServers.
+func checkMCPServers(ctx context.Context, servers map[string]MCPServerConfig, timeout time.Duration, options *Options) error {
+	var mu sync.Mutex
+	var failures []MCPServerFailure
+	var wg sync.WaitGroup
+	for name, config := range servers {
+		stdio, ok := config.(MCPStdioServerConfig)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if failure := preflightStdioServer(ctx, name, stdio, timeout, options); failure != nil {
+				mu.Lock()
+				failures = append(failures, *failure)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(failures) == 0 {
+		return nil
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Name < failures[j].Name })
+	return NewMCPPreflightError(failures)
+}
+
+// preflightMCP runs PreflightMCPServers for the options of a query or
+// connection, if enabled with WithMCPPreflight.
+func preflightMCP(ctx context.Context, o *Options) error {
+	servers, ok := o.MCPServers.(map[string]MCPServerConfig)
+	if o.MCPPreflightTimeout <= 0 || !ok {
+		return nil
+	}
+	return checkMCPServers(ctx, servers, o.MCPPreflightTimeout, o)
+}
+
+// preflightStdioServer starts one server and waits for its answer to an
+// initialize request.
+func preflightStdioServer(ctx context.Context, name string, config MCPStdioServerConfig, timeout time.Duration, options *Options) *MCPServerFailure {
+	failure := &MCPServerFailure{Name: name, Command: config.Command}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, config.Command, config.Args...)
+	cmd.Dir = options.Cwd
+	cmd.Env = os.Environ()
+	for k, v := range options.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	for k, v := range config.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	stderr := &tailBuffer{limit: mcpPreflightStderrLimit}
+	cmd.Stderr = stderr
+	// Do not wait on children of the server holding its output open
+	cmd.WaitDelay = time.Second
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		failure.Err = err
+		return failure
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		failure.Err = err
+		return failure
+	}
+	if err := cmd.Start(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			err = fmt.Errorf("command %q not found", config.Command)
+		}
+		failure.Err = err
+		return failure
+	}
+
+	answered := make(chan error, 1)
+	go func() { answered <- readInitializeResponse(stdout) }()
+
+	request := map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "initialize",
+		"params": map[string]any{
+			"protocolVersion": "2024-11-05",
+			"capabilities":    map[string]any{},
+			"clientInfo":      map[string]any{"name": "claude-agent-sdk-go", "version": Version},
+		},
+	}
+	data, _ := json.Marshal(request)
+	_, _ = stdin.Write(append(data, '\n'))
+
+	select {
+	case err = <-answered:
+	case <-ctx.Done():
+		err = fmt.Errorf("no initialize response within %s", timeout)
+	}
+
+	_ = stdin.Close()
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+
+	if err == nil {
+		return nil
+	}
+	failure.Err = err
+	failure.Stderr = strings.TrimSpace(stderr.String())
+	return failure
+}
+
+// readInitializeResponse reads JSON-RPC messages until the response to
+// the initialize request.
+func readInitializeResponse(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var response struct {
+			ID     any             `json:"id"`
+			Result json.RawMessage `json:"result"`
+			Error  *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &response) != nil || fmt.Sprint(response.ID) != "1" {
+			// Logs and notifications
+			continue
+		}
+		if response.Error != nil {
+			return fmt.Errorf("initialize failed: %s", response.Error.Message)
+		}
+		return nil
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("server exited before answering initialize")
+}
+
+// tailBuffer keeps the last limit bytes written to it.
+type tailBuffer struct {
+	mu    sync.Mutex
+	limit int
+	data  []byte
+	size  int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.size += len(p)
+	b.data = append(b.data, p...)
+	if len(b.data) > b.limit {
+		b.data = b.data[len(b.data)-b.limit:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.data)
+}
+
+// written returns the number of bytes written, kept or not.
+func (b *tailBuffer) written() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.size
+}
@@ -0,0 +1,103 @@
+package claude
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPreflightMCPServers(t *testing.T) {
+	healthy := writeStubCLI(t, `
+read line
+echo 'starting up' >&2
+echo '{"jsonrpc":"2.0","method":"notifications/message","params":{}}'
+echo '{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2024-11-05"}}'
+sleep 10
+`)
+	rejecting := writeStubCLI(t, `
+read line
+echo '{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"unsupported protocol version"}}'
+`)
+	crashing := writeStubCLI(t, `
+echo 'Error: DATABASE_URL is not set' >&2
+exit 1
+`)
+	hanging := writeStubCLI(t, `
+sleep 10
+`)
+
+	servers := map[string]MCPServerConfig{
+		"healthy":   MCPStdioServerConfig{Command: healthy},
+		"rejecting": MCPStdioServerConfig{Command: rejecting},
+		"crashing":  MCPStdioServerConfig{Command: crashing},
+		"hanging":   MCPStdioServerConfig{Command: hanging},
+		"missing":   MCPStdioServerConfig{Command: "claude-sdk-test-missing-mcp-server"},
+		"remote":    MCPSSEServerConfig{Type: "sse", URL: "http://localhost:1"},
+	}
+
+	start := time.Now()
+	err := PreflightMCPServers(context.Background(), servers, 500*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Expected servers to be checked concurrently, took %v", elapsed)
+	}
+
+	preflightErr, ok := AsMCPPreflightError(err)
+	if !ok {
+		t.Fatalf("Expected MCPPreflightError, got %v", err)
+	}
+
+	var names []string
+	failures := make(map[string]MCPServerFailure)
+	for _, f := range preflightErr.Failures {
+		names = append(names, f.Name)
+		failures[f.Name] = f
+	}
+	if got := strings.Join(names, ","); got != "crashing,hanging,missing,rejecting" {
+		t.Fatalf("Expected sorted failures of the broken servers, got %s", got)
+	}
+
+	if f := failures["crashing"]; !strings.Contains(f.Stderr, "DATABASE_URL") || !strings.Contains(f.Err.Error(), "exited") {
+		t.Errorf("Unexpected crashing failure: %+v", f)
+	}
+	if f := failures["hanging"]; !strings.Contains(f.Err.Error(), "no initialize response") {
+		t.Errorf("Unexpected hanging failure: %+v", f)
+	}
+	if f := failures["missing"]; !strings.Contains(f.Err.Error(), "not found") {
+		t.Errorf("Unexpected missing failure: %+v", f)
+	}
+	if f := failures["rejecting"]; !strings.Contains(f.Err.Error(), "unsupported protocol version") {
+		t.Errorf("Unexpected rejecting failure: %+v", f)
+	}
+	if !strings.Contains(err.Error(), "missing: ") {
+		t.Errorf("Expected the message to list failures, got %q", err)
+	}
+}
+
+func TestPreflightMCPServers_Env(t *testing.T) {
+	server := writeStubCLI(t, `
+read line
+[ "$TOKEN" = "secret" ] || exit 1
+echo '{"jsonrpc":"2.0","id":1,"result":{}}'
+`)
+	servers := map[string]MCPServerConfig{
+		"env": MCPStdioServerConfig{Command: server, Env: map[string]string{"TOKEN": "secret"}},
+	}
+	if err := PreflightMCPServers(context.Background(), servers, 5*time.Second); err != nil {
+		t.Errorf("Expected server env to be passed, got %v", err)
+	}
+}
+
+func TestClient_MCPPreflight(t *testing.T) {
+	broken := writeStubCLI(t, "exit 1\n")
+
+	client := NewClient(
+		WithCLIPath("/nonexistent/claude"),
+		WithMCPServers(map[string]MCPServerConfig{"db": MCPStdioServerConfig{Command: broken}}),
+		WithMCPPreflight(5*time.Second),
+	)
+	err := client.Connect(context.Background())
+	if !IsMCPPreflightError(err) {
+		t.Errorf("Expected MCPPreflightError before the CLI starts, got %v", err)
+	}
+}
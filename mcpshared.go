@@ -0,0 +1,221 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// SharedMCPRegistry runs external stdio MCP servers once for many
+// clients. Clients passed the registry with WithSharedMCPServers start no
+// process of their own for a stdio server another client already started
+// with the same command, arguments, environment, and working directory:
+// the server is run by the registry and shared as an in-process SDK
+// server, with the calls of all sessions multiplexed over its stdio.
+//
+// This suits services running many sessions with heavyweight servers.
+// A shared server must not keep state per session, since every session
+// talks to the same process.
+//
+// A server that exits is started again by the next client needing it.
+// Close stops all servers; it is safe to call once no client using the
+// registry is connected. A SharedMCPRegistry is safe for concurrent use.
+type SharedMCPRegistry struct {
+	mu      sync.Mutex
+	servers map[string]*sharedMCPServer
+	closed  bool
+}
+
+// sharedMCPServer is a server process run by a SharedMCPRegistry. ready
+// is closed once the server was started, or failed to start with err.
+type sharedMCPServer struct {
+	ready  chan struct{}
+	config MCPSDKServerConfig
+	err    error
+	cmd    *exec.Cmd
+	stdin  io.Closer
+	exited chan struct{}
+}
+
+// NewSharedMCPRegistry returns a registry running no servers.
+func NewSharedMCPRegistry() *SharedMCPRegistry {
+	return &SharedMCPRegistry{servers: make(map[string]*sharedMCPServer)}
+}
+
+// Server returns an SDK server config for the stdio server config, named
+// name, starting the server if the registry does not run it yet. Calls
+// made at the same time for the same server wait for one start.
+func (r *SharedMCPRegistry) Server(ctx context.Context, name string, config MCPStdioServerConfig) (MCPSDKServerConfig, error) {
+	return r.server(ctx, name, config, "", nil)
+}
+
+// server returns the server run with config in dir, with env added to
+// its environment.
+func (r *SharedMCPRegistry) server(ctx context.Context, name string, config MCPStdioServerConfig, dir string, env map[string]string) (MCPSDKServerConfig, error) {
+	key, err := json.Marshal([]any{config.Command, config.Args, config.Env, dir, env})
+	if err != nil {
+		return MCPSDKServerConfig{}, err
+	}
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return MCPSDKServerConfig{}, NewClaudeSDKError("shared MCP registry is closed")
+	}
+	s, running := r.servers[string(key)]
+	if !running {
+		s = &sharedMCPServer{ready: make(chan struct{})}
+		r.servers[string(key)] = s
+	}
+	r.mu.Unlock()
+
+	if !running {
+		s.err = s.start(ctx, name, config, dir, env)
+		close(s.ready)
+		if s.err != nil {
+			r.remove(string(key), s)
+		} else {
+			go func() {
+				<-s.exited
+				r.remove(string(key), s)
+			}()
+		}
+	}
+
+	select {
+	case <-s.ready:
+	case <-ctx.Done():
+		return MCPSDKServerConfig{}, ctx.Err()
+	}
+	if s.err != nil {
+		return MCPSDKServerConfig{}, s.err
+	}
+	return MCPSDKServerConfig{Type: "sdk", Name: name, Server: s.config.Server}, nil
+}
+
+// remove forgets s, if it is still the server of key.
+func (r *SharedMCPRegistry) remove(key string, s *sharedMCPServer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.servers[key] == s {
+		delete(r.servers, key)
+	}
+}
+
+// Len returns the number of servers the registry runs.
+func (r *SharedMCPRegistry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, s := range r.servers {
+		select {
+		case <-s.ready:
+			if s.err == nil {
+				n++
+			}
+		default:
+		}
+	}
+	return n
+}
+
+// Close stops the servers of the registry. Servers cannot be started
+// after Close.
+func (r *SharedMCPRegistry) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	servers := r.servers
+	r.servers = make(map[string]*sharedMCPServer)
+	r.mu.Unlock()
+
+	for _, s := range servers {
+		<-s.ready
+		if s.err == nil {
+			s.stop()
+		}
+	}
+	return nil
+}
+
+// start starts the server process and lists its tools.
+func (s *sharedMCPServer) start(ctx context.Context, name string, config MCPStdioServerConfig, dir string, env map[string]string) error {
+	cmd := exec.Command(config.Command, config.Args...)
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	for k, v := range config.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	// Do not wait on children of the server holding its output open
+	cmd.WaitDelay = time.Second
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			err = fmt.Errorf("command %q not found", config.Command)
+		}
+		return fmt.Errorf("starting MCP server %s: %w", name, err)
+	}
+	s.cmd, s.stdin = cmd, stdin
+	s.exited = make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(s.exited)
+	}()
+
+	s.config, err = WrapMCPServer(ctx, name, NewMCPStreamHandler(stdout, stdin))
+	if err != nil {
+		s.stop()
+		return err
+	}
+	return nil
+}
+
+// stop ends the server process, asking it to exit by closing its input
+// first.
+func (s *sharedMCPServer) stop() {
+	_ = s.stdin.Close()
+	select {
+	case <-s.exited:
+	case <-time.After(time.Second):
+		_ = s.cmd.Process.Kill()
+		<-s.exited
+	}
+}
+
+// share returns servers with the stdio servers replaced by those of r,
+// run in dir with env. Other servers are kept, and servers is returned
+// unchanged if r is nil or servers is not a map.
+func (r *SharedMCPRegistry) share(ctx context.Context, servers any, dir string, env map[string]string) (any, error) {
+	configs, ok := servers.(map[string]MCPServerConfig)
+	if r == nil || !ok {
+		return servers, nil
+	}
+	shared := make(map[string]MCPServerConfig, len(configs))
+	for name, config := range configs {
+		if stdio, ok := config.(MCPStdioServerConfig); ok && stdio.GetType() == "stdio" {
+			sdk, err := r.server(ctx, name, stdio, dir, env)
+			if err != nil {
+				return nil, err
+			}
+			config = sdk
+		}
+		shared[name] = config
+	}
+	return shared, nil
+}
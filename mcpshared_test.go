@@ -0,0 +1,136 @@
+package claude
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writeStubMCPServer writes a stdio MCP server with a ping tool, which
+// appends a line to the returned log each time it starts.
+func writeStubMCPServer(t *testing.T) (command, log string) {
+	t.Helper()
+	log = filepath.Join(t.TempDir(), "starts")
+	command = writeStubCLI(t, `
+echo started >> "`+log+`"
+while read -r line; do
+	id=$(echo "$line" | sed -n 's/.*"id":\([0-9]*\).*/\1/p')
+	case "$line" in
+	*'"initialize"'*) echo '{"jsonrpc":"2.0","id":'$id',"result":{"serverInfo":{"name":"stub","version":"1.0.0"}}}' ;;
+	*'"tools/list"'*) echo '{"jsonrpc":"2.0","id":'$id',"result":{"tools":[{"name":"ping","inputSchema":{"type":"object"}}]}}' ;;
+	*'"tools/call"'*) echo '{"jsonrpc":"2.0","id":'$id',"result":{"content":[{"type":"text","text":"pong"}]}}' ;;
+	esac
+done
+`)
+	return command, log
+}
+
+// starts returns the number of times the stub server logging to log
+// started.
+func starts(t *testing.T, log string) int {
+	t.Helper()
+	data, err := os.ReadFile(log)
+	if err != nil {
+		return 0
+	}
+	return strings.Count(string(data), "started")
+}
+
+func TestSharedMCPRegistry(t *testing.T) {
+	command, log := writeStubMCPServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	registry := NewSharedMCPRegistry()
+	defer func() { _ = registry.Close() }()
+
+	// Concurrent requests for the same server share one process
+	var wg sync.WaitGroup
+	configs := make([]MCPSDKServerConfig, 5)
+	for i := range configs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			config, err := registry.Server(ctx, "stub", MCPStdioServerConfig{Command: command})
+			if err != nil {
+				t.Errorf("Server failed: %v", err)
+			}
+			configs[i] = config
+		}()
+	}
+	wg.Wait()
+	if n := starts(t, log); n != 1 || registry.Len() != 1 {
+		t.Fatalf("Expected one server process, got %d starts and %d servers", n, registry.Len())
+	}
+	tools := configs[0].Server.Tools()
+	if len(tools) != 1 || tools[0].Name != "ping" || configs[4].Server != configs[0].Server {
+		t.Fatalf("Expected the shared server with its ping tool, got %+v", configs)
+	}
+	result, err := tools[0].Handler(ctx, nil)
+	if err != nil || result.Content[0].Text != "pong" {
+		t.Errorf("Expected the tool call forwarded to the server, got %+v, %v", result, err)
+	}
+
+	// Another configuration gets its own process
+	if _, err := registry.Server(ctx, "other", MCPStdioServerConfig{Command: command, Args: []string{"--other"}}); err != nil {
+		t.Fatalf("Server failed: %v", err)
+	}
+	if n := starts(t, log); n != 2 || registry.Len() != 2 {
+		t.Errorf("Expected two server processes, got %d starts and %d servers", n, registry.Len())
+	}
+
+	if _, err := registry.Server(ctx, "missing", MCPStdioServerConfig{Command: "/nonexistent/mcp-server"}); err == nil {
+		t.Error("Expected an error for a server that cannot start")
+	}
+
+	if err := registry.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := tools[0].Handler(ctx, nil); err == nil {
+		t.Error("Expected calls to fail once the server stopped")
+	}
+	if _, err := registry.Server(ctx, "stub", MCPStdioServerConfig{Command: command}); err == nil {
+		t.Error("Expected an error after Close")
+	}
+}
+
+func TestClient_SharedMCPServers(t *testing.T) {
+	command, log := writeStubMCPServer(t)
+	argsFile := filepath.Join(t.TempDir(), "args")
+	cli := writeStubCLI(t, `
+echo "$@" >> "`+argsFile+`"
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+cat > /dev/null
+`)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	registry := NewSharedMCPRegistry()
+	defer func() { _ = registry.Close() }()
+	servers := map[string]MCPServerConfig{"stub": MCPStdioServerConfig{Command: command}}
+
+	for range 3 {
+		client := NewClient(WithCLIPath(cli), WithMCPServers(servers), WithSharedMCPServers(registry))
+		if err := client.Connect(ctx); err != nil {
+			t.Fatalf("Connect failed: %v", err)
+		}
+		defer func() { _ = client.Close() }()
+	}
+
+	if n := starts(t, log); n != 1 {
+		t.Errorf("Expected the server started once for all clients, got %d", n)
+	}
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("Failed to read CLI arguments: %v", err)
+	}
+	if strings.Contains(string(args), command) || strings.Count(string(args), `"type":"sdk"`) != 3 {
+		t.Errorf("Expected each CLI given the server as an SDK server, got %s", args)
+	}
+}
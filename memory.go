@@ -0,0 +1,152 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// MemoryProvider is a knowledge store that Claude can draw on across
+// sessions. Register one with WithMemory.
+type MemoryProvider interface {
+	// Recall returns snippets relevant to a prompt. They are added to the
+	// prompt's context before Claude sees it.
+	Recall(ctx context.Context, prompt string) ([]string, error)
+
+	// Store is called when Claude finishes responding, with the messages
+	// of the turn: the prompt followed by Claude's replies and tool
+	// results.
+	Store(ctx context.Context, messages []Message) error
+}
+
+// memoryContextHeader introduces recalled snippets in the prompt context.
+const memoryContextHeader = "Relevant memories:"
+
+// WithMemory connects a MemoryProvider through hooks. Snippets recalled
+// for each prompt are injected as UserPromptSubmit additional context, and
+// the turn is stored from the session transcript when Claude stops.
+//
+// Like WithHook, WithMemory adds to the hooks configured so far; a later
+// WithHooks replaces them.
+//
+// Example:
+//
+//	client := claude.NewClient(claude.WithMemory(store))
+func WithMemory(provider MemoryProvider) Option {
+	return func(o *Options) {
+		if o.Hooks == nil {
+			o.Hooks = make(map[HookEvent][]HookMatcher)
+		}
+		o.Hooks[HookEventUserPromptSubmit] = append(o.Hooks[HookEventUserPromptSubmit], HookMatcher{
+			Hooks: []HookCallback{memoryRecallHook(provider)},
+		})
+		o.Hooks[HookEventStop] = append(o.Hooks[HookEventStop], HookMatcher{
+			Hooks: []HookCallback{memoryStoreHook(provider)},
+		})
+	}
+}
+
+// memoryRecallHook returns a UserPromptSubmit hook adding recalled
+// snippets to the prompt context.
+func memoryRecallHook(provider MemoryProvider) HookCallback {
+	return func(ctx context.Context, input HookInput, _ string, _ HookContext) (HookOutput, error) {
+		prompt, ok := input.(UserPromptSubmitHookInput)
+		if !ok {
+			return HookOutput{}, nil
+		}
+
+		snippets, err := provider.Recall(ctx, prompt.Prompt)
+		if err != nil {
+			return HookOutput{}, err
+		}
+		if len(snippets) == 0 {
+			return HookOutput{}, nil
+		}
+
+		return HookOutput{
+			HookSpecificOutput: UserPromptSubmitHookSpecificOutput{
+				HookEventName:     HookEventUserPromptSubmit,
+				AdditionalContext: memoryContextHeader + "\n\n" + strings.Join(snippets, "\n\n"),
+			},
+		}, nil
+	}
+}
+
+// memoryStoreHook returns a Stop hook storing the turn that just ended.
+func memoryStoreHook(provider MemoryProvider) HookCallback {
+	return func(ctx context.Context, input HookInput, _ string, _ HookContext) (HookOutput, error) {
+		stop, ok := input.(StopHookInput)
+		if !ok || stop.TranscriptPath == "" {
+			return HookOutput{}, nil
+		}
+
+		messages, err := readLastTurn(stop.TranscriptPath)
+		if err != nil {
+			return HookOutput{}, err
+		}
+		if len(messages) == 0 {
+			return HookOutput{}, nil
+		}
+
+		return HookOutput{}, provider.Store(ctx, messages)
+	}
+}
+
+// readLastTurn reads the messages of the last turn from a session
+// transcript, starting at the last prompt.
+func readLastTurn(path string) ([]Message, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var turn []Message
+	decoder := json.NewDecoder(f)
+	for {
+		var entry map[string]any
+		if err := decoder.Decode(&entry); err != nil {
+			if errors.Is(err, io.EOF) {
+				return turn, nil
+			}
+			return nil, err
+		}
+
+		// Transcripts also record entries such as summaries, which are
+		// not part of the conversation
+		msg, err := ParseMessage(entry)
+		if err != nil {
+			continue
+		}
+
+		switch m := msg.(type) {
+		case *UserMessage:
+			if isPrompt(m) {
+				turn = []Message{m}
+			} else if turn != nil {
+				turn = append(turn, m)
+			}
+		case *AssistantMessage:
+			if turn != nil {
+				turn = append(turn, m)
+			}
+		}
+	}
+}
+
+// isPrompt reports whether a user message is a prompt rather than a tool
+// result.
+func isPrompt(m *UserMessage) bool {
+	if _, ok := m.Content.(string); ok {
+		return true
+	}
+	for _, block := range m.GetContentBlocks() {
+		if _, ok := block.(ToolResultBlock); ok {
+			return false
+		}
+	}
+	return true
+}
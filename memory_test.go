@@ -0,0 +1,134 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type fakeMemory struct {
+	snippets  []string
+	recallErr error
+	prompts   []string
+	stored    [][]Message
+}
+
+func (m *fakeMemory) Recall(ctx context.Context, prompt string) ([]string, error) {
+	m.prompts = append(m.prompts, prompt)
+	return m.snippets, m.recallErr
+}
+
+func (m *fakeMemory) Store(ctx context.Context, messages []Message) error {
+	m.stored = append(m.stored, messages)
+	return nil
+}
+
+func TestWithMemory_RegistersHooks(t *testing.T) {
+	noop := func(ctx context.Context, input HookInput, toolUseID string, hookCtx HookContext) (HookOutput, error) {
+		return HookOutput{}, nil
+	}
+	opts := NewOptions(
+		WithHook(HookEventUserPromptSubmit, HookMatcher{Hooks: []HookCallback{noop}}),
+		WithMemory(&fakeMemory{}),
+	)
+
+	if got := len(opts.Hooks[HookEventUserPromptSubmit]); got != 2 {
+		t.Errorf("Expected 2 UserPromptSubmit matchers, got %d", got)
+	}
+	if got := len(opts.Hooks[HookEventStop]); got != 1 {
+		t.Errorf("Expected 1 Stop matcher, got %d", got)
+	}
+}
+
+func TestMemoryRecallHook(t *testing.T) {
+	memory := &fakeMemory{snippets: []string{"User prefers tabs", "Project uses Go 1.22"}}
+	hook := memoryRecallHook(memory)
+
+	output, err := hook(context.Background(), UserPromptSubmitHookInput{Prompt: "Format this file"}, "", HookContext{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(memory.prompts) != 1 || memory.prompts[0] != "Format this file" {
+		t.Errorf("Expected Recall with the prompt, got %v", memory.prompts)
+	}
+	specific, ok := output.HookSpecificOutput.(UserPromptSubmitHookSpecificOutput)
+	if !ok {
+		t.Fatalf("Expected UserPromptSubmitHookSpecificOutput, got %T", output.HookSpecificOutput)
+	}
+	want := "Relevant memories:\n\nUser prefers tabs\n\nProject uses Go 1.22"
+	if specific.AdditionalContext != want {
+		t.Errorf("Expected context %q, got %q", want, specific.AdditionalContext)
+	}
+}
+
+func TestMemoryRecallHook_NothingRecalled(t *testing.T) {
+	hook := memoryRecallHook(&fakeMemory{})
+
+	output, err := hook(context.Background(), UserPromptSubmitHookInput{Prompt: "hi"}, "", HookContext{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if output.HookSpecificOutput != nil {
+		t.Errorf("Expected no hook output, got %+v", output.HookSpecificOutput)
+	}
+}
+
+func TestMemoryRecallHook_Error(t *testing.T) {
+	recallErr := errors.New("store unavailable")
+	hook := memoryRecallHook(&fakeMemory{recallErr: recallErr})
+
+	if _, err := hook(context.Background(), UserPromptSubmitHookInput{Prompt: "hi"}, "", HookContext{}); !errors.Is(err, recallErr) {
+		t.Errorf("Expected recall error, got %v", err)
+	}
+}
+
+func TestMemoryStoreHook(t *testing.T) {
+	transcript := filepath.Join(t.TempDir(), "session.jsonl")
+	lines := []string{
+		`{"type":"summary","summary":"Earlier work"}`,
+		`{"type":"user","message":{"role":"user","content":"first prompt"}}`,
+		`{"type":"assistant","message":{"model":"m","content":[{"type":"text","text":"first answer"}]}}`,
+		`{"type":"user","message":{"role":"user","content":"second prompt"}}`,
+		`{"type":"assistant","message":{"model":"m","content":[{"type":"tool_use","id":"t1","name":"Read","input":{}}]}}`,
+		`{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"data"}]}}`,
+		`{"type":"assistant","message":{"model":"m","content":[{"type":"text","text":"second answer"}]}}`,
+	}
+	if err := os.WriteFile(transcript, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	memory := &fakeMemory{}
+	hook := memoryStoreHook(memory)
+
+	input := StopHookInput{BaseHookInput: BaseHookInput{TranscriptPath: transcript}}
+	if _, err := hook(context.Background(), input, "", HookContext{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(memory.stored) != 1 {
+		t.Fatalf("Expected 1 Store call, got %d", len(memory.stored))
+	}
+	turn := memory.stored[0]
+	if len(turn) != 4 {
+		t.Fatalf("Expected 4 messages in the last turn, got %d", len(turn))
+	}
+	if prompt, ok := turn[0].(*UserMessage); !ok || prompt.GetContentString() != "second prompt" {
+		t.Errorf("Expected turn to start at the second prompt, got %+v", turn[0])
+	}
+	if _, ok := turn[3].(*AssistantMessage); !ok {
+		t.Errorf("Expected turn to end with the final answer, got %T", turn[3])
+	}
+}
+
+func TestMemoryStoreHook_MissingTranscript(t *testing.T) {
+	hook := memoryStoreHook(&fakeMemory{})
+
+	input := StopHookInput{BaseHookInput: BaseHookInput{TranscriptPath: filepath.Join(t.TempDir(), "missing.jsonl")}}
+	if _, err := hook(context.Background(), input, "", HookContext{}); err == nil {
+		t.Error("Expected error for missing transcript")
+	}
+}
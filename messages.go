@@ -1,6 +1,10 @@
 package claude
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // ParseMessage parses a message from CLI output into typed Message objects.
 func ParseMessage(data map[string]any) (Message, error) {
@@ -24,6 +28,12 @@ func ParseMessage(data map[string]any) (Message, error) {
 		return parseResultMessage(data)
 	case "stream_event":
 		return parseStreamEvent(data)
+	case "incremental_tool_use":
+		return parseIncrementalToolUse(data)
+	case "diagnostic":
+		return parseDiagnosticEvent(data)
+	case "permission_mode_changed":
+		return parsePermissionModeChanged(data)
 	default:
 		return nil, NewMessageParseError(fmt.Sprintf("Unknown message type: %s", msgType), data)
 	}
@@ -92,6 +102,11 @@ func parseAssistantMessage(data map[string]any) (*AssistantMessage, error) {
 		return nil, NewMessageParseError("Missing required field in assistant message: model", data)
 	}
 
+	// Parse UUID
+	if uuid, ok := data["uuid"].(string); ok {
+		msg.UUID = uuid
+	}
+
 	// Parse parent_tool_use_id
 	if parentID, ok := data["parent_tool_use_id"].(string); ok {
 		msg.ParentToolUseID = parentID
@@ -102,6 +117,10 @@ func parseAssistantMessage(data map[string]any) (*AssistantMessage, error) {
 		msg.Error = AssistantMessageError(errStr)
 	}
 
+	if usage, ok := message["usage"].(map[string]any); ok {
+		msg.Usage = usage
+	}
+
 	// Parse content blocks
 	contentList, ok := message["content"].([]any)
 	if !ok {
@@ -189,6 +208,7 @@ func parseResultMessage(data map[string]any) (*ResultMessage, error) {
 	// Required fields
 	if subtype, ok := data["subtype"].(string); ok {
 		msg.Subtype = subtype
+		msg.Interrupted = ResultSubtype(subtype) == ResultSubtypeInterrupted
 	} else {
 		return nil, NewMessageParseError("Missing required field in result message: subtype", data)
 	}
@@ -230,6 +250,19 @@ func parseResultMessage(data map[string]any) (*ResultMessage, error) {
 		msg.StructuredOutput = structuredOutput
 	}
 
+	// Interrupt annotations, present on results re-encoded by EncodeMessage
+	if interrupted, ok := data["interrupted"].(bool); ok && interrupted {
+		msg.Interrupted = true
+	}
+
+	if reason, ok := data["interrupt_reason"].(string); ok {
+		msg.InterruptReason = reason
+	}
+
+	if model, ok := data["model"].(string); ok {
+		msg.Model = model
+	}
+
 	return msg, nil
 }
 
@@ -262,3 +295,316 @@ func parseStreamEvent(data map[string]any) (*StreamEvent, error) {
 
 	return msg, nil
 }
+
+// parseDiagnosticEvent parses a DiagnosticEvent encoded by EncodeMessage.
+func parseDiagnosticEvent(data map[string]any) (*DiagnosticEvent, error) {
+	kind, ok := data["kind"].(string)
+	if !ok {
+		return nil, NewMessageParseError("Missing required field in diagnostic message: kind", data)
+	}
+
+	msg := &DiagnosticEvent{Kind: DiagnosticKind(kind)}
+
+	if message, ok := data["message"].(string); ok {
+		msg.Message = message
+	}
+
+	switch idle := data["idle_ms"].(type) {
+	case float64:
+		msg.Idle = time.Duration(idle) * time.Millisecond
+	case int64:
+		msg.Idle = time.Duration(idle) * time.Millisecond
+	}
+
+	if action, ok := data["action"].(string); ok {
+		msg.Action = StallAction(action)
+	}
+
+	return msg, nil
+}
+
+// parsePermissionModeChanged parses a PermissionModeChanged encoded by
+// EncodeMessage.
+func parsePermissionModeChanged(data map[string]any) (*PermissionModeChanged, error) {
+	mode, ok := data["mode"].(string)
+	if !ok {
+		return nil, NewMessageParseError("Missing required field in permission_mode_changed message: mode", data)
+	}
+
+	msg := &PermissionModeChanged{Mode: PermissionMode(mode)}
+	if previous, ok := data["previous"].(string); ok {
+		msg.Previous = PermissionMode(previous)
+	}
+	if source, ok := data["source"].(string); ok {
+		msg.Source = PermissionModeSource(source)
+	}
+	msg.SessionID, _ = data["session_id"].(string)
+	return msg, nil
+}
+
+// parseIncrementalToolUse parses an IncrementalToolUse encoded by
+// EncodeMessage.
+func parseIncrementalToolUse(data map[string]any) (*IncrementalToolUse, error) {
+	id, ok := data["id"].(string)
+	if !ok {
+		return nil, NewMessageParseError("Missing required field in incremental_tool_use message: id", data)
+	}
+
+	msg := &IncrementalToolUse{ID: id, Input: map[string]any{}}
+	msg.Name, _ = data["name"].(string)
+	msg.PartialInput, _ = data["partial_input"].(string)
+	msg.Done, _ = data["done"].(bool)
+	msg.SessionID, _ = data["session_id"].(string)
+	msg.ParentToolUseID, _ = data["parent_tool_use_id"].(string)
+
+	switch index := data["index"].(type) {
+	case float64:
+		msg.Index = int(index)
+	case int:
+		msg.Index = index
+	}
+
+	if input, ok := data["input"].(map[string]any); ok {
+		msg.Input = input
+	}
+
+	return msg, nil
+}
+
+// EncodeMessage converts a Message into its wire representation, the inverse
+// of ParseMessage. It is useful for forwarding messages to other processes or
+// clients as JSON.
+//
+// DiagnosticEvent, IncrementalToolUse and PermissionModeChanged, which are
+// produced by the SDK rather than the CLI, are encoded with types
+// "diagnostic", "incremental_tool_use" and "permission_mode_changed", which
+// ParseMessage also accepts.
+func EncodeMessage(msg Message) (map[string]any, error) {
+	switch m := msg.(type) {
+	case *UserMessage:
+		return encodeUserMessage(m), nil
+	case UserMessage:
+		return encodeUserMessage(&m), nil
+	case *AssistantMessage:
+		return encodeAssistantMessage(m), nil
+	case AssistantMessage:
+		return encodeAssistantMessage(&m), nil
+	case *SystemMessage:
+		return encodeSystemMessage(m), nil
+	case SystemMessage:
+		return encodeSystemMessage(&m), nil
+	case *ResultMessage:
+		return encodeResultMessage(m), nil
+	case ResultMessage:
+		return encodeResultMessage(&m), nil
+	case *StreamEvent:
+		return encodeStreamEvent(m), nil
+	case StreamEvent:
+		return encodeStreamEvent(&m), nil
+	case *IncrementalToolUse:
+		return encodeIncrementalToolUse(m), nil
+	case IncrementalToolUse:
+		return encodeIncrementalToolUse(&m), nil
+	case *DiagnosticEvent:
+		return encodeDiagnosticEvent(m), nil
+	case DiagnosticEvent:
+		return encodeDiagnosticEvent(&m), nil
+	case *PermissionModeChanged:
+		return encodePermissionModeChanged(m), nil
+	case PermissionModeChanged:
+		return encodePermissionModeChanged(&m), nil
+	default:
+		return nil, NewClaudeSDKError(fmt.Sprintf("cannot encode message of type %T", msg))
+	}
+}
+
+func encodeUserMessage(m *UserMessage) map[string]any {
+	var content any = m.Content
+	if blocks, ok := m.Content.([]ContentBlock); ok {
+		content = encodeContentBlocks(blocks)
+	}
+
+	data := map[string]any{
+		"type": "user",
+		"message": map[string]any{
+			"role":    "user",
+			"content": content,
+		},
+	}
+	if m.UUID != "" {
+		data["uuid"] = m.UUID
+	}
+	if m.ParentToolUseID != "" {
+		data["parent_tool_use_id"] = m.ParentToolUseID
+	}
+	if m.ToolUseResult != nil {
+		data["tool_use_result"] = m.ToolUseResult
+	}
+	return data
+}
+
+func encodeAssistantMessage(m *AssistantMessage) map[string]any {
+	message := map[string]any{
+		"role":    "assistant",
+		"model":   m.Model,
+		"content": encodeContentBlocks(m.Content),
+	}
+	if m.Error != "" {
+		message["error"] = string(m.Error)
+	}
+
+	data := map[string]any{
+		"type":    "assistant",
+		"message": message,
+	}
+	if m.UUID != "" {
+		data["uuid"] = m.UUID
+	}
+	if m.ParentToolUseID != "" {
+		data["parent_tool_use_id"] = m.ParentToolUseID
+	}
+	return data
+}
+
+func encodeContentBlocks(blocks []ContentBlock) []any {
+	result := make([]any, 0, len(blocks))
+	for _, block := range blocks {
+		if encoded := encodeContentBlock(block); encoded != nil {
+			result = append(result, encoded)
+		}
+	}
+	return result
+}
+
+func encodeContentBlock(block ContentBlock) map[string]any {
+	switch b := block.(type) {
+	case TextBlock:
+		return map[string]any{"type": "text", "text": b.Text}
+	case ThinkingBlock:
+		return map[string]any{"type": "thinking", "thinking": b.Thinking, "signature": b.Signature}
+	case ToolUseBlock:
+		return map[string]any{"type": "tool_use", "id": b.ID, "name": b.Name, "input": b.Input}
+	case ToolResultBlock:
+		data := map[string]any{"type": "tool_result", "tool_use_id": b.ToolUseID}
+		if b.Content != nil {
+			data["content"] = b.Content
+		}
+		if b.IsError != nil {
+			data["is_error"] = *b.IsError
+		}
+		return data
+	default:
+		return nil
+	}
+}
+
+func encodeSystemMessage(m *SystemMessage) map[string]any {
+	data := make(map[string]any, len(m.Data)+2)
+	for k, v := range m.Data {
+		data[k] = v
+	}
+	data["type"] = "system"
+	data["subtype"] = m.Subtype
+	return data
+}
+
+func encodeResultMessage(m *ResultMessage) map[string]any {
+	data := map[string]any{
+		"type":            "result",
+		"subtype":         m.Subtype,
+		"duration_ms":     m.DurationMs,
+		"duration_api_ms": m.DurationAPIMs,
+		"is_error":        m.IsError,
+		"num_turns":       m.NumTurns,
+		"session_id":      m.SessionID,
+	}
+	if m.TotalCostUSD != nil {
+		data["total_cost_usd"] = *m.TotalCostUSD
+	}
+	if m.Usage != nil {
+		data["usage"] = m.Usage
+	}
+	if m.Result != "" {
+		data["result"] = m.Result
+	}
+	if m.StructuredOutput != nil {
+		data["structured_output"] = m.StructuredOutput
+	}
+	if m.Interrupted {
+		data["interrupted"] = true
+	}
+	if m.InterruptReason != "" {
+		data["interrupt_reason"] = m.InterruptReason
+	}
+	if m.Model != "" {
+		data["model"] = m.Model
+	}
+	return data
+}
+
+func encodeStreamEvent(m *StreamEvent) map[string]any {
+	data := map[string]any{
+		"type":       "stream_event",
+		"uuid":       m.UUID,
+		"session_id": m.SessionID,
+		"event":      m.Event,
+	}
+	if m.ParentToolUseID != "" {
+		data["parent_tool_use_id"] = m.ParentToolUseID
+	}
+	return data
+}
+
+func encodeIncrementalToolUse(m *IncrementalToolUse) map[string]any {
+	data := map[string]any{
+		"type":          "incremental_tool_use",
+		"id":            m.ID,
+		"name":          m.Name,
+		"index":         m.Index,
+		"partial_input": m.PartialInput,
+		"input":         m.Input,
+		"done":          m.Done,
+		"session_id":    m.SessionID,
+	}
+	if m.ParentToolUseID != "" {
+		data["parent_tool_use_id"] = m.ParentToolUseID
+	}
+	return data
+}
+
+func encodeDiagnosticEvent(m *DiagnosticEvent) map[string]any {
+	return map[string]any{
+		"type":    "diagnostic",
+		"kind":    string(m.Kind),
+		"message": m.Message,
+		"idle_ms": m.Idle.Milliseconds(),
+		"action":  string(m.Action),
+	}
+}
+
+func encodePermissionModeChanged(m *PermissionModeChanged) map[string]any {
+	return map[string]any{
+		"type":       "permission_mode_changed",
+		"mode":       string(m.Mode),
+		"previous":   string(m.Previous),
+		"source":     string(m.Source),
+		"session_id": m.SessionID,
+	}
+}
+
+// CollectText reads messages until the channel is closed and returns the
+// text of the assistant messages, joined by newlines. Messages of
+// subagents, which have a ParentToolUseID, are left out.
+func CollectText(messages <-chan Message) string {
+	var parts []string
+	for msg := range messages {
+		m, ok := msg.(*AssistantMessage)
+		if !ok || m.ParentToolUseID != "" {
+			continue
+		}
+		if text := m.Text(); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestParseMessage_NilData(t *testing.T) {
@@ -207,6 +208,7 @@ func TestParseMessage_AssistantMessage(t *testing.T) {
 						map[string]any{"type": "tool_use", "id": "tool-1", "name": "read_file", "input": map[string]any{"path": "/tmp"}},
 					},
 					"error": "rate_limit",
+					"usage": map[string]any{"input_tokens": float64(12), "output_tokens": float64(34)},
 				},
 				"parent_tool_use_id": "parent-123",
 			},
@@ -215,6 +217,9 @@ func TestParseMessage_AssistantMessage(t *testing.T) {
 				if msg.Model != "claude-3-opus" {
 					t.Errorf("Expected model 'claude-3-opus', got '%s'", msg.Model)
 				}
+				if tokens := msg.Tokens(); tokens.InputTokens != 12 || tokens.OutputTokens != 34 {
+					t.Errorf("Expected the usage of the response, got %+v", tokens)
+				}
 				if len(msg.Content) != 3 {
 					t.Errorf("Expected 3 content blocks, got %d", len(msg.Content))
 				}
@@ -804,3 +809,136 @@ func BenchmarkParseMessage_AssistantMessage(b *testing.B) {
 		_, _ = ParseMessage(data)
 	}
 }
+
+func TestEncodeMessage_RoundTrip(t *testing.T) {
+	cost := 0.01
+	isError := true
+	tests := []struct {
+		name string
+		msg  Message
+	}{
+		{"user string", &UserMessage{Content: "Hello", UUID: "u-1"}},
+		{"user blocks", &UserMessage{
+			Content: []ContentBlock{
+				ToolResultBlock{ToolUseID: "tool-1", Content: "output", IsError: &isError},
+			},
+			ParentToolUseID: "parent-1",
+		}},
+		{"assistant", &AssistantMessage{
+			Model: "claude-sonnet-4-5",
+			UUID:  "a-1",
+			Content: []ContentBlock{
+				TextBlock{Text: "Hi"},
+				ThinkingBlock{Thinking: "hmm", Signature: "sig"},
+				ToolUseBlock{ID: "tool-1", Name: "Read", Input: map[string]any{"file_path": "/tmp/x"}},
+			},
+		}},
+		{"system", &SystemMessage{Subtype: "init", Data: map[string]any{"type": "system", "subtype": "init", "model": "m"}}},
+		{"result", &ResultMessage{
+			Subtype:      "success",
+			DurationMs:   10,
+			NumTurns:     2,
+			SessionID:    "s-1",
+			TotalCostUSD: &cost,
+			Result:       "done",
+		}},
+		{"interrupted result", &ResultMessage{
+			Subtype:         "error_during_execution",
+			IsError:         true,
+			SessionID:       "s-1",
+			Interrupted:     true,
+			InterruptReason: "user cancelled",
+		}},
+		{"diagnostic", &DiagnosticEvent{
+			Kind:    DiagnosticKindStall,
+			Message: "no message for 2s",
+			Idle:    2 * time.Second,
+			Action:  StallActionInterrupt,
+		}},
+		{"incremental tool use", &IncrementalToolUse{
+			ID:           "tool-1",
+			Name:         "Write",
+			Index:        1,
+			PartialInput: `{"file_path":"/tmp/x","content":"hel`,
+			Input:        map[string]any{"file_path": "/tmp/x", "content": "hel"},
+			SessionID:    "s-1",
+		}},
+		{"stream event", &StreamEvent{UUID: "e-1", SessionID: "s-1", Event: map[string]any{"type": "message_start"}}},
+		{"permission mode changed", &PermissionModeChanged{
+			Mode:      PermissionModeAcceptEdits,
+			Previous:  PermissionModeDefault,
+			Source:    PermissionModeSourceCLI,
+			SessionID: "s-1",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := EncodeMessage(tt.msg)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			// Round-trip through JSON as a remote peer would
+			raw, err := json.Marshal(encoded)
+			if err != nil {
+				t.Fatalf("Failed to marshal: %v", err)
+			}
+			var data map[string]any
+			if err := json.Unmarshal(raw, &data); err != nil {
+				t.Fatalf("Failed to unmarshal: %v", err)
+			}
+
+			parsed, err := ParseMessage(data)
+			if err != nil {
+				t.Fatalf("Failed to parse encoded message: %v", err)
+			}
+
+			want, _ := json.Marshal(tt.msg)
+			got, _ := json.Marshal(parsed)
+			if string(want) != string(got) {
+				t.Errorf("Round trip mismatch:\nwant %s\ngot  %s", want, got)
+			}
+		})
+	}
+}
+
+func TestEncodeMessage_DiagnosticEvent(t *testing.T) {
+	data, err := EncodeMessage(&DiagnosticEvent{
+		Kind:   DiagnosticKindStall,
+		Idle:   2 * time.Second,
+		Action: StallActionInterrupt,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data["type"] != "diagnostic" {
+		t.Errorf("Expected type 'diagnostic', got %v", data["type"])
+	}
+	if data["idle_ms"] != int64(2000) {
+		t.Errorf("Expected idle_ms 2000, got %v", data["idle_ms"])
+	}
+	if data["action"] != "interrupt" {
+		t.Errorf("Expected action 'interrupt', got %v", data["action"])
+	}
+}
+
+func TestEncodeMessage_Nil(t *testing.T) {
+	if _, err := EncodeMessage(nil); err == nil {
+		t.Error("Expected error encoding nil message")
+	}
+}
+
+func TestCollectText(t *testing.T) {
+	messages := make(chan Message, 5)
+	messages <- &SystemMessage{Subtype: "init"}
+	messages <- &AssistantMessage{Content: []ContentBlock{TextBlock{Text: "Paris"}}}
+	messages <- &AssistantMessage{ParentToolUseID: "t1", Content: []ContentBlock{TextBlock{Text: "subagent"}}}
+	messages <- &AssistantMessage{Content: []ContentBlock{TextBlock{Text: "is the capital."}}}
+	messages <- &ResultMessage{Result: "Paris is the capital."}
+	close(messages)
+
+	if got := CollectText(messages); got != "Paris\nis the capital." {
+		t.Errorf("Unexpected text %q", got)
+	}
+}
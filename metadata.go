@@ -0,0 +1,51 @@
+package claude
+
+import "context"
+
+// requestMetadataKey is the context key for request metadata.
+type requestMetadataKey struct{}
+
+// WithRequestMetadata returns a copy of ctx carrying the given metadata.
+//
+// Metadata attached to the context passed to Query, QueryStreaming,
+// Client.Connect, or Client.Query is made available to hook callbacks,
+// CanUseTool callbacks, and MCP tool handlers invoked on behalf of that
+// request. Use it to correlate agent activity with trace IDs, tenants, or
+// users without global state.
+//
+// Metadata already present on ctx is merged, with keys in md taking precedence.
+//
+// Example:
+//
+//	ctx = claude.WithRequestMetadata(ctx, map[string]string{
+//		"trace_id": traceID,
+//		"tenant":   tenant,
+//	})
+//	messages, errs := claude.Query(ctx, "Summarize the report")
+func WithRequestMetadata(ctx context.Context, md map[string]string) context.Context {
+	merged := make(map[string]string)
+	if existing, ok := ctx.Value(requestMetadataKey{}).(map[string]string); ok {
+		for k, v := range existing {
+			merged[k] = v
+		}
+	}
+	for k, v := range md {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, requestMetadataKey{}, merged)
+}
+
+// RequestMetadataFromContext returns the request metadata carried by ctx.
+// Returns nil if no metadata is present. The returned map is a copy and
+// may be modified by the caller.
+func RequestMetadataFromContext(ctx context.Context) map[string]string {
+	md, ok := ctx.Value(requestMetadataKey{}).(map[string]string)
+	if !ok {
+		return nil
+	}
+	result := make(map[string]string, len(md))
+	for k, v := range md {
+		result[k] = v
+	}
+	return result
+}
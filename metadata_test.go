@@ -0,0 +1,63 @@
+package claude
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRequestMetadataFromContext_Empty(t *testing.T) {
+	if md := RequestMetadataFromContext(context.Background()); md != nil {
+		t.Errorf("Expected nil metadata, got %v", md)
+	}
+}
+
+func TestWithRequestMetadata(t *testing.T) {
+	ctx := WithRequestMetadata(context.Background(), map[string]string{
+		"trace_id": "abc",
+		"tenant":   "acme",
+	})
+
+	md := RequestMetadataFromContext(ctx)
+	if md["trace_id"] != "abc" {
+		t.Errorf("Expected trace_id 'abc', got '%s'", md["trace_id"])
+	}
+	if md["tenant"] != "acme" {
+		t.Errorf("Expected tenant 'acme', got '%s'", md["tenant"])
+	}
+}
+
+func TestWithRequestMetadata_Merges(t *testing.T) {
+	ctx := WithRequestMetadata(context.Background(), map[string]string{
+		"trace_id": "abc",
+		"tenant":   "acme",
+	})
+	ctx = WithRequestMetadata(ctx, map[string]string{"trace_id": "def"})
+
+	md := RequestMetadataFromContext(ctx)
+	if md["trace_id"] != "def" {
+		t.Errorf("Expected overridden trace_id 'def', got '%s'", md["trace_id"])
+	}
+	if md["tenant"] != "acme" {
+		t.Errorf("Expected preserved tenant 'acme', got '%s'", md["tenant"])
+	}
+}
+
+func TestWithRequestMetadata_DoesNotMutateParent(t *testing.T) {
+	parent := WithRequestMetadata(context.Background(), map[string]string{"a": "1"})
+	_ = WithRequestMetadata(parent, map[string]string{"a": "2"})
+
+	if md := RequestMetadataFromContext(parent); md["a"] != "1" {
+		t.Errorf("Expected parent metadata unchanged, got '%s'", md["a"])
+	}
+}
+
+func TestRequestMetadataFromContext_ReturnsCopy(t *testing.T) {
+	ctx := WithRequestMetadata(context.Background(), map[string]string{"a": "1"})
+
+	md := RequestMetadataFromContext(ctx)
+	md["a"] = "changed"
+
+	if again := RequestMetadataFromContext(ctx); again["a"] != "1" {
+		t.Errorf("Expected stored metadata unchanged, got '%s'", again["a"])
+	}
+}
@@ -0,0 +1,170 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Model aliases accepted by WithModel. The CLI resolves each to the latest
+// model of its family.
+const (
+	ModelSonnet = "sonnet"
+	ModelOpus   = "opus"
+	ModelHaiku  = "haiku"
+)
+
+// modelAliases maps aliases to the model family they currently resolve to.
+var modelAliases = map[string]string{
+	ModelSonnet: "claude-sonnet-4-5",
+	ModelOpus:   "claude-opus-4-1",
+	ModelHaiku:  "claude-haiku-4-5",
+}
+
+// datedModels maps model families to their dated identifiers.
+var datedModels = map[string]string{
+	"claude-sonnet-4-5": "claude-sonnet-4-5-20250929",
+	"claude-sonnet-4":   "claude-sonnet-4-20250514",
+	"claude-opus-4-1":   "claude-opus-4-1-20250805",
+	"claude-opus-4":     "claude-opus-4-20250514",
+	"claude-haiku-4-5":  "claude-haiku-4-5-20251001",
+	"claude-3-7-sonnet": "claude-3-7-sonnet-20250219",
+	"claude-3-5-haiku":  "claude-3-5-haiku-20241022",
+}
+
+// ResolveModel returns the dated identifier of a model alias such as
+// ModelSonnet or of an undated family such as "claude-sonnet-4-5", as the
+// SDK knows them. Other models, including unknown and already dated ones,
+// are returned unchanged.
+//
+// This is useful to compare a configured model with the Model reported in
+// an AssistantMessage.
+func ResolveModel(model string) string {
+	if family, ok := modelAliases[model]; ok {
+		model = family
+	}
+	if dated, ok := datedModels[model]; ok {
+		return dated
+	}
+	return model
+}
+
+// validateModel fails fast on model names that cannot be a Claude model,
+// such as misspelled aliases. Provider-specific identifiers are accepted
+// as long as they name a Claude model.
+func validateModel(model string) error {
+	if model == "" || model == "default" || model == "opusplan" {
+		return nil
+	}
+	name, _, _ := strings.Cut(model, "[")
+	if _, ok := modelAliases[name]; ok {
+		return nil
+	}
+	if strings.Contains(strings.ToLower(model), "claude") || strings.HasPrefix(model, "arn:") {
+		return nil
+	}
+	return NewClaudeSDKError(fmt.Sprintf("unknown model %q: use an alias such as claude.ModelSonnet or a Claude model ID", model))
+}
+
+// ModelInfo describes a model the CLI offers.
+type ModelInfo struct {
+	// Value is what to pass to WithModel.
+	Value       string `json:"value"`
+	DisplayName string `json:"displayName"`
+	Description string `json:"description"`
+}
+
+// ModelCatalog lists the models and betas available to the CLI.
+type ModelCatalog struct {
+	Models []ModelInfo
+	// Betas lists the betas the CLI reports, or those the SDK knows of
+	// if it reports none.
+	Betas []SdkBeta
+}
+
+// knownBetas lists the betas the SDK defines constants for.
+var knownBetas = []SdkBeta{SdkBetaContext1M}
+
+// Models starts the CLI to ask which models and betas are available for
+// the configured account and provider. Options such as WithCLIPath and
+// WithEnv apply.
+//
+// Example:
+//
+//	catalog, err := claude.Models(ctx)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	for _, m := range catalog.Models {
+//	    fmt.Println(m.Value, "-", m.Description)
+//	}
+func Models(ctx context.Context, opts ...Option) (*ModelCatalog, error) {
+	client := NewClient(opts...)
+	if err := client.Connect(ctx); err != nil {
+		return nil, err
+	}
+	defer func() { _ = client.Close() }()
+
+	return parseModelCatalog(client.GetServerInfo()), nil
+}
+
+// parseModelCatalog extracts the model catalog from the CLI's initialize
+// response.
+func parseModelCatalog(info map[string]any) *ModelCatalog {
+	catalog := &ModelCatalog{}
+
+	models, _ := info["models"].([]any)
+	for _, m := range models {
+		model, ok := m.(map[string]any)
+		if !ok {
+			continue
+		}
+		var mi ModelInfo
+		mi.Value, _ = model["value"].(string)
+		mi.DisplayName, _ = model["displayName"].(string)
+		mi.Description, _ = model["description"].(string)
+		if mi.Value != "" {
+			catalog.Models = append(catalog.Models, mi)
+		}
+	}
+
+	catalog.Betas = reportedBetas(info)
+	if len(catalog.Betas) == 0 {
+		catalog.Betas = append([]SdkBeta(nil), knownBetas...)
+	}
+	return catalog
+}
+
+// reportedBetas returns the betas listed in the CLI's initialize response,
+// or nil if it lists none.
+func reportedBetas(info map[string]any) []SdkBeta {
+	var betas []SdkBeta
+	list, _ := info["betas"].([]any)
+	for _, b := range list {
+		if beta, ok := b.(string); ok {
+			betas = append(betas, SdkBeta(beta))
+		}
+	}
+	return betas
+}
+
+// checkBetas fails with an UnsupportedBetaError if a requested beta is not
+// among those the CLI reports in its initialize response. CLIs that do
+// not report their betas are not checked.
+func checkBetas(requested []SdkBeta, info map[string]any) error {
+	available := reportedBetas(info)
+	if len(requested) == 0 || len(available) == 0 {
+		return nil
+	}
+	var unsupported []SdkBeta
+	for _, beta := range requested {
+		if !slices.Contains(available, beta) {
+			unsupported = append(unsupported, beta)
+		}
+	}
+	if len(unsupported) > 0 {
+		return NewUnsupportedBetaError(unsupported, available)
+	}
+	return nil
+}
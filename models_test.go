@@ -0,0 +1,154 @@
+package claude
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveModel(t *testing.T) {
+	tests := map[string]string{
+		ModelSonnet:                  "claude-sonnet-4-5-20250929",
+		ModelOpus:                    "claude-opus-4-1-20250805",
+		ModelHaiku:                   "claude-haiku-4-5-20251001",
+		"claude-sonnet-4":            "claude-sonnet-4-20250514",
+		"claude-sonnet-4-5-20250929": "claude-sonnet-4-5-20250929",
+		"custom-model":               "custom-model",
+	}
+	for model, want := range tests {
+		if got := ResolveModel(model); got != want {
+			t.Errorf("ResolveModel(%q) = %q, want %q", model, got, want)
+		}
+	}
+}
+
+func TestValidateModel(t *testing.T) {
+	valid := []string{
+		"", "default", "opusplan", ModelSonnet, "sonnet[1m]",
+		"claude-sonnet-4-5", "us.anthropic.claude-sonnet-4-5-20250929-v1:0",
+		"claude-opus-4-1@20250805", "arn:aws:bedrock:us-east-1:123:application-inference-profile/abc",
+	}
+	for _, model := range valid {
+		if err := validateModel(model); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", model, err)
+		}
+	}
+
+	for _, model := range []string{"sonet", "gpt-4o"} {
+		if err := validateModel(model); err == nil {
+			t.Errorf("Expected %q to be rejected", model)
+		}
+	}
+}
+
+func TestValidateOptions_FallbackModel(t *testing.T) {
+	err := validateOptions(NewOptions(WithModel(ModelOpus), WithFallbackModel("sonet")))
+	if err == nil || !strings.Contains(err.Error(), `"sonet"`) {
+		t.Errorf("Expected error for the fallback model, got %v", err)
+	}
+}
+
+func TestParseModelCatalog(t *testing.T) {
+	catalog := parseModelCatalog(map[string]any{
+		"models": []any{
+			map[string]any{"value": "default", "displayName": "Default", "description": "Recommended"},
+			map[string]any{"displayName": "No value"},
+			"invalid",
+		},
+	})
+
+	want := []ModelInfo{{Value: "default", DisplayName: "Default", Description: "Recommended"}}
+	if !reflect.DeepEqual(catalog.Models, want) {
+		t.Errorf("Expected models %+v, got %+v", want, catalog.Models)
+	}
+	if !reflect.DeepEqual(catalog.Betas, knownBetas) {
+		t.Errorf("Expected known betas when the CLI reports none, got %v", catalog.Betas)
+	}
+
+	catalog = parseModelCatalog(map[string]any{"betas": []any{"beta-1"}})
+	if !reflect.DeepEqual(catalog.Betas, []SdkBeta{"beta-1"}) {
+		t.Errorf("Expected reported betas, got %v", catalog.Betas)
+	}
+}
+
+func TestModels(t *testing.T) {
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{"models":[{"value":"sonnet","displayName":"Sonnet","description":"Everyday tasks"},{"value":"opus","displayName":"Opus","description":"Complex tasks"}]}}}'
+cat > /dev/null
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	catalog, err := Models(ctx, WithCLIPath(cli))
+	if err != nil {
+		t.Fatalf("Models failed: %v", err)
+	}
+	if len(catalog.Models) != 2 || catalog.Models[1].Value != ModelOpus {
+		t.Errorf("Unexpected models: %+v", catalog.Models)
+	}
+}
+
+func TestCheckBetas(t *testing.T) {
+	info := map[string]any{"betas": []any{string(SdkBetaContext1M)}}
+
+	if err := checkBetas([]SdkBeta{SdkBetaContext1M}, info); err != nil {
+		t.Errorf("Expected a supported beta to pass, got %v", err)
+	}
+	if err := checkBetas([]SdkBeta{"stale-beta"}, nil); err != nil {
+		t.Errorf("Expected no check when the CLI reports no betas, got %v", err)
+	}
+
+	err := checkBetas([]SdkBeta{SdkBetaContext1M, "stale-beta"}, info)
+	betaErr, ok := AsUnsupportedBetaError(err)
+	if !ok {
+		t.Fatalf("Expected UnsupportedBetaError, got %v", err)
+	}
+	if !reflect.DeepEqual(betaErr.Betas, []SdkBeta{"stale-beta"}) {
+		t.Errorf("Expected the stale beta, got %v", betaErr.Betas)
+	}
+	if !reflect.DeepEqual(betaErr.Available, []SdkBeta{SdkBetaContext1M}) {
+		t.Errorf("Expected the available betas, got %v", betaErr.Available)
+	}
+	if !strings.Contains(err.Error(), "stale-beta") {
+		t.Errorf("Expected the beta in the message, got %q", err.Error())
+	}
+}
+
+func TestClient_Betas(t *testing.T) {
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{"betas":["context-1m-2025-08-07","beta-2"]}}}'
+cat > /dev/null
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli), WithBetas([]SdkBeta{SdkBetaContext1M}))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	betas, err := client.AvailableBetas(ctx)
+	if err != nil {
+		t.Fatalf("AvailableBetas failed: %v", err)
+	}
+	if !reflect.DeepEqual(betas, []SdkBeta{SdkBetaContext1M, "beta-2"}) {
+		t.Errorf("Unexpected betas: %v", betas)
+	}
+	_ = client.Close()
+
+	client = NewClient(WithCLIPath(cli), WithBetas([]SdkBeta{"stale-beta"}))
+	err = client.Connect(ctx)
+	if !IsUnsupportedBetaError(err) {
+		t.Fatalf("Expected UnsupportedBetaError, got %v", err)
+	}
+	if _, err := client.AvailableBetas(ctx); !IsConnectionError(err) {
+		t.Errorf("Expected a connection error after a failed Connect, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package claude
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// proxyURL parses the proxy set with WithHTTPProxy.
+func proxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("no host")
+	}
+	return u, nil
+}
+
+// localProxyPort returns the port of a proxy on the loopback interface,
+// which a sandbox can send its commands' traffic through, and whether it
+// is a SOCKS proxy. It returns 0 for remote proxies and unparsable URLs.
+func localProxyPort(raw string) (port int, socks bool) {
+	u, err := proxyURL(raw)
+	if err != nil {
+		return 0, false
+	}
+	host := u.Hostname()
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return 0, false
+	}
+	socks = strings.HasPrefix(u.Scheme, "socks")
+	port, err = strconv.Atoi(u.Port())
+	if err != nil {
+		switch u.Scheme {
+		case "http":
+			port = 80
+		case "https":
+			port = 443
+		default:
+			port = 1080
+		}
+	}
+	return port, socks
+}
+
+// sandboxProxyPorts returns the proxy ports for the sandbox's network
+// settings: those set explicitly, or else the port of a local proxy set
+// with WithHTTPProxy, so that sandboxed commands use the same proxy as
+// the CLI.
+func sandboxProxyPorts(o *Options) (httpPort, socksPort int) {
+	if o.Sandbox != nil && o.Sandbox.Network != nil {
+		httpPort, socksPort = o.Sandbox.Network.HTTPProxyPort, o.Sandbox.Network.SOCKSProxyPort
+	}
+	if httpPort != 0 || socksPort != 0 {
+		return httpPort, socksPort
+	}
+	port, socks := localProxyPort(o.HTTPProxy)
+	if socks {
+		return 0, port
+	}
+	return port, 0
+}
+
+// validateNetwork checks the proxy and CA bundle settings, and that they
+// agree with the sandbox's network settings.
+func validateNetwork(o *Options) error {
+	if o.HTTPProxy != "" {
+		if _, err := proxyURL(o.HTTPProxy); err != nil {
+			return NewClaudeSDKError(fmt.Sprintf("invalid HTTP proxy %q: %v", o.HTTPProxy, err))
+		}
+	}
+	for _, host := range o.NoProxy {
+		if strings.TrimSpace(host) == "" || strings.ContainsAny(host, ", ") {
+			return NewClaudeSDKError(fmt.Sprintf("invalid no-proxy host %q", host))
+		}
+	}
+
+	// Inside a container or on a remote host the bundle's path is not
+	// local
+	if o.CABundle != "" && o.Container == nil && o.SSH == nil {
+		info, err := os.Stat(o.CABundle)
+		if err != nil {
+			return WrapClaudeSDKError("CA bundle not found", err)
+		}
+		if info.IsDir() {
+			return NewClaudeSDKError(fmt.Sprintf("CA bundle %s is a directory", o.CABundle))
+		}
+	}
+
+	if o.Sandbox != nil && o.Sandbox.Network != nil {
+		port, socks := localProxyPort(o.HTTPProxy)
+		explicit := o.Sandbox.Network.HTTPProxyPort
+		if socks {
+			explicit = o.Sandbox.Network.SOCKSProxyPort
+		}
+		if port != 0 && explicit != 0 && explicit != port {
+			return NewClaudeSDKError(fmt.Sprintf(
+				"sandbox proxy port %d differs from the port of HTTP proxy %s", explicit, o.HTTPProxy))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package claude
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateNetwork(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "corp-ca.pem")
+	if err := os.WriteFile(bundle, []byte("-----BEGIN CERTIFICATE-----\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sandbox := func(httpPort int) Option {
+		return WithSandbox(&SandboxSettings{Enabled: true, Network: &SandboxNetworkConfig{HTTPProxyPort: httpPort}})
+	}
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr string
+	}{
+		{"defaults", nil, ""},
+		{"proxy", []Option{WithHTTPProxy("http://proxy.corp:3128"), WithNoProxy([]string{"localhost", ".corp"})}, ""},
+		{"socks proxy", []Option{WithHTTPProxy("socks5://127.0.0.1:1080")}, ""},
+		{"no scheme", []Option{WithHTTPProxy("proxy.corp:3128")}, "invalid HTTP proxy"},
+		{"no host", []Option{WithHTTPProxy("http://:3128")}, "no host"},
+		{"empty no-proxy host", []Option{WithNoProxy([]string{""})}, "invalid no-proxy host"},
+		{"joined no-proxy hosts", []Option{WithNoProxy([]string{"a.corp,b.corp"})}, "invalid no-proxy host"},
+		{"CA bundle", []Option{WithCABundle(bundle)}, ""},
+		{"missing CA bundle", []Option{WithCABundle(bundle + ".missing")}, "CA bundle not found"},
+		{"CA bundle directory", []Option{WithCABundle(filepath.Dir(bundle))}, "is a directory"},
+		{"remote CA bundle", []Option{WithCABundle("/etc/ssl/corp.pem"), WithSSHRemote(&SSHRemote{Host: "build"})}, ""},
+		{"matching sandbox port", []Option{WithHTTPProxy("http://localhost:8080"), sandbox(8080)}, ""},
+		{"remote proxy with sandbox port", []Option{WithHTTPProxy("http://proxy.corp:3128"), sandbox(8080)}, ""},
+		{"conflicting sandbox port", []Option{WithHTTPProxy("http://localhost:8080"), sandbox(9090)}, "sandbox proxy port 9090"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateNetwork(NewOptions(tt.opts...))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestToTransportOptions_Proxy(t *testing.T) {
+	result := toTransportOptions(NewOptions(
+		WithHTTPProxy("http://proxy.corp:3128"),
+		WithNoProxy([]string{"localhost"}),
+		WithCABundle("/etc/ssl/corp.pem"),
+	))
+	if result.Proxy == nil || result.Proxy.HTTPProxy != "http://proxy.corp:3128" ||
+		result.Proxy.CABundle != "/etc/ssl/corp.pem" || len(result.Proxy.NoProxy) != 1 {
+		t.Errorf("Expected proxy settings, got %+v", result.Proxy)
+	}
+	if toTransportOptions(NewOptions()).Proxy != nil {
+		t.Error("Expected no proxy settings by default")
+	}
+}
+
+func TestToTransportOptions_SandboxProxy(t *testing.T) {
+	tests := []struct {
+		name      string
+		proxy     string
+		network   *SandboxNetworkConfig
+		wantHTTP  int
+		wantSOCKS int
+	}{
+		{"local http proxy", "http://127.0.0.1:8080", nil, 8080, 0},
+		{"local proxy without port", "http://localhost", nil, 80, 0},
+		{"local socks proxy", "socks5://localhost:1080", nil, 0, 1080},
+		{"remote proxy", "http://proxy.corp:3128", nil, 0, 0},
+		{"explicit port", "http://127.0.0.1:8080", &SandboxNetworkConfig{SOCKSProxyPort: 1081}, 0, 1081},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := toTransportOptions(NewOptions(
+				WithHTTPProxy(tt.proxy),
+				WithSandbox(&SandboxSettings{Enabled: true, Network: tt.network}),
+			))
+			var httpPort, socksPort int
+			if network := result.Sandbox.Network; network != nil {
+				httpPort, socksPort = network.HTTPProxyPort, network.SOCKSProxyPort
+			}
+			if httpPort != tt.wantHTTP || socksPort != tt.wantSOCKS {
+				t.Errorf("Expected ports %d and %d, got %d and %d", tt.wantHTTP, tt.wantSOCKS, httpPort, socksPort)
+			}
+		})
+	}
+
+	disabled := toTransportOptions(NewOptions(
+		WithHTTPProxy("http://127.0.0.1:8080"),
+		WithSandbox(&SandboxSettings{}),
+	))
+	if disabled.Sandbox.Network != nil {
+		t.Errorf("Expected no proxy port for a disabled sandbox, got %+v", disabled.Sandbox.Network)
+	}
+}
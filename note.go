@@ -0,0 +1,74 @@
+package claude
+
+import "sync"
+
+// noteQueue holds the notes inserted with Client.InjectSystemNote until
+// they are emitted on the message stream. notify is signalled when a note
+// is added.
+type noteQueue struct {
+	mu     sync.Mutex
+	notes  []*SystemMessage
+	notify chan struct{}
+}
+
+func newNoteQueue() *noteQueue {
+	return &noteQueue{notify: make(chan struct{}, 1)}
+}
+
+// add queues msg.
+func (q *noteQueue) add(msg *SystemMessage) {
+	q.mu.Lock()
+	q.notes = append(q.notes, msg)
+	q.mu.Unlock()
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// take returns and clears the queued notes.
+func (q *noteQueue) take() []*SystemMessage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	notes := q.notes
+	q.notes = nil
+	return notes
+}
+
+// InjectSystemNote inserts a SystemMessage with Subtype SystemSubtypeNote
+// and text in Data["text"] into the message stream of c, after the
+// messages already received. The note is only seen by readers of
+// Messages and ReceiveResponse: it is not sent to Claude, nor written to
+// transcripts. This lets an application interleave its own notices, such
+// as a tool denied by its policy, with the conversation.
+//
+// It fails with a CLIConnectionError if c is not connected.
+//
+// Example:
+//
+//	_ = client.InjectSystemNote("Bash denied by policy: no network access")
+//
+//	for msg := range client.Messages() {
+//	    if m, ok := msg.(*claude.SystemMessage); ok && m.IsNote() {
+//	        showNotice(m.Data["text"].(string))
+//	    }
+//	}
+func (c *Client) InjectSystemNote(text string) error {
+	sessionID := c.SessionID()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.connected {
+		return NewCLIConnectionError("Not connected. Call Connect() first.")
+	}
+	c.notes.add(&SystemMessage{
+		Subtype: SystemSubtypeNote,
+		Data: map[string]any{
+			"type":       "system",
+			"subtype":    SystemSubtypeNote,
+			"text":       text,
+			"session_id": sessionID,
+		},
+	})
+	return nil
+}
@@ -0,0 +1,56 @@
+package claude
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestClient_InjectSystemNote(t *testing.T) {
+	if err := NewClient().InjectSystemNote("note"); err == nil {
+		t.Error("Expected an error before Connect")
+	}
+
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+read line
+echo '{"type":"assistant","message":{"role":"assistant","model":"claude-sonnet-4-5","content":[{"type":"text","text":"Working on it"}]},"session_id":"s"}'
+sleep 0.5
+echo '{"type":"result","subtype":"success","is_error":false,"session_id":"s"}'
+cat > /dev/null
+`)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.Query(ctx, "hello"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	var got []Message
+	for msg := range client.ReceiveResponse(ctx) {
+		got = append(got, msg)
+		if _, ok := msg.(*AssistantMessage); ok {
+			if err := client.InjectSystemNote("Bash denied by policy"); err != nil {
+				t.Fatalf("InjectSystemNote failed: %v", err)
+			}
+		}
+	}
+
+	if len(got) != 3 {
+		t.Fatalf("Expected the assistant message, the note, and the result, got %+v", got)
+	}
+	note, ok := got[1].(*SystemMessage)
+	if !ok || !note.IsNote() || note.Data["text"] != "Bash denied by policy" {
+		t.Errorf("Expected the note between the assistant message and the result, got %+v", got[1])
+	}
+	if _, ok := got[2].(*ResultMessage); !ok {
+		t.Errorf("Expected the result last, got %+v", got[2])
+	}
+}
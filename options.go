@@ -2,7 +2,10 @@ package claude
 
 import (
 	"io"
+	"maps"
 	"os"
+	"slices"
+	"time"
 )
 
 // Options configures Claude SDK behavior.
@@ -75,8 +78,15 @@ type Options struct {
 	// ExtraArgs specifies additional CLI flags.
 	ExtraArgs map[string]*string
 
-	// MaxBufferSize limits the buffer size for CLI stdout.
+	// MaxBufferSize limits the size of messages from the CLI, 1MB by
+	// default.
 	MaxBufferSize int
+	// MaxStderrLineSize limits the size of lines of stderr, 1MB by default.
+	// Longer lines are truncated.
+	MaxStderrLineSize int
+	// MaxControlResponseSize limits the size of control responses, 10MB by
+	// default.
+	MaxControlResponseSize int
 
 	// DebugStderr is deprecated: use Stderr callback instead.
 	DebugStderr io.Writer
@@ -120,6 +130,202 @@ type Options struct {
 
 	// EnableFileCheckpointing enables file checkpointing.
 	EnableFileCheckpointing bool
+
+	// StallTimeout is how long a turn may go without receiving a message
+	// before StallAction is taken. Zero disables stall detection.
+	StallTimeout time.Duration
+
+	// StallAction is the action taken when a turn stalls.
+	StallAction StallAction
+
+	// DetachedStateDir is where detached sessions persist their state.
+	// Defaults to a directory under os.UserCacheDir.
+	DetachedStateDir string
+
+	// ResumeSessionAt truncates a resumed session after the message with
+	// this UUID. Requires Resume.
+	ResumeSessionAt string
+
+	// MCPServersAllowed lists MCP servers whose tools are added to
+	// AllowedTools.
+	MCPServersAllowed []string
+
+	// WatchPaths lists files and directories whose changes are reported
+	// to Claude at the next prompt of a Client session.
+	WatchPaths []string
+
+	// StructuredOutputRetries, if set, validates structured output
+	// against the WithJSONSchema schema and re-prompts Claude up to this
+	// many times when it is missing or invalid.
+	StructuredOutputRetries *int
+
+	// CancelBehavior is what a Client does when the context of a turn in
+	// progress is cancelled. Empty means CancelBehaviorInterrupt.
+	CancelBehavior CancelBehavior
+
+	// SessionMetadataDir is where session titles and annotations are
+	// kept. Defaults to a directory under os.UserCacheDir.
+	SessionMetadataDir string
+
+	// Temperature, TopP, and Seed are sampling parameters. Nil leaves the
+	// model's default.
+	Temperature *float64
+	TopP        *float64
+	Seed        *int64
+
+	// ModelFallbacks are the models a turn is retried with, in order,
+	// when it fails with a rate limit or server error.
+	ModelFallbacks []string
+
+	// HookRegistry resolves the hook names referenced by NamedHooks.
+	HookRegistry *HookRegistry
+
+	// NamedHooks configures registered hooks by name. They run after
+	// those of Hooks for the same event.
+	NamedHooks HookConfig
+
+	// RateLimitPacer, if set, holds new turns while a rate limit is in
+	// effect, and is paused when a turn ends on one.
+	RateLimitPacer *RateLimitPacer
+
+	// MCPPreflightTimeout, if positive, makes stdio MCP servers be
+	// checked before the CLI starts, each within this timeout.
+	MCPPreflightTimeout time.Duration
+
+	// KeepAliveInterval, if positive, is how often a Client sends the CLI
+	// a keep_alive message while connected.
+	KeepAliveInterval time.Duration
+
+	// IdleTimeout, if positive, is how long a Client may go without a
+	// turn before it closes the connection.
+	IdleTimeout time.Duration
+
+	// Container, if set, runs the CLI inside a container.
+	Container *ContainerRuntime
+
+	// SSH, if set, runs the CLI on a remote host over SSH.
+	SSH *SSHRemote
+
+	// Cache, if set, caches the messages of Query results.
+	Cache Cache
+
+	// AutoContinue, if set, is asked after each turn of a Client whether
+	// to follow up with another prompt, at most AutoContinueMaxLoops times
+	// per turn and while the session costs less than
+	// AutoContinueMaxCostUSD, if positive.
+	AutoContinue           func(*ResultMessage) (string, bool)
+	AutoContinueMaxLoops   int
+	AutoContinueMaxCostUSD float64
+
+	// CostAlerts are called when the cost of a turn or of the session
+	// crosses their thresholds.
+	CostAlerts []CostAlert
+
+	// Provider is the model provider chosen with WithAPIKey, WithBedrock or
+	// WithVertex, whose settings are checked before the CLI is started.
+	Provider AuthMethod
+
+	// HTTPProxy, NoProxy and CABundle route the CLI's traffic through a
+	// proxy, and trust the certificate authorities of CABundle.
+	HTTPProxy string
+	NoProxy   []string
+	CABundle  string
+
+	// LowAllocParsing decodes the CLI's messages with a decoder that
+	// allocates less than encoding/json.
+	LowAllocParsing bool
+
+	// BusyBehavior is what Client.Query does when called while a turn is
+	// in progress. Empty means BusyBehaviorQueue.
+	BusyBehavior BusyBehavior
+
+	// AskResolver resolves the asks of CanUseTool before they are left to
+	// the CLI.
+	AskResolver AskResolverFunc
+
+	// ContextFiles are files put before the first prompt or in the system
+	// prompt, as set by ContextFilePlacement. Their contents are cut down
+	// to ContextFileMaxTokens, DefaultContextFileMaxTokens if zero, as set
+	// by ContextFileTruncation.
+	ContextFiles          []string
+	ContextFilePlacement  ContextFilePlacement
+	ContextFileMaxTokens  int
+	ContextFileTruncation ContextFileTruncation
+
+	// TranscriptDir is where transcripts of sessions are written, in the
+	// CLI's format.
+	TranscriptDir string
+
+	// ConversationTree records the sessions of queries and clients and
+	// the forks between them.
+	ConversationTree *ConversationTree
+
+	// PropagateDeadline bounds turns by the deadline of their context,
+	// less DeadlineMargin.
+	PropagateDeadline bool
+	DeadlineMargin    time.Duration
+
+	// SharedMCPServers runs the stdio MCP servers of clients, shared with
+	// other clients using the same registry.
+	SharedMCPServers *SharedMCPRegistry
+
+	// CloseInterruptGrace and CloseTerminateGrace are how long
+	// Client.CloseContext waits for the CLI to exit after SIGINT and
+	// SIGTERM: DefaultCloseGrace if zero, and not sent if negative.
+	CloseInterruptGrace time.Duration
+	CloseTerminateGrace time.Duration
+
+	// SystemPromptFragments are appended to the system prompt after
+	// AppendSystemPrompt, by Order.
+	SystemPromptFragments []SystemPromptFragment
+
+	// TempDir is the directory under which the SDK keeps the temp files it
+	// passes to the CLI. Defaults to the system temp directory.
+	TempDir string
+
+	// WireLog receives the raw JSON lines exchanged with the CLI, with the
+	// values of the WireLogRedact fields replaced.
+	WireLog       io.Writer
+	WireLogRedact []string
+
+	// MessageInterceptors are applied, in order, to each message before
+	// it is delivered.
+	MessageInterceptors []func(Message) Message
+
+	// Locale is the BCP 47 language tag of the strings the SDK writes for
+	// Claude and users, English if empty.
+	Locale string
+
+	// PipeDir is the directory of the named pipes a Client talks to the
+	// CLI over, instead of the pipes of the process.
+	PipeDir string
+
+	// AssistantErrorHandler decides what to do about assistant messages
+	// that fail.
+	AssistantErrorHandler func(AssistantMessageError, *AssistantMessage) ErrorAction
+
+	// DryRun simulates the tools that are not read-only instead of running
+	// them; DryRunReadOnlyTools are run besides the built-in read-only
+	// tools.
+	DryRun              bool
+	DryRunReadOnlyTools []ToolName
+
+	// PlanApprover decides on the plans Claude proposes in plan mode.
+	PlanApprover PlanApproverFunc
+
+	// ContextWindow is the context window of the model in tokens, for
+	// Client.ContextUsage; if zero, it is told from the model.
+	// ContextWarnings are the shares of it, from 0 to 1, past which a
+	// Client warns.
+	ContextWindow   int
+	ContextWarnings []float64
+
+	// WorkspaceLock, if set, locks the working directory while a Client
+	// is connected or a query runs, so that agents sharing it take turns.
+	// WorkspaceLockDir is where lock files are kept; it defaults to a
+	// directory under os.UserCacheDir.
+	WorkspaceLock    WorkspaceLockMode
+	WorkspaceLockDir string
 }
 
 // Option is a functional option for configuring Options.
@@ -137,6 +343,41 @@ func NewOptions(opts ...Option) *Options {
 	return o
 }
 
+// validateOptions checks options that would otherwise only fail once the
+// CLI is running.
+func validateOptions(o *Options) error {
+	if err := validateModel(o.Model); err != nil {
+		return err
+	}
+	if err := validateModel(o.FallbackModel); err != nil {
+		return err
+	}
+	for _, model := range o.ModelFallbacks {
+		if err := validateModel(model); err != nil {
+			return err
+		}
+	}
+	if _, err := o.HookRegistry.Resolve(o.NamedHooks); err != nil {
+		return err
+	}
+	if o.Container != nil && o.SSH != nil {
+		return NewClaudeSDKError("a container runtime and an SSH remote cannot be combined")
+	}
+	if err := validateProvider(o); err != nil {
+		return err
+	}
+	if err := validateNetwork(o); err != nil {
+		return err
+	}
+	if err := validateContextUsage(o); err != nil {
+		return err
+	}
+	if err := validateWorkspaceLock(o); err != nil {
+		return err
+	}
+	return validateSampling(o)
+}
+
 // WithTools sets the tools to use.
 func WithTools(tools []string) Option {
 	return func(o *Options) {
@@ -193,6 +434,38 @@ func WithPermissionMode(mode PermissionMode) Option {
 	}
 }
 
+// WithPlanMode starts the session in plan mode, in which Claude explores
+// and proposes a plan, with the ExitPlanMode tool, rather than editing
+// files or running commands. Combine with WithPlanApprover to decide on
+// the plan; otherwise the CLI's permission prompt does.
+func WithPlanMode() Option {
+	return WithPermissionMode(PermissionModePlan)
+}
+
+// WithPlanApprover sets fn to decide on the plans Claude proposes with the
+// ExitPlanMode tool. On approval the session leaves plan mode for the mode
+// of the decision, and a Client emits a PermissionModeChanged of source
+// PermissionModeSourcePlan. On rejection Claude is given the feedback of
+// the decision and stays in plan mode. Other tools are left to the
+// CanUseTool callback, if any.
+//
+// Example:
+//
+//	client := claude.NewClient(
+//	    claude.WithPlanMode(),
+//	    claude.WithPlanApprover(func(ctx context.Context, p claude.PlanProposal) (claude.PlanDecision, error) {
+//	        if strings.Contains(p.Plan, "migration") {
+//	            return claude.RejectPlan("Do not touch the database schema."), nil
+//	        }
+//	        return claude.ApprovePlan(claude.PermissionModeAcceptEdits), nil
+//	    }),
+//	)
+func WithPlanApprover(fn PlanApproverFunc) Option {
+	return func(o *Options) {
+		o.PlanApprover = fn
+	}
+}
+
 // WithContinueConversation enables continuing from the last conversation.
 func WithContinueConversation(cont bool) Option {
 	return func(o *Options) {
@@ -228,7 +501,8 @@ func WithDisallowedTools(tools []string) Option {
 	}
 }
 
-// WithModel sets the model to use.
+// WithModel sets the model to use: an alias such as ModelSonnet, or a
+// model ID. Names that cannot be a Claude model fail when the CLI starts.
 func WithModel(model string) Option {
 	return func(o *Options) {
 		o.Model = model
@@ -301,13 +575,31 @@ func WithExtraArg(flag string, value *string) Option {
 	}
 }
 
-// WithMaxBufferSize sets the max buffer size for CLI stdout.
+// WithMaxBufferSize sets the maximum size of a message from the CLI.
+// Larger messages end the stream with a BufferLimitError.
 func WithMaxBufferSize(size int) Option {
 	return func(o *Options) {
 		o.MaxBufferSize = size
 	}
 }
 
+// WithMaxStderrLineSize sets the maximum size of a line of CLI stderr.
+// Longer lines are truncated, and reported as a BufferLimitError.
+func WithMaxStderrLineSize(size int) Option {
+	return func(o *Options) {
+		o.MaxStderrLineSize = size
+	}
+}
+
+// WithMaxControlResponseSize sets the maximum size of a control response
+// from the CLI, such as the reply to initialize, independently of the
+// message limit of WithMaxBufferSize.
+func WithMaxControlResponseSize(size int) Option {
+	return func(o *Options) {
+		o.MaxControlResponseSize = size
+	}
+}
+
 // WithStderr sets the stderr callback.
 func WithStderr(callback func(string)) Option {
 	return func(o *Options) {
@@ -322,6 +614,17 @@ func WithCanUseTool(callback CanUseToolFunc) Option {
 	}
 }
 
+// WithAskResolver sets a resolver for the tool calls the CanUseTool
+// callback answers with PermissionResultAsk, so that the callback decides
+// the calls it can and passes the others on. Asks the resolver returns,
+// and all asks without a resolver, are left to the CLI's permission
+// prompt.
+func WithAskResolver(resolver AskResolverFunc) Option {
+	return func(o *Options) {
+		o.AskResolver = resolver
+	}
+}
+
 // WithHooks sets hook configurations.
 func WithHooks(hooks map[HookEvent][]HookMatcher) Option {
 	return func(o *Options) {
@@ -399,9 +702,510 @@ func WithEnableFileCheckpointing(enable bool) Option {
 	}
 }
 
-// WithAppendSystemPrompt appends text to the system prompt.
-// If no system prompt is set, this becomes the system prompt.
-// Can be called multiple times to append additional text.
+// WithStallTimeout enables stall detection: if no message arrives for d
+// while a turn is in progress, action is taken. An unknown action is
+// treated as StallActionDiagnostic.
+func WithStallTimeout(d time.Duration, action StallAction) Option {
+	return func(o *Options) {
+		if !action.valid() {
+			action = StallActionDiagnostic
+		}
+		o.StallTimeout = d
+		o.StallAction = action
+	}
+}
+
+// WithDetachedStateDir sets where StartDetached and Attach keep session state.
+func WithDetachedStateDir(dir string) Option {
+	return func(o *Options) {
+		o.DetachedStateDir = dir
+	}
+}
+
+// WithResumeSessionAt resumes the session only up to the message with the
+// given UUID, discarding later messages. Use with WithResume.
+func WithResumeSessionAt(messageUUID string) Option {
+	return func(o *Options) {
+		o.ResumeSessionAt = messageUUID
+	}
+}
+
+// WithMCPServerAllowed allows every tool of the named MCP servers, adding
+// their "mcp__<server>__<tool>" names to the allowed tools.
+func WithMCPServerAllowed(servers ...string) Option {
+	return func(o *Options) {
+		o.MCPServersAllowed = append(o.MCPServersAllowed, servers...)
+	}
+}
+
+// WithWatchPaths watches files and directories during a Client session.
+// When watched files change between prompts, the next prompt tells Claude
+// which files were created, modified, or deleted. Directories are watched
+// recursively, skipping hidden directories, and relative paths are
+// resolved against the working directory. Changes Claude makes itself
+// with its file editing tools are not reported.
+func WithWatchPaths(paths []string) Option {
+	return func(o *Options) {
+		o.WatchPaths = paths
+	}
+}
+
+// WithStructuredOutputRetries validates structured output against the
+// schema set with WithJSONSchema. When the output is missing or invalid,
+// Claude is re-prompted with the validation errors up to n times. Results
+// that are retried are not delivered; if the last attempt is still
+// invalid, its ResultMessage is followed by a SchemaValidationError.
+// Use n = 0 to validate without retrying.
+func WithStructuredOutputRetries(n int) Option {
+	return func(o *Options) {
+		o.StructuredOutputRetries = &n
+	}
+}
+
+// WithCancelBehavior sets what a Client does when the context passed to
+// Query or ReceiveResponse is cancelled while the turn is in progress. By
+// default the turn is interrupted so the CLI stops working on it.
+func WithCancelBehavior(behavior CancelBehavior) Option {
+	return func(o *Options) {
+		o.CancelBehavior = behavior
+	}
+}
+
+// WithSessionMetadataDir sets where SetSessionTitle and Annotate record
+// session metadata, and where LoadSessionMetadata and ListSessionMetadata
+// read it.
+func WithSessionMetadataDir(dir string) Option {
+	return func(o *Options) {
+		o.SessionMetadataDir = dir
+	}
+}
+
+// WithTemperature sets the sampling temperature, from 0 to 1. Lower values
+// make responses more deterministic.
+func WithTemperature(temperature float64) Option {
+	return func(o *Options) {
+		o.Temperature = &temperature
+	}
+}
+
+// WithTopP sets nucleus sampling, from 0 (exclusive) to 1.
+func WithTopP(topP float64) Option {
+	return func(o *Options) {
+		o.TopP = &topP
+	}
+}
+
+// WithSeed sets the sampling seed, for models and CLI versions that
+// support it.
+func WithSeed(seed int64) Option {
+	return func(o *Options) {
+		o.Seed = &seed
+	}
+}
+
+// WithModelFallbacks sets models to fail over to, in order, when a turn
+// fails with a rate limit or server error: the SDK switches to the next
+// model and sends the turn's prompt again. Each turn starts over with the
+// primary model, and the ResultMessage reports the model that served it.
+//
+// Unlike WithFallbackModel, which the CLI applies when the primary model
+// is overloaded, the chain can hold any number of models.
+func WithModelFallbacks(models []string) Option {
+	return func(o *Options) {
+		o.ModelFallbacks = models
+	}
+}
+
+// WithAssistantErrorHandler sets fn to decide what to do about assistant
+// messages that fail with an error such as a rate limit, a billing error
+// or a server error, in one place rather than in each consumer of the
+// messages. fn is called as each failed message arrives, before it is
+// delivered, and its action is taken once the turn ends:
+//
+//   - ActionContinue handles the error as without a handler, retrying
+//     rate limits and server errors with WithModelFallbacks, if set.
+//   - ActionRetry sends the turn's prompt again with the same model.
+//   - ActionSwitchModel(model) switches to model and sends the prompt
+//     again; the next turn starts over with the primary model.
+//   - ActionAbort ends the turn with an AssistantError, reported by Query
+//     and Client like other errors, after the turn's ResultMessage.
+//
+// Retries are reported with a DiagnosticEvent. fn is called for the
+// failed messages of retries too, so it decides when to stop retrying.
+// Errors that a later message of the turn recovers from, as when the CLI
+// retried on its own, are not acted on.
+//
+// Example:
+//
+//	retries := 0
+//	client := claude.NewClient(claude.WithAssistantErrorHandler(
+//	    func(kind claude.AssistantMessageError, msg *claude.AssistantMessage) claude.ErrorAction {
+//	        switch kind {
+//	        case claude.AssistantMessageErrorServerError:
+//	            if retries++; retries <= 2 {
+//	                return claude.ActionRetry
+//	            }
+//	            return claude.ActionSwitchModel(claude.ModelSonnet)
+//	        case claude.AssistantMessageErrorBillingError, claude.AssistantMessageErrorAuthenticationFailed:
+//	            return claude.ActionAbort
+//	        }
+//	        return claude.ActionContinue
+//	    }))
+func WithAssistantErrorHandler(fn func(AssistantMessageError, *AssistantMessage) ErrorAction) Option {
+	return func(o *Options) {
+		o.AssistantErrorHandler = fn
+	}
+}
+
+// WithNamedHooks adds the hooks declared by config, looked up by name in
+// registry. Connecting fails if config references an unregistered hook.
+func WithNamedHooks(registry *HookRegistry, config HookConfig) Option {
+	return func(o *Options) {
+		o.HookRegistry = registry
+		o.NamedHooks = config
+	}
+}
+
+// WithRateLimitPacer makes turns wait while pacer is paused, and pauses
+// it until the limit resets when a turn ends on a rate limit. Share the
+// pacer between workers so that all of them back off together.
+func WithRateLimitPacer(pacer *RateLimitPacer) Option {
+	return func(o *Options) {
+		o.RateLimitPacer = pacer
+	}
+}
+
+// WithMCPPreflight starts each stdio MCP server of WithMCPServers before
+// the CLI does and checks that it answers the MCP initialize handshake
+// within timeout. Connecting fails with an MCPPreflightError listing the
+// servers that are missing or broken, rather than the CLI starting
+// without their tools.
+func WithMCPPreflight(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.MCPPreflightTimeout = timeout
+	}
+}
+
+// WithKeepAlive makes a Client send the CLI a keep_alive message every
+// interval while it is connected, so that long idle sessions are not
+// dropped as inactive by the CLI or by proxies in between.
+func WithKeepAlive(interval time.Duration) Option {
+	return func(o *Options) {
+		o.KeepAliveInterval = interval
+	}
+}
+
+// WithIdleTimeout makes a Client close its connection once no turn has
+// been in progress for d, reporting an IdleTimeoutError on Errors(). The
+// next Connect resumes the session, so an idle client is closed cleanly
+// at a predictable point instead of dying whenever the CLI or OS gives up
+// on it.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.IdleTimeout = d
+	}
+}
+
+// WithDockerRuntime runs the CLI inside a container of image, with the
+// working directory and mounts attached as volumes and env set in its
+// environment. Messages are exchanged over the container's stdio just as
+// with a local CLI, so untrusted workloads can be isolated at the OS level
+// without any other change. The image must provide the CLI; WithCLIPath
+// sets its path inside the container.
+func WithDockerRuntime(image string, mounts []string, env map[string]string) Option {
+	return func(o *Options) {
+		o.Container = &ContainerRuntime{Image: image, Mounts: mounts, Env: env}
+	}
+}
+
+// WithSSHRemote runs the CLI on a remote host over SSH, streaming its stdio
+// back to this process, so that a local program can drive the CLI on a
+// larger machine or inside a restricted network. Tools and hooks of the
+// SDK still run locally.
+func WithSSHRemote(remote *SSHRemote) Option {
+	return func(o *Options) {
+		o.SSH = remote
+	}
+}
+
+// WithCache makes Query answer a repeated query from cache instead of
+// running the CLI. Queries are keyed by their prompt, with whitespace
+// normalized, and the options that affect the answer, such as the model,
+// system prompt, tools and working directory. Only results without an
+// error are cached, and queries that resume or continue a session are not.
+// Use NewMemoryCache or FileCache, which also set how long entries last.
+func WithCache(cache Cache) Option {
+	return func(o *Options) {
+		o.Cache = cache
+	}
+}
+
+// WithAutoContinue makes a Client follow up on a turn by itself: when the
+// turn ends, predicate is called with its result and, if it returns true,
+// the prompt it returns is sent as the next turn, until predicate returns
+// false. This implements autonomous loops such as "continue until the
+// tests pass". A DiagnosticEvent of kind DiagnosticKindAutoContinue is
+// emitted for each follow-up in place of the result, so the loop ends with
+// a single ResultMessage. Interrupted turns are not followed up.
+//
+// Loops are limited to 10 follow-ups per turn; use WithAutoContinueBudget
+// to change the limit or add a cost ceiling.
+func WithAutoContinue(predicate func(*ResultMessage) (string, bool)) Option {
+	return func(o *Options) {
+		o.AutoContinue = predicate
+	}
+}
+
+// WithAutoContinueBudget limits the loops of WithAutoContinue to maxLoops
+// follow-ups per turn, and stops them once a result reports a total cost
+// of maxCostUSD or more. A maxCostUSD of zero sets no cost ceiling.
+func WithAutoContinueBudget(maxLoops int, maxCostUSD float64) Option {
+	return func(o *Options) {
+		o.AutoContinueMaxLoops = maxLoops
+		o.AutoContinueMaxCostUSD = maxCostUSD
+	}
+}
+
+// WithCostAlert calls fn when the cost of a turn, or of the whole session,
+// crosses threshold USD, as reported by results. It does not stop the
+// agent, unlike WithMaxBudgetUSD, so services can log or page before a
+// budget is enforced. fn is called once per turn whose cost reaches
+// threshold, with CostScopeTurn, and once when the session's reaches it,
+// with CostScopeSession; a single expensive first turn sets off both.
+// Several alerts can be set. fn runs on the goroutine delivering messages
+// and should return quickly.
+func WithCostAlert(threshold float64, fn func(SessionCost)) Option {
+	return func(o *Options) {
+		o.CostAlerts = append(o.CostAlerts, CostAlert{Threshold: threshold, Fn: fn})
+	}
+}
+
+// WithAPIKey authenticates the CLI with an Anthropic API key, instead of
+// a stored login, and turns off Bedrock and Vertex AI settings inherited
+// from the environment.
+func WithAPIKey(key string) Option {
+	return withProvider(AuthMethodAPIKey, map[string]string{
+		"ANTHROPIC_API_KEY":       key,
+		"CLAUDE_CODE_USE_BEDROCK": "",
+		"CLAUDE_CODE_USE_VERTEX":  "",
+	})
+}
+
+// WithBedrock has the CLI use Amazon Bedrock in region, with the AWS
+// credentials of profile, or of the default credential chain if profile is
+// empty. An empty region falls back to AWS_REGION of the environment.
+func WithBedrock(region, profile string) Option {
+	env := map[string]string{
+		"CLAUDE_CODE_USE_BEDROCK": "1",
+		"CLAUDE_CODE_USE_VERTEX":  "",
+	}
+	if region != "" {
+		env["AWS_REGION"] = region
+	}
+	if profile != "" {
+		env["AWS_PROFILE"] = profile
+	}
+	return withProvider(AuthMethodBedrock, env)
+}
+
+// WithVertex has the CLI use Google Vertex AI in the Google Cloud project
+// and region, with application default credentials. Empty values fall
+// back to ANTHROPIC_VERTEX_PROJECT_ID and CLOUD_ML_REGION of the
+// environment.
+func WithVertex(project, region string) Option {
+	env := map[string]string{
+		"CLAUDE_CODE_USE_VERTEX":  "1",
+		"CLAUDE_CODE_USE_BEDROCK": "",
+	}
+	if project != "" {
+		env["ANTHROPIC_VERTEX_PROJECT_ID"] = project
+	}
+	if region != "" {
+		env["CLOUD_ML_REGION"] = region
+	}
+	return withProvider(AuthMethodVertex, env)
+}
+
+// withProvider sets the provider and its environment variables.
+func withProvider(provider AuthMethod, env map[string]string) Option {
+	return func(o *Options) {
+		if o.Env == nil {
+			o.Env = make(map[string]string)
+		}
+		maps.Copy(o.Env, env)
+		o.Provider = provider
+	}
+}
+
+// WithHTTPProxy routes the traffic of the CLI, and of the commands it
+// runs, through the proxy at proxyURL, an http, https or socks5 URL. If
+// sandboxing is enabled and the proxy listens on the loopback interface,
+// sandboxed commands are sent through it too, unless the sandbox's network
+// settings name a proxy port.
+func WithHTTPProxy(proxyURL string) Option {
+	return func(o *Options) {
+		o.HTTPProxy = proxyURL
+	}
+}
+
+// WithNoProxy lists hosts reached directly, bypassing the proxy, such as
+// "localhost", ".internal.example.com" or "10.0.0.0/8".
+func WithNoProxy(hosts []string) Option {
+	return func(o *Options) {
+		o.NoProxy = hosts
+	}
+}
+
+// WithCABundle has the CLI trust the certificate authorities of the PEM
+// file at path in addition to the system ones, for proxies that inspect
+// TLS traffic.
+func WithCABundle(path string) Option {
+	return func(o *Options) {
+		o.CABundle = path
+	}
+}
+
+// WithLowAllocParsing decodes the CLI's messages with a decoder built for
+// high-volume streaming, such as with WithIncludePartialMessages: it
+// interns keys and repeated values, shares small numbers and reuses its
+// buffers, allocating less than half as often as encoding/json per stream
+// event. Messages decode to the same values either way; input the decoder
+// does not handle is left to encoding/json.
+func WithLowAllocParsing() Option {
+	return func(o *Options) {
+		o.LowAllocParsing = true
+	}
+}
+
+// WithBusyBehavior sets what Client.Query and Client.QueryMessage do when
+// called while a turn is in progress. By default they wait for the turns
+// before them, in the order they were called; BusyBehaviorReject returns a
+// BusyError instead.
+func WithBusyBehavior(behavior BusyBehavior) Option {
+	return func(o *Options) {
+		o.BusyBehavior = behavior
+	}
+}
+
+// WithContextFiles gives Claude the contents of files with the first
+// prompt, so that it does not need the Read tool for them. Each file is
+// put in a code fence annotated with its path. Relative paths are resolved
+// against the working directory, and the files are read when the session
+// starts. Each call adds to the files given so far.
+//
+// Example:
+//
+//	claude.Query(ctx, "Review this change for bugs",
+//	    claude.WithContextFiles("handler.go", "handler_test.go"))
+func WithContextFiles(paths ...string) Option {
+	return func(o *Options) {
+		o.ContextFiles = append(o.ContextFiles, paths...)
+	}
+}
+
+// WithContextFilePlacement sets where the files of WithContextFiles are
+// put: before the first prompt, the default, or in the system prompt,
+// where they stay through compaction.
+func WithContextFilePlacement(placement ContextFilePlacement) Option {
+	return func(o *Options) {
+		o.ContextFilePlacement = placement
+	}
+}
+
+// WithContextFileLimit limits the files of WithContextFiles to about
+// maxTokens tokens, estimated at four bytes each, cutting them down with
+// truncation when they are larger.
+func WithContextFileLimit(maxTokens int, truncation ContextFileTruncation) Option {
+	return func(o *Options) {
+		o.ContextFileMaxTokens = maxTokens
+		o.ContextFileTruncation = truncation
+	}
+}
+
+// WithTranscriptDir writes the transcripts of Client and Query sessions to
+// dir as they run, in the JSONL format of the CLI, one file per session
+// named by its ID. Existing tools read them, and with ProjectTranscriptDir
+// they are listed by `claude --resume`. This keeps a record where the
+// CLI's own transcripts are not kept, such as in a container or with a
+// temporary config directory; the CLI's directory for the working
+// directory of the session already has them otherwise.
+func WithTranscriptDir(dir string) Option {
+	return func(o *Options) {
+		o.TranscriptDir = dir
+	}
+}
+
+// WithConversationTree records the sessions run with these options in
+// tree: forks made with WithForkSession, Client.Fork and Client.Rollback
+// as branches of the session they forked, and other sessions as roots.
+func WithConversationTree(tree *ConversationTree) Option {
+	return func(o *Options) {
+		o.ConversationTree = tree
+	}
+}
+
+// WithDeadlinePropagation enforces the deadline of the context passed to
+// Query, QueryStreaming, QueryOnce and Client.Query on the CLI's side,
+// rather than only by stopping it when the deadline passes. margin is
+// kept back for the SDK to receive the end of the turn.
+//
+// The CLI's timeouts for API requests, Bash commands and MCP tool calls
+// are capped to the time left, so no step of a query outlives it; a
+// query started with no time left fails with context.DeadlineExceeded.
+// A Client turn is interrupted when its time runs out, ending with a
+// ResultMessage that reports IsInterrupted with reason
+// "context deadline", delivered before the context expires.
+func WithDeadlinePropagation(margin time.Duration) Option {
+	return func(o *Options) {
+		o.PropagateDeadline = true
+		o.DeadlineMargin = margin
+	}
+}
+
+// WithSharedMCPServers runs the stdio MCP servers of a Client with
+// registry, so that clients configuring the same server share one process
+// rather than each starting its own. See SharedMCPRegistry.
+func WithSharedMCPServers(registry *SharedMCPRegistry) Option {
+	return func(o *Options) {
+		o.SharedMCPServers = registry
+	}
+}
+
+// WithCloseGrace sets how long Client.CloseContext and
+// Client.CloseWithTimeout wait for the CLI to exit after sending it
+// SIGINT and then SIGTERM, before moving on to the next signal. Zero uses
+// DefaultCloseGrace, and a negative grace period skips the signal.
+func WithCloseGrace(interrupt, terminate time.Duration) Option {
+	return func(o *Options) {
+		o.CloseInterruptGrace = interrupt
+		o.CloseTerminateGrace = terminate
+	}
+}
+
+// WithPromptCaching sets whether the CLI uses prompt caching, which it
+// does by default. The CLI marks the system prompt, tools and conversation
+// so far for caching itself; keeping the system prompt and the start of
+// the conversation the same between sessions lets later sessions read
+// them from the cache. ResultMessage.Tokens reports the tokens read from
+// and written to the cache.
+func WithPromptCaching(enabled bool) Option {
+	disable := ""
+	if !enabled {
+		disable = "1"
+	}
+	return func(o *Options) {
+		if o.Env == nil {
+			o.Env = make(map[string]string)
+		}
+		o.Env["DISABLE_PROMPT_CACHING"] = disable
+	}
+}
+
+// WithAppendSystemPrompt appends text to the system prompt, keeping the
+// prompt it is appended to: a prompt set with WithSystemPrompt, a preset
+// set with WithSystemPromptPreset, after its own Append, or, if neither is
+// set, the default prompt of the CLI. Can be called multiple times to
+// append additional text.
 func WithAppendSystemPrompt(text string) Option {
 	return func(o *Options) {
 		if o.AppendSystemPrompt == "" {
@@ -412,6 +1216,229 @@ func WithAppendSystemPrompt(text string) Option {
 	}
 }
 
+// WithSystemPromptFragment contributes text to the system prompt under
+// name, so that libraries such as guardrails, memory and personas can each
+// add to the prompt without overwriting one another. Fragments are
+// appended to the prompt as with WithAppendSystemPrompt, after its text,
+// lowest order first and by name among equal orders, separated by blank
+// lines, so the prompt is the same whatever order the options are applied
+// in. A fragment replaces an earlier one of the same name; empty text
+// removes it.
+func WithSystemPromptFragment(name, text string, order int) Option {
+	return func(o *Options) {
+		fragments := slices.DeleteFunc(slices.Clone(o.SystemPromptFragments), func(f SystemPromptFragment) bool {
+			return f.Name == name
+		})
+		if text != "" {
+			fragments = append(fragments, SystemPromptFragment{Name: name, Text: text, Order: order})
+		}
+		o.SystemPromptFragments = fragments
+	}
+}
+
+// WithTempDir sets the directory under which the SDK writes the temp files
+// it passes to the CLI, such as agent definitions too long for the command
+// line. Files go in a claude-agent-sdk subdirectory, named after the PID
+// of the writing process and the session ID, and are removed on Close.
+// Files left behind by a process that crashed are removed the next time
+// the SDK writes to the directory.
+func WithTempDir(dir string) Option {
+	return func(o *Options) {
+		o.TempDir = dir
+	}
+}
+
+// WithWireLog writes every JSON line sent to and received from the CLI
+// to w, one per line, prefixed with the time and "send" or "recv", to
+// diagnose protocol mismatches between the SDK and the CLI. Unlike
+// WithStderr, which shows the CLI's own diagnostics, it shows the
+// messages themselves. The values of fields named in redact, at any depth
+// of a message, are replaced with "[REDACTED]".
+//
+// Lines are logged whole, including prompts, tool inputs and results, so
+// the log is as sensitive as the conversation. Writes to w are
+// serialized; errors writing to it are ignored.
+//
+// Example:
+//
+//	f, _ := os.Create("wire.log")
+//	defer f.Close()
+//	client := claude.NewClient(claude.WithWireLog(f, "api_key", "content"))
+func WithWireLog(w io.Writer, redact ...string) Option {
+	return func(o *Options) {
+		o.WireLog = w
+		o.WireLogRedact = redact
+	}
+}
+
+// WithMessageInterceptor adds fn to the interceptors applied to each
+// message before it is delivered on the channel of Query, QueryStreaming
+// or a Client, so that assistant output can be redacted, translated or
+// annotated in one place rather than by every consumer. Interceptors run
+// in the order they were added, each given the message returned by the
+// one before; returning nil drops the message.
+//
+// Interceptors run on the goroutine that reads the CLI's output, so a
+// slow one delays every message after it. Messages are shared with the
+// SDK's own bookkeeping, such as the query cache, so fn must return a
+// modified copy rather than modify msg in place.
+//
+// Example:
+//
+//	claude.WithMessageInterceptor(func(msg claude.Message) claude.Message {
+//	    m, ok := msg.(*claude.AssistantMessage)
+//	    if !ok {
+//	        return msg
+//	    }
+//	    redacted := *m
+//	    redacted.Content = nil
+//	    for _, block := range m.Content {
+//	        if text, ok := block.(claude.TextBlock); ok {
+//	            block = claude.TextBlock{Text: emails.ReplaceAllString(text.Text, "[email]")}
+//	        }
+//	        redacted.Content = append(redacted.Content, block)
+//	    }
+//	    return &redacted
+//	})
+func WithMessageInterceptor(fn func(Message) Message) Option {
+	return func(o *Options) {
+		o.MessageInterceptors = append(o.MessageInterceptors, fn)
+	}
+}
+
+// WithLocale sets the locale of the strings the SDK writes for Claude and
+// users, as a BCP 47 language tag such as "de" or "pt-BR": the guidance
+// added to denials, the reason writes outside a Workspace are denied, and
+// the text of DiagnosticEvents. Messages missing from the locale's
+// Catalog, see RegisterCatalog, are written in English. Errors returned
+// by the SDK, which are meant for developers, stay in English.
+//
+// Example:
+//
+//	client := claude.NewClient(claude.WithLocale("fr"))
+func WithLocale(tag string) Option {
+	return func(o *Options) {
+		o.Locale = tag
+	}
+}
+
+// WithNamedPipes has a Client talk to the CLI over named pipes in dir,
+// created if needed, rather than the pipes of the process, so that the CLI
+// outlives the Client's process: a Client with the same dir, in this
+// process or one that replaced it, reconnects to the CLI if it is still
+// running, and continues the conversation where it left off. The CLI is
+// started in its own session, and is ended by Close as usual; use Detach
+// to disconnect and leave it running. Only one Client is connected at a
+// time.
+//
+// The buffers of the pipes are grown, where the system allows it, so that
+// the CLI can write a large message without waiting for it to be read.
+// While no Client is connected, the CLI waits once it has filled them.
+// Messages a process had read but not delivered when it died are lost, as
+// are the permission requests and hook calls it had not answered, which
+// the CLI waits for. Pass the same hooks to the Client that reconnects.
+//
+// The CLI has no way to talk over a socket, so that named pipes stand for
+// one. They are not supported on Windows, nor with Query, which has no
+// process to outlive.
+//
+// Example:
+//
+//	client := claude.NewClient(claude.WithNamedPipes("/var/run/agent/claude"))
+//	if err := client.Connect(ctx); err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer client.Detach()
+func WithNamedPipes(dir string) Option {
+	return func(o *Options) {
+		o.PipeDir = dir
+	}
+}
+
+// WithDryRun makes Claude plan rather than act: calls of tools that may
+// change something, such as Write, Edit, Bash and MCP tools, are denied
+// with a reason telling Claude the call was simulated, while read-only
+// tools such as Read, Glob and Grep run. The calls simulated in a turn
+// are returned by Client.Plan. readOnly names more tools to run, such as
+// MCP tools known to change nothing.
+//
+// Calls are simulated with a PreToolUse hook, so WithDryRun applies to a
+// Client and QueryStreaming only.
+//
+// Example:
+//
+//	client := claude.NewClient(claude.WithDryRun())
+//	// ... run a turn ...
+//	for _, action := range client.Plan().Actions {
+//	    fmt.Println(action.ToolName, action.Input)
+//	}
+func WithDryRun(readOnly ...ToolName) Option {
+	return func(o *Options) {
+		o.DryRun = true
+		o.DryRunReadOnlyTools = append(o.DryRunReadOnlyTools, readOnly...)
+	}
+}
+
+// WithContextWindow sets the context window of the model, in tokens, for
+// Client.ContextUsage and WithContextWarnings. Without it, models are
+// assumed to have DefaultContextWindow tokens, or 1M with
+// SdkBetaContext1M or a "[1m]" model suffix.
+func WithContextWindow(tokens int) Option {
+	return func(o *Options) {
+		o.ContextWindow = tokens
+	}
+}
+
+// WithContextWarnings makes a Client warn when the context fills past
+// each of thresholds, shares of the context window from 0 to 1, so that
+// an application can compact or summarize the conversation in time. The
+// warning is a DiagnosticEvent of kind DiagnosticKindContext, emitted
+// after the message that reported the usage, with the usage in Context.
+// A threshold warns again once the context fell below it, as it does on
+// compaction.
+//
+// Example:
+//
+//	client := claude.NewClient(claude.WithContextWarnings(0.8, 0.95))
+//	// ...
+//	if event, ok := msg.(*claude.DiagnosticEvent); ok && event.Kind == claude.DiagnosticKindContext {
+//	    _ = client.Query(ctx, "/compact")
+//	}
+func WithContextWarnings(thresholds ...float64) Option {
+	return func(o *Options) {
+		o.ContextWarnings = append(o.ContextWarnings, thresholds...)
+	}
+}
+
+// WithWorkspaceLock locks the working directory, see WithCwd, with an
+// advisory file lock while a Client is connected or a Query,
+// QueryStreaming or QueryOnce runs, so that agents in this and other processes pointed
+// at the same directory do not edit it at once. WorkspaceLockQueue waits
+// for the lock, until the context of Connect or the query is done;
+// WorkspaceLockReject returns a WorkspaceBusyError if it is held. The
+// lock is released when the Client closes or the query ends, and by the
+// system if the process exits. It coordinates only agents that set it.
+//
+// Example:
+//
+//	client := claude.NewClient(claude.WithCwd(repo), claude.WithWorkspaceLock(claude.WorkspaceLockReject))
+//	if err := client.Connect(ctx); claude.IsWorkspaceBusyError(err) {
+//	    log.Println("another agent is working in", repo)
+//	}
+func WithWorkspaceLock(mode WorkspaceLockMode) Option {
+	return func(o *Options) {
+		o.WorkspaceLock = mode
+	}
+}
+
+// WithWorkspaceLockDir sets the directory of the lock files of
+// WithWorkspaceLock. Agents coordinate only through the same directory.
+func WithWorkspaceLockDir(dir string) Option {
+	return func(o *Options) {
+		o.WorkspaceLockDir = dir
+	}
+}
+
 // WithDebugStderr enables stderr output to os.Stderr for debugging.
 // This is a convenience wrapper around WithStderr that prints to standard error.
 func WithDebugStderr() Option {
@@ -2,7 +2,9 @@ package claude
 
 import (
 	"context"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestNewOptions_Defaults(t *testing.T) {
@@ -299,6 +301,17 @@ func TestWithMaxBufferSize(t *testing.T) {
 	}
 }
 
+func TestWithStreamLimits(t *testing.T) {
+	opts := NewOptions(WithMaxStderrLineSize(512), WithMaxControlResponseSize(8192))
+	if opts.MaxStderrLineSize != 512 || opts.MaxControlResponseSize != 8192 {
+		t.Errorf("Expected limits 512 and 8192, got %d and %d", opts.MaxStderrLineSize, opts.MaxControlResponseSize)
+	}
+	result := toTransportOptions(opts)
+	if result.MaxStderrLineSize != 512 || result.MaxControlResponseSize != 8192 {
+		t.Errorf("Expected limits to reach the transport, got %d and %d", result.MaxStderrLineSize, result.MaxControlResponseSize)
+	}
+}
+
 func TestWithStderr(t *testing.T) {
 	called := false
 	callback := func(line string) { called = true }
@@ -459,6 +472,363 @@ func TestWithEnableFileCheckpointing(t *testing.T) {
 	}
 }
 
+func TestWithStallTimeout(t *testing.T) {
+	opts := NewOptions(WithStallTimeout(30*time.Second, StallActionInterrupt))
+
+	if opts.StallTimeout != 30*time.Second {
+		t.Errorf("Expected StallTimeout 30s, got %v", opts.StallTimeout)
+	}
+	if opts.StallAction != StallActionInterrupt {
+		t.Errorf("Expected StallAction %q, got %q", StallActionInterrupt, opts.StallAction)
+	}
+}
+
+func TestWithDetachedStateDir(t *testing.T) {
+	opts := NewOptions(WithDetachedStateDir("/var/lib/agents"))
+	if opts.DetachedStateDir != "/var/lib/agents" {
+		t.Errorf("Expected DetachedStateDir '/var/lib/agents', got %q", opts.DetachedStateDir)
+	}
+}
+
+func TestWithResumeSessionAt(t *testing.T) {
+	opts := NewOptions(WithResume("session-123"), WithResumeSessionAt("msg-1"))
+	if opts.ResumeSessionAt != "msg-1" {
+		t.Errorf("Expected ResumeSessionAt 'msg-1', got %q", opts.ResumeSessionAt)
+	}
+}
+
+func TestWithMCPServerAllowed(t *testing.T) {
+	opts := NewOptions(WithMCPServerAllowed("calc"), WithMCPServerAllowed("files", "web"))
+	want := []string{"calc", "files", "web"}
+	if !reflect.DeepEqual(opts.MCPServersAllowed, want) {
+		t.Errorf("Expected MCPServersAllowed %v, got %v", want, opts.MCPServersAllowed)
+	}
+}
+
+func TestWithWatchPaths(t *testing.T) {
+	opts := NewOptions(WithWatchPaths([]string{"src", "go.mod"}))
+	want := []string{"src", "go.mod"}
+	if !reflect.DeepEqual(opts.WatchPaths, want) {
+		t.Errorf("Expected WatchPaths %v, got %v", want, opts.WatchPaths)
+	}
+}
+
+func TestWithCancelBehavior(t *testing.T) {
+	if opts := NewOptions(); opts.CancelBehavior != "" {
+		t.Errorf("Expected empty default CancelBehavior, got %q", opts.CancelBehavior)
+	}
+	opts := NewOptions(WithCancelBehavior(CancelBehaviorClose))
+	if opts.CancelBehavior != CancelBehaviorClose {
+		t.Errorf("Expected CancelBehavior %q, got %q", CancelBehaviorClose, opts.CancelBehavior)
+	}
+}
+
+func TestWithSessionMetadataDir(t *testing.T) {
+	opts := NewOptions(WithSessionMetadataDir("/tmp/metadata"))
+	if opts.SessionMetadataDir != "/tmp/metadata" {
+		t.Errorf("Expected SessionMetadataDir '/tmp/metadata', got %q", opts.SessionMetadataDir)
+	}
+}
+
+func TestWithSampling(t *testing.T) {
+	opts := NewOptions()
+	if opts.Temperature != nil || opts.TopP != nil || opts.Seed != nil {
+		t.Error("Expected sampling options to be unset by default")
+	}
+
+	opts = NewOptions(WithTemperature(0), WithTopP(0.9), WithSeed(42))
+	if opts.Temperature == nil || *opts.Temperature != 0 {
+		t.Errorf("Expected Temperature 0, got %v", opts.Temperature)
+	}
+	if opts.TopP == nil || *opts.TopP != 0.9 {
+		t.Errorf("Expected TopP 0.9, got %v", opts.TopP)
+	}
+	if opts.Seed == nil || *opts.Seed != 42 {
+		t.Errorf("Expected Seed 42, got %v", opts.Seed)
+	}
+}
+
+func TestWithModelFallbacks(t *testing.T) {
+	opts := NewOptions(WithModelFallbacks([]string{ModelSonnet, ModelHaiku}))
+	want := []string{ModelSonnet, ModelHaiku}
+	if !reflect.DeepEqual(opts.ModelFallbacks, want) {
+		t.Errorf("Expected ModelFallbacks %v, got %v", want, opts.ModelFallbacks)
+	}
+	if err := validateOptions(NewOptions(WithModelFallbacks([]string{"sonet"}))); err == nil {
+		t.Error("Expected an unknown fallback model to be rejected")
+	}
+}
+
+func TestWithNamedHooks(t *testing.T) {
+	registry := NewHookRegistry()
+	config := HookConfig{HookEventStop: {{Hooks: []string{"audit"}}}}
+
+	opts := NewOptions(WithNamedHooks(registry, config))
+	if opts.HookRegistry != registry {
+		t.Error("Expected HookRegistry to be set")
+	}
+	if !reflect.DeepEqual(opts.NamedHooks, config) {
+		t.Errorf("Expected NamedHooks %v, got %v", config, opts.NamedHooks)
+	}
+	if err := validateOptions(opts); err == nil {
+		t.Error("Expected an unregistered hook to be rejected")
+	}
+}
+
+func TestWithMCPPreflight(t *testing.T) {
+	opts := NewOptions(WithMCPPreflight(5 * time.Second))
+	if opts.MCPPreflightTimeout != 5*time.Second {
+		t.Errorf("Expected MCPPreflightTimeout 5s, got %v", opts.MCPPreflightTimeout)
+	}
+}
+
+func TestWithKeepAliveAndIdleTimeout(t *testing.T) {
+	opts := NewOptions(WithKeepAlive(30*time.Second), WithIdleTimeout(10*time.Minute))
+	if opts.KeepAliveInterval != 30*time.Second {
+		t.Errorf("Expected KeepAliveInterval 30s, got %v", opts.KeepAliveInterval)
+	}
+	if opts.IdleTimeout != 10*time.Minute {
+		t.Errorf("Expected IdleTimeout 10m, got %v", opts.IdleTimeout)
+	}
+}
+
+func TestWithDockerRuntime(t *testing.T) {
+	opts := NewOptions(WithDockerRuntime("agent:latest", []string{"/data:/data:ro"}, map[string]string{"A": "1"}))
+	if opts.Container == nil || opts.Container.Image != "agent:latest" || opts.Container.Runtime != "" {
+		t.Fatalf("Unexpected container %+v", opts.Container)
+	}
+	if len(opts.Container.Mounts) != 1 || opts.Container.Env["A"] != "1" {
+		t.Errorf("Expected mounts and env to be set, got %+v", opts.Container)
+	}
+}
+
+func TestWithSSHRemote(t *testing.T) {
+	remote := &SSHRemote{Host: "devbox", Port: 2222, IdentityFile: "/keys/id"}
+	opts := NewOptions(WithSSHRemote(remote))
+	if opts.SSH != remote {
+		t.Errorf("Expected SSH remote to be set, got %+v", opts.SSH)
+	}
+}
+
+func TestWithCache(t *testing.T) {
+	cache := NewMemoryCache(time.Hour)
+	if opts := NewOptions(WithCache(cache)); opts.Cache != cache {
+		t.Errorf("Expected Cache to be set, got %v", opts.Cache)
+	}
+}
+
+func TestWithAutoContinue(t *testing.T) {
+	opts := NewOptions(
+		WithAutoContinue(func(*ResultMessage) (string, bool) { return "continue", true }),
+		WithAutoContinueBudget(3, 2.5),
+	)
+	if opts.AutoContinue == nil {
+		t.Error("Expected AutoContinue to be set")
+	}
+	if opts.AutoContinueMaxLoops != 3 || opts.AutoContinueMaxCostUSD != 2.5 {
+		t.Errorf("Expected budget 3 loops and $2.5, got %d and %v", opts.AutoContinueMaxLoops, opts.AutoContinueMaxCostUSD)
+	}
+}
+
+func TestWithCostAlert(t *testing.T) {
+	opts := NewOptions(
+		WithCostAlert(1, func(SessionCost) {}),
+		WithCostAlert(5, func(SessionCost) {}),
+	)
+	if len(opts.CostAlerts) != 2 || opts.CostAlerts[0].Threshold != 1 || opts.CostAlerts[1].Threshold != 5 {
+		t.Errorf("Expected alerts at $1 and $5, got %+v", opts.CostAlerts)
+	}
+}
+
+func TestWithProviders(t *testing.T) {
+	t.Run("api key", func(t *testing.T) {
+		opts := NewOptions(WithAPIKey("sk-ant-test"))
+		if opts.Provider != AuthMethodAPIKey || opts.Env["ANTHROPIC_API_KEY"] != "sk-ant-test" {
+			t.Errorf("Expected API key provider, got %q and %v", opts.Provider, opts.Env)
+		}
+		if value, ok := opts.Env["CLAUDE_CODE_USE_BEDROCK"]; !ok || value != "" {
+			t.Errorf("Expected inherited Bedrock setting to be cleared, got %v", opts.Env)
+		}
+	})
+
+	t.Run("bedrock", func(t *testing.T) {
+		opts := NewOptions(WithBedrock("us-west-2", "prod"))
+		want := map[string]string{
+			"CLAUDE_CODE_USE_BEDROCK": "1",
+			"CLAUDE_CODE_USE_VERTEX":  "",
+			"AWS_REGION":              "us-west-2",
+			"AWS_PROFILE":             "prod",
+		}
+		if opts.Provider != AuthMethodBedrock || !reflect.DeepEqual(opts.Env, want) {
+			t.Errorf("Expected Bedrock provider with %v, got %q and %v", want, opts.Provider, opts.Env)
+		}
+		if _, ok := NewOptions(WithBedrock("us-west-2", "")).Env["AWS_PROFILE"]; ok {
+			t.Error("Expected no AWS_PROFILE without a profile")
+		}
+	})
+
+	t.Run("vertex", func(t *testing.T) {
+		opts := NewOptions(WithVertex("my-project", "us-east5"))
+		want := map[string]string{
+			"CLAUDE_CODE_USE_VERTEX":      "1",
+			"CLAUDE_CODE_USE_BEDROCK":     "",
+			"ANTHROPIC_VERTEX_PROJECT_ID": "my-project",
+			"CLOUD_ML_REGION":             "us-east5",
+		}
+		if opts.Provider != AuthMethodVertex || !reflect.DeepEqual(opts.Env, want) {
+			t.Errorf("Expected Vertex provider with %v, got %q and %v", want, opts.Provider, opts.Env)
+		}
+	})
+
+	t.Run("last wins", func(t *testing.T) {
+		opts := NewOptions(WithBedrock("us-west-2", ""), WithVertex("my-project", "us-east5"))
+		if opts.Provider != AuthMethodVertex || opts.Env["CLAUDE_CODE_USE_BEDROCK"] != "" {
+			t.Errorf("Expected Vertex to replace Bedrock, got %q and %v", opts.Provider, opts.Env)
+		}
+	})
+}
+
+func TestWithProxy(t *testing.T) {
+	opts := NewOptions(
+		WithHTTPProxy("http://proxy.corp:3128"),
+		WithNoProxy([]string{"localhost", ".corp"}),
+		WithCABundle("/etc/ssl/corp.pem"),
+	)
+	if opts.HTTPProxy != "http://proxy.corp:3128" {
+		t.Errorf("Expected HTTPProxy to be set, got %q", opts.HTTPProxy)
+	}
+	if !reflect.DeepEqual(opts.NoProxy, []string{"localhost", ".corp"}) {
+		t.Errorf("Expected NoProxy to be set, got %v", opts.NoProxy)
+	}
+	if opts.CABundle != "/etc/ssl/corp.pem" {
+		t.Errorf("Expected CABundle to be set, got %q", opts.CABundle)
+	}
+}
+
+func TestWithLowAllocParsing(t *testing.T) {
+	opts := NewOptions(WithLowAllocParsing())
+	if !opts.LowAllocParsing || !toTransportOptions(opts).LowAllocParsing {
+		t.Error("Expected LowAllocParsing to be set and reach the transport")
+	}
+}
+
+func TestWithBusyBehavior(t *testing.T) {
+	if opts := NewOptions(); opts.BusyBehavior != "" {
+		t.Errorf("Expected empty default BusyBehavior, got %q", opts.BusyBehavior)
+	}
+	opts := NewOptions(WithBusyBehavior(BusyBehaviorReject))
+	if opts.BusyBehavior != BusyBehaviorReject {
+		t.Errorf("Expected BusyBehavior %q, got %q", BusyBehaviorReject, opts.BusyBehavior)
+	}
+}
+
+func TestWithAskResolver(t *testing.T) {
+	if opts := NewOptions(); opts.AskResolver != nil {
+		t.Error("Expected no default AskResolver")
+	}
+	opts := NewOptions(WithAskResolver(func(ctx context.Context, toolName string, input map[string]any, permCtx ToolPermissionContext, ask PermissionResultAsk) (PermissionResult, error) {
+		return PermissionResultDeny{}, nil
+	}))
+	if opts.AskResolver == nil {
+		t.Fatal("Expected AskResolver to be set")
+	}
+}
+
+func TestWithContextFiles(t *testing.T) {
+	opts := NewOptions(
+		WithContextFiles("a.go"),
+		WithContextFiles("b.go", "c.go"),
+		WithContextFilePlacement(ContextFilePlacementSystemPrompt),
+		WithContextFileLimit(1000, ContextFileOmit),
+	)
+	if !reflect.DeepEqual(opts.ContextFiles, []string{"a.go", "b.go", "c.go"}) {
+		t.Errorf("Expected context files to accumulate, got %v", opts.ContextFiles)
+	}
+	if opts.ContextFilePlacement != ContextFilePlacementSystemPrompt {
+		t.Errorf("Expected system prompt placement, got %q", opts.ContextFilePlacement)
+	}
+	if opts.ContextFileMaxTokens != 1000 || opts.ContextFileTruncation != ContextFileOmit {
+		t.Errorf("Expected limit 1000 with omit, got %d with %q", opts.ContextFileMaxTokens, opts.ContextFileTruncation)
+	}
+}
+
+func TestWithTranscriptDir(t *testing.T) {
+	opts := NewOptions(WithTranscriptDir("/var/log/claude"))
+	if opts.TranscriptDir != "/var/log/claude" {
+		t.Errorf("Expected transcript dir /var/log/claude, got %q", opts.TranscriptDir)
+	}
+}
+
+func TestWithPromptCaching(t *testing.T) {
+	opts := NewOptions(WithPromptCaching(false))
+	if opts.Env["DISABLE_PROMPT_CACHING"] != "1" {
+		t.Errorf("Expected caching to be disabled, got %v", opts.Env)
+	}
+
+	// Enabling overrides a setting inherited from the environment
+	opts = NewOptions(WithPromptCaching(true))
+	if v, ok := opts.Env["DISABLE_PROMPT_CACHING"]; !ok || v != "" {
+		t.Errorf("Expected caching to be enabled, got %v", opts.Env)
+	}
+}
+
+func TestWithConversationTree(t *testing.T) {
+	tree := NewConversationTree()
+	opts := NewOptions(WithConversationTree(tree))
+	if opts.ConversationTree != tree {
+		t.Error("Expected the conversation tree to be set")
+	}
+}
+
+func TestWithDeadlinePropagation(t *testing.T) {
+	opts := NewOptions(WithDeadlinePropagation(5 * time.Second))
+	if !opts.PropagateDeadline || opts.DeadlineMargin != 5*time.Second {
+		t.Errorf("Expected deadline propagation with a 5s margin, got %v, %v", opts.PropagateDeadline, opts.DeadlineMargin)
+	}
+}
+
+func TestWithSharedMCPServers(t *testing.T) {
+	registry := NewSharedMCPRegistry()
+	opts := NewOptions(WithSharedMCPServers(registry))
+	if opts.SharedMCPServers != registry {
+		t.Error("Expected the shared MCP registry to be set")
+	}
+}
+
+func TestWithCloseGrace(t *testing.T) {
+	opts := NewOptions(WithCloseGrace(time.Second, -1))
+	if opts.CloseInterruptGrace != time.Second || opts.CloseTerminateGrace != -1 {
+		t.Errorf("Expected the close grace periods to be set, got %v, %v", opts.CloseInterruptGrace, opts.CloseTerminateGrace)
+	}
+}
+
+func TestWithSystemPromptFragment(t *testing.T) {
+	opts := NewOptions(
+		WithSystemPromptFragment("persona", "Be concise.", 1),
+		WithSystemPromptFragment("memory", "The user prefers Go.", 2),
+		WithSystemPromptFragment("persona", "Be thorough.", 3),
+	)
+	want := []SystemPromptFragment{{"memory", "The user prefers Go.", 2}, {"persona", "Be thorough.", 3}}
+	if !reflect.DeepEqual(opts.SystemPromptFragments, want) {
+		t.Errorf("Expected a fragment replaced by one of the same name, got %+v", opts.SystemPromptFragments)
+	}
+
+	WithSystemPromptFragment("memory", "", 0)(opts)
+	if len(opts.SystemPromptFragments) != 1 || opts.SystemPromptFragments[0].Name != "persona" {
+		t.Errorf("Expected empty text to remove the fragment, got %+v", opts.SystemPromptFragments)
+	}
+}
+
+func TestWithTempDir(t *testing.T) {
+	opts := NewOptions(WithTempDir("/var/tmp/agents"))
+	if opts.TempDir != "/var/tmp/agents" {
+		t.Errorf("Expected TempDir to be set, got %q", opts.TempDir)
+	}
+	if got := toTransportOptions(opts).TempDir; got != "/var/tmp/agents" {
+		t.Errorf("Expected TempDir passed to the transport, got %q", got)
+	}
+}
+
 // Test option chaining and composition
 func TestOptionsChaining(t *testing.T) {
 	opts := NewOptions(
@@ -0,0 +1,239 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/transport"
+)
+
+// OptionsView is a read-only snapshot of the options of a Client, for
+// logging the configuration of each session or showing it in an admin UI.
+// It does not change when the client's options do.
+type OptionsView struct {
+	options Options
+}
+
+// Options returns a snapshot of the options in effect: those the client
+// was created with, as changed by UpdateOptions, with the model and
+// permission mode set by SetModel and SetPermissionMode or reported by
+// the CLI. It is safe to call concurrently with the client's other
+// methods.
+//
+// Example:
+//
+//	slog.Info("session started", "session", client.SessionID(), "options", client.Options())
+func (c *Client) Options() OptionsView {
+	c.mu.Lock()
+	options := cloneOptions(c.options)
+	c.mu.Unlock()
+
+	options.Model = c.failover.primary()
+	if mode := c.PermissionMode(); mode != "" {
+		options.PermissionMode = mode
+	}
+	return OptionsView{options: options}
+}
+
+// Options returns a copy of the options of the snapshot. Its slices and
+// maps are copied, so changing them changes neither the snapshot nor the
+// client; pointers, such as that of a HookRegistry, and callbacks are
+// shared.
+func (v OptionsView) Options() Options {
+	return cloneOptions(&v.options)
+}
+
+// Map returns the options that are set, keyed by their field names in
+// snake case, such as "allowed_tools", as JSON values. Callbacks are shown
+// as true, values that cannot be shown, such as writers, as their type,
+// and durations as strings. Secrets are redacted as by
+// Options.BuildCommandPreview: env values, MCP server headers, values of
+// keys named like secrets and passwords in URLs.
+func (v OptionsView) Map() map[string]any {
+	m := make(map[string]any)
+	rv := reflect.ValueOf(v.options)
+	seen := make(map[uintptr]bool)
+	for i := range rv.NumField() {
+		field := rv.Field(i)
+		if field.IsZero() {
+			continue
+		}
+		if value := describeValue(field, seen); value != nil {
+			m[snakeCase(rv.Type().Field(i).Name)] = value
+		}
+	}
+	if v.options.Settings != "" {
+		m["settings"] = transport.RedactJSON(v.options.Settings)
+	}
+	return transport.RedactValue(m).(map[string]any)
+}
+
+// MarshalJSON encodes Map.
+func (v OptionsView) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.Map())
+}
+
+// String returns Map as JSON.
+func (v OptionsView) String() string {
+	data, err := v.MarshalJSON()
+	if err != nil {
+		return fmt.Sprintf("OptionsView(%v)", err)
+	}
+	return string(data)
+}
+
+// cloneOptions returns a copy of o with its slices and maps copied, at any
+// depth within them.
+func cloneOptions(o *Options) Options {
+	var clone Options
+	reflect.ValueOf(&clone).Elem().Set(cloneValue(reflect.ValueOf(*o)))
+	return clone
+}
+
+// cloneValue returns a copy of v with the slices and maps in it, and in
+// the interfaces it holds, copied. Pointers and functions are shared.
+func cloneValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Struct:
+		clone := reflect.New(v.Type()).Elem()
+		clone.Set(v)
+		for i := range v.NumField() {
+			if clone.Field(i).CanSet() {
+				clone.Field(i).Set(cloneValue(v.Field(i)))
+			}
+		}
+		return clone
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		clone := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := range v.Len() {
+			clone.Index(i).Set(cloneValue(v.Index(i)))
+		}
+		return clone
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		clone := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			clone.SetMapIndex(iter.Key(), cloneValue(iter.Value()))
+		}
+		return clone
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		clone := reflect.New(v.Type()).Elem()
+		clone.Set(cloneValue(v.Elem()))
+		return clone
+	}
+	return v
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// describeValue returns v as a JSON value for Map, or nil if it is not
+// shown. seen holds the pointers being described, so that cycles are
+// shown as the type of the pointer.
+func describeValue(v reflect.Value, seen map[uintptr]bool) any {
+	switch v.Kind() {
+	case reflect.Invalid, reflect.Chan, reflect.UnsafePointer:
+		return nil
+	case reflect.Func:
+		return !v.IsNil()
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return describeValue(v.Elem(), seen)
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		if seen[v.Pointer()] {
+			return v.Type().String()
+		}
+		seen[v.Pointer()] = true
+		defer delete(seen, v.Pointer())
+		if v.Elem().Kind() == reflect.Struct && !hasExportedFields(v.Elem().Type()) {
+			return v.Type().String()
+		}
+		return describeValue(v.Elem(), seen)
+	case reflect.Struct:
+		if !hasExportedFields(v.Type()) {
+			return v.Type().String()
+		}
+		m := make(map[string]any)
+		for i := range v.NumField() {
+			field := v.Type().Field(i)
+			if !field.IsExported() || v.Field(i).IsZero() {
+				continue
+			}
+			if value := describeValue(v.Field(i), seen); value != nil {
+				m[fieldName(field)] = value
+			}
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		s := make([]any, v.Len())
+		for i := range v.Len() {
+			s[i] = describeValue(v.Index(i), seen)
+		}
+		return s
+	case reflect.Map:
+		m := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = describeValue(iter.Value(), seen)
+		}
+		return m
+	}
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	return v.Interface()
+}
+
+// hasExportedFields reports whether the struct type t has exported fields.
+func hasExportedFields(t reflect.Type) bool {
+	for i := range t.NumField() {
+		if t.Field(i).IsExported() {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldName returns the name of a struct field in Map: the name of its
+// JSON tag, or its name in snake case.
+func fieldName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return snakeCase(field.Name)
+}
+
+// snakeCase returns a Go name in snake case, such as "mcp_servers" for
+// "MCPServers".
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
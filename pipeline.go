@@ -0,0 +1,239 @@
+package claude
+
+// Stage transforms a stream of messages. It reads in until it is closed,
+// and closes the returned channel when done.
+type Stage func(in <-chan Message) <-chan Message
+
+// Pipe passes messages through stages in order and returns the output of
+// the last one, so that an application can shape the stream for its UI
+// declaratively:
+//
+//	for msg := range claude.Pipe(client.Messages(), claude.FilterToolNoise(), claude.CoalesceDeltas()) {
+//	    render(msg)
+//	}
+//
+// Each stage runs in its own goroutine, which exits once its input is
+// closed and its output drained.
+func Pipe(messages <-chan Message, stages ...Stage) <-chan Message {
+	for _, stage := range stages {
+		messages = stage(messages)
+	}
+	return messages
+}
+
+// Map returns a stage applying fn to each message. Messages for which fn
+// returns nil are dropped.
+func Map(fn func(Message) Message) Stage {
+	return func(in <-chan Message) <-chan Message {
+		out := make(chan Message)
+		go func() {
+			defer close(out)
+			for msg := range in {
+				if msg = fn(msg); msg != nil {
+					out <- msg
+				}
+			}
+		}()
+		return out
+	}
+}
+
+// Filter returns a stage keeping the messages for which keep returns true.
+func Filter(keep func(Message) bool) Stage {
+	return Map(func(msg Message) Message {
+		if !keep(msg) {
+			return nil
+		}
+		return msg
+	})
+}
+
+// OnlyAssistant returns a stage keeping only assistant messages.
+func OnlyAssistant() Stage {
+	return Filter(func(msg Message) bool {
+		_, ok := msg.(*AssistantMessage)
+		return ok
+	})
+}
+
+// FilterToolNoise returns a stage dropping the tool traffic of a turn,
+// leaving what Claude says and the result:
+//   - tool use blocks are removed from assistant messages, and messages
+//     left without content are dropped;
+//   - user messages carrying tool results are dropped;
+//   - IncrementalToolUse messages and stream events of tool use blocks
+//     are dropped;
+//   - messages of subagents, which have a ParentToolUseID, are dropped.
+func FilterToolNoise() Stage {
+	// Indexes of the tool use blocks of the message being streamed
+	toolBlocks := make(map[int]bool)
+
+	return Map(func(msg Message) Message {
+		switch m := msg.(type) {
+		case *AssistantMessage:
+			if m.ParentToolUseID != "" {
+				return nil
+			}
+			var content []ContentBlock
+			for _, block := range m.Content {
+				if _, ok := block.(ToolUseBlock); !ok {
+					content = append(content, block)
+				}
+			}
+			if len(content) == 0 {
+				return nil
+			}
+			if len(content) == len(m.Content) {
+				return m
+			}
+			filtered := *m
+			filtered.Content = content
+			return &filtered
+
+		case *UserMessage:
+			if m.ParentToolUseID != "" || m.ToolUseResult != nil {
+				return nil
+			}
+			if blocks, ok := m.Content.([]ContentBlock); ok {
+				for _, block := range blocks {
+					if _, ok := block.(ToolResultBlock); ok {
+						return nil
+					}
+				}
+			}
+			return m
+
+		case *IncrementalToolUse:
+			return nil
+
+		case *StreamEvent:
+			if m.ParentToolUseID != "" {
+				return nil
+			}
+			index, _ := m.Event["index"].(float64)
+			switch m.Event["type"] {
+			case "message_start":
+				clear(toolBlocks)
+			case "content_block_start":
+				block, _ := m.Event["content_block"].(map[string]any)
+				if block["type"] == "tool_use" {
+					toolBlocks[int(index)] = true
+					return nil
+				}
+			case "content_block_delta", "content_block_stop":
+				if toolBlocks[int(index)] {
+					return nil
+				}
+			}
+			return m
+		}
+		return msg
+	})
+}
+
+// deltaFields maps the types of stream deltas that can be merged to the
+// field holding their content.
+var deltaFields = map[string]string{
+	"text_delta":       "text",
+	"thinking_delta":   "thinking",
+	"input_json_delta": "partial_json",
+}
+
+// CoalesceDeltas returns a stage merging consecutive content deltas of
+// partial streaming into one StreamEvent. Only deltas already waiting to
+// be read are merged, so nothing is held back: a consumer that keeps up
+// sees every delta, and one that falls behind catches up in fewer, larger
+// events.
+func CoalesceDeltas() Stage {
+	return func(in <-chan Message) <-chan Message {
+		out := make(chan Message)
+		go func() {
+			defer close(out)
+			var pending Message
+			for {
+				msg := pending
+				pending = nil
+				if msg == nil {
+					var ok bool
+					if msg, ok = <-in; !ok {
+						return
+					}
+				}
+
+				merged, ok := msg.(*StreamEvent)
+				if !ok || deltaField(merged) == "" {
+					out <- msg
+					continue
+				}
+
+			drain:
+				for {
+					select {
+					case next, ok := <-in:
+						if !ok {
+							break drain
+						}
+						event, isEvent := next.(*StreamEvent)
+						if !isEvent || !sameDeltaStream(merged, event) {
+							pending = next
+							break drain
+						}
+						merged = mergeDeltas(merged, event)
+					default:
+						break drain
+					}
+				}
+				out <- merged
+			}
+		}()
+		return out
+	}
+}
+
+// deltaField returns the field holding the content of a mergeable delta
+// event, or "" if event is not one.
+func deltaField(event *StreamEvent) string {
+	if event.Event["type"] != "content_block_delta" {
+		return ""
+	}
+	delta, _ := event.Event["delta"].(map[string]any)
+	deltaType, _ := delta["type"].(string)
+	return deltaFields[deltaType]
+}
+
+// sameDeltaStream reports whether b continues the deltas of a: a delta of
+// the same type to the same content block.
+func sameDeltaStream(a, b *StreamEvent) bool {
+	if deltaField(b) == "" || a.ParentToolUseID != b.ParentToolUseID || a.Event["index"] != b.Event["index"] {
+		return false
+	}
+	da, _ := a.Event["delta"].(map[string]any)
+	db, _ := b.Event["delta"].(map[string]any)
+	return da["type"] == db["type"]
+}
+
+// mergeDeltas returns an event with the content of b appended to that of
+// a. Neither event is modified.
+func mergeDeltas(a, b *StreamEvent) *StreamEvent {
+	field := deltaField(a)
+	da, _ := a.Event["delta"].(map[string]any)
+	db, _ := b.Event["delta"].(map[string]any)
+	textA, _ := da[field].(string)
+	textB, _ := db[field].(string)
+
+	delta := make(map[string]any, len(da))
+	for k, v := range da {
+		delta[k] = v
+	}
+	delta[field] = textA + textB
+
+	event := make(map[string]any, len(a.Event))
+	for k, v := range a.Event {
+		event[k] = v
+	}
+	event["delta"] = delta
+
+	merged := *b
+	merged.Event = event
+	return &merged
+}
@@ -0,0 +1,206 @@
+package claude
+
+import (
+	"testing"
+)
+
+// feed returns a closed channel holding msgs.
+func feed(msgs ...Message) <-chan Message {
+	ch := make(chan Message, len(msgs))
+	for _, msg := range msgs {
+		ch <- msg
+	}
+	close(ch)
+	return ch
+}
+
+func drain(ch <-chan Message) []Message {
+	var msgs []Message
+	for msg := range ch {
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func textDelta(index int, text string) *StreamEvent {
+	return &StreamEvent{Event: map[string]any{
+		"type":  "content_block_delta",
+		"index": float64(index),
+		"delta": map[string]any{"type": "text_delta", "text": text},
+	}}
+}
+
+func TestPipe_NoStages(t *testing.T) {
+	msgs := drain(Pipe(feed(&SystemMessage{Subtype: "init"}, &ResultMessage{})))
+	if len(msgs) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(msgs))
+	}
+}
+
+func TestPipe_StagesInOrder(t *testing.T) {
+	var order []string
+	tag := func(name string) Stage {
+		return Map(func(msg Message) Message {
+			order = append(order, name)
+			return msg
+		})
+	}
+
+	msgs := drain(Pipe(feed(&ResultMessage{}), tag("first"), tag("second")))
+	if len(msgs) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(msgs))
+	}
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Errorf("Unexpected stage order: %v", order)
+	}
+}
+
+func TestMap_DropsNil(t *testing.T) {
+	msgs := drain(Pipe(
+		feed(&SystemMessage{}, &ResultMessage{}),
+		Map(func(msg Message) Message {
+			if _, ok := msg.(*SystemMessage); ok {
+				return nil
+			}
+			return msg
+		}),
+	))
+	if len(msgs) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(msgs))
+	}
+	if _, ok := msgs[0].(*ResultMessage); !ok {
+		t.Errorf("Expected *ResultMessage, got %T", msgs[0])
+	}
+}
+
+func TestOnlyAssistant(t *testing.T) {
+	msgs := drain(Pipe(
+		feed(
+			&SystemMessage{Subtype: "init"},
+			&AssistantMessage{Content: []ContentBlock{TextBlock{Text: "hi"}}},
+			&UserMessage{Content: "hello"},
+			&ResultMessage{},
+		),
+		OnlyAssistant(),
+	))
+	if len(msgs) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(msgs))
+	}
+	if _, ok := msgs[0].(*AssistantMessage); !ok {
+		t.Errorf("Expected *AssistantMessage, got %T", msgs[0])
+	}
+}
+
+func TestFilterToolNoise(t *testing.T) {
+	mixed := &AssistantMessage{Content: []ContentBlock{
+		TextBlock{Text: "Let me look."},
+		ToolUseBlock{ID: "t1", Name: "Read"},
+	}}
+	msgs := drain(Pipe(
+		feed(
+			&UserMessage{Content: "read it"},
+			mixed,
+			&AssistantMessage{Content: []ContentBlock{ToolUseBlock{ID: "t2", Name: "Bash"}}},
+			&IncrementalToolUse{ID: "t2", Name: "Bash"},
+			&UserMessage{Content: []ContentBlock{ToolResultBlock{ToolUseID: "t1"}}},
+			&AssistantMessage{Content: []ContentBlock{TextBlock{Text: "sub"}}, ParentToolUseID: "t3"},
+			&AssistantMessage{Content: []ContentBlock{TextBlock{Text: "Done."}}},
+			&ResultMessage{},
+		),
+		FilterToolNoise(),
+	))
+
+	if len(msgs) != 4 {
+		t.Fatalf("Expected 4 messages, got %d: %#v", len(msgs), msgs)
+	}
+	if _, ok := msgs[0].(*UserMessage); !ok {
+		t.Errorf("Expected the prompt first, got %T", msgs[0])
+	}
+	first, ok := msgs[1].(*AssistantMessage)
+	if !ok || len(first.Content) != 1 || first.Text() != "Let me look." {
+		t.Errorf("Expected the text of the mixed message, got %#v", msgs[1])
+	}
+	if len(mixed.Content) != 2 {
+		t.Error("Expected the original message to be left unmodified")
+	}
+	if last, ok := msgs[2].(*AssistantMessage); !ok || last.Text() != "Done." {
+		t.Errorf("Expected the final answer, got %#v", msgs[2])
+	}
+	if _, ok := msgs[3].(*ResultMessage); !ok {
+		t.Errorf("Expected the result last, got %T", msgs[3])
+	}
+}
+
+func TestFilterToolNoise_StreamEvents(t *testing.T) {
+	msgs := drain(Pipe(
+		feed(
+			&StreamEvent{Event: map[string]any{"type": "message_start"}},
+			&StreamEvent{Event: map[string]any{"type": "content_block_start", "index": float64(0), "content_block": map[string]any{"type": "text"}}},
+			textDelta(0, "hi"),
+			&StreamEvent{Event: map[string]any{"type": "content_block_start", "index": float64(1), "content_block": map[string]any{"type": "tool_use"}}},
+			&StreamEvent{Event: map[string]any{"type": "content_block_delta", "index": float64(1), "delta": map[string]any{"type": "input_json_delta", "partial_json": "{"}}},
+			&StreamEvent{Event: map[string]any{"type": "content_block_stop", "index": float64(1)}},
+			&StreamEvent{Event: map[string]any{"type": "message_start"}},
+			textDelta(1, "next"),
+		),
+		FilterToolNoise(),
+	))
+
+	if len(msgs) != 5 {
+		t.Fatalf("Expected 5 events, got %d", len(msgs))
+	}
+	// Index 1 of the second message is text again
+	if last := msgs[4].(*StreamEvent); last.Event["index"] != float64(1) {
+		t.Errorf("Expected the text delta of the second message, got %v", last.Event)
+	}
+}
+
+func TestCoalesceDeltas(t *testing.T) {
+	msgs := drain(Pipe(
+		feed(
+			&StreamEvent{Event: map[string]any{"type": "content_block_start", "index": float64(0)}},
+			textDelta(0, "Hel"),
+			textDelta(0, "lo"),
+			textDelta(0, ", world"),
+			textDelta(1, "!"),
+			&AssistantMessage{Content: []ContentBlock{TextBlock{Text: "Hello, world!"}}},
+		),
+		CoalesceDeltas(),
+	))
+
+	if len(msgs) != 4 {
+		t.Fatalf("Expected 4 messages, got %d", len(msgs))
+	}
+	merged := msgs[1].(*StreamEvent)
+	delta := merged.Event["delta"].(map[string]any)
+	if delta["text"] != "Hello, world" {
+		t.Errorf("Expected merged text, got %q", delta["text"])
+	}
+	if other := msgs[2].(*StreamEvent); other.Event["index"] != float64(1) {
+		t.Errorf("Expected the delta of another block kept apart, got %v", other.Event)
+	}
+	if _, ok := msgs[3].(*AssistantMessage); !ok {
+		t.Errorf("Expected the assistant message last, got %T", msgs[3])
+	}
+}
+
+func TestCoalesceDeltas_DoesNotWait(t *testing.T) {
+	in := make(chan Message)
+	out := Pipe(in, CoalesceDeltas())
+	defer close(in)
+
+	in <- textDelta(0, "a")
+	// The delta is forwarded without waiting for more
+	msg := <-out
+	if delta := msg.(*StreamEvent).Event["delta"].(map[string]any); delta["text"] != "a" {
+		t.Errorf("Expected %q, got %q", "a", delta["text"])
+	}
+}
+
+func TestCoalesceDeltas_DoesNotModifyInput(t *testing.T) {
+	first := textDelta(0, "a")
+	drain(Pipe(feed(first, textDelta(0, "b")), CoalesceDeltas()))
+	if first.Event["delta"].(map[string]any)["text"] != "a" {
+		t.Error("Expected the input event to be left unmodified")
+	}
+}
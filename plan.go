@@ -0,0 +1,102 @@
+package claude
+
+import "context"
+
+// PlanProposal is a plan Claude proposes in plan mode, by calling the
+// ExitPlanMode tool.
+type PlanProposal struct {
+	ToolUseID string
+	Plan      string
+}
+
+// PlanProposal returns the plan the message proposes, reporting false if
+// it makes no ExitPlanMode tool use.
+func (m *AssistantMessage) PlanProposal() (*PlanProposal, bool) {
+	for _, use := range m.ToolUses() {
+		if use.Name == string(ToolExitPlanMode) {
+			plan, _ := use.Input["plan"].(string)
+			return &PlanProposal{ToolUseID: use.ID, Plan: plan}, true
+		}
+	}
+	return nil, false
+}
+
+// PlanDecision is the answer of a PlanApproverFunc to a plan.
+type PlanDecision struct {
+	// Approve reports whether Claude may carry out the plan.
+	Approve bool
+
+	// Mode is the permission mode the session leaves plan mode for on
+	// approval, PermissionModeDefault if empty.
+	Mode PermissionMode
+
+	// Feedback tells Claude why the plan is rejected, or what to change
+	// about it.
+	Feedback string
+}
+
+// ApprovePlan returns a decision approving a plan and leaving plan mode
+// for mode.
+func ApprovePlan(mode PermissionMode) PlanDecision {
+	return PlanDecision{Approve: true, Mode: mode}
+}
+
+// RejectPlan returns a decision rejecting a plan, keeping the session in
+// plan mode, with feedback for Claude.
+func RejectPlan(feedback string) PlanDecision {
+	return PlanDecision{Feedback: feedback}
+}
+
+// PlanApproverFunc decides on the plans Claude proposes in plan mode.
+type PlanApproverFunc func(ctx context.Context, proposal PlanProposal) (PlanDecision, error)
+
+// approvePlans returns a permission callback answering the ExitPlanMode
+// calls with approve and passing other tools on to fn, or to the CLI if
+// fn is nil. onApprove is called with the mode of each plan approved.
+func approvePlans(fn CanUseToolFunc, approve PlanApproverFunc, onApprove func(PermissionMode)) CanUseToolFunc {
+	if approve == nil {
+		return fn
+	}
+	return func(ctx context.Context, toolName string, input map[string]any, permCtx ToolPermissionContext) (PermissionResult, error) {
+		if toolName != string(ToolExitPlanMode) {
+			if fn == nil {
+				return PermissionResultAsk{}, nil
+			}
+			return fn(ctx, toolName, input, permCtx)
+		}
+
+		plan, _ := input["plan"].(string)
+		info, _ := ToolCallInfoFromContext(ctx)
+		decision, err := approve(ctx, PlanProposal{ToolUseID: info.ToolUseID, Plan: plan})
+		if err != nil {
+			return nil, err
+		}
+		if !decision.Approve {
+			return PermissionResultDeny{Message: decision.Feedback}, nil
+		}
+
+		mode := decision.Mode
+		if mode == "" {
+			mode = PermissionModeDefault
+		}
+		if onApprove != nil {
+			onApprove(mode)
+		}
+		return PermissionResultAllow{
+			UpdatedInput: input,
+			UpdatedPermissions: []PermissionUpdate{{
+				Type:        PermissionUpdateTypeSetMode,
+				Mode:        mode,
+				Destination: PermissionUpdateDestinationSession,
+			}},
+		}, nil
+	}
+}
+
+// permissionCallback returns the permission callback of o: CanUseTool,
+// with its asks resolved by AskResolver and plans decided by
+// PlanApprover. It is nil if none of them is set, leaving permissions to
+// the CLI.
+func permissionCallback(o *Options, onApprove func(PermissionMode)) CanUseToolFunc {
+	return approvePlans(resolveAsks(o.CanUseTool, o.AskResolver), o.PlanApprover, onApprove)
+}
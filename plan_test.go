@@ -0,0 +1,105 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/protocol"
+)
+
+func TestAssistantMessage_PlanProposal(t *testing.T) {
+	msg := &AssistantMessage{Content: []ContentBlock{
+		TextBlock{Text: "Here is my plan."},
+		ToolUseBlock{ID: "t1", Name: "ExitPlanMode", Input: map[string]any{"plan": "1. Add tests"}},
+	}}
+	proposal, ok := msg.PlanProposal()
+	if !ok || proposal.ToolUseID != "t1" || proposal.Plan != "1. Add tests" {
+		t.Errorf("Expected the proposed plan, got %+v, %v", proposal, ok)
+	}
+
+	msg = &AssistantMessage{Content: []ContentBlock{ToolUseBlock{ID: "t2", Name: "Read"}}}
+	if _, ok := msg.PlanProposal(); ok {
+		t.Error("Expected no plan without an ExitPlanMode tool use")
+	}
+}
+
+func TestApprovePlans(t *testing.T) {
+	var proposed PlanProposal
+	var approved PermissionMode
+	decision := ApprovePlan(PermissionModeAcceptEdits)
+	fn := approvePlans(nil, func(ctx context.Context, p PlanProposal) (PlanDecision, error) {
+		proposed = p
+		return decision, nil
+	}, func(mode PermissionMode) { approved = mode })
+
+	ctx := protocol.WithToolUseID(context.Background(), "t1")
+	input := map[string]any{"plan": "1. Add tests"}
+	result, err := fn(ctx, "ExitPlanMode", input, ToolPermissionContext{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	allow, ok := result.(PermissionResultAllow)
+	if !ok || len(allow.UpdatedPermissions) != 1 {
+		t.Fatalf("Expected the plan approved with a mode change, got %+v", result)
+	}
+	if update := allow.UpdatedPermissions[0]; update.Type != PermissionUpdateTypeSetMode || update.Mode != PermissionModeAcceptEdits {
+		t.Errorf("Expected a change to acceptEdits, got %+v", update)
+	}
+	if proposed.ToolUseID != "t1" || proposed.Plan != "1. Add tests" {
+		t.Errorf("Expected the proposal passed to the approver, got %+v", proposed)
+	}
+	if approved != PermissionModeAcceptEdits {
+		t.Errorf("Expected onApprove called with acceptEdits, got %q", approved)
+	}
+
+	// Approval without a mode leaves plan mode for the default mode
+	decision = PlanDecision{Approve: true}
+	result, _ = fn(ctx, "ExitPlanMode", input, ToolPermissionContext{})
+	if allow := result.(PermissionResultAllow); allow.UpdatedPermissions[0].Mode != PermissionModeDefault {
+		t.Errorf("Expected a change to the default mode, got %+v", allow.UpdatedPermissions)
+	}
+
+	decision = RejectPlan("Keep the schema as is.")
+	result, _ = fn(ctx, "ExitPlanMode", input, ToolPermissionContext{})
+	if deny, ok := result.(PermissionResultDeny); !ok || deny.Message != "Keep the schema as is." {
+		t.Errorf("Expected the plan rejected with the feedback, got %+v", result)
+	}
+
+	// Without a callback, other tools are left to the CLI
+	if result, _ := fn(ctx, "Bash", nil, ToolPermissionContext{}); result != (PermissionResultAsk{}) {
+		t.Errorf("Expected Bash left to the CLI, got %+v", result)
+	}
+}
+
+func TestApprovePlans_OtherTools(t *testing.T) {
+	canUseTool := func(ctx context.Context, toolName string, input map[string]any, permCtx ToolPermissionContext) (PermissionResult, error) {
+		return PermissionResultDeny{Message: "no " + toolName}, nil
+	}
+	failing := errors.New("approver failed")
+	fn := approvePlans(canUseTool, func(ctx context.Context, p PlanProposal) (PlanDecision, error) {
+		return PlanDecision{}, failing
+	}, nil)
+
+	if result, _ := fn(context.Background(), "Bash", nil, ToolPermissionContext{}); result != (PermissionResultDeny{Message: "no Bash"}) {
+		t.Errorf("Expected Bash passed to CanUseTool, got %+v", result)
+	}
+	if _, err := fn(context.Background(), "ExitPlanMode", nil, ToolPermissionContext{}); !errors.Is(err, failing) {
+		t.Errorf("Expected the error of the approver, got %v", err)
+	}
+
+	if approvePlans(nil, nil, nil) != nil {
+		t.Error("Expected no callback without CanUseTool or an approver")
+	}
+	if permissionCallback(NewOptions(WithPlanApprover(func(ctx context.Context, p PlanProposal) (PlanDecision, error) {
+		return ApprovePlan(""), nil
+	})), nil) == nil {
+		t.Error("Expected a permission callback with only an approver")
+	}
+}
+
+func TestWithPlanMode(t *testing.T) {
+	if mode := NewOptions(WithPlanMode()).PermissionMode; mode != PermissionModePlan {
+		t.Errorf("Expected plan mode, got %q", mode)
+	}
+}
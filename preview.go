@@ -0,0 +1,48 @@
+package claude
+
+import "github.com/afsharalex/claude-agent-sdk-go/internal/transport"
+
+// BuildCommandPreview returns the command line a Client with options o
+// would start the CLI with, without starting it, so that an option that
+// does not seem to take effect can be checked against the flags the CLI
+// receives. The command is that of Client.Connect; queries add their
+// prompt to it and run the CLI in print mode.
+//
+// Secrets are redacted: values of flags, variables and JSON keys named
+// like secrets (such as API keys, tokens and passwords), the env and
+// headers of MCP servers and settings, and passwords in proxy URLs. MCP
+// servers shared with WithSharedMCPServers appear as configured, since
+// previewing does not start them.
+//
+// It fails with the errors Connect would return for invalid options, or
+// if the CLI cannot be found.
+//
+// Example:
+//
+//	argv, err := claude.NewOptions(
+//	    claude.WithModel("claude-sonnet-4-5"),
+//	    claude.WithAllowedTools([]string{"Read", "Grep"}),
+//	).BuildCommandPreview()
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	fmt.Println(strings.Join(argv, " "))
+func (o *Options) BuildCommandPreview() ([]string, error) {
+	if permissionCallback(o, nil) != nil && o.PermissionPromptToolName != "" {
+		return nil, NewClaudeSDKError("can_use_tool callback cannot be used with permission_prompt_tool_name")
+	}
+	if err := validateOptions(o); err != nil {
+		return nil, err
+	}
+	files, err := loadContextFiles(o)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{options: o, contextFiles: files}
+	t, err := transport.NewSubprocessTransport("", true, c.transportOptions(nil))
+	if err != nil {
+		return nil, err
+	}
+	return t.PreviewCommand()
+}
@@ -0,0 +1,57 @@
+package claude
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestOptions_BuildCommandPreview(t *testing.T) {
+	apiKey := "sk-ant-secret"
+	opts := NewOptions(
+		WithCLIPath("/usr/local/bin/claude"),
+		WithModel("claude-sonnet-4-5"),
+		WithMCPServers(map[string]MCPServerConfig{
+			"docs": MCPHTTPServerConfig{Type: "http", URL: "https://mcp.example.com", Headers: map[string]string{"Authorization": "Bearer " + apiKey}},
+		}),
+		WithCanUseTool(func(ctx context.Context, toolName string, input map[string]any, permCtx ToolPermissionContext) (PermissionResult, error) {
+			return PermissionResultAllow{}, nil
+		}),
+	)
+	opts.ExtraArgs["api-key"] = &apiKey
+
+	argv, err := opts.BuildCommandPreview()
+	if err != nil {
+		t.Fatalf("BuildCommandPreview failed: %v", err)
+	}
+	cmd := strings.Join(argv, " ")
+	if argv[0] != "/usr/local/bin/claude" || !strings.Contains(cmd, "--model claude-sonnet-4-5") {
+		t.Errorf("Expected the CLI started with the model, got %q", cmd)
+	}
+	if !strings.Contains(cmd, "--permission-prompt-tool stdio") {
+		t.Errorf("Expected the permission prompt tool set for CanUseTool, got %q", cmd)
+	}
+	if strings.Contains(cmd, apiKey) {
+		t.Errorf("Expected the secrets redacted, got %q", cmd)
+	}
+	if i := slices.Index(argv, "--api-key"); i < 0 || argv[i+1] != "[REDACTED]" {
+		t.Errorf("Expected the value of --api-key redacted, got %q", cmd)
+	}
+	if !strings.Contains(cmd, `"url":"https://mcp.example.com"`) {
+		t.Errorf("Expected the MCP config kept apart from its secrets, got %q", cmd)
+	}
+}
+
+func TestOptions_BuildCommandPreview_InvalidOptions(t *testing.T) {
+	opts := NewOptions(
+		WithCLIPath("/usr/local/bin/claude"),
+		WithPermissionPromptToolName("mcp__auth__prompt"),
+		WithCanUseTool(func(ctx context.Context, toolName string, input map[string]any, permCtx ToolPermissionContext) (PermissionResult, error) {
+			return PermissionResultAllow{}, nil
+		}),
+	)
+	if _, err := opts.BuildCommandPreview(); err == nil {
+		t.Error("Expected the options Connect rejects to fail")
+	}
+}
@@ -0,0 +1,156 @@
+package claude
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// processFailure is what parseProcessFailure makes of a failed CLI's
+// output.
+type processFailure struct {
+	kind   ProcessErrorKind
+	hint   string
+	docURL string
+}
+
+// processErrorHints are the hints given for failures whose output has
+// none.
+var processErrorHints = map[ProcessErrorKind]string{
+	ProcessErrorKindAuthentication: "Log in with `claude login`, or set ANTHROPIC_API_KEY.",
+	ProcessErrorKindInvalidFlag:    "Update the Claude Code CLI; this version does not support a flag the SDK passed.",
+	ProcessErrorKindInvalidModel:   "Check the model name; Models lists the models the CLI offers.",
+	ProcessErrorKindUpdateRequired: "Update the Claude Code CLI with `claude update`.",
+}
+
+// processErrorPatterns classify failures by the code or text the CLI
+// reported, lowercased. They are tried in order.
+var processErrorPatterns = []struct {
+	kind     ProcessErrorKind
+	patterns []string
+}{
+	{ProcessErrorKindAuthentication, []string{
+		"authentication_error", "authentication_failed", "invalid api key", "invalid x-api-key",
+		"/login", "not logged in", "please log in", "oauth token has expired", "no credentials",
+	}},
+	{ProcessErrorKindUpdateRequired, []string{
+		"update_required", "update required", "out of date", "please update", "minimum version",
+		"no longer supported",
+	}},
+	{ProcessErrorKindInvalidModel, []string{
+		"invalid model", "invalid_model", "unknown model", "model not found", "model_not_found",
+	}},
+	{ProcessErrorKindInvalidFlag, []string{
+		"unknown option", "unknown argument", "invalid option", "unexpected argument", "error: option",
+	}},
+}
+
+// docURLPattern finds a documentation link in the CLI's output.
+var docURLPattern = regexp.MustCompile(`https?://[^\s"'<>)]+`)
+
+// parseProcessFailure classifies the failure of the CLI from its stderr.
+// The last line holding a JSON error payload is used if there is one;
+// otherwise the text is matched against known messages.
+func parseProcessFailure(stderr string) processFailure {
+	var failure processFailure
+	text := stderr
+
+	lines := strings.Split(stderr, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if payload, ok := parseErrorPayload(lines[i]); ok {
+			text = payload.code + "\n" + payload.message
+			if kind := ProcessErrorKind(payload.kind); processErrorHints[kind] != "" {
+				failure.kind = kind
+			}
+			failure.hint = payload.hint
+			failure.docURL = payload.docURL
+			break
+		}
+	}
+
+	if failure.kind == ProcessErrorKindUnknown {
+		failure.kind = classifyProcessError(text)
+	}
+	if failure.hint == "" {
+		failure.hint = processErrorHints[failure.kind]
+	}
+	if failure.docURL == "" {
+		failure.docURL = strings.TrimRight(docURLPattern.FindString(text), ".,;:")
+	}
+	return failure
+}
+
+// classifyProcessError matches text against the known failures.
+func classifyProcessError(text string) ProcessErrorKind {
+	text = strings.ToLower(text)
+	for _, p := range processErrorPatterns {
+		for _, pattern := range p.patterns {
+			if strings.Contains(text, pattern) {
+				return p.kind
+			}
+		}
+	}
+	// API errors for unknown models only say the resource was not found
+	if strings.Contains(text, "not_found_error") && strings.Contains(text, "model") {
+		return ProcessErrorKindInvalidModel
+	}
+	return ProcessErrorKindUnknown
+}
+
+// errorPayload holds the fields of a JSON error payload.
+type errorPayload struct {
+	kind    string
+	code    string
+	message string
+	hint    string
+	docURL  string
+}
+
+// parseErrorPayload decodes a line holding a JSON error, such as an API
+// error ({"type":"error","error":{"type":"...","message":"..."}}), an
+// error result, or an object with error, hint and doc URL fields.
+func parseErrorPayload(line string) (errorPayload, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "{") {
+		return errorPayload{}, false
+	}
+	var data map[string]any
+	if err := json.Unmarshal([]byte(line), &data); err != nil {
+		return errorPayload{}, false
+	}
+
+	str := func(m map[string]any, keys ...string) string {
+		for _, key := range keys {
+			if s, ok := m[key].(string); ok && s != "" {
+				return s
+			}
+		}
+		return ""
+	}
+
+	var p errorPayload
+	switch e := data["error"].(type) {
+	case string:
+		p.message = e
+	case map[string]any:
+		p.code = str(e, "type", "code")
+		p.message = str(e, "message")
+	}
+	if p.message == "" && data["is_error"] == true {
+		p.message = str(data, "result")
+	}
+	if p.message == "" {
+		p.message = str(data, "message")
+	}
+	if p.code == "" {
+		p.code = str(data, "code", "error_type")
+	}
+	if p.message == "" && p.code == "" {
+		return errorPayload{}, false
+	}
+
+	p.kind = str(data, "kind")
+	p.hint = str(data, "hint")
+	p.docURL = str(data, "doc_url", "docs_url", "docUrl", "docsUrl", "documentation_url")
+	return p, true
+}
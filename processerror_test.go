@@ -0,0 +1,72 @@
+package claude
+
+import "testing"
+
+func TestParseProcessFailure(t *testing.T) {
+	tests := map[string]struct {
+		stderr string
+		kind   ProcessErrorKind
+		hint   string
+		docURL string
+	}{
+		"login text": {
+			stderr: "Invalid API key · Please run /login",
+			kind:   ProcessErrorKindAuthentication,
+			hint:   processErrorHints[ProcessErrorKindAuthentication],
+		},
+		"api error payload": {
+			stderr: "starting\n" + `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`,
+			kind:   ProcessErrorKindAuthentication,
+			hint:   processErrorHints[ProcessErrorKindAuthentication],
+		},
+		"unknown flag": {
+			stderr: "error: unknown option '--output-format-v2'",
+			kind:   ProcessErrorKindInvalidFlag,
+			hint:   processErrorHints[ProcessErrorKindInvalidFlag],
+		},
+		"model not found": {
+			stderr: `{"type":"error","error":{"type":"not_found_error","message":"model: claude-nonexistent"}}`,
+			kind:   ProcessErrorKindInvalidModel,
+			hint:   processErrorHints[ProcessErrorKindInvalidModel],
+		},
+		"update required with link": {
+			stderr: "Your version of Claude Code is out of date. See https://docs.example.com/update.",
+			kind:   ProcessErrorKindUpdateRequired,
+			hint:   processErrorHints[ProcessErrorKindUpdateRequired],
+			docURL: "https://docs.example.com/update",
+		},
+		"payload fields": {
+			stderr: `{"error":"login required","kind":"authentication","hint":"Run claude setup-token","doc_url":"https://docs.example.com/auth"}`,
+			kind:   ProcessErrorKindAuthentication,
+			hint:   "Run claude setup-token",
+			docURL: "https://docs.example.com/auth",
+		},
+		"error result": {
+			stderr: `{"type":"result","is_error":true,"result":"Invalid model name"}`,
+			kind:   ProcessErrorKindInvalidModel,
+			hint:   processErrorHints[ProcessErrorKindInvalidModel],
+		},
+		"unrecognized": {
+			stderr: "segmentation fault",
+			kind:   ProcessErrorKindUnknown,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := parseProcessFailure(tt.stderr)
+			if got.kind != tt.kind || got.hint != tt.hint || got.docURL != tt.docURL {
+				t.Errorf("Expected %q %q %q, got %q %q %q", tt.kind, tt.hint, tt.docURL, got.kind, got.hint, got.docURL)
+			}
+		})
+	}
+}
+
+func TestNewProcessError_Kind(t *testing.T) {
+	err := NewProcessError("Claude Code process failed", 1, "Not logged in · Please run /login")
+	if err.Kind != ProcessErrorKindAuthentication || err.Hint == "" {
+		t.Errorf("Expected an authentication error with a hint, got %q %q", err.Kind, err.Hint)
+	}
+	if err.Stderr != "Not logged in · Please run /login" {
+		t.Errorf("Expected stderr to be kept, got %q", err.Stderr)
+	}
+}
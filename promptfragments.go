@@ -0,0 +1,34 @@
+package claude
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// SystemPromptFragment is a part of the system prompt contributed with
+// WithSystemPromptFragment.
+type SystemPromptFragment struct {
+	// Name identifies the fragment; a later fragment with the same name
+	// replaces it.
+	Name string
+	Text string
+	// Order places the fragment among the others, lowest first.
+	Order int
+}
+
+// systemPromptFragments joins the fragments of o into one text, by Order
+// and then by Name, separated by blank lines.
+func systemPromptFragments(o *Options) string {
+	fragments := slices.Clone(o.SystemPromptFragments)
+	slices.SortStableFunc(fragments, func(a, b SystemPromptFragment) int {
+		return cmp.Or(cmp.Compare(a.Order, b.Order), strings.Compare(a.Name, b.Name))
+	})
+	var parts []string
+	for _, f := range fragments {
+		if f.Text != "" {
+			parts = append(parts, f.Text)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
@@ -0,0 +1,52 @@
+package claude
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// providerSettings lists the environment variables each provider needs.
+// Bedrock also accepts AWS_DEFAULT_REGION for its region.
+var providerSettings = map[AuthMethod][][]string{
+	AuthMethodAPIKey:  {{"ANTHROPIC_API_KEY"}},
+	AuthMethodBedrock: {{"AWS_REGION", "AWS_DEFAULT_REGION"}},
+	AuthMethodVertex:  {{"ANTHROPIC_VERTEX_PROJECT_ID"}, {"CLOUD_ML_REGION"}},
+}
+
+// validateProvider checks that the provider chosen with WithAPIKey,
+// WithBedrock or WithVertex has its settings, from the options or the
+// environment the CLI inherits.
+func validateProvider(o *Options) error {
+	if o.Provider == AuthMethodNone {
+		return nil
+	}
+	settings, ok := providerSettings[o.Provider]
+	if !ok {
+		return NewClaudeSDKError(fmt.Sprintf("unsupported provider %q", o.Provider))
+	}
+
+	env := func(key string) string {
+		if value, ok := o.Env[key]; ok {
+			return value
+		}
+		return os.Getenv(key)
+	}
+	var missing []string
+	for _, keys := range settings {
+		found := false
+		for _, key := range keys {
+			if strings.TrimSpace(env(key)) != "" {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, keys[0])
+		}
+	}
+	if len(missing) > 0 {
+		return NewClaudeSDKError(fmt.Sprintf("provider %s is missing %s", o.Provider, strings.Join(missing, ", ")))
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package claude
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestValidateProvider(t *testing.T) {
+	for _, key := range []string{"ANTHROPIC_API_KEY", "AWS_REGION", "AWS_DEFAULT_REGION", "ANTHROPIC_VERTEX_PROJECT_ID", "CLOUD_ML_REGION"} {
+		t.Setenv(key, "")
+	}
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr string
+	}{
+		{"no provider", nil, ""},
+		{"api key", []Option{WithAPIKey("sk-ant-test")}, ""},
+		{"empty api key", []Option{WithAPIKey("")}, "missing ANTHROPIC_API_KEY"},
+		{"bedrock", []Option{WithBedrock("us-east-1", "")}, ""},
+		{"bedrock without region", []Option{WithBedrock("", "dev")}, "missing AWS_REGION"},
+		{"bedrock region from env", []Option{WithEnvVar("AWS_DEFAULT_REGION", "eu-west-1"), WithBedrock("", "")}, ""},
+		{"vertex", []Option{WithVertex("my-project", "us-east5")}, ""},
+		{"vertex without project", []Option{WithVertex("", "us-east5")}, "missing ANTHROPIC_VERTEX_PROJECT_ID"},
+		{"vertex without settings", []Option{WithVertex("", "")}, "ANTHROPIC_VERTEX_PROJECT_ID, CLOUD_ML_REGION"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateProvider(NewOptions(tt.opts...))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateProvider_Environment(t *testing.T) {
+	t.Setenv("CLOUD_ML_REGION", "us-east5")
+	if err := validateProvider(NewOptions(WithVertex("my-project", ""))); err != nil {
+		t.Errorf("Expected the region from the environment, got %v", err)
+	}
+}
+
+func TestQuery_MissingProviderSettings(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	messages, errs := Query(context.Background(), "Hi", WithCLIPath("/nonexistent/claude"), WithBedrock("", ""))
+	for range messages {
+	}
+	if err := <-errs; err == nil || !strings.Contains(err.Error(), "AWS_REGION") {
+		t.Errorf("Expected region error before starting the CLI, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package claude
+
+import (
+	"context"
+	"errors"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/transport"
+)
+
+// QueryOnce runs prompt as a one-shot query and returns its result. The CLI
+// runs in its single JSON output mode, printing only the result when it
+// finishes, so no message stream is parsed along the way. This suits
+// automation that only needs the final answer.
+//
+// As no intermediate messages are seen, model fallbacks, structured output
+// repair and the stall watchdog of Query do not apply. A result that
+// reports an error is returned as is, with IsError set; an error is
+// returned only if no result was produced.
+func QueryOnce(ctx context.Context, prompt string, opts ...Option) (*ResultMessage, error) {
+	options := NewOptions(opts...)
+	if err := validateOptions(options); err != nil {
+		return nil, err
+	}
+	files, err := loadContextFiles(options)
+	if err != nil {
+		return nil, err
+	}
+	options = files.systemOptions(options)
+	prompt = files.prompt(prompt)
+
+	if err := options.RateLimitPacer.Wait(ctx); err != nil {
+		return nil, err
+	}
+	if err := preflightMCP(ctx, options); err != nil {
+		return nil, err
+	}
+
+	transportOpts := toTransportOptions(options)
+	transportOpts.JSONOutput = true
+	if err := applyDeadline(ctx, options, transportOpts); err != nil {
+		return nil, err
+	}
+
+	lock, err := acquireWorkspaceLock(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.release()
+
+	t, err := transport.NewSubprocessTransport(prompt, false, transportOpts)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Connect(ctx); err != nil {
+		return nil, err
+	}
+	defer func() { _ = t.Close() }()
+
+	var result *ResultMessage
+	var readErr error
+	for r := range t.ReadMessages(ctx) {
+		if r.Error != nil {
+			readErr = r.Error
+			continue
+		}
+		if r.Data["type"] != "result" {
+			continue
+		}
+		msg, err := ParseMessage(r.Data)
+		if err != nil {
+			return nil, err
+		}
+		result, _ = msg.(*ResultMessage)
+		newForkRecorder(options.ConversationTree, transportOpts).observe(msg)
+	}
+
+	if result != nil {
+		limits := newRateLimitTracker()
+		limits.observe(result)
+		options.RateLimitPacer.observe(limits.result())
+		return result, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var exitErr *transport.ExitError
+	if errors.As(readErr, &exitErr) {
+		return nil, NewProcessError("Claude Code process failed", exitErr.ExitCode, exitErr.Stderr)
+	}
+	if IsBufferLimitError(publicError(readErr)) {
+		return nil, publicError(readErr)
+	}
+	if readErr != nil {
+		return nil, NewClaudeSDKError(readErr.Error())
+	}
+	return nil, NewClaudeSDKError("no result received from Claude Code")
+}
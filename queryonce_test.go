@@ -0,0 +1,77 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryOnce(t *testing.T) {
+	dir := t.TempDir()
+	cli := writeStubCLI(t, fmt.Sprintf(`
+echo "$*" > %s/args
+echo '{"type":"result","subtype":"success","is_error":false,"duration_ms":12,"num_turns":1,"session_id":"sess-1","total_cost_usd":0.01,"result":"4"}'
+`, dir))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := QueryOnce(ctx, "What is 2+2?", WithCLIPath(cli))
+	if err != nil {
+		t.Fatalf("QueryOnce failed: %v", err)
+	}
+	if result.Result != "4" || result.SessionID != "sess-1" || result.NumTurns != 1 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	if !strings.HasPrefix(string(args), "--output-format json ") || strings.Contains(string(args), "--verbose") {
+		t.Errorf("Expected single JSON output mode, got %q", args)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(string(args)), "--print -- What is 2+2?") {
+		t.Errorf("Expected the prompt to be passed, got %q", args)
+	}
+}
+
+func TestQueryOnce_ErrorResult(t *testing.T) {
+	cli := writeStubCLI(t, `
+echo '{"type":"result","subtype":"error_max_turns","is_error":true,"session_id":"sess-1"}'
+exit 1
+`)
+
+	result, err := QueryOnce(context.Background(), "Loop", WithCLIPath(cli))
+	if err != nil {
+		t.Fatalf("Expected the error result to be returned, got %v", err)
+	}
+	if !result.IsError || result.Subtype != "error_max_turns" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
+
+func TestQueryOnce_ProcessError(t *testing.T) {
+	cli := writeStubCLI(t, `
+echo 'invalid API key' >&2
+exit 2
+`)
+
+	_, err := QueryOnce(context.Background(), "Hi", WithCLIPath(cli))
+	procErr, ok := AsProcessError(err)
+	if !ok {
+		t.Fatalf("Expected ProcessError, got %v", err)
+	}
+	if procErr.ExitCode != 2 || !strings.Contains(procErr.Stderr, "invalid API key") {
+		t.Errorf("Unexpected ProcessError %+v", procErr)
+	}
+}
+
+func TestQueryOnce_NoResult(t *testing.T) {
+	cli := writeStubCLI(t, `echo 'not json'`)
+
+	if _, err := QueryOnce(context.Background(), "Hi", WithCLIPath(cli)); err == nil || !strings.Contains(err.Error(), "no result") {
+		t.Errorf("Expected no result error, got %v", err)
+	}
+}
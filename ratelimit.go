@@ -0,0 +1,162 @@
+package claude
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultRateLimitPause is how long a RateLimitPacer pauses for a rate
+// limit whose reset time is unknown.
+const defaultRateLimitPause = 30 * time.Second
+
+// RateLimitPacer pauses new turns until a rate limit resets. Share one
+// pacer between the Clients and Query calls of a worker pool with
+// WithRateLimitPacer, so that a limit hit by one worker pauses all of them
+// instead of each one failing in turn.
+type RateLimitPacer struct {
+	mu      sync.Mutex
+	resetAt time.Time
+
+	now func() time.Time
+}
+
+// NewRateLimitPacer creates a RateLimitPacer that is not paused.
+func NewRateLimitPacer() *RateLimitPacer {
+	return &RateLimitPacer{now: time.Now}
+}
+
+// Pause holds new turns until the given time. An earlier pause is only
+// ever extended.
+func (p *RateLimitPacer) Pause(until time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if until.After(p.resetAt) {
+		p.resetAt = until
+	}
+}
+
+// ResetAt returns when the pacer resumes, or the zero time if it is not
+// paused.
+func (p *RateLimitPacer) ResetAt() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.resetAt.After(p.now()) {
+		return time.Time{}
+	}
+	return p.resetAt
+}
+
+// Wait blocks until the pacer resumes or ctx is done. It is a no-op on a
+// nil pacer.
+func (p *RateLimitPacer) Wait(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	for {
+		resetAt := p.ResetAt()
+		if resetAt.IsZero() {
+			return nil
+		}
+
+		timer := time.NewTimer(resetAt.Sub(p.now()))
+		select {
+		case <-timer.C:
+			// Check again: the pause may have been extended
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// observe pauses the pacer for err. It is a no-op on a nil pacer.
+func (p *RateLimitPacer) observe(err *RateLimitError) {
+	if p == nil || err == nil {
+		return
+	}
+	until := err.ResetAt
+	if until.IsZero() {
+		until = p.now().Add(defaultRateLimitPause)
+	}
+	p.Pause(until)
+}
+
+// Patterns of the CLI's rate limit messages that tell when the limit
+// resets.
+var (
+	// usageLimitReset matches "Claude AI usage limit reached|<unix time>".
+	usageLimitReset = regexp.MustCompile(`(?i)usage limit reached\|(\d+)`)
+	// retryAfter matches a retry-after delay in seconds.
+	retryAfter = regexp.MustCompile(`(?i)retry[- ]after"?:?\s*"?(\d+)`)
+)
+
+// rateLimitTracker detects turns that end on a rate or usage limit.
+type rateLimitTracker struct {
+	mu  sync.Mutex
+	err *RateLimitError
+
+	now func() time.Time
+}
+
+func newRateLimitTracker() *rateLimitTracker {
+	return &rateLimitTracker{now: time.Now}
+}
+
+// observe records rate limit errors, which a later successful assistant
+// message clears.
+func (r *rateLimitTracker) observe(msg Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch m := msg.(type) {
+	case *AssistantMessage:
+		switch m.Error {
+		case "":
+			r.err = nil
+		case AssistantMessageErrorRateLimit:
+			var text string
+			for _, block := range m.Content {
+				if t, ok := block.(TextBlock); ok {
+					text += t.Text
+				}
+			}
+			r.err = NewRateLimitError(r.resetAt(text))
+		}
+	case *ResultMessage:
+		if m.IsError && usageLimitReset.MatchString(m.Result) {
+			r.err = NewRateLimitError(r.resetAt(m.Result))
+		}
+	}
+}
+
+// result returns the rate limit that ended the turn, if any, and starts
+// tracking the next turn.
+func (r *rateLimitTracker) result() *RateLimitError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.err
+	r.err = nil
+	return err
+}
+
+// resetAt extracts when a rate limit resets from the CLI's message about
+// it, or returns the zero time.
+func (r *rateLimitTracker) resetAt(text string) time.Time {
+	if m := usageLimitReset.FindStringSubmatch(text); m != nil {
+		if sec, err := strconv.ParseInt(m[1], 10, 64); err == nil {
+			return time.Unix(sec, 0)
+		}
+	}
+	if m := retryAfter.FindStringSubmatch(text); m != nil {
+		if sec, err := strconv.Atoi(m[1]); err == nil {
+			return r.now().Add(time.Duration(sec) * time.Second)
+		}
+	}
+	return time.Time{}
+}
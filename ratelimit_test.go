@@ -0,0 +1,190 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRateLimitPacer_Pause(t *testing.T) {
+	pacer := NewRateLimitPacer()
+	if !pacer.ResetAt().IsZero() {
+		t.Error("Expected a new pacer not to be paused")
+	}
+
+	later := time.Now().Add(time.Hour)
+	pacer.Pause(later)
+	pacer.Pause(later.Add(-time.Minute))
+	if !pacer.ResetAt().Equal(later) {
+		t.Errorf("Expected pause to only be extended, got %v", pacer.ResetAt())
+	}
+
+	pacer = NewRateLimitPacer()
+	pacer.Pause(time.Now().Add(-time.Second))
+	if !pacer.ResetAt().IsZero() {
+		t.Error("Expected a past pause to have no effect")
+	}
+}
+
+func TestRateLimitPacer_Wait(t *testing.T) {
+	var nilPacer *RateLimitPacer
+	if err := nilPacer.Wait(context.Background()); err != nil {
+		t.Errorf("Expected nil pacer not to wait, got %v", err)
+	}
+
+	pacer := NewRateLimitPacer()
+	pacer.Pause(time.Now().Add(50 * time.Millisecond))
+	start := time.Now()
+	if err := pacer.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected Wait to block until reset, returned after %v", elapsed)
+	}
+
+	pacer.Pause(time.Now().Add(time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := pacer.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context error, got %v", err)
+	}
+}
+
+func TestRateLimitPacer_ObserveUnknownReset(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	pacer := &RateLimitPacer{now: func() time.Time { return now }}
+
+	pacer.observe(nil)
+	if !pacer.ResetAt().IsZero() {
+		t.Error("Expected no pause without a rate limit")
+	}
+	pacer.observe(NewRateLimitError(time.Time{}))
+	if want := now.Add(defaultRateLimitPause); !pacer.ResetAt().Equal(want) {
+		t.Errorf("Expected default pause until %v, got %v", want, pacer.ResetAt())
+	}
+}
+
+func TestRateLimitTracker(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	limited := func(text string) *AssistantMessage {
+		return &AssistantMessage{Error: AssistantMessageErrorRateLimit, Content: []ContentBlock{TextBlock{Text: text}}}
+	}
+
+	tests := []struct {
+		name     string
+		messages []Message
+		resetAt  time.Time
+		limited  bool
+	}{
+		{"none", []Message{&AssistantMessage{Model: "m"}}, time.Time{}, false},
+		{"usage limit", []Message{limited("Claude AI usage limit reached|1735740000")}, time.Unix(1735740000, 0), true},
+		{"retry after", []Message{limited("API Error: 429 rate_limit_error, retry-after: 30")}, now.Add(30 * time.Second), true},
+		{"unknown reset", []Message{limited("API Error: 429")}, time.Time{}, true},
+		{"recovered", []Message{limited("API Error: 429"), &AssistantMessage{Model: "m"}}, time.Time{}, false},
+		{"result", []Message{&ResultMessage{IsError: true, Result: "Claude AI usage limit reached|1735740000"}}, time.Unix(1735740000, 0), true},
+		{"other error", []Message{&ResultMessage{IsError: true, Result: "boom"}}, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := &rateLimitTracker{now: func() time.Time { return now }}
+			for _, msg := range tt.messages {
+				tracker.observe(msg)
+			}
+			err := tracker.result()
+			if (err != nil) != tt.limited {
+				t.Fatalf("Expected limited=%v, got %v", tt.limited, err)
+			}
+			if err != nil && !err.ResetAt.Equal(tt.resetAt) {
+				t.Errorf("Expected ResetAt %v, got %v", tt.resetAt, err.ResetAt)
+			}
+			if tracker.result() != nil {
+				t.Error("Expected result to start a new turn")
+			}
+		})
+	}
+}
+
+func TestQuery_RateLimitPausesPacer(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Unix()
+	cli := writeStubCLI(t, fmt.Sprintf(`
+echo '{"type":"assistant","message":{"model":"m","content":[{"type":"text","text":"Claude AI usage limit reached|%d"}],"error":"rate_limit"}}'
+echo '{"type":"result","subtype":"success","is_error":true,"result":"Claude AI usage limit reached|%d"}'
+`, reset, reset))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pacer := NewRateLimitPacer()
+	messages, errs := Query(ctx, "Hello", WithCLIPath(cli), WithRateLimitPacer(pacer))
+	for range messages {
+	}
+
+	rateErr, ok := AsRateLimitError(<-errs)
+	if !ok {
+		t.Fatal("Expected RateLimitError")
+	}
+	if rateErr.ResetAt.Unix() != reset {
+		t.Errorf("Expected ResetAt %d, got %v", reset, rateErr.ResetAt)
+	}
+	if pacer.ResetAt().Unix() != reset {
+		t.Errorf("Expected pacer paused until %d, got %v", reset, pacer.ResetAt())
+	}
+
+	// The next query waits for the reset instead of running
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer waitCancel()
+	messages, errs = Query(waitCtx, "Again", WithCLIPath(cli), WithRateLimitPacer(pacer))
+	for range messages {
+	}
+	if err := <-errs; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the query to wait for the reset, got %v", err)
+	}
+}
+
+func TestClient_RateLimitError(t *testing.T) {
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+read line
+echo '{"type":"assistant","message":{"model":"m","content":[{"type":"text","text":"API Error: 429, retry-after: 120"}],"error":"rate_limit"}}'
+echo '{"type":"result","subtype":"success","is_error":true,"result":"API Error: 429"}'
+read line
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pacer := NewRateLimitPacer()
+	client := NewClient(WithCLIPath(cli), WithRateLimitPacer(pacer))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.Query(ctx, "Hello"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	for range client.ReceiveResponse(ctx) {
+	}
+
+	rateErr, ok := AsRateLimitError(<-client.Errors())
+	if !ok {
+		t.Fatal("Expected RateLimitError")
+	}
+	if until := time.Until(rateErr.ResetAt); until < time.Minute || until > 2*time.Minute {
+		t.Errorf("Expected reset in about 2 minutes, got %v", until)
+	}
+	if !pacer.ResetAt().Equal(rateErr.ResetAt) {
+		t.Errorf("Expected pacer paused until %v, got %v", rateErr.ResetAt, pacer.ResetAt())
+	}
+
+	waitCtx, waitCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer waitCancel()
+	if err := client.Query(waitCtx, "Again"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected Query to wait for the reset, got %v", err)
+	}
+}
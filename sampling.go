@@ -0,0 +1,55 @@
+package claude
+
+import (
+	"fmt"
+	"strings"
+)
+
+// samplingExclusiveModels lists the model families that accept Temperature
+// or TopP, but not both. Bare aliases resolve to such models too.
+var samplingExclusiveModels = []string{
+	"claude-opus-4-1",
+	"claude-opus-4-5",
+	"claude-sonnet-4-5",
+	"claude-haiku-4-5",
+	"opus",
+	"sonnet",
+	"haiku",
+}
+
+// validateSampling checks the sampling options against their ranges and
+// the constraints of the model and of extended thinking.
+func validateSampling(o *Options) error {
+	if o.Temperature != nil && (*o.Temperature < 0 || *o.Temperature > 1) {
+		return NewClaudeSDKError(fmt.Sprintf("temperature must be between 0 and 1, got %g", *o.Temperature))
+	}
+	if o.TopP != nil && (*o.TopP <= 0 || *o.TopP > 1) {
+		return NewClaudeSDKError(fmt.Sprintf("top_p must be greater than 0 and at most 1, got %g", *o.TopP))
+	}
+
+	if o.MaxThinkingTokens > 0 {
+		// Extended thinking only allows light nucleus sampling
+		if o.Temperature != nil && *o.Temperature != 1 {
+			return NewClaudeSDKError("temperature cannot be changed when extended thinking is enabled")
+		}
+		if o.TopP != nil && *o.TopP < 0.95 {
+			return NewClaudeSDKError("top_p must be at least 0.95 when extended thinking is enabled")
+		}
+	}
+
+	if o.Temperature != nil && o.TopP != nil && samplingExclusive(o.Model) {
+		return NewClaudeSDKError(fmt.Sprintf("model %s accepts temperature or top_p, not both", o.Model))
+	}
+	return nil
+}
+
+// samplingExclusive reports whether model accepts only one of Temperature
+// and TopP.
+func samplingExclusive(model string) bool {
+	for _, family := range samplingExclusiveModels {
+		if model == family || strings.HasPrefix(model, family+"-") || strings.HasPrefix(model, family+"[") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package claude
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestValidateSampling(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr string
+	}{
+		{"defaults", nil, ""},
+		{"deterministic", []Option{WithTemperature(0), WithSeed(7)}, ""},
+		{"temperature too high", []Option{WithTemperature(1.5)}, "temperature must be between 0 and 1"},
+		{"top_p zero", []Option{WithTopP(0)}, "top_p must be greater than 0"},
+		{"thinking with temperature", []Option{WithMaxThinkingTokens(1024), WithTemperature(0.2)}, "extended thinking"},
+		{"thinking with default temperature", []Option{WithMaxThinkingTokens(1024), WithTemperature(1), WithTopP(0.95)}, ""},
+		{"thinking with low top_p", []Option{WithMaxThinkingTokens(1024), WithTopP(0.5)}, "at least 0.95"},
+		{"both on older model", []Option{WithModel("claude-sonnet-4-20250514"), WithTemperature(0.2), WithTopP(0.9)}, ""},
+		{"both on exclusive model", []Option{WithModel("claude-sonnet-4-5-20250929"), WithTemperature(0.2), WithTopP(0.9)}, "not both"},
+		{"both on alias", []Option{WithModel("opus"), WithTemperature(0.2), WithTopP(0.9)}, "not both"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSampling(NewOptions(tt.opts...))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestQuery_InvalidSampling(t *testing.T) {
+	messages, errs := Query(context.Background(), "Hi", WithCLIPath("/nonexistent/claude"), WithTemperature(2))
+	for range messages {
+	}
+	if err := <-errs; err == nil || !strings.Contains(err.Error(), "temperature") {
+		t.Errorf("Expected temperature error before starting the CLI, got %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+package claude
+
+// SchemaBuilder creates JSON schemas for WithJSONSchema and MCP tool
+// inputs. Obtain one with Schema.
+type SchemaBuilder struct{}
+
+// Schema returns a builder for JSON schemas, replacing nested map literals
+// with a fluent API.
+//
+// Example:
+//
+//	s := claude.Schema()
+//	schema := s.Object().
+//		Prop("name", s.String().Desc("The person's full name")).
+//		Prop("skills", s.Array(s.String())).
+//		Required("name", "skills").
+//		Build()
+//	client := claude.NewClient(claude.WithJSONSchema(schema))
+func Schema() SchemaBuilder {
+	return SchemaBuilder{}
+}
+
+// Object returns a schema for a JSON object. Add properties with Prop.
+func (SchemaBuilder) Object() *JSONSchema {
+	return newJSONSchema("object")
+}
+
+// String returns a schema for a JSON string.
+func (SchemaBuilder) String() *JSONSchema {
+	return newJSONSchema("string")
+}
+
+// Integer returns a schema for a whole number.
+func (SchemaBuilder) Integer() *JSONSchema {
+	return newJSONSchema("integer")
+}
+
+// Number returns a schema for a JSON number.
+func (SchemaBuilder) Number() *JSONSchema {
+	return newJSONSchema("number")
+}
+
+// Boolean returns a schema for a JSON boolean.
+func (SchemaBuilder) Boolean() *JSONSchema {
+	return newJSONSchema("boolean")
+}
+
+// Array returns a schema for a JSON array whose items match items.
+func (SchemaBuilder) Array(items *JSONSchema) *JSONSchema {
+	return newJSONSchema("array").Items(items)
+}
+
+// JSONSchema is a JSON schema under construction. Its methods modify and
+// return the schema so calls can be chained; Build produces the map.
+type JSONSchema struct {
+	fields     map[string]any
+	properties map[string]*JSONSchema
+	required   []string
+	items      *JSONSchema
+}
+
+func newJSONSchema(typ string) *JSONSchema {
+	return &JSONSchema{fields: map[string]any{"type": typ}}
+}
+
+// set sets a schema keyword.
+func (s *JSONSchema) set(key string, value any) *JSONSchema {
+	s.fields[key] = value
+	return s
+}
+
+// Desc sets the description.
+func (s *JSONSchema) Desc(description string) *JSONSchema {
+	return s.set("description", description)
+}
+
+// Title sets the title.
+func (s *JSONSchema) Title(title string) *JSONSchema {
+	return s.set("title", title)
+}
+
+// Default sets the default value.
+func (s *JSONSchema) Default(value any) *JSONSchema {
+	return s.set("default", value)
+}
+
+// Enum restricts the value to the given values.
+func (s *JSONSchema) Enum(values ...any) *JSONSchema {
+	return s.set("enum", values)
+}
+
+// Nullable also allows null.
+func (s *JSONSchema) Nullable() *JSONSchema {
+	if typ, ok := s.fields["type"].(string); ok {
+		s.fields["type"] = []string{typ, "null"}
+	}
+	return s
+}
+
+// Format sets the string format, such as "date-time" or "email".
+func (s *JSONSchema) Format(format string) *JSONSchema {
+	return s.set("format", format)
+}
+
+// Pattern requires strings to match a regular expression.
+func (s *JSONSchema) Pattern(pattern string) *JSONSchema {
+	return s.set("pattern", pattern)
+}
+
+// MinLength sets the minimum string length.
+func (s *JSONSchema) MinLength(n int) *JSONSchema {
+	return s.set("minLength", n)
+}
+
+// MaxLength sets the maximum string length.
+func (s *JSONSchema) MaxLength(n int) *JSONSchema {
+	return s.set("maxLength", n)
+}
+
+// Minimum sets the inclusive minimum of a number.
+func (s *JSONSchema) Minimum(n float64) *JSONSchema {
+	return s.set("minimum", n)
+}
+
+// Maximum sets the inclusive maximum of a number.
+func (s *JSONSchema) Maximum(n float64) *JSONSchema {
+	return s.set("maximum", n)
+}
+
+// Items sets the schema of array items.
+func (s *JSONSchema) Items(items *JSONSchema) *JSONSchema {
+	s.items = items
+	return s
+}
+
+// MinItems sets the minimum array length.
+func (s *JSONSchema) MinItems(n int) *JSONSchema {
+	return s.set("minItems", n)
+}
+
+// MaxItems sets the maximum array length.
+func (s *JSONSchema) MaxItems(n int) *JSONSchema {
+	return s.set("maxItems", n)
+}
+
+// Prop adds an object property. Adding a property again replaces it.
+func (s *JSONSchema) Prop(name string, schema *JSONSchema) *JSONSchema {
+	if s.properties == nil {
+		s.properties = make(map[string]*JSONSchema)
+	}
+	s.properties[name] = schema
+	return s
+}
+
+// Required marks object properties as required.
+func (s *JSONSchema) Required(names ...string) *JSONSchema {
+	s.required = append(s.required, names...)
+	return s
+}
+
+// AdditionalProperties sets whether an object may have properties other
+// than those added with Prop.
+func (s *JSONSchema) AdditionalProperties(allowed bool) *JSONSchema {
+	return s.set("additionalProperties", allowed)
+}
+
+// Build returns the schema as the map accepted by WithJSONSchema and Tool.
+func (s *JSONSchema) Build() map[string]any {
+	schema := make(map[string]any, len(s.fields)+3)
+	for key, value := range s.fields {
+		schema[key] = value
+	}
+	if s.properties != nil {
+		properties := make(map[string]any, len(s.properties))
+		for name, prop := range s.properties {
+			properties[name] = prop.Build()
+		}
+		schema["properties"] = properties
+	}
+	if len(s.required) > 0 {
+		schema["required"] = append([]string(nil), s.required...)
+	}
+	if s.items != nil {
+		schema["items"] = s.items.Build()
+	}
+	return schema
+}
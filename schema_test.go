@@ -0,0 +1,65 @@
+package claude
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSchema_Build(t *testing.T) {
+	s := Schema()
+	got := s.Object().
+		Prop("name", s.String().Desc("Full name").MinLength(1)).
+		Prop("age", s.Integer().Minimum(0).Maximum(150)).
+		Prop("role", s.String().Enum("admin", "user").Default("user")).
+		Prop("skills", s.Array(s.String()).MinItems(1)).
+		Prop("nickname", s.String().Nullable()).
+		Required("name", "age").
+		AdditionalProperties(false).
+		Build()
+
+	want := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"name":     map[string]any{"type": "string", "description": "Full name", "minLength": 1},
+			"age":      map[string]any{"type": "integer", "minimum": 0.0, "maximum": 150.0},
+			"role":     map[string]any{"type": "string", "enum": []any{"admin", "user"}, "default": "user"},
+			"skills":   map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "minItems": 1},
+			"nickname": map[string]any{"type": []string{"string", "null"}},
+		},
+		"required":             []string{"name", "age"},
+		"additionalProperties": false,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %#v, got %#v", want, got)
+	}
+}
+
+func TestSchema_BuildIsIndependent(t *testing.T) {
+	s := Schema()
+	builder := s.Object().Prop("a", s.Number())
+	first := builder.Build()
+
+	builder.Prop("b", s.Boolean()).Required("b")
+	if props := first["properties"].(map[string]any); len(props) != 1 {
+		t.Errorf("Expected earlier build to be unchanged, got %v", props)
+	}
+	if _, ok := first["required"]; ok {
+		t.Error("Expected earlier build to have no required properties")
+	}
+}
+
+func TestSchema_Validates(t *testing.T) {
+	s := Schema()
+	schema := s.Object().
+		Prop("name", s.String()).
+		Prop("tags", s.Array(s.String()).MaxItems(2)).
+		Required("name").
+		Build()
+
+	if errs := ValidateJSONSchema(schema, map[string]any{"name": "x", "tags": []any{"a"}}); errs != nil {
+		t.Errorf("Expected valid value, got %v", errs)
+	}
+	if errs := ValidateJSONSchema(schema, map[string]any{"tags": []any{"a", "b", "c"}}); len(errs) != 2 {
+		t.Errorf("Expected 2 violations, got %v", errs)
+	}
+}
@@ -0,0 +1,239 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SessionMetadata is the title and annotations recorded for a session with
+// Client.SetSessionTitle and Client.Annotate.
+//
+// The CLI has no place for such metadata, so the SDK keeps it in a sidecar
+// file per session, under the directory set with WithSessionMetadataDir.
+type SessionMetadata struct {
+	SessionID   string            `json:"session_id"`
+	Title       string            `json:"title,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// sessionMetadataMu serializes updates to metadata files within a process.
+var sessionMetadataMu sync.Mutex
+
+// SetSessionTitle sets the title of the client's session, so it can be
+// identified later with ListSessionMetadata.
+//
+// If the session ID is not known yet because no message has been received,
+// the title is recorded once it is.
+func (c *Client) SetSessionTitle(ctx context.Context, title string) error {
+	return c.updateSessionMetadata(ctx, func(m *SessionMetadata) {
+		m.Title = title
+	})
+}
+
+// Annotate records a key-value annotation on the client's session, such
+// as a job ID or the tenant it ran for. Annotating an existing key replaces
+// its value; an empty value removes it.
+//
+// If the session ID is not known yet because no message has been received,
+// the annotation is recorded once it is.
+func (c *Client) Annotate(ctx context.Context, key, value string) error {
+	if key == "" {
+		return NewClaudeSDKError("annotation key must not be empty")
+	}
+	return c.updateSessionMetadata(ctx, func(m *SessionMetadata) {
+		if value == "" {
+			delete(m.Annotations, key)
+			return
+		}
+		if m.Annotations == nil {
+			m.Annotations = make(map[string]string)
+		}
+		m.Annotations[key] = value
+	})
+}
+
+// updateSessionMetadata applies update to the session's metadata, or queues
+// it until the session ID is known.
+func (c *Client) updateSessionMetadata(ctx context.Context, update func(*SessionMetadata)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.turnMu.Lock()
+	sessionID := c.lastSessionID
+	if sessionID == "" {
+		sessionID = c.options.Resume
+	}
+	if sessionID == "" {
+		c.pendingMetadata = append(c.pendingMetadata, update)
+		c.turnMu.Unlock()
+		return nil
+	}
+	c.turnMu.Unlock()
+
+	return writeSessionMetadata(c.options, sessionID, update)
+}
+
+// flushSessionMetadata applies queued metadata updates once the session ID
+// is known.
+func (c *Client) flushSessionMetadata() error {
+	c.turnMu.Lock()
+	sessionID := c.lastSessionID
+	pending := c.pendingMetadata
+	if sessionID == "" || len(pending) == 0 {
+		c.turnMu.Unlock()
+		return nil
+	}
+	c.pendingMetadata = nil
+	c.turnMu.Unlock()
+
+	return writeSessionMetadata(c.options, sessionID, pending...)
+}
+
+// writeSessionMetadata applies updates to the metadata file of sessionID.
+func writeSessionMetadata(options *Options, sessionID string, updates ...func(*SessionMetadata)) error {
+	path, err := sessionMetadataPath(options, sessionID)
+	if err != nil {
+		return err
+	}
+
+	sessionMetadataMu.Lock()
+	defer sessionMetadataMu.Unlock()
+
+	metadata, err := readSessionMetadata(path)
+	if err != nil {
+		return err
+	}
+	if metadata == nil {
+		metadata = &SessionMetadata{SessionID: sessionID}
+	}
+	for _, update := range updates {
+		update(metadata)
+	}
+	metadata.UpdatedAt = time.Now()
+
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return WrapClaudeSDKError("failed to create session metadata directory", err)
+	}
+
+	// Replace the file atomically so readers never see a partial write
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return WrapClaudeSDKError("failed to write session metadata", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return WrapClaudeSDKError("failed to write session metadata", err)
+	}
+	return nil
+}
+
+// LoadSessionMetadata returns the metadata recorded for a session. A
+// session without metadata has an empty title and no annotations. Pass the
+// same WithSessionMetadataDir option used to record it, if any.
+func LoadSessionMetadata(sessionID string, opts ...Option) (*SessionMetadata, error) {
+	path, err := sessionMetadataPath(NewOptions(opts...), sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := readSessionMetadata(path)
+	if err != nil {
+		return nil, err
+	}
+	if metadata == nil {
+		metadata = &SessionMetadata{SessionID: sessionID}
+	}
+	return metadata, nil
+}
+
+// ListSessionMetadata returns the metadata of all sessions that have any,
+// most recently updated first.
+func ListSessionMetadata(opts ...Option) ([]SessionMetadata, error) {
+	root, err := sessionMetadataRoot(NewOptions(opts...))
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, WrapClaudeSDKError("failed to list session metadata", err)
+	}
+
+	var sessions []SessionMetadata
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		metadata, err := readSessionMetadata(filepath.Join(root, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if metadata != nil {
+			sessions = append(sessions, *metadata)
+		}
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
+
+// readSessionMetadata reads a metadata file, returning nil if it does not
+// exist.
+func readSessionMetadata(path string) (*SessionMetadata, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, WrapClaudeSDKError("failed to read session metadata", err)
+	}
+
+	var metadata SessionMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, NewJSONDecodeError(string(data), err)
+	}
+	return &metadata, nil
+}
+
+// sessionMetadataPath returns the metadata file of sessionID.
+func sessionMetadataPath(options *Options, sessionID string) (string, error) {
+	if sessionID == "" || strings.ContainsAny(sessionID, `/\`) || sessionID == "." || sessionID == ".." {
+		return "", NewClaudeSDKError(fmt.Sprintf("invalid session ID: %q", sessionID))
+	}
+	root, err := sessionMetadataRoot(options)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, sessionID+".json"), nil
+}
+
+// sessionMetadataRoot returns the directory holding session metadata.
+func sessionMetadataRoot(options *Options) (string, error) {
+	if options.SessionMetadataDir != "" {
+		return options.SessionMetadataDir, nil
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", WrapClaudeSDKError("failed to locate user cache directory", err)
+	}
+	return filepath.Join(cache, "claude-agent-sdk-go", "metadata"), nil
+}
@@ -0,0 +1,131 @@
+package claude
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClient_SessionMetadata(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	client := NewClient(WithSessionMetadataDir(dir))
+
+	// Updates before the session ID is known are queued
+	if err := client.SetSessionTitle(ctx, "Nightly triage"); err != nil {
+		t.Fatalf("SetSessionTitle failed: %v", err)
+	}
+	if err := client.Annotate(ctx, "job", "42"); err != nil {
+		t.Fatalf("Annotate failed: %v", err)
+	}
+	if sessions, _ := ListSessionMetadata(WithSessionMetadataDir(dir)); len(sessions) != 0 {
+		t.Fatalf("Expected nothing recorded yet, got %+v", sessions)
+	}
+
+	client.trackPosition(&ResultMessage{SessionID: "sess-1"})
+	if err := client.flushSessionMetadata(); err != nil {
+		t.Fatalf("flushSessionMetadata failed: %v", err)
+	}
+
+	if err := client.Annotate(ctx, "tenant", "acme"); err != nil {
+		t.Fatalf("Annotate failed: %v", err)
+	}
+	if err := client.Annotate(ctx, "job", ""); err != nil {
+		t.Fatalf("Annotate failed: %v", err)
+	}
+
+	metadata, err := LoadSessionMetadata("sess-1", WithSessionMetadataDir(dir))
+	if err != nil {
+		t.Fatalf("LoadSessionMetadata failed: %v", err)
+	}
+	if metadata.Title != "Nightly triage" {
+		t.Errorf("Expected title, got %q", metadata.Title)
+	}
+	if want := map[string]string{"tenant": "acme"}; !reflect.DeepEqual(metadata.Annotations, want) {
+		t.Errorf("Expected annotations %v, got %v", want, metadata.Annotations)
+	}
+
+	if err := client.Annotate(ctx, "", "x"); err == nil {
+		t.Error("Expected error for an empty annotation key")
+	}
+}
+
+func TestLoadSessionMetadata_Missing(t *testing.T) {
+	dir := t.TempDir()
+
+	metadata, err := LoadSessionMetadata("unknown", WithSessionMetadataDir(dir))
+	if err != nil {
+		t.Fatalf("LoadSessionMetadata failed: %v", err)
+	}
+	if metadata.SessionID != "unknown" || metadata.Title != "" || metadata.Annotations != nil {
+		t.Errorf("Expected empty metadata, got %+v", metadata)
+	}
+
+	if _, err := LoadSessionMetadata("../escape", WithSessionMetadataDir(dir)); err == nil {
+		t.Error("Expected error for an invalid session ID")
+	}
+}
+
+func TestListSessionMetadata(t *testing.T) {
+	dir := t.TempDir()
+	options := NewOptions(WithSessionMetadataDir(dir))
+
+	for _, id := range []string{"old", "new"} {
+		title := id + " run"
+		if err := writeSessionMetadata(options, id, func(m *SessionMetadata) { m.Title = title }); err != nil {
+			t.Fatalf("writeSessionMetadata failed: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	sessions, err := ListSessionMetadata(WithSessionMetadataDir(dir))
+	if err != nil {
+		t.Fatalf("ListSessionMetadata failed: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].SessionID != "new" || sessions[1].Title != "old run" {
+		t.Errorf("Expected newest session first, got %+v", sessions)
+	}
+
+	if sessions, err := ListSessionMetadata(WithSessionMetadataDir(dir + "/missing")); err != nil || sessions != nil {
+		t.Errorf("Expected no sessions for a missing directory, got %v, %v", sessions, err)
+	}
+}
+
+func TestClient_SessionMetadata_RecordedOnFirstMessage(t *testing.T) {
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+read line
+echo '{"type":"result","subtype":"success","session_id":"sess-9"}'
+cat > /dev/null
+`)
+	dir := t.TempDir()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli), WithSessionMetadataDir(dir))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.SetSessionTitle(ctx, "Deploy check"); err != nil {
+		t.Fatalf("SetSessionTitle failed: %v", err)
+	}
+	if err := client.Query(ctx, "Check the deploy"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	for range client.ReceiveResponse(ctx) {
+	}
+
+	metadata, err := LoadSessionMetadata("sess-9", WithSessionMetadataDir(dir))
+	if err != nil {
+		t.Fatalf("LoadSessionMetadata failed: %v", err)
+	}
+	if metadata.Title != "Deploy check" {
+		t.Errorf("Expected title recorded for the session, got %+v", metadata)
+	}
+}
@@ -0,0 +1,272 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ShellServerName is the name of the server returned by ShellServer. Its
+// tool is "mcp__shell__run", see MCPToolRef.
+const ShellServerName = "shell"
+
+// DefaultShellTimeout and DefaultShellMaxOutput are the limits of a
+// ShellPolicy that sets none.
+const (
+	DefaultShellTimeout   = 2 * time.Minute
+	DefaultShellMaxOutput = 30_000
+)
+
+// ShellPolicy governs the commands run by ShellServer.
+type ShellPolicy struct {
+	// Allow lists the commands that may run. An entry of several words,
+	// such as "git status", matches a command starting with those words.
+	// Programs match by name exactly: "git" does not allow "/tmp/git". A
+	// command matching no entry is refused.
+	Allow []string
+
+	// Dir is the directory commands run in, and the directory that
+	// holds any other directory a call asks to run in. Defaults to the
+	// working directory of the process.
+	Dir string
+
+	// Timeout limits each command, which is killed when it runs out.
+	// Defaults to DefaultShellTimeout.
+	Timeout time.Duration
+
+	// MaxOutput caps the output of a command returned to Claude, in
+	// bytes of stdout and stderr together. Only the end of a longer
+	// output is returned. Defaults to DefaultShellMaxOutput.
+	MaxOutput int
+
+	// Env is added to the environment of the process for commands.
+	Env map[string]string
+}
+
+// timeout returns the timeout of a command.
+func (p ShellPolicy) timeout() time.Duration {
+	if p.Timeout > 0 {
+		return p.Timeout
+	}
+	return DefaultShellTimeout
+}
+
+// maxOutput returns the output cap of a command.
+func (p ShellPolicy) maxOutput() int {
+	if p.MaxOutput > 0 {
+		return p.MaxOutput
+	}
+	return DefaultShellMaxOutput
+}
+
+// allows reports whether the policy allows the command of words.
+func (p ShellPolicy) allows(words []string) bool {
+	for _, entry := range p.Allow {
+		fields := strings.Fields(entry)
+		if len(fields) > 0 && len(fields) <= len(words) && slices.Equal(words[:len(fields)], fields) {
+			return true
+		}
+	}
+	return false
+}
+
+// ShellServer returns an in-process MCP server with a tool, run, that
+// runs commands under policy, for deployments that replace the CLI's Bash
+// tool with one governed in Go.
+//
+// Commands are run directly, not by a shell: a command line is split into
+// words, with single and double quotes and backslash escapes, and is
+// refused if it uses pipes, lists, redirections, substitutions or
+// variables, or if policy does not allow it. A call may name a directory
+// to run in, within policy.Dir. The result holds the output and exit
+// status, and is an error result if the command fails or times out.
+//
+// The policy checks the command, not its effects: an allowed program can
+// still read or change files outside policy.Dir if its arguments say so.
+//
+// Example:
+//
+//	client := claude.NewClient(
+//	    claude.WithMCPServers(map[string]claude.MCPServerConfig{
+//	        claude.ShellServerName: claude.ShellServer(claude.ShellPolicy{
+//	            Allow:   []string{"go test", "go vet", "git status", "git diff"},
+//	            Dir:     "/srv/checkout",
+//	            Timeout: 5 * time.Minute,
+//	        }),
+//	    }),
+//	    claude.WithDisallowedTools([]string{"Bash"}),
+//	    claude.WithMCPServerAllowed(claude.ShellServerName),
+//	)
+func ShellServer(policy ShellPolicy) MCPSDKServerConfig {
+	description := fmt.Sprintf("Run a command, without a shell: pipes, redirections, lists and variables are not supported. "+
+		"Allowed commands: %s. Commands time out after %s; only the last %d bytes of their output are returned.",
+		strings.Join(policy.Allow, ", "), policy.timeout(), policy.maxOutput())
+	server := NewMCPServer(ShellServerName, "1.0.0", []MCPTool{
+		Tool("run", description, objectSchema(map[string]any{
+			"command": stringProperty("The command line"),
+			"cwd":     stringProperty("Directory to run in, relative to the working directory; the working directory if empty"),
+		}, "command"), policy.run),
+	})
+	return MCPSDKServerConfig{
+		Type:   "sdk",
+		Name:   ShellServerName,
+		Server: server.ValidateInputs(true),
+	}
+}
+
+// run is the handler of the run tool.
+func (p ShellPolicy) run(ctx context.Context, args map[string]any) (MCPToolResult, error) {
+	line, _ := args["command"].(string)
+	words, err := commandLineWords(line)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("command refused: %v", err)), nil
+	}
+	if len(words) == 0 {
+		return ErrorResult("command refused: empty command"), nil
+	}
+	if !p.allows(words) {
+		return ErrorResult(fmt.Sprintf("command refused: %q is not allowed; allowed commands: %s", line, strings.Join(p.Allow, ", "))), nil
+	}
+	cwd, _ := args["cwd"].(string)
+	dir, err := p.dir(cwd)
+	if err != nil {
+		return ErrorResult(err.Error()), nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout())
+	defer cancel()
+	cmd := exec.CommandContext(ctx, words[0], words[1:]...)
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	for k, v := range p.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	output := &tailBuffer{limit: p.maxOutput()}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	// Do not wait on children of the command holding its output open
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	exitCode := cmd.ProcessState.ExitCode()
+	timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+
+	var text strings.Builder
+	kept, size := output.String(), output.written()
+	if size > len(kept) {
+		fmt.Fprintf(&text, "[first %d of %d bytes of output omitted]\n", size-len(kept), size)
+	}
+	text.WriteString(kept)
+	var exitErr *exec.ExitError
+	switch {
+	case timedOut:
+		fmt.Fprintf(&text, "\n[command timed out after %s]", p.timeout())
+	case err != nil && !errors.As(err, &exitErr) && !errors.Is(err, exec.ErrWaitDelay):
+		return ErrorResult(fmt.Sprintf("command failed to run: %v", err)), nil
+	case exitCode != 0:
+		fmt.Fprintf(&text, "\n[exit status %d]", exitCode)
+	}
+	return MCPToolResult{
+		Content: []MCPContent{{Type: "text", Text: text.String()}},
+		IsError: timedOut || exitCode != 0,
+		Structured: map[string]any{
+			"exit_code": exitCode,
+			"timed_out": timedOut,
+			"truncated": size > len(kept),
+		},
+	}, nil
+}
+
+// dir returns the directory to run a command in: cwd, resolved against
+// the directory of the policy, which it must be within.
+func (p ShellPolicy) dir(cwd string) (string, error) {
+	base := p.Dir
+	if base == "" {
+		var err error
+		if base, err = os.Getwd(); err != nil {
+			return "", err
+		}
+	}
+	base, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+	base = resolveWorkspacePath(base, "")
+	if cwd == "" {
+		return base, nil
+	}
+	dir := resolveWorkspacePath(cwd, base)
+	if !withinDir(dir, base) {
+		return "", fmt.Errorf("directory %s is outside the working directory", cwd)
+	}
+	return dir, nil
+}
+
+// commandLineWords splits a command line into words as a shell would,
+// with single and double quotes and backslash escapes, refusing the
+// syntax that needs a shell: pipes, lists, redirections, substitutions,
+// variables, globs and home directories.
+func commandLineWords(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			// In double quotes, a backslash escapes only what is special
+			// there
+			if quote == '"' && !strings.ContainsRune("$`\"\\", r) {
+				word.WriteRune('\\')
+			}
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case quote == '"':
+			switch r {
+			case '"':
+				quote = 0
+			case '\\':
+				escaped = true
+			case '$', '`':
+				return nil, fmt.Errorf("%q substitutions are not supported", r)
+			default:
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == '\\':
+			escaped, inWord = true, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case strings.ContainsRune("|&;<>()$`\n*?[", r), r == '~' && !inWord:
+			return nil, fmt.Errorf("%q needs a shell, which is not supported", r)
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or escape")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,117 @@
+package claude
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandLineWords(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"go test ./...", []string{"go", "test", "./..."}},
+		{`git commit -m "fix: the \"bug\""`, []string{"git", "commit", "-m", `fix: the "bug"`}},
+		{`grep -n 'a|b' "C:\dir"`, []string{"grep", "-n", "a|b", `C:\dir`}},
+		{`echo a\ b ""`, []string{"echo", "a b", ""}},
+		{"git diff HEAD~1", []string{"git", "diff", "HEAD~1"}},
+		{"  ", nil},
+	}
+	for _, tt := range tests {
+		got, err := commandLineWords(tt.line)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("commandLineWords(%q) = %q, %v, want %q", tt.line, got, err, tt.want)
+		}
+	}
+
+	for _, line := range []string{
+		"ls | wc -l", "make && rm -rf /", "echo hi > out", "echo $HOME", `echo "$(id)"`,
+		"echo `id`", "rm *.go", "cat ~/.ssh/id_rsa", "echo 'open", `echo \`,
+	} {
+		if words, err := commandLineWords(line); err == nil {
+			t.Errorf("Expected %q refused, got %q", line, words)
+		}
+	}
+}
+
+// runShell calls the run tool of a ShellServer with policy.
+func runShell(t *testing.T, policy ShellPolicy, args map[string]any) MCPToolResult {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires POSIX commands")
+	}
+	result, err := ShellServer(policy).Server.Tools()[0].Handler(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestShellServer_Allow(t *testing.T) {
+	dir := t.TempDir()
+	policy := ShellPolicy{Allow: []string{"echo", "ls -a"}, Dir: dir}
+
+	if result := runShell(t, policy, map[string]any{"command": "echo hello 'big world'"}); result.IsError || result.Content[0].Text != "hello big world\n" {
+		t.Errorf("Expected the command run, got %+v", result)
+	}
+	for _, command := range []string{"ls", "ls -l", "/bin/echo hi", "rm -rf x"} {
+		result := runShell(t, policy, map[string]any{"command": command})
+		if !result.IsError || !strings.Contains(result.Content[0].Text, "not allowed") {
+			t.Errorf("Expected %q refused, got %+v", command, result)
+		}
+	}
+	if result := runShell(t, policy, map[string]any{"command": "echo hi; id"}); !result.IsError {
+		t.Errorf("Expected a list refused, got %+v", result)
+	}
+}
+
+func TestShellServer_Dir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	policy := ShellPolicy{Allow: []string{"pwd"}, Dir: dir}
+
+	resolved, _ := filepath.EvalSymlinks(dir)
+	if result := runShell(t, policy, map[string]any{"command": "pwd"}); strings.TrimSpace(result.Content[0].Text) != resolved {
+		t.Errorf("Expected the command run in the policy directory, got %+v", result)
+	}
+	if result := runShell(t, policy, map[string]any{"command": "pwd", "cwd": "sub"}); strings.TrimSpace(result.Content[0].Text) != filepath.Join(resolved, "sub") {
+		t.Errorf("Expected the command run in a subdirectory, got %+v", result)
+	}
+	for _, cwd := range []string{"..", "/", "sub/../.."} {
+		if result := runShell(t, policy, map[string]any{"command": "pwd", "cwd": cwd}); !result.IsError {
+			t.Errorf("Expected %s refused, got %+v", cwd, result)
+		}
+	}
+}
+
+func TestShellServer_Limits(t *testing.T) {
+	policy := ShellPolicy{Allow: []string{"seq", "sleep", "false"}, Dir: t.TempDir(), Timeout: 200 * time.Millisecond, MaxOutput: 20}
+
+	result := runShell(t, policy, map[string]any{"command": "seq 1 100"})
+	text := result.Content[0].Text
+	if result.IsError || !strings.HasSuffix(text, "\n99\n100\n") || !strings.HasPrefix(text, "[first 272 of 292 bytes of output omitted]") {
+		t.Errorf("Expected the end of the output kept, got %q", text)
+	}
+	if structured := result.Structured.(map[string]any); structured["truncated"] != true {
+		t.Errorf("Expected the output reported truncated, got %+v", structured)
+	}
+
+	start := time.Now()
+	result = runShell(t, policy, map[string]any{"command": "sleep 10"})
+	if !result.IsError || !strings.Contains(result.Content[0].Text, "timed out") || time.Since(start) > 5*time.Second {
+		t.Errorf("Expected the command killed on timeout, got %+v", result)
+	}
+
+	result = runShell(t, policy, map[string]any{"command": "false"})
+	if !result.IsError || !strings.Contains(result.Content[0].Text, "[exit status 1]") || result.Structured.(map[string]any)["exit_code"] != 1 {
+		t.Errorf("Expected the exit status reported, got %+v", result)
+	}
+}
@@ -0,0 +1,122 @@
+package claude
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/transport"
+)
+
+// DefaultCloseGrace is how long CloseContext waits for the CLI to exit
+// after each of SIGINT and SIGTERM, unless set with WithCloseGrace.
+const DefaultCloseGrace = 2 * time.Second
+
+// CloseStage tells how the CLI process ended on CloseContext.
+type CloseStage string
+
+const (
+	// CloseStageNone is reported when the client was not connected.
+	CloseStageNone CloseStage = ""
+	// CloseStageExited is reported when the process had already exited.
+	CloseStageExited CloseStage = "exited"
+	// CloseStageInterrupt is reported when the process exited on SIGINT.
+	CloseStageInterrupt CloseStage = "interrupt"
+	// CloseStageTerminate is reported when the process exited on SIGTERM.
+	CloseStageTerminate CloseStage = "terminate"
+	// CloseStageKill is reported when the process had to be killed.
+	CloseStageKill CloseStage = "kill"
+)
+
+// CloseContext disconnects like Close, but lets the CLI shut down
+// cleanly: its input is closed and it is sent SIGINT, then SIGTERM, each
+// followed by a grace period set with WithCloseGrace, and it is killed if
+// still running after that. Once ctx is done, the process is killed
+// without waiting further, so CloseContext returns shortly after ctx is
+// done. The sessions started with NewSession are closed with Close.
+//
+// It returns the stage at which the process ended, or CloseStageNone if
+// the client was not connected. The error is ctx.Err() if ctx was done
+// before the process exited and it was killed, or the error closing the
+// transport or killing the process.
+//
+// Example:
+//
+//	stage, err := client.CloseContext(ctx)
+//	if stage == claude.CloseStageKill {
+//	    log.Println("claude did not exit in time and was killed")
+//	}
+func (c *Client) CloseContext(ctx context.Context) (CloseStage, error) {
+	stage := CloseStageKill
+	var err error
+	connected := c.close(func(t transport.Transport) error {
+		subprocess, ok := t.(*transport.SubprocessTransport)
+		if !ok {
+			err = t.Close()
+			return err
+		}
+		steps, stages := c.closeSteps()
+		var i int
+		i, err = subprocess.Shutdown(ctx, steps)
+		switch {
+		case i < 0:
+			stage = CloseStageExited
+		case i < len(stages):
+			stage = stages[i]
+		case err == nil && ctx.Err() != nil:
+			err = ctx.Err()
+		}
+		return err
+	})
+	if !connected {
+		return CloseStageNone, nil
+	}
+	return stage, err
+}
+
+// CloseWithTimeout disconnects like CloseContext, killing the CLI if it
+// has not exited within timeout, in which case it returns
+// context.DeadlineExceeded.
+func (c *Client) CloseWithTimeout(timeout time.Duration) (CloseStage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return c.CloseContext(ctx)
+}
+
+// Detach disconnects like Close, but leaves the CLI running if it talks
+// over named pipes, see WithNamedPipes, so that a Client can reconnect to
+// it later, as after this process restarts. Without named pipes, it is
+// Close.
+func (c *Client) Detach() error {
+	var err error
+	c.close(func(t transport.Transport) error {
+		subprocess, ok := t.(*transport.SubprocessTransport)
+		if !ok {
+			return t.Close()
+		}
+		err = subprocess.Detach()
+		return err
+	})
+	return err
+}
+
+// closeSteps returns the steps ending the CLI on CloseContext, and the
+// stage each stands for. A step with a negative grace period is skipped.
+func (c *Client) closeSteps() ([]transport.StopStep, []CloseStage) {
+	var steps []transport.StopStep
+	var stages []CloseStage
+	add := func(signal os.Signal, grace time.Duration, stage CloseStage) {
+		if grace < 0 {
+			return
+		}
+		if grace == 0 {
+			grace = DefaultCloseGrace
+		}
+		steps = append(steps, transport.StopStep{Signal: signal, Grace: grace})
+		stages = append(stages, stage)
+	}
+	add(os.Interrupt, c.options.CloseInterruptGrace, CloseStageInterrupt)
+	add(syscall.SIGTERM, c.options.CloseTerminateGrace, CloseStageTerminate)
+	return steps, stages
+}
@@ -0,0 +1,132 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// writeTrapCLI writes a stub CLI that answers the initialize request and
+// then runs until a signal it traps with traps.
+func writeTrapCLI(t *testing.T, traps string) string {
+	t.Helper()
+	return writeStubCLI(t, traps+`
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+while true; do sleep 0.05; done
+`)
+}
+
+func TestClient_CloseContext(t *testing.T) {
+	tests := []struct {
+		name  string
+		traps string
+		opts  []Option
+		want  CloseStage
+	}{
+		{"interrupt", `trap 'exit 0' INT`, nil, CloseStageInterrupt},
+		{"terminate", `trap '' INT; trap 'exit 0' TERM`, []Option{WithCloseGrace(200*time.Millisecond, 0)}, CloseStageTerminate},
+		{"kill", `trap '' INT TERM`, []Option{WithCloseGrace(100*time.Millisecond, 100*time.Millisecond)}, CloseStageKill},
+		{"interrupt skipped", `trap 'exit 0' INT TERM`, []Option{WithCloseGrace(-1, 0)}, CloseStageTerminate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			client := NewClient(append([]Option{WithCLIPath(writeTrapCLI(t, tt.traps))}, tt.opts...)...)
+			if err := client.Connect(ctx); err != nil {
+				t.Fatalf("Connect failed: %v", err)
+			}
+			stage, err := client.CloseContext(ctx)
+			if err != nil || stage != tt.want {
+				t.Errorf("Expected stage %q, got %q, %v", tt.want, stage, err)
+			}
+		})
+	}
+
+	stage, err := NewClient().CloseContext(context.Background())
+	if err != nil || stage != CloseStageNone {
+		t.Errorf("Expected no stage when not connected, got %q, %v", stage, err)
+	}
+}
+
+func TestClient_CloseWithTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The default grace periods would take 4s; the timeout cuts them short
+	client := NewClient(WithCLIPath(writeTrapCLI(t, `trap '' INT TERM`)))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	start := time.Now()
+	stage, err := client.CloseWithTimeout(200 * time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) || stage != CloseStageKill {
+		t.Errorf("Expected the CLI killed at the deadline, got %q, %v", stage, err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected CloseWithTimeout to return at its timeout, took %s", elapsed)
+	}
+}
+
+func TestClient_Detach(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// A stub CLI that answers initialize, then each prompt with a result
+	// counting the prompts it has answered
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{"output_style":"stub"}}}'
+n=0
+while read -r line; do
+  case "$line" in
+    *'"type":"user"'*)
+      n=$((n+1))
+      echo '{"type":"result","subtype":"success","duration_ms":1,"duration_api_ms":1,"is_error":false,"num_turns":1,"session_id":"s","result":"'$n'"}' ;;
+  esac
+done
+`)
+	dir := t.TempDir()
+	ask := func(client *Client) string {
+		t.Helper()
+		if err := client.Query(ctx, "Count"); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		for msg := range client.ReceiveResponse(ctx) {
+			if result, ok := msg.(*ResultMessage); ok {
+				return result.Result
+			}
+		}
+		t.Fatal("Expected a result")
+		return ""
+	}
+
+	client := NewClient(WithCLIPath(cli), WithNamedPipes(dir))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if got := ask(client); got != "1" {
+		t.Errorf("Expected the first prompt answered, got %q", got)
+	}
+	if err := client.Detach(); err != nil {
+		t.Fatalf("Detach failed: %v", err)
+	}
+
+	// Another client picks up the same CLI process
+	client = NewClient(WithCLIPath(cli), WithNamedPipes(dir))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Reconnecting failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	if got := client.GetServerInfo()["output_style"]; got != "stub" {
+		t.Errorf("Expected the server info of the first connection, got %v", got)
+	}
+	if got := ask(client); got != "2" {
+		t.Errorf("Expected the same process to answer, got %q", got)
+	}
+}
@@ -0,0 +1,132 @@
+package claude
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// stallWatchdog tracks message activity during a turn and reports when the
+// turn has gone quiet for longer than the timeout. A nil watchdog is valid
+// and never reports a stall.
+type stallWatchdog struct {
+	timeout time.Duration
+	action  StallAction
+	locale  string
+
+	mu       sync.Mutex
+	active   bool
+	last     time.Time
+	reported time.Time
+}
+
+// newStallWatchdog returns a watchdog for the options, or nil if stall
+// detection is disabled.
+func newStallWatchdog(opts *Options) *stallWatchdog {
+	if opts.StallTimeout <= 0 {
+		return nil
+	}
+	action := opts.StallAction
+	if !action.valid() {
+		action = StallActionDiagnostic
+	}
+	return &stallWatchdog{timeout: opts.StallTimeout, action: action, locale: opts.Locale}
+}
+
+// interval returns how often the watchdog should be checked.
+func (w *stallWatchdog) interval() time.Duration {
+	return max(w.timeout/4, time.Millisecond)
+}
+
+// ticker returns a ticker channel for the watchdog and a stop function.
+// For a nil watchdog the channel is nil and never fires.
+func (w *stallWatchdog) ticker() (<-chan time.Time, func()) {
+	if w == nil {
+		return nil, func() {}
+	}
+	t := time.NewTicker(w.interval())
+	return t.C, t.Stop
+}
+
+// begin marks the start of a turn.
+func (w *stallWatchdog) begin() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.active = true
+	w.last = time.Now()
+	w.reported = time.Time{}
+}
+
+// touch records that a message arrived.
+func (w *stallWatchdog) touch() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.last = time.Now()
+}
+
+// end marks the end of a turn.
+func (w *stallWatchdog) end() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.active = false
+}
+
+// check returns a DiagnosticEvent if the turn has stalled as of now.
+// While the turn stays stalled, an event is returned at most once per timeout.
+func (w *stallWatchdog) check(now time.Time) *DiagnosticEvent {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.active {
+		return nil
+	}
+	since := w.last
+	if w.reported.After(since) {
+		since = w.reported
+	}
+	if now.Sub(since) < w.timeout {
+		return nil
+	}
+	w.reported = now
+
+	idle := now.Sub(w.last)
+	return &DiagnosticEvent{
+		Kind:    DiagnosticKindStall,
+		Message: localize(w.locale, MessageStall, idle.Round(time.Millisecond)),
+		Idle:    idle,
+		Action:  w.action,
+	}
+}
+
+// watchInput forwards messages from in, marking the start of a turn for
+// each one. For a nil watchdog in is returned unchanged.
+func (w *stallWatchdog) watchInput(ctx context.Context, in <-chan map[string]any) <-chan map[string]any {
+	if w == nil {
+		return in
+	}
+	out := make(chan map[string]any)
+	go func() {
+		defer close(out)
+		for msg := range in {
+			w.begin()
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,162 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewStallWatchdog_Disabled(t *testing.T) {
+	w := newStallWatchdog(NewOptions())
+	if w != nil {
+		t.Fatal("Expected nil watchdog when stall timeout is not set")
+	}
+
+	// A nil watchdog is safe to use
+	w.begin()
+	w.touch()
+	w.end()
+	if event := w.check(time.Now()); event != nil {
+		t.Errorf("Expected no event from nil watchdog, got %+v", event)
+	}
+	tick, stop := w.ticker()
+	defer stop()
+	if tick != nil {
+		t.Error("Expected nil ticker channel from nil watchdog")
+	}
+}
+
+func TestNewStallWatchdog_DefaultAction(t *testing.T) {
+	w := newStallWatchdog(NewOptions(WithStallTimeout(time.Second, "")))
+	if w == nil {
+		t.Fatal("Expected watchdog")
+	}
+	if w.action != StallActionDiagnostic {
+		t.Errorf("Expected default action %q, got %q", StallActionDiagnostic, w.action)
+	}
+}
+
+func TestNewStallWatchdog_UnknownAction(t *testing.T) {
+	// Unknown actions fall back to a diagnostic, whether set through the
+	// option or on the Options struct directly
+	opts := NewOptions(WithStallTimeout(time.Second, "restart"))
+	if opts.StallAction != StallActionDiagnostic {
+		t.Errorf("Expected option to normalize action, got %q", opts.StallAction)
+	}
+
+	w := newStallWatchdog(&Options{StallTimeout: time.Second, StallAction: "restart"})
+	if w.action != StallActionDiagnostic {
+		t.Errorf("Expected action %q, got %q", StallActionDiagnostic, w.action)
+	}
+}
+
+func TestQuery_StallError(t *testing.T) {
+	cli := writeStubCLI(t, `sleep 5`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	messages, errs := Query(ctx, "hang", WithCLIPath(cli), WithStallTimeout(50*time.Millisecond, StallActionClose))
+
+	var events int
+	for msg := range messages {
+		if _, ok := msg.(*DiagnosticEvent); ok {
+			events++
+		}
+	}
+	if events != 1 {
+		t.Errorf("Expected 1 diagnostic event, got %d", events)
+	}
+
+	err := <-errs
+	if !IsStallError(err) {
+		t.Fatalf("Expected StallError, got %v", err)
+	}
+	var stallErr *StallError
+	if errors.As(err, &stallErr); stallErr.Event == nil || stallErr.Event.Action != StallActionClose {
+		t.Errorf("Expected stall event with close action, got %+v", stallErr.Event)
+	}
+}
+
+func TestStallWatchdog_Check(t *testing.T) {
+	w := newStallWatchdog(NewOptions(WithStallTimeout(time.Second, StallActionInterrupt)))
+
+	// No turn in progress
+	if event := w.check(time.Now().Add(time.Hour)); event != nil {
+		t.Errorf("Expected no event outside a turn, got %+v", event)
+	}
+
+	w.begin()
+	start := w.last
+
+	if event := w.check(start.Add(500 * time.Millisecond)); event != nil {
+		t.Errorf("Expected no event before timeout, got %+v", event)
+	}
+
+	event := w.check(start.Add(time.Second))
+	if event == nil {
+		t.Fatal("Expected stall event after timeout")
+	}
+	if event.Kind != DiagnosticKindStall {
+		t.Errorf("Expected kind %q, got %q", DiagnosticKindStall, event.Kind)
+	}
+	if event.Action != StallActionInterrupt {
+		t.Errorf("Expected action %q, got %q", StallActionInterrupt, event.Action)
+	}
+	if event.Idle != time.Second {
+		t.Errorf("Expected idle 1s, got %v", event.Idle)
+	}
+
+	// Reported at most once per timeout while stalled
+	if event := w.check(start.Add(1500 * time.Millisecond)); event != nil {
+		t.Errorf("Expected no repeated event within timeout, got %+v", event)
+	}
+	event = w.check(start.Add(2 * time.Second))
+	if event == nil {
+		t.Fatal("Expected repeated stall event after another timeout")
+	}
+	if event.Idle != 2*time.Second {
+		t.Errorf("Expected idle 2s, got %v", event.Idle)
+	}
+
+	w.end()
+	if event := w.check(start.Add(time.Hour)); event != nil {
+		t.Errorf("Expected no event after turn ended, got %+v", event)
+	}
+}
+
+func TestStallWatchdog_TouchResets(t *testing.T) {
+	w := newStallWatchdog(NewOptions(WithStallTimeout(time.Second, StallActionDiagnostic)))
+	w.begin()
+	w.touch()
+	last := w.last
+
+	if event := w.check(last.Add(900 * time.Millisecond)); event != nil {
+		t.Errorf("Expected no event after recent activity, got %+v", event)
+	}
+}
+
+func TestStallWatchdog_WatchInput(t *testing.T) {
+	w := newStallWatchdog(NewOptions(WithStallTimeout(time.Second, StallActionDiagnostic)))
+
+	in := make(chan map[string]any, 1)
+	in <- map[string]any{"type": "user"}
+	close(in)
+
+	out := w.watchInput(context.Background(), in)
+	msg, ok := <-out
+	if !ok || msg["type"] != "user" {
+		t.Fatalf("Expected forwarded message, got %v", msg)
+	}
+	if _, ok := <-out; ok {
+		t.Error("Expected output channel to close")
+	}
+
+	w.mu.Lock()
+	active := w.active
+	w.mu.Unlock()
+	if !active {
+		t.Error("Expected input message to begin a turn")
+	}
+}
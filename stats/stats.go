@@ -0,0 +1,249 @@
+// Package stats summarizes the results of queries run by a fleet of
+// agents, for monitoring them in production. An Aggregator records the
+// ResultMessage of each query and reports, over a sliding window, the
+// rate of queries, their error rate, the percentiles of their duration
+// and their cost per hour:
+//
+//	agg := stats.NewAggregator(5 * time.Minute)
+//	agg.Publish("claude")             // in /debug/vars
+//	http.Handle("/debug/claude", agg) // as JSON
+//
+//	runner := &jobs.Runner{Concurrency: 8}
+//	for _, job := range batch {
+//	    job.OnDone = func(_ *jobs.Job, result jobs.Result) { agg.RecordJob(result) }
+//	}
+//	runner.Run(ctx, batch)
+//
+// Clients record the results of their turns with Observe:
+//
+//	for msg := range client.ReceiveResponse(ctx) {
+//	    agg.Observe(msg)
+//	}
+//
+// Report calls a function with a summary at an interval, to log them or
+// push them to a metrics system.
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	claude "github.com/afsharalex/claude-agent-sdk-go"
+	"github.com/afsharalex/claude-agent-sdk-go/jobs"
+)
+
+// DefaultWindow is the window of an Aggregator created with a zero window.
+const DefaultWindow = time.Minute
+
+// Summary describes the queries that finished within the window of an
+// Aggregator, and in total since it was created.
+type Summary struct {
+	Time time.Time `json:"time"`
+	// Window is the time the summary covers: the window of the
+	// Aggregator, or the time since it was created if shorter.
+	Window time.Duration `json:"window"`
+
+	// Queries is the number of queries that finished within the window,
+	// and Errors the number of them that failed.
+	Queries int `json:"queries"`
+	Errors  int `json:"errors"`
+	// QPS is the number of queries finished per second, and ErrorRate the
+	// fraction of them that failed.
+	QPS       float64 `json:"qps"`
+	ErrorRate float64 `json:"error_rate"`
+
+	// P50 and P95 are percentiles of the duration of the queries with a
+	// result, as reported by the CLI.
+	P50 time.Duration `json:"p50"`
+	P95 time.Duration `json:"p95"`
+
+	// CostUSD is the cost of the queries, and CostPerHourUSD the rate at
+	// which it was spent.
+	CostUSD        float64 `json:"cost_usd"`
+	CostPerHourUSD float64 `json:"cost_per_hour_usd"`
+
+	// TotalQueries, TotalErrors and TotalCostUSD count all queries
+	// recorded since the Aggregator was created.
+	TotalQueries int     `json:"total_queries"`
+	TotalErrors  int     `json:"total_errors"`
+	TotalCostUSD float64 `json:"total_cost_usd"`
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("%d queries in %s (%.2f/s), %.1f%% errors, p50 %s p95 %s, $%.4f ($%.2f/h)",
+		s.Queries, s.Window.Round(time.Second), s.QPS, 100*s.ErrorRate,
+		s.P50, s.P95, s.CostUSD, s.CostPerHourUSD)
+}
+
+// sample is a finished query.
+type sample struct {
+	at       time.Time
+	duration time.Duration // negative for a failure without a result
+	cost     float64
+	isError  bool
+}
+
+// Aggregator records finished queries and summarizes them over a sliding
+// window. It is safe for concurrent use.
+type Aggregator struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	started time.Time
+	samples []sample // in the order recorded
+	total   struct {
+		queries, errors int
+		cost            float64
+	}
+}
+
+// NewAggregator returns an Aggregator summarizing the queries that
+// finished within window, DefaultWindow if zero.
+func NewAggregator(window time.Duration) *Aggregator {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	return &Aggregator{window: window, now: time.Now, started: time.Now()}
+}
+
+// Observe records msg if it is a ResultMessage, and ignores other
+// messages, so that it can be called with each message of a stream.
+func (a *Aggregator) Observe(msg claude.Message) {
+	if result, ok := msg.(*claude.ResultMessage); ok {
+		a.Record(result)
+	}
+}
+
+// Record records the result of a query. Results with IsError set count
+// as errors.
+func (a *Aggregator) Record(result *claude.ResultMessage) {
+	if result == nil {
+		return
+	}
+	s := sample{duration: time.Duration(result.DurationMs) * time.Millisecond, isError: result.IsError}
+	if result.TotalCostUSD != nil {
+		s.cost = *result.TotalCostUSD
+	}
+	a.add(s)
+}
+
+// RecordError records a query that failed without a result, such as one
+// whose CLI could not be started. It counts toward the error rate but not
+// the durations.
+func (a *Aggregator) RecordError(err error) {
+	a.add(sample{duration: -1, isError: true})
+}
+
+// RecordJob records the outcome of a job run by a jobs.Runner: its last
+// result if it has one, or an error if it failed without one. Skipped
+// jobs are not recorded.
+func (a *Aggregator) RecordJob(result jobs.Result) {
+	switch {
+	case result.Result != nil:
+		a.Record(result.Result)
+	case result.Status == jobs.StatusFailed:
+		a.RecordError(result.Err)
+	}
+}
+
+func (a *Aggregator) add(s sample) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s.at = a.now()
+	a.samples = append(a.samples, s)
+	a.total.queries++
+	if s.isError {
+		a.total.errors++
+	}
+	a.total.cost += s.cost
+	a.prune(s.at)
+}
+
+// prune drops the samples older than the window. a.mu must be held.
+func (a *Aggregator) prune(now time.Time) {
+	cutoff := now.Add(-a.window)
+	i := 0
+	for i < len(a.samples) && !a.samples[i].at.After(cutoff) {
+		i++
+	}
+	if i > 0 {
+		a.samples = slices.Delete(a.samples, 0, i)
+	}
+}
+
+// Summary summarizes the queries that finished within the window.
+func (a *Aggregator) Summary() Summary {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.now()
+	a.prune(now)
+
+	s := Summary{
+		Time:         now,
+		Window:       min(a.window, now.Sub(a.started)),
+		Queries:      len(a.samples),
+		TotalQueries: a.total.queries,
+		TotalErrors:  a.total.errors,
+		TotalCostUSD: a.total.cost,
+	}
+	var durations []time.Duration
+	for _, sample := range a.samples {
+		if sample.isError {
+			s.Errors++
+		}
+		if sample.duration >= 0 {
+			durations = append(durations, sample.duration)
+		}
+		s.CostUSD += sample.cost
+	}
+	if s.Queries > 0 {
+		s.ErrorRate = float64(s.Errors) / float64(s.Queries)
+	}
+	if s.Window > 0 {
+		s.QPS = float64(s.Queries) / s.Window.Seconds()
+		s.CostPerHourUSD = s.CostUSD / s.Window.Hours()
+	}
+	if len(durations) > 0 {
+		slices.Sort(durations)
+		at := func(p float64) time.Duration {
+			return durations[min(len(durations)-1, int(p*float64(len(durations))))]
+		}
+		s.P50, s.P95 = at(0.50), at(0.95)
+	}
+	return s
+}
+
+// ServeHTTP writes the current summary as JSON.
+func (a *Aggregator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(a.Summary())
+}
+
+// Publish publishes the current summary as the expvar variable name, so
+// that it is served by the /debug/vars handler expvar registers on
+// http.DefaultServeMux. Like expvar.Publish, it panics if name is already
+// published.
+func (a *Aggregator) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() any { return a.Summary() }))
+}
+
+// Report calls fn with a summary every interval until ctx is cancelled.
+func (a *Aggregator) Report(ctx context.Context, interval time.Duration, fn func(Summary)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fn(a.Summary())
+		}
+	}
+}
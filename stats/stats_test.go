@@ -0,0 +1,169 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"math"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	claude "github.com/afsharalex/claude-agent-sdk-go"
+	"github.com/afsharalex/claude-agent-sdk-go/jobs"
+)
+
+// fakeClock is a clock that moves only when advanced.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestAggregator(window time.Duration) (*Aggregator, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	a := NewAggregator(window)
+	a.now, a.started = clock.now, clock.t
+	return a, clock
+}
+
+func result(durationMs int, cost float64, isError bool) *claude.ResultMessage {
+	return &claude.ResultMessage{DurationMs: durationMs, TotalCostUSD: &cost, IsError: isError}
+}
+
+func TestAggregator_Summary(t *testing.T) {
+	a, clock := newTestAggregator(time.Minute)
+	clock.advance(2 * time.Minute)
+	for i := range 20 {
+		a.Record(result((i+1)*100, 0.01, i%5 == 0))
+	}
+	a.RecordError(errors.New("CLI not found"))
+
+	s := a.Summary()
+	if s.Window != time.Minute || s.Queries != 21 || s.Errors != 5 {
+		t.Fatalf("Expected 21 queries with 5 errors over a minute, got %+v", s)
+	}
+	if math.Abs(s.QPS-21.0/60) > 1e-9 || math.Abs(s.ErrorRate-5.0/21) > 1e-9 {
+		t.Errorf("Expected the rates of the window, got %+v", s)
+	}
+	if s.P50 != 1100*time.Millisecond || s.P95 != 2000*time.Millisecond {
+		t.Errorf("Expected percentiles of the result durations, got p50 %s p95 %s", s.P50, s.P95)
+	}
+	if math.Abs(s.CostUSD-0.2) > 1e-9 || math.Abs(s.CostPerHourUSD-12) > 1e-9 {
+		t.Errorf("Expected $0.20 spent at $12/h, got %+v", s)
+	}
+}
+
+func TestAggregator_Window(t *testing.T) {
+	a, clock := newTestAggregator(time.Minute)
+	a.Record(result(1000, 1, true))
+	clock.advance(30 * time.Second)
+
+	// Before the window has elapsed, rates are over the time since creation
+	s := a.Summary()
+	if s.Window != 30*time.Second || s.QPS != 1.0/30 || s.CostPerHourUSD != 120 {
+		t.Errorf("Expected rates over 30s, got %+v", s)
+	}
+
+	clock.advance(45 * time.Second)
+	a.Record(result(2000, 2, false))
+	s = a.Summary()
+	if s.Queries != 1 || s.Errors != 0 || s.P50 != 2*time.Second || s.CostUSD != 2 {
+		t.Errorf("Expected the first result out of the window, got %+v", s)
+	}
+	if s.TotalQueries != 2 || s.TotalErrors != 1 || s.TotalCostUSD != 3 {
+		t.Errorf("Expected totals to include the first result, got %+v", s)
+	}
+
+	clock.advance(time.Hour)
+	if s = a.Summary(); s.Queries != 0 || s.QPS != 0 || s.ErrorRate != 0 || s.P50 != 0 {
+		t.Errorf("Expected an empty window, got %+v", s)
+	}
+}
+
+func TestAggregator_Observe(t *testing.T) {
+	a, clock := newTestAggregator(time.Minute)
+	clock.advance(time.Minute)
+	a.Observe(&claude.AssistantMessage{})
+	a.Observe(result(500, 0.5, false))
+	a.Observe(&claude.ResultMessage{DurationMs: 700})
+
+	s := a.Summary()
+	if s.Queries != 2 || s.CostUSD != 0.5 || s.P95 != 700*time.Millisecond {
+		t.Errorf("Expected only the results recorded, got %+v", s)
+	}
+}
+
+func TestAggregator_RecordJob(t *testing.T) {
+	a, clock := newTestAggregator(time.Minute)
+	clock.advance(time.Minute)
+	a.RecordJob(jobs.Result{Status: jobs.StatusSucceeded, Result: result(100, 0.1, false)})
+	a.RecordJob(jobs.Result{Status: jobs.StatusFailed, Err: errors.New("exit status 1")})
+	a.RecordJob(jobs.Result{Status: jobs.StatusFailed, Result: result(200, 0.2, true)})
+	a.RecordJob(jobs.Result{Status: jobs.StatusSkipped})
+
+	s := a.Summary()
+	if s.Queries != 3 || s.Errors != 2 || s.P50 != 200*time.Millisecond {
+		t.Errorf("Expected two failed jobs and one success, got %+v", s)
+	}
+}
+
+func TestAggregator_ServeHTTP(t *testing.T) {
+	a, clock := newTestAggregator(time.Minute)
+	clock.advance(time.Minute)
+	a.Record(result(1500, 0.25, false))
+
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/claude", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected JSON, got %q", ct)
+	}
+	var s Summary
+	if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+		t.Fatalf("Failed to decode summary: %v", err)
+	}
+	if s.Queries != 1 || s.P50 != 1500*time.Millisecond || s.CostUSD != 0.25 {
+		t.Errorf("Expected the summary served, got %+v", s)
+	}
+}
+
+func TestAggregator_Publish(t *testing.T) {
+	a, clock := newTestAggregator(time.Minute)
+	clock.advance(time.Minute)
+	a.Publish("stats_test")
+	a.Record(result(100, 0, false))
+
+	var s Summary
+	if err := json.Unmarshal([]byte(expvar.Get("stats_test").String()), &s); err != nil {
+		t.Fatalf("Failed to decode published summary: %v", err)
+	}
+	if s.Queries != 1 {
+		t.Errorf("Expected the current summary published, got %+v", s)
+	}
+}
+
+func TestAggregator_Report(t *testing.T) {
+	a := NewAggregator(0)
+	if a.window != DefaultWindow {
+		t.Errorf("Expected the default window, got %s", a.window)
+	}
+	a.Record(result(100, 0, false))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	summaries := make(chan Summary, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.Report(ctx, time.Millisecond, func(s Summary) {
+			select {
+			case summaries <- s:
+			default:
+			}
+		})
+	}()
+	if s := <-summaries; s.Queries != 1 {
+		t.Errorf("Expected the summary reported, got %+v", s)
+	}
+	cancel()
+	<-done
+}
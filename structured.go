@@ -0,0 +1,78 @@
+package claude
+
+import (
+	"strings"
+	"sync"
+)
+
+// structuredOutputValidator checks structured output against the schema
+// of WithJSONSchema, deciding when to ask Claude to repair it.
+type structuredOutputValidator struct {
+	schema  map[string]any
+	retries int
+
+	mu sync.Mutex
+	// attempts counts the repairs requested in the current turn.
+	attempts int
+}
+
+// newStructuredOutputValidator returns a validator for options, or nil if
+// validation is not enabled or no JSON schema is set.
+func newStructuredOutputValidator(o *Options) *structuredOutputValidator {
+	if o.StructuredOutputRetries == nil || o.OutputFormat["type"] != "json_schema" {
+		return nil
+	}
+	schema, ok := o.OutputFormat["schema"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	return &structuredOutputValidator{
+		schema:  schema,
+		retries: max(0, *o.StructuredOutputRetries),
+	}
+}
+
+// check validates the structured output of result. It returns a prompt
+// asking Claude to repair the output if a retry is left, or a
+// SchemaValidationError once retries are exhausted. Error results are not
+// checked.
+func (v *structuredOutputValidator) check(result *ResultMessage) (string, error) {
+	if v == nil || result.IsError {
+		return "", nil
+	}
+
+	var violations []string
+	if result.StructuredOutput == nil {
+		violations = []string{"$: structured output is missing"}
+	} else {
+		violations = ValidateJSONSchema(v.schema, result.StructuredOutput)
+	}
+	if len(violations) == 0 {
+		return "", nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.attempts >= v.retries {
+		return "", NewSchemaValidationError(result.StructuredOutput, violations)
+	}
+	v.attempts++
+	return repairPrompt(violations), nil
+}
+
+// reset starts counting repairs for a new turn.
+func (v *structuredOutputValidator) reset() {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	v.attempts = 0
+	v.mu.Unlock()
+}
+
+// repairPrompt asks Claude to correct its structured output.
+func repairPrompt(violations []string) string {
+	return "Your structured output did not match the required JSON schema:\n- " +
+		strings.Join(violations, "\n- ") +
+		"\nRespond again with structured output that matches the schema."
+}
@@ -0,0 +1,182 @@
+package claude
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+var nameSchema = map[string]any{
+	"type":       "object",
+	"properties": map[string]any{"name": map[string]any{"type": "string"}},
+	"required":   []string{"name"},
+}
+
+func TestNewStructuredOutputValidator(t *testing.T) {
+	if v := newStructuredOutputValidator(NewOptions(WithJSONSchema(nameSchema))); v != nil {
+		t.Error("Expected no validator without WithStructuredOutputRetries")
+	}
+	if v := newStructuredOutputValidator(NewOptions(WithStructuredOutputRetries(2))); v != nil {
+		t.Error("Expected no validator without a JSON schema")
+	}
+	v := newStructuredOutputValidator(NewOptions(WithJSONSchema(nameSchema), WithStructuredOutputRetries(2)))
+	if v == nil || v.retries != 2 {
+		t.Fatalf("Expected validator with 2 retries, got %+v", v)
+	}
+}
+
+func TestStructuredOutputValidator_Check(t *testing.T) {
+	v := newStructuredOutputValidator(NewOptions(WithJSONSchema(nameSchema), WithStructuredOutputRetries(1)))
+
+	if repair, err := v.check(&ResultMessage{StructuredOutput: map[string]any{"name": "Ada"}}); repair != "" || err != nil {
+		t.Errorf("Expected valid output to pass, got %q, %v", repair, err)
+	}
+	if repair, err := v.check(&ResultMessage{IsError: true}); repair != "" || err != nil {
+		t.Errorf("Expected error results to be skipped, got %q, %v", repair, err)
+	}
+
+	repair, err := v.check(&ResultMessage{})
+	if err != nil || !strings.Contains(repair, "structured output is missing") {
+		t.Errorf("Expected repair prompt for missing output, got %q, %v", repair, err)
+	}
+
+	invalid := &ResultMessage{StructuredOutput: map[string]any{"name": 1}}
+	repair, err = v.check(invalid)
+	if repair != "" {
+		t.Errorf("Expected no repair once retries are exhausted, got %q", repair)
+	}
+	schemaErr, ok := AsSchemaValidationError(err)
+	if !ok {
+		t.Fatalf("Expected SchemaValidationError, got %v", err)
+	}
+	if len(schemaErr.Errors) != 1 || schemaErr.Errors[0] != "$.name: expected string, got number" {
+		t.Errorf("Unexpected violations: %q", schemaErr.Errors)
+	}
+
+	// A new turn gets its own retries
+	v.reset()
+	if repair, _ := v.check(invalid); repair == "" {
+		t.Error("Expected a repair after reset")
+	}
+
+	var disabled *structuredOutputValidator
+	if repair, err := disabled.check(&ResultMessage{}); repair != "" || err != nil {
+		t.Errorf("Expected nil validator to accept everything, got %q, %v", repair, err)
+	}
+	disabled.reset()
+}
+
+// structuredStubCLI returns a stub CLI whose first answer lacks the
+// required name and whose answers to resumed sessions are valid.
+func structuredStubCLI(t *testing.T, streaming bool) string {
+	t.Helper()
+	body := `
+respond() {
+	case "$*" in
+	*--resume*) echo '{"type":"result","subtype":"success","session_id":"sess-1","structured_output":{"name":"Ada"}}' ;;
+	*) echo '{"type":"result","subtype":"success","session_id":"sess-1","structured_output":{"age":1}}' ;;
+	esac
+}
+respond "$@"
+`
+	if streaming {
+		// Answer initialize, then each prompt; the second prompt is valid
+		body = `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+read line
+echo '{"type":"result","subtype":"success","session_id":"sess-1","structured_output":{"age":1}}'
+read line
+case "$line" in
+*"did not match"*) echo '{"type":"result","subtype":"success","session_id":"sess-1","structured_output":{"name":"Ada"}}' ;;
+*) echo '{"type":"result","subtype":"success","session_id":"sess-1"}' ;;
+esac
+cat > /dev/null
+`
+	}
+	return writeStubCLI(t, body)
+}
+
+func TestQuery_StructuredOutputRetry(t *testing.T) {
+	cli := structuredStubCLI(t, false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	messages, errs := Query(ctx, "Who?", WithCLIPath(cli),
+		WithJSONSchema(nameSchema), WithStructuredOutputRetries(1))
+
+	var results []*ResultMessage
+	for msg := range messages {
+		if result, ok := msg.(*ResultMessage); ok {
+			results = append(results, result)
+		}
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(results) != 1 {
+		t.Fatalf("Expected only the repaired result, got %d results", len(results))
+	}
+	output, _ := results[0].StructuredOutput.(map[string]any)
+	if output["name"] != "Ada" {
+		t.Errorf("Expected repaired output, got %v", results[0].StructuredOutput)
+	}
+}
+
+func TestQuery_StructuredOutputInvalid(t *testing.T) {
+	cli := structuredStubCLI(t, false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	messages, errs := Query(ctx, "Who?", WithCLIPath(cli),
+		WithJSONSchema(nameSchema), WithStructuredOutputRetries(0))
+
+	var results int
+	for msg := range messages {
+		if _, ok := msg.(*ResultMessage); ok {
+			results++
+		}
+	}
+	if results != 1 {
+		t.Errorf("Expected the invalid result to be delivered, got %d results", results)
+	}
+	if err := <-errs; !IsSchemaValidationError(err) {
+		t.Errorf("Expected SchemaValidationError, got %v", err)
+	}
+}
+
+func TestClient_StructuredOutputRetry(t *testing.T) {
+	cli := structuredStubCLI(t, true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli), WithJSONSchema(nameSchema), WithStructuredOutputRetries(1))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.Query(ctx, "Who?"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	var results []*ResultMessage
+	for msg := range client.ReceiveResponse(ctx) {
+		if result, ok := msg.(*ResultMessage); ok {
+			results = append(results, result)
+		}
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected only the repaired result, got %d results", len(results))
+	}
+	output, _ := results[0].StructuredOutput.(map[string]any)
+	if output["name"] != "Ada" {
+		t.Errorf("Expected repaired output, got %v", results[0].StructuredOutput)
+	}
+}
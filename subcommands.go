@@ -0,0 +1,208 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/transport"
+)
+
+// DoctorCheck is a line of a section of the CLI's doctor report, such as
+// "Auto-updates: default (true)" under "Diagnostics".
+type DoctorCheck struct {
+	Section string
+	// Name is the text before the colon, and Value the text after it.
+	// Lines without a colon have only a Name.
+	Name  string
+	Value string
+}
+
+// DoctorWarning is a problem the doctor found, with the fix it suggests,
+// if any.
+type DoctorWarning struct {
+	Message string
+	Fix     string
+}
+
+// DoctorReport is the outcome of `claude doctor`.
+type DoctorReport struct {
+	// Version is the version of the CLI that is running, if the report
+	// names it.
+	Version  string
+	Checks   []DoctorCheck
+	Warnings []DoctorWarning
+	// Output is the report as printed, without terminal escapes.
+	Output string
+}
+
+// Healthy reports whether the doctor found no problems.
+func (r *DoctorReport) Healthy() bool {
+	return len(r.Warnings) == 0
+}
+
+// Check returns the first check named name, compared without case.
+func (r *DoctorReport) Check(name string) (DoctorCheck, bool) {
+	for _, check := range r.Checks {
+		if strings.EqualFold(check.Name, name) {
+			return check, true
+		}
+	}
+	return DoctorCheck{}, false
+}
+
+// Doctor runs `claude doctor`, which checks the CLI's installation, auto
+// updates and settings, and parses its report, so that deployment
+// tooling can verify an install. Options such as WithCLIPath, WithEnv
+// and WithContainerRuntime apply.
+//
+// The report is meant for people and its layout may change between CLI
+// versions; Output holds it whole. Doctor fails with a ProcessError if the
+// CLI exits with an error, returning the report it printed, if any.
+//
+// Example:
+//
+//	report, err := claude.Doctor(ctx)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	for _, w := range report.Warnings {
+//	    log.Printf("claude doctor: %s (fix: %s)", w.Message, w.Fix)
+//	}
+func Doctor(ctx context.Context, opts ...Option) (*DoctorReport, error) {
+	// The report waits for Enter before exiting
+	output, err := runSubcommand(ctx, opts, "\n", "doctor")
+	if output == "" && err != nil {
+		return nil, err
+	}
+	return parseDoctorReport(output), err
+}
+
+// CLIUpdateResult is the outcome of `claude update`.
+type CLIUpdateResult struct {
+	// PreviousVersion is the version before the update, and Version the
+	// version installed after it. They are equal if the CLI was up to
+	// date.
+	PreviousVersion string
+	Version         string
+	Updated         bool
+	// Output is the output of the update, without terminal escapes.
+	Output string
+}
+
+// CLIUpdate runs `claude update`, which installs the latest version of
+// the CLI if it is not up to date, and reports the versions before and
+// after. Options such as WithCLIPath and WithEnv apply. It fails with a
+// ProcessError if the update fails.
+//
+// Example:
+//
+//	result, err := claude.CLIUpdate(ctx)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	if result.Updated {
+//	    log.Printf("updated Claude Code from %s to %s", result.PreviousVersion, result.Version)
+//	}
+func CLIUpdate(ctx context.Context, opts ...Option) (*CLIUpdateResult, error) {
+	output, err := runSubcommand(ctx, opts, "", "update")
+	if err != nil {
+		return nil, err
+	}
+	return parseCLIUpdate(output), nil
+}
+
+// runSubcommand runs the CLI configured by opts with args and input, and
+// returns its output without terminal escapes. A failed run returns the
+// output with a ProcessError.
+func runSubcommand(ctx context.Context, opts []Option, input string, args ...string) (string, error) {
+	options := NewOptions(opts...)
+	t, err := transport.NewSubprocessTransport("", true, toTransportOptions(options))
+	if err != nil {
+		return "", err
+	}
+	stdout, stderr, err := t.RunSubcommand(ctx, input, args...)
+	output := stripANSI(string(stdout))
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if ctx.Err() != nil {
+			return output, ctx.Err()
+		}
+		message := "claude " + strings.Join(args, " ") + " failed"
+		return output, NewProcessError(message, exitErr.ExitCode(), strings.TrimSpace(stripANSI(string(stderr))))
+	}
+	return output, err
+}
+
+// ansiEscape matches terminal escape sequences.
+var ansiEscape = regexp.MustCompile(`\x1b(\[[0-9;?]*[ -/]*[@-~]|\][^\x07]*\x07|[@-Z\\-_])`)
+
+func stripANSI(s string) string {
+	return ansiEscape.ReplaceAllString(s, "")
+}
+
+// semver matches a version number.
+var semver = regexp.MustCompile(`\d+\.\d+\.\d+(?:-[0-9A-Za-z.]+)?`)
+
+// parseDoctorReport parses the report of `claude doctor`: sections headed
+// by a title, with one check per line under a tree branch ("└"), and
+// "Warning:" lines, each followed by an optional "Fix:" line.
+func parseDoctorReport(output string) *DoctorReport {
+	report := &DoctorReport{Output: output}
+	var section string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		trimmed := strings.TrimSpace(strings.TrimLeft(line, "└├│─⎿ "))
+		switch {
+		case line == "":
+		case strings.HasPrefix(strings.TrimLeft(line, "⚠ "), "Warning:"):
+			message := strings.TrimPrefix(strings.TrimLeft(line, "⚠ "), "Warning:")
+			report.Warnings = append(report.Warnings, DoctorWarning{Message: strings.TrimSpace(message)})
+		case strings.HasPrefix(line, "Fix:") && len(report.Warnings) > 0:
+			report.Warnings[len(report.Warnings)-1].Fix = strings.TrimSpace(strings.TrimPrefix(line, "Fix:"))
+		case trimmed != line && trimmed != "":
+			check := DoctorCheck{Section: section, Name: trimmed}
+			if name, value, ok := strings.Cut(trimmed, ": "); ok {
+				check.Name, check.Value = name, value
+			}
+			report.Checks = append(report.Checks, check)
+			if report.Version == "" && strings.EqualFold(check.Name, "Currently running") {
+				report.Version = semver.FindString(check.Value)
+			}
+		case strings.HasPrefix(line, "Press Enter"):
+		default:
+			section = line
+		}
+	}
+	return report
+}
+
+// The lines of `claude update` that give versions.
+var (
+	currentVersionLine = regexp.MustCompile(`(?i)current version:?\s*(` + semver.String() + `)`)
+	updatedVersionLine = regexp.MustCompile(`(?i)updated from (` + semver.String() + `) to (?:version )?(` + semver.String() + `)`)
+	upToDateLine       = regexp.MustCompile(`(?i)up to date \(?(?:version )?(` + semver.String() + `)`)
+)
+
+// parseCLIUpdate parses the output of `claude update`.
+func parseCLIUpdate(output string) *CLIUpdateResult {
+	result := &CLIUpdateResult{Output: output}
+	if m := updatedVersionLine.FindStringSubmatch(output); m != nil {
+		result.PreviousVersion, result.Version, result.Updated = m[1], m[2], true
+		return result
+	}
+	if m := currentVersionLine.FindStringSubmatch(output); m != nil {
+		result.PreviousVersion = m[1]
+	}
+	result.Version = result.PreviousVersion
+	if m := upToDateLine.FindStringSubmatch(output); m != nil {
+		result.Version = m[1]
+	}
+	if result.PreviousVersion == "" {
+		result.PreviousVersion = result.Version
+	}
+	return result
+}
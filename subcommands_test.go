@@ -0,0 +1,140 @@
+package claude
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const doctorOutput = "\x1b[1m Diagnostics\x1b[22m\n" +
+	" └ Currently running: npm-global (1.0.58)\n" +
+	" └ Path: /usr/local/bin/node\n" +
+	" └ Auto-updates: default (true)\n" +
+	" └ Search: OK (vendor)\n" +
+	"\n" +
+	" Version Locks\n" +
+	" └ No active version locks\n" +
+	"\n" +
+	" \x1b[33mWarning: Local installation exists but not being used\x1b[39m\n" +
+	" Fix: Consider using local installation: claude migrate-installer\n" +
+	"\n" +
+	" Press Enter to continue…\n"
+
+func TestParseDoctorReport(t *testing.T) {
+	report := parseDoctorReport(stripANSI(doctorOutput))
+	if report.Version != "1.0.58" {
+		t.Errorf("Expected the running version, got %q", report.Version)
+	}
+	wantChecks := []DoctorCheck{
+		{Section: "Diagnostics", Name: "Currently running", Value: "npm-global (1.0.58)"},
+		{Section: "Diagnostics", Name: "Path", Value: "/usr/local/bin/node"},
+		{Section: "Diagnostics", Name: "Auto-updates", Value: "default (true)"},
+		{Section: "Diagnostics", Name: "Search", Value: "OK (vendor)"},
+		{Section: "Version Locks", Name: "No active version locks"},
+	}
+	if !reflect.DeepEqual(report.Checks, wantChecks) {
+		t.Errorf("Expected checks %+v, got %+v", wantChecks, report.Checks)
+	}
+	wantWarnings := []DoctorWarning{{
+		Message: "Local installation exists but not being used",
+		Fix:     "Consider using local installation: claude migrate-installer",
+	}}
+	if !reflect.DeepEqual(report.Warnings, wantWarnings) || report.Healthy() {
+		t.Errorf("Expected warnings %+v, got %+v", wantWarnings, report.Warnings)
+	}
+	if check, ok := report.Check("auto-updates"); !ok || check.Value != "default (true)" {
+		t.Errorf("Expected to find a check by name, got %+v", check)
+	}
+	if _, ok := report.Check("Update permissions"); ok {
+		t.Error("Expected no check for a name the report lacks")
+	}
+}
+
+func TestParseCLIUpdate(t *testing.T) {
+	tests := map[string]struct {
+		output string
+		want   CLIUpdateResult
+	}{
+		"updated": {
+			output: "Current version: 1.0.58\nChecking for updates...\nNew version available: 1.0.60 (current: 1.0.58)\nInstalling update...\nSuccessfully updated from 1.0.58 to version 1.0.60\n",
+			want:   CLIUpdateResult{PreviousVersion: "1.0.58", Version: "1.0.60", Updated: true},
+		},
+		"up to date": {
+			output: "Current version: 1.0.60\nChecking for updates...\nClaude Code is up to date (1.0.60)\n",
+			want:   CLIUpdateResult{PreviousVersion: "1.0.60", Version: "1.0.60"},
+		},
+		"up to date without current version": {
+			output: "Claude Code is up to date (2.0.1-beta.2)\n",
+			want:   CLIUpdateResult{PreviousVersion: "2.0.1-beta.2", Version: "2.0.1-beta.2"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := parseCLIUpdate(tt.output)
+			tt.want.Output = tt.output
+			if *got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, *got)
+			}
+		})
+	}
+}
+
+func TestDoctor(t *testing.T) {
+	cli := writeStubCLI(t, `
+[ "$1" = doctor ] || exit 2
+read enter
+printf '`+strings.ReplaceAll(doctorOutput, "\x1b", `\033`)+`'
+`)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	report, err := Doctor(ctx, WithCLIPath(cli))
+	if err != nil {
+		t.Fatalf("Doctor failed: %v", err)
+	}
+	if report.Version != "1.0.58" || len(report.Warnings) != 1 || strings.Contains(report.Output, "\x1b") {
+		t.Errorf("Expected the parsed report without escapes, got %+v", report)
+	}
+}
+
+func TestDoctor_Failure(t *testing.T) {
+	cli := writeStubCLI(t, `
+echo ' Diagnostics'
+echo ' └ Currently running: unknown'
+echo 'config file is corrupt' >&2
+exit 1
+`)
+	report, err := Doctor(context.Background(), WithCLIPath(cli))
+	procErr, ok := AsProcessError(err)
+	if !ok || procErr.ExitCode != 1 || !strings.Contains(procErr.Stderr, "corrupt") {
+		t.Fatalf("Expected a ProcessError with the CLI's stderr, got %v", err)
+	}
+	if report == nil || len(report.Checks) != 1 {
+		t.Errorf("Expected the report printed before the failure, got %+v", report)
+	}
+}
+
+func TestCLIUpdate(t *testing.T) {
+	cli := writeStubCLI(t, `
+[ "$1" = update ] || exit 2
+echo "Current version: 1.0.58"
+echo "Successfully updated from 1.0.58 to version 1.0.60"
+`)
+	result, err := CLIUpdate(context.Background(), WithCLIPath(cli))
+	if err != nil {
+		t.Fatalf("CLIUpdate failed: %v", err)
+	}
+	if !result.Updated || result.PreviousVersion != "1.0.58" || result.Version != "1.0.60" {
+		t.Errorf("Expected the update reported, got %+v", result)
+	}
+
+	cli = writeStubCLI(t, `
+echo "Error: Failed to install update: EACCES" >&2
+exit 1
+`)
+	if _, err := CLIUpdate(context.Background(), WithCLIPath(cli)); !IsProcessError(err) || !strings.Contains(err.Error(), "EACCES") {
+		t.Errorf("Expected a ProcessError for a failed update, got %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+package claude
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// TurnTiming breaks down where the time of a turn went. Apart from API,
+// durations are measured by the SDK from when messages arrive.
+type TurnTiming struct {
+	// Total is the time from sending the prompt to the ResultMessage.
+	Total time.Duration
+
+	// TimeToFirstToken is the time from sending the prompt to the first
+	// streamed token or, without WithIncludePartialMessages, to the first
+	// assistant message.
+	TimeToFirstToken time.Duration
+
+	// API is the time the CLI reports spending in API calls
+	// (ResultMessage.DurationAPIMs).
+	API time.Duration
+
+	// Tools is the time spent running tools, from each tool use to its
+	// result. Tools running at the same time are counted once.
+	Tools time.Duration
+
+	// Model is the rest of Total: time spent waiting on the model.
+	Model time.Duration
+
+	// ToolCalls lists the tool executions of the turn, in the order they
+	// finished.
+	ToolCalls []ToolTiming
+}
+
+// ToolTiming is the duration of one tool execution.
+type ToolTiming struct {
+	ToolUseID string
+	Name      string
+	Duration  time.Duration
+}
+
+// turnTimer measures the timing of turns from their messages.
+type turnTimer struct {
+	mu  sync.Mutex
+	now func() time.Time
+
+	start      time.Time
+	firstToken time.Time
+	running    map[string]runningTool
+	calls      []ToolTiming
+	spans      []timeSpan
+
+	last *TurnTiming
+}
+
+// runningTool is a tool use waiting for its result.
+type runningTool struct {
+	name  string
+	start time.Time
+}
+
+// timeSpan is an interval of time.
+type timeSpan struct {
+	start, end time.Time
+}
+
+func newTurnTimer() *turnTimer {
+	return &turnTimer{now: time.Now}
+}
+
+// begin starts timing a turn.
+func (t *turnTimer) begin() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.start = t.now()
+	t.firstToken = time.Time{}
+	t.running = nil
+	t.calls = nil
+	t.spans = nil
+}
+
+// observe records the first token and the start and end of tool uses.
+func (t *turnTimer) observe(msg Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if t.start.IsZero() {
+		// The turn was started without begin, e.g. by a prompt written
+		// directly to the CLI
+		t.start = now
+	}
+
+	switch m := msg.(type) {
+	case *StreamEvent:
+		if t.firstToken.IsZero() && m.Event["type"] == "content_block_delta" {
+			t.firstToken = now
+		}
+	case *AssistantMessage:
+		if t.firstToken.IsZero() {
+			t.firstToken = now
+		}
+		for _, block := range m.Content {
+			if use, ok := block.(ToolUseBlock); ok {
+				if t.running == nil {
+					t.running = make(map[string]runningTool)
+				}
+				t.running[use.ID] = runningTool{name: use.Name, start: now}
+			}
+		}
+	case *UserMessage:
+		for _, block := range m.GetContentBlocks() {
+			result, ok := block.(ToolResultBlock)
+			if !ok {
+				continue
+			}
+			tool, ok := t.running[result.ToolUseID]
+			if !ok {
+				continue
+			}
+			delete(t.running, result.ToolUseID)
+			t.calls = append(t.calls, ToolTiming{ToolUseID: result.ToolUseID, Name: tool.name, Duration: now.Sub(tool.start)})
+			t.spans = append(t.spans, timeSpan{start: tool.start, end: now})
+		}
+	}
+}
+
+// finish completes the timing of the turn ended by result, attaches it
+// to result and keeps it as the last turn's timing.
+func (t *turnTimer) finish(result *ResultMessage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if t.start.IsZero() {
+		t.start = now
+	}
+	timing := &TurnTiming{
+		Total:     now.Sub(t.start),
+		API:       time.Duration(result.DurationAPIMs) * time.Millisecond,
+		Tools:     spanUnion(t.spans),
+		ToolCalls: t.calls,
+	}
+	if !t.firstToken.IsZero() {
+		timing.TimeToFirstToken = t.firstToken.Sub(t.start)
+	}
+	timing.Model = max(timing.Total-timing.Tools, 0)
+
+	result.Timing = timing
+	t.last = timing
+	t.start = time.Time{}
+}
+
+// lastTiming returns the timing of the last finished turn.
+func (t *turnTimer) lastTiming() *TurnTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.last
+}
+
+// spanUnion returns the total time covered by spans.
+func spanUnion(spans []timeSpan) time.Duration {
+	sorted := append([]timeSpan(nil), spans...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].start.Before(sorted[j].start) })
+
+	var total time.Duration
+	var current timeSpan
+	for i, span := range sorted {
+		switch {
+		case i == 0:
+			current = span
+		case !span.start.After(current.end):
+			if span.end.After(current.end) {
+				current.end = span.end
+			}
+		default:
+			total += current.end.Sub(current.start)
+			current = span
+		}
+	}
+	if len(sorted) > 0 {
+		total += current.end.Sub(current.start)
+	}
+	return total
+}
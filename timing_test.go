@@ -0,0 +1,184 @@
+package claude
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock returns a clock for a turnTimer and a function advancing it.
+func fakeClock() (func() time.Time, func(time.Duration)) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	return func() time.Time { return now }, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestTurnTimer(t *testing.T) {
+	clock, advance := fakeClock()
+	timer := &turnTimer{now: clock}
+
+	timer.begin()
+	advance(100 * time.Millisecond)
+	timer.observe(&StreamEvent{Event: map[string]any{"type": "message_start"}})
+	timer.observe(&StreamEvent{Event: map[string]any{"type": "content_block_delta"}})
+	advance(100 * time.Millisecond)
+	timer.observe(&AssistantMessage{Content: []ContentBlock{
+		ToolUseBlock{ID: "a", Name: "Read"},
+		ToolUseBlock{ID: "b", Name: "Bash"},
+	}})
+	advance(300 * time.Millisecond)
+	timer.observe(&UserMessage{Content: []ContentBlock{ToolResultBlock{ToolUseID: "a"}}})
+	advance(200 * time.Millisecond)
+	timer.observe(&UserMessage{Content: []ContentBlock{ToolResultBlock{ToolUseID: "b"}}})
+	advance(300 * time.Millisecond)
+
+	result := &ResultMessage{DurationAPIMs: 600}
+	timer.finish(result)
+
+	timing := result.Timing
+	if timing == nil {
+		t.Fatal("Expected timing on the result")
+	}
+	if timing.Total != time.Second {
+		t.Errorf("Expected Total 1s, got %v", timing.Total)
+	}
+	if timing.TimeToFirstToken != 100*time.Millisecond {
+		t.Errorf("Expected TimeToFirstToken 100ms, got %v", timing.TimeToFirstToken)
+	}
+	if timing.API != 600*time.Millisecond {
+		t.Errorf("Expected API 600ms, got %v", timing.API)
+	}
+	// The tools overlap from 200ms to 700ms
+	if timing.Tools != 500*time.Millisecond || timing.Model != 500*time.Millisecond {
+		t.Errorf("Expected Tools and Model of 500ms, got %v and %v", timing.Tools, timing.Model)
+	}
+	want := []ToolTiming{
+		{ToolUseID: "a", Name: "Read", Duration: 300 * time.Millisecond},
+		{ToolUseID: "b", Name: "Bash", Duration: 500 * time.Millisecond},
+	}
+	if len(timing.ToolCalls) != 2 || timing.ToolCalls[0] != want[0] || timing.ToolCalls[1] != want[1] {
+		t.Errorf("Expected tool calls %v, got %v", want, timing.ToolCalls)
+	}
+	if timer.lastTiming() != timing {
+		t.Error("Expected the timing to be kept as the last turn's")
+	}
+}
+
+func TestTurnTimer_FirstTokenWithoutStreaming(t *testing.T) {
+	clock, advance := fakeClock()
+	timer := &turnTimer{now: clock}
+
+	timer.begin()
+	advance(250 * time.Millisecond)
+	timer.observe(&AssistantMessage{Content: []ContentBlock{TextBlock{Text: "Hi"}}})
+	advance(50 * time.Millisecond)
+
+	result := &ResultMessage{}
+	timer.finish(result)
+	if result.Timing.TimeToFirstToken != 250*time.Millisecond {
+		t.Errorf("Expected TimeToFirstToken 250ms, got %v", result.Timing.TimeToFirstToken)
+	}
+	if result.Timing.Tools != 0 || result.Timing.Model != 300*time.Millisecond {
+		t.Errorf("Expected all time spent on the model, got %+v", result.Timing)
+	}
+}
+
+func TestSpanUnion(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	span := func(start, end int) timeSpan {
+		return timeSpan{start: base.Add(time.Duration(start) * time.Second), end: base.Add(time.Duration(end) * time.Second)}
+	}
+
+	tests := []struct {
+		name  string
+		spans []timeSpan
+		want  time.Duration
+	}{
+		{"empty", nil, 0},
+		{"disjoint", []timeSpan{span(5, 6), span(0, 2)}, 3 * time.Second},
+		{"nested", []timeSpan{span(0, 10), span(2, 3)}, 10 * time.Second},
+		{"touching", []timeSpan{span(0, 2), span(2, 4)}, 4 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := spanUnion(tt.spans); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestClient_LastTurnTiming(t *testing.T) {
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+read line
+echo '{"type":"assistant","message":{"model":"m","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{}}]}}'
+sleep 0.1
+echo '{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"ok"}]}}'
+echo '{"type":"result","subtype":"success","duration_api_ms":20,"session_id":"s"}'
+read line
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if client.LastTurnTiming() != nil {
+		t.Error("Expected no timing before a turn")
+	}
+	if err := client.Query(ctx, "Hello"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	var result *ResultMessage
+	for msg := range client.ReceiveResponse(ctx) {
+		if r, ok := msg.(*ResultMessage); ok {
+			result = r
+		}
+	}
+	if result == nil || result.Timing == nil {
+		t.Fatal("Expected a result with timing")
+	}
+
+	timing := client.LastTurnTiming()
+	if timing != result.Timing {
+		t.Error("Expected LastTurnTiming to match the result")
+	}
+	if len(timing.ToolCalls) != 1 || timing.ToolCalls[0].Name != "Bash" || timing.ToolCalls[0].Duration < 50*time.Millisecond {
+		t.Errorf("Expected one Bash call of about 100ms, got %v", timing.ToolCalls)
+	}
+	if timing.API != 20*time.Millisecond || timing.Total < timing.Tools {
+		t.Errorf("Unexpected timing: %+v", timing)
+	}
+}
+
+func TestQuery_Timing(t *testing.T) {
+	cli := writeStubCLI(t, `
+echo '{"type":"assistant","message":{"model":"m","content":[{"type":"text","text":"Hi"}]}}'
+echo '{"type":"result","subtype":"success","duration_api_ms":5,"session_id":"s"}'
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	messages, errs := Query(ctx, "Hello", WithCLIPath(cli))
+	var result *ResultMessage
+	for msg := range messages {
+		if r, ok := msg.(*ResultMessage); ok {
+			result = r
+		}
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if result == nil || result.Timing == nil {
+		t.Fatal("Expected a result with timing")
+	}
+	if result.Timing.API != 5*time.Millisecond || result.Timing.Total <= 0 || result.Timing.TimeToFirstToken > result.Timing.Total {
+		t.Errorf("Unexpected timing: %+v", result.Timing)
+	}
+}
@@ -0,0 +1,155 @@
+package claude
+
+import (
+	"context"
+	"sync"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/protocol"
+)
+
+// ToolCallInfo describes the session a callback runs for. It is available
+// to MCP tool handlers, hook callbacks and CanUseTool callbacks through
+// ToolCallInfoFromContext, so they can log and scope their side effects
+// per session.
+type ToolCallInfo struct {
+	// SessionID is the ID of the CLI session, once the CLI has reported
+	// it.
+	SessionID string
+
+	// Cwd is the working directory of the session.
+	Cwd string
+
+	// PermissionMode is the permission mode in effect, including changes
+	// made with Client.SetPermissionMode.
+	PermissionMode PermissionMode
+
+	// ToolUseID is the ID of the tool use the callback runs for, or ""
+	// if the CLI did not pass one.
+	ToolUseID string
+}
+
+// toolCallInfoKey is the context key for the session part of ToolCallInfo.
+type toolCallInfoKey struct{}
+
+// ToolCallInfoFromContext returns the information about the session and
+// tool use that a callback runs for. It reports false for contexts that
+// do not come from the SDK.
+//
+// Example:
+//
+//	func(ctx context.Context, args map[string]any) (claude.MCPToolResult, error) {
+//		info, _ := claude.ToolCallInfoFromContext(ctx)
+//		log.Printf("session %s: tool use %s", info.SessionID, info.ToolUseID)
+//		...
+//	}
+func ToolCallInfoFromContext(ctx context.Context) (ToolCallInfo, bool) {
+	info, ok := ctx.Value(toolCallInfoKey{}).(ToolCallInfo)
+	if id := protocol.ToolUseIDFromContext(ctx); id != "" {
+		info.ToolUseID = id
+		ok = true
+	}
+	return info, ok
+}
+
+// sessionInfo tracks the session details passed to callbacks in
+// ToolCallInfo.
+type sessionInfo struct {
+	mu   sync.Mutex
+	info ToolCallInfo
+
+	// modeChanges are the permission mode changes not yet emitted on the
+	// message stream, and notify is signalled when one is added.
+	modeChanges []*PermissionModeChanged
+	notify      chan struct{}
+}
+
+func newSessionInfo(o *Options) *sessionInfo {
+	return &sessionInfo{
+		info: ToolCallInfo{
+			SessionID:      o.Resume,
+			Cwd:            o.Cwd,
+			PermissionMode: o.PermissionMode,
+		},
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// observe records the session details the CLI reports.
+func (s *sessionInfo) observe(msg Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch m := msg.(type) {
+	case *SystemMessage:
+		if m.Subtype == "init" {
+			if id, ok := m.Data["session_id"].(string); ok && id != "" {
+				s.info.SessionID = id
+			}
+			if cwd, ok := m.Data["cwd"].(string); ok && cwd != "" {
+				s.info.Cwd = cwd
+			}
+		}
+		// Any system message may report the mode, as the CLI does after
+		// a hook or the user changes it
+		if mode, ok := m.Data["permissionMode"].(string); ok && mode != "" {
+			s.changeMode(PermissionMode(mode), PermissionModeSourceCLI)
+		}
+	case *ResultMessage:
+		if m.SessionID != "" {
+			s.info.SessionID = m.SessionID
+		}
+	}
+}
+
+// setPermissionMode records a permission mode change made by the SDK,
+// with Client.SetPermissionMode or on approving a plan.
+func (s *sessionInfo) setPermissionMode(mode PermissionMode, source PermissionModeSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changeMode(mode, source)
+}
+
+// changeMode records the permission mode in effect, queueing a
+// PermissionModeChanged if it differs from a mode known before. s.mu must
+// be held.
+func (s *sessionInfo) changeMode(mode PermissionMode, source PermissionModeSource) {
+	previous := s.info.PermissionMode
+	s.info.PermissionMode = mode
+	if previous == "" || previous == mode {
+		return
+	}
+	s.modeChanges = append(s.modeChanges, &PermissionModeChanged{
+		Mode:      mode,
+		Previous:  previous,
+		Source:    source,
+		SessionID: s.info.SessionID,
+	})
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// takeModeChanges returns and clears the queued permission mode changes.
+func (s *sessionInfo) takeModeChanges() []*PermissionModeChanged {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changes := s.modeChanges
+	s.modeChanges = nil
+	return changes
+}
+
+// permissionMode returns the permission mode in effect.
+func (s *sessionInfo) permissionMode() PermissionMode {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.info.PermissionMode
+}
+
+// context returns a copy of ctx carrying the session details.
+func (s *sessionInfo) context(ctx context.Context) context.Context {
+	s.mu.Lock()
+	info := s.info
+	s.mu.Unlock()
+	return context.WithValue(ctx, toolCallInfoKey{}, info)
+}
@@ -0,0 +1,182 @@
+package claude
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestToolCallInfoFromContext_Missing(t *testing.T) {
+	if _, ok := ToolCallInfoFromContext(context.Background()); ok {
+		t.Error("Expected no ToolCallInfo on a plain context")
+	}
+}
+
+func TestSessionInfo(t *testing.T) {
+	session := newSessionInfo(NewOptions(
+		WithCwd("/start"),
+		WithPermissionMode(PermissionModeDefault),
+		WithResume("resumed"),
+	))
+
+	info, ok := ToolCallInfoFromContext(session.context(context.Background()))
+	if !ok || info.SessionID != "resumed" || info.Cwd != "/start" || info.PermissionMode != PermissionModeDefault {
+		t.Errorf("Expected details from options, got %+v", info)
+	}
+
+	session.observe(&SystemMessage{Subtype: "init", Data: map[string]any{
+		"session_id":     "sess-1",
+		"cwd":            "/work",
+		"permissionMode": "plan",
+	}})
+	session.setPermissionMode(PermissionModeAcceptEdits, PermissionModeSourceClient)
+
+	info, _ = ToolCallInfoFromContext(session.context(context.Background()))
+	if info.SessionID != "sess-1" || info.Cwd != "/work" || info.PermissionMode != PermissionModeAcceptEdits {
+		t.Errorf("Expected details reported by the CLI, got %+v", info)
+	}
+}
+
+func TestSessionInfo_ModeChanges(t *testing.T) {
+	session := newSessionInfo(NewOptions())
+
+	// The mode the session starts with is not a change
+	session.observe(&SystemMessage{Subtype: "init", Data: map[string]any{"session_id": "sess-1", "permissionMode": "default"}})
+	if changes := session.takeModeChanges(); len(changes) != 0 {
+		t.Fatalf("Expected no changes, got %+v", changes)
+	}
+
+	session.setPermissionMode(PermissionModeAcceptEdits, PermissionModeSourceClient)
+	session.observe(&SystemMessage{Subtype: "status", Data: map[string]any{"permissionMode": "acceptEdits"}})
+	session.observe(&SystemMessage{Subtype: "status", Data: map[string]any{"permissionMode": "plan"}})
+
+	select {
+	case <-session.notify:
+	default:
+		t.Error("Expected a notification")
+	}
+	changes := session.takeModeChanges()
+	want := []PermissionModeChanged{
+		{Mode: PermissionModeAcceptEdits, Previous: PermissionModeDefault, Source: PermissionModeSourceClient, SessionID: "sess-1"},
+		{Mode: PermissionModePlan, Previous: PermissionModeAcceptEdits, Source: PermissionModeSourceCLI, SessionID: "sess-1"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Expected %d changes, got %+v", len(want), changes)
+	}
+	for i := range want {
+		if *changes[i] != want[i] {
+			t.Errorf("Change %d: expected %+v, got %+v", i, want[i], *changes[i])
+		}
+	}
+	if session.permissionMode() != PermissionModePlan {
+		t.Errorf("Expected mode plan, got %s", session.permissionMode())
+	}
+	if changes := session.takeModeChanges(); len(changes) != 0 {
+		t.Errorf("Expected changes to be cleared, got %+v", changes)
+	}
+}
+
+func TestClient_PermissionModeChanged(t *testing.T) {
+	cli := writeStubCLI(t, `
+for i in 1 2; do
+  read line
+  id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+  echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+done
+read line
+echo '{"type":"system","subtype":"init","session_id":"sess-1","permissionMode":"acceptEdits"}'
+echo '{"type":"system","subtype":"status","session_id":"sess-1","permissionMode":"plan"}'
+echo '{"type":"result","subtype":"success","session_id":"sess-1"}'
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli), WithPermissionMode(PermissionModeDefault))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.SetPermissionMode(ctx, PermissionModeAcceptEdits); err != nil {
+		t.Fatalf("SetPermissionMode failed: %v", err)
+	}
+	if err := client.Query(ctx, "Hello"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	var changes []*PermissionModeChanged
+	for msg := range client.Messages() {
+		if change, ok := msg.(*PermissionModeChanged); ok {
+			changes = append(changes, change)
+		}
+		if _, ok := msg.(*ResultMessage); ok {
+			break
+		}
+	}
+
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %+v", changes)
+	}
+	if changes[0].Mode != PermissionModeAcceptEdits || changes[0].Source != PermissionModeSourceClient {
+		t.Errorf("Expected the change made by the client first, got %+v", changes[0])
+	}
+	if changes[1].Mode != PermissionModePlan || changes[1].Previous != PermissionModeAcceptEdits || changes[1].Source != PermissionModeSourceCLI {
+		t.Errorf("Expected the change reported by the CLI, got %+v", changes[1])
+	}
+	if client.PermissionMode() != PermissionModePlan {
+		t.Errorf("Expected mode plan, got %s", client.PermissionMode())
+	}
+}
+
+func TestClient_ToolCallInfo(t *testing.T) {
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+read line
+echo '{"type":"system","subtype":"init","session_id":"sess-1","cwd":"/work","permissionMode":"acceptEdits"}'
+echo '{"type":"result","subtype":"success","session_id":"sess-1"}'
+read line
+echo '{"type":"control_request","request_id":"req-1","request":{"subtype":"mcp_message","server_name":"tools","message":{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"whoami","arguments":{},"_meta":{"claudecode/toolUseId":"toolu_1"}}}}}'
+read line
+echo '{"type":"result","subtype":"success","session_id":"sess-1"}'
+`)
+
+	infos := make(chan ToolCallInfo, 1)
+	whoami := Tool("whoami", "Report the session", nil, func(ctx context.Context, args map[string]any) (MCPToolResult, error) {
+		info, _ := ToolCallInfoFromContext(ctx)
+		infos <- info
+		return TextResult(info.SessionID), nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli), WithSdkMcpServer("tools", NewMCPServer("tools", "1.0.0", []MCPTool{whoami})))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	for range 2 {
+		if err := client.Query(ctx, "Hello"); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		for msg := range client.Messages() {
+			if _, ok := msg.(*ResultMessage); ok {
+				break
+			}
+		}
+	}
+
+	select {
+	case info := <-infos:
+		want := ToolCallInfo{SessionID: "sess-1", Cwd: "/work", PermissionMode: PermissionModeAcceptEdits, ToolUseID: "toolu_1"}
+		if info != want {
+			t.Errorf("Expected %+v, got %+v", want, info)
+		}
+	case <-ctx.Done():
+		t.Fatal("Tool was not called")
+	}
+}
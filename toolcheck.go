@@ -0,0 +1,108 @@
+package claude
+
+import (
+	"slices"
+	"strings"
+)
+
+// builtinTools lists the built-in tools the SDK defines constants for.
+var builtinTools = []ToolName{
+	ToolBash, ToolBashOutput, ToolKillShell, ToolRead, ToolWrite, ToolEdit,
+	ToolMultiEdit, ToolNotebookEdit, ToolGlob, ToolGrep, ToolWebFetch,
+	ToolWebSearch, ToolTask, ToolTodoWrite, ToolExitPlanMode,
+	ToolListMcpResources, ToolReadMcpResource,
+}
+
+// checkTools compares the tools and MCP servers named in the options with
+// those the CLI reports in the init system message msg. It returns a
+// ToolMismatchError listing the differences, or nil if there are none.
+//
+// A tool named in WithTools or WithAllowedTools is unknown if the CLI does
+// not offer it. Disallowed tools are left out of what the CLI reports, so
+// one named in WithDisallowedTools is unknown only if it is neither a
+// built-in tool nor a tool of a connected MCP server. Tools of an MCP
+// server that is not connected are not reported, the server is.
+func checkTools(o *Options, msg *SystemMessage) error {
+	var reported []string
+	tools, _ := msg.Data["tools"].([]any)
+	for _, t := range tools {
+		if name, ok := t.(string); ok {
+			reported = append(reported, name)
+		}
+	}
+	connected := make(map[string]bool)
+	servers, _ := msg.Data["mcp_servers"].([]any)
+	for _, s := range servers {
+		server, _ := s.(map[string]any)
+		name, _ := server["name"].(string)
+		if status, _ := server["status"].(string); name != "" && status == "connected" {
+			connected[name] = true
+		}
+	}
+
+	var missing []string
+	if configured, ok := o.MCPServers.(map[string]MCPServerConfig); ok {
+		for name := range configured {
+			if !connected[name] {
+				missing = append(missing, name)
+			}
+		}
+	}
+	slices.Sort(missing)
+
+	var unknown []string
+	seen := make(map[string]bool)
+	check := func(rule string, disallowed bool) {
+		name, _, _ := strings.Cut(rule, "(")
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			return
+		}
+		seen[name] = true
+
+		if server, tool, ok := splitMCPTool(name); ok {
+			if !connected[server] {
+				if !slices.Contains(missing, server) {
+					unknown = append(unknown, name)
+				}
+				return
+			}
+			if tool == "" || tool == "*" || disallowed {
+				return
+			}
+		} else if disallowed && slices.Contains(builtinTools, ToolName(name)) {
+			return
+		}
+		if !slices.Contains(reported, name) {
+			unknown = append(unknown, name)
+		}
+	}
+	if base, ok := o.Tools.([]string); ok {
+		for _, rule := range base {
+			check(rule, false)
+		}
+	}
+	for _, rule := range allowedTools(o) {
+		check(rule, false)
+	}
+	for _, rule := range o.DisallowedTools {
+		check(rule, true)
+	}
+
+	if len(unknown) == 0 && len(missing) == 0 {
+		return nil
+	}
+	return NewToolMismatchError(unknown, missing)
+}
+
+// splitMCPTool splits an MCP tool name of the form "mcp__<server>__<tool>"
+// or "mcp__<server>" into its server and tool. It reports false if name is
+// not an MCP tool name.
+func splitMCPTool(name string) (server, tool string, ok bool) {
+	rest, ok := strings.CutPrefix(name, mcpToolPrefix)
+	if !ok || rest == "" {
+		return "", "", false
+	}
+	server, tool, _ = strings.Cut(rest, "__")
+	return server, tool, true
+}
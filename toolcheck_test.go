@@ -0,0 +1,91 @@
+package claude
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCheckTools(t *testing.T) {
+	msg := &SystemMessage{Subtype: "init", Data: map[string]any{
+		"tools": []any{"Read", "Grep", "mcp__calc__add", "mcp__calc__multiply"},
+		"mcp_servers": []any{
+			map[string]any{"name": "calc", "status": "connected"},
+			map[string]any{"name": "search", "status": "failed"},
+		},
+	}}
+	servers := map[string]MCPServerConfig{
+		"calc":   MCPStdioServerConfig{Command: "calc"},
+		"search": MCPStdioServerConfig{Command: "search"},
+		"docs":   MCPStdioServerConfig{Command: "docs"},
+	}
+
+	tests := []struct {
+		name        string
+		opts        []Option
+		wantUnknown []string
+		wantMissing []string
+	}{
+		{"all known", []Option{WithAllowedTools([]string{"Read", "Grep(*.go)", "mcp__calc", "mcp__calc__add"})}, nil, nil},
+		{"typo", []Option{WithAllowedTools([]string{"Raed", "mcp__calc__ad"})}, []string{"Raed", "mcp__calc__ad"}, nil},
+		{"base set", []Option{WithTools([]string{"Read", "Gerp"})}, []string{"Gerp"}, nil},
+		{"disallowed", []Option{WithDisallowedTools([]string{"Bash(rm:*)", "mcp__calc__divide", "Bsh"})}, []string{"Bsh"}, nil},
+		{"unknown server", []Option{WithAllowedTools([]string{"mcp__calcs__add"})}, []string{"mcp__calcs__add"}, nil},
+		{"missing servers", []Option{WithMCPServers(servers), WithAllowedTools([]string{"mcp__search__query"})}, nil, []string{"docs", "search"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkTools(NewOptions(tt.opts...), msg)
+			if tt.wantUnknown == nil && tt.wantMissing == nil {
+				if err != nil {
+					t.Fatalf("Expected no mismatch, got %v", err)
+				}
+				return
+			}
+			mismatch, ok := AsToolMismatchError(err)
+			if !ok {
+				t.Fatalf("Expected ToolMismatchError, got %v", err)
+			}
+			if !reflect.DeepEqual(mismatch.Unknown, tt.wantUnknown) || !reflect.DeepEqual(mismatch.MissingMCPServers, tt.wantMissing) {
+				t.Errorf("Expected unknown %v and missing %v, got %v and %v",
+					tt.wantUnknown, tt.wantMissing, mismatch.Unknown, mismatch.MissingMCPServers)
+			}
+		})
+	}
+}
+
+func TestClient_ToolMismatch(t *testing.T) {
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+read line
+echo '{"type":"system","subtype":"init","session_id":"s","tools":["Read","Grep"],"mcp_servers":[]}'
+echo '{"type":"result","subtype":"success","is_error":false,"session_id":"s"}'
+cat > /dev/null
+`)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli), WithAllowedTools([]string{"Read", "Grpe"}))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.Query(ctx, "hello"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	for range client.ReceiveResponse(ctx) {
+	}
+	select {
+	case err := <-client.Errors():
+		mismatch, ok := AsToolMismatchError(err)
+		if !ok || !reflect.DeepEqual(mismatch.Unknown, []string{"Grpe"}) {
+			t.Errorf("Expected a ToolMismatchError for Grpe, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a ToolMismatchError")
+	}
+}
@@ -0,0 +1,162 @@
+package claude
+
+import "encoding/json"
+
+// toolInputKey identifies a content block across interleaved streams of
+// the main agent and subagents.
+type toolInputKey struct {
+	parentToolUseID string
+	index           int
+}
+
+// toolInputAccumulator builds IncrementalToolUse messages from the
+// input_json_delta stream events of partial streaming.
+type toolInputAccumulator struct {
+	pending map[toolInputKey]*IncrementalToolUse
+}
+
+func newToolInputAccumulator() *toolInputAccumulator {
+	return &toolInputAccumulator{pending: make(map[toolInputKey]*IncrementalToolUse)}
+}
+
+// observe returns the progress of a tool use updated by event, or nil if
+// event does not concern a tool use.
+func (a *toolInputAccumulator) observe(event *StreamEvent) *IncrementalToolUse {
+	eventType, _ := event.Event["type"].(string)
+	index, _ := event.Event["index"].(float64)
+	key := toolInputKey{parentToolUseID: event.ParentToolUseID, index: int(index)}
+
+	switch eventType {
+	case "message_start":
+		for k := range a.pending {
+			if k.parentToolUseID == event.ParentToolUseID {
+				delete(a.pending, k)
+			}
+		}
+		return nil
+
+	case "content_block_start":
+		block, _ := event.Event["content_block"].(map[string]any)
+		if block["type"] != "tool_use" {
+			return nil
+		}
+		use := &IncrementalToolUse{
+			Index:           key.index,
+			Input:           map[string]any{},
+			SessionID:       event.SessionID,
+			ParentToolUseID: event.ParentToolUseID,
+		}
+		use.ID, _ = block["id"].(string)
+		use.Name, _ = block["name"].(string)
+		a.pending[key] = use
+		return use.snapshot()
+
+	case "content_block_delta":
+		use, ok := a.pending[key]
+		delta, _ := event.Event["delta"].(map[string]any)
+		if !ok || delta["type"] != "input_json_delta" {
+			return nil
+		}
+		partial, _ := delta["partial_json"].(string)
+		use.PartialInput += partial
+		if input, ok := parsePartialJSON(use.PartialInput); ok {
+			use.Input = input
+		}
+		return use.snapshot()
+
+	case "content_block_stop":
+		use, ok := a.pending[key]
+		if !ok {
+			return nil
+		}
+		delete(a.pending, key)
+		use.Done = true
+		if input, ok := parsePartialJSON(use.PartialInput); ok {
+			use.Input = input
+		}
+		return use
+	}
+	return nil
+}
+
+// snapshot returns a copy of u that later deltas do not modify.
+func (u *IncrementalToolUse) snapshot() *IncrementalToolUse {
+	copied := *u
+	return &copied
+}
+
+// parsePartialJSON parses the prefix of a JSON object, closing any open
+// string, array, and object. If closing them is not enough, the incomplete
+// trailing key or value is dropped.
+func parsePartialJSON(partial string) (map[string]any, bool) {
+	stack, inString, escaped, cut := scanJSON(partial)
+
+	closed := partial
+	if inString {
+		if escaped {
+			closed = closed[:len(closed)-1]
+		}
+		closed += `"`
+	}
+	if input, ok := unmarshalObject(closed + closers(stack)); ok {
+		return input, true
+	}
+
+	// Drop the incomplete member and close what remains
+	stack, _, _, _ = scanJSON(partial[:cut])
+	return unmarshalObject(partial[:cut] + closers(stack))
+}
+
+// scanJSON scans a JSON prefix. It returns the closers still expected,
+// whether the prefix ends inside a string and after a backslash, and the
+// offset at which the last member or element started.
+func scanJSON(prefix string) (stack []byte, inString, escaped bool, cut int) {
+	for i := 0; i < len(prefix); i++ {
+		c := prefix[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			stack = append(stack, '}')
+			cut = i + 1
+		case '[':
+			stack = append(stack, ']')
+			cut = i + 1
+		case '}', ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case ',':
+			cut = i
+		}
+	}
+	return stack, inString, escaped, cut
+}
+
+// closers returns the closing characters for stack, innermost first.
+func closers(stack []byte) string {
+	out := make([]byte, len(stack))
+	for i := range stack {
+		out[i] = stack[len(stack)-1-i]
+	}
+	return string(out)
+}
+
+func unmarshalObject(data string) (map[string]any, bool) {
+	var object map[string]any
+	if err := json.Unmarshal([]byte(data), &object); err != nil || object == nil {
+		return nil, false
+	}
+	return object, true
+}
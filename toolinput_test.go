@@ -0,0 +1,141 @@
+package claude
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePartialJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		partial string
+		want    map[string]any
+		ok      bool
+	}{
+		{"empty", "", nil, false},
+		{"open object", "{", map[string]any{}, true},
+		{"partial key", `{"file_pa`, map[string]any{}, true},
+		{"missing value", `{"file_path":`, map[string]any{}, true},
+		{"partial string", `{"file_path":"/tmp/a`, map[string]any{"file_path": "/tmp/a"}, true},
+		{"dangling escape", `{"content":"line\`, map[string]any{"content": "line"}, true},
+		{"escaped quote", `{"content":"say \"hi`, map[string]any{"content": `say "hi`}, true},
+		{"partial literal", `{"a":1,"b":tr`, map[string]any{"a": 1.0}, true},
+		{"nested", `{"edits":[{"old":"x","new":"y"},{"old":"z`, map[string]any{
+			"edits": []any{
+				map[string]any{"old": "x", "new": "y"},
+				map[string]any{"old": "z"},
+			},
+		}, true},
+		{"nested partial key", `{"opts":{"a":1,"b`, map[string]any{"opts": map[string]any{"a": 1.0}}, true},
+		{"complete", `{"a":[1,2]}`, map[string]any{"a": []any{1.0, 2.0}}, true},
+		{"not an object", `[1,2`, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parsePartialJSON(tt.partial)
+			if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, %v, got %v, %v", tt.want, tt.ok, got, ok)
+			}
+		})
+	}
+}
+
+func streamEvent(event map[string]any) *StreamEvent {
+	return &StreamEvent{SessionID: "s-1", Event: event}
+}
+
+func TestToolInputAccumulator(t *testing.T) {
+	a := newToolInputAccumulator()
+
+	if use := a.observe(streamEvent(map[string]any{"type": "message_start"})); use != nil {
+		t.Errorf("Expected nothing for message_start, got %+v", use)
+	}
+	text := map[string]any{"type": "content_block_start", "index": 0.0, "content_block": map[string]any{"type": "text"}}
+	if use := a.observe(streamEvent(text)); use != nil {
+		t.Errorf("Expected nothing for a text block, got %+v", use)
+	}
+
+	start := a.observe(streamEvent(map[string]any{
+		"type":          "content_block_start",
+		"index":         1.0,
+		"content_block": map[string]any{"type": "tool_use", "id": "tool-1", "name": "Write", "input": map[string]any{}},
+	}))
+	if start == nil || start.ID != "tool-1" || start.Name != "Write" || start.Index != 1 || start.Done {
+		t.Fatalf("Unexpected start: %+v", start)
+	}
+
+	delta := func(partial string) *IncrementalToolUse {
+		return a.observe(streamEvent(map[string]any{
+			"type":  "content_block_delta",
+			"index": 1.0,
+			"delta": map[string]any{"type": "input_json_delta", "partial_json": partial},
+		}))
+	}
+
+	first := delta(`{"file_path":"/tmp/a","con`)
+	if first == nil || first.Input["file_path"] != "/tmp/a" {
+		t.Fatalf("Unexpected first delta: %+v", first)
+	}
+	second := delta(`tent":"hi"}`)
+	if second.PartialInput != `{"file_path":"/tmp/a","content":"hi"}` || second.Input["content"] != "hi" {
+		t.Errorf("Unexpected second delta: %+v", second)
+	}
+	if first.PartialInput == second.PartialInput {
+		t.Error("Expected earlier messages to be unchanged by later deltas")
+	}
+
+	stop := a.observe(streamEvent(map[string]any{"type": "content_block_stop", "index": 1.0}))
+	if stop == nil || !stop.Done || stop.Input["content"] != "hi" || stop.SessionID != "s-1" {
+		t.Errorf("Unexpected stop: %+v", stop)
+	}
+	if use := a.observe(streamEvent(map[string]any{"type": "content_block_stop", "index": 1.0})); use != nil {
+		t.Errorf("Expected nothing for an unknown block, got %+v", use)
+	}
+}
+
+func TestClient_IncrementalToolUse(t *testing.T) {
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+read line
+echo '{"type":"stream_event","uuid":"e-1","session_id":"s-1","event":{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"tool-1","name":"Bash","input":{}}}}'
+echo '{"type":"stream_event","uuid":"e-2","session_id":"s-1","event":{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"command\":\"ls -"}}}'
+echo '{"type":"stream_event","uuid":"e-3","session_id":"s-1","event":{"type":"content_block_stop","index":0}}'
+echo '{"type":"result","subtype":"success","session_id":"s-1"}'
+cat > /dev/null
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli), WithIncludePartialMessages(true))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.Query(ctx, "List files"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	var uses []*IncrementalToolUse
+	for msg := range client.ReceiveResponse(ctx) {
+		if use, ok := msg.(*IncrementalToolUse); ok {
+			uses = append(uses, use)
+		}
+	}
+
+	if len(uses) != 3 {
+		t.Fatalf("Expected 3 incremental tool uses, got %d", len(uses))
+	}
+	if uses[1].Input["command"] != "ls -" {
+		t.Errorf("Expected partial command, got %v", uses[1].Input)
+	}
+	if !uses[2].Done {
+		t.Error("Expected the last update to be done")
+	}
+}
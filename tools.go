@@ -0,0 +1,152 @@
+package claude
+
+import (
+	"strings"
+	"time"
+)
+
+// ToolName is the name of a tool Claude can use, as accepted by
+// WithTools, WithAllowedTools, and WithDisallowedTools.
+type ToolName string
+
+// Built-in Claude Code tools.
+const (
+	ToolBash             ToolName = "Bash"
+	ToolBashOutput       ToolName = "BashOutput"
+	ToolKillShell        ToolName = "KillShell"
+	ToolRead             ToolName = "Read"
+	ToolWrite            ToolName = "Write"
+	ToolEdit             ToolName = "Edit"
+	ToolMultiEdit        ToolName = "MultiEdit"
+	ToolNotebookEdit     ToolName = "NotebookEdit"
+	ToolGlob             ToolName = "Glob"
+	ToolGrep             ToolName = "Grep"
+	ToolWebFetch         ToolName = "WebFetch"
+	ToolWebSearch        ToolName = "WebSearch"
+	ToolTask             ToolName = "Task"
+	ToolTodoWrite        ToolName = "TodoWrite"
+	ToolExitPlanMode     ToolName = "ExitPlanMode"
+	ToolListMcpResources ToolName = "ListMcpResourcesTool"
+	ToolReadMcpResource  ToolName = "ReadMcpResourceTool"
+)
+
+// mcpToolPrefix prefixes the names of tools provided by MCP servers.
+const mcpToolPrefix = "mcp__"
+
+// MCPToolRef returns the name of a tool provided by an MCP server, in the
+// "mcp__<server>__<tool>" form the CLI expects.
+//
+// Example:
+//
+//	claude.MCPToolRef("calc", "add") // "mcp__calc__add"
+func MCPToolRef(server, tool string) ToolName {
+	return ToolName(mcpToolPrefix + server + "__" + tool)
+}
+
+// MCPServerRef returns a name matching every tool provided by an MCP
+// server.
+func MCPServerRef(server string) ToolName {
+	return ToolName(mcpToolPrefix + server)
+}
+
+// IsMCP reports whether n names an MCP tool or server.
+func (n ToolName) IsMCP() bool {
+	return strings.HasPrefix(string(n), mcpToolPrefix)
+}
+
+// String returns the tool name.
+func (n ToolName) String() string {
+	return string(n)
+}
+
+// ToolNames converts tool names to the string slice accepted by
+// WithTools, WithAllowedTools, and WithDisallowedTools.
+func ToolNames(tools ...ToolName) []string {
+	names := make([]string, len(tools))
+	for i, tool := range tools {
+		names[i] = string(tool)
+	}
+	return names
+}
+
+// ToolSelection builds a list of tool names, mixing built-in and MCP tools.
+// Duplicates are dropped.
+//
+// Example:
+//
+//	tools := claude.NewToolSelection(claude.ToolRead, claude.ToolGrep).
+//	    AddMCP("calc", "add", "multiply")
+//	client := claude.NewClient(claude.WithAllowedTools(tools.Names()))
+type ToolSelection struct {
+	tools []ToolName
+	seen  map[ToolName]bool
+}
+
+// NewToolSelection creates a selection containing tools.
+func NewToolSelection(tools ...ToolName) *ToolSelection {
+	s := &ToolSelection{seen: make(map[ToolName]bool)}
+	return s.Add(tools...)
+}
+
+// Add adds tools to the selection.
+func (s *ToolSelection) Add(tools ...ToolName) *ToolSelection {
+	for _, tool := range tools {
+		if s.seen[tool] {
+			continue
+		}
+		s.seen[tool] = true
+		s.tools = append(s.tools, tool)
+	}
+	return s
+}
+
+// AddMCP adds tools provided by an MCP server. With no tools, every tool
+// of the server is added.
+func (s *ToolSelection) AddMCP(server string, tools ...string) *ToolSelection {
+	if len(tools) == 0 {
+		return s.Add(MCPServerRef(server))
+	}
+	for _, tool := range tools {
+		s.Add(MCPToolRef(server, tool))
+	}
+	return s
+}
+
+// Tools returns the selected tools in the order they were added.
+func (s *ToolSelection) Tools() []ToolName {
+	return append([]ToolName(nil), s.tools...)
+}
+
+// Names returns the selected tool names in the order they were added.
+func (s *ToolSelection) Names() []string {
+	return ToolNames(s.tools...)
+}
+
+// ToolStats holds usage statistics for one tool, as reported by
+// Client.ToolStats.
+type ToolStats struct {
+	// Calls is the number of completed invocations.
+	Calls int
+	// Failures is the number of invocations whose result was an error.
+	Failures int
+	// TotalDuration is the time from each tool use to its result, summed.
+	TotalDuration time.Duration
+	// OutputBytes is the size of all tool results.
+	OutputBytes int64
+}
+
+// AverageDuration returns the mean duration of an invocation.
+func (s ToolStats) AverageDuration() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.TotalDuration / time.Duration(s.Calls)
+}
+
+// FailureRate returns the fraction of invocations that failed.
+func (s ToolStats) FailureRate() float64 {
+	if s.Calls == 0 {
+		return 0
+	}
+	return float64(s.Failures) / float64(s.Calls)
+}
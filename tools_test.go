@@ -0,0 +1,66 @@
+package claude
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMCPToolRef(t *testing.T) {
+	if got := MCPToolRef("calc", "add"); got != "mcp__calc__add" {
+		t.Errorf("Expected 'mcp__calc__add', got %q", got)
+	}
+	if got := MCPServerRef("calc"); got != "mcp__calc" {
+		t.Errorf("Expected 'mcp__calc', got %q", got)
+	}
+	if !MCPToolRef("calc", "add").IsMCP() || ToolBash.IsMCP() {
+		t.Error("Expected only MCP names to report IsMCP")
+	}
+}
+
+func TestToolNames(t *testing.T) {
+	got := ToolNames(ToolRead, ToolBash)
+	want := []string{"Read", "Bash"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestToolSelection(t *testing.T) {
+	sel := NewToolSelection(ToolRead, ToolGrep).
+		AddMCP("calc", "add", "multiply").
+		AddMCP("weather").
+		Add(ToolRead, ToolWebFetch)
+
+	want := []string{"Read", "Grep", "mcp__calc__add", "mcp__calc__multiply", "mcp__weather", "WebFetch"}
+	if got := sel.Names(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	opts := NewOptions(WithAllowedTools(sel.Names()))
+	if len(opts.AllowedTools) != len(want) {
+		t.Errorf("Expected %d allowed tools, got %v", len(want), opts.AllowedTools)
+	}
+
+	// Tools returns a copy
+	tools := sel.Tools()
+	tools[0] = ToolBash
+	if sel.Tools()[0] != ToolRead {
+		t.Error("Expected Tools to return a copy")
+	}
+}
+
+func TestToolStats_Derived(t *testing.T) {
+	var empty ToolStats
+	if empty.AverageDuration() != 0 || empty.FailureRate() != 0 {
+		t.Error("Expected zero averages with no calls")
+	}
+
+	stats := ToolStats{Calls: 4, Failures: 1, TotalDuration: 2 * time.Second}
+	if got := stats.AverageDuration(); got != 500*time.Millisecond {
+		t.Errorf("AverageDuration() = %v, want 500ms", got)
+	}
+	if got := stats.FailureRate(); got != 0.25 {
+		t.Errorf("FailureRate() = %v, want 0.25", got)
+	}
+}
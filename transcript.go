@@ -0,0 +1,279 @@
+package claude
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProjectTranscriptDir returns the directory the CLI keeps the transcripts
+// of sessions run in cwd in, under CLAUDE_CONFIG_DIR or ~/.claude. Pass it
+// to WithTranscriptDir for sessions to be listed by `claude --resume` run
+// in cwd on this machine.
+func ProjectTranscriptDir(cwd string) (string, error) {
+	abs, err := filepath.Abs(cwd)
+	if err != nil {
+		return "", err
+	}
+	configDir := os.Getenv("CLAUDE_CONFIG_DIR")
+	if configDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		configDir = filepath.Join(home, ".claude")
+	}
+	return filepath.Join(configDir, "projects", projectDirName(abs)), nil
+}
+
+// projectDirName names the project directory of cwd as the CLI does,
+// replacing every character but ASCII letters and digits with a dash.
+func projectDirName(cwd string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '-'
+	}, cwd)
+}
+
+// transcriptEntry is a line of a transcript, in the format of the CLI.
+type transcriptEntry struct {
+	ParentUUID    *string `json:"parentUuid"`
+	IsSidechain   bool    `json:"isSidechain"`
+	UserType      string  `json:"userType"`
+	Cwd           string  `json:"cwd"`
+	SessionID     string  `json:"sessionId"`
+	Version       string  `json:"version,omitempty"`
+	Type          string  `json:"type"`
+	Message       any     `json:"message"`
+	ToolUseResult any     `json:"toolUseResult,omitempty"`
+	UUID          string  `json:"uuid"`
+	Timestamp     string  `json:"timestamp"`
+}
+
+// transcriptWriter writes the conversation of a session to a JSONL
+// transcript in the CLI's format, as messages arrive. Prompts are recorded
+// when sent, and the messages of the CLI as they are read; entries made
+// before the session ID is known are held until it is. A new file is
+// started when the session ID changes, such as on a fork. A nil
+// transcriptWriter records nothing.
+type transcriptWriter struct {
+	dir string
+	cwd string
+
+	mu        sync.Mutex
+	sessionID string
+	version   string
+	file      *os.File
+	lastUUID  string
+	pending   []*transcriptEntry
+	err       error
+}
+
+// newTranscriptWriter returns a writer for the transcript directory of
+// options, or nil if none is set.
+func newTranscriptWriter(options *Options) *transcriptWriter {
+	if options.TranscriptDir == "" {
+		return nil
+	}
+	cwd := options.Cwd
+	if abs, err := filepath.Abs(cwd); err == nil {
+		cwd = abs
+	}
+	return &transcriptWriter{dir: options.TranscriptDir, cwd: cwd}
+}
+
+// prompt records a user message sent to the CLI.
+func (w *transcriptWriter) prompt(message map[string]any) {
+	if w == nil {
+		return
+	}
+	uuid, _ := message["uuid"].(string)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.add(&transcriptEntry{Type: "user", Message: message["message"], UUID: uuid})
+}
+
+// observe records a message read from the CLI. Prompts echoed by the CLI
+// were recorded when sent, and stream events and results are not part of
+// transcripts.
+func (w *transcriptWriter) observe(data map[string]any) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if data["type"] == "system" && data["subtype"] == "init" {
+		w.version, _ = data["claude_code_version"].(string)
+	}
+	if sessionID, _ := data["session_id"].(string); sessionID != "" && sessionID != w.sessionID {
+		w.switchSession(sessionID)
+	}
+
+	switch data["type"] {
+	case "user", "assistant":
+		message, _ := data["message"].(map[string]any)
+		if data["type"] == "user" && isPromptContent(message["content"]) {
+			return
+		}
+		uuid, _ := data["uuid"].(string)
+		parentToolUseID, _ := data["parent_tool_use_id"].(string)
+		w.add(&transcriptEntry{
+			Type:          data["type"].(string),
+			Message:       message,
+			ToolUseResult: data["tool_use_result"],
+			UUID:          uuid,
+			IsSidechain:   parentToolUseID != "",
+		})
+	}
+}
+
+// isPromptContent reports whether the content of a user message is a
+// prompt rather than tool results.
+func isPromptContent(content any) bool {
+	blocks, ok := content.([]any)
+	if !ok {
+		return true
+	}
+	for _, block := range blocks {
+		if b, ok := block.(map[string]any); ok && b["type"] == "tool_result" {
+			return false
+		}
+	}
+	return true
+}
+
+// add completes entry and writes it, or holds it until the session ID is
+// known.
+func (w *transcriptWriter) add(entry *transcriptEntry) {
+	if entry.UUID == "" {
+		entry.UUID, _ = newUUID()
+	}
+	entry.UserType = "external"
+	entry.Cwd = w.cwd
+	entry.Timestamp = time.Now().UTC().Format("2006-01-02T15:04:05.000Z07:00")
+	if w.lastUUID != "" {
+		parent := w.lastUUID
+		entry.ParentUUID = &parent
+	}
+	w.lastUUID = entry.UUID
+
+	if w.sessionID == "" {
+		w.pending = append(w.pending, entry)
+		return
+	}
+	w.write(entry)
+}
+
+// switchSession starts writing the transcript of sessionID, appending to
+// it if it exists, and writes the entries held until then.
+func (w *transcriptWriter) switchSession(sessionID string) {
+	if w.file != nil {
+		_ = w.file.Close()
+		w.file = nil
+	}
+	w.sessionID = sessionID
+
+	path := filepath.Join(w.dir, sessionID+".jsonl")
+	if err := os.MkdirAll(w.dir, 0o700); err != nil {
+		w.fail(err)
+		return
+	}
+	if w.lastUUID == "" && len(w.pending) == 0 {
+		// Continue the chain of a resumed session
+		w.lastUUID = lastTranscriptUUID(path)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		w.fail(err)
+		return
+	}
+	w.file = file
+
+	for _, entry := range w.pending {
+		w.write(entry)
+	}
+	w.pending = nil
+}
+
+func (w *transcriptWriter) write(entry *transcriptEntry) {
+	if w.file == nil {
+		return
+	}
+	entry.SessionID = w.sessionID
+	entry.Version = w.version
+	data, err := json.Marshal(entry)
+	if err != nil {
+		w.fail(err)
+		return
+	}
+	if _, err := w.file.Write(append(data, '\n')); err != nil {
+		w.fail(err)
+	}
+}
+
+// fail records the first error, to be reported by takeErr.
+func (w *transcriptWriter) fail(err error) {
+	if w.err == nil {
+		w.err = WrapClaudeSDKError("failed to write transcript", err)
+	}
+}
+
+// takeErr returns the first error writing the transcript, once.
+func (w *transcriptWriter) takeErr() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.err
+	w.err = nil
+	return err
+}
+
+// close closes the transcript file, returning any error not yet taken.
+func (w *transcriptWriter) close() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			w.fail(err)
+		}
+		w.file = nil
+		// A later connection reopens the file
+		w.sessionID = ""
+	}
+	w.mu.Unlock()
+	return w.takeErr()
+}
+
+// lastTranscriptUUID returns the UUID of the last entry of the transcript
+// at path, if there is one.
+func lastTranscriptUUID(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = file.Close() }()
+
+	var last string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		var entry struct {
+			UUID string `json:"uuid"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil && entry.UUID != "" {
+			last = entry.UUID
+		}
+	}
+	return last
+}
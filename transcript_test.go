@@ -0,0 +1,205 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readTranscript reads the entries of a transcript file.
+func readTranscript(t *testing.T, path string) []map[string]any {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read transcript: %v", err)
+	}
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Invalid transcript line %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestProjectTranscriptDir(t *testing.T) {
+	t.Setenv("CLAUDE_CONFIG_DIR", "/config")
+
+	dir, err := ProjectTranscriptDir("/home/dev/my_app.v2")
+	if err != nil {
+		t.Fatalf("ProjectTranscriptDir failed: %v", err)
+	}
+	if want := filepath.Join("/config", "projects", "-home-dev-my-app-v2"); dir != want {
+		t.Errorf("Expected %s, got %s", want, dir)
+	}
+}
+
+func TestTranscriptWriter(t *testing.T) {
+	dir := t.TempDir()
+	w := newTranscriptWriter(NewOptions(WithTranscriptDir(dir), WithCwd("/work")))
+
+	// The prompt is held until the session ID is known
+	w.prompt(map[string]any{
+		"type":       "user",
+		"message":    map[string]any{"role": "user", "content": "List the files"},
+		"session_id": "default",
+	})
+	messages := []map[string]any{
+		{"type": "system", "subtype": "init", "session_id": "s1", "claude_code_version": "2.1.0"},
+		{"type": "stream_event", "session_id": "s1", "event": map[string]any{"type": "message_start"}},
+		{"type": "assistant", "session_id": "s1", "uuid": "a1", "message": map[string]any{
+			"role": "assistant", "model": "claude-sonnet-4-5",
+			"content": []any{map[string]any{"type": "tool_use", "id": "t1", "name": "Bash", "input": map[string]any{"command": "ls"}}},
+		}},
+		{"type": "user", "session_id": "s1", "uuid": "u1", "tool_use_result": map[string]any{"stdout": "go.mod"}, "message": map[string]any{
+			"role": "user", "content": []any{map[string]any{"type": "tool_result", "tool_use_id": "t1", "content": "go.mod"}},
+		}},
+		{"type": "assistant", "session_id": "s1", "uuid": "a2", "parent_tool_use_id": "t0", "message": map[string]any{
+			"role": "assistant", "model": "claude-sonnet-4-5", "content": []any{map[string]any{"type": "text", "text": "subagent"}},
+		}},
+		// Prompts echoed by the CLI were recorded when sent
+		{"type": "user", "session_id": "s1", "message": map[string]any{"role": "user", "content": "List the files"}},
+		{"type": "result", "subtype": "success", "session_id": "s1"},
+	}
+	for _, data := range messages {
+		w.observe(data)
+	}
+	if err := w.close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	entries := readTranscript(t, filepath.Join(dir, "s1.jsonl"))
+	if len(entries) != 4 {
+		t.Fatalf("Expected 4 entries, got %d: %v", len(entries), entries)
+	}
+	wantTypes := []string{"user", "assistant", "user", "assistant"}
+	for i, entry := range entries {
+		if entry["type"] != wantTypes[i] {
+			t.Errorf("Entry %d: expected type %s, got %v", i, wantTypes[i], entry["type"])
+		}
+		if entry["sessionId"] != "s1" || entry["cwd"] != "/work" || entry["version"] != "2.1.0" || entry["userType"] != "external" {
+			t.Errorf("Entry %d: unexpected session fields %v", i, entry)
+		}
+		if _, err := time.Parse(time.RFC3339, entry["timestamp"].(string)); err != nil {
+			t.Errorf("Entry %d: invalid timestamp: %v", i, err)
+		}
+	}
+	if entries[0]["parentUuid"] != nil {
+		t.Errorf("Expected the first entry to have no parent, got %v", entries[0]["parentUuid"])
+	}
+	for i := 1; i < len(entries); i++ {
+		if entries[i]["parentUuid"] != entries[i-1]["uuid"] {
+			t.Errorf("Entry %d: expected parent %v, got %v", i, entries[i-1]["uuid"], entries[i]["parentUuid"])
+		}
+	}
+	if entries[2]["toolUseResult"] == nil {
+		t.Error("Expected the tool result to be recorded")
+	}
+	if entries[3]["isSidechain"] != true || entries[1]["isSidechain"] != false {
+		t.Error("Expected only the subagent message to be a sidechain")
+	}
+
+	// Transcripts read back as messages
+	turn, err := readLastTurn(filepath.Join(dir, "s1.jsonl"))
+	if err != nil || len(turn) != 4 {
+		t.Fatalf("Expected the turn to read back, got %v, %v", turn, err)
+	}
+	if prompt, ok := turn[0].(*UserMessage); !ok || prompt.Content != "List the files" {
+		t.Errorf("Expected the prompt first, got %#v", turn[0])
+	}
+}
+
+func TestTranscriptWriter_Resume(t *testing.T) {
+	dir := t.TempDir()
+	w := newTranscriptWriter(NewOptions(WithTranscriptDir(dir)))
+	w.observe(map[string]any{"type": "assistant", "session_id": "s1", "uuid": "a1", "message": map[string]any{"role": "assistant"}})
+	_ = w.close()
+
+	// A later session resuming s1 continues its chain
+	w = newTranscriptWriter(NewOptions(WithTranscriptDir(dir)))
+	w.observe(map[string]any{"type": "system", "subtype": "init", "session_id": "s1"})
+	w.prompt(map[string]any{"message": map[string]any{"role": "user", "content": "Continue"}})
+	_ = w.close()
+
+	entries := readTranscript(t, filepath.Join(dir, "s1.jsonl"))
+	if len(entries) != 2 || entries[1]["parentUuid"] != "a1" {
+		t.Errorf("Expected the resumed prompt to follow a1, got %v", entries)
+	}
+
+	// A fork is written to its own file
+	w.observe(map[string]any{"type": "assistant", "session_id": "s2", "uuid": "a2", "message": map[string]any{"role": "assistant"}})
+	_ = w.close()
+	if entries := readTranscript(t, filepath.Join(dir, "s2.jsonl")); len(entries) != 1 {
+		t.Errorf("Expected 1 entry in the fork's transcript, got %v", entries)
+	}
+}
+
+func TestTranscriptWriter_Error(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	w := newTranscriptWriter(NewOptions(WithTranscriptDir(filepath.Join(file, "transcripts"))))
+	w.observe(map[string]any{"type": "system", "subtype": "init", "session_id": "s1"})
+
+	if err := w.takeErr(); err == nil || !strings.Contains(err.Error(), "failed to write transcript") {
+		t.Errorf("Expected a transcript error, got %v", err)
+	}
+	if err := w.takeErr(); err != nil {
+		t.Errorf("Expected the error to be reported once, got %v", err)
+	}
+
+	var none *transcriptWriter
+	none.observe(map[string]any{"type": "assistant"})
+	if err := none.close(); err != nil {
+		t.Errorf("Expected a nil writer to do nothing, got %v", err)
+	}
+}
+
+func TestClient_TranscriptDir(t *testing.T) {
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+while read -r line; do
+  case "$line" in
+  *'"type":"user"'*)
+    echo '{"type":"system","subtype":"init","session_id":"sess-1","claude_code_version":"2.1.0"}'
+    echo '{"type":"assistant","session_id":"sess-1","uuid":"a1","message":{"role":"assistant","model":"claude-sonnet-4-5","content":[{"type":"text","text":"Hi"}]}}'
+    echo '{"type":"result","subtype":"success","session_id":"sess-1"}'
+    ;;
+  esac
+done
+`)
+	dir := t.TempDir()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli), WithTranscriptDir(dir))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if err := client.Query(ctx, "Hello"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	for range client.ReceiveResponse(ctx) {
+	}
+	_ = client.Close()
+
+	entries := readTranscript(t, filepath.Join(dir, "sess-1.jsonl"))
+	if len(entries) != 2 {
+		t.Fatalf("Expected prompt and reply, got %v", entries)
+	}
+	if message := entries[0]["message"].(map[string]any); message["content"] != "Hello" {
+		t.Errorf("Expected the prompt first, got %v", entries[0])
+	}
+	if entries[1]["uuid"] != "a1" || entries[1]["parentUuid"] != entries[0]["uuid"] {
+		t.Errorf("Expected the reply to follow the prompt, got %v", entries[1])
+	}
+}
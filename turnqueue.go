@@ -0,0 +1,105 @@
+package claude
+
+import (
+	"context"
+	"sync"
+)
+
+// turnQueue runs the turns of a connection one at a time. Query and
+// QueryMessage acquire it before sending their message, in the order they
+// were called, and it is released once the turn's ResultMessage has been
+// delivered. It is closed when the connection ends, failing the calls
+// still waiting. A nil turnQueue lets every turn through.
+type turnQueue struct {
+	mu      sync.Mutex
+	busy    bool
+	closed  bool
+	waiters []chan error
+}
+
+func newTurnQueue() *turnQueue {
+	return &turnQueue{}
+}
+
+// acquire waits for the turns queued before it to end. If wait is false
+// and a turn is in progress, it returns a BusyError instead.
+func (q *turnQueue) acquire(ctx context.Context, wait bool) error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return NewCLIConnectionError("connection closed")
+	}
+	if !q.busy {
+		q.busy = true
+		q.mu.Unlock()
+		return nil
+	}
+	if !wait {
+		q.mu.Unlock()
+		return NewBusyError()
+	}
+	ready := make(chan error, 1)
+	q.waiters = append(q.waiters, ready)
+	q.mu.Unlock()
+
+	select {
+	case err := <-ready:
+		return err
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, w := range q.waiters {
+		if w == ready {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			return context.Cause(ctx)
+		}
+	}
+	// The turn was handed over as ctx was cancelled; pass it on
+	if err := <-ready; err == nil {
+		q.releaseLocked()
+	}
+	return context.Cause(ctx)
+}
+
+// release ends the turn in progress, handing it to the next waiting call.
+// It does nothing if no turn is in progress.
+func (q *turnQueue) release() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.releaseLocked()
+}
+
+func (q *turnQueue) releaseLocked() {
+	if !q.busy {
+		return
+	}
+	if len(q.waiters) == 0 {
+		q.busy = false
+		return
+	}
+	next := q.waiters[0]
+	q.waiters = q.waiters[1:]
+	next <- nil
+}
+
+// close fails the waiting calls and any later ones.
+func (q *turnQueue) close() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	for _, w := range q.waiters {
+		w <- NewCLIConnectionError("connection closed while waiting for the turn in progress")
+	}
+	q.waiters = nil
+}
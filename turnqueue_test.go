@@ -0,0 +1,203 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitForWaiters waits until n calls are waiting on q.
+func waitForWaiters(t *testing.T, q *turnQueue, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		q.mu.Lock()
+		waiting := len(q.waiters)
+		q.mu.Unlock()
+		if waiting == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %d waiting calls", n)
+}
+
+func TestTurnQueue_Order(t *testing.T) {
+	q := newTurnQueue()
+	ctx := context.Background()
+	if err := q.acquire(ctx, true); err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+
+	order := make(chan int, 3)
+	for i := range 3 {
+		go func() {
+			if err := q.acquire(ctx, true); err != nil {
+				t.Errorf("acquire %d failed: %v", i, err)
+				return
+			}
+			order <- i
+		}()
+		waitForWaiters(t, q, i+1)
+	}
+
+	for want := range 3 {
+		q.release()
+		select {
+		case got := <-order:
+			if got != want {
+				t.Errorf("Expected call %d to run next, got %d", want, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for call %d", want)
+		}
+	}
+
+	// The last turn ends with no one waiting
+	q.release()
+	if err := q.acquire(ctx, false); err != nil {
+		t.Errorf("Expected the queue to be free, got %v", err)
+	}
+}
+
+func TestTurnQueue_Reject(t *testing.T) {
+	q := newTurnQueue()
+	_ = q.acquire(context.Background(), false)
+
+	err := q.acquire(context.Background(), false)
+	if !IsBusyError(err) {
+		t.Fatalf("Expected BusyError, got %v", err)
+	}
+}
+
+func TestTurnQueue_CancelWhileWaiting(t *testing.T) {
+	q := newTurnQueue()
+	_ = q.acquire(context.Background(), true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- q.acquire(ctx, true) }()
+	waitForWaiters(t, q, 1)
+	cancel()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	waitForWaiters(t, q, 0)
+
+	// The cancelled call does not take the next turn
+	q.release()
+	if err := q.acquire(context.Background(), false); err != nil {
+		t.Errorf("Expected the queue to be free, got %v", err)
+	}
+}
+
+func TestTurnQueue_Close(t *testing.T) {
+	q := newTurnQueue()
+	_ = q.acquire(context.Background(), true)
+
+	done := make(chan error, 1)
+	go func() { done <- q.acquire(context.Background(), true) }()
+	waitForWaiters(t, q, 1)
+	q.close()
+
+	if err := <-done; !IsConnectionError(err) {
+		t.Errorf("Expected CLIConnectionError for a waiting call, got %v", err)
+	}
+	if err := q.acquire(context.Background(), true); !IsConnectionError(err) {
+		t.Errorf("Expected CLIConnectionError after close, got %v", err)
+	}
+}
+
+func TestTurnQueue_Nil(t *testing.T) {
+	var q *turnQueue
+	if err := q.acquire(context.Background(), false); err != nil {
+		t.Errorf("Expected a nil queue to let turns through, got %v", err)
+	}
+	q.release()
+	q.close()
+}
+
+// echoCLI answers each prompt with a result holding the prompt, after a
+// delay.
+const echoCLI = `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+while read line; do
+  case "$line" in
+  *'"type":"user"'*)
+    prompt=$(echo "$line" | sed 's/.*"content":"\([^"]*\)".*/\1/')
+    sleep 0.2
+    echo '{"type":"result","subtype":"success","session_id":"s","result":"'$prompt'"}'
+    ;;
+  esac
+done
+`
+
+func TestClient_ConcurrentQueries(t *testing.T) {
+	cli := writeStubCLI(t, echoCLI)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.Query(ctx, "first"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	// Later calls wait for the turn in progress, in the order they were made
+	for i, prompt := range []string{"second", "third"} {
+		go func() {
+			if err := client.Query(ctx, prompt); err != nil {
+				t.Errorf("Query %q failed: %v", prompt, err)
+			}
+		}()
+		waitForWaiters(t, client.turns, i+1)
+	}
+
+	var results []string
+	for msg := range client.Messages() {
+		if result, ok := msg.(*ResultMessage); ok {
+			results = append(results, result.Result)
+			if len(results) == 3 {
+				break
+			}
+		}
+	}
+	want := []string{"first", "second", "third"}
+	for i := range want {
+		if i >= len(results) || results[i] != want[i] {
+			t.Fatalf("Expected results %v, got %v", want, results)
+		}
+	}
+}
+
+func TestClient_BusyBehaviorReject(t *testing.T) {
+	cli := writeStubCLI(t, echoCLI)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli), WithBusyBehavior(BusyBehaviorReject))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.Query(ctx, "first"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if err := client.Query(ctx, "second"); !IsBusyError(err) {
+		t.Fatalf("Expected BusyError while a turn is in progress, got %v", err)
+	}
+	for range client.ReceiveResponse(ctx) {
+	}
+
+	if err := client.Query(ctx, "third"); err != nil {
+		t.Errorf("Expected Query to succeed once the turn ended, got %v", err)
+	}
+}
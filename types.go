@@ -1,6 +1,10 @@
 package claude
 
-import "context"
+import (
+	"context"
+	"strings"
+	"time"
+)
 
 // =============================================================================
 // Content Blocks
@@ -50,7 +54,7 @@ func (ToolResultBlock) contentBlock() {}
 // =============================================================================
 
 // Message represents a message in the conversation.
-// It can be one of: UserMessage, AssistantMessage, SystemMessage, ResultMessage, StreamEvent.
+// It can be one of: UserMessage, AssistantMessage, SystemMessage, ResultMessage, StreamEvent, IncrementalToolUse, DiagnosticEvent.
 type Message interface {
 	message()
 }
@@ -100,12 +104,54 @@ func (m *UserMessage) GetContentString() string {
 type AssistantMessage struct {
 	Content         []ContentBlock        `json:"content"`
 	Model           string                `json:"model"`
+	UUID            string                `json:"uuid,omitempty"`
 	ParentToolUseID string                `json:"parent_tool_use_id,omitempty"`
 	Error           AssistantMessageError `json:"error,omitempty"`
+	// Usage is the token usage of the model response, as reported by the
+	// API.
+	Usage map[string]any `json:"usage,omitempty"`
 }
 
 func (AssistantMessage) message() {}
 
+// Tokens returns the token counts of Usage.
+func (m *AssistantMessage) Tokens() TokenUsage {
+	return tokenUsage(m.Usage)
+}
+
+// Text returns the text blocks of the message, joined by newlines.
+func (m *AssistantMessage) Text() string {
+	var parts []string
+	for _, block := range m.Content {
+		if b, ok := block.(TextBlock); ok {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// Thinking returns the thinking blocks of the message, joined by newlines.
+func (m *AssistantMessage) Thinking() string {
+	var parts []string
+	for _, block := range m.Content {
+		if b, ok := block.(ThinkingBlock); ok {
+			parts = append(parts, b.Thinking)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// ToolUses returns the tool use blocks of the message.
+func (m *AssistantMessage) ToolUses() []ToolUseBlock {
+	var uses []ToolUseBlock
+	for _, block := range m.Content {
+		if b, ok := block.(ToolUseBlock); ok {
+			uses = append(uses, b)
+		}
+	}
+	return uses
+}
+
 // SystemMessage represents a system message with metadata.
 type SystemMessage struct {
 	Subtype string         `json:"subtype"`
@@ -114,6 +160,28 @@ type SystemMessage struct {
 
 func (SystemMessage) message() {}
 
+// SystemSubtypeNote is the Subtype of the SystemMessages inserted into
+// the message stream with Client.InjectSystemNote, holding the note in
+// Data["text"].
+const SystemSubtypeNote = "sdk_note"
+
+// IsNote reports whether m is a note inserted with
+// Client.InjectSystemNote rather than a message of the CLI.
+func (m *SystemMessage) IsNote() bool {
+	return m.Subtype == SystemSubtypeNote
+}
+
+// ResultSubtype represents the subtype reported in ResultMessage.Subtype.
+type ResultSubtype string
+
+const (
+	ResultSubtypeSuccess              ResultSubtype = "success"
+	ResultSubtypeErrorMaxTurns        ResultSubtype = "error_max_turns"
+	ResultSubtypeErrorMaxBudget       ResultSubtype = "error_max_budget_usd"
+	ResultSubtypeErrorDuringExecution ResultSubtype = "error_during_execution"
+	ResultSubtypeInterrupted          ResultSubtype = "interrupted"
+)
+
 // ResultMessage represents a result message with cost and usage information.
 type ResultMessage struct {
 	Subtype          string         `json:"subtype"`
@@ -126,10 +194,78 @@ type ResultMessage struct {
 	Usage            map[string]any `json:"usage,omitempty"`
 	Result           string         `json:"result,omitempty"`
 	StructuredOutput any            `json:"structured_output,omitempty"`
+
+	// Interrupted is set when the turn ended because of an interrupt,
+	// either reported by the CLI or requested through Client.Interrupt.
+	Interrupted bool `json:"interrupted,omitempty"`
+	// InterruptReason is the reason passed to Client.InterruptWithReason.
+	InterruptReason string `json:"interrupt_reason,omitempty"`
+
+	// Model is the model that served the turn, as reported by its last
+	// assistant message. Set by the SDK, not the CLI.
+	Model string `json:"model,omitempty"`
+
+	// Timing breaks down the duration of the turn. Set by the SDK for
+	// results of Query and Client; not part of the wire format.
+	Timing *TurnTiming `json:"-"`
 }
 
 func (ResultMessage) message() {}
 
+// IsInterrupted reports whether the turn was interrupted rather than
+// completing or failing on its own.
+func (m *ResultMessage) IsInterrupted() bool {
+	return m.Interrupted || ResultSubtype(m.Subtype) == ResultSubtypeInterrupted
+}
+
+// Tokens returns the token counts of Usage.
+func (m *ResultMessage) Tokens() TokenUsage {
+	return tokenUsage(m.Usage)
+}
+
+// tokenUsage reads the token counts of a usage object of the API.
+func tokenUsage(usage map[string]any) TokenUsage {
+	count := func(key string) int {
+		n, _ := usage[key].(float64)
+		return int(n)
+	}
+	return TokenUsage{
+		InputTokens:              count("input_tokens"),
+		OutputTokens:             count("output_tokens"),
+		CacheCreationInputTokens: count("cache_creation_input_tokens"),
+		CacheReadInputTokens:     count("cache_read_input_tokens"),
+	}
+}
+
+// TokenUsage holds the token counts of a turn. Input tokens read from or
+// written to the prompt cache are counted apart from InputTokens.
+type TokenUsage struct {
+	// InputTokens counts the input tokens not read from or written to the
+	// cache.
+	InputTokens  int
+	OutputTokens int
+	// CacheCreationInputTokens counts the input tokens written to the
+	// cache.
+	CacheCreationInputTokens int
+	// CacheReadInputTokens counts the input tokens read from the cache.
+	CacheReadInputTokens int
+}
+
+// TotalInputTokens returns the input tokens of the turn, cached or not.
+func (u TokenUsage) TotalInputTokens() int {
+	return u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+}
+
+// CacheHitRate returns the share of input tokens read from the cache, from
+// 0 to 1, or 0 if there were none.
+func (u TokenUsage) CacheHitRate() float64 {
+	total := u.TotalInputTokens()
+	if total == 0 {
+		return 0
+	}
+	return float64(u.CacheReadInputTokens) / float64(total)
+}
+
 // StreamEvent represents a stream event for partial message updates during streaming.
 type StreamEvent struct {
 	UUID            string         `json:"uuid"`
@@ -140,6 +276,139 @@ type StreamEvent struct {
 
 func (StreamEvent) message() {}
 
+// IncrementalToolUse reports a tool call whose input Claude is still
+// writing. With partial streaming, the Client emits one after each input
+// delta, so UIs can show a tool call before it completes.
+type IncrementalToolUse struct {
+	// ID and Name identify the tool use, as in the final ToolUseBlock.
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Index is the position of the tool use in the assistant message.
+	Index int `json:"index"`
+	// PartialInput is the raw input JSON received so far.
+	PartialInput string `json:"partial_input"`
+	// Input holds the arguments parsed from PartialInput, with the value
+	// being written cut short. It is empty until a value can be parsed.
+	Input map[string]any `json:"input"`
+	// Done reports that the input is complete.
+	Done            bool   `json:"done"`
+	SessionID       string `json:"session_id"`
+	ParentToolUseID string `json:"parent_tool_use_id,omitempty"`
+}
+
+func (IncrementalToolUse) message() {}
+
+// DiagnosticKind represents the kind of a DiagnosticEvent.
+type DiagnosticKind string
+
+const (
+	// DiagnosticKindStall reports that no messages arrived for the configured
+	// stall timeout while a turn was in progress.
+	DiagnosticKindStall DiagnosticKind = "stall"
+	// DiagnosticKindModelFallback reports that a turn failed with a rate
+	// limit or server error and is retried with the next model of
+	// WithModelFallbacks.
+	DiagnosticKindModelFallback DiagnosticKind = "model_fallback"
+	// DiagnosticKindAutoContinue reports that a turn is followed up with
+	// the prompt of WithAutoContinue, or that the loop stopped at its
+	// budget.
+	DiagnosticKindAutoContinue DiagnosticKind = "auto_continue"
+	// DiagnosticKindRetry reports that a turn failed and is retried with
+	// the same model, as the handler set with WithAssistantErrorHandler
+	// decided.
+	DiagnosticKindRetry DiagnosticKind = "retry"
+	// DiagnosticKindContext reports that the context filled past a
+	// threshold of WithContextWarnings.
+	DiagnosticKindContext DiagnosticKind = "context"
+)
+
+// DiagnosticEvent is emitted by the SDK itself, not the CLI, to report
+// conditions such as a stalled turn.
+type DiagnosticEvent struct {
+	Kind    DiagnosticKind `json:"kind"`
+	Message string         `json:"message"`
+	// Idle is how long the turn has gone without a message.
+	Idle time.Duration `json:"idle"`
+	// Action is the stall action taken after emitting the event.
+	Action StallAction `json:"action"`
+	// Context is the context usage that set off a context warning.
+	Context *ContextUsage `json:"context,omitempty"`
+}
+
+func (DiagnosticEvent) message() {}
+
+// PermissionModeSource tells what changed the permission mode.
+type PermissionModeSource string
+
+const (
+	// PermissionModeSourceCLI is a change reported by the CLI, such as one
+	// made by a hook or by the user in the CLI.
+	PermissionModeSourceCLI PermissionModeSource = "cli"
+	// PermissionModeSourceClient is a change made with
+	// Client.SetPermissionMode.
+	PermissionModeSourceClient PermissionModeSource = "client"
+	// PermissionModeSourcePlan is a change made on approving a plan with
+	// WithPlanApprover.
+	PermissionModeSourcePlan PermissionModeSource = "plan"
+)
+
+// PermissionModeChanged is emitted by the SDK when the permission mode of
+// the session changes, so that a UI can show the mode in effect.
+type PermissionModeChanged struct {
+	Mode      PermissionMode       `json:"mode"`
+	Previous  PermissionMode       `json:"previous"`
+	Source    PermissionModeSource `json:"source"`
+	SessionID string               `json:"session_id"`
+}
+
+func (PermissionModeChanged) message() {}
+
+// StallAction represents the action taken when a turn stalls.
+type StallAction string
+
+const (
+	// StallActionDiagnostic only emits a DiagnosticEvent.
+	StallActionDiagnostic StallAction = "diagnostic"
+	// StallActionInterrupt emits a DiagnosticEvent and interrupts the turn.
+	StallActionInterrupt StallAction = "interrupt"
+	// StallActionClose emits a DiagnosticEvent and closes the session.
+	StallActionClose StallAction = "close"
+)
+
+// valid reports whether a is a known stall action.
+func (a StallAction) valid() bool {
+	switch a {
+	case StallActionDiagnostic, StallActionInterrupt, StallActionClose:
+		return true
+	}
+	return false
+}
+
+// CancelBehavior represents what a Client does when the context of a turn
+// in progress is cancelled.
+type CancelBehavior string
+
+const (
+	// CancelBehaviorInterrupt interrupts the turn. This is the default.
+	CancelBehaviorInterrupt CancelBehavior = "interrupt"
+	// CancelBehaviorClose closes the session.
+	CancelBehaviorClose CancelBehavior = "close"
+	// CancelBehaviorNone leaves the turn running.
+	CancelBehaviorNone CancelBehavior = "none"
+)
+
+// BusyBehavior represents what a Client does when a query is made while a
+// turn is in progress.
+type BusyBehavior string
+
+const (
+	// BusyBehaviorQueue waits for the turns in progress and queued before
+	// the query. This is the default.
+	BusyBehaviorQueue BusyBehavior = "queue"
+	// BusyBehaviorReject returns a BusyError.
+	BusyBehaviorReject BusyBehavior = "reject"
+)
+
 // =============================================================================
 // Hooks
 // =============================================================================
@@ -164,6 +433,9 @@ type HookInput interface {
 	GetTranscriptPath() string
 	GetCwd() string
 	GetHookEventName() HookEvent
+	// Raw returns the input as sent by the CLI, including fields the SDK
+	// does not decode yet. It is nil for inputs not received from the CLI.
+	Raw() map[string]any
 }
 
 // BaseHookInput contains common fields present across many hook events.
@@ -172,11 +444,14 @@ type BaseHookInput struct {
 	TranscriptPath string `json:"transcript_path"`
 	Cwd            string `json:"cwd"`
 	PermissionMode string `json:"permission_mode,omitempty"`
+
+	raw map[string]any
 }
 
 func (b BaseHookInput) GetSessionID() string      { return b.SessionID }
 func (b BaseHookInput) GetTranscriptPath() string { return b.TranscriptPath }
 func (b BaseHookInput) GetCwd() string            { return b.Cwd }
+func (b BaseHookInput) Raw() map[string]any       { return b.raw }
 
 // PreToolUseHookInput is the input for PreToolUse hook events.
 type PreToolUseHookInput struct {
@@ -545,6 +820,43 @@ type ToolPermissionContext struct {
 	Suggestions []PermissionUpdate
 }
 
+// AcceptSuggestions allows the tool call and applies the permission
+// updates the CLI suggested for it, such as a rule allowing the tool from
+// now on, as if the user had accepted them in the CLI's prompt. Set
+// UpdatedInput on the result to also change the input.
+//
+// Example:
+//
+//	func canUseTool(ctx context.Context, toolName string, input map[string]any, permCtx claude.ToolPermissionContext) (claude.PermissionResult, error) {
+//	    if toolName == "Read" {
+//	        return permCtx.AcceptSuggestions(), nil
+//	    }
+//	    return claude.PermissionResultDeny{Message: "not allowed"}, nil
+//	}
+func (c ToolPermissionContext) AcceptSuggestions() PermissionResultAllow {
+	if len(c.Suggestions) == 0 {
+		return PermissionResultAllow{}
+	}
+	updates := make([]PermissionUpdate, len(c.Suggestions))
+	for i, u := range c.Suggestions {
+		u.Rules = append([]PermissionRuleValue(nil), u.Rules...)
+		u.Directories = append([]string(nil), u.Directories...)
+		updates[i] = u
+	}
+	return PermissionResultAllow{UpdatedPermissions: updates}
+}
+
+// AcceptSuggestionsForSession is like AcceptSuggestions, but applies the
+// suggested updates to the current session only, rather than saving them
+// to the settings files the CLI suggested.
+func (c ToolPermissionContext) AcceptSuggestionsForSession() PermissionResultAllow {
+	allow := c.AcceptSuggestions()
+	for i := range allow.UpdatedPermissions {
+		allow.UpdatedPermissions[i].Destination = PermissionUpdateDestinationSession
+	}
+	return allow
+}
+
 // PermissionResult is the interface for permission callback results.
 type PermissionResult interface {
 	permissionResult()
@@ -562,14 +874,33 @@ func (PermissionResultAllow) permissionResult() {}
 type PermissionResultDeny struct {
 	Message   string `json:"message,omitempty"`
 	Interrupt bool   `json:"interrupt,omitempty"`
+	// Guidance helps Claude recover from the denial. It is added to the
+	// message Claude receives. See DenyWithGuidance.
+	Guidance *DenyGuidance `json:"guidance,omitempty"`
 }
 
 func (PermissionResultDeny) permissionResult() {}
 
+// PermissionResultAsk defers the decision to the CLI, which asks the user
+// as it would without a CanUseTool callback, applying its permission mode
+// and rules. Message is shown with the prompt. With WithAskResolver, the
+// resolver decides first.
+type PermissionResultAsk struct {
+	Message string `json:"message,omitempty"`
+}
+
+func (PermissionResultAsk) permissionResult() {}
+
 // CanUseToolFunc is the callback type for tool permission requests.
 // It receives the tool name, input parameters, and context, and returns a permission result.
 type CanUseToolFunc func(ctx context.Context, toolName string, input map[string]any, permCtx ToolPermissionContext) (PermissionResult, error)
 
+// AskResolverFunc resolves the tool calls a CanUseTool callback answered
+// with PermissionResultAsk, such as by asking for approval elsewhere. It
+// receives the callback's arguments and result; returning ask, or another
+// PermissionResultAsk, leaves the decision to the CLI.
+type AskResolverFunc func(ctx context.Context, toolName string, input map[string]any, permCtx ToolPermissionContext, ask PermissionResultAsk) (PermissionResult, error)
+
 // =============================================================================
 // MCP (Model Context Protocol)
 // =============================================================================
@@ -629,9 +960,10 @@ func (c MCPSDKServerConfig) GetType() string { return "sdk" }
 
 // MCPServer represents an in-process MCP server.
 type MCPServer struct {
-	name    string
-	version string
-	tools   []MCPTool
+	name           string
+	version        string
+	tools          []MCPTool
+	validateInputs bool
 }
 
 // MCPTool represents a tool that can be called via MCP.
@@ -649,14 +981,25 @@ type MCPToolHandler func(ctx context.Context, args map[string]any) (MCPToolResul
 type MCPToolResult struct {
 	Content []MCPContent `json:"content"`
 	IsError bool         `json:"is_error,omitempty"`
+
+	// Structured is machine-readable output, sent as the MCP
+	// structuredContent of the result. It must encode to a JSON object.
+	// See JSONResult.
+	Structured any `json:"structuredContent,omitempty"`
 }
 
 // MCPContent represents content in an MCP tool result.
 type MCPContent struct {
-	Type     string `json:"type"` // "text" or "image"
+	Type     string `json:"type"` // "text", "image" or "resource_link"
 	Text     string `json:"text,omitempty"`
 	Data     string `json:"data,omitempty"`
 	MimeType string `json:"mimeType,omitempty"`
+
+	// URI, Name and Size describe a resource_link: content stored
+	// elsewhere, such as a large output written to a file.
+	URI  string `json:"uri,omitempty"`
+	Name string `json:"name,omitempty"`
+	Size int64  `json:"size,omitempty"`
 }
 
 // NewMCPServer creates a new in-process MCP server.
@@ -677,6 +1020,16 @@ func (s *MCPServer) Version() string { return s.version }
 // Tools returns the list of tools.
 func (s *MCPServer) Tools() []MCPTool { return s.tools }
 
+// ValidateInputs sets whether the arguments of each tool call are checked
+// against the tool's InputSchema before its handler is called. Arguments
+// that do not conform are answered with an error result listing the
+// violations, which Claude can correct, and the handler is not called.
+// It returns s, and must be set before the server is used.
+func (s *MCPServer) ValidateInputs(enabled bool) *MCPServer {
+	s.validateInputs = enabled
+	return s
+}
+
 // CreateSDKMCPServer creates an MCPSDKServerConfig with an in-process MCP server.
 func CreateSDKMCPServer(name, version string, tools []MCPTool) MCPSDKServerConfig {
 	server := NewMCPServer(name, version, tools)
@@ -748,6 +1101,46 @@ type SandboxSettings struct {
 	EnableWeakerNestedSandbox bool `json:"enableWeakerNestedSandbox,omitempty"`
 }
 
+// ContainerRuntime runs the CLI inside a docker or podman container, for
+// isolation at the OS level beyond what SandboxSettings provide. The
+// working directory is mounted at the same path inside the container.
+type ContainerRuntime struct {
+	// Runtime is the container runtime command or path. If empty, docker
+	// is used, or podman if docker is not installed.
+	Runtime string
+	// Image is the image to run. It must have the CLI on its PATH, or at
+	// the path set with WithCLIPath.
+	Image string
+	// Mounts are additional volumes, in the runtime's -v syntax
+	// ("host:container[:options]").
+	Mounts []string
+	// Env holds environment variables set inside the container. Nothing
+	// of the host environment is passed in otherwise, so credentials such
+	// as ANTHROPIC_API_KEY must be listed here or in WithEnv.
+	Env map[string]string
+}
+
+// SSHRemote runs the CLI on a remote host with the OpenSSH client, which
+// reads the user's ssh_config, known_hosts and agent as usual. The working
+// directory, AddDirs and CLIPath refer to paths on the remote host.
+type SSHRemote struct {
+	// Host is the host name or ssh_config alias, optionally as user@host.
+	Host string
+	// Port is the SSH port. If zero, the client's default is used.
+	Port int
+	// User is the remote user. If empty, the client's default is used.
+	User string
+	// IdentityFile is the private key to authenticate with.
+	IdentityFile string
+	// Options are extra ssh -o options, such as "StrictHostKeyChecking=yes".
+	Options []string
+	// Env holds environment variables set for the remote CLI. They are
+	// sent over stdin ahead of its input, not on the command line.
+	Env map[string]string
+	// Path is the ssh client command or path. If empty, ssh is used.
+	Path string
+}
+
 // SdkPluginType defines the type of plugin.
 type SdkPluginType string
 
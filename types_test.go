@@ -187,6 +187,30 @@ func TestAssistantMessage_Fields(t *testing.T) {
 	}
 }
 
+func TestAssistantMessage_Accessors(t *testing.T) {
+	m := &AssistantMessage{Content: []ContentBlock{
+		ThinkingBlock{Thinking: "The user wants a file."},
+		TextBlock{Text: "Let me check."},
+		ToolUseBlock{ID: "t1", Name: "Read", Input: map[string]any{"file_path": "a.go"}},
+		TextBlock{Text: "Done."},
+	}}
+
+	if got := m.Text(); got != "Let me check.\nDone." {
+		t.Errorf("Unexpected text %q", got)
+	}
+	if got := m.Thinking(); got != "The user wants a file." {
+		t.Errorf("Unexpected thinking %q", got)
+	}
+	if uses := m.ToolUses(); len(uses) != 1 || uses[0].Name != "Read" {
+		t.Errorf("Unexpected tool uses %+v", uses)
+	}
+
+	empty := &AssistantMessage{}
+	if empty.Text() != "" || empty.Thinking() != "" || empty.ToolUses() != nil {
+		t.Error("Expected empty accessors for a message without content")
+	}
+}
+
 func TestAssistantMessageError_Constants(t *testing.T) {
 	tests := []struct {
 		err      AssistantMessageError
@@ -295,6 +319,52 @@ func TestResultMessage_NilOptionalFields(t *testing.T) {
 	}
 }
 
+func TestResultMessage_IsInterrupted(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  ResultMessage
+		want bool
+	}{
+		{"success", ResultMessage{Subtype: string(ResultSubtypeSuccess)}, false},
+		{"error", ResultMessage{Subtype: string(ResultSubtypeErrorDuringExecution), IsError: true}, false},
+		{"interrupted subtype", ResultMessage{Subtype: string(ResultSubtypeInterrupted)}, true},
+		{"interrupted flag", ResultMessage{Subtype: string(ResultSubtypeErrorDuringExecution), Interrupted: true}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.msg.IsInterrupted(); got != tt.want {
+				t.Errorf("Expected IsInterrupted() = %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestResultMessage_Tokens(t *testing.T) {
+	msg := &ResultMessage{Usage: map[string]any{
+		"input_tokens":                float64(100),
+		"output_tokens":               float64(50),
+		"cache_creation_input_tokens": float64(300),
+		"cache_read_input_tokens":     float64(1600),
+	}}
+
+	tokens := msg.Tokens()
+	want := TokenUsage{InputTokens: 100, OutputTokens: 50, CacheCreationInputTokens: 300, CacheReadInputTokens: 1600}
+	if tokens != want {
+		t.Errorf("Expected %+v, got %+v", want, tokens)
+	}
+	if tokens.TotalInputTokens() != 2000 {
+		t.Errorf("Expected 2000 input tokens, got %d", tokens.TotalInputTokens())
+	}
+	if tokens.CacheHitRate() != 0.8 {
+		t.Errorf("Expected a cache hit rate of 0.8, got %v", tokens.CacheHitRate())
+	}
+
+	if rate := (&ResultMessage{}).Tokens().CacheHitRate(); rate != 0 {
+		t.Errorf("Expected a hit rate of 0 without usage, got %v", rate)
+	}
+}
+
 func TestStreamEvent_Fields(t *testing.T) {
 	msg := &StreamEvent{
 		UUID:      "stream-001",
@@ -716,6 +786,38 @@ func TestToolPermissionContext_Fields(t *testing.T) {
 	}
 }
 
+func TestToolPermissionContext_AcceptSuggestions(t *testing.T) {
+	ctx := ToolPermissionContext{
+		Suggestions: []PermissionUpdate{{
+			Type:        PermissionUpdateTypeAddRules,
+			Rules:       []PermissionRuleValue{{ToolName: "Bash", RuleContent: "npm test:*"}},
+			Behavior:    PermissionBehaviorAllow,
+			Destination: PermissionUpdateDestinationProjectSettings,
+		}},
+	}
+
+	allow := ctx.AcceptSuggestions()
+	if !reflect.DeepEqual(allow.UpdatedPermissions, ctx.Suggestions) || allow.UpdatedInput != nil {
+		t.Errorf("Expected the suggestions as updated permissions, got %+v", allow)
+	}
+	allow.UpdatedPermissions[0].Rules[0].RuleContent = "changed"
+	if ctx.Suggestions[0].Rules[0].RuleContent != "npm test:*" {
+		t.Error("Expected the suggestions copied")
+	}
+
+	session := ctx.AcceptSuggestionsForSession()
+	if session.UpdatedPermissions[0].Destination != PermissionUpdateDestinationSession {
+		t.Errorf("Expected the session destination, got %+v", session.UpdatedPermissions)
+	}
+	if ctx.Suggestions[0].Destination != PermissionUpdateDestinationProjectSettings {
+		t.Error("Expected the suggestions left unchanged")
+	}
+
+	if allow := (ToolPermissionContext{}).AcceptSuggestions(); allow.UpdatedPermissions != nil {
+		t.Errorf("Expected no updated permissions without suggestions, got %+v", allow)
+	}
+}
+
 // =============================================================================
 // MCP Config Tests
 // =============================================================================
@@ -0,0 +1,184 @@
+package claude
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileEditTools maps tools that modify files to the input field naming the
+// file.
+var fileEditTools = map[string]string{
+	string(ToolWrite):        "file_path",
+	string(ToolEdit):         "file_path",
+	string(ToolMultiEdit):    "file_path",
+	string(ToolNotebookEdit): "notebook_path",
+}
+
+// fileState identifies a version of a watched file.
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// fileWatcher detects changes to watched files between turns so Claude can
+// be told about them. The watched files are compared with a snapshot taken
+// at the previous prompt; files Claude modified itself are not reported.
+type fileWatcher struct {
+	paths []string
+
+	mu       sync.Mutex
+	snapshot map[string]fileState
+	// edited holds files Claude modified during the current turn.
+	edited map[string]bool
+}
+
+// newFileWatcher creates a watcher for paths, resolving relative paths
+// against cwd. It returns nil when there is nothing to watch.
+func newFileWatcher(paths []string, cwd string) *fileWatcher {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	w := &fileWatcher{edited: make(map[string]bool)}
+	for _, path := range paths {
+		w.paths = append(w.paths, resolvePath(path, cwd))
+	}
+	w.snapshot = w.scan()
+	return w
+}
+
+// resolvePath returns path as an absolute, clean path.
+func resolvePath(path, cwd string) string {
+	if !filepath.IsAbs(path) && cwd != "" {
+		path = filepath.Join(cwd, path)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return filepath.Clean(path)
+}
+
+// scan records the state of every file under the watched paths.
+// Directories are walked recursively, skipping hidden directories.
+func (w *fileWatcher) scan() map[string]fileState {
+	files := make(map[string]fileState)
+	for _, root := range w.paths {
+		_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if path != root && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			files[path] = fileState{size: info.Size(), modTime: info.ModTime()}
+			return nil
+		})
+	}
+	return files
+}
+
+// observe records files modified by Claude's tool uses in msg.
+func (w *fileWatcher) observe(msg Message, cwd string) {
+	assistant, ok := msg.(*AssistantMessage)
+	if w == nil || !ok {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, block := range assistant.Content {
+		toolUse, ok := block.(ToolUseBlock)
+		if !ok {
+			continue
+		}
+		field, ok := fileEditTools[toolUse.Name]
+		if !ok {
+			continue
+		}
+		if path, _ := toolUse.Input[field].(string); path != "" {
+			w.edited[resolvePath(path, cwd)] = true
+		}
+	}
+}
+
+// changes returns a description of the files changed since the previous
+// call, or "" if none changed, and takes a new snapshot.
+func (w *fileWatcher) changes() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	current := w.scan()
+	var lines []string
+	for path, state := range current {
+		if w.edited[path] {
+			continue
+		}
+		previous, ok := w.snapshot[path]
+		switch {
+		case !ok:
+			lines = append(lines, "- "+path+" (created)")
+		case previous.size != state.size || !previous.modTime.Equal(state.modTime):
+			lines = append(lines, "- "+path+" (modified)")
+		}
+	}
+	for path := range w.snapshot {
+		if _, ok := current[path]; !ok && !w.edited[path] {
+			lines = append(lines, "- "+path+" (deleted)")
+		}
+	}
+
+	w.snapshot = current
+	clear(w.edited)
+
+	if len(lines) == 0 {
+		return ""
+	}
+	sort.Strings(lines)
+	return "These watched files changed since the last turn:\n" + strings.Join(lines, "\n")
+}
+
+// hook returns a UserPromptSubmit hook adding the changed files to the
+// prompt context.
+func (w *fileWatcher) hook() HookCallback {
+	return func(ctx context.Context, input HookInput, _ string, _ HookContext) (HookOutput, error) {
+		changes := w.changes()
+		if changes == "" {
+			return HookOutput{}, nil
+		}
+		return HookOutput{
+			HookSpecificOutput: UserPromptSubmitHookSpecificOutput{
+				HookEventName:     HookEventUserPromptSubmit,
+				AdditionalContext: changes,
+			},
+		}, nil
+	}
+}
+
+// withHook returns a copy of hooks with the watcher's hook added.
+func (w *fileWatcher) withHook(hooks map[HookEvent][]HookMatcher) map[HookEvent][]HookMatcher {
+	if w == nil {
+		return hooks
+	}
+	merged := make(map[HookEvent][]HookMatcher, len(hooks)+1)
+	for event, matchers := range hooks {
+		merged[event] = matchers
+	}
+	merged[HookEventUserPromptSubmit] = append(
+		append([]HookMatcher(nil), hooks[HookEventUserPromptSubmit]...),
+		HookMatcher{Hooks: []HookCallback{w.hook()}},
+	)
+	return merged
+}
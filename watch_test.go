@@ -0,0 +1,122 @@
+package claude
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeWatchedFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewFileWatcher_NoPaths(t *testing.T) {
+	if w := newFileWatcher(nil, ""); w != nil {
+		t.Error("Expected nil watcher without paths")
+	}
+}
+
+func TestFileWatcher_Changes(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Now().Add(-time.Hour)
+	writeWatchedFile(t, filepath.Join(dir, "kept.go"), "a", base)
+	writeWatchedFile(t, filepath.Join(dir, "changed.go"), "a", base)
+	writeWatchedFile(t, filepath.Join(dir, "removed.go"), "a", base)
+	if err := os.Mkdir(filepath.Join(dir, ".git"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	w := newFileWatcher([]string{"."}, dir)
+	if got := w.changes(); got != "" {
+		t.Errorf("Expected no changes, got %q", got)
+	}
+
+	writeWatchedFile(t, filepath.Join(dir, "changed.go"), "ab", base.Add(time.Minute))
+	writeWatchedFile(t, filepath.Join(dir, "added.go"), "a", base)
+	writeWatchedFile(t, filepath.Join(dir, ".git", "index"), "a", base)
+	if err := os.Remove(filepath.Join(dir, "removed.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"These watched files changed since the last turn:",
+		"- " + filepath.Join(dir, "added.go") + " (created)",
+		"- " + filepath.Join(dir, "changed.go") + " (modified)",
+		"- " + filepath.Join(dir, "removed.go") + " (deleted)",
+	}, "\n")
+	if got := w.changes(); got != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	// Changes are reported once
+	if got := w.changes(); got != "" {
+		t.Errorf("Expected no changes after reporting, got %q", got)
+	}
+}
+
+func TestFileWatcher_IgnoresClaudeEdits(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Now().Add(-time.Hour)
+	path := filepath.Join(dir, "main.go")
+	writeWatchedFile(t, path, "a", base)
+
+	w := newFileWatcher([]string{dir}, "")
+	w.observe(&AssistantMessage{Content: []ContentBlock{
+		ToolUseBlock{ID: "t1", Name: "Edit", Input: map[string]any{"file_path": "main.go"}},
+	}}, dir)
+	writeWatchedFile(t, path, "ab", base.Add(time.Minute))
+
+	if got := w.changes(); got != "" {
+		t.Errorf("Expected Claude's edit to be ignored, got %q", got)
+	}
+
+	// Later external changes to the same file are reported
+	writeWatchedFile(t, path, "abc", base.Add(2*time.Minute))
+	if got := w.changes(); !strings.Contains(got, path+" (modified)") {
+		t.Errorf("Expected external change to be reported, got %q", got)
+	}
+}
+
+func TestFileWatcher_Hook(t *testing.T) {
+	dir := t.TempDir()
+	w := newFileWatcher([]string{dir}, "")
+	writeWatchedFile(t, filepath.Join(dir, "new.txt"), "a", time.Now())
+
+	output, err := w.hook()(context.Background(), UserPromptSubmitHookInput{Prompt: "hi"}, "", HookContext{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	specific, ok := output.HookSpecificOutput.(UserPromptSubmitHookSpecificOutput)
+	if !ok || !strings.Contains(specific.AdditionalContext, "new.txt (created)") {
+		t.Errorf("Expected changed file in context, got %+v", output.HookSpecificOutput)
+	}
+}
+
+func TestFileWatcher_WithHook(t *testing.T) {
+	hooks := map[HookEvent][]HookMatcher{
+		HookEventUserPromptSubmit: {{Matcher: "existing"}},
+	}
+
+	var nilWatcher *fileWatcher
+	if got := nilWatcher.withHook(hooks); len(got[HookEventUserPromptSubmit]) != 1 {
+		t.Error("Expected nil watcher to leave hooks unchanged")
+	}
+
+	w := newFileWatcher([]string{t.TempDir()}, "")
+	merged := w.withHook(hooks)
+	if got := len(merged[HookEventUserPromptSubmit]); got != 2 {
+		t.Errorf("Expected 2 UserPromptSubmit matchers, got %d", got)
+	}
+	if got := len(hooks[HookEventUserPromptSubmit]); got != 1 {
+		t.Errorf("Expected options hooks to be unchanged, got %d matchers", got)
+	}
+}
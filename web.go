@@ -0,0 +1,219 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// webToolMatcher matches the web tools in a HookMatcher.
+const webToolMatcher = "WebFetch|WebSearch"
+
+// WebResult describes the result of a WebFetch or WebSearch call.
+type WebResult struct {
+	ToolUseID string
+	ToolName  string
+
+	// URL is the URL fetched, as reported by the CLI after redirects if
+	// it does, and Query the query searched for.
+	URL   string
+	Query string
+
+	// StatusCode is the HTTP status of a fetch, or 0 if the CLI does not
+	// report it.
+	StatusCode int
+
+	// Size is the size in bytes of the content fetched, or of the search
+	// results.
+	Size int
+
+	// Response is the tool response, as sent by the CLI.
+	Response any
+}
+
+// WebResultHook is called with the result of each WebFetch and WebSearch
+// call. Its output is that of a PostToolUse hook: it can, for example,
+// block the result with a reason for Claude, or add context.
+type WebResultHook func(ctx context.Context, result WebResult) (HookOutput, error)
+
+// WithWebResultHook adds a PostToolUse hook calling fn with the results
+// of WebFetch and WebSearch, parsed from the tool responses.
+//
+// Example:
+//
+//	claude.WithWebResultHook(func(ctx context.Context, r claude.WebResult) (claude.HookOutput, error) {
+//	    log.Printf("%s %s: status %d, %d bytes", r.ToolName, r.URL, r.StatusCode, r.Size)
+//	    return claude.HookOutput{}, nil
+//	})
+func WithWebResultHook(fn WebResultHook) Option {
+	return func(o *Options) {
+		if o.Hooks == nil {
+			o.Hooks = make(map[HookEvent][]HookMatcher)
+		}
+		o.Hooks[HookEventPostToolUse] = append(o.Hooks[HookEventPostToolUse], HookMatcher{
+			Matcher: webToolMatcher,
+			Hooks:   []HookCallback{webResultHook(fn)},
+		})
+	}
+}
+
+// webResultHook returns a PostToolUse hook calling fn with the web tool
+// results.
+func webResultHook(fn WebResultHook) HookCallback {
+	return func(ctx context.Context, input HookInput, toolUseID string, _ HookContext) (HookOutput, error) {
+		post, ok := input.(PostToolUseHookInput)
+		if !ok || (post.ToolName != string(ToolWebFetch) && post.ToolName != string(ToolWebSearch)) {
+			return HookOutput{}, nil
+		}
+		return fn(ctx, parseWebResult(toolUseID, post))
+	}
+}
+
+// parseWebResult reads the result of a web tool from its response,
+// falling back on its input for what the response lacks.
+func parseWebResult(toolUseID string, post PostToolUseHookInput) WebResult {
+	result := WebResult{
+		ToolUseID: toolUseID,
+		ToolName:  post.ToolName,
+		Response:  post.ToolResponse,
+	}
+	result.URL, _ = post.ToolInput["url"].(string)
+	result.Query, _ = post.ToolInput["query"].(string)
+
+	response, ok := post.ToolResponse.(map[string]any)
+	if !ok {
+		if text, ok := post.ToolResponse.(string); ok {
+			result.Size = len(text)
+		}
+		return result
+	}
+	if u, ok := response["url"].(string); ok && u != "" {
+		result.URL = u
+	}
+	if q, ok := response["query"].(string); ok && q != "" {
+		result.Query = q
+	}
+	if code, ok := response["code"].(float64); ok {
+		result.StatusCode = int(code)
+	}
+	switch {
+	case response["bytes"] != nil:
+		size, _ := response["bytes"].(float64)
+		result.Size = int(size)
+	case response["result"] != nil:
+		text, _ := response["result"].(string)
+		result.Size = len(text)
+	case response["results"] != nil:
+		data, _ := json.Marshal(response["results"])
+		result.Size = len(data)
+	}
+	return result
+}
+
+// webDomainPolicy decides which domains the web tools may access.
+type webDomainPolicy struct {
+	allow, deny []string
+}
+
+// permits reports whether the policy permits host: it matches no denied
+// domain and, if there are allowed domains, one of them.
+func (p webDomainPolicy) permits(host string) bool {
+	if slices.ContainsFunc(p.deny, func(domain string) bool { return matchesDomain(host, domain) }) {
+		return false
+	}
+	return len(p.allow) == 0 || slices.ContainsFunc(p.allow, func(domain string) bool { return matchesDomain(host, domain) })
+}
+
+// matchesDomain reports whether host is domain or one of its subdomains.
+// A leading "*." of domain is ignored.
+func matchesDomain(host, domain string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimPrefix(domain, "*.")), ".")
+	return domain != "" && (host == domain || strings.HasSuffix(host, "."+domain))
+}
+
+// WithWebDomainPolicy restricts the domains Claude's web tools access. A
+// domain covers its subdomains; a leading "*." is optional. Denied domains
+// take precedence, and if allow is not empty, only its domains are
+// permitted.
+//
+// A PreToolUse hook enforces the policy, so it applies whatever the
+// permission mode and rules: WebFetch calls of URLs outside it are
+// denied, and WebSearch calls are denied, with guidance for Claude,
+// unless their allowed_domains are permitted domains or, without allowed
+// domains, their blocked_domains include the denied ones. The search tool
+// takes either allowed or blocked domains, so denied subdomains of
+// allowed domains may still appear in search results.
+//
+// Example:
+//
+//	claude.WithWebDomainPolicy([]string{"go.dev", "github.com"}, []string{"gist.github.com"})
+func WithWebDomainPolicy(allow, deny []string) Option {
+	return func(o *Options) {
+		if o.Hooks == nil {
+			o.Hooks = make(map[HookEvent][]HookMatcher)
+		}
+		o.Hooks[HookEventPreToolUse] = append(o.Hooks[HookEventPreToolUse], HookMatcher{
+			Matcher: webToolMatcher,
+			Hooks:   []HookCallback{webDomainHook(webDomainPolicy{allow: allow, deny: deny}, o)},
+		})
+	}
+}
+
+// webDomainHook returns a PreToolUse hook denying the web tool calls
+// outside policy, with the reason in the locale of o.
+func webDomainHook(policy webDomainPolicy, o *Options) HookCallback {
+	return func(ctx context.Context, input HookInput, _ string, _ HookContext) (HookOutput, error) {
+		pre, ok := input.(PreToolUseHookInput)
+		if !ok {
+			return HookOutput{}, nil
+		}
+		var reason string
+		switch pre.ToolName {
+		case string(ToolWebFetch):
+			raw, _ := pre.ToolInput["url"].(string)
+			if u, err := url.Parse(raw); err != nil || !policy.permits(u.Hostname()) {
+				reason = localize(o.Locale, MessageWebFetchDenied, raw)
+			}
+		case string(ToolWebSearch):
+			reason = policy.searchReason(pre.ToolInput, o.Locale)
+		}
+		if reason == "" {
+			return HookOutput{}, nil
+		}
+		return HookOutput{
+			HookSpecificOutput: PreToolUseHookSpecificOutput{
+				HookEventName:            HookEventPreToolUse,
+				PermissionDecision:       HookPermissionDecisionDeny,
+				PermissionDecisionReason: reason,
+			},
+		}, nil
+	}
+}
+
+// searchReason returns why a WebSearch call with input is outside the
+// policy, or "" if it is not.
+func (p webDomainPolicy) searchReason(input map[string]any, locale string) string {
+	allowed := stringList(input["allowed_domains"])
+	permitted := !slices.ContainsFunc(allowed, func(domain string) bool {
+		return !p.permits(strings.TrimPrefix(domain, "*."))
+	})
+	switch {
+	case len(allowed) > 0 && permitted:
+		return ""
+	case len(p.allow) > 0:
+		return localize(locale, MessageWebSearchAllowedDomains, strings.Join(p.allow, ", "))
+	case len(allowed) > 0:
+		return localize(locale, MessageWebSearchBlockedDomains, strings.Join(p.deny, ", "))
+	}
+
+	blocked := stringList(input["blocked_domains"])
+	for _, denied := range p.deny {
+		if !slices.ContainsFunc(blocked, func(domain string) bool { return matchesDomain(denied, domain) }) {
+			return localize(locale, MessageWebSearchBlockedDomains, strings.Join(p.deny, ", "))
+		}
+	}
+	return ""
+}
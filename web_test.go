@@ -0,0 +1,158 @@
+package claude
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMatchesDomain(t *testing.T) {
+	tests := []struct {
+		host, domain string
+		want         bool
+	}{
+		{"go.dev", "go.dev", true},
+		{"pkg.go.dev", "go.dev", true},
+		{"PKG.Go.Dev", "*.go.dev", true},
+		{"notgo.dev", "go.dev", false},
+		{"go.dev.evil.com", "go.dev", false},
+		{"go.dev", "", false},
+	}
+	for _, tt := range tests {
+		if got := matchesDomain(tt.host, tt.domain); got != tt.want {
+			t.Errorf("matchesDomain(%q, %q) = %v, want %v", tt.host, tt.domain, got, tt.want)
+		}
+	}
+}
+
+// webDecision runs the PreToolUse hook of opts on a call of tool with
+// input, returning the reason of its denial, or "" if it allows the call.
+func webDecision(t *testing.T, opts *Options, tool string, input map[string]any) string {
+	t.Helper()
+	hook := opts.Hooks[HookEventPreToolUse][0].Hooks[0]
+	output, err := hook(context.Background(), PreToolUseHookInput{ToolName: tool, ToolInput: input}, "t1", HookContext{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.HookSpecificOutput == nil {
+		return ""
+	}
+	specific := output.HookSpecificOutput.(PreToolUseHookSpecificOutput)
+	if specific.PermissionDecision != HookPermissionDecisionDeny {
+		t.Fatalf("Expected a denial, got %+v", specific)
+	}
+	return specific.PermissionDecisionReason
+}
+
+func TestWithWebDomainPolicy_WebFetch(t *testing.T) {
+	opts := NewOptions(WithWebDomainPolicy([]string{"go.dev", "github.com"}, []string{"gist.github.com"}))
+	if m := opts.Hooks[HookEventPreToolUse][0].Matcher; m != "WebFetch|WebSearch" {
+		t.Errorf("Expected the hook to match the web tools, got %q", m)
+	}
+
+	for url, allowed := range map[string]bool{
+		"https://go.dev/doc":               true,
+		"https://pkg.go.dev/net/http":      true,
+		"https://github.com:443/golang/go": true,
+		"https://gist.github.com/x":        false,
+		"https://example.com":              false,
+		"::not a url":                      false,
+	} {
+		reason := webDecision(t, opts, "WebFetch", map[string]any{"url": url})
+		if allowed && reason != "" {
+			t.Errorf("Expected %s fetched, got %q", url, reason)
+		}
+		if !allowed && reason != localize("", MessageWebFetchDenied, url) {
+			t.Errorf("Expected %s denied, got %q", url, reason)
+		}
+	}
+}
+
+func TestWithWebDomainPolicy_WebSearch(t *testing.T) {
+	allowing := NewOptions(WithWebDomainPolicy([]string{"go.dev"}, []string{"tour.go.dev"}))
+	needAllowed := localize("", MessageWebSearchAllowedDomains, "go.dev")
+	for _, tt := range []struct {
+		input map[string]any
+		want  string
+	}{
+		{map[string]any{"query": "generics"}, needAllowed},
+		{map[string]any{"query": "generics", "allowed_domains": []any{"go.dev"}}, ""},
+		{map[string]any{"query": "generics", "allowed_domains": []any{"pkg.go.dev"}}, ""},
+		{map[string]any{"query": "generics", "allowed_domains": []any{"go.dev", "example.com"}}, needAllowed},
+		{map[string]any{"query": "generics", "allowed_domains": []any{"tour.go.dev"}}, needAllowed},
+	} {
+		if got := webDecision(t, allowing, "WebSearch", tt.input); got != tt.want {
+			t.Errorf("Search with %v: expected %q, got %q", tt.input, tt.want, got)
+		}
+	}
+
+	denying := NewOptions(WithWebDomainPolicy(nil, []string{"example.com"}))
+	needBlocked := localize("", MessageWebSearchBlockedDomains, "example.com")
+	for _, tt := range []struct {
+		input map[string]any
+		want  string
+	}{
+		{map[string]any{"query": "q"}, needBlocked},
+		{map[string]any{"query": "q", "blocked_domains": []any{"example.com"}}, ""},
+		{map[string]any{"query": "q", "blocked_domains": []any{"other.com"}}, needBlocked},
+		{map[string]any{"query": "q", "allowed_domains": []any{"go.dev"}}, ""},
+		{map[string]any{"query": "q", "allowed_domains": []any{"www.example.com"}}, needBlocked},
+	} {
+		if got := webDecision(t, denying, "WebSearch", tt.input); got != tt.want {
+			t.Errorf("Search with %v: expected %q, got %q", tt.input, tt.want, got)
+		}
+	}
+
+	// Other tools are not checked
+	if got := webDecision(t, denying, "Read", map[string]any{"file_path": "example.com"}); got != "" {
+		t.Errorf("Expected Read allowed, got %q", got)
+	}
+}
+
+func TestWithWebResultHook(t *testing.T) {
+	var results []WebResult
+	opts := NewOptions(WithWebResultHook(func(ctx context.Context, r WebResult) (HookOutput, error) {
+		results = append(results, r)
+		return HookOutput{Decision: HookDecisionBlock, Reason: "too large"}, nil
+	}))
+	matcher := opts.Hooks[HookEventPostToolUse][0]
+	if matcher.Matcher != "WebFetch|WebSearch" {
+		t.Errorf("Expected the hook to match the web tools, got %q", matcher.Matcher)
+	}
+	hook := matcher.Hooks[0]
+
+	output, _ := hook(context.Background(), PostToolUseHookInput{
+		ToolName:  "WebFetch",
+		ToolInput: map[string]any{"url": "http://go.dev", "prompt": "summarize"},
+		ToolResponse: map[string]any{
+			"url": "https://go.dev/", "code": float64(200), "codeText": "OK",
+			"bytes": float64(5120), "result": "Go is an open source language",
+		},
+	}, "t1", HookContext{})
+	if output.Reason != "too large" {
+		t.Errorf("Expected the output of the hook, got %+v", output)
+	}
+	_, _ = hook(context.Background(), PostToolUseHookInput{
+		ToolName:     "WebSearch",
+		ToolInput:    map[string]any{"query": "go generics"},
+		ToolResponse: map[string]any{"query": "go generics", "results": []any{"a"}},
+	}, "t2", HookContext{})
+	_, _ = hook(context.Background(), PostToolUseHookInput{
+		ToolName:     "WebFetch",
+		ToolInput:    map[string]any{"url": "https://go.dev"},
+		ToolResponse: "page",
+	}, "t3", HookContext{})
+	_, _ = hook(context.Background(), PostToolUseHookInput{ToolName: "Read"}, "t4", HookContext{})
+
+	if len(results) != 3 {
+		t.Fatalf("Expected the three web results, got %+v", results)
+	}
+	if r := results[0]; r.ToolUseID != "t1" || r.URL != "https://go.dev/" || r.StatusCode != 200 || r.Size != 5120 {
+		t.Errorf("Expected the fetch parsed from its response, got %+v", r)
+	}
+	if r := results[1]; r.ToolName != "WebSearch" || r.Query != "go generics" || r.Size != len(`["a"]`) {
+		t.Errorf("Expected the search parsed from its response, got %+v", r)
+	}
+	if r := results[2]; r.URL != "https://go.dev" || r.StatusCode != 0 || r.Size != 4 {
+		t.Errorf("Expected a text response measured, got %+v", r)
+	}
+}
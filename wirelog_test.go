@@ -0,0 +1,71 @@
+package claude
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuffer is a bytes.Buffer safe for concurrent use.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWithWireLog(t *testing.T) {
+	cli := writeStubCLI(t, `
+read line
+id=$(echo "$line" | sed 's/.*"request_id":"\([^"]*\)".*/\1/')
+echo '{"type":"control_response","response":{"subtype":"success","request_id":"'$id'","response":{}}}'
+read line
+echo '{"type":"assistant","message":{"role":"assistant","model":"claude-sonnet-4-5","content":[{"type":"text","text":"the secret plan"}]},"session_id":"s"}'
+echo '{"type":"result","subtype":"success","is_error":false,"session_id":"s"}'
+cat > /dev/null
+`)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var wire lockedBuffer
+	client := NewClient(WithCLIPath(cli), WithWireLog(&wire, "text"))
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.Query(ctx, "hello"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	for range client.ReceiveResponse(ctx) {
+	}
+
+	log := wire.String()
+	for _, want := range []string{
+		` send {"request":{"subtype":"initialize"}`,
+		` recv {"type":"control_response"`,
+		` send {"message":{"content":"hello"`,
+		`"text":"[REDACTED]"`,
+		` recv {"type":"result"`,
+	} {
+		if !strings.Contains(log, want) {
+			t.Errorf("Expected the wire log to contain %q, got:\n%s", want, log)
+		}
+	}
+	if strings.Contains(log, "the secret plan") {
+		t.Errorf("Expected the text fields redacted, got:\n%s", log)
+	}
+}
@@ -0,0 +1,168 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// workspaceWriteTools are the tools whose writes a Workspace checks, with
+// the input fields holding the path they write to.
+var workspaceWriteTools = map[string]string{
+	"Write":        "file_path",
+	"Edit":         "file_path",
+	"MultiEdit":    "file_path",
+	"NotebookEdit": "notebook_path",
+}
+
+// Workspace describes the directories a session works in: its working
+// directory, other directories Claude may access, the directories it may
+// write to, and whether file changes are checkpointed. Pass it to
+// WithWorkspace instead of combining WithCwd, WithAddDirs and a path check
+// of one's own.
+type Workspace struct {
+	// Dir is the working directory.
+	Dir string
+
+	// AddDirs are other directories Claude may access.
+	AddDirs []string
+
+	// WriteRoots are the directories file writes are allowed in. If empty,
+	// writes are allowed in Dir only.
+	WriteRoots []string
+
+	// Checkpointing enables file checkpointing, so that changes can be
+	// undone with Client.RewindFiles.
+	Checkpointing bool
+
+	// temp is set for workspaces created by NewTempWorkspace, whose Dir
+	// Close removes.
+	temp bool
+}
+
+// NewTempWorkspace creates a workspace in a new temporary directory, named
+// after pattern as with os.MkdirTemp. Close removes the directory and
+// everything in it.
+func NewTempWorkspace(pattern string) (*Workspace, error) {
+	dir, err := os.MkdirTemp("", pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+	// Resolve links such as /tmp on macOS, so paths reported by the CLI
+	// match Dir
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	return &Workspace{Dir: dir, temp: true}, nil
+}
+
+// Close removes the directory of a workspace created by NewTempWorkspace.
+// It does nothing for other workspaces.
+func (w *Workspace) Close() error {
+	if !w.temp {
+		return nil
+	}
+	return os.RemoveAll(w.Dir)
+}
+
+// CanWrite reports whether path lies within the write roots. Relative
+// paths are resolved against Dir. Paths are cleaned and symlinks resolved
+// before they are compared, so a path cannot escape through ".." or a link
+// pointing outside.
+func (w *Workspace) CanWrite(path string) bool {
+	roots := w.WriteRoots
+	if len(roots) == 0 {
+		roots = []string{w.Dir}
+	}
+	target := resolveWorkspacePath(path, w.Dir)
+	for _, root := range roots {
+		if withinDir(target, resolveWorkspacePath(root, w.Dir)) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithWorkspace runs the session in w: it sets the working directory, adds
+// the directories of AddDirs, enables file checkpointing if requested, and
+// adds a PreToolUse hook denying file writes outside the write roots.
+// Writes made by Bash commands are not checked; combine with WithSandbox
+// or WithDockerRuntime to confine those.
+func WithWorkspace(w *Workspace) Option {
+	return func(o *Options) {
+		o.Cwd = w.Dir
+		o.AddDirs = append(o.AddDirs, w.AddDirs...)
+		if w.Checkpointing {
+			o.EnableFileCheckpointing = true
+		}
+		if o.Hooks == nil {
+			o.Hooks = make(map[HookEvent][]HookMatcher)
+		}
+		o.Hooks[HookEventPreToolUse] = append(o.Hooks[HookEventPreToolUse], HookMatcher{
+			Hooks: []HookCallback{workspaceWriteHook(w, o)},
+		})
+	}
+}
+
+// workspaceWriteHook returns a PreToolUse hook denying writes outside the
+// write roots of w, with the reason in the locale of o.
+func workspaceWriteHook(w *Workspace, o *Options) HookCallback {
+	return func(ctx context.Context, input HookInput, _ string, _ HookContext) (HookOutput, error) {
+		pre, ok := input.(PreToolUseHookInput)
+		if !ok {
+			return HookOutput{}, nil
+		}
+		field, ok := workspaceWriteTools[pre.ToolName]
+		if !ok {
+			return HookOutput{}, nil
+		}
+		path, _ := pre.ToolInput[field].(string)
+		if path == "" || w.CanWrite(path) {
+			return HookOutput{}, nil
+		}
+		return HookOutput{
+			HookSpecificOutput: PreToolUseHookSpecificOutput{
+				HookEventName:            HookEventPreToolUse,
+				PermissionDecision:       HookPermissionDecisionDeny,
+				PermissionDecisionReason: localize(o.Locale, MessageWorkspaceWriteDenied, path),
+			},
+		}, nil
+	}
+}
+
+// resolveWorkspacePath makes path absolute against dir, cleans it, and
+// resolves symlinks in the part of it that exists.
+func resolveWorkspacePath(path, dir string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	path = filepath.Clean(path)
+
+	// Resolve the longest existing prefix, since the target of a write
+	// usually does not exist yet.
+	var rest []string
+	for p := path; ; p = filepath.Dir(p) {
+		resolved, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...)
+		}
+		parent := filepath.Dir(p)
+		if parent == p || !errors.Is(err, fs.ErrNotExist) {
+			return path
+		}
+		rest = append([]string{filepath.Base(p)}, rest...)
+	}
+}
+
+// withinDir reports whether path is dir or inside it.
+func withinDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator)))
+}
@@ -0,0 +1,101 @@
+package claude
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewTempWorkspace(t *testing.T) {
+	w, err := NewTempWorkspace("workspace-test-*")
+	if err != nil {
+		t.Fatalf("NewTempWorkspace failed: %v", err)
+	}
+	if info, err := os.Stat(w.Dir); err != nil || !info.IsDir() {
+		t.Fatalf("Expected the directory to exist, got %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(w.Dir); !os.IsNotExist(err) {
+		t.Error("Expected Close to remove the directory")
+	}
+
+	// Workspaces not created by NewTempWorkspace are left alone
+	dir := t.TempDir()
+	if err := (&Workspace{Dir: dir}).Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Error("Expected Close to keep an existing directory")
+	}
+}
+
+func TestWorkspace_CanWrite(t *testing.T) {
+	dir, _ := filepath.EvalSymlinks(t.TempDir())
+	outside, _ := filepath.EvalSymlinks(t.TempDir())
+	if err := os.Symlink(outside, filepath.Join(dir, "escape")); err != nil {
+		t.Skip("symlinks unsupported")
+	}
+	scratch := filepath.Join(outside, "scratch")
+
+	w := &Workspace{Dir: dir}
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"main.go", true},
+		{filepath.Join(dir, "pkg", "new.go"), true},
+		{"../other/file.go", false},
+		{filepath.Join(outside, "file.go"), false},
+		{"escape/file.go", false},
+	}
+	for _, tt := range tests {
+		if got := w.CanWrite(tt.path); got != tt.expected {
+			t.Errorf("CanWrite(%q) = %v, expected %v", tt.path, got, tt.expected)
+		}
+	}
+
+	w.WriteRoots = []string{scratch}
+	if w.CanWrite("main.go") || !w.CanWrite(filepath.Join(scratch, "out.txt")) {
+		t.Error("Expected WriteRoots to replace Dir as the write root")
+	}
+}
+
+func TestWithWorkspace(t *testing.T) {
+	w := &Workspace{Dir: "/srv/project", AddDirs: []string{"/srv/shared"}, Checkpointing: true}
+	opts := NewOptions(WithAddDirs([]string{"/srv/docs"}), WithWorkspace(w))
+
+	if opts.Cwd != "/srv/project" || !opts.EnableFileCheckpointing {
+		t.Errorf("Expected Cwd and checkpointing to be set, got %q, %v", opts.Cwd, opts.EnableFileCheckpointing)
+	}
+	if len(opts.AddDirs) != 2 || opts.AddDirs[1] != "/srv/shared" {
+		t.Errorf("Expected the workspace directories to be added, got %v", opts.AddDirs)
+	}
+
+	matchers := opts.Hooks[HookEventPreToolUse]
+	if len(matchers) != 1 {
+		t.Fatalf("Expected a PreToolUse hook, got %d", len(matchers))
+	}
+	hook := matchers[0].Hooks[0]
+
+	output, _ := hook(context.Background(), PreToolUseHookInput{
+		ToolName:  "Write",
+		ToolInput: map[string]any{"file_path": "/etc/passwd"},
+	}, "t1", HookContext{})
+	specific, ok := output.HookSpecificOutput.(PreToolUseHookSpecificOutput)
+	if !ok || specific.PermissionDecision != HookPermissionDecisionDeny {
+		t.Errorf("Expected a write outside the workspace to be denied, got %+v", output)
+	}
+
+	for _, input := range []PreToolUseHookInput{
+		{ToolName: "Edit", ToolInput: map[string]any{"file_path": "/srv/project/main.go"}},
+		{ToolName: "Read", ToolInput: map[string]any{"file_path": "/etc/passwd"}},
+	} {
+		output, _ := hook(context.Background(), input, "t2", HookContext{})
+		if output.HookSpecificOutput != nil {
+			t.Errorf("Expected %s to be allowed, got %+v", input.ToolName, output)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package claude
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/afsharalex/claude-agent-sdk-go/internal/transport"
+)
+
+// WorkspaceLockMode represents what a Client or query does when its
+// working directory is locked by another, see WithWorkspaceLock.
+type WorkspaceLockMode string
+
+const (
+	// WorkspaceLockQueue waits for the lock to be released.
+	WorkspaceLockQueue WorkspaceLockMode = "queue"
+	// WorkspaceLockReject returns a WorkspaceBusyError.
+	WorkspaceLockReject WorkspaceLockMode = "reject"
+)
+
+// workspaceLockPollInterval is how often a queued lock is tried again.
+const workspaceLockPollInterval = 100 * time.Millisecond
+
+// workspaceLockHolder is what a lock file records of the process holding
+// it.
+type workspaceLockHolder struct {
+	PID int    `json:"pid"`
+	Cwd string `json:"cwd"`
+}
+
+// workspaceLock is an advisory lock on a working directory, held through
+// an open lock file. A nil *workspaceLock is no lock.
+type workspaceLock struct {
+	file *os.File
+}
+
+// validateWorkspaceLock checks the workspace lock mode of o.
+func validateWorkspaceLock(o *Options) error {
+	switch o.WorkspaceLock {
+	case "", WorkspaceLockQueue, WorkspaceLockReject:
+		return nil
+	}
+	return NewClaudeSDKError(fmt.Sprintf("unknown workspace lock mode %q", o.WorkspaceLock))
+}
+
+// workspaceLockRoot returns the directory holding workspace lock files.
+func workspaceLockRoot(options *Options) (string, error) {
+	if options.WorkspaceLockDir != "" {
+		return options.WorkspaceLockDir, nil
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", WrapClaudeSDKError("failed to locate user cache directory", err)
+	}
+	return filepath.Join(cache, "claude-agent-sdk-go", "locks"), nil
+}
+
+// workspaceLockPath returns the working directory of options, absolute
+// and with symbolic links resolved so that every path to it shares a lock,
+// and the path of its lock file.
+func workspaceLockPath(options *Options) (cwd, path string, err error) {
+	cwd = options.Cwd
+	if cwd == "" {
+		if cwd, err = os.Getwd(); err != nil {
+			return "", "", WrapClaudeSDKError("failed to get working directory", err)
+		}
+	}
+	if cwd, err = filepath.Abs(cwd); err != nil {
+		return "", "", WrapClaudeSDKError("failed to resolve working directory", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(cwd); err == nil {
+		cwd = resolved
+	}
+	root, err := workspaceLockRoot(options)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(cwd))
+	return cwd, filepath.Join(root, hex.EncodeToString(sum[:16])+".lock"), nil
+}
+
+// acquireWorkspaceLock locks the working directory of options as set with
+// WithWorkspaceLock, returning nil if it is not set. A lock held by
+// another is waited for, until ctx is done, or reported as a
+// WorkspaceBusyError, depending on the mode.
+func acquireWorkspaceLock(ctx context.Context, options *Options) (*workspaceLock, error) {
+	if options.WorkspaceLock == "" {
+		return nil, nil
+	}
+	cwd, path, err := workspaceLockPath(options)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, WrapClaudeSDKError("failed to create workspace lock directory", err)
+	}
+
+	for {
+		lock, holder, err := tryWorkspaceLock(path, cwd)
+		if err != nil || lock != nil {
+			return lock, err
+		}
+		busy := NewWorkspaceBusyError(cwd, holder)
+		if options.WorkspaceLock != WorkspaceLockQueue {
+			return nil, busy
+		}
+		select {
+		case <-ctx.Done():
+			busy.Cause = ctx.Err()
+			return nil, busy
+		case <-time.After(workspaceLockPollInterval):
+		}
+	}
+}
+
+// tryWorkspaceLock takes the lock file at path without waiting. If it is
+// held, it returns the PID of the holder, or 0 if unknown.
+func tryWorkspaceLock(path, cwd string) (*workspaceLock, int, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, 0, WrapClaudeSDKError("failed to open workspace lock", err)
+	}
+	locked, err := transport.TryLockFile(f)
+	if err != nil || !locked {
+		var holder workspaceLockHolder
+		if data, readErr := os.ReadFile(path); readErr == nil {
+			_ = json.Unmarshal(data, &holder)
+		}
+		_ = f.Close()
+		if err != nil {
+			return nil, 0, WrapClaudeSDKError("failed to lock workspace", err)
+		}
+		return nil, holder.PID, nil
+	}
+
+	// The holder is informational: the lock is what excludes others
+	data, _ := json.Marshal(workspaceLockHolder{PID: os.Getpid(), Cwd: cwd})
+	if err := f.Truncate(0); err == nil {
+		_, _ = f.WriteAt(data, 0)
+	}
+	return &workspaceLock{file: f}, 0, nil
+}
+
+// release releases the lock. The lock file is left in place: removing it
+// would let a process that opened it before lock a file no longer used.
+func (l *workspaceLock) release() {
+	if l == nil {
+		return
+	}
+	_ = l.file.Truncate(0)
+	_ = transport.UnlockFile(l.file)
+	_ = l.file.Close()
+}
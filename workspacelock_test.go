@@ -0,0 +1,84 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquireWorkspaceLock_Reject(t *testing.T) {
+	cwd, dir := t.TempDir(), t.TempDir()
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(cwd, link); err != nil {
+		t.Fatal(err)
+	}
+	opts := NewOptions(WithCwd(cwd), WithWorkspaceLock(WorkspaceLockReject), WithWorkspaceLockDir(dir))
+	lock, err := acquireWorkspaceLock(context.Background(), opts)
+	if err != nil || lock == nil {
+		t.Fatalf("Expected the lock, got %v", err)
+	}
+
+	// The same directory through a link shares the lock
+	other := NewOptions(WithCwd(link), WithWorkspaceLock(WorkspaceLockReject), WithWorkspaceLockDir(dir))
+	_, err = acquireWorkspaceLock(context.Background(), other)
+	busy, ok := AsWorkspaceBusyError(err)
+	if !ok {
+		t.Fatalf("Expected a WorkspaceBusyError, got %v", err)
+	}
+	resolved, _ := filepath.EvalSymlinks(cwd)
+	if busy.Cwd != resolved || busy.PID != os.Getpid() {
+		t.Errorf("Expected the directory and holder reported, got %+v", busy)
+	}
+
+	// Other directories are not locked
+	elsewhere := NewOptions(WithCwd(t.TempDir()), WithWorkspaceLock(WorkspaceLockReject), WithWorkspaceLockDir(dir))
+	unrelated, err := acquireWorkspaceLock(context.Background(), elsewhere)
+	if err != nil {
+		t.Fatalf("Expected another directory locked, got %v", err)
+	}
+	unrelated.release()
+
+	lock.release()
+	lock, err = acquireWorkspaceLock(context.Background(), other)
+	if err != nil {
+		t.Fatalf("Expected the lock once released, got %v", err)
+	}
+	lock.release()
+}
+
+func TestAcquireWorkspaceLock_Queue(t *testing.T) {
+	opts := NewOptions(WithCwd(t.TempDir()), WithWorkspaceLock(WorkspaceLockQueue), WithWorkspaceLockDir(t.TempDir()))
+	held, err := acquireWorkspaceLock(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*workspaceLockPollInterval)
+	defer cancel()
+	_, err = acquireWorkspaceLock(ctx, opts)
+	if !IsWorkspaceBusyError(err) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected a WorkspaceBusyError once the context is done, got %v", err)
+	}
+
+	time.AfterFunc(2*workspaceLockPollInterval, held.release)
+	lock, err := acquireWorkspaceLock(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Expected the lock once released, got %v", err)
+	}
+	lock.release()
+}
+
+func TestAcquireWorkspaceLock_Unset(t *testing.T) {
+	lock, err := acquireWorkspaceLock(context.Background(), NewOptions(WithWorkspaceLockDir(t.TempDir())))
+	if lock != nil || err != nil {
+		t.Errorf("Expected no lock without WithWorkspaceLock, got %v, %v", lock, err)
+	}
+	lock.release()
+
+	if err := validateOptions(NewOptions(WithWorkspaceLock("wait"))); err == nil {
+		t.Error("Expected an unknown mode to be invalid")
+	}
+}